@@ -41,9 +41,13 @@ func BotTokenList(c *fiber.Ctx) error {
 
 func BotTokenAdd(c *fiber.Ctx) error {
 	type RequestBody struct {
-		Name      string `json:"name"`
-		Avatar    string `json:"avatar"`
-		NickColor string `json:"nickColor"`
+		Name              string   `json:"name"`
+		Avatar            string   `json:"avatar"`
+		NickColor         string   `json:"nickColor"`
+		Scopes            []string `json:"scopes"`
+		AllowedWorldIDs   []string `json:"allowedWorldIds"`
+		AllowedChannelIDs []string `json:"allowedChannelIds"`
+		EventTypes        []string `json:"eventTypes"`
 	}
 	var data RequestBody
 	if err := c.BodyParser(&data); err != nil {
@@ -84,6 +88,11 @@ func BotTokenAdd(c *fiber.Ctx) error {
 		NickColor: nickColor,
 		Token:     utils.NewIDWithLength(32),
 		ExpiresAt: time.Now().UnixMilli() + 3*365*24*60*60*1e3, // 3 years
+
+		Scopes:            botNormalizeScopes(data.Scopes),
+		AllowedWorldIDs:   botNormalizeList(data.AllowedWorldIDs),
+		AllowedChannelIDs: botNormalizeList(data.AllowedChannelIDs),
+		EventTypes:        botNormalizeList(data.EventTypes),
 	}
 
 	err := db.Create(item).Error
@@ -105,6 +114,11 @@ func BotTokenUpdate(c *fiber.Ctx) error {
 		Name      string `json:"name"`
 		Avatar    string `json:"avatar"`
 		NickColor string `json:"nickColor"`
+		// 以下字段省略时保持不变，传空数组表示取消限制
+		Scopes            *[]string `json:"scopes"`
+		AllowedWorldIDs   *[]string `json:"allowedWorldIds"`
+		AllowedChannelIDs *[]string `json:"allowedChannelIds"`
+		EventTypes        *[]string `json:"eventTypes"`
 	}
 	var data RequestBody
 	if err := c.BodyParser(&data); err != nil {
@@ -132,10 +146,27 @@ func BotTokenUpdate(c *fiber.Ctx) error {
 	update["nick_color"] = nickColor
 	token.Avatar = strings.TrimSpace(data.Avatar)
 	token.NickColor = nickColor
+	if data.Scopes != nil {
+		token.Scopes = botNormalizeScopes(*data.Scopes)
+		update["scopes"] = token.Scopes
+	}
+	if data.AllowedWorldIDs != nil {
+		token.AllowedWorldIDs = botNormalizeList(*data.AllowedWorldIDs)
+		update["allowed_world_ids"] = token.AllowedWorldIDs
+	}
+	if data.AllowedChannelIDs != nil {
+		token.AllowedChannelIDs = botNormalizeList(*data.AllowedChannelIDs)
+		update["allowed_channel_ids"] = token.AllowedChannelIDs
+	}
+	if data.EventTypes != nil {
+		token.EventTypes = botNormalizeList(*data.EventTypes)
+		update["event_types"] = token.EventTypes
+	}
 
 	if err := db.Model(&model.BotTokenModel{}).Where("id = ?", data.ID).Updates(update).Error; err != nil {
 		return err
 	}
+	botTokenInvalidate(token.ID)
 	if err := service.SyncBotUserProfile(&token); err != nil {
 		return err
 	}
@@ -197,6 +228,8 @@ func BotTokenDelete(c *fiber.Ctx) error {
 	if err := tx.Commit().Error; err != nil {
 		return err
 	}
+	model.PermCacheInvalidateUser(token.ID)
+	botTokenInvalidate(token.ID)
 
 	return c.JSON(fiber.Map{
 		"message": "删除成功",
@@ -11,6 +11,7 @@ import (
 	"net/url"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/compress"
@@ -172,9 +173,9 @@ func applyPageTitleToIndex(htmlSource string, title string) string {
 func Init(config *utils.AppConfig, uiStatic fs.FS) {
 	appConfig = config
 	corsConfig := cors.New(cors.Config{
-		AllowMethods:     "GET, POST, PUT, DELETE, OPTIONS",
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, ObjectId",
-		ExposeHeaders:    "Content-Length, X-Access-Token-Refresh",
+		AllowMethods:     "GET, POST, PUT, PATCH, DELETE, HEAD, OPTIONS",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, ObjectId, Upload-Offset",
+		ExposeHeaders:    "Content-Length, X-Access-Token-Refresh, Upload-Offset, Upload-Length",
 		MaxAge:           3600,
 		AllowOrigins:     "",
 		AllowCredentials: true,
@@ -187,10 +188,18 @@ func Init(config *utils.AppConfig, uiStatic fs.FS) {
 
 	imageLimitBytes := int(config.ImageSizeLimit * 1024)
 	audioLimitBytes := int(config.Audio.MaxUploadSizeMB * 1024 * 1024)
+	mediaLimitBytes := int(config.Media.MaxUploadSizeMB * 1024 * 1024)
 	bodyLimit := imageLimitBytes
 	if audioLimitBytes > bodyLimit {
 		bodyLimit = audioLimitBytes
 	}
+	if mediaLimitBytes > bodyLimit {
+		bodyLimit = mediaLimitBytes
+	}
+	// 续传分片走原始请求体，预留一点余量
+	if chunkLimitBytes := int(config.ResumableUpload.ChunkSizeMB*1024*1024) + 64*1024; chunkLimitBytes > bodyLimit {
+		bodyLimit = chunkLimitBytes
+	}
 	if bodyLimit < 32*1024*1024 {
 		bodyLimit = 32 * 1024 * 1024
 	}
@@ -204,7 +213,8 @@ func Init(config *utils.AppConfig, uiStatic fs.FS) {
 	app.Use(compress.New(compress.Config{
 		Next: func(c *fiber.Ctx) bool {
 			path := c.Path()
-			return strings.HasPrefix(path, "/api/v1/audio/stream")
+			return strings.HasPrefix(path, "/api/v1/audio/stream") ||
+				(strings.HasPrefix(path, "/api/v1/attachment/") && strings.HasSuffix(path, "/stream"))
 		},
 	}))
 
@@ -249,12 +259,17 @@ func Init(config *utils.AppConfig, uiStatic fs.FS) {
 		}
 		return c.Status(http.StatusOK).JSON(resp)
 	})
+	v1.Get("/public/worlds", WorldDirectoryHandler)
 	v1.Get("/public/worlds/:worldId", WorldPublicDetail)
 	v1.Get("/public/worlds/:worldId/keywords", WorldKeywordPublicListHandler)
 	v1.Get("/public/worlds/:worldId/keywords/categories", WorldKeywordPublicCategoriesHandler)
 
-	v1.Get("/attachment/:id", AttachmentGet)
-	v1.Get("/attachment/:id/thumb", AttachmentThumb)
+	v1.Get("/calendar/:token.ics", CalendarFeedHandler)
+
+	v1.Get("/attachment/:id", OptionalSignCheckMiddleware, AttachmentGet)
+	v1.Get("/attachment/:id/thumb", OptionalSignCheckMiddleware, AttachmentThumb)
+	v1.Get("/attachment/:id/preview", OptionalSignCheckMiddleware, AttachmentPreview)
+	v1.Get("/attachment/:id/stream", OptionalSignCheckMiddleware, AttachmentStream)
 
 	// External webhook API (channelId + token auth) - 必须在 v1Auth 之前定义
 	v1.Get("/webhook/channels/:channelId/changes", WebhookAuthMiddleware, WebhookChanges)
@@ -295,12 +310,23 @@ func Init(config *utils.AppConfig, uiStatic fs.FS) {
 	v1Auth.Get("/timeline-list", TimelineList)
 	v1Auth.Post("/timeline-mark-read", TimelineMarkRead)
 
+	v1Auth.Get("/calendar/feed-token", CalendarFeedTokenHandler)
+	v1Auth.Post("/calendar/feed-token/rotate", CalendarFeedTokenRotateHandler)
+
 	v1Auth.Post("/upload", Upload)
 	v1Auth.Post("/upload-quick", UploadQuick)
 	v1Auth.Get("/attachments-list", AttachmentList)
 
 	v1Auth.Post("/attachment-upload", AttachmentUploadTempFile)
 	v1Auth.Post("/attachment-upload-quick", AttachmentUploadQuick)
+	v1Auth.Post("/attachment-upload-media", AttachmentUploadMedia)
+	v1Auth.Post("/upload-sessions", UploadSessionCreate)
+	v1Auth.Head("/upload-sessions/:id", UploadSessionHead)
+	v1Auth.Get("/upload-sessions/:id", UploadSessionGet)
+	v1Auth.Patch("/upload-sessions/:id", UploadSessionPatch)
+	v1Auth.Delete("/upload-sessions/:id", UploadSessionDelete)
+	v1Auth.Post("/upload-sessions/:id/confirm", UploadSessionConfirm)
+	v1Auth.Post("/attachment-presign", AttachmentPresignUpload)
 	v1Auth.Post("/attachment-confirm", AttachmentSetConfirm)
 	v1Auth.Post("/attachments-delete", AttachmentDelete)
 	v1Auth.Get("/attachment/:id/meta", AttachmentMeta)
@@ -384,7 +410,7 @@ func Init(config *utils.AppConfig, uiStatic fs.FS) {
 	v1Auth.Get("/status", StatusLatest)
 	v1Auth.Get("/status/history", StatusHistory)
 
-	audio := v1Auth.Group("/audio")
+	audio := v1Auth.Group("/audio", BotScopeMiddleware(model.BotScopeAudio))
 	audio.Get("/assets", AudioAssetList)
 	audio.Get("/assets/:id", AudioAssetGet)
 	audio.Get("/folders", AudioFolderList)
@@ -393,6 +419,7 @@ func Init(config *utils.AppConfig, uiStatic fs.FS) {
 	audio.Get("/state", AudioPlaybackStateGet)
 	audioAdmin := audio.Group("", AudioWorkbenchMiddleware)
 	audioAdmin.Post("/assets/upload", AudioAssetUpload)
+	audioAdmin.Post("/assets/upload-sessions", AudioUploadSessionCreate)
 	audioAdmin.Get("/assets/import/preview", AudioAssetImportPreview)
 	audioAdmin.Post("/assets/import", AudioAssetImport)
 	audioAdmin.Patch("/assets/:id", AudioAssetUpdate)
@@ -411,6 +438,15 @@ func Init(config *utils.AppConfig, uiStatic fs.FS) {
 	v1Auth.Get("/channels/:channelId/speaker-options", ChannelSpeakerOptions)
 	v1Auth.Get("/channels/:channelId/speaker-role-options", ChannelSpeakerRoleOptions)
 	v1Auth.Post("/channels/:channelId/copy", ChannelCopy)
+	v1Auth.Post("/channels/:channelId/move", ChannelMoveHandler)
+	v1Auth.Post("/channels/:channelId/perm-override", ChannelPermOverrideHandler)
+	v1Auth.Get("/channels/:channelId/perm-overwrites", ChannelPermOverwriteListHandler)
+	v1Auth.Put("/channels/:channelId/perm-overwrites/:userId", ChannelPermOverwriteSetHandler)
+	v1Auth.Delete("/channels/:channelId/perm-overwrites/:userId", ChannelPermOverwriteDeleteHandler)
+	v1Auth.Get("/channels/:channelId/perm-explain", ChannelPermExplainHandler)
+	v1Auth.Get("/channels/:channelId/effective-perms", ChannelEffectivePermsHandler)
+	v1Auth.Post("/channels/:channelId/read-receipts", ChannelReadReceiptsHandler)
+	v1Auth.Get("/channels/:channelId/messages/:messageId/seen-by", MessageSeenByHandler)
 	v1Auth.Delete("/channels/:channelId", ChannelDissolve)
 	v1Auth.Post("/channel-background-edit", ChannelBackgroundEdit)
 	v1Auth.Post("/channel-info-edit", ChannelInfoEdit)
@@ -430,6 +466,29 @@ func Init(config *utils.AppConfig, uiStatic fs.FS) {
 	worldGroup.Delete("/:worldId", WorldDeleteHandler)
 	worldGroup.Post("/:worldId/join", WorldJoinHandler)
 	worldGroup.Post("/:worldId/leave", WorldLeaveHandler)
+	worldGroup.Post("/:worldId/applications", WorldApplicationSubmitHandler)
+	worldGroup.Get("/:worldId/applications", WorldApplicationListHandler)
+	worldGroup.Get("/:worldId/applications/mine", WorldApplicationMineHandler)
+	worldGroup.Post("/:worldId/applications/withdraw", WorldApplicationWithdrawHandler)
+	worldGroup.Post("/:worldId/applications/:applicationId/review", WorldApplicationReviewHandler)
+	worldGroup.Get("/:worldId/bans", WorldBanListHandler)
+	worldGroup.Post("/:worldId/bans", WorldBanCreateHandler)
+	worldGroup.Delete("/:worldId/bans/:banId", WorldBanRevokeHandler)
+	worldGroup.Get("/:worldId/roles", WorldRoleListHandler)
+	worldGroup.Post("/:worldId/roles", WorldRoleCreateHandler)
+	worldGroup.Post("/:worldId/roles/reorder", WorldRoleReorderHandler)
+	worldGroup.Patch("/:worldId/roles/:roleId", WorldRoleUpdateHandler)
+	worldGroup.Delete("/:worldId/roles/:roleId", WorldRoleDeleteHandler)
+	worldGroup.Post("/:worldId/roles/:roleId/members", WorldRoleMembersHandler)
+	worldGroup.Get("/:worldId/roles/:roleId/overrides", WorldRoleOverrideListHandler)
+	worldGroup.Delete("/:worldId/roles/:roleId/overrides/:channelId", WorldRoleOverrideClearHandler)
+	worldGroup.Get("/:worldId/sessions", WorldSessionListHandler)
+	worldGroup.Post("/:worldId/sessions", WorldSessionCreateHandler)
+	worldGroup.Get("/:worldId/sessions/:sessionId", WorldSessionDetailHandler)
+	worldGroup.Patch("/:worldId/sessions/:sessionId", WorldSessionUpdateHandler)
+	worldGroup.Delete("/:worldId/sessions/:sessionId", WorldSessionCancelHandler)
+	worldGroup.Post("/:worldId/sessions/:sessionId/rsvp", WorldSessionRSVPHandler)
+	worldGroup.Get("/:worldId/sessions/:sessionId/rsvps", WorldSessionRSVPListHandler)
 	worldGroup.Get("/:worldId/sections", WorldSectionsHandler)
 	worldGroup.Post("/:worldId/invites", WorldInviteCreateHandler)
 	worldGroup.Get("/favorites", WorldFavoriteListHandler)
@@ -447,6 +506,9 @@ func Init(config *utils.AppConfig, uiStatic fs.FS) {
 	worldGroup.Post("/:worldId/keywords/reorder", WorldKeywordReorderHandler)
 	worldGroup.Post("/:worldId/keywords/import", WorldKeywordImportHandler)
 	worldGroup.Get("/:worldId/keywords/export", WorldKeywordExportHandler)
+	worldGroup.Get("/:worldId/package/export", WorldPackageExportHandler)
+	worldGroup.Post("/package/preview", WorldPackagePreviewHandler)
+	worldGroup.Post("/package/import", WorldPackageImportHandler)
 	worldGroup.Get("/:worldId/archived-channels", ArchivedChannelList)
 	v1Auth.Post("/worlds/invites/:slug/consume", WorldInviteConsumeHandler)
 	v1Auth.Post("/channels/archive", ChannelArchive)
@@ -497,6 +559,7 @@ func Init(config *utils.AppConfig, uiStatic fs.FS) {
 	v1AuthAdmin.Get("/admin/user-check-username", AdminCheckUsername)
 	v1AuthAdmin.Get("/admin/user-import-template", AdminUserImportTemplate)
 	v1AuthAdmin.Post("/admin/user-batch-create", AdminUserBatchCreate)
+	v1AuthAdmin.Post("/admin/world-feature", AdminWorldFeature)
 	v1AuthAdmin.Get("/admin/update-status", AdminUpdateStatus)
 	v1AuthAdmin.Post("/admin/update-check", AdminUpdateCheck)
 	v1AuthAdmin.Post("/admin/update-version", AdminUpdateVersion)
@@ -562,6 +625,9 @@ func Init(config *utils.AppConfig, uiStatic fs.FS) {
 	}))
 
 	websocketWorks(app)
+	service.StartWorldSessionWorker(30*time.Second, announceWorldSession)
+	satoriWorks(app)
+	oneBotWorks(app)
 
 	// Check port availability and find fallback if needed
 	listenAddr := config.ServeAt
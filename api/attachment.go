@@ -79,6 +79,9 @@ func Upload(c *fiber.Ctx) error {
 		return wrapError(c, err, "上传失败，请重试")
 	}
 	channelId := getHeader(c, "Channelid") // header中只能首字大写
+	if err := channelSpeakCheckHTTP(c, channelId, getCurUser(c).ID); err != nil {
+		return wrapErrorStatus(c, fiber.StatusForbidden, err, err.Error())
+	}
 
 	// 获取上传的文件切片
 	files := form.File["file"]
@@ -246,6 +249,11 @@ func AttachmentMeta(c *fiber.Ctx) error {
 			"hash":        att.Hash,
 			"mimeType":    att.MimeType,
 			"isAnimated":  att.IsAnimated,
+			"mediaKind":   att.MediaKind,
+			"width":       att.Width,
+			"height":      att.Height,
+			"duration":    att.Duration,
+			"previewText": att.PreviewText,
 			"storageType": att.StorageType,
 			"objectKey":   att.ObjectKey,
 			"externalUrl": att.ExternalURL,
@@ -315,6 +323,27 @@ func redirectAttachmentToRemote(c *fiber.Ctx, att *model.AttachmentModel) bool {
 	if att == nil {
 		return false
 	}
+	manager := service.GetStorageManager()
+	if att.StorageType == model.StorageS3 && manager.PrivateBucket() {
+		// 私有桶的签名链接等同于临时授权，签发前先校验访问权限
+		var userID string
+		if user := getCurUser(c); user != nil {
+			userID = user.ID
+		}
+		if !service.CanUserAccessAttachment(userID, att) {
+			_ = c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "无权访问此附件",
+			})
+			return true
+		}
+		target := service.AttachmentRemoteURL(att)
+		if target == "" {
+			return false
+		}
+		c.Set("Cache-Control", "private, no-store")
+		_ = c.Redirect(target, fiber.StatusTemporaryRedirect)
+		return true
+	}
 	target := service.AttachmentPublicURL(att)
 	if target == "" {
 		return false
@@ -0,0 +1,280 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/afero"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/service"
+	"sealchat/utils"
+)
+
+// AttachmentUploadMedia 上传视频、PDF、文本等非图片附件
+// POST /api/v1/attachment-upload-media
+// 文件以流的方式写入临时目录，MIME 由服务端嗅探，随后生成海报帧/首页预览
+func AttachmentUploadMedia(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	form, err := c.MultipartForm()
+	if err != nil {
+		return wrapError(c, err, "提交的数据存在问题")
+	}
+	getFromForm := func(key string) string {
+		if v, exists := form.Value[key]; exists && len(v) > 0 {
+			return strings.TrimSpace(v[0])
+		}
+		return ""
+	}
+
+	channelID := getFromForm("channelId")
+	if channelID == "" {
+		return wrapError(c, nil, "缺少频道ID")
+	}
+	if !pm.CanWithChannelRole(user.ID, channelID, pm.PermFuncChannelMediaSend) {
+		return wrapErrorStatus(c, fiber.StatusForbidden, nil, "您没有在此频道发送视频或文件的权限")
+	}
+	if err := channelSpeakCheckHTTP(c, channelID, user.ID); err != nil {
+		return wrapErrorStatus(c, fiber.StatusForbidden, err, err.Error())
+	}
+
+	files := form.File["file"]
+	if len(files) == 0 {
+		return wrapError(c, nil, "未找到上传的文件")
+	}
+
+	cfg := appConfig.Media
+	limit := cfg.MaxUploadSizeMB * 1024 * 1024
+	tmpDir := appConfig.Storage.Local.TempDir
+	if strings.TrimSpace(tmpDir) == "" {
+		tmpDir = "./data/temp/"
+	}
+	_ = appFs.MkdirAll(tmpDir, 0755)
+
+	var items []*model.AttachmentModel
+	for _, file := range files {
+		if limit > 0 && file.Size > limit {
+			return wrapErrorStatus(c, fiber.StatusRequestEntityTooLarge, ErrFileTooLarge, fmt.Sprintf("文件大小超过限制（%dMB）", cfg.MaxUploadSizeMB))
+		}
+		tempFile, err := afero.TempFile(appFs, tmpDir, "*.upload")
+		if err != nil {
+			return wrapErrorStatus(c, fiber.StatusInternalServerError, err, "创建临时文件失败")
+		}
+		tempPath := tempFile.Name()
+		hash, size, err := saveMediaUpload(file, tempFile, limit)
+		_ = tempFile.Close()
+		if err != nil {
+			_ = os.Remove(tempPath)
+			if errors.Is(err, ErrFileTooLarge) {
+				return wrapErrorStatus(c, fiber.StatusRequestEntityTooLarge, err, fmt.Sprintf("文件大小超过限制（%dMB）", cfg.MaxUploadSizeMB))
+			}
+			return wrapErrorStatus(c, fiber.StatusInternalServerError, err, "保存上传文件失败")
+		}
+
+		mimeType, err := service.SniffAttachmentMime(tempPath)
+		if err != nil || !service.AttachmentMimeAllowed(mimeType, cfg.AllowedMimeTypes) {
+			_ = os.Remove(tempPath)
+			return wrapErrorStatus(c, fiber.StatusUnsupportedMediaType, err, fmt.Sprintf("不支持的文件类型：%s", mimeType))
+		}
+
+		media := service.PrepareAttachmentMedia(tempPath, mimeType, hash, size, cfg.PreviewEnabled)
+		location, err := service.PersistAttachmentFile(hash, size, tempPath, mimeType)
+		if err != nil {
+			_ = os.Remove(tempPath)
+			return wrapErrorStatus(c, fiber.StatusInternalServerError, err, "保存附件失败")
+		}
+
+		attachment := &model.AttachmentModel{
+			Filename:      filepath.Base(file.Filename),
+			Size:          size,
+			Hash:          hash,
+			MimeType:      mimeType,
+			MediaKind:     media.Kind,
+			Width:         media.Width,
+			Height:        media.Height,
+			Duration:      media.Duration,
+			PreviewText:   media.PreviewText,
+			UserID:        user.ID,
+			ChannelID:     channelID,
+			StorageType:   location.StorageType,
+			ObjectKey:     location.ObjectKey,
+			ExternalURL:   location.ExternalURL,
+			IsTemp:        true,
+			CreatorName:   user.Nickname,
+			CreatorAvatar: user.Avatar,
+		}
+		attachment.ID = utils.NewID()
+		if err := model.GetDB().Create(attachment).Error; err != nil {
+			_ = os.Remove(tempPath)
+			return wrapErrorStatus(c, fiber.StatusInternalServerError, err, "保存附件失败")
+		}
+		items = append(items, attachment)
+	}
+
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	return c.JSON(fiber.Map{
+		"message": "上传成功",
+		"ids":     ids,
+		"items":   items,
+	})
+}
+
+// saveMediaUpload 边读边计算哈希写入临时文件，避免大文件整体读入内存
+func saveMediaUpload(fh *multipart.FileHeader, dst io.Writer, limit int64) ([]byte, int64, error) {
+	src, err := fh.Open()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer src.Close()
+	var reader io.Reader = src
+	if limit > 0 {
+		reader = io.LimitReader(src, limit+1)
+	}
+	hash, size, err := copyWithHash(dst, reader)
+	if err != nil {
+		return nil, size, err
+	}
+	if limit > 0 && size > limit {
+		return nil, size, ErrFileTooLarge
+	}
+	return hash, size, nil
+}
+
+// AttachmentPreview 返回附件的预览图：图片走缩略图，视频返回海报帧，PDF 返回首页
+// GET /api/v1/attachment/:id/preview?size=300
+func AttachmentPreview(c *fiber.Ctx) error {
+	attachmentID := c.Params("id")
+	if attachmentID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "无效的附件ID",
+		})
+	}
+
+	size := c.QueryInt("size", 300)
+	if size < 50 {
+		size = 50
+	}
+	if size > 800 {
+		size = 800
+	}
+
+	var att model.AttachmentModel
+	if err := model.GetDB().Where("id = ?", attachmentID).Limit(1).Find(&att).Error; err != nil {
+		return wrapError(c, err, "读取附件失败")
+	}
+	if att.ID == "" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "附件不存在",
+		})
+	}
+
+	kind := att.MediaKind
+	if kind == "" {
+		kind = service.AttachmentMediaKind(att.MimeType)
+	}
+	if kind == service.AttachmentKindImage {
+		return AttachmentThumb(c)
+	}
+	if kind != service.AttachmentKindVideo && kind != service.AttachmentKindDocument {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "该附件没有预览图",
+		})
+	}
+
+	thumbPath := filepath.Join(defaultThumbDir, fmt.Sprintf("%s_%d_preview.webp", att.ID, size))
+	if _, err := os.Stat(thumbPath); err == nil {
+		setThumbCacheHeaders(c)
+		return c.SendFile(thumbPath)
+	}
+
+	posterPath := service.AttachmentPosterPath(att.Hash, att.Size)
+	if _, err := os.Stat(posterPath); err != nil {
+		if !appConfig.Media.PreviewEnabled {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "该附件没有预览图",
+			})
+		}
+		// 旧附件或上传时工具不可用，尝试对本地文件补生成
+		originalPath, err := getAttachmentPath(&att)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "该附件没有预览图",
+			})
+		}
+		media := service.PrepareAttachmentMedia(originalPath, att.MimeType, att.Hash, att.Size, true)
+		if media.PosterPath == "" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "预览图生成失败",
+			})
+		}
+		posterPath = media.PosterPath
+	}
+
+	if err := generateThumbnail(posterPath, thumbPath, size); err != nil {
+		return wrapErrorStatus(c, fiber.StatusInternalServerError, err, "预览图生成失败")
+	}
+	setThumbCacheHeaders(c)
+	return c.SendFile(thumbPath)
+}
+
+// AttachmentStream 以 Range 方式输出附件内容，供视频播放器拖动进度
+// GET /api/v1/attachment/:id/stream
+func AttachmentStream(c *fiber.Ctx) error {
+	attachmentID := c.Params("id")
+	if attachmentID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "无效的附件ID",
+		})
+	}
+	var att model.AttachmentModel
+	if err := model.GetDB().Where("id = ?", attachmentID).Limit(1).Find(&att).Error; err != nil {
+		return wrapError(c, err, "读取附件失败")
+	}
+	if att.ID == "" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "附件不存在",
+		})
+	}
+	if att.StorageType == model.StorageS3 {
+		if redirectAttachmentToRemote(c, &att) {
+			return nil
+		}
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "附件文件不存在",
+		})
+	}
+
+	path, err := getAttachmentPath(&att)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "附件文件不存在",
+		})
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return wrapError(c, err, "读取附件失败")
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return wrapError(c, err, "读取附件失败")
+	}
+
+	setAttachmentCacheHeaders(c, &att)
+	setAttachmentContentType(c, &att)
+	contentType := strings.TrimSpace(att.MimeType)
+	if contentType == "" || !isInlineAttachmentMime(strings.ToLower(contentType)) {
+		contentType = "application/octet-stream"
+	}
+	return streamFileWithRange(c, file, info.Size(), contentType)
+}
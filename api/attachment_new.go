@@ -121,6 +121,10 @@ func UploadRaw(c *fiber.Ctx, uploadCallback func(item *model.AttachmentModel)) (
 	rootId := getFromForm("rootId")
 	rootIdType := getFromForm("rootIdType")
 	extra := getFromForm("extra")
+	channelID := resolveUploadChannelID(c, ui.ID, getFromForm("channelId"))
+	if err := channelSpeakCheckHTTP(c, channelID, ui.ID); err != nil {
+		return nil, err
+	}
 
 	// 遍历每个文件
 	err, ids, filenames := uploadFiles(files, ui.ID, func(item *model.AttachmentModel) {
@@ -129,6 +133,7 @@ func UploadRaw(c *fiber.Ctx, uploadCallback func(item *model.AttachmentModel)) (
 		item.RootID = rootId
 		item.RootIDType = rootIdType
 		item.Extra = extra
+		item.ChannelID = channelID
 
 		item.UserID = ui.ID
 		item.CreatorName = ui.Nickname
@@ -156,6 +161,18 @@ func UploadRaw(c *fiber.Ctx, uploadCallback func(item *model.AttachmentModel)) (
 	}, nil
 }
 
+// resolveUploadChannelID 取表单字段或 ChannelId 请求头中的频道，仅在上传者能阅读该频道时记录到附件上
+func resolveUploadChannelID(c *fiber.Ctx, userID, channelID string) string {
+	channelID = strings.TrimSpace(channelID)
+	if channelID == "" {
+		channelID = strings.TrimSpace(c.Get("ChannelId"))
+	}
+	if channelID == "" || !service.CanReadChannelByUserId(userID, channelID) {
+		return ""
+	}
+	return channelID
+}
+
 func AttachmentUploadTempFile(c *fiber.Ctx) error {
 	// 使用 UploadRaw 重构函数
 	result, err := UploadRaw(c, func(item *model.AttachmentModel) {
@@ -182,6 +199,7 @@ func AttachmentUploadQuick(c *fiber.Ctx) error {
 		RootId       string `json:"rootId"`
 		ParentIdType string `json:"parentIdType"`
 		ParentId     string `json:"parentId"`
+		ChannelId    string `json:"channelId"`
 	}
 	if err := c.BodyParser(&body); err != nil {
 		return wrapError(c, err, "提交的数据存在问题")
@@ -192,6 +210,11 @@ func AttachmentUploadQuick(c *fiber.Ctx) error {
 		return wrapError(c, err, "提交的数据存在问题")
 	}
 
+	channelID := resolveUploadChannelID(c, ui.ID, body.ChannelId)
+	if err := channelSpeakCheckHTTP(c, channelID, ui.ID); err != nil {
+		return wrapErrorStatus(c, fiber.StatusForbidden, err, err.Error())
+	}
+
 	db := model.GetDB()
 	var item model.AttachmentModel
 	db.Where("hash = ? and size = ?", hashBytes, body.Size).Limit(1).Find(&item)
@@ -214,8 +237,9 @@ func AttachmentUploadQuick(c *fiber.Ctx) error {
 		RootID:       body.RootId,
 		RootIDType:   body.RootIdType,
 
-		Extra: body.Extra,
-		Note:  body.Note,
+		Extra:     body.Extra,
+		Note:      body.Note,
+		ChannelID: channelID,
 
 		UserID:        ui.ID,
 		CreatorName:   ui.Nickname,
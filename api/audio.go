@@ -133,20 +133,8 @@ func AudioAssetUpload(c *fiber.Ctx) error {
 		worldID = &worldIDVal
 	}
 	// 权限校验
-	if scope == model.AudioScopeCommon {
-		// 只有系统管理员可以上传 common 素材
-		if !isSystemAdmin {
-			return wrapErrorStatus(c, fiber.StatusForbidden, nil, "仅平台管理员可上传通用素材")
-		}
-	} else if scope == model.AudioScopeWorld {
-		// 世界级素材必须指定 worldId
-		if worldID == nil || *worldID == "" {
-			return wrapErrorStatus(c, fiber.StatusBadRequest, nil, "世界级素材必须指定 worldId")
-		}
-		// 检查是否为世界管理员
-		if !isSystemAdmin && !service.IsWorldAdmin(*worldID, user.ID) {
-			return wrapErrorStatus(c, fiber.StatusForbidden, nil, "仅世界管理员可上传此世界的素材")
-		}
+	if status, message := checkAudioUploadScope(user.ID, isSystemAdmin, scope, worldID); status != 0 {
+		return wrapErrorStatus(c, status, nil, message)
 	}
 	asset, err := service.AudioCreateAssetFromUpload(file, service.AudioUploadOptions{
 		Name:        name,
@@ -182,6 +170,26 @@ func AudioAssetUpload(c *fiber.Ctx) error {
 	})
 }
 
+// checkAudioUploadScope 校验上传者能否向指定范围写入素材，通过时返回 0
+func checkAudioUploadScope(userID string, isSystemAdmin bool, scope model.AudioAssetScope, worldID *string) (int, string) {
+	if scope == model.AudioScopeCommon {
+		// 只有系统管理员可以上传 common 素材
+		if !isSystemAdmin {
+			return fiber.StatusForbidden, "仅平台管理员可上传通用素材"
+		}
+	} else if scope == model.AudioScopeWorld {
+		// 世界级素材必须指定 worldId
+		if worldID == nil || *worldID == "" {
+			return fiber.StatusBadRequest, "世界级素材必须指定 worldId"
+		}
+		// 检查是否为世界管理员
+		if !isSystemAdmin && !service.IsWorldAdmin(*worldID, userID) {
+			return fiber.StatusForbidden, "仅世界管理员可上传此世界的素材"
+		}
+	}
+	return 0, ""
+}
+
 func AudioAssetImportPreview(c *fiber.Ctx) error {
 	cfg := utils.GetConfig()
 	if cfg == nil || strings.TrimSpace(cfg.Audio.ImportDir) == "" {
@@ -70,7 +70,7 @@ func apiCharacterGet(ctx *ChatContext, msg []byte) {
 	}
 
 	// Forward request to BOT and wait for response
-	resp := forwardCharacterRequest(botConn, "character.get", data.Echo, data.Data)
+	resp := forwardCharacterRequest(botConn, botInfo, "character.get", data.Echo, data.Data)
 	if resp == nil {
 		sendCharacterError(ctx, data.Echo, "请求超时")
 		return
@@ -105,7 +105,7 @@ func apiCharacterSet(ctx *ChatContext, msg []byte) {
 		return
 	}
 
-	resp := forwardCharacterRequest(botConn, "character.set", data.Echo, data.Data)
+	resp := forwardCharacterRequest(botConn, botInfo, "character.set", data.Echo, data.Data)
 	if resp == nil {
 		sendCharacterError(ctx, data.Echo, "请求超时")
 		return
@@ -145,7 +145,7 @@ func apiCharacterList(ctx *ChatContext, msg []byte) {
 		return
 	}
 
-	resp := forwardCharacterRequest(botConn, "character.list", data.Echo, data.Data)
+	resp := forwardCharacterRequest(botConn, botInfo, "character.list", data.Echo, data.Data)
 	if resp == nil {
 		sendCharacterError(ctx, data.Echo, "请求超时")
 		return
@@ -193,7 +193,7 @@ func apiCharacterCapabilityTest(ctx *ChatContext, msg []byte) {
 	}
 
 	echo := "bot-cap-test-" + utils.NewID()
-	resp := forwardCharacterRequestWithTimeout(botConn, "character.list", echo, payload, botCharacterProbeTimeout)
+	resp := forwardCharacterRequestWithTimeout(botConn, botInfo, "character.list", echo, payload, botCharacterProbeTimeout)
 	if resp == nil {
 		botInfo.BotCharacterSupport = BotCharacterSupportNo
 		botInfo.BotCharacterProbeFail++
@@ -229,32 +229,51 @@ func findBotConnectionForChannel(ctx *ChatContext, channelID string) (*WsSyncCon
 	if err != nil {
 		return nil, nil, errors.New(botCharacterUnsupportedText)
 	}
-	if x, ok := userId2ConnInfoGlobal.Load(botID); ok {
-		var activeConn *WsSyncConn
-		var activeInfo *ConnInfo
-		var activeAt int64 = -1
-		x.Range(func(conn *WsSyncConn, value *ConnInfo) bool {
-			if value == nil {
-				return true
-			}
-			lastAlive := value.LastAliveTime
-			if lastAlive == 0 {
-				lastAlive = value.LastPingTime
-			}
-			if lastAlive > activeAt {
-				activeAt = lastAlive
-				activeConn = conn
-				activeInfo = value
-			}
-			return true
-		})
-		if activeConn != nil {
-			return activeConn, activeInfo, nil
-		}
+	if conn, info := findLocalBotConnection(botID); conn != nil {
+		return conn, info, nil
+	}
+	// BOT 可能连接在其他实例上，确认对方实例持有连接后交由事件总线转发
+	if found, support := probeRemoteBot(botID); found {
+		return nil, &ConnInfo{
+			User:                &model.UserModel{},
+			RemoteBotID:         botID,
+			BotCharacterSupport: support,
+		}, nil
 	}
 	return nil, nil, errors.New(botCharacterUnsupportedText)
 }
 
+// findLocalBotConnection 返回本实例上该 BOT 最近活跃的连接
+func findLocalBotConnection(botID string) (*WsSyncConn, *ConnInfo) {
+	if userId2ConnInfoGlobal == nil || botID == "" {
+		return nil, nil
+	}
+	x, ok := userId2ConnInfoGlobal.Load(botID)
+	if !ok {
+		return nil, nil
+	}
+	var activeConn *WsSyncConn
+	var activeInfo *ConnInfo
+	var activeAt int64 = -1
+	x.Range(func(conn *WsSyncConn, value *ConnInfo) bool {
+		// 标准协议连接不支持人物卡等站内扩展请求
+		if value == nil || conn.adapter != nil {
+			return true
+		}
+		lastAlive := value.LastAliveTime
+		if lastAlive == 0 {
+			lastAlive = value.LastPingTime
+		}
+		if lastAlive > activeAt {
+			activeAt = lastAlive
+			activeConn = conn
+			activeInfo = value
+		}
+		return true
+	})
+	return activeConn, activeInfo
+}
+
 func GetChannelCharacterAPICapability(channelID string, channel *model.ChannelModel) (bool, string) {
 	channelID = strings.TrimSpace(channelID)
 	if channelID == "" {
@@ -280,29 +299,11 @@ func GetChannelCharacterAPICapability(channelID string, channel *model.ChannelMo
 		return false, botCharacterUnsupportedText
 	}
 
-	connMap, ok := userId2ConnInfoGlobal.Load(botID)
-	if !ok || connMap == nil {
-		return false, botCharacterUnsupportedText
-	}
-
-	var activeInfo *ConnInfo
-	var activeAt int64 = -1
-	connMap.Range(func(_ *WsSyncConn, value *ConnInfo) bool {
-		if value == nil {
-			return true
-		}
-		lastAlive := value.LastAliveTime
-		if lastAlive == 0 {
-			lastAlive = value.LastPingTime
-		}
-		if lastAlive > activeAt {
-			activeAt = lastAlive
-			activeInfo = value
-		}
-		return true
-	})
-
+	_, activeInfo := findLocalBotConnection(botID)
 	if activeInfo == nil {
+		if found, support := probeRemoteBot(botID); found && support == BotCharacterSupportYes {
+			return true, ""
+		}
 		return false, botCharacterUnsupportedText
 	}
 
@@ -388,7 +389,7 @@ func startBotCharacterCapabilityProbe(info *ConnInfo) {
 	}
 	echo := "bot-cap-probe-" + utils.NewID()
 	go func() {
-		resp := forwardCharacterRequestWithTimeout(botConn, "character.list", echo, map[string]any{
+		resp := forwardCharacterRequestWithTimeout(botConn, info, "character.list", echo, map[string]any{
 			"user_id": info.User.ID,
 		}, botCharacterProbeTimeout)
 		if resp == nil {
@@ -462,12 +463,16 @@ func parseBoolean(value any) (bool, bool) {
 }
 
 // forwardCharacterRequest forwards a character API request to a BOT
-func forwardCharacterRequest(botConn *WsSyncConn, api, echo string, data any) json.RawMessage {
-	return forwardCharacterRequestWithTimeout(botConn, api, echo, data, characterRequestTimeout)
+func forwardCharacterRequest(botConn *WsSyncConn, botInfo *ConnInfo, api, echo string, data any) json.RawMessage {
+	return forwardCharacterRequestWithTimeout(botConn, botInfo, api, echo, data, characterRequestTimeout)
 }
 
-func forwardCharacterRequestWithTimeout(botConn *WsSyncConn, api, echo string, data any, timeout time.Duration) json.RawMessage {
-	if botConn == nil {
+func forwardCharacterRequestWithTimeout(botConn *WsSyncConn, botInfo *ConnInfo, api, echo string, data any, timeout time.Duration) json.RawMessage {
+	remoteBotID := ""
+	if botInfo != nil {
+		remoteBotID = botInfo.RemoteBotID
+	}
+	if botConn == nil && remoteBotID == "" {
 		return nil
 	}
 
@@ -484,13 +489,19 @@ func forwardCharacterRequestWithTimeout(botConn *WsSyncConn, api, echo string, d
 	defer characterPendingRequests.Delete(echo)
 
 	// Send request to BOT
-	req := map[string]any{
-		"api":  api,
-		"echo": echo,
-		"data": data,
-	}
-	if err := botConn.WriteJSON(req); err != nil {
-		return nil
+	if remoteBotID != "" {
+		if err := publishCharacterRequest(remoteBotID, api, echo, data); err != nil {
+			return nil
+		}
+	} else {
+		req := map[string]any{
+			"api":  api,
+			"echo": echo,
+			"data": data,
+		}
+		if err := botConn.WriteJSON(req); err != nil {
+			return nil
+		}
 	}
 
 	// Wait for response with timeout
@@ -507,7 +518,8 @@ func forwardCharacterRequestWithTimeout(botConn *WsSyncConn, api, echo string, d
 func HandleCharacterResponse(echo string, data json.RawMessage) bool {
 	pending, ok := characterPendingRequests.Load(echo)
 	if !ok {
-		return false
+		// 其他实例转发来的请求，响应需送回发起实例
+		return relayCharacterResponse(echo, data)
 	}
 
 	req := pending.(*CharacterPendingRequest)
@@ -605,7 +617,7 @@ func apiCharacterNew(ctx *ChatContext, msg []byte) {
 		return
 	}
 
-	resp := forwardCharacterRequest(botConn, "character.new", data.Echo, data.Data)
+	resp := forwardCharacterRequest(botConn, botInfo, "character.new", data.Echo, data.Data)
 	if resp == nil {
 		sendCharacterError(ctx, data.Echo, "请求超时")
 		return
@@ -639,7 +651,7 @@ func apiCharacterSave(ctx *ChatContext, msg []byte) {
 		return
 	}
 
-	resp := forwardCharacterRequest(botConn, "character.save", data.Echo, data.Data)
+	resp := forwardCharacterRequest(botConn, botInfo, "character.save", data.Echo, data.Data)
 	if resp == nil {
 		sendCharacterError(ctx, data.Echo, "请求超时")
 		return
@@ -673,7 +685,7 @@ func apiCharacterTag(ctx *ChatContext, msg []byte) {
 		return
 	}
 
-	resp := forwardCharacterRequest(botConn, "character.tag", data.Echo, data.Data)
+	resp := forwardCharacterRequest(botConn, botInfo, "character.tag", data.Echo, data.Data)
 	if resp == nil {
 		sendCharacterError(ctx, data.Echo, "请求超时")
 		return
@@ -713,7 +725,7 @@ func apiCharacterUntagAll(ctx *ChatContext, msg []byte) {
 		return
 	}
 
-	resp := forwardCharacterRequest(botConn, "character.untagAll", data.Echo, data.Data)
+	resp := forwardCharacterRequest(botConn, botInfo, "character.untagAll", data.Echo, data.Data)
 	if resp == nil {
 		sendCharacterError(ctx, data.Echo, "请求超时")
 		return
@@ -747,7 +759,7 @@ func apiCharacterLoad(ctx *ChatContext, msg []byte) {
 		return
 	}
 
-	resp := forwardCharacterRequest(botConn, "character.load", data.Echo, data.Data)
+	resp := forwardCharacterRequest(botConn, botInfo, "character.load", data.Echo, data.Data)
 	if resp == nil {
 		sendCharacterError(ctx, data.Echo, "请求超时")
 		return
@@ -788,7 +800,7 @@ func apiCharacterDelete(ctx *ChatContext, msg []byte) {
 		return
 	}
 
-	resp := forwardCharacterRequest(botConn, "character.delete", data.Echo, data.Data)
+	resp := forwardCharacterRequest(botConn, botInfo, "character.delete", data.Echo, data.Data)
 	if resp == nil {
 		sendCharacterError(ctx, data.Echo, "请求超时")
 		return
@@ -0,0 +1,361 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/protocol"
+	"sealchat/service"
+	"sealchat/utils"
+)
+
+// BOT 斜杠指令：BOT 经 bot.command.register 声明带参数表的指令后，
+// 用户发送的 "/指令" 由服务端解析，以 interaction/command 事件只投递给声明该指令的 BOT，不作为普通消息落库
+
+const (
+	botCommandInteractionTTL      = 15 * time.Minute
+	botCommandAutocompleteTimeout = 3 * time.Second
+)
+
+// botCommandDecls 各 BOT 声明的结构化指令，与 commandTips 一样仅保存在内存中，BOT 重连后重新注册
+var botCommandDecls utils.SyncMap[string, []protocol.Command]
+
+type botCommandInteraction struct {
+	BotID     string
+	UserID    string
+	ChannelID string
+	ExpiresAt time.Time
+}
+
+var botCommandInteractions utils.SyncMap[string, *botCommandInteraction]
+
+// botCommandRegisterDecls 保存结构化指令，同时写入 commandTips 供 /commands 列表展示
+func botCommandRegisterDecls(botID string, commands []protocol.Command) {
+	botCommandDecls.Store(botID, commands)
+	tips := make(map[string]string, len(commands))
+	for _, cmd := range commands {
+		desc := cmd.Description["zh-CN"]
+		if desc == "" {
+			for _, v := range cmd.Description {
+				desc = v
+				break
+			}
+		}
+		tips[cmd.Name] = desc
+	}
+	commandTips.Store(botID, tips)
+}
+
+// botCommandChannelBots 返回频道内可接收指令的 BOT，按 ID 排序保证同名指令的归属稳定
+func botCommandChannelBots(userID, channelID string) []string {
+	ids := service.BotListByChannelId(userID, channelID)
+	sort.Strings(ids)
+	return ids
+}
+
+// botCommandOwner 查找频道内声明了该指令且未停用的 BOT
+func botCommandOwner(userID, channelID, name string) (string, *protocol.Command) {
+	disabled, _ := model.ChannelCommandDisabledSet(channelID)
+	for _, botID := range botCommandChannelBots(userID, channelID) {
+		decls, ok := botCommandDecls.Load(botID)
+		if !ok {
+			continue
+		}
+		cmd := service.BotCommandFind(decls, name)
+		if cmd == nil || disabled[botID+"|"+cmd.Name] {
+			continue
+		}
+		return botID, cmd
+	}
+	return "", nil
+}
+
+func botCommandCanUse(userID, channelID string) bool {
+	if len(channelID) < 30 {
+		return pm.CanWithChannelRole(userID, channelID, pm.PermFuncChannelTextSend, pm.PermFuncChannelTextSendAll)
+	}
+	fr, _ := model.FriendRelationGetByID(channelID)
+	return fr.ID != "" && (fr.UserID1 == userID || fr.UserID2 == userID)
+}
+
+// findBotEventConnection 返回本实例上该 BOT 最近活跃的连接，含标准协议连接
+func findBotEventConnection(botID string) *WsSyncConn {
+	if userId2ConnInfoGlobal == nil {
+		return nil
+	}
+	x, ok := userId2ConnInfoGlobal.Load(botID)
+	if !ok {
+		return nil
+	}
+	var active *WsSyncConn
+	var activeAt int64 = -1
+	x.Range(func(conn *WsSyncConn, value *ConnInfo) bool {
+		if value == nil {
+			return true
+		}
+		lastAlive := value.LastAliveTime
+		if lastAlive == 0 {
+			lastAlive = value.LastPingTime
+		}
+		if lastAlive > activeAt {
+			activeAt = lastAlive
+			active = conn
+		}
+		return true
+	})
+	return active
+}
+
+// botCommandTryDispatch 在消息创建前调用：内容命中频道内 BOT 声明的指令时解析参数并投递给 BOT。
+// handled 为 false 时按普通消息处理
+func botCommandTryDispatch(ctx *ChatContext, channelID, content string) (handled bool, ret any, err error) {
+	tokens, ok := service.BotCommandTokenize(content)
+	if !ok {
+		return false, nil, nil
+	}
+	botID, cmd := botCommandOwner(ctx.User.ID, channelID, strings.TrimPrefix(tokens[0], "/"))
+	if cmd == nil {
+		return false, nil, nil
+	}
+	argv, err := service.BotCommandParse(cmd, tokens[1:])
+	if err != nil {
+		return true, nil, err
+	}
+	conn := findBotEventConnection(botID)
+	if conn == nil {
+		return true, nil, fmt.Errorf("指令所属的 BOT 当前不在线")
+	}
+	channel, _ := model.ChannelGet(channelID)
+	if channel.ID == "" {
+		return true, nil, nil
+	}
+
+	now := time.Now()
+	botCommandInteractions.Range(func(key string, value *botCommandInteraction) bool {
+		if now.After(value.ExpiresAt) {
+			botCommandInteractions.Delete(key)
+		}
+		return true
+	})
+	interactionID := utils.NewID()
+	botCommandInteractions.Store(interactionID, &botCommandInteraction{
+		BotID:     botID,
+		UserID:    ctx.User.ID,
+		ChannelID: channelID,
+		ExpiresAt: now.Add(botCommandInteractionTTL),
+	})
+
+	writeEventFrame(conn, &protocol.Event{
+		Type:        protocol.EventInteractionCommand,
+		Timestamp:   now.Unix(),
+		Argv:        argv,
+		Channel:     channel.ToProtocolType(),
+		User:        ctx.User.ToProtocolType(),
+		Interaction: &protocol.CommandInteraction{ID: interactionID},
+	})
+
+	return true, &struct {
+		InteractionID string `json:"interaction_id"`
+		Command       string `json:"command"`
+	}{InteractionID: interactionID, Command: argv.Name}, nil
+}
+
+// apiBotCommandReply BOT 回复指令调用。ephemeral 为 true 时仅调用者可见且不落库，否则作为普通消息发送
+func apiBotCommandReply(ctx *ChatContext, data *struct {
+	InteractionID string `json:"interaction_id"`
+	Content       string `json:"content"`
+	Ephemeral     bool   `json:"ephemeral"`
+}) (any, error) {
+	if !ctx.User.IsBot {
+		return nil, nil
+	}
+	item, ok := botCommandInteractions.Load(data.InteractionID)
+	if !ok || time.Now().After(item.ExpiresAt) {
+		return nil, fmt.Errorf("指令调用不存在或已过期")
+	}
+	if item.BotID != ctx.User.ID {
+		return nil, nil
+	}
+	if strings.TrimSpace(data.Content) == "" {
+		return nil, fmt.Errorf("回复内容不能为空")
+	}
+	if !data.Ephemeral {
+		return apiInvoke(ctx, apiMessageCreate, map[string]any{
+			"channel_id": item.ChannelID,
+			"content":    data.Content,
+		})
+	}
+
+	channel, _ := model.ChannelGet(item.ChannelID)
+	if channel.ID == "" {
+		return nil, nil
+	}
+	nowMs := time.Now().UnixMilli()
+	channelData := channel.ToProtocolType()
+	userData := ctx.User.ToProtocolType()
+	msg := &protocol.Message{
+		ID:           utils.NewID(),
+		Channel:      channelData,
+		User:         userData,
+		Content:      protocol.EscapeSatoriText(data.Content),
+		Timestamp:    nowMs / 1000,
+		CreatedAt:    nowMs,
+		UpdatedAt:    nowMs,
+		DisplayOrder: float64(nowMs),
+		IcMode:       "ic",
+		Ephemeral:    true,
+	}
+	ctx.BroadcastEventInChannelToUsers(item.ChannelID, []string{item.UserID}, &protocol.Event{
+		Type:        protocol.EventMessageCreated,
+		Message:     msg,
+		Channel:     channelData,
+		User:        userData,
+		Interaction: &protocol.CommandInteraction{ID: data.InteractionID},
+	})
+	return &struct {
+		Message *protocol.Message `json:"message"`
+	}{Message: msg}, nil
+}
+
+type botCommandListItem struct {
+	BotID   string            `json:"bot_id"`
+	Command *protocol.Command `json:"command"`
+	Enabled bool              `json:"enabled"`
+}
+
+// apiChannelCommandList 列出频道内可用的 BOT 指令及其启用状态
+func apiChannelCommandList(ctx *ChatContext, data *struct {
+	ChannelID string `json:"channel_id"`
+}) (any, error) {
+	if !botCommandCanUse(ctx.User.ID, data.ChannelID) {
+		return nil, nil
+	}
+	disabled, err := model.ChannelCommandDisabledSet(data.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+	items := []*botCommandListItem{}
+	for _, botID := range botCommandChannelBots(ctx.User.ID, data.ChannelID) {
+		decls, _ := botCommandDecls.Load(botID)
+		for i := range decls {
+			items = append(items, &botCommandListItem{
+				BotID:   botID,
+				Command: &decls[i],
+				Enabled: !disabled[botID+"|"+decls[i].Name],
+			})
+		}
+	}
+	return &struct {
+		Items []*botCommandListItem `json:"items"`
+	}{Items: items}, nil
+}
+
+// apiChannelCommandSet 设置频道内 BOT 指令的启用状态
+func apiChannelCommandSet(ctx *ChatContext, data *struct {
+	ChannelID string `json:"channel_id"`
+	BotID     string `json:"bot_id"`
+	Name      string `json:"name"`
+	Enabled   bool   `json:"enabled"`
+}) (any, error) {
+	if len(data.ChannelID) >= 30 {
+		return nil, nil
+	}
+	if !pm.CanWithChannelRole(ctx.User.ID, data.ChannelID, pm.PermFuncChannelManageInfo) {
+		return nil, nil
+	}
+	name := strings.TrimSpace(data.Name)
+	if name == "" {
+		return nil, fmt.Errorf("缺少指令名")
+	}
+	found := false
+	for _, botID := range botCommandChannelBots(ctx.User.ID, data.ChannelID) {
+		if botID == data.BotID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("该 BOT 未加入频道")
+	}
+	if err := model.ChannelCommandSetEnabled(data.ChannelID, data.BotID, name, data.Enabled); err != nil {
+		return nil, err
+	}
+	return &struct {
+		Success bool `json:"success"`
+	}{Success: true}, nil
+}
+
+type botCommandChoice struct {
+	Name  string `json:"name"`
+	Value any    `json:"value"`
+}
+
+type botCommandChoiceList struct {
+	Choices []botCommandChoice `json:"choices"`
+}
+
+// apiBotCommandAutocomplete 指令补全：输入指令名时在本地匹配，输入参数时对声明了 Autocomplete 的参数转发给 BOT
+func apiBotCommandAutocomplete(ctx *ChatContext, data *struct {
+	ChannelID string `json:"channel_id"`
+	Input     string `json:"input"`
+}) (any, error) {
+	if !botCommandCanUse(ctx.User.ID, data.ChannelID) {
+		return nil, nil
+	}
+	input := strings.TrimLeft(data.Input, " ")
+	if !strings.HasPrefix(input, "/") {
+		return &botCommandChoiceList{Choices: []botCommandChoice{}}, nil
+	}
+	name, rest, hasArgs := strings.Cut(input[1:], " ")
+	choices := []botCommandChoice{}
+
+	if !hasArgs {
+		disabled, _ := model.ChannelCommandDisabledSet(data.ChannelID)
+		for _, botID := range botCommandChannelBots(ctx.User.ID, data.ChannelID) {
+			decls, _ := botCommandDecls.Load(botID)
+			for _, cmd := range decls {
+				if disabled[botID+"|"+cmd.Name] || !strings.HasPrefix(strings.ToLower(cmd.Name), strings.ToLower(name)) {
+					continue
+				}
+				choices = append(choices, botCommandChoice{Name: cmd.Description["zh-CN"], Value: "/" + cmd.Name})
+			}
+		}
+		return &botCommandChoiceList{Choices: choices}, nil
+	}
+
+	botID, cmd := botCommandOwner(ctx.User.ID, data.ChannelID, name)
+	if cmd == nil {
+		return &botCommandChoiceList{Choices: choices}, nil
+	}
+	fullName, decl, value := service.BotCommandFocus(cmd, rest)
+	if decl == nil || !decl.Autocomplete {
+		return &botCommandChoiceList{Choices: choices}, nil
+	}
+	// 标准协议连接没有请求通道，只有 /ws/seal 连接支持补全
+	conn, info := findLocalBotConnection(botID)
+	if conn == nil {
+		return &botCommandChoiceList{Choices: choices}, nil
+	}
+	// 复用人物卡 API 的请求-响应通道：BOT 以同一 echo 回复
+	resp := forwardCharacterRequestWithTimeout(conn, info, "command.autocomplete", "cmd-ac-"+utils.NewID(), map[string]any{
+		"channel_id": data.ChannelID,
+		"user_id":    ctx.User.ID,
+		"command":    fullName,
+		"focused":    map[string]any{"name": decl.Name, "value": value},
+		"input":      rest,
+	}, botCommandAutocompleteTimeout)
+	if resp != nil {
+		var payload struct {
+			Choices []botCommandChoice `json:"choices"`
+		}
+		if err := json.Unmarshal(resp, &payload); err == nil && payload.Choices != nil {
+			choices = payload.Choices
+		}
+	}
+	return &botCommandChoiceList{Choices: choices}, nil
+}
@@ -0,0 +1,290 @@
+package api
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sealchat/model"
+	"sealchat/protocol"
+	"sealchat/utils"
+)
+
+// BOT 令牌的权限范围、频道限制与事件订阅。令牌未配置任何限制时行为与以往一致
+
+// botTokenCache 事件投递的热路径上按 BOT ID 读取令牌限制，令牌修改或删除时失效
+var botTokenCache utils.SyncMap[string, *model.BotTokenModel]
+
+// botAPIScopes WS API 所需的权限范围，未列出的 API 不做范围检查
+var botAPIScopes = map[string]string{
+	"message.list":         model.BotScopeMessageRead,
+	"message.get":          model.BotScopeMessageRead,
+	"message.context":      model.BotScopeMessageRead,
+	"message.pin.list":     model.BotScopeMessageRead,
+	"message.edit.history": model.BotScopeMessageRead,
+	"message.edit.diff":    model.BotScopeMessageRead,
+
+	"message.create":         model.BotScopeMessageWrite,
+	"message.update":         model.BotScopeMessageWrite,
+	"message.delete":         model.BotScopeMessageWrite,
+	"message.remove":         model.BotScopeMessageWrite,
+	"message.reorder":        model.BotScopeMessageWrite,
+	"message.archive":        model.BotScopeMessageWrite,
+	"message.unarchive":      model.BotScopeMessageWrite,
+	"message.pin":            model.BotScopeMessageWrite,
+	"message.unpin":          model.BotScopeMessageWrite,
+	"message.edit.rollback":  model.BotScopeMessageWrite,
+	"message.gm_roll.reveal": model.BotScopeMessageWrite,
+	"message.bulk.preview":   model.BotScopeMessageWrite,
+	"message.bulk.start":     model.BotScopeMessageWrite,
+	"message.bulk.status":    model.BotScopeMessageWrite,
+	"message.typing":         model.BotScopeMessageWrite,
+	"widget.interact":        model.BotScopeMessageWrite,
+	"asset.upload":           model.BotScopeMessageWrite,
+	"bot.command.reply":      model.BotScopeMessageWrite,
+
+	"sticky-note.update": model.BotScopeStickyNote,
+	"sticky-note.delete": model.BotScopeStickyNote,
+	"sticky-note.push":   model.BotScopeStickyNote,
+
+	"character.get":             model.BotScopeCharacter,
+	"character.set":             model.BotScopeCharacter,
+	"character.list":            model.BotScopeCharacter,
+	"character.capability.test": model.BotScopeCharacter,
+	"character.new":             model.BotScopeCharacter,
+	"character.save":            model.BotScopeCharacter,
+	"character.tag":             model.BotScopeCharacter,
+	"character.untagAll":        model.BotScopeCharacter,
+	"character.load":            model.BotScopeCharacter,
+	"character.delete":          model.BotScopeCharacter,
+	"character.badge.broadcast": model.BotScopeCharacter,
+	"character.badge.snapshot":  model.BotScopeCharacter,
+}
+
+// botTokenOf 返回 BOT 的令牌限制；找不到令牌时视为不限制
+func botTokenOf(botID string) *model.BotTokenModel {
+	if token, ok := botTokenCache.Load(botID); ok {
+		return token
+	}
+	token, _ := model.BotTokenGet(botID)
+	if token == nil {
+		token = &model.BotTokenModel{}
+	}
+	botTokenCache.Store(botID, token)
+	return token
+}
+
+func botTokenInvalidate(botID string) {
+	botTokenCache.Delete(botID)
+	publishCacheInvalidate(&busCacheInvalidate{Kind: busCacheBotToken, UserIDs: []string{botID}})
+}
+
+func botTokenRestrictsChannels(token *model.BotTokenModel) bool {
+	return len(token.AllowedWorldIDs) > 0 || len(token.AllowedChannelIDs) > 0
+}
+
+// botTokenAllowsChannel 私聊频道不受世界/频道限制
+func botTokenAllowsChannel(token *model.BotTokenModel, ch *model.ChannelModel) bool {
+	if !botTokenRestrictsChannels(token) || ch == nil || ch.IsPrivate {
+		return true
+	}
+	return token.AllowsChannel(ch.ID, ch.WorldID)
+}
+
+// botAPICheck WS 调用前检查权限范围与频道限制，返回拒绝原因，允许时返回空串
+func botAPICheck(botID, api string, data json.RawMessage) string {
+	token := botTokenOf(botID)
+	if scope, ok := botAPIScopes[api]; ok && !token.HasScope(scope) {
+		return "bot_scope_denied"
+	}
+	if !botTokenRestrictsChannels(token) || len(data) == 0 {
+		return ""
+	}
+	var payload struct {
+		ChannelID string `json:"channel_id"`
+		NoteID    string `json:"noteId"`
+	}
+	if json.Unmarshal(data, &payload) != nil {
+		return ""
+	}
+	if payload.ChannelID == "" && payload.NoteID != "" {
+		// 便签接口只带便签 ID，按其所属频道判断
+		if note, err := model.StickyNoteGet(payload.NoteID); err == nil {
+			payload.ChannelID = note.ChannelID
+		}
+	}
+	if payload.ChannelID == "" || len(payload.ChannelID) >= 30 {
+		return ""
+	}
+	ch, _ := model.ChannelGet(payload.ChannelID)
+	if ch.ID != "" && !botTokenAllowsChannel(token, ch) {
+		return "bot_channel_denied"
+	}
+	return ""
+}
+
+func botEventIsMessage(eventType protocol.EventName) bool {
+	name := string(eventType)
+	return strings.HasPrefix(name, "message") || strings.HasPrefix(name, "reaction-")
+}
+
+// botEventAllowed 按事件订阅、message.read 范围与频道限制过滤投递给 BOT 的事件
+func botEventAllowed(botID string, ev *protocol.Event) bool {
+	token := botTokenOf(botID)
+	if !token.SubscribesEvent(string(ev.Type)) {
+		return false
+	}
+	if botEventIsMessage(ev.Type) && !token.HasScope(model.BotScopeMessageRead) {
+		return false
+	}
+	if ch := ev.Channel; ch != nil && ch.Type != protocol.DirectChannelType && botTokenRestrictsChannels(token) {
+		return token.AllowsChannel(ch.ID, ch.WorldID)
+	}
+	return true
+}
+
+type botHTTPRoute struct {
+	pattern *regexp.Regexp
+	// scope 为空时按请求方法区分读写
+	scope string
+}
+
+// botHTTPRoutes HTTP 接口所需的权限范围，路径为去掉 /api/v1 前缀后的部分；未列出的接口不做范围检查
+var botHTTPRoutes = []botHTTPRoute{
+	{regexp.MustCompile(`^/(channels/[^/]+/)?sticky-note`), model.BotScopeStickyNote},
+	{regexp.MustCompile(`^/audio(/|$)`), model.BotScopeAudio},
+	{regexp.MustCompile(`^/character-card`), model.BotScopeCharacter},
+	{regexp.MustCompile(`^/(attachment-upload|attachment-presign|attachment-confirm|upload|upload-quick|upload-sessions)(/|$)`), model.BotScopeMessageWrite},
+	{regexp.MustCompile(`^/channels/[^/]+/messages/`), ""},
+	{regexp.MustCompile(`^/messages/[^/]+/reactions`), ""},
+}
+
+var (
+	botHTTPChannelPattern = regexp.MustCompile(`^/channels/([^/]+)`)
+	botHTTPNotePattern    = regexp.MustCompile(`^/sticky-notes/([^/]+)`)
+	botHTTPFolderPattern  = regexp.MustCompile(`^/sticky-note-folders/([^/]+)`)
+)
+
+// botHTTPChannelID 从路径或查询参数中取出请求涉及的频道，便签与便签文件夹按其所属频道
+func botHTTPChannelID(c *fiber.Ctx, path string) string {
+	if m := botHTTPChannelPattern.FindStringSubmatch(path); m != nil {
+		return m[1]
+	}
+	if m := botHTTPNotePattern.FindStringSubmatch(path); m != nil {
+		if note, err := model.StickyNoteGet(m[1]); err == nil {
+			return note.ChannelID
+		}
+		return ""
+	}
+	if m := botHTTPFolderPattern.FindStringSubmatch(path); m != nil {
+		if folder, err := model.StickyNoteFolderGet(m[1]); err == nil {
+			return folder.ChannelID
+		}
+		return ""
+	}
+	if id := strings.TrimSpace(c.Query("channelId")); id != "" {
+		return id
+	}
+	return strings.TrimSpace(c.Query("channel_id"))
+}
+
+// botHTTPCheck BOT 令牌调用 HTTP 接口前检查权限范围与频道限制，返回拒绝原因，允许时返回空串
+func botHTTPCheck(c *fiber.Ctx, botID string) string {
+	token := botTokenOf(botID)
+	path := strings.TrimPrefix(c.Path(), "/api/v1")
+	for _, route := range botHTTPRoutes {
+		if !route.pattern.MatchString(path) {
+			continue
+		}
+		scope := route.scope
+		if scope == "" {
+			scope = model.BotScopeMessageWrite
+			if c.Method() == fiber.MethodGet {
+				scope = model.BotScopeMessageRead
+			}
+		}
+		if !token.HasScope(scope) {
+			return "BOT 令牌无此权限"
+		}
+		break
+	}
+	if !botTokenRestrictsChannels(token) {
+		return ""
+	}
+	channelID := botHTTPChannelID(c, path)
+	if channelID == "" || len(channelID) >= 30 {
+		return ""
+	}
+	if ch, _ := model.ChannelGet(channelID); ch != nil && ch.ID != "" && !botTokenAllowsChannel(token, ch) {
+		return "BOT 令牌不允许访问该频道"
+	}
+	return ""
+}
+
+// botJSONAllowed 非事件结构的推送（如新消息提示）同样按事件订阅与频道限制过滤
+func botJSONAllowed(botID string, data any) bool {
+	token := botTokenOf(botID)
+	if len(token.EventTypes) == 0 && !botTokenRestrictsChannels(token) {
+		return true
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return false
+	}
+	var head struct {
+		Type       string `json:"type"`
+		ChannelID  string `json:"channelId"`
+		ChannelID2 string `json:"channel_id"`
+	}
+	_ = json.Unmarshal(raw, &head)
+	if head.Type != "" && !token.SubscribesEvent(head.Type) {
+		return false
+	}
+	channelID := head.ChannelID
+	if channelID == "" {
+		channelID = head.ChannelID2
+	}
+	if channelID == "" || len(channelID) >= 30 || !botTokenRestrictsChannels(token) {
+		return true
+	}
+	ch, _ := model.ChannelGet(channelID)
+	return ch == nil || ch.ID == "" || botTokenAllowsChannel(token, ch)
+}
+
+// BotScopeMiddleware HTTP 接口的权限范围检查，仅作用于 BOT 令牌
+func BotScopeMiddleware(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := getCurUser(c)
+		if user != nil && user.IsBot && !botTokenOf(user.ID).HasScope(scope) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "BOT 令牌无此权限"})
+		}
+		return c.Next()
+	}
+}
+
+// botNormalizeScopes 过滤未知的权限范围
+func botNormalizeScopes(scopes []string) model.JSONList[string] {
+	ret := model.JSONList[string]{}
+	for _, s := range scopes {
+		s = strings.TrimSpace(s)
+		for _, known := range model.BotScopeAll {
+			if s == known {
+				ret = append(ret, s)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+func botNormalizeList(items []string) model.JSONList[string] {
+	ret := model.JSONList[string]{}
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			ret = append(ret, item)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sealchat/model"
+	"sealchat/utils"
+)
+
+func setBotTokenLimits(t *testing.T, f *botFixture, updates map[string]any) {
+	t.Helper()
+	if err := model.GetDB().Model(&model.BotTokenModel{}).Where("id = ?", f.bot.ID).Updates(updates).Error; err != nil {
+		t.Fatalf("update bot token failed: %v", err)
+	}
+	botTokenCache.Delete(f.bot.ID)
+	t.Cleanup(func() { botTokenCache.Delete(f.bot.ID) })
+}
+
+func newStickyNoteTestApp() *fiber.App {
+	app := fiber.New()
+	v1Auth := app.Group("/api/v1", SignCheckMiddleware)
+	BindStickyNoteRoutes(v1Auth)
+	return app
+}
+
+func botHTTPStatus(t *testing.T, app *fiber.App, f *botFixture, path string) int {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Authorization", "Bearer "+f.token)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("request %s failed: %v", path, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func seedStickyNote(t *testing.T, f *botFixture) *model.StickyNoteModel {
+	t.Helper()
+	note := &model.StickyNoteModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: "note" + utils.NewIDWithLength(8)},
+		ChannelID:         f.channelID,
+		WorldID:           f.worldID,
+		Title:             "先攻",
+		CreatorID:         f.ownerID,
+		IsPublic:          true,
+	}
+	if err := model.GetDB().Create(note).Error; err != nil {
+		t.Fatalf("create sticky note failed: %v", err)
+	}
+	return note
+}
+
+func TestBotHTTPStickyNoteScope(t *testing.T) {
+	f := seedBotFixture(t)
+	note := seedStickyNote(t, f)
+	app := newStickyNoteTestApp()
+	listPath := "/api/v1/channels/" + f.channelID + "/sticky-notes"
+	notePath := "/api/v1/sticky-notes/" + note.ID
+
+	if status := botHTTPStatus(t, app, f, listPath); status == http.StatusForbidden {
+		t.Fatalf("unrestricted token should reach the handler, got %d", status)
+	}
+
+	setBotTokenLimits(t, f, map[string]any{"scopes": model.JSONList[string]{model.BotScopeMessageRead}})
+	if status := botHTTPStatus(t, app, f, listPath); status != http.StatusForbidden {
+		t.Fatalf("token without sticky-note scope should be 403, got %d", status)
+	}
+	if status := botHTTPStatus(t, app, f, notePath); status != http.StatusForbidden {
+		t.Fatalf("token without sticky-note scope should be 403 on note, got %d", status)
+	}
+
+	setBotTokenLimits(t, f, map[string]any{
+		"scopes":              model.JSONList[string]{},
+		"allowed_channel_ids": model.JSONList[string]{"other" + utils.NewIDWithLength(6)},
+	})
+	if status := botHTTPStatus(t, app, f, listPath); status != http.StatusForbidden {
+		t.Fatalf("channel outside the allow list should be 403, got %d", status)
+	}
+	if status := botHTTPStatus(t, app, f, notePath); status != http.StatusForbidden {
+		t.Fatalf("note in a channel outside the allow list should be 403, got %d", status)
+	}
+
+	setBotTokenLimits(t, f, map[string]any{"allowed_channel_ids": model.JSONList[string]{f.channelID}})
+	if status := botHTTPStatus(t, app, f, notePath); status == http.StatusForbidden {
+		t.Fatalf("allowed channel should reach the handler, got %d", status)
+	}
+}
+
+func TestBotAPICheckStickyNoteChannel(t *testing.T) {
+	f := seedBotFixture(t)
+	note := seedStickyNote(t, f)
+	data, _ := json.Marshal(map[string]any{"noteId": note.ID})
+
+	if reason := botAPICheck(f.bot.ID, "sticky-note.update", data); reason != "" {
+		t.Fatalf("unrestricted token should pass, got %s", reason)
+	}
+	setBotTokenLimits(t, f, map[string]any{"allowed_world_ids": model.JSONList[string]{"other" + utils.NewIDWithLength(6)}})
+	if reason := botAPICheck(f.bot.ID, "sticky-note.update", data); reason != "bot_channel_denied" {
+		t.Fatalf("note outside the allowed worlds should be denied, got %q", reason)
+	}
+	setBotTokenLimits(t, f, map[string]any{"allowed_world_ids": model.JSONList[string]{f.worldID}})
+	if reason := botAPICheck(f.bot.ID, "sticky-note.update", data); reason != "" {
+		t.Fatalf("note in an allowed world should pass, got %q", reason)
+	}
+}
+
+func TestBotJSONAllowed(t *testing.T) {
+	f := seedBotFixture(t)
+	payload := map[string]any{"type": "channel-read-updated", "channelId": f.channelID}
+
+	if !botJSONAllowed(f.bot.ID, payload) {
+		t.Fatalf("unrestricted token should receive every push")
+	}
+
+	setBotTokenLimits(t, f, map[string]any{"event_types": model.JSONList[string]{"message-*"}})
+	if botJSONAllowed(f.bot.ID, payload) {
+		t.Fatalf("unsubscribed push type should be filtered")
+	}
+	if !botJSONAllowed(f.bot.ID, map[string]any{"type": "message-created", "channelId": f.channelID}) {
+		t.Fatalf("subscribed push type should be delivered")
+	}
+
+	setBotTokenLimits(t, f, map[string]any{
+		"event_types":         model.JSONList[string]{},
+		"allowed_channel_ids": model.JSONList[string]{"other" + utils.NewIDWithLength(6)},
+	})
+	if botJSONAllowed(f.bot.ID, payload) {
+		t.Fatalf("push for a channel outside the allow list should be filtered")
+	}
+	if !botJSONAllowed(f.bot.ID, map[string]any{"type": "channel-read-updated"}) {
+		t.Fatalf("push without channel should be delivered")
+	}
+}
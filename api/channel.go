@@ -531,6 +531,10 @@ func RolePermApply(c *fiber.Ctx) error {
 
 	// 更新角色权限
 	pm.RolePermApply(req.RoleId, req.Permissions)
+	// 世界角色派生的频道角色被单独修改时记为该频道的覆盖
+	if err := service.WorldRoleChannelOverrideRecord(req.RoleId, req.Permissions); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
 
 	return c.JSON(fiber.Map{
 		"message": "更新成功",
@@ -654,8 +658,9 @@ func ChannelPermanentDelete(c *fiber.Ctx) error {
 	}
 
 	var req struct {
-		ChannelIDs   []string `json:"channelIds"`
-		ConfirmToken string   `json:"confirmToken"`
+		ChannelIDs      []string `json:"channelIds"`
+		ConfirmToken    string   `json:"confirmToken"`
+		IncludeChildren *bool    `json:"includeChildren"` // 未传时与旧版一致，一并删除子频道
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -677,7 +682,8 @@ func ChannelPermanentDelete(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := service.ChannelPermanentDelete(req.ChannelIDs, user.ID); err != nil {
+	includeChildren := req.IncludeChildren == nil || *req.IncludeChildren
+	if err := service.ChannelPermanentDelete(req.ChannelIDs, user.ID, includeChildren); err != nil {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sealchat/service"
+)
+
+func channelPermOverwriteErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrChannelTreeNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrPermOverwriteInvalid):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrWorldPermission):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "没有管理频道权限的权限"})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "操作失败"})
+	}
+}
+
+// ChannelPermOverwriteListHandler 列出频道内的用户权限覆盖
+func ChannelPermOverwriteListHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "未登录"})
+	}
+	items, err := service.ChannelPermOverwriteList(c.Params("channelId"), user.ID)
+	if err != nil {
+		return channelPermOverwriteErrorResponse(c, err)
+	}
+	return c.JSON(fiber.Map{"items": items})
+}
+
+// ChannelPermOverwriteSetHandler 整体设置某用户在频道上的允许/禁止权限
+func ChannelPermOverwriteSetHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "未登录"})
+	}
+	var req struct {
+		Allow []string `json:"allow"`
+		Deny  []string `json:"deny"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "请求参数解析失败"})
+	}
+	entry, err := service.ChannelPermOverwriteSet(c.Params("channelId"), user.ID, c.Params("userId"), req.Allow, req.Deny)
+	if err != nil {
+		return channelPermOverwriteErrorResponse(c, err)
+	}
+	return c.JSON(fiber.Map{"item": entry})
+}
+
+// ChannelPermOverwriteDeleteHandler 清除某用户在频道上的全部覆盖
+func ChannelPermOverwriteDeleteHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "未登录"})
+	}
+	if _, err := service.ChannelPermOverwriteSet(c.Params("channelId"), user.ID, c.Params("userId"), nil, nil); err != nil {
+		return channelPermOverwriteErrorResponse(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "已清除权限覆盖"})
+}
+
+// ChannelPermExplainHandler 说明用户能否在频道中使用某项权限，以及结论来自哪一步
+func ChannelPermExplainHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "未登录"})
+	}
+	result, err := service.ChannelPermExplain(c.Params("channelId"), user.ID, strings.TrimSpace(c.Query("userId")), c.Query("perm"))
+	if err != nil {
+		return channelPermOverwriteErrorResponse(c, err)
+	}
+	return c.JSON(result)
+}
+
+// ChannelEffectivePermsHandler 列出用户在频道上的全部有效权限及每项权限的来源
+func ChannelEffectivePermsHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "未登录"})
+	}
+	result, err := service.ChannelEffectivePermissions(c.Params("channelId"), user.ID, strings.TrimSpace(c.Query("userId")))
+	if err != nil {
+		return channelPermOverwriteErrorResponse(c, err)
+	}
+	return c.JSON(result)
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sealchat/model"
+	"sealchat/protocol"
+	"sealchat/service"
+)
+
+func channelTreeErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrChannelTreeNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrChannelTreeInvalid):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrWorldPermission):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "没有调整频道结构的权限"})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "操作失败"})
+	}
+}
+
+// broadcastChannelTreeChanged 频道层级变化影响整个世界的频道列表，向世界内所有在线成员广播
+func broadcastChannelTreeChanged(operator *model.UserModel, channel *model.ChannelModel) {
+	if channel == nil || channel.WorldID == "" {
+		return
+	}
+	event := &protocol.Event{
+		Type:    protocol.EventChannelUpdated,
+		Channel: channel.ToProtocolType(),
+	}
+	if operator != nil {
+		event.User = operator.ToProtocolType()
+	}
+	broadcastEventToWorld(channel.WorldID, event)
+}
+
+// ChannelMoveHandler 拖拽调整频道：移到新的父频道（或顶层）下，并按给定顺序重排同级频道
+func ChannelMoveHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "未登录"})
+	}
+	var req struct {
+		ParentID   string   `json:"parentId"`
+		OrderedIDs []string `json:"orderedIds"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "请求参数解析失败"})
+	}
+	channel, err := service.ChannelMove(strings.TrimSpace(c.Params("channelId")), user.ID, req.ParentID, req.OrderedIDs)
+	if err != nil {
+		return channelTreeErrorResponse(c, err)
+	}
+	broadcastChannelTreeChanged(user, channel)
+	return c.JSON(fiber.Map{"channel": channel})
+}
+
+// ChannelPermOverrideHandler 设置频道是否脱离所属分类的权限继承
+func ChannelPermOverrideHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "未登录"})
+	}
+	var req struct {
+		PermOverride bool `json:"permOverride"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "请求参数解析失败"})
+	}
+	channel, err := service.ChannelPermOverrideSet(strings.TrimSpace(c.Params("channelId")), user.ID, req.PermOverride)
+	if err != nil {
+		return channelTreeErrorResponse(c, err)
+	}
+	broadcastChannelTreeChanged(user, channel)
+	return c.JSON(fiber.Map{"channel": channel})
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sealchat/model"
+)
+
+// convertFixtureChannelToCategory 把 BOT 夹具的频道改为分类
+func convertFixtureChannelToCategory(t *testing.T, f *botFixture) {
+	t.Helper()
+	if err := model.GetDB().Model(&model.ChannelModel{}).Where("id = ?", f.channelID).
+		Update("kind", model.ChannelKindCategory).Error; err != nil {
+		t.Fatalf("convert channel failed: %v", err)
+	}
+}
+
+func TestStickyNoteCreateRejectsCategory(t *testing.T) {
+	f := seedBotFixture(t)
+	convertFixtureChannelToCategory(t, f)
+	app := newStickyNoteTestApp()
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/channels/"+f.channelID+"/sticky-notes", strings.NewReader(`{"title":"先攻"}`))
+	req.Header.Set("Authorization", "Bearer "+f.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("sticky note in a category should be 403, got %d", resp.StatusCode)
+	}
+}
+
+func TestUploadCheckRejectsCategory(t *testing.T) {
+	f := seedBotFixture(t)
+	app := fiber.New()
+	app.Post("/upload", func(c *fiber.Ctx) error {
+		if err := channelSpeakCheckHTTP(c, getHeader(c, "Channelid"), f.bot.ID); err != nil {
+			return c.SendStatus(fiber.StatusForbidden)
+		}
+		return c.SendStatus(fiber.StatusOK)
+	})
+	upload := func() int {
+		req, _ := http.NewRequest(http.MethodPost, "/upload", nil)
+		req.Header.Set("ChannelId", f.channelID)
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := upload(); status != http.StatusOK {
+		t.Fatalf("upload to a regular channel should pass, got %d", status)
+	}
+	convertFixtureChannelToCategory(t, f)
+	if status := upload(); status != http.StatusForbidden {
+		t.Fatalf("upload to a category should be rejected, got %d", status)
+	}
+}
@@ -42,6 +42,19 @@ func apiWrap[T any, T2 any](ctx *ChatContext, msg []byte, solve func(ctx *ChatCo
 	}{ctx.Echo, ret})
 }
 
+// apiInvoke 供 HTTP 协议适配复用 WS API：参数经 JSON 转换为目标类型后直接调用
+func apiInvoke[T any](ctx *ChatContext, solve func(ctx *ChatContext, data T) (any, error), params any) (any, error) {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	var data T
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+	return solve(ctx, data)
+}
+
 func apiUserListCommon(dataNext string, f func(q *gorm.DB)) (any, error) {
 	db := model.GetDB()
 
@@ -136,14 +149,26 @@ func apiBotInfoSetName(ctx *ChatContext, msg []byte) {
 
 func apiBotCommandRegister(ctx *ChatContext, msg []byte) {
 	data := struct {
-		Data map[string]string `json:"data"`
+		Data json.RawMessage `json:"data"`
 	}{}
 	err := json.Unmarshal(msg, &data)
 	if err != nil {
 		return
 	}
 
-	commandTips.Store(ctx.User.ID, data.Data)
+	// 新格式 {"commands": [...]} 携带参数表，由服务端解析指令；旧格式为 指令名 -> 说明，仅用于展示
+	var decls struct {
+		Commands []protocol.Command `json:"commands"`
+	}
+	if json.Unmarshal(data.Data, &decls) == nil && len(decls.Commands) > 0 {
+		botCommandRegisterDecls(ctx.User.ID, decls.Commands)
+	} else {
+		var tips map[string]string
+		if err := json.Unmarshal(data.Data, &tips); err != nil {
+			return
+		}
+		commandTips.Store(ctx.User.ID, tips)
+	}
 
 	ret := struct {
 		Echo string `json:"echo"`
@@ -37,7 +37,15 @@ func apiChannelCreate(ctx *ChatContext, data *protocol.Channel) (any, error) {
 		return nil, fmt.Errorf("无权在该世界创建频道")
 	}
 
-	m := service.ChannelNew(utils.NewID(), permType, data.Name, worldID, ctx.User.ID, data.ParentID)
+	if _, err := service.ChannelValidateParent(worldID, data.ParentID); err != nil {
+		return nil, err
+	}
+	kind := ""
+	if data.Type == protocol.CategoryChannelType {
+		kind = model.ChannelKindCategory
+	}
+
+	m := service.ChannelNewWithKind(utils.NewID(), kind, permType, data.Name, worldID, ctx.User.ID, strings.TrimSpace(data.ParentID))
 
 	return &struct {
 		Channel *protocol.Channel `json:"channel"`
@@ -77,9 +85,17 @@ func apiChannelPrivateCreate(ctx *ChatContext, data *struct {
 }
 
 func apiChannelList(ctx *ChatContext, data *struct {
-	WorldID string `json:"world_id"`
+	WorldID       string `json:"world_id"`
+	PreviewUserID string `json:"preview_user_id"` // 管理员以该用户视角查看
 }) (any, error) {
 	worldID := strings.TrimSpace(data.WorldID)
+	if previewUserID := strings.TrimSpace(data.PreviewUserID); previewUserID != "" {
+		preview, err := ctx.previewAs(worldID, previewUserID)
+		if err != nil {
+			return nil, err
+		}
+		ctx = preview
+	}
 	if ctx.IsReadOnly() {
 		if worldID == "" {
 			return nil, fmt.Errorf("未找到世界")
@@ -245,7 +261,7 @@ func apiChannelEnter(ctx *ChatContext, data *struct {
 
 	// 权限检查
 	if ctx.IsReadOnly() {
-		channel, err := service.CanGuestAccessChannel(channelId)
+		channel, err := service.CanGuestAccessChannel(channelId, ctx.RemoteIP())
 		if err != nil {
 			return nil, err
 		}
@@ -281,6 +297,11 @@ func apiChannelEnter(ctx *ChatContext, data *struct {
 			if ch.WorldID != "" && !service.IsWorldMember(ch.WorldID, ctx.User.ID) && !pm.CanWithSystemRole(ctx.User.ID, pm.PermModAdmin) {
 				return nil, fmt.Errorf("尚未加入该世界")
 			}
+			if ch.WorldID != "" {
+				if err := service.WorldBanCheck(ch.WorldID, ctx.User.ID, ctx.RemoteIP()); err != nil {
+					return nil, err
+				}
+			}
 		}
 		if !pm.CanWithChannelRole(ctx.User.ID, channelId, pm.PermFuncChannelRead, pm.PermFuncChannelReadAll) {
 			return nil, nil
@@ -347,10 +368,12 @@ func apiChannelEnter(ctx *ChatContext, data *struct {
 		FirstUnreadMsgTime    int64                 `json:"first_unread_msg_time,omitempty"`
 		CharacterAPIEnabled   bool                  `json:"character_api_enabled"`
 		CharacterAPIReason    string                `json:"character_api_reason,omitempty"`
+		EventSeq              int64                 `json:"event_seq"`
 	}{
 		Member:                memberPT,
 		FirstUnreadMessageId:  firstUnreadMsgId,
 		FirstUnreadMsgTime:    firstUnreadMsgTime,
+		EventSeq:              service.EventReplayLatestSeq(channelId),
 	}
 	characterEnabled, characterReason := GetChannelCharacterAPICapability(channelId, nil)
 	rData.CharacterAPIEnabled = characterEnabled
@@ -15,7 +15,6 @@ import (
 	"time"
 
 	"github.com/samber/lo"
-	ds "github.com/sealdice/dicescript"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 
@@ -155,7 +154,7 @@ func apiMessageGet(ctx *ChatContext, data *struct {
 		if len(channelId) >= 30 {
 			return nil, fmt.Errorf("频道不可公开访问")
 		}
-		if _, err := service.CanGuestAccessChannel(channelId); err != nil {
+		if _, err := service.CanGuestAccessChannel(channelId, ctx.RemoteIP()); err != nil {
 			return nil, err
 		}
 	} else if len(channelId) < 30 {
@@ -220,7 +219,7 @@ func apiMessageContext(ctx *ChatContext, data *struct {
 		if len(channelID) >= 30 {
 			return nil, fmt.Errorf("频道不可公开访问")
 		}
-		if _, err := service.CanGuestAccessChannel(channelID); err != nil {
+		if _, err := service.CanGuestAccessChannel(channelID, ctx.RemoteIP()); err != nil {
 			return nil, err
 		}
 	} else if len(channelID) < 30 {
@@ -374,6 +373,7 @@ func apiMessageContext(ctx *ChatContext, data *struct {
 		}
 		item.EnsureWhisperMeta()
 	}
+	maskGMRollsForUser(ctx.User.ID, nil, channelID, items)
 
 	if ctx.User != nil && len(items) > 0 {
 		ids := make([]string, 0, len(items))
@@ -545,6 +545,8 @@ func apiMessageRemove(ctx *ChatContext, data *struct {
 		}
 		recipients = lo.Uniq(recipients)
 		ctx.BroadcastEventInChannelToUsers(channelID, recipients, ev)
+	} else if msg.IsGMRoll {
+		broadcastGMRollEvent(ctx, channelID, msg.UserID, ev)
 	} else {
 		ctx.BroadcastEventInChannel(channelID, ev)
 		ctx.BroadcastEventInChannelForBot(channelID, ev)
@@ -572,7 +574,7 @@ func hydrateMessagesForBroadcast(messages []*model.MessageModel) {
 			return
 		}
 		i.Quote = x[0]
-	}, "id, content, created_at, user_id, is_revoked, is_deleted, whisper_to, channel_id, whisper_sender_member_id, whisper_sender_member_name, whisper_sender_user_name, whisper_sender_user_nick, whisper_target_member_id, whisper_target_member_name, whisper_target_user_name, whisper_target_user_nick")
+	}, "id, content, created_at, user_id, is_revoked, is_deleted, whisper_to, channel_id, is_gm_roll, sender_member_name, sender_identity_name, whisper_sender_member_id, whisper_sender_member_name, whisper_sender_user_name, whisper_sender_user_nick, whisper_target_member_id, whisper_target_member_name, whisper_target_user_name, whisper_target_user_nick")
 
 	var whisperMsgIDs []string
 	for _, item := range messages {
@@ -1135,6 +1137,8 @@ func apiMessagePin(ctx *ChatContext, data *struct {
 			}
 			recipients = lo.Uniq(recipients)
 			ctx.BroadcastEventInChannelToUsers(data.ChannelID, recipients, ev)
+		} else if msg.IsGMRoll {
+			broadcastGMRollEvent(ctx, data.ChannelID, msg.UserID, ev)
 		} else {
 			ctx.BroadcastEventInChannel(data.ChannelID, ev)
 			ctx.BroadcastEventInChannelForBot(data.ChannelID, ev)
@@ -1200,6 +1204,8 @@ func apiMessageUnpin(ctx *ChatContext, data *struct {
 			}
 			recipients = lo.Uniq(recipients)
 			ctx.BroadcastEventInChannelToUsers(data.ChannelID, recipients, ev)
+		} else if msg.IsGMRoll {
+			broadcastGMRollEvent(ctx, data.ChannelID, msg.UserID, ev)
 		} else {
 			ctx.BroadcastEventInChannel(data.ChannelID, ev)
 			ctx.BroadcastEventInChannelForBot(data.ChannelID, ev)
@@ -1261,6 +1267,7 @@ func apiMessagePinList(ctx *ChatContext, data *struct {
 	}
 
 	hydrateMessagesForBroadcast(items)
+	maskGMRollsForUser(ctx.User.ID, nil, channelID, items)
 
 	return &struct {
 		Data []*model.MessageModel `json:"data"`
@@ -1339,6 +1346,8 @@ func apiMessageArchive(ctx *ChatContext, data *struct {
 			}
 			recipients = lo.Uniq(recipients)
 			ctx.BroadcastEventInChannelToUsers(data.ChannelID, recipients, ev)
+		} else if msg.IsGMRoll {
+			broadcastGMRollEvent(ctx, data.ChannelID, msg.UserID, ev)
 		} else {
 			ctx.BroadcastEventInChannel(data.ChannelID, ev)
 			ctx.BroadcastEventInChannelForBot(data.ChannelID, ev)
@@ -1420,6 +1429,8 @@ func apiMessageUnarchive(ctx *ChatContext, data *struct {
 			}
 			recipients = lo.Uniq(recipients)
 			ctx.BroadcastEventInChannelToUsers(data.ChannelID, recipients, ev)
+		} else if msg.IsGMRoll {
+			broadcastGMRollEvent(ctx, data.ChannelID, msg.UserID, ev)
 		} else {
 			ctx.BroadcastEventInChannel(data.ChannelID, ev)
 			ctx.BroadcastEventInChannelForBot(data.ChannelID, ev)
@@ -1442,6 +1453,7 @@ func apiMessageCreate(ctx *ChatContext, data *struct {
 	IdentityID   string   `json:"identity_id"`
 	ICMode       string   `json:"ic_mode"`
 	DisplayOrder *float64 `json:"display_order"`
+	GMRoll       bool     `json:"gm_roll"` // GM 暗骰，揭示前仅发送者与 GM 可见
 }) (any, error) {
 	echo := ctx.Echo
 	db := model.GetDB()
@@ -1467,6 +1479,14 @@ func apiMessageCreate(ctx *ChatContext, data *struct {
 		if !pm.CanWithChannelRole(ctx.User.ID, channelId, pm.PermFuncChannelTextSend, pm.PermFuncChannelTextSendAll) {
 			return nil, nil
 		}
+		if err := service.ChannelSpeakCheck(channelId, ctx.User.ID, ctx.RemoteIP()); err != nil {
+			return nil, err
+		}
+		if ch, _ := model.ChannelGet(channelId); ch != nil {
+			if err := service.ChannelCheckAcceptsMessages(ch); err != nil {
+				return nil, err
+			}
+		}
 	} else {
 		// 好友/陌生人
 		fr, _ := model.FriendRelationGetByID(channelId)
@@ -1480,6 +1500,12 @@ func apiMessageCreate(ctx *ChatContext, data *struct {
 		}
 	}
 
+	if !ctx.User.IsBot {
+		if handled, ret, err := botCommandTryDispatch(ctx, channelId, data.Content); handled {
+			return ret, err
+		}
+	}
+
 	content := data.Content
 
 	// BOT 消息的 Satori 内容规范化
@@ -1538,7 +1564,11 @@ func apiMessageCreate(ctx *ChatContext, data *struct {
 	channelData := channel.ToProtocolType()
 	var renderResult *service.DiceRenderResult
 	var isHiddenDice bool
-	if channel.BuiltInDiceEnabled {
+	// 内置小海豹处理的指令保留原文，由小海豹回复结果
+	sealBotCommand := !data.GMRoll && appConfig.BuiltInSealBotEnable && channel.BuiltInDiceEnabled && !channel.BotFeatureEnabled && service.SealBotMatch(content)
+	if sealBotCommand {
+		isHiddenDice = service.SealBotIsHidden(content)
+	} else if channel.BuiltInDiceEnabled {
 		renderResult, err = service.RenderDiceContent(content, channel.DefaultDiceExpr, nil)
 		if err != nil {
 			return nil, err
@@ -1578,6 +1608,15 @@ func apiMessageCreate(ctx *ChatContext, data *struct {
 			}
 		}
 	}
+	if data.GMRoll {
+		if len(channelId) >= 30 {
+			return nil, fmt.Errorf("私聊中不能使用GM暗骰")
+		}
+		if whisperTo != "" || len(data.WhisperToIds) > 0 {
+			return nil, fmt.Errorf("GM暗骰不能与悄悄话同时使用")
+		}
+		isHiddenDice = false
+	}
 	if isHiddenDice && len(channelId) < 30 && whisperTo == "" && !channel.BotFeatureEnabled {
 		hiddenWhisperToSelf = true
 		whisperTo = ctx.User.ID
@@ -1688,6 +1727,7 @@ func apiMessageCreate(ctx *ChatContext, data *struct {
 		WidgetData:   widgetData,
 		DisplayOrder: displayOrder,
 		ICMode:       icMode,
+		IsGMRoll:     data.GMRoll,
 
 		SenderMemberName: member.Nickname,
 		IsWhisper:        whisperUser != nil,
@@ -1767,6 +1807,9 @@ func apiMessageCreate(ctx *ChatContext, data *struct {
 			if quote.WhisperTarget != nil {
 				qData.WhisperTo = quote.WhisperTarget.ToProtocolType()
 			}
+			if quote.IsGMRoll {
+				qData = gmRollMaskProtocol(qData)
+			}
 			messageData.Quote = qData
 		} else {
 			messageData.Quote = nil
@@ -1783,6 +1826,7 @@ func apiMessageCreate(ctx *ChatContext, data *struct {
 				IsWhisper:    whisperUser != nil,
 				IsHiddenDice: isHiddenDice,
 				SenderUserID: ctx.User.ID,
+				IsGMRoll:     data.GMRoll,
 			}
 			if whisperUser != nil {
 				msgContext.WhisperToUserID = whisperUser.ID
@@ -1810,6 +1854,8 @@ func apiMessageCreate(ctx *ChatContext, data *struct {
 			recipients = lo.Uniq(recipients)
 			ctx.BroadcastEventInChannelToUsers(data.ChannelID, recipients, ev)
 			ctx.BroadcastEventInChannelForBot(data.ChannelID, ev)
+		} else if m.IsGMRoll {
+			broadcastGMRollEvent(ctx, data.ChannelID, ctx.User.ID, ev)
 		} else {
 			ctx.BroadcastEventInChannel(data.ChannelID, ev)
 			ctx.BroadcastEventInChannelForBot(data.ChannelID, ev)
@@ -1817,7 +1863,7 @@ func apiMessageCreate(ctx *ChatContext, data *struct {
 
 		_ = model.WebhookEventLogAppendForMessage(data.ChannelID, "message-created", m.ID)
 
-		if isHiddenDice && len(channelId) < 30 {
+		if isHiddenDice && len(channelId) < 30 && !sealBotCommand {
 			go sendHiddenDicePrivateCopy(ctx, channelData, messageData)
 		}
 		if channel.PermType == "private" && ctx.User != nil && ctx.User.IsBot {
@@ -1825,7 +1871,7 @@ func apiMessageCreate(ctx *ChatContext, data *struct {
 		}
 
 		// 当频道启用了机器人骰点时，不再触发内置小海豹以避免覆盖自定义机器人回复
-		if appConfig.BuiltInSealBotEnable && whisperUser == nil && channel.BuiltInDiceEnabled && !channel.BotFeatureEnabled {
+		if sealBotCommand && (whisperUser == nil || hiddenWhisperToSelf) {
 			botReq := &struct {
 				ChannelID string `json:"channel_id"`
 				QuoteID   string `json:"quote_id"`
@@ -1841,7 +1887,7 @@ func apiMessageCreate(ctx *ChatContext, data *struct {
 				ClientID:  data.ClientID,
 				ICMode:    icMode,
 			}
-			builtinSealBotSolve(ctx, botReq, channelData, isHiddenDice)
+			builtinSealBotSolve(ctx, botReq, channelData, m.SenderMemberName, channel.DefaultDiceExpr)
 		}
 
 		if channel.PermType == "private" {
@@ -1885,8 +1931,12 @@ func apiMessageCreate(ctx *ChatContext, data *struct {
 				})
 			}
 
+			readAt := time.Now().UnixMilli()
 			_ = model.ChannelReadInitInBatches(data.ChannelID, uids)
 			_ = model.ChannelReadSetInBatch([]string{data.ChannelID}, uidsOnline)
+			if channel.ReadReceipts {
+				queueChannelReadUpdated(ctx, data.ChannelID, uidsOnline, readAt)
+			}
 
 			// 发送快速更新通知
 			ctx.BroadcastJSON(noticePayload, uidsOnline)
@@ -1904,6 +1954,12 @@ func apiMessageCreate(ctx *ChatContext, data *struct {
 	}, nil
 }
 
+type messageListResult struct {
+	Data          []*model.MessageModel `json:"data"`
+	Next          string                `json:"next"`
+	CanReorderAll bool                  `json:"can_reorder_all"`
+}
+
 func apiMessageList(ctx *ChatContext, data *struct {
 	ChannelID string `json:"channel_id"`
 	Next      string `json:"next"`
@@ -1920,16 +1976,28 @@ func apiMessageList(ctx *ChatContext, data *struct {
 	RoleIDs         []string `json:"role_ids"`
 	IncludeRoleless bool     `json:"include_roleless"`
 	Limit           int      `json:"limit"`
+	PreviewUserID   string   `json:"preview_user_id"` // 管理员以该用户视角查看，仅支持世界频道
 }) (any, error) {
 	db := model.GetDB()
 
 	// 权限检查
 	channelId := data.ChannelID
+	if previewUserID := strings.TrimSpace(data.PreviewUserID); previewUserID != "" {
+		ch, _ := model.ChannelGet(channelId)
+		if ch == nil || ch.ID == "" || ch.IsPrivate || ch.WorldID == "" {
+			return nil, fmt.Errorf("仅世界频道支持预览")
+		}
+		preview, err := ctx.previewAs(ch.WorldID, previewUserID)
+		if err != nil {
+			return nil, err
+		}
+		ctx = preview
+	}
 	if ctx.IsReadOnly() {
 		if len(channelId) >= 30 {
 			return nil, fmt.Errorf("频道不可公开访问")
 		}
-		if _, err := service.CanGuestAccessChannel(channelId); err != nil {
+		if _, err := service.CanGuestAccessChannel(channelId, ctx.RemoteIP()); err != nil {
 			return nil, err
 		}
 	} else if len(channelId) < 30 { // 注意，这不是一个好的区分方式
@@ -1937,6 +2005,9 @@ func apiMessageList(ctx *ChatContext, data *struct {
 		if !pm.CanWithChannelRole(ctx.User.ID, channelId, pm.PermFuncChannelRead, pm.PermFuncChannelReadAll) {
 			return nil, nil
 		}
+		if err := service.ChannelBanCheck(channelId, ctx.User.ID, ctx.RemoteIP()); err != nil {
+			return nil, err
+		}
 	} else {
 		// 好友/陌生人
 		fr, _ := model.FriendRelationGetByID(channelId)
@@ -2072,10 +2143,15 @@ func apiMessageList(ctx *ChatContext, data *struct {
 		return []string{i.QuoteID}
 	}, func(i *model.MessageModel, x []*model.MessageModel) {
 		i.Quote = x[0]
-	}, "id, content, created_at, user_id, is_revoked, is_deleted, whisper_to, channel_id, sender_member_name, sender_identity_id, sender_identity_name, sender_identity_color, sender_identity_avatar_id, whisper_sender_member_id, whisper_sender_member_name, whisper_sender_user_name, whisper_sender_user_nick, whisper_target_member_id, whisper_target_member_name, whisper_target_user_name, whisper_target_user_nick")
+	}, "id, content, created_at, user_id, is_revoked, is_deleted, whisper_to, channel_id, is_gm_roll, sender_member_name, sender_identity_id, sender_identity_name, sender_identity_color, sender_identity_avatar_id, whisper_sender_member_id, whisper_sender_member_name, whisper_sender_user_name, whisper_sender_user_nick, whisper_target_member_id, whisper_target_member_name, whisper_target_user_name, whisper_target_user_nick")
 
-	if !ctx.IsReadOnly() {
+	if !ctx.IsReadOnly() && !ctx.IsPreview() {
+		readAt := time.Now().UnixMilli()
 		_ = model.ChannelReadSet(data.ChannelID, ctx.User.ID)
+		// 只在加载最新一页时广播，翻阅历史不算读到新位置
+		if data.Next == "" && channel != nil && channel.ReadReceipts {
+			broadcastChannelReadUpdated(ctx, data.ChannelID, []string{ctx.User.ID}, readAt)
+		}
 	}
 
 	q.Count(&count)
@@ -2201,6 +2277,10 @@ func apiMessageList(ctx *ChatContext, data *struct {
 			i.Quote.EnsureWhisperMeta()
 		}
 	}
+	maskGMRollsForUser(ctx.User.ID, channel, data.ChannelID, items)
+	if ctx.IsPreview() {
+		maskWhispersForPreview(ctx.PreviewActorID, items, recipientMap)
+	}
 
 	if ctx.User != nil && len(items) > 0 {
 		ids := make([]string, 0, len(items))
@@ -2225,17 +2305,31 @@ func apiMessageList(ctx *ChatContext, data *struct {
 		}
 	}
 
-	return &struct {
-		Data          []*model.MessageModel `json:"data"`
-		Next          string                `json:"next"`
-		CanReorderAll bool                  `json:"can_reorder_all"`
-	}{
+	return &messageListResult{
 		Data:          items,
 		Next:          next,
 		CanReorderAll: canReorderAll,
 	}, nil
 }
 
+// resolveMessageEditAccess 权限检查：是否为消息作者，或世界管理员代编辑
+func resolveMessageEditAccess(userID string, channel *model.ChannelModel, msg *model.MessageModel) (isAuthor bool, isAdminEdit bool) {
+	isAuthor = msg.UserID == userID
+	if isAuthor || channel == nil || channel.WorldID == "" {
+		return isAuthor, false
+	}
+	world, err := service.GetWorldByID(channel.WorldID)
+	if err == nil && world != nil && world.AllowAdminEditMessages {
+		if service.IsWorldAdmin(channel.WorldID, userID) {
+			// 检查目标消息作者是否为非管理员
+			if !service.IsWorldAdmin(channel.WorldID, msg.UserID) {
+				isAdminEdit = true
+			}
+		}
+	}
+	return false, isAdminEdit
+}
+
 func apiMessageUpdate(ctx *ChatContext, data *struct {
 	ChannelID  string  `json:"channel_id"`
 	MessageID  string  `json:"message_id"`
@@ -2268,27 +2362,19 @@ func apiMessageUpdate(ctx *ChatContext, data *struct {
 		return nil, nil
 	}
 
-	// 权限检查：是否为消息作者，或世界管理员代编辑
-	isAuthor := msg.UserID == ctx.User.ID
-	isAdminEdit := false
+	isAuthor, isAdminEdit := resolveMessageEditAccess(ctx.User.ID, channel, &msg)
 	editorUserName := strings.TrimSpace(ctx.User.Nickname)
 	if editorUserName == "" {
 		editorUserName = ctx.User.Username
 	}
-	if !isAuthor && channel.WorldID != "" {
-		world, err := service.GetWorldByID(channel.WorldID)
-		if err == nil && world != nil && world.AllowAdminEditMessages {
-			if service.IsWorldAdmin(channel.WorldID, ctx.User.ID) {
-				// 检查目标消息作者是否为非管理员
-				if !service.IsWorldAdmin(channel.WorldID, msg.UserID) {
-					isAdminEdit = true
-				}
-			}
-		}
-	}
 	if !isAuthor && !isAdminEdit {
 		return nil, nil
 	}
+	if channel.WorldID != "" {
+		if err := service.WorldSpeakCheck(channel.WorldID, ctx.User.ID, ctx.RemoteIP()); err != nil {
+			return nil, err
+		}
+	}
 	channelData := channel.ToProtocolType()
 
 	var authorUser *model.UserModel
@@ -2400,6 +2486,9 @@ func apiMessageUpdate(ctx *ChatContext, data *struct {
 			if quote.WhisperTarget != nil {
 				qData.WhisperTo = quote.WhisperTarget.ToProtocolType()
 			}
+			if quote.IsGMRoll {
+				qData = gmRollMaskProtocol(qData)
+			}
 			messageData.Quote = qData
 		}
 		return messageData
@@ -2504,6 +2593,8 @@ func apiMessageUpdate(ctx *ChatContext, data *struct {
 		}
 		recipients = lo.Uniq(recipients)
 		ctx.BroadcastEventInChannelToUsers(data.ChannelID, recipients, ev)
+	} else if msg.IsGMRoll {
+		broadcastGMRollEvent(ctx, data.ChannelID, msg.UserID, ev)
 	} else {
 		ctx.BroadcastEventInChannel(data.ChannelID, ev)
 		ctx.BroadcastEventInChannelForBot(data.ChannelID, ev)
@@ -2666,6 +2757,8 @@ func apiMessageReorder(ctx *ChatContext, data *struct {
 		}
 		recipients = lo.Uniq(recipients)
 		ctx.BroadcastEventInChannelToUsers(data.ChannelID, recipients, ev)
+	} else if msg.IsGMRoll {
+		broadcastGMRollEvent(ctx, data.ChannelID, msg.UserID, ev)
 	} else {
 		ctx.BroadcastEventInChannel(data.ChannelID, ev)
 		ctx.BroadcastEventInChannelForBot(data.ChannelID, ev)
@@ -2686,16 +2779,9 @@ func apiMessageEditHistory(ctx *ChatContext, data *struct {
 	ChannelID string `json:"channel_id"`
 	MessageID string `json:"message_id"`
 }) (any, error) {
-	channelId := data.ChannelID
-	if len(channelId) < 30 {
-		if !pm.CanWithChannelRole(ctx.User.ID, channelId, pm.PermFuncChannelRead, pm.PermFuncChannelReadAll) {
-			return nil, nil
-		}
-	} else {
-		fr, _ := model.FriendRelationGetByID(channelId)
-		if fr.ID == "" {
-			return nil, nil
-		}
+	// 悄悄话与 GM 暗骰的可见性与查看版本差异一致
+	if _, err := loadMessageForRevision(ctx, data.ChannelID, data.MessageID); err != nil {
+		return nil, err
 	}
 
 	var histories []model.MessageEditHistoryModel
@@ -2717,18 +2803,20 @@ func apiMessageEditHistory(ctx *ChatContext, data *struct {
 	}
 
 	type historyItem struct {
+		Revision    int            `json:"revision"`
 		PrevContent string         `json:"prev_content"`
 		EditedAt    int64          `json:"edited_at"`
 		Editor      *protocol.User `json:"editor"`
 	}
 
 	var resp []historyItem
-	for _, h := range histories {
+	for i, h := range histories {
 		var editor *protocol.User
 		if u, ok := id2User[h.EditorID]; ok {
 			editor = u.ToProtocolType()
 		}
 		resp = append(resp, historyItem{
+			Revision:    i,
 			PrevContent: h.PrevContent,
 			EditedAt:    h.CreatedAt.UnixMilli(),
 			Editor:      editor,
@@ -2740,6 +2828,125 @@ func apiMessageEditHistory(ctx *ChatContext, data *struct {
 	}{History: resp}, nil
 }
 
+// loadMessageForRevision 读取可查看编辑历史的消息；悄悄话仅对参与者可见，GM 暗骰仅对发送者与 GM 可见
+func loadMessageForRevision(ctx *ChatContext, channelID, messageID string) (*model.MessageModel, error) {
+	if len(channelID) < 30 {
+		if !pm.CanWithChannelRole(ctx.User.ID, channelID, pm.PermFuncChannelRead, pm.PermFuncChannelReadAll) {
+			return nil, fmt.Errorf("无权查看该频道")
+		}
+	} else {
+		fr, _ := model.FriendRelationGetByID(channelID)
+		if fr.ID == "" || (fr.UserID1 != ctx.User.ID && fr.UserID2 != ctx.User.ID) {
+			return nil, fmt.Errorf("无权查看该频道")
+		}
+	}
+	var msg model.MessageModel
+	model.GetDB().Where("id = ? AND channel_id = ?", messageID, channelID).Limit(1).Find(&msg)
+	if msg.ID == "" || msg.IsRevoked || msg.IsDeleted {
+		return nil, fmt.Errorf("消息不存在")
+	}
+	if msg.IsWhisper && msg.UserID != ctx.User.ID && msg.WhisperTo != ctx.User.ID && !model.HasWhisperRecipient(msg.ID, ctx.User.ID) {
+		return nil, fmt.Errorf("消息不存在")
+	}
+	if gmRollHiddenFrom(ctx.User.ID, &msg) {
+		return nil, fmt.Errorf("消息不存在")
+	}
+	return &msg, nil
+}
+
+// apiMessageEditDiff 比较消息的两个版本，revision 留空表示当前版本
+func apiMessageEditDiff(ctx *ChatContext, data *struct {
+	ChannelID    string `json:"channel_id"`
+	MessageID    string `json:"message_id"`
+	FromRevision *int   `json:"from_revision"`
+	ToRevision   *int   `json:"to_revision"`
+}) (any, error) {
+	msg, err := loadMessageForRevision(ctx, data.ChannelID, data.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := service.ListMessageRevisions(msg)
+	if err != nil {
+		return nil, err
+	}
+	// 未指定起点时与上一个版本比较
+	fromRevision := data.FromRevision
+	if fromRevision == nil {
+		prev := len(revisions) - 2
+		if data.ToRevision != nil && *data.ToRevision >= 0 {
+			prev = *data.ToRevision - 1
+		}
+		prev = max(prev, 0)
+		fromRevision = &prev
+	}
+	from, err := service.PickMessageRevision(revisions, fromRevision)
+	if err != nil {
+		return nil, err
+	}
+	to, err := service.PickMessageRevision(revisions, data.ToRevision)
+	if err != nil {
+		return nil, err
+	}
+
+	return &struct {
+		From  service.MessageRevision     `json:"from"`
+		To    service.MessageRevision     `json:"to"`
+		Total int                         `json:"total"`
+		Diff  *service.MessageContentDiff `json:"diff"`
+	}{
+		From:  *from,
+		To:    *to,
+		Total: len(revisions),
+		Diff:  service.DiffMessageContent(from.Content, to.Content),
+	}, nil
+}
+
+// apiMessageEditRollback 将消息恢复到指定版本，恢复本身作为一次新的编辑记录
+func apiMessageEditRollback(ctx *ChatContext, data *struct {
+	ChannelID string `json:"channel_id"`
+	MessageID string `json:"message_id"`
+	Revision  *int   `json:"revision"`
+}) (any, error) {
+	if data.Revision == nil || *data.Revision < 0 {
+		return nil, fmt.Errorf("请指定要恢复的版本")
+	}
+	msg, err := loadMessageForRevision(ctx, data.ChannelID, data.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	channel, _ := model.ChannelGet(data.ChannelID)
+	if channel.ID == "" {
+		return nil, fmt.Errorf("频道不存在")
+	}
+	isAuthor, isAdminEdit := resolveMessageEditAccess(ctx.User.ID, channel, msg)
+	if !isAuthor && !isAdminEdit {
+		return nil, fmt.Errorf("无权恢复该消息")
+	}
+	revisions, err := service.ListMessageRevisions(msg)
+	if err != nil {
+		return nil, err
+	}
+	target, err := service.PickMessageRevision(revisions, data.Revision)
+	if err != nil {
+		return nil, err
+	}
+	if target.IsCurrent || target.Content == msg.Content {
+		return nil, fmt.Errorf("该版本与当前内容相同")
+	}
+
+	return apiMessageUpdate(ctx, &struct {
+		ChannelID  string  `json:"channel_id"`
+		MessageID  string  `json:"message_id"`
+		Content    string  `json:"content"`
+		ICMode     string  `json:"ic_mode"`
+		IdentityID *string `json:"identity_id"`
+	}{
+		ChannelID: data.ChannelID,
+		MessageID: data.MessageID,
+		Content:   target.Content,
+	})
+}
+
 func normalizeTypingState(raw string, enabled *bool) protocol.TypingState {
 	state := strings.ToLower(strings.TrimSpace(raw))
 	switch state {
@@ -2969,91 +3176,65 @@ func builtinSealBotSolve(ctx *ChatContext, data *struct {
 	WhisperTo string `json:"whisper_to"`
 	ClientID  string `json:"client_id"`
 	ICMode    string `json:"ic_mode"`
-}, channelData *protocol.Channel, isHiddenDice bool) {
-	content := data.Content
-	if len(content) >= 2 && (content[0] == '/' || content[0] == '.') && content[1] == 'x' {
-		vm := ds.NewVM()
-		var botText string
-		expr := strings.TrimSpace(content[2:])
+}, channelData *protocol.Channel, senderName string, defaultDiceExpr string) {
+	reply := service.SealBotSolve(&service.SealBotRequest{
+		UserID:          ctx.User.ID,
+		ChannelID:       data.ChannelID,
+		SenderName:      senderName,
+		Content:         data.Content,
+		DefaultDiceExpr: defaultDiceExpr,
+	})
+	if reply == nil {
+		return
+	}
+	botUser := service.SealBotEnsureUser()
 
-		if expr == "" {
-			expr = "d100"
-		}
+	msgICMode := strings.TrimSpace(strings.ToLower(data.ICMode))
+	if msgICMode == "" {
+		msgICMode = "ic"
+	}
+	botText := reply.Text
+	if reply.Hidden {
+		botText = reply.PublicText
+	}
 
-		err := vm.Run(expr)
-		vm.Config.EnableDiceWoD = true
-		vm.Config.EnableDiceCoC = true
-		vm.Config.EnableDiceFate = true
-		vm.Config.EnableDiceDoubleCross = true
-		vm.Config.DefaultDiceSideExpr = "面数 ?? 100"
-		vm.Config.OpCountLimit = 30000
+	m := model.MessageModel{
+		StringPKBaseModel: model.StringPKBaseModel{
+			ID: utils.NewID(),
+		},
+		UserID:    service.SealBotUserID,
+		ChannelID: data.ChannelID,
+		MemberID:  service.SealBotUserID,
+		Content:   botText,
+		ICMode:    msgICMode,
+	}
+	model.GetDB().Create(&m)
 
-		if err != nil {
-			botText = "出错:" + err.Error()
-		} else {
-			sb := strings.Builder{}
-			sb.WriteString(fmt.Sprintf("算式: %s\n", expr))
-			sb.WriteString(fmt.Sprintf("过程: %s\n", vm.GetDetailText()))
-			sb.WriteString(fmt.Sprintf("结果: %s\n", vm.Ret.ToString()))
-			sb.WriteString(fmt.Sprintf("栈顶: %d 层数:%d 算力: %d\n", vm.StackTop(), vm.Depth(), vm.NumOpCount))
-			sb.WriteString(fmt.Sprintf("注: 这是一只小海豹，只有基本骰点功能，完整功能请接入海豹核心"))
-			botText = sb.String()
-		}
-
-		msgICMode := strings.TrimSpace(strings.ToLower(data.ICMode))
-		if msgICMode == "" {
-			msgICMode = "ic"
-		}
-
-		m := model.MessageModel{
-			StringPKBaseModel: model.StringPKBaseModel{
-				ID: utils.NewID(),
-			},
-			UserID:    "BOT:1000",
-			ChannelID: data.ChannelID,
-			MemberID:  "BOT:1000",
-			Content:   botText,
-			ICMode:    msgICMode,
-		}
-		if isHiddenDice && len(data.ChannelID) < 30 {
-			m.IsWhisper = true
-			m.WhisperTo = ctx.User.ID
-			m.WhisperTarget = ctx.User
-		}
-		model.GetDB().Create(&m)
-
-		userData := &protocol.User{
-			ID:     "BOT:1000",
-			Nick:   "小海豹",
-			Avatar: "",
-			IsBot:  true,
-		}
-		messageData := m.ToProtocolType2(channelData)
-		messageData.User = userData
-		messageData.Member = &protocol.GuildMember{
-			Name: userData.Nick,
-			Nick: userData.Nick,
-		}
-
-		if m.IsWhisper {
-			ctx.BroadcastEventInChannelToUsers(data.ChannelID, []string{ctx.User.ID}, &protocol.Event{
-				// 协议规定: 事件中必须含有 channel，message，user
-				Type:    protocol.EventMessageCreated,
-				Message: messageData,
-				Channel: channelData,
-				User:    userData,
-			})
-		} else {
-			ctx.BroadcastEventInChannel(data.ChannelID, &protocol.Event{
-				// 协议规定: 事件中必须含有 channel，message，user
-				Type:    protocol.EventMessageCreated,
-				Message: messageData,
-				Channel: channelData,
-				User:    userData,
-			})
-		}
+	userData := &protocol.User{
+		ID:     service.SealBotUserID,
+		Nick:   "小海豹",
+		Avatar: "",
+		IsBot:  true,
+	}
+	messageData := m.ToProtocolType2(channelData)
+	messageData.User = userData
+	messageData.Member = &protocol.GuildMember{
+		Name: userData.Nick,
+		Nick: userData.Nick,
+	}
 
-		_ = model.WebhookEventLogAppendForMessage(data.ChannelID, "message-created", m.ID)
+	ctx.BroadcastEventInChannel(data.ChannelID, &protocol.Event{
+		// 协议规定: 事件中必须含有 channel，message，user
+		Type:    protocol.EventMessageCreated,
+		Message: messageData,
+		Channel: channelData,
+		User:    userData,
+	})
+	_ = model.WebhookEventLogAppendForMessage(data.ChannelID, "message-created", m.ID)
+
+	// 暗骰结果通过小海豹私聊发给发送者
+	if reply.Hidden && botUser != nil && len(data.ChannelID) < 30 {
+		go sendHiddenDicePrivateCopy(ctx, channelData, &protocol.Message{Content: reply.Text, IcMode: msgICMode})
 	}
 }
 
@@ -3061,7 +3242,7 @@ func sendHiddenDicePrivateCopy(ctx *ChatContext, sourceChannel *protocol.Channel
 	if ctx == nil || ctx.User == nil || ctx.User.ID == "" || sourceChannel == nil || originalMsg == nil {
 		return
 	}
-	const botID = "BOT:1000"
+	botID := service.SealBotUserID
 	botUser := model.UserGet(botID)
 	if botUser == nil {
 		return
@@ -3303,6 +3484,9 @@ func apiWidgetInteract(ctx *ChatContext, data *struct {
 	if !pm.CanWithChannelRole(ctx.User.ID, msg.ChannelID, pm.PermFuncChannelRead) {
 		return nil, fmt.Errorf("forbidden")
 	}
+	if err := service.ChannelSpeakCheck(msg.ChannelID, ctx.User.ID, ctx.RemoteIP()); err != nil {
+		return nil, err
+	}
 
 	// Whisper check
 	if msg.IsWhisper {
@@ -3435,6 +3619,8 @@ func apiWidgetInteract(ctx *ChatContext, data *struct {
 		}
 		recipients = lo.Uniq(recipients)
 		ctx.BroadcastEventInChannelToUsers(fullMsg.ChannelID, recipients, ev)
+	} else if fullMsg.IsGMRoll {
+		broadcastGMRollEvent(ctx, fullMsg.ChannelID, fullMsg.UserID, ev)
 	} else {
 		ctx.BroadcastEventInChannel(fullMsg.ChannelID, ev)
 		ctx.BroadcastEventInChannelForBot(fullMsg.ChannelID, ev)
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/protocol"
+	"sealchat/service"
+)
+
+type messageBulkRequest struct {
+	ChannelID string                    `json:"channel_id"`
+	Action    string                    `json:"action"`
+	Filter    service.MessageBulkFilter `json:"filter"`
+	Params    service.MessageBulkParams `json:"params"`
+}
+
+// isChannelGameMaster 批量操作仅对主持人开放：频道管理员、世界管理员或系统管理员
+func isChannelGameMaster(channel *model.ChannelModel, userID string) bool {
+	if channel == nil || channel.ID == "" {
+		return false
+	}
+	if isChannelAdminUser(channel, channel.ID, userID) {
+		return true
+	}
+	if channel.WorldID != "" && service.IsWorldAdmin(channel.WorldID, userID) {
+		return true
+	}
+	return pm.CanWithSystemRole(userID, pm.PermModAdmin)
+}
+
+func buildMessageBulkRequest(ctx *ChatContext, data *messageBulkRequest) (*service.MessageBulkRequest, error) {
+	channelID := strings.TrimSpace(data.ChannelID)
+	if channelID == "" {
+		return nil, fmt.Errorf("channel_id 不能为空")
+	}
+	channel, err := model.ChannelGet(channelID)
+	if err != nil {
+		return nil, err
+	}
+	if channel.ID == "" {
+		return nil, fmt.Errorf("频道不存在")
+	}
+	if !isChannelGameMaster(channel, ctx.User.ID) {
+		return nil, fmt.Errorf("仅主持人可以执行批量操作")
+	}
+	action := strings.ToLower(strings.TrimSpace(data.Action))
+	if action == model.MessageBulkActionMove {
+		target, _ := model.ChannelGet(strings.TrimSpace(data.Params.TargetChannelID))
+		if target.ID != "" && !isChannelGameMaster(target, ctx.User.ID) {
+			return nil, fmt.Errorf("无权限向目标频道移动消息")
+		}
+	}
+	return &service.MessageBulkRequest{
+		Channel:    channel,
+		OperatorID: ctx.User.ID,
+		Action:     action,
+		Filter:     data.Filter,
+		Params:     data.Params,
+		// 与单条归档、删除一致：不能批量处理其他管理员的消息
+		IsProtectedAuthor: func(userID string) bool {
+			return isChannelAdminUser(channel, channel.ID, userID) || service.IsWorldAdmin(channel.WorldID, userID)
+		},
+	}, nil
+}
+
+// apiMessageBulkPreview 试运行，返回将被处理与因权限跳过的消息数
+func apiMessageBulkPreview(ctx *ChatContext, data *messageBulkRequest) (any, error) {
+	req, err := buildMessageBulkRequest(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	return service.MessageBulkPreview(req)
+}
+
+// apiMessageBulkStart 启动批量操作；进度只推送给操作者，完成后每个频道只广播一次结果
+func apiMessageBulkStart(ctx *ChatContext, data *messageBulkRequest) (any, error) {
+	req, err := buildMessageBulkRequest(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	operatorID := ctx.User.ID
+	operator := ctx.User.ToProtocolType()
+	channelData := req.Channel.ToProtocolType()
+
+	job, err := service.MessageBulkStart(req, service.MessageBulkHooks{
+		OnProgress: func(job *model.MessageBulkJobModel) {
+			sendMessageBulkEventToUser(ctx, operatorID, &protocol.Event{
+				Type:        protocol.EventMessageBulkProgress,
+				Channel:     channelData,
+				User:        operator,
+				MessageBulk: buildMessageBulkPayload(job, nil),
+			})
+		},
+		OnFinish: func(job *model.MessageBulkJobModel, applied map[string][]string) {
+			sendMessageBulkEventToUser(ctx, operatorID, &protocol.Event{
+				Type:        protocol.EventMessageBulkProgress,
+				Channel:     channelData,
+				User:        operator,
+				MessageBulk: buildMessageBulkPayload(job, nil),
+			})
+			for channelID, ids := range applied {
+				evChannel := channelData
+				if channelID != req.Channel.ID {
+					if target, _ := model.ChannelGet(channelID); target.ID != "" {
+						evChannel = target.ToProtocolType()
+					}
+				}
+				ev := &protocol.Event{
+					Type:        protocol.EventMessageBulkApplied,
+					Channel:     evChannel,
+					User:        operator,
+					MessageBulk: buildMessageBulkPayload(job, ids),
+				}
+				ctx.BroadcastEventInChannel(channelID, ev)
+				ctx.BroadcastEventInChannelForBot(channelID, ev)
+			}
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &struct {
+		Job *model.MessageBulkJobModel `json:"job"`
+	}{Job: job}, nil
+}
+
+func apiMessageBulkStatus(ctx *ChatContext, data *struct {
+	JobID string `json:"job_id"`
+}) (any, error) {
+	job, err := model.MessageBulkJobGet(strings.TrimSpace(data.JobID))
+	if err != nil {
+		return nil, err
+	}
+	if job == nil || job.OperatorID != ctx.User.ID {
+		return nil, fmt.Errorf("任务不存在")
+	}
+	return &struct {
+		Job *model.MessageBulkJobModel `json:"job"`
+	}{Job: job}, nil
+}
+
+func buildMessageBulkPayload(job *model.MessageBulkJobModel, ids []string) *protocol.MessageBulkEventPayload {
+	return &protocol.MessageBulkEventPayload{
+		JobID:           job.ID,
+		Action:          job.Action,
+		Status:          job.Status,
+		Total:           job.Total,
+		Processed:       job.Processed,
+		Error:           job.ErrorMsg,
+		MessageIDs:      ids,
+		TargetChannelID: job.TargetChannelID,
+	}
+}
+
+func sendMessageBulkEventToUser(ctx *ChatContext, userID string, ev *protocol.Event) {
+	ev.Timestamp = time.Now().Unix()
+	ctx.BroadcastToUserJSON(userID, struct {
+		protocol.Event
+		Op protocol.Opcode `json:"op"`
+	}{
+		Event: *ev,
+		Op:    protocol.OpEvent,
+	})
+}
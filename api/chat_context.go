@@ -1,12 +1,16 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"sealchat/model"
 	"sealchat/protocol"
 	"sealchat/service"
 	"sealchat/utils"
+
+	"github.com/samber/lo"
 )
 
 type ChatContext struct {
@@ -18,6 +22,9 @@ type ChatContext struct {
 
 	ChannelUsersMap *utils.SyncMap[string, *utils.SyncSet[string]]
 	UserId2ConnInfo *utils.SyncMap[string, *utils.SyncMap[*WsSyncConn, *ConnInfo]]
+
+	// PreviewActorID 非空时表示该管理员正以 User 的视角预览，只读取数据，不产生已读等副作用
+	PreviewActorID string
 }
 
 func (ctx *ChatContext) IsGuest() bool {
@@ -28,10 +35,64 @@ func (ctx *ChatContext) IsObserver() bool {
 	return ctx != nil && ctx.ConnInfo != nil && ctx.ConnInfo.IsObserver
 }
 
+// RemoteIP 当前连接的客户端 IP，未知时为空
+func (ctx *ChatContext) RemoteIP() string {
+	if ctx == nil || ctx.ConnInfo == nil {
+		return ""
+	}
+	return ctx.ConnInfo.RemoteIP
+}
+
 func (ctx *ChatContext) IsReadOnly() bool {
 	return ctx.IsGuest() || ctx.IsObserver()
 }
 
+func (ctx *ChatContext) IsPreview() bool {
+	return ctx != nil && ctx.PreviewActorID != ""
+}
+
+// previewAs 校验当前用户是否为该世界管理员，并返回以目标用户身份读取数据的上下文副本
+func (ctx *ChatContext) previewAs(worldID, targetUserID string) (*ChatContext, error) {
+	if ctx.IsReadOnly() || ctx.User == nil {
+		return nil, fmt.Errorf("当前连接不支持预览")
+	}
+	target, err := service.ChannelPermPreviewCheck(worldID, ctx.User.ID, targetUserID)
+	if err != nil {
+		if errors.Is(err, service.ErrWorldPermission) {
+			return nil, fmt.Errorf("仅世界管理员可预览其他用户的视角")
+		}
+		return nil, err
+	}
+	preview := *ctx
+	preview.User = target
+	preview.Members = nil
+	preview.PreviewActorID = ctx.User.ID
+	return &preview, nil
+}
+
+// maskWhispersForPreview 预览者本人不在其中的悄悄话只显示存在：内容与组件数据（如骰点结果）一律清空，
+// 引用的悄悄话同时去掉收件人信息
+func maskWhispersForPreview(actorID string, items []*model.MessageModel, recipientMap map[string][]string) {
+	hidden := func(m *model.MessageModel) bool {
+		return m.IsWhisper && m.UserID != actorID && m.WhisperTo != actorID &&
+			!lo.Contains(recipientMap[m.ID], actorID)
+	}
+	for _, i := range items {
+		if hidden(i) {
+			i.Content = ""
+			i.WidgetData = ""
+		}
+		if q := i.Quote; q != nil && hidden(q) {
+			q.Content = ""
+			q.WidgetData = ""
+			q.WhisperTarget = nil
+			q.WhisperTargets = nil
+			q.WhisperMeta = nil
+			q.EnsureWhisperMeta()
+		}
+	}
+}
+
 func userHasChannelConnection(userId string, channelId string, userId2ConnInfo *utils.SyncMap[string, *utils.SyncMap[*WsSyncConn, *ConnInfo]], exclude *WsSyncConn) bool {
 	if userId == "" || channelId == "" || userId2ConnInfo == nil {
 		return false
@@ -58,64 +119,177 @@ func userHasChannelConnection(userId string, channelId string, userId2ConnInfo *
 }
 
 func (ctx *ChatContext) BroadcastToUserJSON(userId string, data any) {
-	value, _ := ctx.UserId2ConnInfo.Load(userId)
+	deliverJSONToUser(ctx.UserId2ConnInfo, userId, data)
+	publishBusBroadcast(&busBroadcast{Kind: busBroadcastUserJSON, UserIDs: []string{userId}}, data)
+}
+
+func (ctx *ChatContext) BroadcastJSON(data any, ignoredUserIds []string) {
+	deliverJSON(ctx.UserId2ConnInfo, data, ignoredUserIds)
+	publishBusBroadcast(&busBroadcast{Kind: busBroadcastJSON, UserIDs: ignoredUserIds}, data)
+}
+
+func (ctx *ChatContext) BroadcastEvent(data *protocol.Event) {
+	data.Timestamp = time.Now().Unix()
+	if channelId := eventChannelID(data); channelId != "" {
+		service.EventReplayRecord(channelId, data, nil, nil)
+	}
+	deliverEvent(ctx.UserId2ConnInfo, data)
+	publishBusBroadcast(&busBroadcast{Kind: busBroadcastAll, Event: data}, nil)
+}
+
+func (ctx *ChatContext) BroadcastEventInChannel(channelId string, data *protocol.Event) {
+	data.Timestamp = time.Now().Unix()
+	emitChannelEvent(ctx.UserId2ConnInfo, busBroadcastChannel, channelId, nil, data)
+}
+
+func (ctx *ChatContext) BroadcastEventInChannelForBot(channelId string, data *protocol.Event) {
+	if ctx == nil || ctx.UserId2ConnInfo == nil || channelId == "" || data == nil {
+		return
+	}
+	data.Timestamp = time.Now().Unix()
+	deliverEventInChannelForBot(ctx.UserId2ConnInfo, channelId, data)
+	publishBusBroadcast(&busBroadcast{Kind: busBroadcastBot, ChannelID: channelId, Event: data}, nil)
+}
+
+func (ctx *ChatContext) BroadcastEventInChannelExcept(channelId string, ignoredUserIds []string, data *protocol.Event) {
+	data.Timestamp = time.Now().Unix()
+	emitChannelEvent(ctx.UserId2ConnInfo, busBroadcastExcept, channelId, ignoredUserIds, data)
+}
+
+func (ctx *ChatContext) BroadcastEventInChannelToUsers(channelId string, userIds []string, data *protocol.Event) {
+	if len(userIds) == 0 {
+		return
+	}
+	data.Timestamp = time.Now().Unix()
+	emitChannelEvent(ctx.UserId2ConnInfo, busBroadcastUsers, channelId, userIds, data)
+}
+
+// emitChannelEvent 频道事件的统一出口：分配回放序号、投递本实例连接并同步给其他实例。
+// 不经过 ChatContext 的推送也应走这里，否则断线重连后无法补发
+func emitChannelEvent(userConnMap *userConnInfoMap, kind string, channelId string, userIds []string, data *protocol.Event) {
+	if channelId == "" || data == nil {
+		return
+	}
+	switch kind {
+	case busBroadcastExcept:
+		service.EventReplayRecord(channelId, data, nil, userIds)
+		deliverEventInChannelExcept(userConnMap, channelId, userIds, data)
+	case busBroadcastUsers:
+		service.EventReplayRecord(channelId, data, userIds, nil)
+		deliverEventInChannelToUsers(userConnMap, channelId, userIds, data)
+	default:
+		kind = busBroadcastChannel
+		userIds = nil
+		service.EventReplayRecord(channelId, data, nil, nil)
+		deliverEventInChannel(userConnMap, channelId, data)
+	}
+	publishBusBroadcast(&busBroadcast{Kind: kind, ChannelID: channelId, UserIDs: userIds, Event: data}, nil)
+}
+
+// emitUserEvent 推送给指定用户的所有连接；事件属于某个频道时同样记入该频道的回放，仅对这些用户可见
+func emitUserEvent(userConnMap *userConnInfoMap, userIds []string, data *protocol.Event) {
+	if len(userIds) == 0 || data == nil {
+		return
+	}
+	if channelId := eventChannelID(data); channelId != "" {
+		service.EventReplayRecord(channelId, data, userIds, nil)
+	}
+	deliverEventToUsers(userConnMap, userIds, data)
+	publishBusBroadcast(&busBroadcast{Kind: busBroadcastUserEvent, UserIDs: userIds, Event: data}, nil)
+}
+
+func eventChannelID(data *protocol.Event) string {
+	if data == nil || data.Channel == nil {
+		return ""
+	}
+	return data.Channel.ID
+}
+
+// 以下 deliver* 只负责本实例连接的投递，跨实例同步由调用方经事件总线完成
+
+type userConnInfoMap = utils.SyncMap[string, *utils.SyncMap[*WsSyncConn, *ConnInfo]]
+
+func writeEventFrame(conn *WsSyncConn, data *protocol.Event) {
+	if conn.botID != "" && !botEventAllowed(conn.botID, data) {
+		return
+	}
+	if conn.adapter != nil {
+		conn.adapter.writeEvent(conn, data)
+		return
+	}
+	_ = conn.WriteJSON(struct {
+		protocol.Event
+		Op protocol.Opcode `json:"op"`
+	}{
+		// 协议规定: 事件中必须含有 channel，message，user
+		Event: *data,
+		Op:    protocol.OpEvent,
+	})
+}
+
+// writeJSONFrame 写出非事件结构的推送，BOT 连接与事件一样按令牌过滤
+func writeJSONFrame(conn *WsSyncConn, data any) {
+	if conn.botID != "" && !botJSONAllowed(conn.botID, data) {
+		return
+	}
+	_ = conn.WriteJSON(data)
+}
+
+func deliverJSONToUser(userConnMap *userConnInfoMap, userId string, data any) {
+	if userConnMap == nil {
+		return
+	}
+	value, _ := userConnMap.Load(userId)
 	if value == nil {
 		return
 	}
 	value.Range(func(key *WsSyncConn, value *ConnInfo) bool {
-		_ = value.Conn.WriteJSON(data)
+		writeJSONFrame(value.Conn, data)
 		return true
 	})
 }
 
-func (ctx *ChatContext) BroadcastJSON(data any, ignoredUserIds []string) {
+func deliverJSON(userConnMap *userConnInfoMap, data any, ignoredUserIds []string) {
+	if userConnMap == nil {
+		return
+	}
 	ignoredMap := make(map[string]bool)
 	for _, id := range ignoredUserIds {
 		ignoredMap[id] = true
 	}
-	ctx.UserId2ConnInfo.Range(func(key string, value *utils.SyncMap[*WsSyncConn, *ConnInfo]) bool {
+	userConnMap.Range(func(key string, value *utils.SyncMap[*WsSyncConn, *ConnInfo]) bool {
 		if ignoredMap[key] {
 			return true
 		}
 		value.Range(func(key *WsSyncConn, value *ConnInfo) bool {
-			_ = value.Conn.WriteJSON(data)
+			writeJSONFrame(value.Conn, data)
 			return true
 		})
 		return true
 	})
 }
 
-func (ctx *ChatContext) BroadcastEvent(data *protocol.Event) {
-	data.Timestamp = time.Now().Unix()
-	ctx.UserId2ConnInfo.Range(func(key string, value *utils.SyncMap[*WsSyncConn, *ConnInfo]) bool {
+func deliverEvent(userConnMap *userConnInfoMap, data *protocol.Event) {
+	if userConnMap == nil {
+		return
+	}
+	userConnMap.Range(func(key string, value *utils.SyncMap[*WsSyncConn, *ConnInfo]) bool {
 		value.Range(func(key *WsSyncConn, value *ConnInfo) bool {
-			_ = value.Conn.WriteJSON(struct {
-				protocol.Event
-				Op protocol.Opcode `json:"op"`
-			}{
-				// 协议规定: 事件中必须含有 channel，message，user
-				Event: *data,
-				Op:    protocol.OpEvent,
-			})
+			writeEventFrame(value.Conn, data)
 			return true
 		})
 		return true
 	})
 }
 
-func (ctx *ChatContext) BroadcastEventInChannel(channelId string, data *protocol.Event) {
-	data.Timestamp = time.Now().Unix()
-	ctx.UserId2ConnInfo.Range(func(key string, value *utils.SyncMap[*WsSyncConn, *ConnInfo]) bool {
+func deliverEventInChannel(userConnMap *userConnInfoMap, channelId string, data *protocol.Event) {
+	if userConnMap == nil {
+		return
+	}
+	userConnMap.Range(func(key string, value *utils.SyncMap[*WsSyncConn, *ConnInfo]) bool {
 		value.Range(func(key *WsSyncConn, value *ConnInfo) bool {
 			if value.ChannelId == channelId {
-				_ = value.Conn.WriteJSON(struct {
-					protocol.Event
-					Op protocol.Opcode `json:"op"`
-				}{
-					// 协议规定: 事件中必须含有 channel，message，user
-					Event: *data,
-					Op:    protocol.OpEvent,
-				})
+				writeEventFrame(value.Conn, data)
 			}
 			return true
 		})
@@ -123,17 +297,16 @@ func (ctx *ChatContext) BroadcastEventInChannel(channelId string, data *protocol
 	})
 }
 
-func (ctx *ChatContext) BroadcastEventInChannelForBot(channelId string, data *protocol.Event) {
-	if ctx == nil || ctx.UserId2ConnInfo == nil || channelId == "" || data == nil {
+func deliverEventInChannelForBot(userConnMap *userConnInfoMap, channelId string, data *protocol.Event) {
+	if userConnMap == nil {
 		return
 	}
 	// 只向频道选中的 BOT 推送事件，避免多 BOT 实例导致数据不同步
-	data.Timestamp = time.Now().Unix()
 	botID, err := service.SelectedBotIdByChannelId(channelId)
 	if err != nil {
 		return
 	}
-	if x, ok := ctx.UserId2ConnInfo.Load(botID); ok {
+	if x, ok := userConnMap.Load(botID); ok {
 		var active *ConnInfo
 		var activeAt int64 = -1
 		x.Range(func(_ *WsSyncConn, value *ConnInfo) bool {
@@ -166,37 +339,26 @@ func (ctx *ChatContext) BroadcastEventInChannelForBot(channelId string, data *pr
 					})
 				}
 			}
-			_ = active.Conn.WriteJSON(struct {
-				protocol.Event
-				Op protocol.Opcode `json:"op"`
-			}{
-				// 协议规定: 事件中必须含有 channel，message，user
-				Event: *data,
-				Op:    protocol.OpEvent,
-			})
+			writeEventFrame(active.Conn, data)
 		}
 	}
 }
 
-func (ctx *ChatContext) BroadcastEventInChannelExcept(channelId string, ignoredUserIds []string, data *protocol.Event) {
+func deliverEventInChannelExcept(userConnMap *userConnInfoMap, channelId string, ignoredUserIds []string, data *protocol.Event) {
+	if userConnMap == nil {
+		return
+	}
 	ignoredMap := make(map[string]struct{}, len(ignoredUserIds))
 	for _, id := range ignoredUserIds {
 		ignoredMap[id] = struct{}{}
 	}
-	data.Timestamp = time.Now().Unix()
-	ctx.UserId2ConnInfo.Range(func(userId string, value *utils.SyncMap[*WsSyncConn, *ConnInfo]) bool {
+	userConnMap.Range(func(userId string, value *utils.SyncMap[*WsSyncConn, *ConnInfo]) bool {
 		if _, ignored := ignoredMap[userId]; ignored {
 			return true
 		}
 		value.Range(func(conn *WsSyncConn, info *ConnInfo) bool {
 			if info.ChannelId == channelId {
-				_ = info.Conn.WriteJSON(struct {
-					protocol.Event
-					Op protocol.Opcode `json:"op"`
-				}{
-					Event: *data,
-					Op:    protocol.OpEvent,
-				})
+				writeEventFrame(info.Conn, data)
 			}
 			return true
 		})
@@ -204,28 +366,21 @@ func (ctx *ChatContext) BroadcastEventInChannelExcept(channelId string, ignoredU
 	})
 }
 
-func (ctx *ChatContext) BroadcastEventInChannelToUsers(channelId string, userIds []string, data *protocol.Event) {
-	if len(userIds) == 0 {
+func deliverEventInChannelToUsers(userConnMap *userConnInfoMap, channelId string, userIds []string, data *protocol.Event) {
+	if userConnMap == nil {
 		return
 	}
 	targets := make(map[string]struct{}, len(userIds))
 	for _, id := range userIds {
 		targets[id] = struct{}{}
 	}
-	data.Timestamp = time.Now().Unix()
-	ctx.UserId2ConnInfo.Range(func(userId string, value *utils.SyncMap[*WsSyncConn, *ConnInfo]) bool {
+	userConnMap.Range(func(userId string, value *utils.SyncMap[*WsSyncConn, *ConnInfo]) bool {
 		if _, ok := targets[userId]; !ok {
 			return true
 		}
 		value.Range(func(conn *WsSyncConn, info *ConnInfo) bool {
 			if info.ChannelId == channelId {
-				_ = info.Conn.WriteJSON(struct {
-					protocol.Event
-					Op protocol.Opcode `json:"op"`
-				}{
-					Event: *data,
-					Op:    protocol.OpEvent,
-				})
+				writeEventFrame(info.Conn, data)
 			}
 			return true
 		})
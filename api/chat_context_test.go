@@ -0,0 +1,45 @@
+package api
+
+import (
+	"testing"
+
+	"sealchat/model"
+)
+
+func TestMaskWhispersForPreview(t *testing.T) {
+	whisper := func(id, content string) *model.MessageModel {
+		return &model.MessageModel{
+			StringPKBaseModel: model.StringPKBaseModel{ID: id},
+			UserID:            "sender",
+			WhisperTo:         "target",
+			IsWhisper:         true,
+			Content:           content,
+			WidgetData:        `{"dice":"D100=42"}`,
+			WhisperTargets:    []*model.UserModel{{StringPKBaseModel: model.StringPKBaseModel{ID: "target"}}},
+		}
+	}
+	quote := whisper("q1", "引用的秘密")
+	quote.EnsureWhisperMeta()
+	hidden := whisper("m1", "秘密")
+	hidden.Quote = quote
+	visible := whisper("m2", "给预览者的")
+
+	items := []*model.MessageModel{hidden, visible}
+	maskWhispersForPreview("viewer", items, map[string][]string{"m2": {"target", "viewer"}})
+
+	if hidden.Content != "" || hidden.WidgetData != "" {
+		t.Fatalf("preview leaked whisper: content=%q widget=%q", hidden.Content, hidden.WidgetData)
+	}
+	if quote.Content != "" || quote.WidgetData != "" {
+		t.Fatalf("preview leaked quoted whisper: content=%q widget=%q", quote.Content, quote.WidgetData)
+	}
+	if quote.WhisperTarget != nil || len(quote.WhisperTargets) != 0 {
+		t.Fatalf("preview leaked quoted whisper targets")
+	}
+	if quote.WhisperMeta == nil || len(quote.WhisperMeta.TargetUserIds) != 0 {
+		t.Fatalf("quoted whisper meta not rebuilt: %+v", quote.WhisperMeta)
+	}
+	if visible.Content != "给预览者的" || visible.WidgetData == "" {
+		t.Fatalf("whisper addressed to the preview actor was masked")
+	}
+}
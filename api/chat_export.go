@@ -34,6 +34,7 @@ type chatExportRequest struct {
 	SliceLimit         int            `json:"slice_limit"`
 	MaxConcurrency     int            `json:"max_concurrency"`
 	TextColorizeBBCode *bool          `json:"text_bbcode_colorize"`
+	IncludeEditHistory *bool          `json:"include_edit_history"`
 }
 
 type chatExportResponse struct {
@@ -154,6 +155,12 @@ func execChatExportCreate(userID string, req *chatExportRequest) (*chatExportRes
 		textColorizeBBCode = *req.TextColorizeBBCode
 	}
 
+	// 编辑历史只写入 JSON 导出
+	includeEditHistory := false
+	if req.IncludeEditHistory != nil && strings.EqualFold(format, "json") {
+		includeEditHistory = *req.IncludeEditHistory
+	}
+
 	displaySettings := normalizeDisplaySettings(req.DisplaySettings)
 	sliceLimit := service.NormalizeExportSliceLimit(req.SliceLimit)
 	maxConcurrency := service.NormalizeExportConcurrency(req.MaxConcurrency)
@@ -168,6 +175,7 @@ func execChatExportCreate(userID string, req *chatExportRequest) (*chatExportRes
 		WithoutTimestamp:   withoutTimestamp,
 		MergeMessages:      mergeMessages,
 		TextColorizeBBCode: textColorizeBBCode,
+		IncludeEditHistory: includeEditHistory,
 		StartTime:          start,
 		EndTime:            end,
 		DisplaySettings:    displaySettings,
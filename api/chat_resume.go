@@ -0,0 +1,113 @@
+package api
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"sealchat/model"
+	"sealchat/protocol"
+	"sealchat/service"
+)
+
+// 单次恢复最多处理的频道数，避免客户端带上过多频道拖慢握手
+const maxResumeChannels = 50
+
+// parseResumeSeqs 解析 Identify 中的 resume 字段：{"频道ID": 最后收到的序号}
+func parseResumeSeqs(body any) map[string]int64 {
+	m, ok := body.(map[string]any)
+	if !ok {
+		return nil
+	}
+	raw, ok := m["resume"].(map[string]any)
+	if !ok || len(raw) == 0 {
+		return nil
+	}
+	result := make(map[string]int64, len(raw))
+	for channelID, value := range raw {
+		channelID = strings.TrimSpace(channelID)
+		if channelID == "" {
+			continue
+		}
+		var seq int64
+		switch v := value.(type) {
+		case float64:
+			seq = int64(v)
+		case string:
+			parsed, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			if err != nil {
+				continue
+			}
+			seq = parsed
+		default:
+			continue
+		}
+		if seq < 0 {
+			continue
+		}
+		result[channelID] = seq
+		if len(result) >= maxResumeChannels {
+			break
+		}
+	}
+	return result
+}
+
+func canResumeChannel(user *model.UserModel, info *ConnInfo, channelID string) bool {
+	if info != nil && info.IsGuest {
+		_, err := service.CanGuestAccessChannel(channelID, info.RemoteIP)
+		return err == nil
+	}
+	ip := ""
+	if info != nil {
+		ip = info.RemoteIP
+	}
+	if service.ChannelBanCheck(channelID, user.ID, ip) != nil {
+		return false
+	}
+	return service.CanReadChannelByUserId(user.ID, channelID)
+}
+
+// resumeMissedEvents 在 Ready 之后按频道补发断线期间错过的事件，
+// 无法补全的频道下发 OpResyncRequired，最后以 OpResumed 告知各频道最新序号
+func resumeMissedEvents(c *WsSyncConn, user *model.UserModel, info *ConnInfo, body any) {
+	seqs := parseResumeSeqs(body)
+	if len(seqs) == 0 || user == nil {
+		return
+	}
+	payload := protocol.ResumedPayload{
+		Latest:   map[string]int64{},
+		Replayed: map[string]int{},
+	}
+	for channelID, afterSeq := range seqs {
+		if !canResumeChannel(user, info, channelID) {
+			continue
+		}
+		result, err := service.EventReplayCollect(channelID, afterSeq, user.ID)
+		if err != nil {
+			log.Printf("[WS] 频道 %s 事件补发失败: %v", channelID, err)
+			result = &service.EventReplayResult{Latest: service.EventReplayLatestSeq(channelID), Resync: true, Reason: "读取事件失败"}
+		}
+		payload.Latest[channelID] = result.Latest
+		if result.Resync {
+			payload.Resync = append(payload.Resync, channelID)
+			_ = c.WriteJSON(protocol.GatewayPayloadStructure{
+				Op: protocol.OpResyncRequired,
+				Body: protocol.ResyncRequiredPayload{
+					ChannelID: channelID,
+					LatestSeq: result.Latest,
+					Reason:    result.Reason,
+				},
+			})
+			continue
+		}
+		for _, frame := range result.Frames {
+			_ = c.WriteJSON(frame)
+		}
+		payload.Replayed[channelID] = len(result.Frames)
+	}
+	_ = c.WriteJSON(protocol.GatewayPayloadStructure{
+		Op:   protocol.OpResumed,
+		Body: payload,
+	})
+}
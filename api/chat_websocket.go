@@ -27,12 +27,19 @@ type ApiMsgPayload struct {
 type WsSyncConn struct {
 	*websocket.Conn
 	Mux sync.RWMutex
+	// transport 下行帧编码与压缩，OpIdentify 时协商，见 ws_transport.go
+	transport wsTransportInfo
+	// deflate 握手时是否协商了 permessage-deflate
+	deflate bool
+	// adapter 不为空表示标准协议（Satori、OneBot）接入的 BOT 连接
+	adapter botProtocolAdapter
+	// botID 不为空表示 BOT 连接，事件投递时按令牌的订阅与限制过滤
+	botID string
 }
 
-func (c *WsSyncConn) WriteJSON(v interface{}) error {
-	c.Mux.Lock()
-	defer c.Mux.Unlock()
-	return c.Conn.WriteJSON(v)
+// botProtocolAdapter 标准协议连接只接收转换后的事件，站内私有信令一律不下发
+type botProtocolAdapter interface {
+	writeEvent(conn *WsSyncConn, data *protocol.Event)
 }
 
 type ConnInfo struct {
@@ -59,6 +66,10 @@ type ConnInfo struct {
 	BotCharacterSupport   BotCharacterSupportState
 	BotCharacterProbeOn   bool
 	BotCharacterProbeFail int
+	// RemoteBotID 不为空表示 BOT 连接在其他实例上，仅作人物卡请求转发的占位
+	RemoteBotID string
+	// RemoteIP 建立连接时的客户端 IP，用于世界 IP 封禁匹配
+	RemoteIP string
 }
 
 type BotHiddenDicePending struct {
@@ -100,6 +111,7 @@ func websocketWorks(app *fiber.App) {
 	userId2ConnInfo := &utils.SyncMap[string, *utils.SyncMap[*WsSyncConn, *ConnInfo]]{}
 	channelUsersMapGlobal = channelUsersMap
 	userId2ConnInfoGlobal = userId2ConnInfo
+	subscribeEventBus()
 
 	guestAllowedAPIs := map[string]struct{}{
 		"channel.list":               {},
@@ -156,6 +168,7 @@ func websocketWorks(app *fiber.App) {
 					TypingState:   protocol.TypingStateSilent,
 					TypingIcMode:  "ic",
 					Focused:       true,
+					RemoteIP:      getWsClientIP(c),
 				}
 				m.Store(c, curConnInfo)
 				curUser = user
@@ -166,8 +179,9 @@ func websocketWorks(app *fiber.App) {
 				_ = c.WriteJSON(protocol.GatewayPayloadStructure{
 					Op: protocol.OpReady,
 					Body: map[string]any{
-						"user":  curUser,
-						"guest": true,
+						"user":      curUser,
+						"guest":     true,
+						"transport": c.transport,
 					},
 				})
 				return
@@ -183,6 +197,7 @@ func websocketWorks(app *fiber.App) {
 				m, _ := userId2ConnInfo.LoadOrStore(user.ID, &utils.SyncMap[*WsSyncConn, *ConnInfo]{})
 
 				if user.IsBot {
+					c.botID = user.ID
 					closedCount := 0
 					m.Range(func(conn *WsSyncConn, _ *ConnInfo) bool {
 						conn.Close()
@@ -234,6 +249,7 @@ func websocketWorks(app *fiber.App) {
 					TypingState:   protocol.TypingStateSilent,
 					TypingIcMode:  "ic",
 					Focused:       true,
+					RemoteIP:      getWsClientIP(c),
 				}
 				m.Store(c, curConnInfo)
 
@@ -245,7 +261,8 @@ func websocketWorks(app *fiber.App) {
 				_ = c.WriteJSON(protocol.GatewayPayloadStructure{
 					Op: protocol.OpReady,
 					Body: map[string]any{
-						"user": curUser,
+						"user":      curUser,
+						"transport": c.transport,
 					},
 				})
 				if user.IsBot {
@@ -360,7 +377,7 @@ func websocketWorks(app *fiber.App) {
 			curUser     *model.UserModel
 			curConnInfo *ConnInfo
 		)
-		c := &WsSyncConn{rawConn, sync.RWMutex{}}
+		c := newWsSyncConn(rawConn)
 
 		// 设置pong处理器，收到pong时更新连接活跃状态
 		rawConn.SetPongHandler(func(appData string) error {
@@ -401,6 +418,12 @@ func websocketWorks(app *fiber.App) {
 				// 解析错误或超时
 				break
 			}
+			if decoded, ok := decodeWsFrame(mt, msg); ok {
+				msg = decoded
+			} else {
+				log.Println("[WS] 无法解析的二进制帧")
+				continue
+			}
 			if curConnInfo != nil {
 				curConnInfo.LastAliveTime = time.Now().UnixMilli()
 			}
@@ -413,11 +436,13 @@ func websocketWorks(app *fiber.App) {
 				switch gatewayMsg.Op {
 				case protocol.OpIdentify:
 					fmt.Println("新客户端接入")
+					c.negotiateTransport(gatewayMsg.Body)
 					curUser, curConnInfo = clientEnter(c, gatewayMsg.Body)
 					if curUser == nil {
 						_ = c.Close()
 						return
 					}
+					resumeMissedEvents(c, curUser, curConnInfo, gatewayMsg.Body)
 					solved = true
 				case protocol.OpPing:
 					if curUser == nil {
@@ -503,9 +528,7 @@ func websocketWorks(app *fiber.App) {
 				apiMsg := ApiMsgPayload{}
 				err := json.Unmarshal(msg, &apiMsg)
 
-				var members []*model.MemberModel
-				db := model.GetDB()
-				db.Where("user_id = ?", curUser.ID).Find(&members)
+				members, _ := model.MemberListByUserIDCached(curUser.ID)
 
 				ctx := &ChatContext{
 					Conn:            c,
@@ -530,6 +553,15 @@ func websocketWorks(app *fiber.App) {
 					if solved {
 						continue
 					}
+					if curUser.IsBot && apiMsg.Api != "" {
+						if reason := botAPICheck(curUser.ID, apiMsg.Api, apiMsg.Data); reason != "" {
+							_ = c.WriteJSON(&struct {
+								Echo string `json:"echo"`
+								Err  string `json:"err"`
+							}{Echo: ctx.Echo, Err: reason})
+							continue
+						}
+					}
 
 					// Handle BOT response (api field is empty)
 					if apiMsg.Api == "" && apiMsg.Echo != "" {
@@ -588,6 +620,9 @@ func websocketWorks(app *fiber.App) {
 					case "channel.feature.update":
 						apiWrap(ctx, msg, apiChannelFeatureUpdate)
 						solved = true
+					case "channel.gm_roles.set":
+						apiWrap(ctx, msg, apiChannelGMRolesSet)
+						solved = true
 						// case "guild.list":
 					//	 apiChannelList(c, msg, apiMsg.Echo)
 					//	 solved = true
@@ -653,9 +688,27 @@ func websocketWorks(app *fiber.App) {
 					case "message.pin.list":
 						apiWrap(ctx, msg, apiMessagePinList)
 						solved = true
+					case "message.gm_roll.reveal":
+						apiWrap(ctx, msg, apiMessageGMRollReveal)
+						solved = true
 					case "message.edit.history":
 						apiWrap(ctx, msg, apiMessageEditHistory)
 						solved = true
+					case "message.edit.diff":
+						apiWrap(ctx, msg, apiMessageEditDiff)
+						solved = true
+					case "message.edit.rollback":
+						apiWrap(ctx, msg, apiMessageEditRollback)
+						solved = true
+					case "message.bulk.preview":
+						apiWrap(ctx, msg, apiMessageBulkPreview)
+						solved = true
+					case "message.bulk.start":
+						apiWrap(ctx, msg, apiMessageBulkStart)
+						solved = true
+					case "message.bulk.status":
+						apiWrap(ctx, msg, apiMessageBulkStatus)
+						solved = true
 					case "message.typing":
 						apiWrap(ctx, msg, apiMessageTyping)
 						solved = true
@@ -677,6 +730,18 @@ func websocketWorks(app *fiber.App) {
 					case "bot.command.register":
 						apiBotCommandRegister(ctx, msg)
 						solved = true
+					case "bot.command.reply":
+						apiWrap(ctx, msg, apiBotCommandReply)
+						solved = true
+					case "bot.command.autocomplete":
+						apiWrap(ctx, msg, apiBotCommandAutocomplete)
+						solved = true
+					case "channel.command.list":
+						apiWrap(ctx, msg, apiChannelCommandList)
+						solved = true
+					case "channel.command.set":
+						apiWrap(ctx, msg, apiChannelCommandSet)
+						solved = true
 					case "bot.channel_member.set_name":
 						apiBotChannelMemberSetName(ctx, msg)
 						solved = true
@@ -803,5 +868,5 @@ func websocketWorks(app *fiber.App) {
 			}
 			return true
 		})
-	}))
+	}, newWsUpgradeConfig()))
 }
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 客户端 IP 用于世界 IP 封禁、验证码与限流。X-Real-IP/X-Forwarded-For 可被客户端随意伪造，
+// 只有直连地址属于配置的可信代理时才采信。
+
+// isTrustedProxy 判断 ip 是否在 trustedProxies 配置中，支持单个 IP 与 CIDR
+func isTrustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	if appConfig == nil {
+		return false
+	}
+	for _, item := range appConfig.TrustedProxies {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if strings.Contains(item, "/") {
+			if _, network, err := net.ParseCIDR(item); err == nil && network.Contains(parsed) {
+				return true
+			}
+			continue
+		}
+		if proxy := net.ParseIP(item); proxy != nil && proxy.Equal(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveClientIP remote 为直连地址，header 读取请求头
+func resolveClientIP(remote string, header func(key string) string) string {
+	remote = strings.TrimSpace(remote)
+	if !isTrustedProxy(remote) {
+		return remote
+	}
+	if ip := strings.TrimSpace(header("X-Real-IP")); net.ParseIP(ip) != nil {
+		return ip
+	}
+	// 从右往左取第一个不是可信代理的地址，更左侧的内容可能由客户端伪造
+	if forwarded := header("X-Forwarded-For"); forwarded != "" {
+		parts := strings.Split(forwarded, ",")
+		for i := len(parts) - 1; i >= 0; i-- {
+			ip := strings.TrimSpace(parts[i])
+			if net.ParseIP(ip) == nil {
+				break
+			}
+			if !isTrustedProxy(ip) {
+				return ip
+			}
+		}
+	}
+	return remote
+}
+
+func getClientIP(c *fiber.Ctx) string {
+	return resolveClientIP(c.Context().RemoteIP().String(), func(key string) string {
+		return c.Get(key)
+	})
+}
+
+// getWsClientIP 与 getClientIP 一致，仅信任可信代理传入的头
+func getWsClientIP(c *WsSyncConn) string {
+	if c == nil || c.Conn == nil {
+		return ""
+	}
+	remote := ""
+	if addr := c.RemoteAddr(); addr != nil {
+		remote = addr.String()
+		if host, _, err := net.SplitHostPort(remote); err == nil {
+			remote = host
+		}
+	}
+	return resolveClientIP(remote, func(key string) string {
+		return c.Headers(key)
+	})
+}
@@ -0,0 +1,31 @@
+package api
+
+import "testing"
+
+func TestResolveClientIP(t *testing.T) {
+	initTestDB(t)
+	prev := appConfig.TrustedProxies
+	appConfig.TrustedProxies = []string{"127.0.0.1", "10.0.0.0/8"}
+	t.Cleanup(func() { appConfig.TrustedProxies = prev })
+
+	headers := map[string]string{}
+	get := func(key string) string { return headers[key] }
+
+	headers["X-Real-IP"] = "1.2.3.4"
+	if ip := resolveClientIP("8.8.8.8", get); ip != "8.8.8.8" {
+		t.Fatalf("headers from an untrusted peer must be ignored, got %s", ip)
+	}
+	if ip := resolveClientIP("127.0.0.1", get); ip != "1.2.3.4" {
+		t.Fatalf("X-Real-IP from a trusted proxy should be used, got %s", ip)
+	}
+
+	delete(headers, "X-Real-IP")
+	headers["X-Forwarded-For"] = "6.6.6.6, 5.5.5.5, 10.1.2.3"
+	if ip := resolveClientIP("10.0.0.1", get); ip != "5.5.5.5" {
+		t.Fatalf("the rightmost untrusted hop should be used, got %s", ip)
+	}
+	headers["X-Forwarded-For"] = "not-an-ip"
+	if ip := resolveClientIP("127.0.0.1", get); ip != "127.0.0.1" {
+		t.Fatalf("invalid forwarded value should fall back to the peer, got %s", ip)
+	}
+}
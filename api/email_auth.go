@@ -34,20 +34,6 @@ func isValidEmail(email string) bool {
 	return emailRegex.MatchString(email)
 }
 
-func getClientIP(c *fiber.Ctx) string {
-	ip := c.Get("X-Real-IP")
-	if ip == "" {
-		ip = c.Get("X-Forwarded-For")
-		if ip != "" {
-			ip = strings.Split(ip, ",")[0]
-		}
-	}
-	if ip == "" {
-		ip = c.IP()
-	}
-	return strings.TrimSpace(ip)
-}
-
 func EmailAuthSignupCodeSend(c *fiber.Ctx) error {
 	var req struct {
 		Email          string `json:"email"`
@@ -0,0 +1,410 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"sealchat/model"
+	"sealchat/protocol"
+	"sealchat/service"
+	"sealchat/service/eventbus"
+	"sealchat/utils"
+)
+
+// 广播消息的投递方式，与 ChatContext 上的 Broadcast* 一一对应
+const (
+	busBroadcastUserJSON  = "user_json"
+	busBroadcastJSON      = "json"
+	busBroadcastAll       = "all"
+	busBroadcastChannel   = "channel"
+	busBroadcastBot       = "bot"
+	busBroadcastExcept    = "except"
+	busBroadcastUsers     = "users"
+	busBroadcastUserEvent = "user_event"
+	busBroadcastWorld     = "world"
+)
+
+type busBroadcast struct {
+	Kind      string          `json:"kind"`
+	ChannelID string          `json:"channelId,omitempty"`
+	WorldID   string          `json:"worldId,omitempty"`
+	UserIDs   []string        `json:"userIds,omitempty"`
+	Event     *protocol.Event `json:"event,omitempty"`
+	Raw       json.RawMessage `json:"raw,omitempty"`
+}
+
+type busPresence struct {
+	ChannelID string                      `json:"channelId"`
+	Presence  []*protocol.ChannelPresence `json:"presence"`
+}
+
+const (
+	busCharacterRequest  = "request"
+	busCharacterResponse = "response"
+	// busCharacterProbe 询问哪个实例持有该 BOT 的连接及其人物卡能力，持有者才会回复
+	busCharacterProbe = "probe"
+)
+
+type busCharacter struct {
+	Kind  string          `json:"kind"`
+	BotID string          `json:"botId,omitempty"`
+	API   string          `json:"api,omitempty"`
+	Echo  string          `json:"echo"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+var subscribeEventBusOnce sync.Once
+
+func subscribeEventBus() {
+	subscribeEventBusOnce.Do(func() {
+		bus := eventbus.Default()
+		bus.Subscribe(eventbus.TopicBroadcast, handleBusBroadcast)
+		bus.Subscribe(eventbus.TopicPresence, handleBusPresence)
+		bus.Subscribe(eventbus.TopicCharacter, handleBusCharacter)
+		bus.Subscribe(eventbus.TopicCache, handleBusCacheInvalidate)
+		model.PermCacheSetSync(func(userIDs []string, all bool) {
+			publishCacheInvalidate(&busCacheInvalidate{Kind: busCachePerm, UserIDs: userIDs, All: all})
+		})
+		service.WorldSanctionCacheSetSync(func(worldIDs []string) {
+			publishCacheInvalidate(&busCacheInvalidate{Kind: busCacheWorldSanction, WorldIDs: worldIDs})
+		})
+	})
+}
+
+const (
+	busCachePerm          = "perm"
+	busCacheBotToken      = "bot_token"
+	busCacheWorldSanction = "world_sanction"
+)
+
+type busCacheInvalidate struct {
+	Kind    string   `json:"kind"`
+	UserIDs []string `json:"userIds,omitempty"`
+	All     bool     `json:"all,omitempty"`
+	// WorldIDs 按世界失效的缓存使用，例如世界封禁
+	WorldIDs []string `json:"worldIds,omitempty"`
+}
+
+// publishCacheInvalidate 本实例已失效的缓存同步给其他实例
+func publishCacheInvalidate(item *busCacheInvalidate) {
+	bus := eventbus.Default()
+	if !bus.Distributed() {
+		return
+	}
+	if err := bus.Publish(eventbus.TopicCache, "", item); err != nil {
+		log.Printf("[eventbus] 发布缓存失效失败: %v", err)
+	}
+}
+
+func handleBusCacheInvalidate(msg *eventbus.Message) {
+	var item busCacheInvalidate
+	if err := json.Unmarshal(msg.Data, &item); err != nil {
+		return
+	}
+	switch item.Kind {
+	case busCachePerm:
+		model.PermCacheApplyRemote(item.UserIDs, item.All)
+	case busCacheBotToken:
+		for _, botID := range item.UserIDs {
+			botTokenCache.Delete(botID)
+		}
+	case busCacheWorldSanction:
+		service.WorldSanctionCacheApplyRemote(item.WorldIDs)
+	}
+}
+
+// publishBusBroadcast 把已在本实例投递过的推送同步给其他实例；raw 为非事件类的原始 JSON
+func publishBusBroadcast(item *busBroadcast, raw any) {
+	bus := eventbus.Default()
+	if !bus.Distributed() {
+		return
+	}
+	if raw != nil {
+		data, err := json.Marshal(raw)
+		if err != nil {
+			return
+		}
+		item.Raw = data
+	}
+	if err := bus.Publish(eventbus.TopicBroadcast, "", item); err != nil {
+		log.Printf("[eventbus] 发布广播失败: %v", err)
+	}
+}
+
+func handleBusBroadcast(msg *eventbus.Message) {
+	var item busBroadcast
+	if err := json.Unmarshal(msg.Data, &item); err != nil {
+		return
+	}
+	userConnMap := getUserConnInfoMap()
+	switch item.Kind {
+	case busBroadcastUserJSON:
+		for _, userID := range item.UserIDs {
+			deliverJSONToUser(userConnMap, userID, item.Raw)
+		}
+		return
+	case busBroadcastJSON:
+		deliverJSON(userConnMap, item.Raw, item.UserIDs)
+		return
+	}
+	if item.Event == nil {
+		return
+	}
+	switch item.Kind {
+	case busBroadcastAll:
+		if channelID := eventChannelID(item.Event); channelID != "" {
+			service.EventReplayIngest(channelID, item.Event, nil, nil)
+		}
+		deliverEvent(userConnMap, item.Event)
+	case busBroadcastChannel:
+		service.EventReplayIngest(item.ChannelID, item.Event, nil, nil)
+		deliverEventInChannel(userConnMap, item.ChannelID, item.Event)
+	case busBroadcastExcept:
+		service.EventReplayIngest(item.ChannelID, item.Event, nil, item.UserIDs)
+		deliverEventInChannelExcept(userConnMap, item.ChannelID, item.UserIDs, item.Event)
+	case busBroadcastUsers:
+		service.EventReplayIngest(item.ChannelID, item.Event, item.UserIDs, nil)
+		deliverEventInChannelToUsers(userConnMap, item.ChannelID, item.UserIDs, item.Event)
+	case busBroadcastBot:
+		deliverEventInChannelForBot(userConnMap, item.ChannelID, item.Event)
+	case busBroadcastUserEvent:
+		if channelID := eventChannelID(item.Event); channelID != "" {
+			service.EventReplayIngest(channelID, item.Event, item.UserIDs, nil)
+		}
+		deliverEventToUsers(userConnMap, item.UserIDs, item.Event)
+	case busBroadcastWorld:
+		deliverEventToWorld(userConnMap, item.WorldID, item.Event)
+	}
+}
+
+// 其他实例上报的频道在线快照，超过有效期未刷新的视为该实例已下线
+const remotePresenceTTL = 3 * time.Minute
+
+type remotePresenceEntry struct {
+	presence  []*protocol.ChannelPresence
+	updatedAt time.Time
+}
+
+// remotePresence key 为 实例ID + "|" + 频道ID
+var remotePresence utils.SyncMap[string, *remotePresenceEntry]
+
+func publishPresenceSnapshot(channelID string, local []*protocol.ChannelPresence) {
+	bus := eventbus.Default()
+	if !bus.Distributed() {
+		return
+	}
+	if err := bus.Publish(eventbus.TopicPresence, "", &busPresence{ChannelID: channelID, Presence: local}); err != nil {
+		log.Printf("[eventbus] 发布在线状态失败: %v", err)
+	}
+}
+
+func handleBusPresence(msg *eventbus.Message) {
+	var item busPresence
+	if err := json.Unmarshal(msg.Data, &item); err != nil || item.ChannelID == "" {
+		return
+	}
+	key := msg.Origin + "|" + item.ChannelID
+	if len(item.Presence) == 0 {
+		remotePresence.Delete(key)
+	} else {
+		remotePresence.Store(key, &remotePresenceEntry{presence: item.Presence, updatedAt: time.Now()})
+	}
+	local := buildChannelPresenceSnapshot(item.ChannelID, getChannelUsersMap(), getUserConnInfoMap())
+	deliverEventInChannel(getUserConnInfoMap(), item.ChannelID, &protocol.Event{
+		Type:      protocol.EventChannelPresenceUpdated,
+		Timestamp: time.Now().UnixMilli(),
+		Channel:   &protocol.Channel{ID: item.ChannelID},
+		Presence:  mergeRemotePresence(item.ChannelID, local),
+	})
+}
+
+// mergeRemotePresence 合并其他实例的在线用户，同一用户以本实例或最近活跃的记录为准
+func mergeRemotePresence(channelID string, local []*protocol.ChannelPresence) []*protocol.ChannelPresence {
+	if !eventbus.Default().Distributed() {
+		return local
+	}
+	byUser := map[string]*protocol.ChannelPresence{}
+	result := append([]*protocol.ChannelPresence{}, local...)
+	for _, item := range local {
+		if item.User != nil {
+			byUser[item.User.ID] = item
+		}
+	}
+	now := time.Now()
+	suffix := "|" + channelID
+	remotePresence.Range(func(key string, entry *remotePresenceEntry) bool {
+		if len(key) < len(suffix) || key[len(key)-len(suffix):] != suffix {
+			return true
+		}
+		if now.Sub(entry.updatedAt) > remotePresenceTTL {
+			remotePresence.Delete(key)
+			return true
+		}
+		for _, item := range entry.presence {
+			if item == nil || item.User == nil {
+				continue
+			}
+			if existing, ok := byUser[item.User.ID]; ok {
+				if existing.LastSeen >= item.LastSeen {
+					continue
+				}
+				*existing = *item
+				continue
+			}
+			copied := *item
+			byUser[item.User.ID] = &copied
+			result = append(result, &copied)
+		}
+		return true
+	})
+	sortChannelPresence(result)
+	return result
+}
+
+func sortChannelPresence(results []*protocol.ChannelPresence) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Focused != results[j].Focused {
+			return results[i].Focused
+		}
+		return results[i].Latency < results[j].Latency
+	})
+}
+
+// remoteCharacterEchoes 记录由本实例代为转发给 BOT 的请求，echo -> 发起实例
+var remoteCharacterEchoes utils.SyncMap[string, string]
+
+func publishCharacterRequest(botID, api, echo string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return eventbus.Default().Publish(eventbus.TopicCharacter, "", &busCharacter{
+		Kind:  busCharacterRequest,
+		BotID: botID,
+		API:   api,
+		Echo:  echo,
+		Data:  raw,
+	})
+}
+
+const (
+	remoteBotProbeTimeout  = time.Second
+	remoteBotProbeCacheTTL = 15 * time.Second
+)
+
+type remoteBotProbeEntry struct {
+	found     bool
+	support   BotCharacterSupportState
+	checkedAt time.Time
+}
+
+// remoteBotProbes 缓存其他实例上 BOT 连接的探测结果，避免每次请求都等待总线往返
+var remoteBotProbes utils.SyncMap[string, *remoteBotProbeEntry]
+
+// probeRemoteBot 经事件总线确认 BOT 是否连接在其他实例上；无实例回复视为不在线
+func probeRemoteBot(botID string) (bool, BotCharacterSupportState) {
+	if botID == "" || !eventbus.Default().Distributed() {
+		return false, BotCharacterSupportUnknown
+	}
+	if entry, ok := remoteBotProbes.Load(botID); ok && time.Since(entry.checkedAt) < remoteBotProbeCacheTTL {
+		return entry.found, entry.support
+	}
+	echo := "bot-remote-probe-" + utils.NewID()
+	respChan := make(chan json.RawMessage, 1)
+	characterPendingRequests.Store(echo, &CharacterPendingRequest{
+		Echo:      echo,
+		API:       busCharacterProbe,
+		CreatedAt: time.Now(),
+		Response:  respChan,
+	})
+	defer characterPendingRequests.Delete(echo)
+	if err := eventbus.Default().Publish(eventbus.TopicCharacter, "", &busCharacter{
+		Kind:  busCharacterProbe,
+		BotID: botID,
+		Echo:  echo,
+	}); err != nil {
+		log.Printf("[eventbus] 探测 BOT 连接失败: %v", err)
+		return false, BotCharacterSupportUnknown
+	}
+	entry := &remoteBotProbeEntry{support: BotCharacterSupportUnknown}
+	select {
+	case resp := <-respChan:
+		var reply struct {
+			Support BotCharacterSupportState `json:"support"`
+		}
+		if err := json.Unmarshal(resp, &reply); err == nil {
+			entry.found = true
+			entry.support = reply.Support
+		}
+	case <-time.After(remoteBotProbeTimeout):
+	}
+	entry.checkedAt = time.Now()
+	remoteBotProbes.Store(botID, entry)
+	return entry.found, entry.support
+}
+
+// relayCharacterResponse BOT 的响应属于其他实例发起的请求时，转发回发起实例
+func relayCharacterResponse(echo string, data json.RawMessage) bool {
+	origin, ok := remoteCharacterEchoes.LoadAndDelete(echo)
+	if !ok {
+		return false
+	}
+	if err := eventbus.Default().Publish(eventbus.TopicCharacter, origin, &busCharacter{
+		Kind: busCharacterResponse,
+		Echo: echo,
+		Data: data,
+	}); err != nil {
+		log.Printf("[eventbus] 回传人物卡响应失败: %v", err)
+	}
+	return true
+}
+
+func handleBusCharacter(msg *eventbus.Message) {
+	var item busCharacter
+	if err := json.Unmarshal(msg.Data, &item); err != nil || item.Echo == "" {
+		return
+	}
+	switch item.Kind {
+	case busCharacterResponse:
+		HandleCharacterResponse(item.Echo, item.Data)
+	case busCharacterProbe:
+		conn, info := findLocalBotConnection(item.BotID)
+		if conn == nil {
+			return
+		}
+		startBotCharacterCapabilityProbe(info)
+		reply, _ := json.Marshal(map[string]any{"support": info.BotCharacterSupport})
+		_ = eventbus.Default().Publish(eventbus.TopicCharacter, msg.Origin, &busCharacter{
+			Kind: busCharacterResponse,
+			Echo: item.Echo,
+			Data: reply,
+		})
+	case busCharacterRequest:
+		conn, info := findLocalBotConnection(item.BotID)
+		if conn == nil {
+			return
+		}
+		startBotCharacterCapabilityProbe(info)
+		if info.BotCharacterSupport != BotCharacterSupportYes {
+			unsupported, _ := json.Marshal(map[string]any{"ok": false, "error": botCharacterUnsupportedText})
+			_ = eventbus.Default().Publish(eventbus.TopicCharacter, msg.Origin, &busCharacter{
+				Kind: busCharacterResponse,
+				Echo: item.Echo,
+				Data: unsupported,
+			})
+			return
+		}
+		remoteCharacterEchoes.Store(item.Echo, msg.Origin)
+		time.AfterFunc(2*characterRequestTimeout, func() {
+			remoteCharacterEchoes.Delete(item.Echo)
+		})
+		_ = conn.WriteJSON(map[string]any{
+			"api":  item.API,
+			"echo": item.Echo,
+			"data": item.Data,
+		})
+	}
+}
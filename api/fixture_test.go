@@ -0,0 +1,94 @@
+package api
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/utils"
+)
+
+var testDBOnce sync.Once
+
+// initTestDB 与 service 包的测试一致，所有用例共用一个内存库，数据用随机 ID 隔开
+func initTestDB(t testing.TB) {
+	t.Helper()
+	testDBOnce.Do(func() {
+		cfg := &utils.AppConfig{
+			DSN: ":memory:",
+			SQLite: utils.SQLiteConfig{
+				ReadConnections: 1,
+			},
+		}
+		model.DBInit(cfg)
+		pm.Init()
+		appConfig = cfg
+		channelUsersMapGlobal = &utils.SyncMap[string, *utils.SyncSet[string]]{}
+		userId2ConnInfoGlobal = &utils.SyncMap[string, *utils.SyncMap[*WsSyncConn, *ConnInfo]]{}
+	})
+}
+
+type botFixture struct {
+	worldID   string
+	channelID string
+	ownerID   string
+	bot       *model.UserModel
+	token     string
+}
+
+// seedBotFixture 创建世界、一个公开频道和已加入该频道的 BOT，BOT 拥有读取与发言权限
+func seedBotFixture(t *testing.T) *botFixture {
+	t.Helper()
+	initTestDB(t)
+	db := model.GetDB()
+	suffix := utils.NewIDWithLength(8)
+	f := &botFixture{
+		worldID:   "world" + suffix,
+		channelID: "chan" + suffix,
+		ownerID:   "owner" + suffix,
+		token:     utils.NewIDWithLength(32),
+	}
+	if err := db.Create(&model.WorldModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: f.worldID},
+		Name:              "Bot World",
+		Status:            "active",
+		OwnerID:           f.ownerID,
+	}).Error; err != nil {
+		t.Fatalf("create world failed: %v", err)
+	}
+	if err := db.Create(&model.ChannelModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: f.channelID},
+		WorldID:           f.worldID,
+		Name:              "大厅",
+		PermType:          "public",
+		Status:            model.ChannelStatusActive,
+	}).Error; err != nil {
+		t.Fatalf("create channel failed: %v", err)
+	}
+	f.bot = &model.UserModel{StringPKBaseModel: model.StringPKBaseModel{ID: "bot" + suffix}, Username: "bot" + suffix, Nickname: "骰子", IsBot: true}
+	if err := db.Create(f.bot).Error; err != nil {
+		t.Fatalf("create bot failed: %v", err)
+	}
+	if err := db.Create(&model.BotTokenModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: f.bot.ID},
+		Name:              "骰子",
+		Token:             f.token,
+		ExpiresAt:         time.Now().Add(time.Hour).UnixMilli(),
+	}).Error; err != nil {
+		t.Fatalf("create bot token failed: %v", err)
+	}
+	if err := db.Create(&model.WorldMemberModel{WorldID: f.worldID, UserID: f.bot.ID, Role: model.WorldRoleMember}).Error; err != nil {
+		t.Fatalf("create world member failed: %v", err)
+	}
+	roleID := "ch-" + f.channelID + "-bot"
+	if err := db.Create(&model.ChannelRoleModel{StringPKBaseModel: model.StringPKBaseModel{ID: roleID}, Name: "机器人", ChannelID: f.channelID}).Error; err != nil {
+		t.Fatalf("create role failed: %v", err)
+	}
+	pm.RolePermApply(roleID, []string{pm.PermFuncChannelRead.ID(), pm.PermFuncChannelTextSend.ID()})
+	if err := model.UserRoleMappingCreate(&model.UserRoleMappingModel{RoleType: "channel", UserID: f.bot.ID, RoleID: roleID}); err != nil {
+		t.Fatalf("create role mapping failed: %v", err)
+	}
+	return f
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/protocol"
+	"sealchat/service"
+)
+
+// gmRollMaskProtocol 返回打码后的消息副本，不修改原消息
+func gmRollMaskProtocol(msg *protocol.Message) *protocol.Message {
+	if msg == nil {
+		return nil
+	}
+	masked := *msg
+	name := ""
+	switch {
+	case msg.Identity != nil && msg.Identity.DisplayName != "":
+		name = msg.Identity.DisplayName
+	case msg.Member != nil && msg.Member.Nick != "":
+		name = msg.Member.Nick
+	case msg.User != nil:
+		name = msg.User.Nick
+	}
+	masked.Content = service.GMRollPlaceholder(name)
+	masked.WidgetData = ""
+	masked.GMRollMasked = true
+	if masked.Quote != nil && masked.Quote.IsGMRoll {
+		masked.Quote = gmRollMaskProtocol(masked.Quote)
+	}
+	return &masked
+}
+
+// broadcastGMRollEvent 发送者与 GM 收到完整事件，其他成员收到打码后的事件。
+// 频道选中的 BOT 需要处理暗骰指令，收到完整事件，由 MessageContext.IsGMRoll 标明
+func broadcastGMRollEvent(ctx *ChatContext, channelID, senderID string, ev *protocol.Event) {
+	channel, _ := model.ChannelGet(channelID)
+	viewers := lo.Uniq(append(service.GMRollViewerIDs(channel), senderID))
+	ctx.BroadcastEventInChannelToUsers(channelID, viewers, ev)
+
+	masked := *ev
+	masked.Message = gmRollMaskProtocol(ev.Message)
+	ctx.BroadcastEventInChannelExcept(channelID, viewers, &masked)
+	ctx.BroadcastEventInChannelForBot(channelID, ev)
+}
+
+// gmRollHiddenFrom 单条消息是否为用户看不到内容的 GM 暗骰
+func gmRollHiddenFrom(userID string, msg *model.MessageModel) bool {
+	if msg == nil || !msg.IsGMRoll || msg.UserID == userID {
+		return false
+	}
+	channel, _ := model.ChannelGet(msg.ChannelID)
+	return !service.GMRollIsViewer(userID, channel)
+}
+
+// maskGMRollsForUser 列表接口返回前为非 GM 打码；channel 为空时按需加载
+func maskGMRollsForUser(userID string, channel *model.ChannelModel, channelID string, items []*model.MessageModel) {
+	hasGMRoll := lo.ContainsBy(items, func(item *model.MessageModel) bool {
+		return item.IsGMRoll || (item.Quote != nil && item.Quote.IsGMRoll)
+	})
+	if !hasGMRoll {
+		return
+	}
+	if channel == nil {
+		channel, _ = model.ChannelGet(channelID)
+	}
+	service.GMRollMaskForViewer(userID, service.GMRollIsViewer(userID, channel), items)
+}
+
+// apiMessageGMRollReveal 揭示 GM 暗骰，转为所有人可见的普通消息
+func apiMessageGMRollReveal(ctx *ChatContext, data *struct {
+	ChannelID string `json:"channel_id"`
+	MessageID string `json:"message_id"`
+}) (any, error) {
+	channelID := strings.TrimSpace(data.ChannelID)
+	messageID := strings.TrimSpace(data.MessageID)
+	if channelID == "" || messageID == "" {
+		return nil, fmt.Errorf("channel_id 和 message_id 不能为空")
+	}
+	channel, _ := model.ChannelGet(channelID)
+	if channel.ID == "" {
+		return nil, fmt.Errorf("频道不存在")
+	}
+
+	db := model.GetDB()
+	var msg model.MessageModel
+	db.Preload("User", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, username, nickname, avatar, is_bot")
+	}).Preload("Member", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, nickname, channel_id")
+	}).Where("channel_id = ? AND id = ? AND is_deleted = ?", channelID, messageID, false).Limit(1).Find(&msg)
+	if msg.ID == "" {
+		return nil, fmt.Errorf("消息不存在")
+	}
+	if !msg.IsGMRoll {
+		return nil, fmt.Errorf("该消息不是GM暗骰")
+	}
+	if msg.UserID != ctx.User.ID && !service.GMRollIsViewer(ctx.User.ID, channel) {
+		return nil, fmt.Errorf("只有发送者或GM可以揭示暗骰")
+	}
+
+	if err := db.Model(&model.MessageModel{}).Where("id = ?", msg.ID).Update("is_gm_roll", false).Error; err != nil {
+		return nil, err
+	}
+	msg.IsGMRoll = false
+	hydrateMessagesForBroadcast([]*model.MessageModel{&msg})
+
+	channelData := channel.ToProtocolType()
+	messageData := buildProtocolMessage(&msg, channelData)
+	if messageData.Quote != nil && messageData.Quote.IsGMRoll {
+		messageData.Quote = gmRollMaskProtocol(messageData.Quote)
+	}
+	ev := &protocol.Event{
+		Type:    protocol.EventMessageUpdated,
+		Message: messageData,
+		Channel: channelData,
+		User:    ctx.User.ToProtocolType(),
+	}
+	ctx.BroadcastEventInChannel(channelID, ev)
+	ctx.BroadcastEventInChannelForBot(channelID, ev)
+	_ = model.WebhookEventLogAppendForMessage(channelID, "message-updated", msg.ID)
+
+	return &struct {
+		Message *protocol.Message `json:"message"`
+	}{Message: messageData}, nil
+}
+
+// apiChannelGMRolesSet 设置可查看 GM 暗骰的频道角色
+func apiChannelGMRolesSet(ctx *ChatContext, data *struct {
+	ChannelID string   `json:"channel_id"`
+	RoleIDs   []string `json:"role_ids"`
+}) (any, error) {
+	if data.ChannelID == "" {
+		return nil, fmt.Errorf("频道ID不能为空")
+	}
+	if !pm.CanWithChannelRole(ctx.User.ID, data.ChannelID, pm.PermFuncChannelManageInfo, pm.PermFuncChannelRoleLink) {
+		return nil, fmt.Errorf("您没有权限设置GM角色")
+	}
+	channel, _ := model.ChannelGet(data.ChannelID)
+	if channel.ID == "" {
+		return nil, fmt.Errorf("频道不存在")
+	}
+
+	roleIDs := lo.Uniq(lo.Filter(lo.Map(data.RoleIDs, func(id string, _ int) string {
+		return strings.TrimSpace(id)
+	}), func(id string, _ int) bool { return id != "" }))
+	if len(roleIDs) > 0 {
+		var count int64
+		model.GetDB().Model(&model.ChannelRoleModel{}).
+			Where("channel_id = ? AND id IN ?", channel.ID, roleIDs).
+			Count(&count)
+		if int(count) != len(roleIDs) {
+			return nil, fmt.Errorf("包含不属于该频道的角色")
+		}
+	}
+
+	gmRoleIDs := model.JSONList[string](roleIDs)
+	if err := model.GetDB().Model(&model.ChannelModel{}).
+		Where("id = ?", channel.ID).
+		Update("gm_role_ids", gmRoleIDs).Error; err != nil {
+		return nil, err
+	}
+	channel.GMRoleIDs = gmRoleIDs
+	ev := &protocol.Event{
+		Type:    protocol.EventChannelUpdated,
+		Channel: channel.ToProtocolType(),
+		User:    ctx.User.ToProtocolType(),
+	}
+	ctx.BroadcastEventInChannel(channel.ID, ev)
+	ctx.BroadcastEventInChannelForBot(channel.ID, ev)
+
+	return &struct {
+		ChannelID string   `json:"channel_id"`
+		RoleIDs   []string `json:"role_ids"`
+	}{ChannelID: channel.ID, RoleIDs: roleIDs}, nil
+}
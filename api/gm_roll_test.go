@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"sealchat/model"
+	"sealchat/protocol"
+	"sealchat/utils"
+)
+
+func TestSatoriMasksGMRoll(t *testing.T) {
+	f := seedBotFixture(t)
+	app := newSatoriTestApp()
+	msg := &model.MessageModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: "gm" + utils.NewIDWithLength(8)},
+		ChannelID:         f.channelID,
+		UserID:            f.ownerID,
+		Content:           "D100=42 秘密",
+		IsGMRoll:          true,
+		DisplayOrder:      float64(time.Now().UnixMilli()),
+	}
+	if err := model.GetDB().Create(msg).Error; err != nil {
+		t.Fatalf("create message failed: %v", err)
+	}
+
+	status, body := satoriCall(t, app, f, "message.get", map[string]any{"channel_id": f.channelID, "message_id": msg.ID})
+	if status != http.StatusOK {
+		t.Fatalf("message.get status %d: %s", status, body)
+	}
+	var got protocol.SatoriMessage
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("decode message.get failed: %v", err)
+	}
+	if strings.Contains(got.Content, "秘密") {
+		t.Fatalf("message.get leaked GM roll content: %q", got.Content)
+	}
+
+	status, body = satoriCall(t, app, f, "message.list", map[string]any{"channel_id": f.channelID})
+	if status != http.StatusOK {
+		t.Fatalf("message.list status %d: %s", status, body)
+	}
+	if strings.Contains(string(body), "秘密") {
+		t.Fatalf("message.list leaked GM roll content: %s", body)
+	}
+}
@@ -30,6 +30,9 @@ func MessageReactionAdd(c *fiber.Ctx) error {
 	if status != 0 {
 		return c.Status(status).JSON(fiber.Map{"message": errMsg})
 	}
+	if err := channelSpeakCheckHTTP(c, channel.ID, user.ID); err != nil {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"message": err.Error()})
+	}
 
 	var req messageReactionRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -81,6 +84,9 @@ func MessageReactionRemove(c *fiber.Ctx) error {
 	if status != 0 {
 		return c.Status(status).JSON(fiber.Map{"message": errMsg})
 	}
+	if err := channelSpeakCheckHTTP(c, channel.ID, user.ID); err != nil {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"message": err.Error()})
+	}
 
 	var req messageReactionRequest
 	if err := c.BodyParser(&req); err != nil {
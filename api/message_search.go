@@ -152,6 +152,8 @@ func ChannelMessageSearch(c *fiber.Ctx) error {
 	}
 
 	db := model.GetDB()
+	searchChannel, _ := model.ChannelGet(channelID)
+	isGM := service.GMRollIsViewer(user.ID, searchChannel)
 	buildBaseQuery := func() *gorm.DB {
 		q := db.Model(&model.MessageModel{}).
 			Where("channel_id = ?", channelID).
@@ -160,6 +162,10 @@ func ChannelMessageSearch(c *fiber.Ctx) error {
 			))`, false, user.ID, user.ID, user.ID).
 			Where("is_revoked = ?", false).
 			Where("is_deleted = ?", false)
+		if !isGM {
+			// 未揭示的 GM 暗骰只有发送者能搜到
+			q = q.Where("(is_gm_roll = ? OR user_id = ?)", false, user.ID)
+		}
 
 		switch archivedFilter {
 		case "only":
@@ -0,0 +1,609 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+
+	"sealchat/model"
+	"sealchat/protocol"
+	"sealchat/service"
+	"sealchat/utils"
+)
+
+// OneBot v11 适配，供按 OneBot v11 编写的骰子 BOT 直接接入：
+// HTTP API: /onebot/v11/:action，Authorization: Bearer <BOT 令牌>（或 ?access_token=）
+// 反向 WebSocket: 见 onebot_reverse.go
+// 频道对应 group_id，用户对应 user_id，数字编号由 service.OneBotID 分配；消息内容统一为 CQ 码字符串
+
+// OneBot v11 retcode
+const (
+	oneBotRetOK          = 0
+	oneBotRetFailed      = 100
+	oneBotRetBadRequest  = 1400
+	oneBotRetUnsupported = 1404
+)
+
+type oneBotError struct {
+	retcode int
+	message string
+}
+
+func (e *oneBotError) Error() string {
+	return e.message
+}
+
+func newOneBotError(retcode int, message string) error {
+	return &oneBotError{retcode: retcode, message: message}
+}
+
+type oneBotResponse struct {
+	Status  string          `json:"status"`
+	RetCode int             `json:"retcode"`
+	Data    any             `json:"data"`
+	Message string          `json:"message,omitempty"`
+	Echo    json.RawMessage `json:"echo,omitempty"`
+}
+
+// oneBotNumber 兼容数字与字符串两种写法的 ID 参数
+type oneBotNumber int64
+
+func (n *oneBotNumber) UnmarshalJSON(data []byte) error {
+	text := strings.Trim(strings.TrimSpace(string(data)), `"`)
+	if text == "" || text == "null" {
+		*n = 0
+		return nil
+	}
+	v, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return err
+	}
+	*n = oneBotNumber(v)
+	return nil
+}
+
+type oneBotHandler func(ctx *ChatContext, params []byte) (any, error)
+
+func oneBotHandle[T any](solve func(ctx *ChatContext, data *T) (any, error)) oneBotHandler {
+	return func(ctx *ChatContext, params []byte) (any, error) {
+		data := new(T)
+		if len(bytes.TrimSpace(params)) > 0 && !bytes.Equal(bytes.TrimSpace(params), []byte("null")) {
+			if err := json.Unmarshal(params, data); err != nil {
+				return nil, newOneBotError(oneBotRetBadRequest, "参数格式错误")
+			}
+		}
+		return solve(ctx, data)
+	}
+}
+
+var oneBotActions = map[string]oneBotHandler{
+	"send_msg":              oneBotHandle(oneBotSendMsg),
+	"send_group_msg":        oneBotHandle(oneBotSendGroupMsg),
+	"send_private_msg":      oneBotHandle(oneBotSendPrivateMsg),
+	"delete_msg":            oneBotHandle(oneBotDeleteMsg),
+	"get_msg":               oneBotHandle(oneBotGetMsg),
+	"get_login_info":        oneBotHandle(oneBotGetLoginInfo),
+	"get_stranger_info":     oneBotHandle(oneBotGetStrangerInfo),
+	"get_friend_list":       oneBotHandle(oneBotGetFriendList),
+	"get_group_info":        oneBotHandle(oneBotGetGroupInfo),
+	"get_group_list":        oneBotHandle(oneBotGetGroupList),
+	"get_group_member_info": oneBotHandle(oneBotGetGroupMemberInfo),
+	"get_group_member_list": oneBotHandle(oneBotGetGroupMemberList),
+	"can_send_image":        oneBotHandle(oneBotCanSendImage),
+	"can_send_record":       oneBotHandle(oneBotCanSendRecord),
+	"get_status":            oneBotHandle(oneBotGetStatus),
+	"get_version_info":      oneBotHandle(oneBotGetVersionInfo),
+}
+
+// oneBotActionScopes action 所需的 BOT 令牌权限范围
+var oneBotActionScopes = map[string]string{
+	"send_msg":         model.BotScopeMessageWrite,
+	"send_group_msg":   model.BotScopeMessageWrite,
+	"send_private_msg": model.BotScopeMessageWrite,
+	"delete_msg":       model.BotScopeMessageWrite,
+	"get_msg":          model.BotScopeMessageRead,
+}
+
+// oneBotCall 执行一个 action，HTTP 与反向 WebSocket 共用
+func oneBotCall(bot *model.UserModel, action string, params []byte) *oneBotResponse {
+	handler, ok := oneBotActions[action]
+	if !ok {
+		return &oneBotResponse{Status: "failed", RetCode: oneBotRetUnsupported, Message: "不支持的 action: " + action}
+	}
+	if scope, ok := oneBotActionScopes[action]; ok && !botTokenOf(bot.ID).HasScope(scope) {
+		return &oneBotResponse{Status: "failed", RetCode: oneBotRetFailed, Message: "BOT 令牌无此权限"}
+	}
+	ret, err := handler(newAdapterContext(bot), params)
+	if err != nil {
+		retcode := oneBotRetFailed
+		var oe *oneBotError
+		if errors.As(err, &oe) {
+			retcode = oe.retcode
+		}
+		return &oneBotResponse{Status: "failed", RetCode: retcode, Message: err.Error()}
+	}
+	return &oneBotResponse{Status: "ok", RetCode: oneBotRetOK, Data: ret}
+}
+
+func oneBotWorks(app *fiber.App) {
+	app.All("/onebot/v11/:action", OneBotAuthMiddleware, oneBotHTTPHandler)
+	oneBotReverseWorks()
+}
+
+// OneBotAuthMiddleware 按 OneBot v11 约定，缺少令牌返回 401，令牌无效返回 403
+func OneBotAuthMiddleware(c *fiber.Ctx) error {
+	token := getAuthorizationToken(c)
+	if token == "" {
+		token = strings.TrimSpace(c.Query("access_token"))
+	}
+	if token == "" {
+		return c.SendStatus(http.StatusUnauthorized)
+	}
+	if len(token) != 32 {
+		return c.SendStatus(http.StatusForbidden)
+	}
+	user, err := model.BotVerifyAccessToken(token)
+	if err != nil || user == nil {
+		return c.SendStatus(http.StatusForbidden)
+	}
+
+	_ = model.GetDB().Model(&model.BotTokenModel{}).
+		Where("id = ?", user.ID).
+		Update("recent_used_at", time.Now().UnixMilli()).Error
+
+	c.Locals("user", user)
+	return c.Next()
+}
+
+func oneBotHTTPHandler(c *fiber.Ctx) error {
+	action := strings.TrimSpace(c.Params("action"))
+	if _, ok := oneBotActions[action]; !ok {
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	params := c.Body()
+	if c.Method() != fiber.MethodPost || !strings.Contains(strings.ToLower(c.Get(fiber.HeaderContentType)), "json") {
+		// GET 与表单提交的参数都是字符串，数字和布尔值按字面量还原
+		values := map[string]any{}
+		collect := func(key, value []byte) {
+			k := string(key)
+			if k == "access_token" {
+				return
+			}
+			v := string(value)
+			switch v {
+			case "true":
+				values[k] = true
+			case "false":
+				values[k] = false
+			default:
+				values[k] = v
+			}
+		}
+		c.Context().QueryArgs().VisitAll(collect)
+		c.Context().PostArgs().VisitAll(collect)
+		params, _ = json.Marshal(values)
+	}
+	return c.JSON(oneBotCall(getCurUser(c), action, params))
+}
+
+// 消息
+
+// oneBotParseMessage message 参数可以是 CQ 码字符串、消息段数组或单个消息段
+func oneBotParseMessage(raw json.RawMessage, autoEscape bool) (content string, quoteID string, err error) {
+	raw = bytes.TrimSpace(raw)
+	var elements []*protocol.Element
+	switch {
+	case len(raw) == 0:
+	case raw[0] == '"':
+		var text string
+		if err := json.Unmarshal(raw, &text); err != nil {
+			return "", "", newOneBotError(oneBotRetBadRequest, "message 格式错误")
+		}
+		if autoEscape {
+			elements = []*protocol.Element{{Type: "text", Attrs: protocol.Dict{"content": text}}}
+		} else {
+			elements = service.OneBotParseCQ(text)
+		}
+	case raw[0] == '[':
+		var segments []service.OneBotSegment
+		if err := json.Unmarshal(raw, &segments); err != nil {
+			return "", "", newOneBotError(oneBotRetBadRequest, "message 格式错误")
+		}
+		elements = service.OneBotSegmentsToElements(segments)
+	case raw[0] == '{':
+		var segment service.OneBotSegment
+		if err := json.Unmarshal(raw, &segment); err != nil {
+			return "", "", newOneBotError(oneBotRetBadRequest, "message 格式错误")
+		}
+		elements = service.OneBotSegmentsToElements([]service.OneBotSegment{segment})
+	default:
+		return "", "", newOneBotError(oneBotRetBadRequest, "message 格式错误")
+	}
+	content, quoteID = service.OneBotElementsToContent(elements)
+	if strings.TrimSpace(content) == "" {
+		return "", "", newOneBotError(oneBotRetBadRequest, "消息内容为空")
+	}
+	return content, quoteID, nil
+}
+
+func oneBotSend(ctx *ChatContext, channelID string, message json.RawMessage, autoEscape bool) (any, error) {
+	content, quoteID, err := oneBotParseMessage(message, autoEscape)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := apiInvoke(ctx, apiMessageCreate, map[string]any{
+		"channel_id": channelID,
+		"content":    content,
+		"quote_id":   quoteID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	msg, ok := ret.(*protocol.Message)
+	if !ok || msg == nil {
+		return nil, newOneBotError(oneBotRetFailed, "无权在该频道发送消息")
+	}
+	return map[string]any{"message_id": service.OneBotID(service.OneBotKindMessage, msg.ID)}, nil
+}
+
+func oneBotGroupChannel(ctx *ChatContext, groupID oneBotNumber) (*model.ChannelModel, error) {
+	channelID := service.OneBotRefID(service.OneBotKindGroup, int64(groupID))
+	if channelID == "" {
+		return nil, newOneBotError(oneBotRetFailed, "群不存在或无权访问")
+	}
+	ch, _ := model.ChannelGet(channelID)
+	if ch == nil || ch.IsPrivate || !botCanReadChannel(ctx.User.ID, ch) {
+		return nil, newOneBotError(oneBotRetFailed, "群不存在或无权访问")
+	}
+	return ch, nil
+}
+
+func oneBotUserRef(userID oneBotNumber) (*model.UserModel, error) {
+	refID := service.OneBotRefID(service.OneBotKindUser, int64(userID))
+	if refID == "" {
+		return nil, newOneBotError(oneBotRetFailed, "用户不存在")
+	}
+	user := model.UserGet(refID)
+	if user == nil || user.ID == "" {
+		return nil, newOneBotError(oneBotRetFailed, "用户不存在")
+	}
+	return user, nil
+}
+
+func oneBotSendGroupMsg(ctx *ChatContext, data *struct {
+	GroupID    oneBotNumber    `json:"group_id"`
+	Message    json.RawMessage `json:"message"`
+	AutoEscape bool            `json:"auto_escape"`
+}) (any, error) {
+	ch, err := oneBotGroupChannel(ctx, data.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	return oneBotSend(ctx, ch.ID, data.Message, data.AutoEscape)
+}
+
+func oneBotSendPrivateMsg(ctx *ChatContext, data *struct {
+	UserID     oneBotNumber    `json:"user_id"`
+	Message    json.RawMessage `json:"message"`
+	AutoEscape bool            `json:"auto_escape"`
+}) (any, error) {
+	user, err := oneBotUserRef(data.UserID)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := botPrivateChannel(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return oneBotSend(ctx, ch.ID, data.Message, data.AutoEscape)
+}
+
+func oneBotSendMsg(ctx *ChatContext, data *struct {
+	MessageType string          `json:"message_type"`
+	GroupID     oneBotNumber    `json:"group_id"`
+	UserID      oneBotNumber    `json:"user_id"`
+	Message     json.RawMessage `json:"message"`
+	AutoEscape  bool            `json:"auto_escape"`
+}) (any, error) {
+	if data.MessageType == "private" || (data.MessageType == "" && data.GroupID == 0) {
+		return oneBotSendPrivateMsg(ctx, &struct {
+			UserID     oneBotNumber    `json:"user_id"`
+			Message    json.RawMessage `json:"message"`
+			AutoEscape bool            `json:"auto_escape"`
+		}{data.UserID, data.Message, data.AutoEscape})
+	}
+	return oneBotSendGroupMsg(ctx, &struct {
+		GroupID    oneBotNumber    `json:"group_id"`
+		Message    json.RawMessage `json:"message"`
+		AutoEscape bool            `json:"auto_escape"`
+	}{data.GroupID, data.Message, data.AutoEscape})
+}
+
+// oneBotLoadMessage 由数字编号取回消息，并确认 BOT 可以读取其所在频道
+func oneBotLoadMessage(ctx *ChatContext, messageID oneBotNumber) (*model.MessageModel, *model.ChannelModel, error) {
+	refID := service.OneBotRefID(service.OneBotKindMessage, int64(messageID))
+	if refID == "" {
+		return nil, nil, newOneBotError(oneBotRetFailed, "消息不存在")
+	}
+	var item model.MessageModel
+	model.GetDB().Where("id = ?", refID).
+		Preload("User", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, username, nickname, avatar, is_bot")
+		}).
+		Preload("Member", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, nickname, channel_id")
+		}).
+		Limit(1).
+		Find(&item)
+	if item.ID == "" {
+		return nil, nil, newOneBotError(oneBotRetFailed, "消息不存在")
+	}
+	ch, _ := model.ChannelGet(item.ChannelID)
+	if !botCanReadChannel(ctx.User.ID, ch) {
+		return nil, nil, newOneBotError(oneBotRetFailed, "消息不存在")
+	}
+	return &item, ch, nil
+}
+
+func oneBotDeleteMsg(ctx *ChatContext, data *struct {
+	MessageID oneBotNumber `json:"message_id"`
+}) (any, error) {
+	item, ch, err := oneBotLoadMessage(ctx, data.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := apiInvoke(ctx, apiMessageDelete, map[string]any{
+		"channel_id": ch.ID,
+		"message_id": item.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if ret == nil {
+		return nil, newOneBotError(oneBotRetFailed, "无权撤回该消息")
+	}
+	return nil, nil
+}
+
+func oneBotGetMsg(ctx *ChatContext, data *struct {
+	MessageID oneBotNumber `json:"message_id"`
+}) (any, error) {
+	item, ch, err := oneBotLoadMessage(ctx, data.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	// 悄悄话与已删除消息的可见性沿用 message.get 的判断
+	ret, err := apiInvoke(ctx, apiMessageGet, map[string]any{
+		"channel_id": ch.ID,
+		"message_id": item.ID,
+	})
+	if err != nil || ret == nil {
+		return nil, newOneBotError(oneBotRetFailed, "消息不存在")
+	}
+
+	messageType := "group"
+	if ch.IsPrivate {
+		messageType = "private"
+	}
+	maskGMRollsForUser(ctx.User.ID, ch, ch.ID, []*model.MessageModel{item})
+	msg := buildProtocolMessage(item, ch.ToProtocolType())
+	result := map[string]any{
+		"time":         item.CreatedAt.Unix(),
+		"message_type": messageType,
+		"message_id":   int64(data.MessageID),
+		"real_id":      int64(data.MessageID),
+		"sender":       oneBotSenderOf(msg, ""),
+		"message":      service.OneBotContentToCQ(item.Content),
+	}
+	if !ch.IsPrivate {
+		result["group_id"] = service.OneBotID(service.OneBotKindGroup, ch.ID)
+	}
+	return result, nil
+}
+
+// 用户与群
+
+func oneBotSenderOf(msg *protocol.Message, role string) map[string]any {
+	sender := map[string]any{"sex": "unknown", "age": 0}
+	if msg.User != nil {
+		sender["user_id"] = service.OneBotID(service.OneBotKindUser, msg.User.ID)
+		sender["nickname"] = msg.User.Nick
+	}
+	if role != "" {
+		sender["role"] = role
+		sender["card"] = ""
+		if msg.Member != nil {
+			sender["card"] = msg.Member.Nick
+		}
+	}
+	return sender
+}
+
+func oneBotGetLoginInfo(ctx *ChatContext, _ *struct{}) (any, error) {
+	return map[string]any{
+		"user_id":  service.OneBotID(service.OneBotKindUser, ctx.User.ID),
+		"nickname": ctx.User.Nickname,
+	}, nil
+}
+
+func oneBotGetStrangerInfo(ctx *ChatContext, data *struct {
+	UserID oneBotNumber `json:"user_id"`
+}) (any, error) {
+	user, err := oneBotUserRef(data.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"user_id":  int64(data.UserID),
+		"nickname": user.Nickname,
+		"sex":      "unknown",
+		"age":      0,
+	}, nil
+}
+
+// oneBotGetFriendList SealChat 没有与 QQ 等价的好友关系，BOT 总是返回空列表
+func oneBotGetFriendList(ctx *ChatContext, _ *struct{}) (any, error) {
+	return []any{}, nil
+}
+
+func oneBotGroupInfoOf(ch *model.ChannelModel) map[string]any {
+	var count int64
+	model.GetDB().Model(&model.MemberModel{}).Where("channel_id = ?", ch.ID).Count(&count)
+	return map[string]any{
+		"group_id":         service.OneBotID(service.OneBotKindGroup, ch.ID),
+		"group_name":       ch.Name,
+		"member_count":     count,
+		"max_member_count": 0,
+	}
+}
+
+func oneBotGetGroupInfo(ctx *ChatContext, data *struct {
+	GroupID oneBotNumber `json:"group_id"`
+}) (any, error) {
+	ch, err := oneBotGroupChannel(ctx, data.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	return oneBotGroupInfoOf(ch), nil
+}
+
+// oneBotGetGroupList 群列表为 BOT 绑定的频道
+func oneBotGetGroupList(ctx *ChatContext, _ *struct{}) (any, error) {
+	result := []map[string]any{}
+	channelIDs := botBoundChannelIDs(ctx.User.ID)
+	if len(channelIDs) == 0 {
+		return result, nil
+	}
+	var channels []*model.ChannelModel
+	model.GetDB().
+		Where("id IN ? AND is_private = ? AND status = ?", channelIDs, false, model.ChannelStatusActive).
+		Order("created_at asc").
+		Find(&channels)
+	for _, ch := range channels {
+		if botCanReadChannel(ctx.User.ID, ch) {
+			result = append(result, oneBotGroupInfoOf(ch))
+		}
+	}
+	return result, nil
+}
+
+// oneBotWorldRoles 群成员的 role 取自世界成员身份：owner/admin 原样对应，其余为 member
+func oneBotWorldRoles(worldID string, userIDs []string) map[string]string {
+	roles := map[string]string{}
+	if worldID == "" || len(userIDs) == 0 {
+		return roles
+	}
+	var members []*model.WorldMemberModel
+	model.GetDB().Where("world_id = ? AND user_id IN ?", worldID, userIDs).Find(&members)
+	for _, m := range members {
+		if m.Role == model.WorldRoleOwner || m.Role == model.WorldRoleAdmin {
+			roles[m.UserID] = m.Role
+		}
+	}
+	return roles
+}
+
+func oneBotMemberInfoOf(ch *model.ChannelModel, member *model.MemberModel, user *model.UserModel, role string) map[string]any {
+	if role == "" {
+		role = "member"
+	}
+	nickname := ""
+	if user != nil {
+		nickname = user.Nickname
+	}
+	return map[string]any{
+		"group_id":          service.OneBotID(service.OneBotKindGroup, ch.ID),
+		"user_id":           service.OneBotID(service.OneBotKindUser, member.UserID),
+		"nickname":          nickname,
+		"card":              member.Nickname,
+		"sex":               "unknown",
+		"age":               0,
+		"area":              "",
+		"join_time":         member.CreatedAt.Unix(),
+		"last_sent_time":    member.RecentSentAt / 1000,
+		"level":             "",
+		"role":              role,
+		"unfriendly":        false,
+		"title":             "",
+		"title_expire_time": 0,
+		"card_changeable":   false,
+	}
+}
+
+func oneBotGetGroupMemberInfo(ctx *ChatContext, data *struct {
+	GroupID oneBotNumber `json:"group_id"`
+	UserID  oneBotNumber `json:"user_id"`
+}) (any, error) {
+	ch, err := oneBotGroupChannel(ctx, data.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := oneBotUserRef(data.UserID)
+	if err != nil {
+		return nil, err
+	}
+	var member model.MemberModel
+	model.GetDB().Where("channel_id = ? AND user_id = ?", ch.ID, user.ID).Limit(1).Find(&member)
+	if member.ID == "" {
+		return nil, newOneBotError(oneBotRetFailed, "成员不存在")
+	}
+	roles := oneBotWorldRoles(ch.WorldID, []string{user.ID})
+	return oneBotMemberInfoOf(ch, &member, user, roles[user.ID]), nil
+}
+
+func oneBotGetGroupMemberList(ctx *ChatContext, data *struct {
+	GroupID oneBotNumber `json:"group_id"`
+}) (any, error) {
+	ch, err := oneBotGroupChannel(ctx, data.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	db := model.GetDB()
+	var members []*model.MemberModel
+	db.Where("channel_id = ?", ch.ID).Order("created_at asc").Find(&members)
+
+	result := make([]map[string]any, 0, len(members))
+	if len(members) == 0 {
+		return result, nil
+	}
+	userIDs := lo.Uniq(lo.Map(members, func(m *model.MemberModel, _ int) string { return m.UserID }))
+	var users []*model.UserModel
+	db.Select("id, username, nickname, avatar, is_bot").Where("id IN ?", userIDs).Find(&users)
+	id2User := lo.KeyBy(users, func(u *model.UserModel) string { return u.ID })
+	roles := oneBotWorldRoles(ch.WorldID, userIDs)
+	for _, member := range members {
+		result = append(result, oneBotMemberInfoOf(ch, member, id2User[member.UserID], roles[member.UserID]))
+	}
+	return result, nil
+}
+
+// 其他
+
+func oneBotCanSendImage(ctx *ChatContext, _ *struct{}) (any, error) {
+	return map[string]any{"yes": true}, nil
+}
+
+func oneBotCanSendRecord(ctx *ChatContext, _ *struct{}) (any, error) {
+	return map[string]any{"yes": false}, nil
+}
+
+func oneBotGetStatus(ctx *ChatContext, _ *struct{}) (any, error) {
+	return map[string]any{"online": true, "good": true}, nil
+}
+
+func oneBotGetVersionInfo(ctx *ChatContext, _ *struct{}) (any, error) {
+	return map[string]any{
+		"app_name":         "sealchat",
+		"app_version":      utils.BuildVersion,
+		"protocol_version": "v11",
+	}, nil
+}
@@ -0,0 +1,287 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	fastws "github.com/fasthttp/websocket"
+	"github.com/gofiber/contrib/websocket"
+
+	"sealchat/model"
+	"sealchat/protocol"
+	"sealchat/service"
+	"sealchat/service/metrics"
+	"sealchat/utils"
+)
+
+// OneBot v11 反向 WebSocket：SealChat 作为 OneBot 实现主动连接 BOT 框架（Universal 角色），
+// 同一条连接上接收 action 调用并推送事件。断线后按 reconnectIntervalSec 重连
+
+// oneBotSession 挂在 WsSyncConn.adapter 上，负责把站内事件转换为 OneBot v11 事件
+type oneBotSession struct {
+	bot    *model.UserModel
+	selfID int64
+}
+
+func (s *oneBotSession) writeEvent(conn *WsSyncConn, data *protocol.Event) {
+	ev := s.buildEvent(data)
+	if ev == nil {
+		return
+	}
+	_ = conn.writeRaw(ev)
+}
+
+func (s *oneBotSession) baseEvent(postType string) map[string]any {
+	return map[string]any{
+		"time":      time.Now().Unix(),
+		"self_id":   s.selfID,
+		"post_type": postType,
+	}
+}
+
+func (s *oneBotSession) buildEvent(data *protocol.Event) map[string]any {
+	msg := data.Message
+	if msg == nil {
+		return nil
+	}
+	channel := data.Channel
+	if channel == nil {
+		channel = msg.Channel
+	}
+	if channel == nil {
+		return nil
+	}
+	isPrivate := channel.Type == protocol.DirectChannelType
+	messageID := service.OneBotID(service.OneBotKindMessage, msg.ID)
+
+	switch data.Type {
+	case protocol.EventMessageCreated:
+		// 与常见 OneBot 实现一致，不上报 BOT 自己发出的消息
+		if msg.User == nil || msg.User.ID == s.bot.ID {
+			return nil
+		}
+		content := service.OneBotContentToCQ(msg.Content)
+		ev := s.baseEvent("message")
+		ev["message_id"] = messageID
+		ev["user_id"] = service.OneBotID(service.OneBotKindUser, msg.User.ID)
+		ev["message"] = content
+		ev["raw_message"] = content
+		ev["font"] = 0
+		if isPrivate {
+			ev["message_type"] = "private"
+			ev["sub_type"] = "friend"
+			ev["sender"] = oneBotSenderOf(msg, "")
+			return ev
+		}
+		role := oneBotWorldRoles(channel.WorldID, []string{msg.User.ID})[msg.User.ID]
+		if role == "" {
+			role = "member"
+		}
+		ev["message_type"] = "group"
+		ev["sub_type"] = "normal"
+		ev["group_id"] = service.OneBotID(service.OneBotKindGroup, channel.ID)
+		ev["anonymous"] = nil
+		ev["sender"] = oneBotSenderOf(msg, role)
+		return ev
+	case protocol.EventMessageDeleted:
+		senderID := ""
+		if msg.User != nil {
+			senderID = msg.User.ID
+		} else if data.User != nil {
+			senderID = data.User.ID
+		}
+		ev := s.baseEvent("notice")
+		ev["message_id"] = messageID
+		ev["user_id"] = service.OneBotID(service.OneBotKindUser, senderID)
+		if isPrivate {
+			ev["notice_type"] = "friend_recall"
+			return ev
+		}
+		ev["notice_type"] = "group_recall"
+		ev["group_id"] = service.OneBotID(service.OneBotKindGroup, channel.ID)
+		if data.User != nil {
+			ev["operator_id"] = service.OneBotID(service.OneBotKindUser, data.User.ID)
+		}
+		return ev
+	}
+	return nil
+}
+
+func (s *oneBotSession) lifecycleEvent() map[string]any {
+	ev := s.baseEvent("meta_event")
+	ev["meta_event_type"] = "lifecycle"
+	ev["sub_type"] = "connect"
+	return ev
+}
+
+func (s *oneBotSession) heartbeatEvent(interval time.Duration) map[string]any {
+	ev := s.baseEvent("meta_event")
+	ev["meta_event_type"] = "heartbeat"
+	ev["status"] = map[string]any{"online": true, "good": true}
+	ev["interval"] = interval.Milliseconds()
+	return ev
+}
+
+func oneBotReverseWorks() {
+	cfg := utils.GetConfig()
+	if cfg == nil {
+		return
+	}
+	for _, item := range cfg.OneBot.Reverse {
+		go oneBotReverseLoop(item, cfg.OneBot)
+	}
+}
+
+func oneBotReverseLoop(item utils.OneBotReverseConfig, cfg utils.OneBotConfig) {
+	for {
+		if err := oneBotReverseConnect(item, cfg); err != nil {
+			log.Printf("[OneBot] 反向 WebSocket %s: %v", item.URL, err)
+		}
+		time.Sleep(time.Duration(cfg.ReconnectIntervalSec) * time.Second)
+	}
+}
+
+// oneBotRegisterConn 登记连接。与 /ws/seal 一致，同一 BOT 只保留最新的一条连接
+func oneBotRegisterConn(c *WsSyncConn, bot *model.UserModel) *ConnInfo {
+	m, _ := getUserConnInfoMap().LoadOrStore(bot.ID, &utils.SyncMap[*WsSyncConn, *ConnInfo]{})
+	m.Range(func(conn *WsSyncConn, _ *ConnInfo) bool {
+		conn.Close()
+		m.Delete(conn)
+		if collector := metrics.Get(); collector != nil {
+			collector.RecordConnectionClosed(bot.ID)
+		}
+		return true
+	})
+	now := time.Now().UnixMilli()
+	info := &ConnInfo{
+		Conn:          c,
+		LastPingTime:  now,
+		LastAliveTime: now,
+		User:          bot,
+		TypingState:   protocol.TypingStateSilent,
+		TypingIcMode:  "ic",
+	}
+	m.Store(c, info)
+	if collector := metrics.Get(); collector != nil {
+		collector.RecordConnectionOpened(bot.ID)
+		collector.RecordUserHeartbeat(bot.ID)
+	}
+	return info
+}
+
+func oneBotReverseConnect(item utils.OneBotReverseConfig, cfg utils.OneBotConfig) error {
+	if getUserConnInfoMap() == nil {
+		return errors.New("WebSocket 服务尚未就绪")
+	}
+	bot, err := model.BotVerifyAccessToken(item.BotToken)
+	if err != nil || bot == nil {
+		return errors.New("BOT 令牌无效")
+	}
+	session := &oneBotSession{bot: bot, selfID: service.OneBotID(service.OneBotKindUser, bot.ID)}
+
+	header := http.Header{}
+	header.Set("X-Self-ID", strconv.FormatInt(session.selfID, 10))
+	header.Set("X-Client-Role", "Universal")
+	header.Set("User-Agent", "SealChat-OneBot/v11")
+	if item.AccessToken != "" {
+		header.Set("Authorization", "Bearer "+item.AccessToken)
+	}
+	rawConn, _, err := fastws.DefaultDialer.Dial(item.URL, header)
+	if err != nil {
+		return err
+	}
+	c := &WsSyncConn{
+		Conn:      &websocket.Conn{Conn: rawConn},
+		transport: wsTransportInfo{Encoding: wsEncodingJSON},
+		adapter:   session,
+		botID:     bot.ID,
+	}
+	info := oneBotRegisterConn(c, bot)
+	log.Printf("[OneBot] BOT %s 已连接到 %s", bot.ID, item.URL)
+	defer func() {
+		_ = c.Close()
+		if collector := metrics.Get(); collector != nil {
+			collector.RecordConnectionClosed(bot.ID)
+		}
+		if m, ok := getUserConnInfoMap().Load(bot.ID); ok {
+			m.Delete(c)
+		}
+	}()
+
+	// BOT 框架在反向连接上通常只在需要时调用 action，这里用 WebSocket ping 维持活跃，避免被健康检查清理
+	rawConn.SetPongHandler(func(string) error {
+		info.LastAliveTime = time.Now().UnixMilli()
+		return nil
+	})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		pingTicker := time.NewTicker(time.Duration(botHealthCheckIntervalSeconds) * time.Second)
+		defer pingTicker.Stop()
+		var heartbeat <-chan time.Time
+		interval := time.Duration(cfg.HeartbeatIntervalSec) * time.Second
+		if interval > 0 {
+			heartbeatTicker := time.NewTicker(interval)
+			defer heartbeatTicker.Stop()
+			heartbeat = heartbeatTicker.C
+		}
+		for {
+			select {
+			case <-pingTicker.C:
+				c.Mux.Lock()
+				err := rawConn.WriteControl(fastws.PingMessage, nil, time.Now().Add(10*time.Second))
+				c.Mux.Unlock()
+				if err != nil {
+					_ = rawConn.Close()
+					return
+				}
+			case <-heartbeat:
+				_ = c.writeRaw(session.heartbeatEvent(interval))
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	if err := c.writeRaw(session.lifecycleEvent()); err != nil {
+		return err
+	}
+
+	// 同一连接上的 action 按到达顺序逐个处理，避免连发的消息乱序或并发写库；
+	// 读取放在独立循环中，处理较慢时仍能及时响应 pong 与断开
+	calls := make(chan *oneBotReverseCall, oneBotReverseQueueSize)
+	defer close(calls)
+	go func() {
+		for call := range calls {
+			resp := oneBotCall(bot, call.Action, call.Params)
+			resp.Echo = call.Echo
+			_ = c.writeRaw(resp)
+		}
+	}()
+
+	for {
+		_, msg, err := rawConn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		info.LastAliveTime = time.Now().UnixMilli()
+		call := &oneBotReverseCall{}
+		if err := json.Unmarshal(msg, call); err != nil || call.Action == "" {
+			continue
+		}
+		calls <- call
+	}
+}
+
+// oneBotReverseQueueSize 单条连接上等待处理的 action 上限，排满后暂停读取
+const oneBotReverseQueueSize = 64
+
+type oneBotReverseCall struct {
+	Action string          `json:"action"`
+	Params json.RawMessage `json:"params"`
+	Echo   json.RawMessage `json:"echo"`
+}
@@ -1,7 +1,6 @@
 package api
 
 import (
-	"sort"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -61,13 +60,7 @@ func buildChannelPresenceSnapshot(channelID string, channelUsersMap *utils.SyncM
 		return true
 	})
 
-	sort.SliceStable(results, func(i, j int) bool {
-		if results[i].Focused != results[j].Focused {
-			return results[i].Focused
-		}
-		return results[i].Latency < results[j].Latency
-	})
-
+	sortChannelPresence(results)
 	return results
 }
 
@@ -76,14 +69,16 @@ func (ctx *ChatContext) BroadcastChannelPresence(channelID string) {
 		return
 	}
 	now := time.Now().UnixMilli()
-	presence := buildChannelPresenceSnapshot(channelID, ctx.ChannelUsersMap, ctx.UserId2ConnInfo)
+	local := buildChannelPresenceSnapshot(channelID, ctx.ChannelUsersMap, ctx.UserId2ConnInfo)
+	// 在线状态由各实例各自合并后推送，总线上只同步本实例的快照
+	publishPresenceSnapshot(channelID, local)
 	event := &protocol.Event{
 		Type:     protocol.EventChannelPresenceUpdated,
 		Timestamp: now,
 		Channel:  &protocol.Channel{ID: channelID},
-		Presence: presence,
+		Presence: mergeRemotePresence(channelID, local),
 	}
-	ctx.BroadcastEventInChannel(channelID, event)
+	deliverEventInChannel(ctx.UserId2ConnInfo, channelID, event)
 }
 
 func ChannelPresence(c *fiber.Ctx) error {
@@ -108,7 +103,7 @@ func ChannelPresence(c *fiber.Ctx) error {
 		}
 	}
 
-	snapshot := buildChannelPresenceSnapshot(channelID, getChannelUsersMap(), getUserConnInfoMap())
+	snapshot := mergeRemotePresence(channelID, buildChannelPresenceSnapshot(channelID, getChannelUsersMap(), getUserConnInfoMap()))
 	return c.JSON(fiber.Map{
 		"data":       snapshot,
 		"updated_at": time.Now().UnixMilli(),
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/samber/lo"
+
+	"sealchat/protocol"
+	"sealchat/service"
+	"sealchat/utils"
+)
+
+// broadcastChannelReadUpdated 频道开启已读回执时，通知频道内成员这些用户的已读位置已前进到 readAt
+func broadcastChannelReadUpdated(ctx *ChatContext, channelID string, userIDs []string, readAt int64) {
+	if ctx == nil || ctx.UserId2ConnInfo == nil {
+		return
+	}
+	visible := service.ReadReceiptVisibleUsers(channelID, userIDs)
+	if len(visible) == 0 {
+		return
+	}
+	// 与已读名单一致，只推送给可以查看已读名单的人，游客与非成员看不到谁读到了哪里
+	audience := channelReadAudience(ctx.UserId2ConnInfo, channelID)
+	if len(audience) == 0 {
+		return
+	}
+	ctx.BroadcastEventInChannelToUsers(channelID, audience, &protocol.Event{
+		Type:        protocol.EventChannelReadUpdated,
+		Channel:     &protocol.Channel{ID: channelID},
+		ReadReceipt: &protocol.ChannelReadReceiptPayload{UserIDs: visible, ReadAt: readAt},
+	})
+}
+
+// channelReadAudience 正在查看该频道的非游客用户（含其他实例上报的在线用户）中，可以查看已读名单的人
+func channelReadAudience(userConnMap *userConnInfoMap, channelID string) []string {
+	ids := map[string]struct{}{}
+	userConnMap.Range(func(userID string, conns *utils.SyncMap[*WsSyncConn, *ConnInfo]) bool {
+		conns.Range(func(_ *WsSyncConn, info *ConnInfo) bool {
+			if info != nil && info.ChannelId == channelID && !info.IsGuest {
+				ids[userID] = struct{}{}
+				return false
+			}
+			return true
+		})
+		return true
+	})
+	for _, item := range mergeRemotePresence(channelID, nil) {
+		if item.User != nil {
+			ids[item.User.ID] = struct{}{}
+		}
+	}
+	return service.ReadReceiptAudience(channelID, lo.Keys(ids))
+}
+
+// channelReadCoalesceDelay 发消息带动的已读前进按频道合并推送的间隔
+const channelReadCoalesceDelay = 2 * time.Second
+
+type pendingChannelRead struct {
+	userIDs map[string]struct{}
+	readAt  int64
+}
+
+var (
+	pendingChannelReadsMu sync.Mutex
+	pendingChannelReads   = map[string]*pendingChannelRead{}
+)
+
+// queueChannelReadUpdated 发消息时频道内在线成员的已读位置会一起前进，
+// 同一频道在 channelReadCoalesceDelay 内的多次前进合并为一次推送，避免每条消息都广播全体在线成员
+func queueChannelReadUpdated(ctx *ChatContext, channelID string, userIDs []string, readAt int64) {
+	if ctx == nil || ctx.UserId2ConnInfo == nil || len(userIDs) == 0 {
+		return
+	}
+	userConnMap := ctx.UserId2ConnInfo
+	pendingChannelReadsMu.Lock()
+	defer pendingChannelReadsMu.Unlock()
+	item := pendingChannelReads[channelID]
+	if item == nil {
+		item = &pendingChannelRead{userIDs: map[string]struct{}{}}
+		pendingChannelReads[channelID] = item
+		time.AfterFunc(channelReadCoalesceDelay, func() {
+			pendingChannelReadsMu.Lock()
+			delete(pendingChannelReads, channelID)
+			ids := lo.Keys(item.userIDs)
+			at := item.readAt
+			pendingChannelReadsMu.Unlock()
+			broadcastChannelReadUpdated(&ChatContext{UserId2ConnInfo: userConnMap}, channelID, ids, at)
+		})
+	}
+	for _, id := range userIDs {
+		item.userIDs[id] = struct{}{}
+	}
+	item.readAt = max(item.readAt, readAt)
+}
+
+func readReceiptErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrChannelTreeNotFound), errors.Is(err, service.ErrReadReceiptNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrReadReceiptsDisabled):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrReadReceiptPermission):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrWorldPermission):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "没有修改频道设置的权限"})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "操作失败"})
+	}
+}
+
+// ChannelReadReceiptsHandler 开启或关闭频道的已读回执
+func ChannelReadReceiptsHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "未登录"})
+	}
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "请求参数解析失败"})
+	}
+	channel, err := service.ChannelReadReceiptsSet(c.Params("channelId"), user.ID, req.Enabled)
+	if err != nil {
+		return readReceiptErrorResponse(c, err)
+	}
+	broadcastChannelUpdated(user, channel)
+	return c.JSON(fiber.Map{"channel": channel})
+}
+
+// MessageSeenByHandler 查看消息的已读名单
+func MessageSeenByHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "未登录"})
+	}
+	result, err := service.MessageSeenBy(c.Params("channelId"), c.Params("messageId"), user.ID)
+	if err != nil {
+		return readReceiptErrorResponse(c, err)
+	}
+	return c.JSON(result)
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/samber/lo"
+
+	"sealchat/model"
+	"sealchat/protocol"
+	"sealchat/utils"
+)
+
+func TestQueueChannelReadUpdatedCoalesces(t *testing.T) {
+	initTestDB(t)
+	ctx := &ChatContext{UserId2ConnInfo: userId2ConnInfoGlobal}
+	channelID := "rrq" + utils.NewIDWithLength(8)
+
+	queueChannelReadUpdated(ctx, channelID, []string{"u1", "u2"}, 100)
+	queueChannelReadUpdated(ctx, channelID, []string{"u2", "u3"}, 200)
+	queueChannelReadUpdated(ctx, channelID, []string{"u1"}, 150)
+
+	pendingChannelReadsMu.Lock()
+	defer pendingChannelReadsMu.Unlock()
+	item := pendingChannelReads[channelID]
+	if item == nil {
+		t.Fatalf("read updates should be pending")
+	}
+	if len(item.userIDs) != 3 || item.readAt != 200 {
+		t.Fatalf("updates should merge into one push: users=%v readAt=%d", item.userIDs, item.readAt)
+	}
+}
+
+type recordingAdapter struct {
+	mu     sync.Mutex
+	events []*protocol.Event
+}
+
+func (a *recordingAdapter) writeEvent(_ *WsSyncConn, data *protocol.Event) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.events = append(a.events, data)
+}
+
+func (a *recordingAdapter) count(eventType protocol.EventName) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(lo.Filter(a.events, func(e *protocol.Event, _ int) bool { return e.Type == eventType }))
+}
+
+func TestChannelReadUpdatedSkipsGuestsAndNonMembers(t *testing.T) {
+	f := seedBotFixture(t)
+	db := model.GetDB()
+	if err := db.Model(&model.ChannelModel{}).Where("id = ?", f.channelID).Update("read_receipts", true).Error; err != nil {
+		t.Fatalf("enable receipts failed: %v", err)
+	}
+	viewer := &model.UserModel{StringPKBaseModel: model.StringPKBaseModel{ID: "viewer" + utils.NewIDWithLength(8)}, Username: "viewer"}
+	if err := db.Create(viewer).Error; err != nil {
+		t.Fatalf("create viewer failed: %v", err)
+	}
+	guest := &model.UserModel{StringPKBaseModel: model.StringPKBaseModel{ID: "guest-" + utils.NewIDWithLength(12)}, Username: "guest"}
+
+	attach := func(user *model.UserModel, isGuest bool) *recordingAdapter {
+		adapter := &recordingAdapter{}
+		conn := &WsSyncConn{adapter: adapter}
+		connMap, _ := userId2ConnInfoGlobal.LoadOrStore(user.ID, &utils.SyncMap[*WsSyncConn, *ConnInfo]{})
+		connMap.Store(conn, &ConnInfo{Conn: conn, User: user, ChannelId: f.channelID, IsGuest: isGuest})
+		t.Cleanup(func() { connMap.Delete(conn) })
+		return adapter
+	}
+	member := attach(f.bot, false)
+	outsider := attach(viewer, false)
+	guestConn := attach(guest, true)
+
+	ctx := &ChatContext{UserId2ConnInfo: userId2ConnInfoGlobal}
+	broadcastChannelReadUpdated(ctx, f.channelID, []string{f.bot.ID}, 100)
+
+	if member.count(protocol.EventChannelReadUpdated) != 1 {
+		t.Fatalf("world member should receive the read update")
+	}
+	if guestConn.count(protocol.EventChannelReadUpdated) != 0 {
+		t.Fatalf("guest connection must not receive the read update")
+	}
+	if outsider.count(protocol.EventChannelReadUpdated) != 0 {
+		t.Fatalf("non-member viewer must not receive the read update")
+	}
+}
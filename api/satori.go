@@ -0,0 +1,662 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/protocol"
+	"sealchat/service"
+)
+
+// Satori 协议服务端，供 Koishi 等现成框架以标准方式接入：
+// HTTP: POST /satori/v1/{resource}.{method}，Authorization: Bearer <BOT 令牌>
+// 事件: GET /satori/v1/events，WebSocket 信令 IDENTIFY/READY/EVENT/PING/PONG
+// 世界对应 Satori 的 guild，频道对应 channel，私聊频道为 DIRECT 类型
+
+const (
+	satoriAdapterName    = "sealchat"
+	satoriMemberPageSize = 100
+)
+
+// satoriError 携带 HTTP 状态码，普通 error 按 400 处理
+type satoriError struct {
+	status  int
+	message string
+}
+
+func (e *satoriError) Error() string {
+	return e.message
+}
+
+func newSatoriError(status int, message string) error {
+	return &satoriError{status: status, message: message}
+}
+
+type satoriHandler func(ctx *ChatContext, body []byte) (any, error)
+
+// satoriHandle 将请求体解析为处理函数的参数，空请求体视为无参数
+func satoriHandle[T any](solve func(ctx *ChatContext, data *T) (any, error)) satoriHandler {
+	return func(ctx *ChatContext, body []byte) (any, error) {
+		data := new(T)
+		if len(bytes.TrimSpace(body)) > 0 {
+			if err := json.Unmarshal(body, data); err != nil {
+				return nil, newSatoriError(http.StatusBadRequest, "请求体格式错误")
+			}
+		}
+		return solve(ctx, data)
+	}
+}
+
+var satoriMethods = map[string]satoriHandler{
+	"login.get":           satoriHandle(satoriLoginGet),
+	"user.get":            satoriHandle(satoriUserGet),
+	"user.channel.create": satoriHandle(satoriUserChannelCreate),
+	"guild.list":          satoriHandle(satoriGuildList),
+	"guild.get":           satoriHandle(satoriGuildGet),
+	"guild.member.list":   satoriHandle(satoriGuildMemberList),
+	"guild.member.get":    satoriHandle(satoriGuildMemberGet),
+	"channel.list":        satoriHandle(satoriChannelList),
+	"channel.get":         satoriHandle(satoriChannelGet),
+	"message.create":      satoriHandle(satoriMessageCreate),
+	"message.get":         satoriHandle(satoriMessageGet),
+	"message.update":      satoriHandle(satoriMessageUpdate),
+	"message.delete":      satoriHandle(satoriMessageDelete),
+	"message.list":        satoriHandle(satoriMessageList),
+}
+
+// satoriFeatures 随 login 下发，声明已实现的可选接口
+var satoriFeatures = []string{
+	"guild.member.get",
+	"message.delete",
+	"message.get",
+	"message.list",
+	"message.update",
+	"user.channel.create",
+}
+
+func satoriWorks(app *fiber.App) {
+	app.Get("/satori/v1/events", func(c *fiber.Ctx) error {
+		if !websocket.IsWebSocketUpgrade(c) {
+			return fiber.ErrUpgradeRequired
+		}
+		return c.Next()
+	}, websocket.New(satoriEventsHandler, newWsUpgradeConfig()))
+
+	app.Post("/satori/v1/*", SatoriAuthMiddleware, satoriHTTPHandler)
+}
+
+// SatoriAuthMiddleware 校验 BOT 令牌；若请求带有 Satori-User-ID（旧版为 X-Self-ID），须与令牌对应的 BOT 一致
+func SatoriAuthMiddleware(c *fiber.Ctx) error {
+	token := getAuthorizationToken(c)
+	if len(token) != 32 {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "unauthorized",
+			"message": "missing bot token",
+		})
+	}
+	user, err := model.BotVerifyAccessToken(token)
+	if err != nil || user == nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "unauthorized",
+			"message": "invalid bot token",
+		})
+	}
+	selfID := strings.TrimSpace(c.Get("Satori-User-ID"))
+	if selfID == "" {
+		selfID = strings.TrimSpace(c.Get("X-Self-ID"))
+	}
+	if selfID != "" && selfID != user.ID {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error":   "forbidden",
+			"message": "self id mismatch",
+		})
+	}
+
+	_ = model.GetDB().Model(&model.BotTokenModel{}).
+		Where("id = ?", user.ID).
+		Update("recent_used_at", time.Now().UnixMilli()).Error
+
+	c.Locals("user", user)
+	return c.Next()
+}
+
+func satoriHTTPHandler(c *fiber.Ctx) error {
+	method := strings.TrimSpace(c.Params("*"))
+	handler, ok := satoriMethods[method]
+	if !ok {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error":   "not_found",
+			"message": "unknown method: " + method,
+		})
+	}
+
+	bot := getCurUser(c)
+	if scope, ok := botAPIScopes[method]; ok && !botTokenOf(bot.ID).HasScope(scope) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error":   "forbidden",
+			"message": "BOT 令牌无此权限",
+		})
+	}
+	ret, err := handler(newAdapterContext(bot), c.Body())
+	if err != nil {
+		status := http.StatusBadRequest
+		var se *satoriError
+		if errors.As(err, &se) {
+			status = se.status
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error":   strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_")),
+			"message": err.Error(),
+		})
+	}
+	if ret == nil {
+		return c.SendStatus(http.StatusOK)
+	}
+	return c.JSON(ret)
+}
+
+// newAdapterContext 标准协议的 HTTP 调用没有对应的 WS 连接，若 BOT 同时连着事件通道则复用其连接信息（暗骰待办等）
+func newAdapterContext(bot *model.UserModel) *ChatContext {
+	members, _ := model.MemberListByUserIDCached(bot.ID)
+	_, info := findAdapterConnection(bot.ID)
+	return &ChatContext{
+		User:            bot,
+		Members:         members,
+		ConnInfo:        info,
+		ChannelUsersMap: getChannelUsersMap(),
+		UserId2ConnInfo: getUserConnInfoMap(),
+	}
+}
+
+// 资源转换
+
+func satoriUserOf(user *protocol.User) *protocol.SatoriUser {
+	if user == nil {
+		return nil
+	}
+	return &protocol.SatoriUser{
+		ID:     user.ID,
+		Name:   user.Name,
+		Nick:   user.Nick,
+		Avatar: service.SatoriResourceURL(user.Avatar),
+		IsBot:  user.IsBot,
+	}
+}
+
+func satoriChannelOf(ch *protocol.Channel) *protocol.SatoriChannel {
+	if ch == nil {
+		return nil
+	}
+	ret := &protocol.SatoriChannel{ID: ch.ID, Name: ch.Name, ParentID: ch.ParentID}
+	switch ch.Type {
+	case protocol.DirectChannelType:
+		ret.Type = protocol.SatoriChannelDirect
+	case protocol.CategoryChannelType:
+		ret.Type = protocol.SatoriChannelCategory
+	case protocol.VoiceChannelType:
+		ret.Type = protocol.SatoriChannelVoice
+	default:
+		ret.Type = protocol.SatoriChannelText
+	}
+	return ret
+}
+
+func satoriGuildOf(world *model.WorldModel) *protocol.SatoriGuild {
+	if world == nil {
+		return nil
+	}
+	return &protocol.SatoriGuild{
+		ID:     world.ID,
+		Name:   world.Name,
+		Avatar: service.SatoriResourceURL(world.Avatar),
+	}
+}
+
+func satoriMemberOf(member *protocol.GuildMember) *protocol.SatoriGuildMember {
+	if member == nil {
+		return nil
+	}
+	return &protocol.SatoriGuildMember{
+		User:     satoriUserOf(member.User),
+		Nick:     member.Nick,
+		Avatar:   service.SatoriResourceURL(member.Avatar),
+		JoinedAt: member.JoinedAt,
+	}
+}
+
+func satoriMessageOf(msg *protocol.Message) *protocol.SatoriMessage {
+	if msg == nil {
+		return nil
+	}
+	ret := &protocol.SatoriMessage{
+		ID:        msg.ID,
+		Content:   service.ResolveSatoriResourceURLs(msg.Content),
+		Channel:   satoriChannelOf(msg.Channel),
+		User:      satoriUserOf(msg.User),
+		Member:    satoriMemberOf(msg.Member),
+		CreatedAt: msg.CreatedAt,
+		UpdatedAt: msg.UpdatedAt,
+	}
+	if msg.Channel != nil && msg.Channel.WorldID != "" {
+		ret.Guild = &protocol.SatoriGuild{ID: msg.Channel.WorldID}
+	}
+	if msg.Quote != nil {
+		ret.Quote = satoriMessageOf(msg.Quote)
+	}
+	return ret
+}
+
+func satoriLoginOf(bot *model.UserModel, status protocol.Status) *protocol.SatoriLogin {
+	return &protocol.SatoriLogin{
+		Platform: protocol.SatoriPlatform,
+		SelfID:   bot.ID,
+		User:     satoriUserOf(bot.ToProtocolType()),
+		Status:   status,
+		Adapter:  satoriAdapterName,
+		Features: satoriFeatures,
+	}
+}
+
+// 访问控制
+
+// botCanReadChannel 标准协议接入的 BOT 能否读取频道：公开频道按频道角色判断，私聊频道要求 BOT 是会话一方
+func botCanReadChannel(botID string, ch *model.ChannelModel) bool {
+	if ch == nil || ch.ID == "" {
+		return false
+	}
+	if ch.IsPrivate {
+		fr, _ := model.FriendRelationGetByID(ch.ID)
+		return fr != nil && fr.ID != "" && (fr.UserID1 == botID || fr.UserID2 == botID)
+	}
+	if ch.Status != "" && ch.Status != model.ChannelStatusActive {
+		return false
+	}
+	if !botTokenAllowsChannel(botTokenOf(botID), ch) {
+		return false
+	}
+	return pm.CanWithChannelRole(botID, ch.ID, pm.PermFuncChannelRead, pm.PermFuncChannelReadAll)
+}
+
+func satoriLoadChannel(botID, channelID string) (*model.ChannelModel, error) {
+	channelID = strings.TrimSpace(channelID)
+	if channelID == "" {
+		return nil, newSatoriError(http.StatusBadRequest, "channel_id 不能为空")
+	}
+	ch, _ := model.ChannelGet(channelID)
+	if !botCanReadChannel(botID, ch) {
+		return nil, newSatoriError(http.StatusNotFound, "频道不存在或无权访问")
+	}
+	return ch, nil
+}
+
+// botBoundChannelIDs BOT 通过频道角色绑定的频道
+func botBoundChannelIDs(botID string) []string {
+	roleIDs, _ := model.UserRoleMappingListByUserIDCached(botID, "", "channel")
+	return lo.Uniq(lo.Map(roleIDs, func(roleID string, _ int) string {
+		return model.ExtractChIdFromRoleId(roleID)
+	}))
+}
+
+// satoriBotWorldIDs BOT 可见的世界：加入的世界，以及被绑定频道所在的世界
+func satoriBotWorldIDs(botID string) []string {
+	db := model.GetDB()
+	var worldIDs []string
+	db.Model(&model.WorldMemberModel{}).Where("user_id = ?", botID).Pluck("world_id", &worldIDs)
+
+	if channelIDs := botBoundChannelIDs(botID); len(channelIDs) > 0 {
+		var boundWorldIDs []string
+		db.Model(&model.ChannelModel{}).
+			Where("id IN ? AND world_id <> ''", channelIDs).
+			Distinct().
+			Pluck("world_id", &boundWorldIDs)
+		worldIDs = append(worldIDs, boundWorldIDs...)
+	}
+	return lo.Uniq(lo.Compact(worldIDs))
+}
+
+func satoriLoadGuild(botID, guildID string) (*model.WorldModel, error) {
+	guildID = strings.TrimSpace(guildID)
+	if guildID == "" {
+		return nil, newSatoriError(http.StatusBadRequest, "guild_id 不能为空")
+	}
+	if !lo.Contains(satoriBotWorldIDs(botID), guildID) {
+		return nil, newSatoriError(http.StatusNotFound, "世界不存在或无权访问")
+	}
+	world, err := service.GetWorldByID(guildID)
+	if err != nil || world == nil || world.Status != "active" {
+		return nil, newSatoriError(http.StatusNotFound, "世界不存在或无权访问")
+	}
+	return world, nil
+}
+
+func satoriParseOffset(next string) (int, error) {
+	if strings.TrimSpace(next) == "" {
+		return 0, nil
+	}
+	offset, err := strconv.Atoi(next)
+	if err != nil || offset < 0 {
+		return 0, newSatoriError(http.StatusBadRequest, "next 参数无效")
+	}
+	return offset, nil
+}
+
+// 接口实现
+
+func satoriLoginGet(ctx *ChatContext, _ *struct{}) (any, error) {
+	return satoriLoginOf(ctx.User, protocol.StatusOnline), nil
+}
+
+func satoriUserGet(ctx *ChatContext, data *struct {
+	UserID string `json:"user_id"`
+}) (any, error) {
+	user := model.UserGet(strings.TrimSpace(data.UserID))
+	if user == nil || user.ID == "" {
+		return nil, newSatoriError(http.StatusNotFound, "用户不存在")
+	}
+	return satoriUserOf(user.ToProtocolType()), nil
+}
+
+// botPrivateChannel 复用 channel.private.create 建立与用户的私聊频道
+func botPrivateChannel(ctx *ChatContext, userID string) (*protocol.Channel, error) {
+	ret, err := apiInvoke(ctx, apiChannelPrivateCreate, map[string]any{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	// apiChannelPrivateCreate 以匿名结构返回，失败时带 code/msg
+	raw, _ := json.Marshal(ret)
+	var result struct {
+		Code    int               `json:"code"`
+		Msg     string            `json:"msg"`
+		Channel *protocol.Channel `json:"channel"`
+	}
+	_ = json.Unmarshal(raw, &result)
+	if result.Channel == nil {
+		if result.Msg == "" {
+			return nil, errors.New("创建私聊频道失败")
+		}
+		return nil, errors.New(result.Msg)
+	}
+	return result.Channel, nil
+}
+
+func satoriUserChannelCreate(ctx *ChatContext, data *struct {
+	UserID string `json:"user_id"`
+}) (any, error) {
+	ch, err := botPrivateChannel(ctx, strings.TrimSpace(data.UserID))
+	if err != nil {
+		return nil, err
+	}
+	return satoriChannelOf(ch), nil
+}
+
+func satoriGuildList(ctx *ChatContext, data *struct {
+	Next string `json:"next"`
+}) (any, error) {
+	ret := &protocol.SatoriList[*protocol.SatoriGuild]{Data: []*protocol.SatoriGuild{}}
+	worldIDs := satoriBotWorldIDs(ctx.User.ID)
+	if len(worldIDs) == 0 {
+		return ret, nil
+	}
+	var worlds []*model.WorldModel
+	model.GetDB().Where("id IN ? AND status = ?", worldIDs, "active").Order("created_at asc").Find(&worlds)
+	for _, world := range worlds {
+		ret.Data = append(ret.Data, satoriGuildOf(world))
+	}
+	return ret, nil
+}
+
+func satoriGuildGet(ctx *ChatContext, data *struct {
+	GuildID string `json:"guild_id"`
+}) (any, error) {
+	world, err := satoriLoadGuild(ctx.User.ID, data.GuildID)
+	if err != nil {
+		return nil, err
+	}
+	return satoriGuildOf(world), nil
+}
+
+func satoriWorldMemberOf(member *model.WorldMemberModel, user *model.UserModel) *protocol.SatoriGuildMember {
+	ret := &protocol.SatoriGuildMember{JoinedAt: member.JoinedAt.UnixMilli()}
+	if user != nil {
+		ret.User = satoriUserOf(user.ToProtocolType())
+		ret.Nick = user.Nickname
+	} else {
+		ret.User = &protocol.SatoriUser{ID: member.UserID}
+	}
+	return ret
+}
+
+func satoriGuildMemberList(ctx *ChatContext, data *struct {
+	GuildID string `json:"guild_id"`
+	Next    string `json:"next"`
+}) (any, error) {
+	world, err := satoriLoadGuild(ctx.User.ID, data.GuildID)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := satoriParseOffset(data.Next)
+	if err != nil {
+		return nil, err
+	}
+
+	db := model.GetDB()
+	var members []*model.WorldMemberModel
+	db.Where("world_id = ?", world.ID).
+		Order("joined_at asc").
+		Order("id asc").
+		Offset(offset).
+		Limit(satoriMemberPageSize + 1).
+		Find(&members)
+
+	ret := &protocol.SatoriList[*protocol.SatoriGuildMember]{Data: []*protocol.SatoriGuildMember{}}
+	if len(members) > satoriMemberPageSize {
+		members = members[:satoriMemberPageSize]
+		ret.Next = strconv.Itoa(offset + satoriMemberPageSize)
+	}
+	if len(members) == 0 {
+		return ret, nil
+	}
+
+	userIDs := lo.Map(members, func(m *model.WorldMemberModel, _ int) string { return m.UserID })
+	var users []*model.UserModel
+	db.Select("id, username, nickname, avatar, is_bot").Where("id IN ?", userIDs).Find(&users)
+	id2User := lo.KeyBy(users, func(u *model.UserModel) string { return u.ID })
+	for _, member := range members {
+		ret.Data = append(ret.Data, satoriWorldMemberOf(member, id2User[member.UserID]))
+	}
+	return ret, nil
+}
+
+func satoriGuildMemberGet(ctx *ChatContext, data *struct {
+	GuildID string `json:"guild_id"`
+	UserID  string `json:"user_id"`
+}) (any, error) {
+	world, err := satoriLoadGuild(ctx.User.ID, data.GuildID)
+	if err != nil {
+		return nil, err
+	}
+	var member model.WorldMemberModel
+	model.GetDB().Where("world_id = ? AND user_id = ?", world.ID, strings.TrimSpace(data.UserID)).Limit(1).Find(&member)
+	if member.ID == "" {
+		return nil, newSatoriError(http.StatusNotFound, "成员不存在")
+	}
+	return satoriWorldMemberOf(&member, model.UserGet(member.UserID)), nil
+}
+
+func satoriChannelList(ctx *ChatContext, data *struct {
+	GuildID string `json:"guild_id"`
+	Next    string `json:"next"`
+}) (any, error) {
+	world, err := satoriLoadGuild(ctx.User.ID, data.GuildID)
+	if err != nil {
+		return nil, err
+	}
+	var channels []*model.ChannelModel
+	model.GetDB().
+		Where("world_id = ? AND is_private = ? AND status = ?", world.ID, false, model.ChannelStatusActive).
+		Order("created_at asc").
+		Find(&channels)
+
+	ret := &protocol.SatoriList[*protocol.SatoriChannel]{Data: []*protocol.SatoriChannel{}}
+	for _, ch := range channels {
+		if botCanReadChannel(ctx.User.ID, ch) {
+			ret.Data = append(ret.Data, satoriChannelOf(ch.ToProtocolType()))
+		}
+	}
+	return ret, nil
+}
+
+func satoriChannelGet(ctx *ChatContext, data *struct {
+	ChannelID string `json:"channel_id"`
+}) (any, error) {
+	ch, err := satoriLoadChannel(ctx.User.ID, data.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+	return satoriChannelOf(ch.ToProtocolType()), nil
+}
+
+func satoriMessageCreate(ctx *ChatContext, data *struct {
+	ChannelID string `json:"channel_id"`
+	Content   string `json:"content"`
+}) (any, error) {
+	if _, err := satoriLoadChannel(ctx.User.ID, data.ChannelID); err != nil {
+		return nil, err
+	}
+	ret, err := apiInvoke(ctx, apiMessageCreate, map[string]any{
+		"channel_id": data.ChannelID,
+		"content":    data.Content,
+	})
+	if err != nil {
+		return nil, err
+	}
+	msg, ok := ret.(*protocol.Message)
+	if !ok || msg == nil {
+		return nil, newSatoriError(http.StatusForbidden, "无权在该频道发送消息")
+	}
+	return []*protocol.SatoriMessage{satoriMessageOf(msg)}, nil
+}
+
+func satoriMessageGet(ctx *ChatContext, data *struct {
+	ChannelID string `json:"channel_id"`
+	MessageID string `json:"message_id"`
+}) (any, error) {
+	ch, err := satoriLoadChannel(ctx.User.ID, data.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+	// 悄悄话可见性沿用 message.get 的判断
+	ret, err := apiInvoke(ctx, apiMessageGet, data)
+	if err != nil {
+		return nil, err
+	}
+	if ret == nil {
+		return nil, newSatoriError(http.StatusNotFound, "消息不存在")
+	}
+
+	var item model.MessageModel
+	model.GetDB().Where("channel_id = ? AND id = ?", ch.ID, data.MessageID).
+		Preload("User", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, username, nickname, avatar, is_bot")
+		}).
+		Preload("Member", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, nickname, channel_id")
+		}).
+		Limit(1).
+		Find(&item)
+	if item.ID == "" {
+		return nil, newSatoriError(http.StatusNotFound, "消息不存在")
+	}
+	maskGMRollsForUser(ctx.User.ID, ch, ch.ID, []*model.MessageModel{&item})
+	return satoriMessageOf(buildProtocolMessage(&item, ch.ToProtocolType())), nil
+}
+
+func satoriMessageUpdate(ctx *ChatContext, data *struct {
+	ChannelID string `json:"channel_id"`
+	MessageID string `json:"message_id"`
+	Content   string `json:"content"`
+}) (any, error) {
+	if _, err := satoriLoadChannel(ctx.User.ID, data.ChannelID); err != nil {
+		return nil, err
+	}
+	ret, err := apiInvoke(ctx, apiMessageUpdate, data)
+	if err != nil {
+		return nil, err
+	}
+	if ret == nil {
+		return nil, newSatoriError(http.StatusForbidden, "无权编辑该消息")
+	}
+	return nil, nil
+}
+
+func satoriMessageDelete(ctx *ChatContext, data *struct {
+	ChannelID string `json:"channel_id"`
+	MessageID string `json:"message_id"`
+}) (any, error) {
+	if _, err := satoriLoadChannel(ctx.User.ID, data.ChannelID); err != nil {
+		return nil, err
+	}
+	ret, err := apiInvoke(ctx, apiMessageDelete, data)
+	if err != nil {
+		return nil, err
+	}
+	if ret == nil {
+		return nil, newSatoriError(http.StatusForbidden, "无权撤回该消息")
+	}
+	return nil, nil
+}
+
+// satoriMessageList 仅支持向前翻页（direction=before），prev 为更早一页的游标
+func satoriMessageList(ctx *ChatContext, data *struct {
+	ChannelID string `json:"channel_id"`
+	Next      string `json:"next"`
+	Direction string `json:"direction"`
+	Order     string `json:"order"`
+}) (any, error) {
+	ch, err := satoriLoadChannel(ctx.User.ID, data.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+	if direction := strings.ToLower(strings.TrimSpace(data.Direction)); direction != "" && direction != "before" {
+		return nil, newSatoriError(http.StatusBadRequest, "message.list 仅支持 direction=before")
+	}
+	ret, err := apiInvoke(ctx, apiMessageList, map[string]any{
+		"channel_id": ch.ID,
+		"next":       data.Next,
+	})
+	if err != nil {
+		return nil, err
+	}
+	result, ok := ret.(*messageListResult)
+	if !ok || result == nil {
+		return nil, newSatoriError(http.StatusNotFound, "频道不存在或无权访问")
+	}
+
+	channelData := ch.ToProtocolType()
+	list := &protocol.SatoriBidiList[*protocol.SatoriMessage]{
+		Data: make([]*protocol.SatoriMessage, 0, len(result.Data)),
+		Prev: result.Next,
+	}
+	for _, item := range result.Data {
+		if item.IsDeleted || item.IsRevoked {
+			continue
+		}
+		list.Data = append(list.Data, satoriMessageOf(buildProtocolMessage(item, channelData)))
+	}
+	if strings.EqualFold(strings.TrimSpace(data.Order), "desc") {
+		list.Data = lo.Reverse(list.Data)
+	}
+	return list, nil
+}
@@ -0,0 +1,237 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+
+	"sealchat/model"
+	"sealchat/protocol"
+	"sealchat/service/metrics"
+	"sealchat/utils"
+)
+
+// satoriIdentifyTimeout 连接建立后须在此时间内发送 IDENTIFY
+const satoriIdentifyTimeout = 10 * time.Second
+
+// satoriSession 挂在 WsSyncConn.adapter 上，负责把站内事件转换为 Satori 标准事件
+type satoriSession struct {
+	bot *model.UserModel
+	sn  atomic.Int64
+}
+
+// satoriEventTypes 站内事件到 Satori 标准事件的映射，未列出的事件不推送
+var satoriEventTypes = map[protocol.EventName]string{
+	protocol.EventMessageCreated:  "message-created",
+	protocol.EventMessageUpdated:  "message-updated",
+	protocol.EventMessageDeleted:  "message-deleted",
+	protocol.EventReactionAdded:   "reaction-added",
+	protocol.EventReactionDeleted: "reaction-removed",
+
+	protocol.EventInteractionCommand: "interaction/command",
+}
+
+func (s *satoriSession) buildEvent(data *protocol.Event) *protocol.SatoriEvent {
+	eventType, ok := satoriEventTypes[data.Type]
+	if !ok {
+		return nil
+	}
+	sn := s.sn.Add(1)
+	timestamp := data.Timestamp * 1000 // 站内事件时间戳为秒，Satori 为毫秒
+	if timestamp == 0 {
+		timestamp = time.Now().UnixMilli()
+	}
+	ev := &protocol.SatoriEvent{
+		Sn:        sn,
+		ID:        sn,
+		Type:      eventType,
+		Platform:  protocol.SatoriPlatform,
+		SelfID:    s.bot.ID,
+		Timestamp: timestamp,
+		Login:     satoriLoginOf(s.bot, protocol.StatusOnline),
+		Channel:   satoriChannelOf(data.Channel),
+		Member:    satoriMemberOf(data.Member),
+		Message:   satoriMessageOf(data.Message),
+		Operator:  satoriUserOf(data.Operator),
+		User:      satoriUserOf(data.User),
+	}
+	if ev.Message != nil && ev.Message.Channel == nil {
+		ev.Message.Channel = ev.Channel
+	}
+	if data.Argv != nil {
+		ev.Argv = &protocol.SatoriArgv{Name: data.Argv.Name, Arguments: data.Argv.Arguments, Options: data.Argv.Options}
+	}
+	if data.Channel != nil && data.Channel.WorldID != "" {
+		ev.Guild = &protocol.SatoriGuild{ID: data.Channel.WorldID}
+	}
+	return ev
+}
+
+func (s *satoriSession) writeEvent(conn *WsSyncConn, data *protocol.Event) {
+	ev := s.buildEvent(data)
+	if ev == nil {
+		return
+	}
+	_ = conn.writeRaw(protocol.GatewayPayloadStructure{Op: protocol.OpEvent, Body: ev})
+}
+
+// findAdapterConnection 返回本实例上该 BOT 最近活跃的标准协议连接
+func findAdapterConnection(botID string) (*WsSyncConn, *ConnInfo) {
+	if userId2ConnInfoGlobal == nil || botID == "" {
+		return nil, nil
+	}
+	x, ok := userId2ConnInfoGlobal.Load(botID)
+	if !ok {
+		return nil, nil
+	}
+	var activeConn *WsSyncConn
+	var activeInfo *ConnInfo
+	var activeAt int64 = -1
+	x.Range(func(conn *WsSyncConn, value *ConnInfo) bool {
+		if value == nil || conn.adapter == nil {
+			return true
+		}
+		if value.LastAliveTime > activeAt {
+			activeAt = value.LastAliveTime
+			activeConn = conn
+			activeInfo = value
+		}
+		return true
+	})
+	return activeConn, activeInfo
+}
+
+// satoriIdentify 校验 BOT 令牌并登记连接。同一 BOT 只保留最新的一条 Satori 连接，
+// 其 /ws/seal、OneBot 等其他协议的连接不受影响
+func satoriIdentify(c *WsSyncConn, body json.RawMessage) (*model.UserModel, *ConnInfo) {
+	var identify protocol.SatoriIdentify
+	if err := json.Unmarshal(body, &identify); err != nil {
+		return nil, nil
+	}
+	token := strings.TrimSpace(identify.Token)
+	if len(token) != 32 {
+		return nil, nil
+	}
+	bot, err := model.BotVerifyAccessToken(token)
+	if err != nil || bot == nil {
+		return nil, nil
+	}
+
+	userId2ConnInfo := getUserConnInfoMap()
+	m, _ := userId2ConnInfo.LoadOrStore(bot.ID, &utils.SyncMap[*WsSyncConn, *ConnInfo]{})
+	closedCount := 0
+	m.Range(func(conn *WsSyncConn, _ *ConnInfo) bool {
+		conn.Mux.RLock()
+		_, isSatori := conn.adapter.(*satoriSession)
+		conn.Mux.RUnlock()
+		if !isSatori || conn == c {
+			return true
+		}
+		// 劫持的连接 Close 不会立即断开，先通知对端并打断旧连接上阻塞的读取
+		_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "replaced"), time.Now().Add(time.Second))
+		_ = conn.SetReadDeadline(time.Now())
+		conn.Close()
+		m.Delete(conn)
+		closedCount++
+		if collector := metrics.Get(); collector != nil {
+			collector.RecordConnectionClosed(bot.ID)
+		}
+		return true
+	})
+	if closedCount > 0 {
+		log.Printf("[Satori] bot %s 旧连接已清理: %d", bot.ID, closedCount)
+	}
+
+	now := time.Now().UnixMilli()
+	c.Mux.Lock()
+	c.adapter = &satoriSession{bot: bot}
+	c.botID = bot.ID
+	c.Mux.Unlock()
+	info := &ConnInfo{
+		Conn:          c,
+		LastPingTime:  now,
+		LastAliveTime: now,
+		User:          bot,
+		TypingState:   protocol.TypingStateSilent,
+		TypingIcMode:  "ic",
+	}
+	m.Store(c, info)
+	if collector := metrics.Get(); collector != nil {
+		collector.RecordConnectionOpened(bot.ID)
+		collector.RecordUserHeartbeat(bot.ID)
+	}
+	return bot, info
+}
+
+func satoriEventsHandler(rawConn *websocket.Conn) {
+	c := newWsSyncConn(rawConn)
+	var (
+		bot      *model.UserModel
+		connInfo *ConnInfo
+	)
+	_ = rawConn.SetReadDeadline(time.Now().Add(satoriIdentifyTimeout))
+
+	for {
+		_, msg, err := c.ReadMessage()
+		if err != nil {
+			break
+		}
+		var payload struct {
+			Op   protocol.Opcode `json:"op"`
+			Body json.RawMessage `json:"body"`
+		}
+		if err := json.Unmarshal(msg, &payload); err != nil {
+			continue
+		}
+		if connInfo != nil {
+			connInfo.LastAliveTime = time.Now().UnixMilli()
+		}
+
+		switch payload.Op {
+		case protocol.OpIdentify:
+			if bot != nil {
+				continue
+			}
+			// 不支持按 sn 补发事件，重连后从新事件开始推送
+			bot, connInfo = satoriIdentify(c, payload.Body)
+			if bot == nil {
+				_ = c.writeRaw(protocol.GatewayPayloadStructure{
+					Op:   protocol.OpReady,
+					Body: map[string]any{"errorMsg": "no auth"},
+				})
+				_ = c.Close()
+				return
+			}
+			_ = rawConn.SetReadDeadline(time.Time{})
+			_ = c.writeRaw(protocol.GatewayPayloadStructure{
+				Op: protocol.OpReady,
+				Body: &protocol.SatoriReady{
+					Logins:    []*protocol.SatoriLogin{satoriLoginOf(bot, protocol.StatusOnline)},
+					ProxyURLs: []string{},
+				},
+			})
+		case protocol.OpPing:
+			if connInfo != nil {
+				connInfo.LastPingTime = connInfo.LastAliveTime
+				if collector := metrics.Get(); collector != nil {
+					collector.RecordUserHeartbeat(bot.ID)
+				}
+			}
+			_ = c.writeRaw(protocol.GatewayPayloadStructure{Op: protocol.OpPong})
+		}
+	}
+
+	if bot == nil {
+		return
+	}
+	if collector := metrics.Get(); collector != nil {
+		collector.RecordConnectionClosed(bot.ID)
+	}
+	if m, ok := getUserConnInfoMap().Load(bot.ID); ok {
+		m.Delete(c)
+	}
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	fastws "github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+
+	"sealchat/protocol"
+	"sealchat/utils"
+)
+
+func newSatoriTestApp() *fiber.App {
+	app := fiber.New()
+	satoriWorks(app)
+	return app
+}
+
+func satoriCall(t *testing.T, app *fiber.App, f *botFixture, method string, body any) (int, []byte) {
+	t.Helper()
+	raw, _ := json.Marshal(body)
+	req, _ := http.NewRequest(http.MethodPost, "/satori/v1/"+method, bytes.NewReader(raw))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+f.token)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s request failed: %v", method, err)
+	}
+	defer resp.Body.Close()
+	var buf bytes.Buffer
+	_, _ = buf.ReadFrom(resp.Body)
+	return resp.StatusCode, buf.Bytes()
+}
+
+func TestSatoriMessageCreateAndGet(t *testing.T) {
+	f := seedBotFixture(t)
+	app := newSatoriTestApp()
+
+	status, body := satoriCall(t, app, f, "message.create", map[string]any{"channel_id": f.channelID, "content": "hello <b>world</b>"})
+	if status != http.StatusOK {
+		t.Fatalf("message.create status %d: %s", status, body)
+	}
+	var created []*protocol.SatoriMessage
+	if err := json.Unmarshal(body, &created); err != nil || len(created) != 1 || created[0].ID == "" {
+		t.Fatalf("unexpected message.create body: %s", body)
+	}
+
+	status, body = satoriCall(t, app, f, "message.get", map[string]any{"channel_id": f.channelID, "message_id": created[0].ID})
+	if status != http.StatusOK {
+		t.Fatalf("message.get status %d: %s", status, body)
+	}
+	var got protocol.SatoriMessage
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("decode message.get failed: %v", err)
+	}
+	if got.ID != created[0].ID || got.Content != created[0].Content || got.Channel == nil || got.Channel.ID != f.channelID {
+		t.Fatalf("message.get mismatch: %+v vs %+v", got, created[0])
+	}
+	if got.User == nil || got.User.ID != f.bot.ID {
+		t.Fatalf("message.get should carry the author: %+v", got.User)
+	}
+
+	// 无权访问的频道按不存在处理
+	status, _ = satoriCall(t, app, f, "message.get", map[string]any{"channel_id": "missing" + utils.NewIDWithLength(6), "message_id": created[0].ID})
+	if status != http.StatusNotFound {
+		t.Fatalf("unknown channel should be 404, got %d", status)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "/satori/v1/message.get", bytes.NewReader([]byte(`{}`)))
+	req.Header.Set("Authorization", "Bearer "+utils.NewIDWithLength(32))
+	if resp, err := app.Test(req, -1); err != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("invalid token should be rejected: %v", err)
+	}
+}
+
+func dialSatoriEvents(t *testing.T, addr string) *fastws.Conn {
+	t.Helper()
+	conn, _, err := fastws.DefaultDialer.Dial("ws://"+addr+"/satori/v1/events", nil)
+	if err != nil {
+		t.Fatalf("dial events failed: %v", err)
+	}
+	return conn
+}
+
+func satoriIdentifyOver(t *testing.T, conn *fastws.Conn, token string) map[string]json.RawMessage {
+	t.Helper()
+	if err := conn.WriteJSON(map[string]any{"op": protocol.OpIdentify, "body": protocol.SatoriIdentify{Token: token}}); err != nil {
+		t.Fatalf("send identify failed: %v", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var payload map[string]json.RawMessage
+	if err := conn.ReadJSON(&payload); err != nil {
+		t.Fatalf("read ready failed: %v", err)
+	}
+	return payload
+}
+
+func TestSatoriIdentify(t *testing.T) {
+	f := seedBotFixture(t)
+	app := newSatoriTestApp()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	go func() { _ = app.Listener(ln) }()
+	defer func() { _ = app.Shutdown() }()
+	addr := ln.Addr().String()
+
+	// 同一 BOT 的 /ws/seal 连接不应被 Satori 的重连顶掉
+	sealConn := &WsSyncConn{botID: f.bot.ID}
+	connMap, _ := getUserConnInfoMap().LoadOrStore(f.bot.ID, &utils.SyncMap[*WsSyncConn, *ConnInfo]{})
+	connMap.Store(sealConn, &ConnInfo{Conn: sealConn, User: f.bot})
+
+	bad := dialSatoriEvents(t, addr)
+	payload := satoriIdentifyOver(t, bad, utils.NewIDWithLength(32))
+	if string(payload["op"]) != "4" || !bytes.Contains(payload["body"], []byte("no auth")) {
+		t.Fatalf("invalid token should get an error ready: %v", payload)
+	}
+	_ = bad.Close()
+
+	first := dialSatoriEvents(t, addr)
+	defer first.Close()
+	payload = satoriIdentifyOver(t, first, f.token)
+	var ready protocol.SatoriReady
+	if err := json.Unmarshal(payload["body"], &ready); err != nil || len(ready.Logins) != 1 {
+		t.Fatalf("unexpected ready: %s", payload["body"])
+	}
+	if login := ready.Logins[0]; login.SelfID != f.bot.ID || login.Status != protocol.StatusOnline || login.Platform == "" {
+		t.Fatalf("unexpected login: %+v", login)
+	}
+
+	second := dialSatoriEvents(t, addr)
+	defer second.Close()
+	satoriIdentifyOver(t, second, f.token)
+	_ = first.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := first.ReadMessage(); err == nil {
+		t.Fatalf("previous satori connection should be closed")
+	} else if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		t.Fatalf("previous satori connection was left open: %v", err)
+	}
+
+	if _, ok := connMap.Load(sealConn); !ok {
+		t.Fatalf("/ws/seal connection should survive a satori identify")
+	}
+	satoriConns := 0
+	connMap.Range(func(conn *WsSyncConn, _ *ConnInfo) bool {
+		if _, ok := conn.adapter.(*satoriSession); ok {
+			satoriConns++
+		}
+		return true
+	})
+	if satoriConns != 1 {
+		t.Fatalf("expected one satori connection, got %d", satoriConns)
+	}
+}
@@ -26,6 +26,8 @@ type statusSummary struct {
 	AttachmentBytes       int64 `json:"attachmentBytes"`
 	IntervalSeconds       int   `json:"intervalSeconds"`
 	RetentionDays         int   `json:"retentionDays"`
+	// PermCache 权限与成员缓存的命中情况，仅反映当前实例
+	PermCache []model.PermCacheStat `json:"permCache,omitempty"`
 }
 
 type statusHistoryResponse struct {
@@ -155,6 +157,7 @@ func buildSummary(sample *model.ServiceMetricSample, collector *metrics.Collecto
 		AttachmentBytes:       sample.AttachmentBytes,
 		IntervalSeconds:       intervalSeconds,
 		RetentionDays:         retentionDays,
+		PermCache:             model.PermCacheStats(),
 	}
 }
 
@@ -12,6 +12,7 @@ import (
 
 	"sealchat/model"
 	"sealchat/protocol"
+	"sealchat/service"
 	"sealchat/utils"
 )
 
@@ -22,31 +23,35 @@ const stickyNoteEditingLockTTL = 10 * time.Second
 // BindStickyNoteRoutes 绑定便签相关的 REST 路由
 // 接收已认证的路由组 (v1Auth)
 func BindStickyNoteRoutes(group fiber.Router) {
+	// 空前缀的 Group 中间件会作用于其后注册的所有路由，这里逐条挂载
+	scoped := BotScopeMiddleware(model.BotScopeStickyNote)
+	// 会改动频道内便签内容的接口额外检查禁言
+	speak := WorldSpeakMiddleware
 	// 通过频道获取便签列表
-	group.Get("/channels/:channelId/sticky-notes", apiChannelStickyNoteList)
+	group.Get("/channels/:channelId/sticky-notes", scoped, apiChannelStickyNoteList)
 	// 创建便签
-	group.Post("/channels/:channelId/sticky-notes", apiChannelStickyNoteCreate)
+	group.Post("/channels/:channelId/sticky-notes", scoped, speak, apiChannelStickyNoteCreate)
 	// 迁移/复制便签
-	group.Post("/channels/:channelId/sticky-notes/migrate", apiChannelStickyNoteMigrate)
+	group.Post("/channels/:channelId/sticky-notes/migrate", scoped, speak, apiChannelStickyNoteMigrate)
 	// 获取单个便签
-	group.Get("/sticky-notes/:noteId", apiStickyNoteGet)
+	group.Get("/sticky-notes/:noteId", scoped, apiStickyNoteGet)
 	// 获取编辑锁
-	group.Post("/sticky-notes/:noteId/edit-lock/acquire", apiStickyNoteEditLockAcquire)
-	group.Post("/sticky-notes/:noteId/edit-lock/release", apiStickyNoteEditLockRelease)
+	group.Post("/sticky-notes/:noteId/edit-lock/acquire", scoped, apiStickyNoteEditLockAcquire)
+	group.Post("/sticky-notes/:noteId/edit-lock/release", scoped, apiStickyNoteEditLockRelease)
 	// 更新便签
-	group.Patch("/sticky-notes/:noteId", apiStickyNoteUpdateRest)
+	group.Patch("/sticky-notes/:noteId", scoped, speak, apiStickyNoteUpdateRest)
 	// 删除便签
-	group.Delete("/sticky-notes/:noteId", apiStickyNoteDeleteRest)
+	group.Delete("/sticky-notes/:noteId", scoped, speak, apiStickyNoteDeleteRest)
 	// 更新用户状态
-	group.Patch("/sticky-notes/:noteId/state", apiStickyNoteUserStateUpdate)
+	group.Patch("/sticky-notes/:noteId/state", scoped, apiStickyNoteUserStateUpdate)
 	// 推送便签
-	group.Post("/sticky-notes/:noteId/push", apiStickyNotePushRest)
+	group.Post("/sticky-notes/:noteId/push", scoped, speak, apiStickyNotePushRest)
 
 	// 文件夹相关
-	group.Get("/channels/:channelId/sticky-note-folders", apiChannelStickyNoteFolderList)
-	group.Post("/channels/:channelId/sticky-note-folders", apiChannelStickyNoteFolderCreate)
-	group.Patch("/sticky-note-folders/:folderId", apiStickyNoteFolderUpdate)
-	group.Delete("/sticky-note-folders/:folderId", apiStickyNoteFolderDelete)
+	group.Get("/channels/:channelId/sticky-note-folders", scoped, apiChannelStickyNoteFolderList)
+	group.Post("/channels/:channelId/sticky-note-folders", scoped, speak, apiChannelStickyNoteFolderCreate)
+	group.Patch("/sticky-note-folders/:folderId", scoped, speak, apiStickyNoteFolderUpdate)
+	group.Delete("/sticky-note-folders/:folderId", scoped, speak, apiStickyNoteFolderDelete)
 }
 
 // getStickyNoteUser 获取当前用户
@@ -965,6 +970,9 @@ func apiStickyNoteUpdateWs(ctx *ChatContext, data *struct {
 	if err != nil {
 		return nil, fmt.Errorf("note not found")
 	}
+	if err := service.ChannelSpeakCheck(note.ChannelID, ctx.User.ID, ctx.RemoteIP()); err != nil {
+		return nil, err
+	}
 
 	updates := map[string]interface{}{
 		"title":        data.Title,
@@ -1003,6 +1011,9 @@ func apiStickyNoteDeleteWs(ctx *ChatContext, data *struct {
 	if err != nil {
 		return nil, fmt.Errorf("note not found")
 	}
+	if err := service.ChannelSpeakCheck(note.ChannelID, ctx.User.ID, ctx.RemoteIP()); err != nil {
+		return nil, err
+	}
 
 	channelID := note.ChannelID
 
@@ -1038,6 +1049,9 @@ func apiStickyNotePushWs(ctx *ChatContext, data *struct {
 	if err != nil {
 		return nil, fmt.Errorf("note not found")
 	}
+	if err := service.ChannelSpeakCheck(note.ChannelID, ctx.User.ID, ctx.RemoteIP()); err != nil {
+		return nil, err
+	}
 
 	note.LoadCreator()
 
@@ -1067,27 +1081,7 @@ func BroadcastStickyNoteToChannel(channelID string, eventType protocol.EventName
 		StickyNote: payload,
 		Timestamp:  time.Now().UnixMilli(),
 	}
-
-	userConnMap := getUserConnInfoMap()
-	if userConnMap == nil {
-		return
-	}
-
-	userConnMap.Range(func(userID string, connMap *utils.SyncMap[*WsSyncConn, *ConnInfo]) bool {
-		connMap.Range(func(conn *WsSyncConn, info *ConnInfo) bool {
-			if info.ChannelId == channelID {
-				_ = conn.WriteJSON(struct {
-					protocol.Event
-					Op protocol.Opcode `json:"op"`
-				}{
-					Event: *event,
-					Op:    protocol.OpEvent,
-				})
-			}
-			return true
-		})
-		return true
-	})
+	emitChannelEvent(getUserConnInfoMap(), busBroadcastChannel, channelID, nil, event)
 }
 
 // BroadcastStickyNoteToUsers 广播便签事件到指定用户
@@ -1100,29 +1094,24 @@ func BroadcastStickyNoteToUsers(userIDs []string, eventType protocol.EventName,
 	if payload != nil && payload.Note != nil && payload.Note.ChannelID != "" {
 		event.Channel = &protocol.Channel{ID: payload.Note.ChannelID}
 	}
+	emitUserEvent(getUserConnInfoMap(), userIDs, event)
+}
 
+// deliverEventToUsers 推送给指定用户的所有连接，不限当前所在频道
+func deliverEventToUsers(userConnMap *userConnInfoMap, userIDs []string, event *protocol.Event) {
+	if userConnMap == nil {
+		return
+	}
 	targetSet := make(map[string]bool)
 	for _, id := range userIDs {
 		targetSet[id] = true
 	}
-
-	userConnMap := getUserConnInfoMap()
-	if userConnMap == nil {
-		return
-	}
-
 	userConnMap.Range(func(userID string, connMap *utils.SyncMap[*WsSyncConn, *ConnInfo]) bool {
 		if !targetSet[userID] {
 			return true
 		}
 		connMap.Range(func(conn *WsSyncConn, info *ConnInfo) bool {
-			_ = conn.WriteJSON(struct {
-				protocol.Event
-				Op protocol.Opcode `json:"op"`
-			}{
-				Event: *event,
-				Op:    protocol.OpEvent,
-			})
+			writeEventFrame(conn, event)
 			return true
 		})
 		return true
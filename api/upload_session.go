@@ -0,0 +1,298 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/service"
+)
+
+// 参考 tus 协议：HEAD 查询偏移，PATCH 携带 Upload-Offset 追加分片
+const (
+	headerUploadOffset = "Upload-Offset"
+	headerUploadLength = "Upload-Length"
+	// tus 约定的校验失败状态码
+	statusUploadChecksumMismatch = 460
+)
+
+type uploadSessionCreateBody struct {
+	Filename  string `json:"filename"`
+	Size      int64  `json:"size"`
+	MimeType  string `json:"mimeType"`
+	SHA256    string `json:"sha256"`
+	Hash      string `json:"hash"`
+	ChannelID string `json:"channelId"`
+	Extra     string `json:"extra"`
+}
+
+// UploadSessionCreate 创建附件续传会话
+// POST /api/v1/upload-sessions
+func UploadSessionCreate(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	var body uploadSessionCreateBody
+	if err := c.BodyParser(&body); err != nil {
+		return wrapError(c, err, "提交的数据存在问题")
+	}
+	channelID := strings.TrimSpace(body.ChannelID)
+	if channelID != "" && !pm.CanWithChannelRole(user.ID, channelID, pm.PermFuncChannelFileSend) {
+		return wrapErrorStatus(c, fiber.StatusForbidden, nil, "您没有在此频道上传文件的权限")
+	}
+	if err := channelSpeakCheckHTTP(c, channelID, user.ID); err != nil {
+		return wrapErrorStatus(c, fiber.StatusForbidden, err, err.Error())
+	}
+	return uploadSessionCreate(c, user, service.UploadSessionCreateInput{
+		Purpose:   model.UploadPurposeAttachment,
+		Filename:  body.Filename,
+		MimeType:  body.MimeType,
+		Size:      body.Size,
+		SHA256:    body.SHA256,
+		Hash:      body.Hash,
+		ChannelID: channelID,
+		Extra:     body.Extra,
+	})
+}
+
+// AudioUploadSessionCreate 创建音频素材续传会话，完成后交给音频处理流程
+// POST /api/v1/audio/assets/upload-sessions
+func AudioUploadSessionCreate(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	var body struct {
+		uploadSessionCreateBody
+		Name        string   `json:"name"`
+		FolderID    *string  `json:"folderId"`
+		Tags        []string `json:"tags"`
+		Description string   `json:"description"`
+		Visibility  string   `json:"visibility"`
+		Scope       string   `json:"scope"`
+		WorldID     *string  `json:"worldId"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return wrapError(c, err, "提交的数据存在问题")
+	}
+	visibility := model.AudioVisibilityPublic
+	if v := strings.TrimSpace(body.Visibility); v != "" {
+		visibility = model.AudioAssetVisibility(v)
+	}
+	scope := model.AudioScopeCommon
+	if v := strings.TrimSpace(body.Scope); v != "" {
+		scope = model.AudioAssetScope(v)
+	}
+	isSystemAdmin := pm.CanWithSystemRole(user.ID, pm.PermModAdmin)
+	if status, message := checkAudioUploadScope(user.ID, isSystemAdmin, scope, body.WorldID); status != 0 {
+		return wrapErrorStatus(c, status, nil, message)
+	}
+	return uploadSessionCreate(c, user, service.UploadSessionCreateInput{
+		Purpose:  model.UploadPurposeAudio,
+		Filename: body.Filename,
+		MimeType: body.MimeType,
+		Size:     body.Size,
+		SHA256:   body.SHA256,
+		Audio: &service.AudioUploadOptions{
+			Name:        strings.TrimSpace(body.Name),
+			FolderID:    parseOptionalStringPtr(body.FolderID),
+			Tags:        body.Tags,
+			Description: body.Description,
+			Visibility:  visibility,
+			CreatedBy:   user.ID,
+			Scope:       scope,
+			WorldID:     parseOptionalStringPtr(body.WorldID),
+		},
+	})
+}
+
+func parseOptionalStringPtr(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	return parseOptionalString(*value)
+}
+
+func uploadSessionCreate(c *fiber.Ctx, user *model.UserModel, input service.UploadSessionCreateInput) error {
+	session, result, err := service.UploadSessionCreate(user, input)
+	if err != nil {
+		return uploadSessionError(c, err)
+	}
+	setUploadSessionHeaders(c, session)
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"message":   "ok",
+		"item":      session,
+		"chunkSize": service.UploadChunkSizeLimit(),
+		"result":    result,
+	})
+}
+
+// AttachmentPresignUpload 签发 S3 预签名 PUT 地址，客户端上传后调用 confirm 登记附件
+// POST /api/v1/attachment-presign
+func AttachmentPresignUpload(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	var body uploadSessionCreateBody
+	if err := c.BodyParser(&body); err != nil {
+		return wrapError(c, err, "提交的数据存在问题")
+	}
+	channelID := strings.TrimSpace(body.ChannelID)
+	if channelID != "" && !pm.CanWithChannelRole(user.ID, channelID, pm.PermFuncChannelFileSend) {
+		return wrapErrorStatus(c, fiber.StatusForbidden, nil, "您没有在此频道上传文件的权限")
+	}
+	if err := channelSpeakCheckHTTP(c, channelID, user.ID); err != nil {
+		return wrapErrorStatus(c, fiber.StatusForbidden, err, err.Error())
+	}
+	session, putURL, result, err := service.AttachmentDirectUploadCreate(user, service.UploadSessionCreateInput{
+		Filename:  body.Filename,
+		MimeType:  body.MimeType,
+		Size:      body.Size,
+		SHA256:    body.SHA256,
+		Hash:      body.Hash,
+		ChannelID: channelID,
+		Extra:     body.Extra,
+	})
+	if err != nil {
+		return uploadSessionError(c, err)
+	}
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"message":   "ok",
+		"item":      session,
+		"uploadUrl": putURL,
+		"method":    http.MethodPut,
+		"expiresAt": session.ExpiresAt,
+		"result":    result,
+	})
+}
+
+// UploadSessionConfirm 确认直传完成，服务端校验大小与哈希
+// POST /api/v1/upload-sessions/:id/confirm
+func UploadSessionConfirm(c *fiber.Ctx) error {
+	session, err := loadOwnUploadSession(c)
+	if err != nil || session == nil {
+		return err
+	}
+	result, err := service.AttachmentDirectUploadConfirm(session)
+	if err != nil {
+		return uploadSessionError(c, err)
+	}
+	session, _ = model.UploadSessionGet(session.ID)
+	return c.JSON(fiber.Map{
+		"message": "ok",
+		"item":    session,
+		"result":  result,
+	})
+}
+
+// UploadSessionHead 查询当前已接收的偏移，用于断线后续传
+// HEAD /api/v1/upload-sessions/:id
+func UploadSessionHead(c *fiber.Ctx) error {
+	session, err := loadOwnUploadSession(c)
+	if err != nil || session == nil {
+		return err
+	}
+	setUploadSessionHeaders(c, session)
+	if session.Status != model.UploadSessionPending && session.Status != model.UploadSessionCompleted {
+		return c.SendStatus(fiber.StatusGone)
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// UploadSessionGet 查询会话详情
+// GET /api/v1/upload-sessions/:id
+func UploadSessionGet(c *fiber.Ctx) error {
+	session, err := loadOwnUploadSession(c)
+	if err != nil || session == nil {
+		return err
+	}
+	setUploadSessionHeaders(c, session)
+	return c.JSON(fiber.Map{
+		"message":   "ok",
+		"item":      session,
+		"chunkSize": service.UploadChunkSizeLimit(),
+	})
+}
+
+// UploadSessionPatch 追加一个分片，请求体为原始字节
+// PATCH /api/v1/upload-sessions/:id
+func UploadSessionPatch(c *fiber.Ctx) error {
+	session, err := loadOwnUploadSession(c)
+	if err != nil || session == nil {
+		return err
+	}
+	offset, err := strconv.ParseInt(strings.TrimSpace(c.Get(headerUploadOffset)), 10, 64)
+	if err != nil || offset < 0 {
+		return wrapError(c, err, "缺少或无效的 Upload-Offset")
+	}
+	updated, result, err := service.UploadSessionAppend(session, offset, bytes.NewReader(c.Body()))
+	if updated != nil {
+		setUploadSessionHeaders(c, updated)
+	}
+	if err != nil {
+		return uploadSessionError(c, err)
+	}
+	return c.JSON(fiber.Map{
+		"message": "ok",
+		"item":    updated,
+		"result":  result,
+	})
+}
+
+// UploadSessionDelete 取消上传
+// DELETE /api/v1/upload-sessions/:id
+func UploadSessionDelete(c *fiber.Ctx) error {
+	session, err := loadOwnUploadSession(c)
+	if err != nil || session == nil {
+		return err
+	}
+	if err := service.UploadSessionCancel(session); err != nil {
+		return uploadSessionError(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "已取消"})
+}
+
+func loadOwnUploadSession(c *fiber.Ctx) (*model.UploadSessionModel, error) {
+	id := strings.TrimSpace(c.Params("id"))
+	if id == "" {
+		return nil, wrapError(c, nil, "无效的上传会话ID")
+	}
+	session, err := model.UploadSessionGet(id)
+	if err != nil {
+		return nil, wrapErrorStatus(c, fiber.StatusInternalServerError, err, "读取上传会话失败")
+	}
+	if session == nil || session.UserID != getCurUser(c).ID {
+		return nil, wrapErrorStatus(c, fiber.StatusNotFound, nil, "上传会话不存在")
+	}
+	return session, nil
+}
+
+func setUploadSessionHeaders(c *fiber.Ctx, session *model.UploadSessionModel) {
+	c.Set(headerUploadOffset, strconv.FormatInt(session.Offset, 10))
+	c.Set(headerUploadLength, strconv.FormatInt(session.TotalSize, 10))
+	c.Set("Cache-Control", "no-store")
+}
+
+func uploadSessionError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrUploadSessionNotFound):
+		return wrapErrorStatus(c, fiber.StatusNotFound, err, err.Error())
+	case errors.Is(err, service.ErrUploadOffsetMismatch):
+		return wrapErrorStatus(c, fiber.StatusConflict, err, err.Error())
+	case errors.Is(err, service.ErrUploadSessionClosed), errors.Is(err, service.ErrUploadSessionExpired):
+		return wrapErrorStatus(c, fiber.StatusGone, err, err.Error())
+	case errors.Is(err, service.ErrDirectUploadDisabled):
+		return wrapErrorStatus(c, fiber.StatusNotImplemented, err, err.Error())
+	case errors.Is(err, service.ErrUploadObjectMissing):
+		return wrapErrorStatus(c, fiber.StatusConflict, err, err.Error())
+	case errors.Is(err, service.ErrUploadChecksumMismatch), errors.Is(err, service.ErrUploadHashMismatch), errors.Is(err, service.ErrUploadSizeMismatch):
+		return wrapErrorStatus(c, statusUploadChecksumMismatch, err, err.Error())
+	case errors.Is(err, service.ErrUploadTooLarge), errors.Is(err, service.ErrUploadChunkTooLarge), errors.Is(err, service.ErrAudioTooLarge):
+		return wrapErrorStatus(c, fiber.StatusRequestEntityTooLarge, err, err.Error())
+	case errors.Is(err, service.ErrUploadMimeNotAllowed), errors.Is(err, service.ErrAudioUnsupportedMime):
+		return wrapErrorStatus(c, fiber.StatusUnsupportedMediaType, err, err.Error())
+	case errors.Is(err, service.ErrUploadMediaForbidden):
+		return wrapErrorStatus(c, fiber.StatusForbidden, err, err.Error())
+	default:
+		return wrapError(c, err, "上传失败")
+	}
+}
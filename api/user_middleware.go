@@ -110,7 +110,8 @@ func getToken(c *fiber.Ctx) string {
 	return token
 }
 
-func SignCheckMiddleware(c *fiber.Ctx) error {
+// authenticateRequest 校验请求携带的凭证并在需要时续期，失败时返回提示信息
+func authenticateRequest(c *fiber.Ctx) (*model.UserModel, string) {
 	token := getToken(c)
 
 	var user *model.UserModel
@@ -119,16 +120,12 @@ func SignCheckMiddleware(c *fiber.Ctx) error {
 	if len(token) == 32 {
 		user, err = model.BotVerifyAccessToken(token)
 		if err != nil {
-			return c.Status(http.StatusUnauthorized).JSON(
-				fiber.Map{"message": err.Error()},
-			)
+			return nil, err.Error()
 		}
 	} else {
 		user, err = model.UserVerifyAccessToken(token)
 		if err != nil {
-			return c.Status(http.StatusUnauthorized).JSON(
-				fiber.Map{"message": "凭证错误，需要重新登录"},
-			)
+			return nil, "凭证错误，需要重新登录"
 		}
 
 		if user.AccessToken != nil && user.AccessToken.ID != "" && shouldRefreshUserToken(token) {
@@ -149,16 +146,42 @@ func SignCheckMiddleware(c *fiber.Ctx) error {
 	}
 
 	if user.Disabled {
+		return nil, "帐号被禁用"
+	}
+	return user, ""
+}
+
+func SignCheckMiddleware(c *fiber.Ctx) error {
+	user, message := authenticateRequest(c)
+	if user == nil {
 		return c.Status(http.StatusUnauthorized).JSON(
-			fiber.Map{"message": "帐号被禁用"},
+			fiber.Map{"message": message},
 		)
 	}
 
+	if user.IsBot {
+		// BOT 令牌可以调用站内 HTTP 接口，但受令牌的权限范围与频道限制约束
+		if reason := botHTTPCheck(c, user.ID); reason != "" {
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{"message": reason})
+		}
+	}
+
 	c.Locals("user", user)
 	model.TimelineUpdate(user.ID)
 	return c.Next()
 }
 
+// OptionalSignCheckMiddleware 用于公开路由：凭证有效时识别当前用户，缺失或无效时按游客继续处理
+func OptionalSignCheckMiddleware(c *fiber.Ctx) error {
+	if getToken(c) == "" {
+		return c.Next()
+	}
+	if user, _ := authenticateRequest(c); user != nil {
+		c.Locals("user", user)
+	}
+	return c.Next()
+}
+
 func UserRoleAdminMiddleware(c *fiber.Ctx) error {
 	if !CanWithSystemRole(c, pm.PermModAdmin) {
 		return nil
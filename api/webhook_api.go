@@ -176,6 +176,8 @@ func WebhookChanges(c *fiber.Ctx) error {
 				msg.Content = ""
 			}
 			msg.EnsureWhisperMeta()
+			// GM 暗骰揭示前只有集成自己发出的才返回原文
+			service.GMRollMaskForViewer(integration.BotUserID, false, []*model.MessageModel{msg})
 			ev.Channel = channelData
 			ev.Message = buildProtocolMessage(msg, channelData)
 			// BOT 出站：Satori XML 转换为 CQ 码
@@ -222,6 +224,9 @@ func WebhookMessages(c *fiber.Ctx) error {
 	if strings.EqualFold(channel.PermType, "private") {
 		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "forbidden", "message": "私聊频道不支持 webhook 写入"})
 	}
+	if err := service.ChannelCheckAcceptsMessages(channel); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "bad_request", "message": err.Error()})
+	}
 
 	var req webhookWriteRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -231,6 +236,17 @@ func WebhookMessages(c *fiber.Ctx) error {
 	if op == "" {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"ok": false, "error": "bad_request", "message": "op 不能为空"})
 	}
+	// 禁言同时作用于 webhook 的 BOT 身份与创建者，删除自己写入的消息不受限
+	if op != "message.delete" && channel.WorldID != "" {
+		for _, userID := range []string{botUser.ID, integration.CreatedBy} {
+			if userID == "" {
+				continue
+			}
+			if err := service.WorldSpeakCheck(channel.WorldID, userID, getClientIP(c)); err != nil {
+				return c.Status(http.StatusForbidden).JSON(fiber.Map{"ok": false, "error": "forbidden", "message": err.Error()})
+			}
+		}
+	}
 
 	switch op {
 	case "message.upsert":
@@ -209,6 +209,12 @@ func WorldDetail(c *fiber.Ctx) error {
 	// 判断编辑通知是否已确认
 	editNoticeAcked := member.EditNoticeAckedAt != nil
 
+	// 非成员返回自己最近的加入申请，便于展示审核状态
+	var myApplication *model.WorldJoinApplicationModel
+	if member.ID == "" && world.JoinMode == model.WorldJoinModeApplication {
+		myApplication, _ = service.WorldApplicationGetMine(worldID, user.ID)
+	}
+
 	return c.JSON(fiber.Map{
 		"world":                   world,
 		"isMember":                member.ID != "",
@@ -218,6 +224,7 @@ func WorldDetail(c *fiber.Ctx) error {
 		"allowMemberEditKeywords": world.AllowMemberEditKeywords,
 		"ownerNickname":           ownerNickname,
 		"editNoticeAcked":         editNoticeAcked,
+		"myApplication":           myApplication,
 	})
 }
 
@@ -260,7 +267,8 @@ func WorldUpdateHandler(c *fiber.Ctx) error {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "世界不存在"})
 		case errors.Is(err, service.ErrWorldPermission):
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "无权编辑世界"})
-		case errors.Is(err, service.ErrWorldDescriptionTooLong):
+		case errors.Is(err, service.ErrWorldDescriptionTooLong), errors.Is(err, service.ErrWorldApplicationForm),
+			errors.Is(err, service.ErrWorldDirectoryInvalid):
 			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
 		default:
 			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": "更新世界失败"})
@@ -322,8 +330,14 @@ func WorldJoinHandler(c *fiber.Ctx) error {
 	if world.Visibility == model.WorldVisibilityPrivate && !service.IsWorldAdmin(worldID, user.ID) {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "该世界仅通过邀请加入"})
 	}
-	member, err := service.WorldJoin(worldID, user.ID, model.WorldRoleMember)
+	if world.JoinMode == model.WorldJoinModeApplication && !service.IsWorldAdmin(worldID, user.ID) && !service.IsWorldMember(worldID, user.ID) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "该世界需要提交加入申请", "joinMode": world.JoinMode})
+	}
+	member, err := service.WorldJoinWithIP(worldID, user.ID, model.WorldRoleMember, getClientIP(c))
 	if err != nil {
+		if errors.Is(err, service.ErrWorldBanned) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": err.Error()})
+		}
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": "加入失败"})
 	}
 	return c.JSON(fiber.Map{"member": member})
@@ -378,6 +392,11 @@ func WorldSectionsHandler(c *fiber.Ctx) error {
 			if err == nil {
 				resp["channels"] = channels
 			}
+		case "channelTree":
+			channels, err := service.ChannelList(user.ID, worldID)
+			if err == nil {
+				resp["channelTree"] = service.BuildChannelTree(channels)
+			}
 		case "members":
 			members, err := service.ListWorldMembers(worldID, 100)
 			if err == nil {
@@ -435,13 +454,15 @@ func WorldInviteConsumeHandler(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
 	}
 	slug := c.Params("slug")
-	invite, world, member, alreadyJoined, err := service.WorldInviteConsume(slug, user.ID)
+	invite, world, member, alreadyJoined, err := service.WorldInviteConsume(slug, user.ID, getClientIP(c))
 	if err != nil {
 		switch {
 		case errors.Is(err, service.ErrWorldInviteInvalid):
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "邀请链接无效或已过期"})
 		case errors.Is(err, service.ErrWorldNotFound):
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "世界不存在"})
+		case errors.Is(err, service.ErrWorldBanned):
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": err.Error()})
 		default:
 			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": "加入失败"})
 		}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/samber/lo"
+
+	"sealchat/model"
+	"sealchat/protocol"
+	"sealchat/service"
+)
+
+func worldApplicationErrorResponse(c *fiber.Ctx, err error) error {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, service.ErrWorldNotFound), errors.Is(err, service.ErrWorldApplicationNotFound):
+		status = fiber.StatusNotFound
+	case errors.Is(err, service.ErrWorldPermission), errors.Is(err, service.ErrWorldBanned):
+		status = fiber.StatusForbidden
+	case errors.Is(err, service.ErrWorldApplicationCooldown):
+		status = fiber.StatusTooManyRequests
+	}
+	return c.Status(status).JSON(fiber.Map{"message": err.Error()})
+}
+
+// broadcastWorldApplicationEvent 推送给申请人与世界管理员，不要求其当前位于该世界
+func broadcastWorldApplicationEvent(app *model.WorldJoinApplicationModel, operation string) {
+	if app == nil {
+		return
+	}
+	reviewerIDs, _ := service.WorldApplicationReviewerIDs(app.WorldID)
+	userIDs := lo.Uniq(append(reviewerIDs, app.UserID))
+	event := &protocol.Event{
+		Type: protocol.EventWorldApplicationUpdated,
+		Argv: &protocol.Argv{
+			Options: map[string]interface{}{
+				"worldId":     app.WorldID,
+				"operation":   operation,
+				"application": app,
+			},
+		},
+		Timestamp: time.Now().UnixMilli(),
+	}
+	emitUserEvent(getUserConnInfoMap(), userIDs, event)
+}
+
+// WorldApplicationSubmitHandler 提交或补充加入申请
+func WorldApplicationSubmitHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	var payload struct {
+		Answers map[string]string `json:"answers"`
+	}
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "参数错误"})
+	}
+	app, err := service.WorldApplicationSubmit(c.Params("worldId"), user.ID, payload.Answers, getClientIP(c))
+	if err != nil {
+		return worldApplicationErrorResponse(c, err)
+	}
+	broadcastWorldApplicationEvent(app, "submitted")
+	return c.JSON(fiber.Map{"application": app})
+}
+
+// WorldApplicationMineHandler 查看自己在该世界最近的申请
+func WorldApplicationMineHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	app, err := service.WorldApplicationGetMine(c.Params("worldId"), user.ID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": "获取申请失败"})
+	}
+	return c.JSON(fiber.Map{"application": app})
+}
+
+// WorldApplicationWithdrawHandler 撤回进行中的申请
+func WorldApplicationWithdrawHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	app, err := service.WorldApplicationWithdraw(c.Params("worldId"), user.ID)
+	if err != nil {
+		return worldApplicationErrorResponse(c, err)
+	}
+	broadcastWorldApplicationEvent(app, "withdrawn")
+	return c.JSON(fiber.Map{"application": app})
+}
+
+// WorldApplicationListHandler 管理员查看申请列表
+func WorldApplicationListHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	page := parseQueryIntDefault(c, "page", 1)
+	pageSize := parseQueryIntDefault(c, "pageSize", 20)
+	items, total, err := service.WorldApplicationList(c.Params("worldId"), user.ID, c.Query("status"), page, pageSize)
+	if err != nil {
+		return worldApplicationErrorResponse(c, err)
+	}
+	return c.JSON(fiber.Map{
+		"items":    items,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+// WorldApplicationReviewHandler 通过、拒绝申请或要求补充信息
+func WorldApplicationReviewHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	var payload service.WorldApplicationReviewParams
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "参数错误"})
+	}
+	app, err := service.WorldApplicationReview(c.Params("worldId"), c.Params("applicationId"), user.ID, payload)
+	if err != nil {
+		return worldApplicationErrorResponse(c, err)
+	}
+	broadcastWorldApplicationEvent(app, app.Status)
+	return c.JSON(fiber.Map{"application": app})
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sealchat/model"
+	"sealchat/protocol"
+	"sealchat/service"
+)
+
+func worldBanErrorResponse(c *fiber.Ctx, err error) error {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, service.ErrWorldNotFound), errors.Is(err, service.ErrWorldBanNotFound):
+		status = fiber.StatusNotFound
+	case errors.Is(err, service.ErrWorldPermission):
+		status = fiber.StatusForbidden
+	case errors.Is(err, service.ErrWorldOwnerImmutable):
+		return c.Status(status).JSON(fiber.Map{"message": "世界拥有者不可封禁"})
+	case errors.Is(err, service.ErrWorldMemberInvalid):
+		return c.Status(status).JSON(fiber.Map{"message": "用户不存在"})
+	}
+	return c.Status(status).JSON(fiber.Map{"message": err.Error()})
+}
+
+// channelSpeakCheckHTTP HTTP 接口在频道内产生内容前检查：分类不承载内容，且用户未被禁言；频道为空时放行
+func channelSpeakCheckHTTP(c *fiber.Ctx, channelID, userID string) error {
+	if channelID == "" {
+		return nil
+	}
+	if ch, _ := model.ChannelGet(channelID); ch != nil {
+		if err := service.ChannelCheckAcceptsMessages(ch); err != nil {
+			return err
+		}
+	}
+	return service.ChannelSpeakCheck(channelID, userID, getClientIP(c))
+}
+
+// WorldSpeakMiddleware 挂在便签等写接口上，频道解析方式与 BOT 频道限制一致
+func WorldSpeakMiddleware(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Next()
+	}
+	channelID := botHTTPChannelID(c, strings.TrimPrefix(c.Path(), "/api/v1"))
+	if err := channelSpeakCheckHTTP(c, channelID, user.ID); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.Next()
+}
+
+// lookupUserConnIP 取目标用户当前在线连接的 IP，用于“同时封禁 IP”
+func lookupUserConnIP(userID string) string {
+	ip := ""
+	if connMap, ok := getUserConnInfoMap().Load(userID); ok {
+		connMap.Range(func(_ *WsSyncConn, info *ConnInfo) bool {
+			if info != nil && info.RemoteIP != "" {
+				ip = info.RemoteIP
+				return false
+			}
+			return true
+		})
+	}
+	return ip
+}
+
+// notifyWorldSanction 通知被处罚用户，客户端据此退出世界频道或禁用输入框
+func notifyWorldSanction(ban *model.WorldBanModel, operation string) {
+	if ban == nil || ban.UserID == "" {
+		return
+	}
+	event := &protocol.Event{
+		Type: protocol.EventWorldSanctionUpdated,
+		Argv: &protocol.Argv{
+			Options: map[string]interface{}{
+				"worldId":   ban.WorldID,
+				"operation": operation,
+				"sanction":  ban,
+			},
+		},
+		Timestamp: time.Now().UnixMilli(),
+	}
+	userIDs := []string{ban.UserID}
+	emitUserEvent(getUserConnInfoMap(), userIDs, event)
+}
+
+// WorldBanListHandler 管理员查看封禁与禁言列表
+func WorldBanListHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	items, err := service.WorldBanList(c.Params("worldId"), user.ID, c.QueryBool("includeInactive"))
+	if err != nil {
+		return worldBanErrorResponse(c, err)
+	}
+	return c.JSON(fiber.Map{"items": items})
+}
+
+// WorldBanCreateHandler 新增封禁或禁言
+func WorldBanCreateHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	var payload struct {
+		service.WorldBanParams
+		IncludeIP bool `json:"includeIP"`
+	}
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "参数错误"})
+	}
+	params := payload.WorldBanParams
+	if payload.IncludeIP && params.IP == "" && params.UserID != "" {
+		params.IP = lookupUserConnIP(params.UserID)
+	}
+	ban, err := service.WorldBanCreate(c.Params("worldId"), user.ID, params)
+	if err != nil {
+		return worldBanErrorResponse(c, err)
+	}
+	notifyWorldSanction(ban, "created")
+	return c.JSON(fiber.Map{"item": ban})
+}
+
+// WorldBanRevokeHandler 撤销封禁或禁言
+func WorldBanRevokeHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	ban, err := service.WorldBanRevoke(c.Params("worldId"), c.Params("banId"), user.ID)
+	if err != nil {
+		return worldBanErrorResponse(c, err)
+	}
+	notifyWorldSanction(ban, "revoked")
+	return c.JSON(fiber.Map{"item": ban})
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sealchat/service"
+)
+
+// WorldDirectoryHandler 公开世界目录，支持关键词检索、筛选与排序，无需登录
+func WorldDirectoryHandler(c *fiber.Ctx) error {
+	query := service.WorldDirectoryQuery{
+		Keyword:    strings.TrimSpace(c.Query("keyword")),
+		Tag:        strings.TrimSpace(c.Query("tag")),
+		Language:   strings.TrimSpace(c.Query("language")),
+		Genre:      strings.TrimSpace(c.Query("genre")),
+		Recruiting: strings.TrimSpace(c.Query("recruiting")),
+		Featured:   c.QueryBool("featured"),
+		Sort:       strings.TrimSpace(c.Query("sort")),
+		Page:       parseQueryIntDefault(c, "page", 1),
+		PageSize:   parseQueryIntDefault(c, "pageSize", 20),
+	}
+	result, err := service.WorldDirectoryList(query, time.Now())
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": "获取世界目录失败"})
+	}
+	return c.JSON(result)
+}
+
+// AdminWorldFeature 平台管理员推荐或取消推荐世界
+func AdminWorldFeature(c *fiber.Ctx) error {
+	var body struct {
+		WorldID  string `json:"worldId"`
+		Featured bool   `json:"featured"`
+	}
+	if err := c.BodyParser(&body); err != nil || strings.TrimSpace(body.WorldID) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "参数错误"})
+	}
+	world, err := service.WorldSetFeatured(strings.TrimSpace(body.WorldID), body.Featured)
+	if err != nil {
+		if errors.Is(err, service.ErrWorldNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "世界不存在"})
+		}
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": "更新推荐状态失败"})
+	}
+	broadcastWorldUpdated(world)
+	return c.JSON(fiber.Map{"world": world})
+}
@@ -401,20 +401,19 @@ func broadcastWorldKeywordEvent(payload *worldKeywordEventPayload) {
 }
 
 func broadcastEventToWorld(worldID string, event *protocol.Event) {
-	if userId2ConnInfoGlobal == nil {
+	event.Timestamp = time.Now().Unix()
+	deliverEventToWorld(getUserConnInfoMap(), worldID, event)
+	publishBusBroadcast(&busBroadcast{Kind: busBroadcastWorld, WorldID: worldID, Event: event}, nil)
+}
+
+func deliverEventToWorld(userConnMap *userConnInfoMap, worldID string, event *protocol.Event) {
+	if userConnMap == nil {
 		return
 	}
-	event.Timestamp = time.Now().Unix()
-	userId2ConnInfoGlobal.Range(func(_ string, conns *utils.SyncMap[*WsSyncConn, *ConnInfo]) bool {
+	userConnMap.Range(func(_ string, conns *utils.SyncMap[*WsSyncConn, *ConnInfo]) bool {
 		conns.Range(func(conn *WsSyncConn, info *ConnInfo) bool {
 			if info != nil && info.WorldId == worldID {
-				_ = conn.WriteJSON(struct {
-					protocol.Event
-					Op protocol.Opcode `json:"op"`
-				}{
-					Event: *event,
-					Op:    protocol.OpEvent,
-				})
+				writeEventFrame(conn, event)
 			}
 			return true
 		})
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sealchat/service"
+)
+
+func worldPackageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrWorldPermission), errors.Is(err, service.ErrWorldCreateForbidden):
+		return fiber.StatusForbidden
+	case errors.Is(err, service.ErrWorldNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, service.ErrWorldPackageInvalid):
+		return fiber.StatusBadRequest
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
+
+// WorldPackageExportHandler 导出世界包（zip）
+func WorldPackageExportHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	worldID := c.Params("worldId")
+	world, err := service.GetWorldByID(worldID)
+	if err != nil {
+		return c.Status(worldPackageErrorStatus(err)).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	// 包内含音频，先落到临时文件再流式返回，避免整体读入内存
+	f, err := os.CreateTemp("", "world-package-*.zip")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "导出失败"})
+	}
+	tmp := &tempFileReader{File: f}
+	if err := service.WorldPackageExport(world.ID, user.ID, f); err != nil {
+		_ = tmp.Close()
+		return c.Status(worldPackageErrorStatus(err)).JSON(fiber.Map{"message": err.Error()})
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = tmp.Close()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "导出失败"})
+	}
+
+	filename := fmt.Sprintf("%s-%s.sealworld.zip", strings.TrimSpace(world.Name), time.Now().Format("20060102"))
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, "attachment; filename*=UTF-8''"+url.PathEscape(filename))
+	// 响应发送完毕后由 fasthttp 关闭流，此时删除临时文件
+	return c.SendStream(tmp, int(size))
+}
+
+// tempFileReader 关闭时一并删除临时文件；Windows 上无法删除仍打开的文件，不能在创建后立即删除
+type tempFileReader struct {
+	*os.File
+}
+
+func (r *tempFileReader) Close() error {
+	err := r.File.Close()
+	if rmErr := os.Remove(r.File.Name()); rmErr != nil && !os.IsNotExist(rmErr) && err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+// WorldPackagePreviewHandler 解析上传的世界包并返回内容统计与冲突
+func WorldPackagePreviewHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "请上传世界包文件"})
+	}
+	file, err := fh.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "读取文件失败"})
+	}
+	defer file.Close()
+
+	result, err := service.WorldPackagePreview(file, fh.Size, user.ID, c.FormValue("targetWorldId"))
+	if err != nil {
+		return c.Status(worldPackageErrorStatus(err)).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.JSON(result)
+}
+
+// WorldPackageImportHandler 导入世界包；targetWorldId 为空时新建世界
+func WorldPackageImportHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "请上传世界包文件"})
+	}
+	file, err := fh.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "读取文件失败"})
+	}
+	defer file.Close()
+
+	result, err := service.WorldPackageImport(file, fh.Size, user.ID, service.WorldPackageImportOptions{
+		TargetWorldID:    c.FormValue("targetWorldId"),
+		Name:             c.FormValue("name"),
+		ConflictStrategy: c.FormValue("conflictStrategy"),
+	})
+	if err != nil {
+		return c.Status(worldPackageErrorStatus(err)).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.JSON(result)
+}
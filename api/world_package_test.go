@@ -0,0 +1,41 @@
+package api
+
+import (
+	"io"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestTempFileReaderRemovesFileAfterStream(t *testing.T) {
+	f, err := os.CreateTemp("", "world-package-test-*.zip")
+	if err != nil {
+		t.Fatalf("create temp failed: %v", err)
+	}
+	if _, err := f.WriteString("PK"); err != nil {
+		t.Fatalf("write temp failed: %v", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatalf("seek failed: %v", err)
+	}
+	name := f.Name()
+
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendStream(&tempFileReader{File: f}, 2)
+	})
+	resp, err := app.Test(httptest.NewRequest("GET", "/", nil), -1)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "PK" {
+		t.Fatalf("unexpected body %q", body)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Fatalf("temp file should be removed after the stream closes, stat err=%v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sealchat/protocol"
+	"sealchat/service"
+)
+
+func worldRoleErrorResponse(c *fiber.Ctx, err error) error {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrWorldNotFound), errors.Is(err, service.ErrWorldRoleNotFound):
+		status = fiber.StatusNotFound
+	case errors.Is(err, service.ErrWorldPermission):
+		status = fiber.StatusForbidden
+	case errors.Is(err, service.ErrWorldMemberInvalid):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "成员不存在"})
+	case errors.Is(err, service.ErrWorldRoleInvalid):
+		status = fiber.StatusBadRequest
+	}
+	return c.Status(status).JSON(fiber.Map{"message": err.Error()})
+}
+
+// broadcastWorldRolesUpdated 角色或授予关系变化后通知世界内客户端刷新权限
+func broadcastWorldRolesUpdated(worldID, operation string, payload map[string]interface{}) {
+	options := map[string]interface{}{
+		"worldId":   worldID,
+		"operation": operation,
+	}
+	for k, v := range payload {
+		options[k] = v
+	}
+	broadcastEventToWorld(worldID, &protocol.Event{
+		Type: protocol.EventWorldRolesUpdated,
+		Argv: &protocol.Argv{Options: options},
+	})
+}
+
+// WorldRoleListHandler 查看世界角色
+func WorldRoleListHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	items, err := service.WorldRoleList(c.Params("worldId"), user.ID)
+	if err != nil {
+		return worldRoleErrorResponse(c, err)
+	}
+	return c.JSON(fiber.Map{"items": items})
+}
+
+// WorldRoleCreateHandler 新建世界角色
+func WorldRoleCreateHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	var payload service.WorldRoleParams
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "参数错误"})
+	}
+	role, err := service.WorldRoleCreate(c.Params("worldId"), user.ID, payload)
+	if err != nil {
+		return worldRoleErrorResponse(c, err)
+	}
+	broadcastWorldRolesUpdated(role.WorldID, "created", map[string]interface{}{"role": role})
+	return c.JSON(fiber.Map{"item": role})
+}
+
+// WorldRoleUpdateHandler 修改世界角色
+func WorldRoleUpdateHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	var payload service.WorldRoleParams
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "参数错误"})
+	}
+	role, err := service.WorldRoleUpdate(c.Params("worldId"), c.Params("roleId"), user.ID, payload)
+	if err != nil {
+		return worldRoleErrorResponse(c, err)
+	}
+	broadcastWorldRolesUpdated(role.WorldID, "updated", map[string]interface{}{"role": role})
+	return c.JSON(fiber.Map{"item": role})
+}
+
+// WorldRoleDeleteHandler 删除世界角色
+func WorldRoleDeleteHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	worldID := c.Params("worldId")
+	roleID := c.Params("roleId")
+	if err := service.WorldRoleDelete(worldID, roleID, user.ID); err != nil {
+		return worldRoleErrorResponse(c, err)
+	}
+	broadcastWorldRolesUpdated(worldID, "deleted", map[string]interface{}{"roleId": roleID})
+	return c.JSON(fiber.Map{"message": "已删除"})
+}
+
+// WorldRoleReorderHandler 调整角色优先级顺序
+func WorldRoleReorderHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	var payload struct {
+		RoleIDs []string `json:"roleIds"`
+	}
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "参数错误"})
+	}
+	worldID := c.Params("worldId")
+	if err := service.WorldRoleReorder(worldID, user.ID, payload.RoleIDs); err != nil {
+		return worldRoleErrorResponse(c, err)
+	}
+	broadcastWorldRolesUpdated(worldID, "reordered", nil)
+	return c.JSON(fiber.Map{"message": "已更新"})
+}
+
+// WorldRoleMembersHandler 授予或收回成员角色
+func WorldRoleMembersHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	var payload struct {
+		Add    []string `json:"add"`
+		Remove []string `json:"remove"`
+	}
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "参数错误"})
+	}
+	worldID := c.Params("worldId")
+	roleID := c.Params("roleId")
+	if len(payload.Add) > 0 {
+		if err := service.WorldRoleAssign(worldID, roleID, user.ID, payload.Add); err != nil {
+			return worldRoleErrorResponse(c, err)
+		}
+	}
+	if len(payload.Remove) > 0 {
+		if err := service.WorldRoleUnassign(worldID, roleID, user.ID, payload.Remove); err != nil {
+			return worldRoleErrorResponse(c, err)
+		}
+	}
+	broadcastWorldRolesUpdated(worldID, "members", map[string]interface{}{
+		"roleId":  roleID,
+		"added":   payload.Add,
+		"removed": payload.Remove,
+	})
+	return c.JSON(fiber.Map{"message": "已更新"})
+}
+
+// WorldRoleOverrideListHandler 查看角色在各频道的权限覆盖
+func WorldRoleOverrideListHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	items, err := service.WorldRoleChannelOverrideList(c.Params("worldId"), c.Params("roleId"), user.ID)
+	if err != nil {
+		return worldRoleErrorResponse(c, err)
+	}
+	return c.JSON(fiber.Map{"items": items})
+}
+
+// WorldRoleOverrideClearHandler 移除频道覆盖，恢复为世界角色权限
+func WorldRoleOverrideClearHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	worldID := c.Params("worldId")
+	if err := service.WorldRoleChannelOverrideClear(worldID, c.Params("roleId"), c.Params("channelId"), user.ID); err != nil {
+		return worldRoleErrorResponse(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "已恢复"})
+}
@@ -0,0 +1,306 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/samber/lo"
+
+	"sealchat/model"
+	"sealchat/protocol"
+	"sealchat/service"
+	"sealchat/utils"
+)
+
+func worldSessionErrorResponse(c *fiber.Ctx, err error) error {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrWorldNotFound), errors.Is(err, service.ErrWorldSessionNotFound):
+		status = fiber.StatusNotFound
+	case errors.Is(err, service.ErrWorldPermission):
+		status = fiber.StatusForbidden
+	case errors.Is(err, service.ErrWorldSessionInvalid):
+		status = fiber.StatusBadRequest
+	}
+	return c.Status(status).JSON(fiber.Map{"message": err.Error()})
+}
+
+// broadcastWorldSessionsUpdated 场次或出席情况变化后通知世界内客户端刷新日程
+func broadcastWorldSessionsUpdated(worldID, operation string, payload map[string]interface{}) {
+	options := map[string]interface{}{
+		"worldId":   worldID,
+		"operation": operation,
+	}
+	for k, v := range payload {
+		options[k] = v
+	}
+	broadcastEventToWorld(worldID, &protocol.Event{
+		Type: protocol.EventWorldSessionsUpdated,
+		Argv: &protocol.Argv{Options: options},
+	})
+}
+
+func parseWorldSessionRangeTime(value string, fallback time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return fallback, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// WorldSessionListHandler 查看时间范围内的场次，默认为接下来 30 天
+func WorldSessionListHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	now := time.Now()
+	from, err := parseWorldSessionRangeTime(c.Query("from"), now)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "from 需为 RFC3339 时间"})
+	}
+	to, err := parseWorldSessionRangeTime(c.Query("to"), from.AddDate(0, 0, 30))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "to 需为 RFC3339 时间"})
+	}
+	items, err := service.WorldSessionList(c.Params("worldId"), user.ID, from, to)
+	if err != nil {
+		return worldSessionErrorResponse(c, err)
+	}
+	return c.JSON(fiber.Map{"items": items})
+}
+
+// WorldSessionCreateHandler 新建场次
+func WorldSessionCreateHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	var payload service.WorldSessionParams
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "参数错误"})
+	}
+	session, err := service.WorldSessionCreate(c.Params("worldId"), user.ID, payload)
+	if err != nil {
+		return worldSessionErrorResponse(c, err)
+	}
+	broadcastWorldSessionsUpdated(session.WorldID, "created", map[string]interface{}{"session": session})
+	return c.JSON(fiber.Map{"item": session})
+}
+
+// WorldSessionDetailHandler 场次详情
+func WorldSessionDetailHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	session, err := service.WorldSessionGet(c.Params("worldId"), c.Params("sessionId"), user.ID)
+	if err != nil {
+		return worldSessionErrorResponse(c, err)
+	}
+	return c.JSON(fiber.Map{"item": session})
+}
+
+// WorldSessionUpdateHandler 修改场次
+func WorldSessionUpdateHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	var payload service.WorldSessionParams
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "参数错误"})
+	}
+	session, err := service.WorldSessionUpdate(c.Params("worldId"), c.Params("sessionId"), user.ID, payload)
+	if err != nil {
+		return worldSessionErrorResponse(c, err)
+	}
+	broadcastWorldSessionsUpdated(session.WorldID, "updated", map[string]interface{}{"session": session})
+	return c.JSON(fiber.Map{"item": session})
+}
+
+// WorldSessionCancelHandler 取消场次
+func WorldSessionCancelHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	session, err := service.WorldSessionCancel(c.Params("worldId"), c.Params("sessionId"), user.ID)
+	if err != nil {
+		return worldSessionErrorResponse(c, err)
+	}
+	broadcastWorldSessionsUpdated(session.WorldID, "cancelled", map[string]interface{}{"sessionId": session.ID})
+	return c.JSON(fiber.Map{"message": "已取消"})
+}
+
+// WorldSessionRSVPHandler 回复出席情况
+func WorldSessionRSVPHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	var payload struct {
+		Status string `json:"status"`
+		Note   string `json:"note"`
+	}
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "参数错误"})
+	}
+	worldID := c.Params("worldId")
+	rsvp, err := service.WorldSessionRSVPSet(worldID, c.Params("sessionId"), user.ID, payload.Status, payload.Note)
+	if err != nil {
+		return worldSessionErrorResponse(c, err)
+	}
+	broadcastWorldSessionsUpdated(worldID, "rsvp", map[string]interface{}{
+		"sessionId": rsvp.SessionID,
+		"userId":    rsvp.UserID,
+		"status":    rsvp.Status,
+	})
+	return c.JSON(fiber.Map{"item": rsvp})
+}
+
+// WorldSessionRSVPListHandler 查看场次的出席回复
+func WorldSessionRSVPListHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	items, err := service.WorldSessionRSVPList(c.Params("worldId"), c.Params("sessionId"), user.ID)
+	if err != nil {
+		return worldSessionErrorResponse(c, err)
+	}
+	return c.JSON(fiber.Map{"items": items})
+}
+
+func calendarFeedURL(c *fiber.Ctx, token string) string {
+	base := ""
+	if cfg := utils.GetConfig(); cfg != nil && strings.TrimSpace(cfg.Domain) != "" {
+		base = strings.TrimSpace(cfg.Domain)
+		if !strings.HasPrefix(base, "http://") && !strings.HasPrefix(base, "https://") {
+			base = "http://" + base
+		}
+	} else {
+		base = c.BaseURL()
+	}
+	return strings.TrimRight(base, "/") + "/api/v1/calendar/" + token + ".ics"
+}
+
+// CalendarFeedTokenHandler 获取个人日历订阅地址
+func CalendarFeedTokenHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	token, err := service.CalendarFeedToken(user.ID, false)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.JSON(fiber.Map{"token": token, "url": calendarFeedURL(c, token)})
+}
+
+// CalendarFeedTokenRotateHandler 重新生成订阅地址，旧地址立即失效
+func CalendarFeedTokenRotateHandler(c *fiber.Ctx) error {
+	user := getCurUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "未登录"})
+	}
+	token, err := service.CalendarFeedToken(user.ID, true)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.JSON(fiber.Map{"token": token, "url": calendarFeedURL(c, token)})
+}
+
+// CalendarFeedHandler 输出 iCalendar 订阅内容，凭令牌访问无需登录
+func CalendarFeedHandler(c *fiber.Ctx) error {
+	data, err := service.CalendarFeedRender(c.Params("token"), time.Now())
+	if err != nil {
+		if errors.Is(err, service.ErrCalendarFeedInvalid) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": err.Error()})
+		}
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	return c.Send(data)
+}
+
+// announceWorldSession 场次开始时由小海豹在频道内发布开团消息
+func announceWorldSession(item *service.WorldSessionAnnouncement) {
+	session := item.Session
+	channel, err := model.ChannelGet(session.ChannelID)
+	if err != nil || channel == nil || channel.ID == "" {
+		return
+	}
+	// 场次创建后频道可能被改为分类，分类不承载消息
+	if service.ChannelCheckAcceptsMessages(channel) != nil {
+		return
+	}
+	service.SealBotEnsureUser()
+
+	loc, _ := time.LoadLocation(session.Timezone)
+	if loc == nil {
+		loc = time.Local
+	}
+	content := fmt.Sprintf("【开团】%s 现在开始（%s - %s）",
+		session.Title,
+		item.OccurrenceAt.In(loc).Format("15:04"),
+		item.EndAt.In(loc).Format("15:04"))
+	if len(item.Attendees) > 0 {
+		names := lo.FilterMap(item.Attendees, func(id string, _ int) (string, bool) {
+			u := model.UserGet(id)
+			if u == nil {
+				return "", false
+			}
+			if u.Nickname != "" {
+				return u.Nickname, true
+			}
+			return u.Username, true
+		})
+		content += "\n出席：" + strings.Join(names, "、")
+	}
+
+	m := model.MessageModel{
+		StringPKBaseModel: model.StringPKBaseModel{
+			ID: utils.NewID(),
+		},
+		UserID:    service.SealBotUserID,
+		ChannelID: channel.ID,
+		MemberID:  service.SealBotUserID,
+		Content:   content,
+		ICMode:    "ooc",
+	}
+	if err := model.GetDB().Create(&m).Error; err != nil {
+		return
+	}
+
+	channelData := channel.ToProtocolType()
+	userData := &protocol.User{
+		ID:    service.SealBotUserID,
+		Nick:  "小海豹",
+		IsBot: true,
+	}
+	messageData := m.ToProtocolType2(channelData)
+	messageData.User = userData
+	messageData.Member = &protocol.GuildMember{
+		Name: userData.Nick,
+		Nick: userData.Nick,
+	}
+	ctx := &ChatContext{
+		ChannelUsersMap: getChannelUsersMap(),
+		UserId2ConnInfo: getUserConnInfoMap(),
+	}
+	ev := &protocol.Event{
+		Type:    protocol.EventMessageCreated,
+		Message: messageData,
+		Channel: channelData,
+		User:    userData,
+	}
+	ctx.BroadcastEventInChannel(channel.ID, ev)
+	ctx.BroadcastEventInChannelForBot(channel.ID, ev)
+	_ = model.WebhookEventLogAppendForMessage(channel.ID, "message-created", m.ID)
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/gofiber/contrib/websocket"
+
+	"sealchat/utils"
+)
+
+// WebSocket 下行帧编码：json 为文本帧，msgpack 为二进制帧
+const (
+	wsEncodingJSON    = "json"
+	wsEncodingMsgpack = "msgpack"
+)
+
+// wsTransportInfo 协商结果，随 READY 下发
+type wsTransportInfo struct {
+	Encoding string `json:"encoding"`
+	Compress bool   `json:"compress"`
+}
+
+func wsTransportConfig() utils.WSTransportConfig {
+	if cfg := utils.GetConfig(); cfg != nil {
+		return cfg.WSTransport
+	}
+	return utils.WSTransportConfig{}
+}
+
+// newWsUpgradeConfig 升级时只负责协商 permessage-deflate 扩展，是否真正压缩要等 OpIdentify 决定
+func newWsUpgradeConfig() websocket.Config {
+	return websocket.Config{EnableCompression: wsTransportConfig().Compression}
+}
+
+func newWsSyncConn(rawConn *websocket.Conn) *WsSyncConn {
+	// 老客户端未声明 transport 时保持原样，不压缩下行帧
+	rawConn.EnableWriteCompression(false)
+	extensions := rawConn.Headers("Sec-Websocket-Extensions", rawConn.Headers("Sec-WebSocket-Extensions"))
+	return &WsSyncConn{
+		Conn:      rawConn,
+		transport: wsTransportInfo{Encoding: wsEncodingJSON},
+		deflate:   wsTransportConfig().Compression && strings.Contains(strings.ToLower(extensions), "permessage-deflate"),
+	}
+}
+
+// WriteJSON 按协商的编码写出一帧，msgpack 连接发送与 JSON 字段一致的二进制帧
+func (c *WsSyncConn) WriteJSON(v interface{}) error {
+	c.Mux.Lock()
+	defer c.Mux.Unlock()
+	if c.adapter != nil {
+		// 标准协议连接只接收转换后的事件，站内信令直接丢弃
+		return nil
+	}
+	if c.transport.Encoding != wsEncodingMsgpack {
+		return c.Conn.WriteJSON(v)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	packed, err := utils.JSONToMsgpack(data)
+	if err != nil {
+		return err
+	}
+	return c.Conn.WriteMessage(websocket.BinaryMessage, packed)
+}
+
+// negotiateTransport 读取 OpIdentify 中的 transport 字段：
+// {"transport": {"encoding": "msgpack", "compress": true}}。
+// 服务端未开启的选项、握手时未协商 permessage-deflate 的压缩请求都会被忽略，实际结果以返回值为准
+func (c *WsSyncConn) negotiateTransport(body any) wsTransportInfo {
+	cfg := wsTransportConfig()
+	info := wsTransportInfo{Encoding: wsEncodingJSON}
+	if m, ok := body.(map[string]any); ok {
+		if raw, ok := m["transport"].(map[string]any); ok {
+			if encoding, ok := raw["encoding"].(string); ok && cfg.Msgpack &&
+				strings.EqualFold(strings.TrimSpace(encoding), wsEncodingMsgpack) {
+				info.Encoding = wsEncodingMsgpack
+			}
+			if compress, ok := raw["compress"].(bool); ok && compress && c.deflate {
+				info.Compress = true
+			}
+		}
+	}
+
+	c.Mux.Lock()
+	defer c.Mux.Unlock()
+	c.transport = info
+	if info.Compress {
+		c.Conn.EnableWriteCompression(true)
+		_ = c.Conn.SetCompressionLevel(cfg.CompressionLevel)
+	}
+	return info
+}
+
+// decodeWsFrame 把上行的 MessagePack 二进制帧转为 JSON，文本帧原样返回
+func decodeWsFrame(messageType int, msg []byte) ([]byte, bool) {
+	if messageType != websocket.BinaryMessage {
+		return msg, true
+	}
+	if !wsTransportConfig().Msgpack {
+		return nil, false
+	}
+	data, err := utils.MsgpackToJSON(msg)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// writeRaw 绕过 WriteJSON 的过滤直接写出 JSON，供标准协议适配使用
+func (c *WsSyncConn) writeRaw(v any) error {
+	c.Mux.Lock()
+	defer c.Mux.Unlock()
+	return c.Conn.WriteJSON(v)
+}
@@ -16,6 +16,7 @@ import (
 	"sealchat/model"
 	"sealchat/pm"
 	"sealchat/service"
+	"sealchat/service/eventbus"
 	"sealchat/service/metrics"
 	"sealchat/utils"
 )
@@ -132,6 +133,11 @@ func main() {
 		log.Fatalf("初始化存储系统失败: %v", err)
 	}
 
+	// 多实例部署时用于同步推送，单实例下为进程内实现
+	if _, err := eventbus.Init(config.EventBus, config.DSN); err != nil {
+		log.Fatalf("初始化事件总线失败: %v", err)
+	}
+
 	if err := service.InitAudioService(config.Audio, storageManager); err != nil {
 		log.Fatalf("初始化音频子系统失败: %v", err)
 	}
@@ -161,6 +167,11 @@ func main() {
 		HTMLMaxConcurrency:  config.Export.HTMLMaxConcurrency,
 	})
 
+	// 清理过期的续传会话
+	service.StartUploadSessionCleanupWorker(10 * time.Minute)
+	service.StartEventReplayCleanupWorker(10 * time.Minute)
+	service.StartOneBotIDCleanupWorker(time.Hour)
+
 	// 启动未读消息邮件通知 Worker
 	if config.EmailNotification.Enabled {
 		service.StartUnreadNotificationWorker(service.UnreadNotificationWorkerConfig{
@@ -20,8 +20,13 @@ type AttachmentModel struct {
 	Hash        ByteArray   `gorm:"index,size:100" json:"hash"` // hash是32byte
 	Filename    string      `json:"filename"`
 	Size        int64       `gorm:"index" json:"size"`
-	MimeType    string      `json:"mimeType" gorm:"size:64"` // MIME type (e.g., image/webp, image/gif)
-	IsAnimated  bool        `json:"isAnimated"`              // 是否为动态图片（如动态WebP、GIF）
+	MimeType    string      `json:"mimeType" gorm:"size:64"`                // MIME type (e.g., image/webp, image/gif)
+	IsAnimated  bool        `json:"isAnimated"`                             // 是否为动态图片（如动态WebP、GIF）
+	MediaKind   string      `json:"mediaKind,omitempty" gorm:"size:16"`     // 附件类别：image/video/audio/document/text/file
+	Width       int         `json:"width,omitempty"`                        // 视频或图片宽度（像素）
+	Height      int         `json:"height,omitempty"`                       // 视频或图片高度（像素）
+	Duration    float64     `json:"duration,omitempty"`                     // 视频时长（秒）
+	PreviewText string      `json:"previewText,omitempty" gorm:"type:text"` // 文本类附件的内容摘录
 	UserID      string      `json:"userId" gorm:"index"`
 	ChannelID   string      `json:"channel_id"` // 上传的频道ID
 	StorageType StorageType `json:"storageType" gorm:"type:varchar(16);default:'local'"`
@@ -7,6 +7,17 @@ import (
 	"time"
 )
 
+// BOT 令牌的权限范围
+const (
+	BotScopeMessageRead  = "message.read"
+	BotScopeMessageWrite = "message.write"
+	BotScopeStickyNote   = "sticky_note"
+	BotScopeCharacter    = "character"
+	BotScopeAudio        = "audio"
+)
+
+var BotScopeAll = []string{BotScopeMessageRead, BotScopeMessageWrite, BotScopeStickyNote, BotScopeCharacter, BotScopeAudio}
+
 type BotTokenModel struct {
 	StringPKBaseModel
 	Name         string `json:"name"`
@@ -15,12 +26,64 @@ type BotTokenModel struct {
 	Token        string `json:"token" gorm:"index"`
 	ExpiresAt    int64  `json:"expiresAt"`
 	RecentUsedAt int64  `json:"recentUsedAt"`
+
+	// 以下限制为空时均表示不限制，兼容旧令牌
+	Scopes            JSONList[string] `json:"scopes" gorm:"type:text"`
+	AllowedWorldIDs   JSONList[string] `json:"allowedWorldIds" gorm:"type:text"`
+	AllowedChannelIDs JSONList[string] `json:"allowedChannelIds" gorm:"type:text"`
+	// EventTypes 订阅的事件类型，支持以 * 结尾的前缀匹配，如 message-*
+	EventTypes JSONList[string] `json:"eventTypes" gorm:"type:text"`
 }
 
 func (*BotTokenModel) TableName() string {
 	return "bot_tokens"
 }
 
+func (m *BotTokenModel) HasScope(scope string) bool {
+	if len(m.Scopes) == 0 {
+		return true
+	}
+	for _, s := range m.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsChannel 频道是否在令牌允许的范围内；私聊频道不属于任何世界，由调用方单独处理
+func (m *BotTokenModel) AllowsChannel(channelID, worldID string) bool {
+	if len(m.AllowedWorldIDs) == 0 && len(m.AllowedChannelIDs) == 0 {
+		return true
+	}
+	for _, id := range m.AllowedChannelIDs {
+		if id == channelID {
+			return true
+		}
+	}
+	if worldID == "" {
+		return false
+	}
+	for _, id := range m.AllowedWorldIDs {
+		if id == worldID {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *BotTokenModel) SubscribesEvent(eventType string) bool {
+	if len(m.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range m.EventTypes {
+		if t == eventType || (strings.HasSuffix(t, "*") && strings.HasPrefix(eventType, strings.TrimSuffix(t, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
 func BotTokenGet(id string) (*BotTokenModel, error) {
 	if strings.TrimSpace(id) == "" {
 		return nil, nil
@@ -14,12 +14,15 @@ const (
 	ChannelStatusArchived = "archived" // 归档状态
 )
 
+// ChannelKindCategory 分类节点，只用于组织子频道
+const ChannelKindCategory = "category"
+
 type ChannelModel struct {
 	StringPKBaseModel
 	WorldID            string `json:"worldId" gorm:"size:100;index"`
 	Name               string `json:"name"`
 	Note               string `json:"note"`                   // 这是一份注释，用于管理人员辨别数据
-	RootId             string `json:"rootId"`                 // 如果未来有多级子频道，那么rootId指向顶层
+	RootId             string `json:"rootId"`                 // 所在频道树的顶层频道，顶层频道自身为空
 	ParentID           string `json:"parentId" gorm:"null"`   // 好像satori协议这里不统一啊
 	IsPrivate          bool   `json:"isPrivate" gorm:"index"` // 是私聊频道吗？
 	RecentSentAt       int64  `json:"recentSentAt"`           // 最近发送消息的时间
@@ -29,6 +32,11 @@ type ChannelModel struct {
 	BuiltInDiceEnabled bool   `json:"builtInDiceEnabled" gorm:"default:true"`
 	BotFeatureEnabled  bool   `json:"botFeatureEnabled" gorm:"default:false"`
 	Status             string `json:"status" gorm:"size:24;default:active;index"`
+	Kind               string `json:"kind" gorm:"size:16"`               // 为 category 时是分类节点，不承载消息
+	PermOverride       bool   `json:"permOverride" gorm:"default:false"` // 不继承所属分类的权限，仅使用本频道角色
+	ReadReceipts       bool   `json:"readReceipts" gorm:"default:false"` // 开启后成员可查看消息的已读名单
+
+	GMRoleIDs JSONList[string] `json:"gmRoleIds" gorm:"type:text"` // 可查看 GM 暗骰的频道角色，世界管理员始终可见
 
 	SortOrder int `json:"sortOrder" gorm:"index"` // 优先级序号，越大越靠前
 
@@ -182,6 +190,8 @@ func ChannelInfoEdit(channelId string, updates *ChannelModel) error {
 		Updates(updates).Error; err != nil {
 		return err
 	}
+	// 公开与非公开切换会改变访客角色的判定
+	PermCacheInvalidateAll()
 	return nil
 }
 
@@ -193,17 +203,21 @@ func (c *ChannelModel) ToProtocolType() *protocol.Channel {
 	channelType := protocol.TextChannelType
 	if c.IsPrivate {
 		channelType = protocol.DirectChannelType
+	} else if c.IsCategory() {
+		channelType = protocol.CategoryChannelType
 	}
 	return &protocol.Channel{
 		ID:                 c.ID,
 		WorldID:            c.WorldID,
 		Name:               c.Name,
 		Type:               channelType,
+		ParentID:           c.ParentID,
 		DefaultDiceExpr:    c.DefaultDiceExpr,
 		BuiltInDiceEnabled: c.BuiltInDiceEnabled,
 		BotFeatureEnabled:  c.BotFeatureEnabled,
 		BackgroundAttachmentId: c.BackgroundAttachmentId,
 		BackgroundSettings:     c.BackgroundSettings,
+		GMRoleIDs:              c.GMRoleIDs,
 	}
 }
 
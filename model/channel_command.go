@@ -0,0 +1,55 @@
+package model
+
+import (
+	"gorm.io/gorm"
+)
+
+// ChannelCommandDisableModel 记录频道内停用的 BOT 指令，未记录的指令默认启用
+type ChannelCommandDisableModel struct {
+	StringPKBaseModel
+	ChannelID string `json:"channelId" gorm:"size:100;uniqueIndex:idx_channel_command_disable,priority:1"`
+	BotID     string `json:"botId" gorm:"size:100;uniqueIndex:idx_channel_command_disable,priority:2"`
+	Name      string `json:"name" gorm:"size:100;uniqueIndex:idx_channel_command_disable,priority:3"`
+}
+
+func (*ChannelCommandDisableModel) TableName() string {
+	return "channel_command_disables"
+}
+
+func (m *ChannelCommandDisableModel) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		m.Init()
+	}
+	return nil
+}
+
+// ChannelCommandDisabledSet 返回频道内停用的指令，键为 botID + "|" + 指令名
+func ChannelCommandDisabledSet(channelID string) (map[string]bool, error) {
+	var items []ChannelCommandDisableModel
+	if err := GetDB().Where("channel_id = ?", channelID).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	ret := make(map[string]bool, len(items))
+	for _, item := range items {
+		ret[item.BotID+"|"+item.Name] = true
+	}
+	return ret, nil
+}
+
+// ChannelCommandSetEnabled 设置频道内某条指令的启用状态
+func ChannelCommandSetEnabled(channelID, botID, name string, enabled bool) error {
+	db := GetDB()
+	if enabled {
+		return db.Where("channel_id = ? AND bot_id = ? AND name = ?", channelID, botID, name).
+			Delete(&ChannelCommandDisableModel{}).Error
+	}
+	var item ChannelCommandDisableModel
+	if err := db.Where("channel_id = ? AND bot_id = ? AND name = ?", channelID, botID, name).Limit(1).Find(&item).Error; err != nil {
+		return err
+	}
+	if item.ID != "" {
+		return nil
+	}
+	item = ChannelCommandDisableModel{ChannelID: channelID, BotID: botID, Name: name}
+	return db.Create(&item).Error
+}
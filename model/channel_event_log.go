@@ -0,0 +1,55 @@
+package model
+
+import "time"
+
+// ChannelEventLogModel 频道事件回放日志，用于断线重连时补发内存缓冲之外的事件
+type ChannelEventLogModel struct {
+	StringPKBaseModel
+
+	ChannelID string `json:"channel_id" gorm:"size:100;index:idx_channel_event_seq,priority:1"`
+	Seq       int64  `json:"seq" gorm:"index:idx_channel_event_seq,priority:2"`
+	EventType string `json:"event_type" gorm:"size:64"`
+	// Payload 为下发给客户端的完整帧
+	Payload string `json:"payload" gorm:"type:text"`
+	// Audience 为空表示频道内所有人可见
+	Audience JSONList[string] `json:"audience" gorm:"type:text"`
+	Excluded JSONList[string] `json:"excluded" gorm:"type:text"`
+}
+
+func (*ChannelEventLogModel) TableName() string {
+	return "channel_event_logs"
+}
+
+func ChannelEventLogBatchCreate(items []*ChannelEventLogModel) error {
+	if len(items) == 0 {
+		return nil
+	}
+	return db.CreateInBatches(items, 200).Error
+}
+
+func ChannelEventLogMaxSeq(channelID string) (int64, error) {
+	var seq *int64
+	err := db.Model(&ChannelEventLogModel{}).
+		Where("channel_id = ?", channelID).
+		Select("MAX(seq)").
+		Scan(&seq).Error
+	if err != nil || seq == nil {
+		return 0, err
+	}
+	return *seq, nil
+}
+
+// ChannelEventLogListAfter 按序号升序返回 afterSeq 之后的事件
+func ChannelEventLogListAfter(channelID string, afterSeq int64, limit int) ([]*ChannelEventLogModel, error) {
+	var items []*ChannelEventLogModel
+	err := db.Where("channel_id = ? AND seq > ?", channelID, afterSeq).
+		Order("seq asc").
+		Limit(limit).
+		Find(&items).Error
+	return items, err
+}
+
+func ChannelEventLogPurgeBefore(t time.Time) (int64, error) {
+	result := db.Where("created_at < ?", t).Delete(&ChannelEventLogModel{})
+	return result.RowsAffected, result.Error
+}
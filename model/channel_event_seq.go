@@ -0,0 +1,61 @@
+package model
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ChannelEventSeqModel 频道事件序号计数器，多实例部署时由数据库统一分配序号
+type ChannelEventSeqModel struct {
+	ChannelID string `json:"channel_id" gorm:"primaryKey;size:100"`
+	Seq       int64  `json:"seq"`
+}
+
+func (*ChannelEventSeqModel) TableName() string {
+	return "channel_event_seqs"
+}
+
+// ChannelEventSeqNext 在事务内自增并读取频道序号，UPDATE 持有的行锁保证各实例拿到的序号不重复。
+// 计数器不存在时以 floor 为起点创建
+func ChannelEventSeqNext(channelID string, floor int64) (int64, error) {
+	var seq int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for attempt := 0; attempt < 2; attempt++ {
+			ret := tx.Model(&ChannelEventSeqModel{}).
+				Where("channel_id = ?", channelID).
+				Update("seq", gorm.Expr("seq + 1"))
+			if ret.Error != nil {
+				return ret.Error
+			}
+			if ret.RowsAffected > 0 {
+				return tx.Model(&ChannelEventSeqModel{}).
+					Where("channel_id = ?", channelID).
+					Pluck("seq", &seq).Error
+			}
+			item := &ChannelEventSeqModel{ChannelID: channelID, Seq: floor + 1}
+			ret = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(item)
+			if ret.Error != nil {
+				return ret.Error
+			}
+			if ret.RowsAffected > 0 {
+				seq = item.Seq
+				return nil
+			}
+			// 其他实例抢先创建了计数器，重新自增
+		}
+		return gorm.ErrRecordNotFound
+	})
+	return seq, err
+}
+
+// ChannelEventSeqCurrent 返回频道计数器当前值，不存在时为 0
+func ChannelEventSeqCurrent(channelID string) (int64, error) {
+	var seqs []int64
+	err := db.Model(&ChannelEventSeqModel{}).
+		Where("channel_id = ?", channelID).
+		Pluck("seq", &seqs).Error
+	if err != nil || len(seqs) == 0 {
+		return 0, err
+	}
+	return seqs[0], nil
+}
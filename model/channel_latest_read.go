@@ -202,3 +202,14 @@ func ChannelGetFirstUnreadInfo(channelId, userId string, options *FirstUnreadFil
 	}
 	return "", 0, nil
 }
+
+// ChannelReadListSince 已读位置不早于 since（毫秒）的记录，userIds 非空时只查这些用户
+func ChannelReadListSince(channelId string, since int64, userIds []string) ([]*ChannelLatestReadModel, error) {
+	var records []*ChannelLatestReadModel
+	q := db.Where("channel_id = ? AND message_time >= ?", channelId, since)
+	if len(userIds) > 0 {
+		q = q.Where("user_id IN ?", userIds)
+	}
+	err := q.Order("message_time ASC").Find(&records).Error
+	return records, err
+}
@@ -0,0 +1,93 @@
+package model
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const (
+	PermOverwriteAllow = "allow"
+	PermOverwriteDeny  = "deny"
+)
+
+// ChannelPermOverwriteModel 频道内针对单个用户的权限覆盖，叠加在角色权限之上，优先于角色判定
+type ChannelPermOverwriteModel struct {
+	StringPKBaseModel
+	WorldID      string `json:"worldId" gorm:"size:100;index"`
+	ChannelID    string `json:"channelId" gorm:"size:100;uniqueIndex:idx_channel_perm_overwrite,priority:1"`
+	UserID       string `json:"userId" gorm:"size:100;uniqueIndex:idx_channel_perm_overwrite,priority:2;index"`
+	PermissionID string `json:"permissionId" gorm:"size:100;uniqueIndex:idx_channel_perm_overwrite,priority:3"`
+	Effect       string `json:"effect" gorm:"size:8"` // allow/deny
+	CreatorID    string `json:"creatorId" gorm:"size:100"`
+}
+
+func (*ChannelPermOverwriteModel) TableName() string {
+	return "channel_perm_overwrites"
+}
+
+var permOverwriteCache = NewPermCache[map[string]string]("perm_overwrites")
+
+// ChannelPermOverwriteMapCached 用户在频道上的覆盖，权限ID -> allow/deny，结果只读
+func ChannelPermOverwriteMapCached(userID, channelID string) (map[string]string, error) {
+	return permOverwriteCache.Load(userID, userID+"|"+channelID, func() (map[string]string, error) {
+		var items []*ChannelPermOverwriteModel
+		if err := db.Where("channel_id = ? AND user_id = ?", channelID, userID).Find(&items).Error; err != nil {
+			return nil, err
+		}
+		result := make(map[string]string, len(items))
+		for _, item := range items {
+			result[item.PermissionID] = item.Effect
+		}
+		return result, nil
+	})
+}
+
+// ChannelPermOverwriteList 列出频道的覆盖，userID 为空时返回全部用户
+func ChannelPermOverwriteList(channelID, userID string) ([]*ChannelPermOverwriteModel, error) {
+	var items []*ChannelPermOverwriteModel
+	q := db.Where("channel_id = ?", channelID)
+	if userID != "" {
+		q = q.Where("user_id = ?", userID)
+	}
+	err := q.Order("user_id ASC, permission_id ASC").Find(&items).Error
+	return items, err
+}
+
+// ChannelPermOverwriteReplace 用新的允许/禁止列表整体替换用户在频道上的覆盖
+func ChannelPermOverwriteReplace(worldID, channelID, userID, creatorID string, allow, deny []string) error {
+	defer PermCacheInvalidateUser(userID)
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("channel_id = ? AND user_id = ?", channelID, userID).
+			Delete(&ChannelPermOverwriteModel{}).Error; err != nil {
+			return err
+		}
+		var items []*ChannelPermOverwriteModel
+		add := func(ids []string, effect string) {
+			for _, id := range ids {
+				item := &ChannelPermOverwriteModel{
+					WorldID:      worldID,
+					ChannelID:    channelID,
+					UserID:       userID,
+					PermissionID: strings.TrimSpace(id),
+					Effect:       effect,
+					CreatorID:    creatorID,
+				}
+				item.Init()
+				items = append(items, item)
+			}
+		}
+		add(allow, PermOverwriteAllow)
+		add(deny, PermOverwriteDeny)
+		if len(items) == 0 {
+			return nil
+		}
+		return tx.Create(&items).Error
+	})
+}
+
+// ChannelPermOverwriteDeleteByWorldUser 成员离开世界时清理其在该世界频道上的覆盖
+func ChannelPermOverwriteDeleteByWorldUser(worldID, userID string) error {
+	defer PermCacheInvalidateUser(userID)
+	return db.Where("world_id = ? AND user_id = ?", worldID, userID).Delete(&ChannelPermOverwriteModel{}).Error
+}
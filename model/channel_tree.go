@@ -0,0 +1,79 @@
+package model
+
+import "strings"
+
+// ChannelTreeMaxDepth 沿父链查找时的上限，防止异常数据形成环
+const ChannelTreeMaxDepth = 64
+
+func (c *ChannelModel) IsCategory() bool {
+	return c != nil && c.Kind == ChannelKindCategory
+}
+
+// ChannelAncestors 返回频道的祖先链，由近及远
+func ChannelAncestors(ch *ChannelModel) []*ChannelModel {
+	var result []*ChannelModel
+	if ch == nil {
+		return result
+	}
+	seen := map[string]bool{ch.ID: true}
+	parentID := strings.TrimSpace(ch.ParentID)
+	for i := 0; parentID != "" && i < ChannelTreeMaxDepth; i++ {
+		if seen[parentID] {
+			break
+		}
+		seen[parentID] = true
+		parent, err := ChannelGet(parentID)
+		if err != nil || parent == nil || parent.ID == "" {
+			break
+		}
+		result = append(result, parent)
+		parentID = strings.TrimSpace(parent.ParentID)
+	}
+	return result
+}
+
+// ChannelDescendantIDs 逐层查找子孙频道ID，不包含传入的频道本身；statuses 为空时不限状态
+func ChannelDescendantIDs(channelIDs []string, statuses ...string) ([]string, error) {
+	seen := map[string]bool{}
+	for _, id := range channelIDs {
+		seen[id] = true
+	}
+	var result []string
+	frontier := channelIDs
+	for depth := 0; len(frontier) > 0 && depth < ChannelTreeMaxDepth; depth++ {
+		q := db.Model(&ChannelModel{}).Where("parent_id IN ?", frontier)
+		if len(statuses) > 0 {
+			q = q.Where("status IN ?", statuses)
+		}
+		var children []string
+		if err := q.Pluck("id", &children).Error; err != nil {
+			return nil, err
+		}
+		frontier = frontier[:0:0]
+		for _, id := range children {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			result = append(result, id)
+			frontier = append(frontier, id)
+		}
+	}
+	return result, nil
+}
+
+// ChannelRootIDFor 计算挂在 parentID 下的频道应记录的顶层频道ID
+func ChannelRootIDFor(parentID string) string {
+	parentID = strings.TrimSpace(parentID)
+	if parentID == "" {
+		return ""
+	}
+	parent, err := ChannelGet(parentID)
+	if err != nil || parent == nil || parent.ID == "" {
+		return parentID
+	}
+	if parent.RootId != "" {
+		return parent.RootId
+	}
+	return parent.ID
+}
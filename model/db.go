@@ -115,11 +115,18 @@ func DBInit(cfg *utils.AppConfig) {
 	db.AutoMigrate(&MessageDiceRollModel{})
 	db.AutoMigrate(&MessageEditHistoryModel{})
 	db.AutoMigrate(&MessageArchiveLogModel{})
+	db.AutoMigrate(&MessageBulkJobModel{})
+	db.AutoMigrate(&MessageBulkLockModel{})
+	db.AutoMigrate(&ChannelEventLogModel{})
+	db.AutoMigrate(&ChannelEventSeqModel{})
+	db.AutoMigrate(&SealBotInitiativeModel{})
+	db.AutoMigrate(&EventBusSpillModel{})
 	db.AutoMigrate(&MessageReactionModel{}, &MessageReactionCountModel{})
 	db.AutoMigrate(&UserModel{})
 	db.AutoMigrate(&AccessTokenModel{})
 	db.AutoMigrate(&MemberModel{})
 	db.AutoMigrate(&AttachmentModel{})
+	db.AutoMigrate(&UploadSessionModel{})
 	db.AutoMigrate(&ChannelAttachmentImageLayoutModel{})
 	db.AutoMigrate(&MentionModel{})
 	db.AutoMigrate(&TimelineModel{})
@@ -141,6 +148,10 @@ func DBInit(cfg *utils.AppConfig) {
 	db.AutoMigrate(&MessageExportJobModel{})
 	db.AutoMigrate(&ChannelIFormModel{})
 	db.AutoMigrate(&WorldModel{}, &WorldMemberModel{}, &WorldInviteModel{}, &WorldFavoriteModel{}, &WorldKeywordModel{})
+	db.AutoMigrate(&WorldJoinApplicationModel{}, &WorldBanModel{})
+	db.AutoMigrate(&WorldRoleModel{}, &WorldRoleAssignmentModel{}, &WorldRoleChannelOverrideModel{})
+	db.AutoMigrate(&WorldSessionModel{}, &WorldSessionRSVPModel{}, &WorldSessionNotifyLogModel{}, &CalendarFeedTokenModel{})
+	db.AutoMigrate(&ChannelPermOverwriteModel{})
 	db.AutoMigrate(&ServiceMetricSample{})
 	db.AutoMigrate(&ChatImportJobModel{})
 	db.AutoMigrate(&ChannelWebhookIntegrationModel{}, &MessageExternalRefModel{}, &WebhookEventLogModel{}, &WebhookIdentityBindingModel{})
@@ -150,6 +161,8 @@ func DBInit(cfg *utils.AppConfig) {
 	db.AutoMigrate(&UpdateCheckState{})
 	db.AutoMigrate(&ConfigCurrentModel{}, &ConfigHistoryModel{})
 	db.AutoMigrate(&UserPreferenceModel{})
+	db.AutoMigrate(&OneBotIDMapModel{})
+	db.AutoMigrate(&ChannelCommandDisableModel{})
 
 	if err := db.Model(&ChannelModel{}).
 		Where("default_dice_expr = '' OR default_dice_expr IS NULL").
@@ -161,6 +174,10 @@ func DBInit(cfg *utils.AppConfig) {
 		log.Printf("补齐消息 display_order 失败: %v", err)
 	}
 
+	if err := MessageBulkJobFailInterrupted(); err != nil {
+		log.Printf("清理中断的批量消息任务失败: %v", err)
+	}
+
 	if err := BackfillChannelRecentSentAt(); err != nil {
 		log.Printf("回填频道最近发言时间失败: %v", err)
 	}
@@ -169,6 +186,10 @@ func DBInit(cfg *utils.AppConfig) {
 		log.Printf("初始化世界数据失败: %v", err)
 	}
 
+	if err := BackfillWorldSearchText(); err != nil {
+		log.Printf("回填世界检索文本失败: %v", err)
+	}
+
 	if IsSQLite() {
 		go func() {
 			if err := ensureSQLiteFTSManager(db); err != nil {
@@ -0,0 +1,47 @@
+package model
+
+import "time"
+
+// EventBusSpillModel 总线消息的落库副本。负载超出 NOTIFY 上限时通知中只携带 ID，
+// 监听连接断开重连后也据此补收断开期间的消息
+type EventBusSpillModel struct {
+	StringPKBaseModel
+
+	Payload string `json:"payload" gorm:"type:text"`
+}
+
+func (*EventBusSpillModel) TableName() string {
+	return "event_bus_spills"
+}
+
+func EventBusSpillCreate(payload string) (string, error) {
+	item := &EventBusSpillModel{Payload: payload}
+	item.Init()
+	if err := db.Create(item).Error; err != nil {
+		return "", err
+	}
+	return item.ID, nil
+}
+
+func EventBusSpillGet(id string) (string, error) {
+	var item EventBusSpillModel
+	if err := db.Where("id = ?", id).Limit(1).Find(&item).Error; err != nil {
+		return "", err
+	}
+	return item.Payload, nil
+}
+
+// EventBusSpillListSince 按写入时间升序返回 since 之后的消息
+func EventBusSpillListSince(since time.Time, limit int) ([]*EventBusSpillModel, error) {
+	var items []*EventBusSpillModel
+	err := db.Where("created_at >= ?", since).
+		Order("created_at asc, id asc").
+		Limit(limit).
+		Find(&items).Error
+	return items, err
+}
+
+// EventBusSpillPurgeBefore 各实例读取后即可丢弃，保留一段时间以容忍监听延迟
+func EventBusSpillPurgeBefore(t time.Time) error {
+	return db.Where("created_at < ?", t).Delete(&EventBusSpillModel{}).Error
+}
@@ -18,6 +18,7 @@ type MemberModel struct {
 
 func (u *MemberModel) SaveInfo() {
 	db.Model(u).Select("nickname").Updates(u)
+	PermCacheInvalidateUser(u.UserID)
 }
 
 func (*MemberModel) TableName() string {
@@ -47,6 +48,7 @@ func MemberGetByUserIDAndChannelIDBase(userId string, channelId string, defaultN
 		if createIfNotExists {
 			x := MemberModel{UserID: userId, ChannelID: channelId, Nickname: defaultName}
 			err = db.Create(&x).Error
+			PermCacheInvalidateUser(userId)
 			return &x, err
 		}
 		return nil, nil
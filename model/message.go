@@ -47,6 +47,8 @@ type MessageModel struct {
 	IsDeleted     bool       `json:"is_deleted" gorm:"default:false;index:idx_msg_deleted"` // 删除后不再展示
 	DeletedAt     *time.Time `json:"deleted_at"`
 	DeletedBy     string     `json:"deleted_by" gorm:"size:100"`
+	IsGMRoll      bool       `json:"is_gm_roll" gorm:"default:false"` // GM 暗骰，揭示前仅发送者与 GM 可见
+	GMRollMasked  bool       `json:"gm_roll_masked" gorm:"-"`
 
 	SenderMemberName       string `json:"sender_member_name"` // 用户在当时的名字
 	SenderIdentityID       string `json:"sender_identity_id" gorm:"size:100"`
@@ -114,6 +116,8 @@ func (m *MessageModel) ToProtocolType2(channelData *protocol.Channel) *protocol.
 		DeletedAt:        deletedAt,
 		DeletedBy:        m.DeletedBy,
 		WidgetData:       m.WidgetData,
+		IsGMRoll:         m.IsGMRoll,
+		GMRollMasked:     m.GMRollMasked,
 		WhisperTo: func() *protocol.User {
 			if m.WhisperTarget != nil {
 				return m.WhisperTarget.ToProtocolType()
@@ -0,0 +1,117 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	MessageBulkStatusRunning = "running"
+	MessageBulkStatusDone    = "done"
+	MessageBulkStatusFailed  = "failed"
+)
+
+const (
+	MessageBulkActionArchive          = "archive"
+	MessageBulkActionUnarchive        = "unarchive"
+	MessageBulkActionDelete           = "delete"
+	MessageBulkActionReassignIdentity = "reassign_identity"
+	MessageBulkActionSetICMode        = "set_ic_mode"
+	MessageBulkActionMove             = "move"
+)
+
+// MessageBulkJobModel 记录一次批量消息操作，便于追溯主持人的批量修改
+type MessageBulkJobModel struct {
+	StringPKBaseModel
+
+	WorldID         string  `json:"world_id" gorm:"size:100;index"`
+	ChannelID       string  `json:"channel_id" gorm:"size:100;index"`
+	OperatorID      string  `json:"operator_id" gorm:"size:100;index"`
+	Action          string  `json:"action" gorm:"size:32"`
+	Filter          JSONMap `json:"filter" gorm:"type:text"`
+	Params          JSONMap `json:"params" gorm:"type:text"`
+	TargetChannelID string  `json:"target_channel_id" gorm:"size:100"`
+
+	Total     int `json:"total"`
+	Processed int `json:"processed"`
+	Skipped   int `json:"skipped"`
+
+	Status     string     `json:"status" gorm:"index;size:24"`
+	ErrorMsg   string     `json:"error_msg" gorm:"type:text"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+func (*MessageBulkJobModel) TableName() string {
+	return "message_bulk_jobs"
+}
+
+func MessageBulkJobGet(id string) (*MessageBulkJobModel, error) {
+	var item MessageBulkJobModel
+	if err := db.Where("id = ?", id).Limit(1).Find(&item).Error; err != nil {
+		return nil, err
+	}
+	if item.ID == "" {
+		return nil, nil
+	}
+	return &item, nil
+}
+
+// MessageBulkLockModel 批量操作占用的频道，主键保证同一频道同时只有一个任务
+type MessageBulkLockModel struct {
+	ChannelID string    `json:"channel_id" gorm:"primaryKey;size:100"`
+	JobID     string    `json:"job_id" gorm:"size:100;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (*MessageBulkLockModel) TableName() string {
+	return "message_bulk_locks"
+}
+
+// MessageBulkJobCreateLocked 在同一事务中占用涉及的频道并创建任务；任一频道已被占用时返回 false
+func MessageBulkJobCreateLocked(job *MessageBulkJobModel, channelIDs []string) (bool, error) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		locks := make([]*MessageBulkLockModel, 0, len(channelIDs))
+		for _, id := range lo.Uniq(channelIDs) {
+			locks = append(locks, &MessageBulkLockModel{ChannelID: id, JobID: job.ID})
+		}
+		ret := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&locks)
+		if ret.Error != nil {
+			return ret.Error
+		}
+		if int(ret.RowsAffected) != len(locks) {
+			return errMessageBulkLockBusy
+		}
+		return tx.Create(job).Error
+	})
+	if errors.Is(err, errMessageBulkLockBusy) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// MessageBulkJobReleaseLocks 任务结束后释放占用的频道
+func MessageBulkJobReleaseLocks(jobID string) error {
+	return db.Where("job_id = ?", jobID).Delete(&MessageBulkLockModel{}).Error
+}
+
+var errMessageBulkLockBusy = errors.New("message bulk channel locked")
+
+// MessageBulkJobFailInterrupted 服务重启后仍处于运行中的任务已无法继续，统一标记失败并释放频道占用
+func MessageBulkJobFailInterrupted() error {
+	now := time.Now()
+	if err := db.Model(&MessageBulkJobModel{}).
+		Where("status = ?", MessageBulkStatusRunning).
+		Updates(map[string]any{
+			"status":      MessageBulkStatusFailed,
+			"error_msg":   "服务重启，任务已中断",
+			"finished_at": now,
+		}).Error; err != nil {
+		return err
+	}
+	return db.Where("job_id NOT IN (?)", db.Model(&MessageBulkJobModel{}).Select("id").Where("status = ?", MessageBulkStatusRunning)).
+		Delete(&MessageBulkLockModel{}).Error
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"time"
+)
+
+// OneBotIDMapModel OneBot v11 只认数字 ID，这里为用户、频道、消息分配稳定的数字编号。
+// 编号即自增主键，一经分配不再变化，BOT 侧可以放心持久化
+type OneBotIDMapModel struct {
+	ID        int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	Kind      string    `json:"kind" gorm:"size:16;uniqueIndex:idx_onebot_id_ref,priority:1"` // user/group/message
+	RefID     string    `json:"refId" gorm:"size:100;uniqueIndex:idx_onebot_id_ref,priority:2"`
+	CreatedAt time.Time `json:"createdAt" gorm:"index"`
+}
+
+func (*OneBotIDMapModel) TableName() string {
+	return "onebot_id_maps"
+}
+
+// OneBotIDMapGetOrCreate 返回 refID 对应的数字编号，不存在时分配一个
+func OneBotIDMapGetOrCreate(kind, refID string) (int64, error) {
+	var item OneBotIDMapModel
+	if err := db.Where("kind = ? AND ref_id = ?", kind, refID).Limit(1).Find(&item).Error; err != nil {
+		return 0, err
+	}
+	if item.ID != 0 {
+		return item.ID, nil
+	}
+	item = OneBotIDMapModel{Kind: kind, RefID: refID}
+	if err := db.Create(&item).Error; err != nil {
+		// 并发分配时唯一索引冲突，以先写入的为准
+		var existing OneBotIDMapModel
+		db.Where("kind = ? AND ref_id = ?", kind, refID).Limit(1).Find(&existing)
+		if existing.ID != 0 {
+			return existing.ID, nil
+		}
+		return 0, err
+	}
+	return item.ID, nil
+}
+
+// OneBotIDMapResolve 由数字编号反查 refID，未分配过时返回空串
+func OneBotIDMapResolve(kind string, id int64) (string, error) {
+	var item OneBotIDMapModel
+	if err := db.Where("id = ? AND kind = ?", id, kind).Limit(1).Find(&item).Error; err != nil {
+		return "", err
+	}
+	return item.RefID, nil
+}
+
+// OneBotIDMapPurgeBefore 删除 kind 类型中早于 t 分配的编号，仅用于数量无上限的消息编号
+func OneBotIDMapPurgeBefore(kind string, t time.Time) (int64, error) {
+	result := db.Where("kind = ? AND created_at < ?", kind, t).Delete(&OneBotIDMapModel{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,210 @@
+package model
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 成员关系与权限判定缓存。
+// WebSocket 每一帧都会查询成员列表、角色与世界成员身份，结果绝大多数时候不变。
+// 条目按代数失效：用户级失效只让该用户的条目过期，全局失效让所有条目过期；
+// 另设较短的存活时间，兜底未显式失效的写入路径。
+const (
+	permCacheTTL        = time.Minute
+	permCacheSweepEvery = 1024
+)
+
+type permCacheStamp struct {
+	global uint64
+	user   uint64
+}
+
+var (
+	permCacheGlobalGen atomic.Uint64
+	permCacheUserGens  sync.Map // userID -> *atomic.Uint64
+
+	permCacheRegistryMu sync.Mutex
+	permCacheRegistry   []permCacheStatter
+
+	// permCacheSync 把本实例发起的失效同步给其他实例，多实例部署时由事件总线注册
+	permCacheSync atomic.Pointer[func(userIDs []string, all bool)]
+)
+
+func currentPermCacheStamp(userID string) permCacheStamp {
+	stamp := permCacheStamp{global: permCacheGlobalGen.Load()}
+	if value, ok := permCacheUserGens.Load(userID); ok {
+		stamp.user = value.(*atomic.Uint64).Load()
+	}
+	return stamp
+}
+
+// PermCacheSetSync 注册跨实例同步失效的发布函数
+func PermCacheSetSync(fn func(userIDs []string, all bool)) {
+	if fn == nil {
+		permCacheSync.Store(nil)
+		return
+	}
+	permCacheSync.Store(&fn)
+}
+
+func publishPermCacheInvalidate(userIDs []string, all bool) {
+	if !all && len(userIDs) == 0 {
+		return
+	}
+	if fn := permCacheSync.Load(); fn != nil {
+		(*fn)(userIDs, all)
+	}
+}
+
+// PermCacheInvalidateUser 用户的角色、成员或世界身份变化后调用
+func PermCacheInvalidateUser(userIDs ...string) {
+	permCacheInvalidateUsersLocal(userIDs)
+	publishPermCacheInvalidate(userIDs, false)
+}
+
+// PermCacheInvalidateAll 角色权限调整、频道解散等影响多人的变更后调用
+func PermCacheInvalidateAll() {
+	permCacheGlobalGen.Add(1)
+	publishPermCacheInvalidate(nil, true)
+}
+
+// PermCacheApplyRemote 处理其他实例同步来的失效，只作用于本实例
+func PermCacheApplyRemote(userIDs []string, all bool) {
+	if all {
+		permCacheGlobalGen.Add(1)
+		return
+	}
+	permCacheInvalidateUsersLocal(userIDs)
+}
+
+func permCacheInvalidateUsersLocal(userIDs []string) {
+	for _, userID := range userIDs {
+		if userID == "" {
+			continue
+		}
+		value, _ := permCacheUserGens.LoadOrStore(userID, &atomic.Uint64{})
+		value.(*atomic.Uint64).Add(1)
+	}
+}
+
+type permCacheEntry[T any] struct {
+	value    T
+	stamp    permCacheStamp
+	expireAt time.Time
+}
+
+// PermCache 按用户分代的读穿缓存，key 需自行包含用户 ID 以免串号
+type PermCache[T any] struct {
+	name    string
+	entries sync.Map
+	stores  atomic.Int64
+	hits    atomic.Int64
+	misses  atomic.Int64
+}
+
+func NewPermCache[T any](name string) *PermCache[T] {
+	c := &PermCache[T]{name: name}
+	permCacheRegistryMu.Lock()
+	permCacheRegistry = append(permCacheRegistry, c)
+	permCacheRegistryMu.Unlock()
+	return c
+}
+
+// Load 命中则直接返回，否则调用 fill 并缓存成功的结果
+func (c *PermCache[T]) Load(userID, key string, fill func() (T, error)) (T, error) {
+	stamp := currentPermCacheStamp(userID)
+	now := time.Now()
+	if value, ok := c.entries.Load(key); ok {
+		entry := value.(*permCacheEntry[T])
+		if entry.stamp == stamp && now.Before(entry.expireAt) {
+			c.hits.Add(1)
+			return entry.value, nil
+		}
+	}
+	c.misses.Add(1)
+	value, err := fill()
+	if err != nil {
+		return value, err
+	}
+	// 使用查询前的代数，查询期间发生的失效会让这条结果下次直接作废
+	c.entries.Store(key, &permCacheEntry[T]{value: value, stamp: stamp, expireAt: now.Add(permCacheTTL)})
+	if c.stores.Add(1)%permCacheSweepEvery == 0 {
+		c.sweep(now)
+	}
+	return value, nil
+}
+
+func (c *PermCache[T]) sweep(now time.Time) {
+	c.entries.Range(func(key, value any) bool {
+		if now.After(value.(*permCacheEntry[T]).expireAt) {
+			c.entries.Delete(key)
+		}
+		return true
+	})
+}
+
+type permCacheStatter interface {
+	stat() PermCacheStat
+}
+
+func (c *PermCache[T]) stat() PermCacheStat {
+	hits, misses := c.hits.Load(), c.misses.Load()
+	item := PermCacheStat{Name: c.name, Hits: hits, Misses: misses}
+	if total := hits + misses; total > 0 {
+		item.HitRate = float64(hits) / float64(total)
+	}
+	return item
+}
+
+type PermCacheStat struct {
+	Name    string  `json:"name"`
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hitRate"`
+}
+
+// PermCacheStats 各缓存自启动以来的命中情况
+func PermCacheStats() []PermCacheStat {
+	permCacheRegistryMu.Lock()
+	caches := append([]permCacheStatter(nil), permCacheRegistry...)
+	permCacheRegistryMu.Unlock()
+	items := make([]PermCacheStat, 0, len(caches))
+	for _, c := range caches {
+		items = append(items, c.stat())
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	return items
+}
+
+var (
+	memberListCache = NewPermCache[[]*MemberModel]("members")
+	roleListCache   = NewPermCache[[]string]("user_roles")
+)
+
+// MemberListByUserIDCached 返回用户的频道成员记录副本，调用方可以放心修改
+func MemberListByUserIDCached(userID string) ([]*MemberModel, error) {
+	items, err := memberListCache.Load(userID, userID, func() ([]*MemberModel, error) {
+		var members []*MemberModel
+		err := db.Where("user_id = ?", userID).Find(&members).Error
+		return members, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	copied := make([]*MemberModel, len(items))
+	for i, item := range items {
+		clone := *item
+		copied[i] = &clone
+	}
+	return copied, nil
+}
+
+// UserRoleMappingListByUserIDCached 同 UserRoleMappingListByUserID，结果只读
+func UserRoleMappingListByUserIDCached(userID string, channelId string, roleType string) ([]string, error) {
+	key := userID + "|" + channelId + "|" + roleType
+	return roleListCache.Load(userID, key, func() ([]string, error) {
+		return UserRoleMappingListByUserID(userID, channelId, roleType)
+	})
+}
@@ -215,6 +215,7 @@ func RolePermissionList(roleID string) ([]string, error) {
 // UserRoleMappingCreate 创建用户角色关系
 func UserRoleMappingCreate(userRole *UserRoleMappingModel) error {
 	userRole.Init()
+	defer PermCacheInvalidateUser(userRole.UserID)
 	return db.Create(userRole).Error
 }
 
@@ -227,11 +228,13 @@ func UserRoleMappingGet(id string) (*UserRoleMappingModel, error) {
 
 // UserRoleUpdate 更新用户角色关系
 func UserRoleUpdate(userRole *UserRoleMappingModel) error {
+	defer PermCacheInvalidateUser(userRole.UserID)
 	return db.Save(userRole).Error
 }
 
 // UserRoleUnlink 删除用户角色关系
 func UserRoleUnlink(roleIds []string, userIds []string) (int64, error) {
+	defer PermCacheInvalidateUser(userIds...)
 	// 直接删除用户角色
 	result := db.Unscoped().Where("user_id in ? AND role_id in ?", userIds, roleIds).Delete(&UserRoleMappingModel{})
 	if err := result.Error; err != nil {
@@ -244,6 +247,7 @@ func UserRoleUnlink(roleIds []string, userIds []string) (int64, error) {
 
 // UserRoleLink
 func UserRoleLink(roleIds []string, userIds []string) (int64, error) {
+	defer PermCacheInvalidateUser(userIds...)
 	tx := db.Begin()
 	if tx.Error != nil {
 		return 0, tx.Error
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// SealBotInitiativeModel 小海豹的频道先攻列表，存库以便多实例与重启后保持一致
+type SealBotInitiativeModel struct {
+	ChannelID string    `json:"channel_id" gorm:"primaryKey;size:100"`
+	Name      string    `json:"name" gorm:"primaryKey;size:100"`
+	Value     int64     `json:"value"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (*SealBotInitiativeModel) TableName() string {
+	return "seal_bot_initiatives"
+}
+
+// SealBotInitiativeSet 设置先攻点数，已存在时只更新点数，保留加入顺序
+func SealBotInitiativeSet(channelID, name string, value int64) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "channel_id"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value"}),
+	}).Create(&SealBotInitiativeModel{ChannelID: channelID, Name: name, Value: value}).Error
+}
+
+// SealBotInitiativeList 按加入顺序返回频道先攻列表
+func SealBotInitiativeList(channelID string) ([]*SealBotInitiativeModel, error) {
+	var items []*SealBotInitiativeModel
+	err := db.Where("channel_id = ?", channelID).Order("created_at asc").Find(&items).Error
+	return items, err
+}
+
+// SealBotInitiativeDelete 移除一项，返回是否存在
+func SealBotInitiativeDelete(channelID, name string) (bool, error) {
+	ret := db.Where("channel_id = ? AND name = ?", channelID, name).Delete(&SealBotInitiativeModel{})
+	return ret.RowsAffected > 0, ret.Error
+}
+
+func SealBotInitiativeClear(channelID string) error {
+	return db.Where("channel_id = ?", channelID).Delete(&SealBotInitiativeModel{}).Error
+}
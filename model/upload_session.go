@@ -0,0 +1,117 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type UploadSessionStatus string
+
+const (
+	UploadSessionPending   UploadSessionStatus = "pending"
+	UploadSessionCompleted UploadSessionStatus = "completed"
+	UploadSessionFailed    UploadSessionStatus = "failed"
+	UploadSessionCancelled UploadSessionStatus = "cancelled"
+	UploadSessionExpired   UploadSessionStatus = "expired"
+)
+
+type UploadSessionPurpose string
+
+const (
+	UploadPurposeAttachment UploadSessionPurpose = "attachment"
+	UploadPurposeAudio      UploadSessionPurpose = "audio"
+)
+
+// UploadSessionModel 可续传的分片上传会话
+type UploadSessionModel struct {
+	StringPKBaseModel
+	UserID    string               `json:"userId" gorm:"size:100;index"`
+	Purpose   UploadSessionPurpose `json:"purpose" gorm:"size:16"`
+	Filename  string               `json:"filename"`
+	MimeType  string               `json:"mimeType" gorm:"size:128"` // 客户端声明的类型，完成时以服务端嗅探结果为准
+	TotalSize int64                `json:"totalSize"`
+	Offset    int64                `json:"offset" gorm:"column:upload_offset"` // offset 为 SQL 关键字，换用列名
+	SHA256    string               `json:"sha256" gorm:"size:64;index"`        // 客户端提供的 SHA-256，完成时校验
+	ChannelID string               `json:"channelId" gorm:"size:100"`
+	Options   JSONMap              `json:"options,omitempty" gorm:"type:json"` // 交接给附件/音频流程时使用的额外参数
+	TempPath  string               `json:"-"`
+	Direct    bool                 `json:"direct"`                        // 客户端直传 S3，服务端只负责签发与确认
+	ObjectKey string               `json:"-"`                             // 直传时预先分配的对象键
+	Hash      string               `json:"hash,omitempty" gorm:"size:64"` // 直传时声明的 blake2s 哈希
+
+	Status       UploadSessionStatus `json:"status" gorm:"size:16;index"`
+	ExpiresAt    time.Time           `json:"expiresAt" gorm:"index"`
+	ResultID     string              `json:"resultId,omitempty" gorm:"size:100"` // 完成后生成的附件或音频素材ID
+	ErrorMessage string              `json:"errorMessage,omitempty"`
+}
+
+func (*UploadSessionModel) TableName() string {
+	return "upload_sessions"
+}
+
+func UploadSessionGet(id string) (*UploadSessionModel, error) {
+	var item UploadSessionModel
+	if err := db.Where("id = ?", id).Limit(1).Find(&item).Error; err != nil {
+		return nil, err
+	}
+	if item.ID == "" {
+		return nil, nil
+	}
+	return &item, nil
+}
+
+// UploadSessionAdvanceOffset 以旧偏移为条件推进偏移，避免并发写入同一会话
+func UploadSessionAdvanceOffset(id string, from, to int64, expiresAt time.Time) (bool, error) {
+	ret := db.Model(&UploadSessionModel{}).
+		Where("id = ? AND status = ? AND upload_offset = ?", id, UploadSessionPending, from).
+		Updates(map[string]any{
+			"upload_offset": to,
+			"expires_at":    expiresAt,
+		})
+	if ret.Error != nil {
+		return false, ret.Error
+	}
+	return ret.RowsAffected == 1, nil
+}
+
+func UploadSessionFinish(id string, status UploadSessionStatus, resultID string, errMsg string) error {
+	return db.Model(&UploadSessionModel{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"status":        status,
+			"result_id":     resultID,
+			"error_message": errMsg,
+		}).Error
+}
+
+// UploadSessionFindCompletedBySHA256 查找同内容的已完成附件会话，用于秒传
+func UploadSessionFindCompletedBySHA256(sha256 string, size int64) (*UploadSessionModel, error) {
+	var item UploadSessionModel
+	err := db.Where("sha256 = ? AND total_size = ? AND purpose = ? AND status = ? AND result_id <> ''",
+		sha256, size, UploadPurposeAttachment, UploadSessionCompleted).
+		Order("created_at ASC").
+		Limit(1).
+		Find(&item).Error
+	if err != nil {
+		return nil, err
+	}
+	if item.ID == "" {
+		return nil, nil
+	}
+	return &item, nil
+}
+
+func UploadSessionListExpired(now time.Time, limit int) ([]*UploadSessionModel, error) {
+	var items []*UploadSessionModel
+	err := db.Where("status = ? AND expires_at < ?", UploadSessionPending, now).
+		Limit(limit).
+		Find(&items).Error
+	return items, err
+}
+
+// UploadSessionPurgeFinished 清理已结束且超过保留期的会话记录
+func UploadSessionPurgeFinished(before time.Time) *gorm.DB {
+	return db.Where("status <> ? AND updated_at < ?", UploadSessionPending, before).
+		Delete(&UploadSessionModel{})
+}
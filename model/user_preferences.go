@@ -52,3 +52,15 @@ func UserPreferenceUpsert(userID, key, value string) (*UserPreferenceModel, erro
 	record.PrefValue = value
 	return record, nil
 }
+
+// UserPreferenceUserIDsWithValue 在 userIDs 中筛出偏好 key 取值属于 values 的用户
+func UserPreferenceUserIDsWithValue(key string, userIDs []string, values ...string) ([]string, error) {
+	var result []string
+	if len(userIDs) == 0 || len(values) == 0 {
+		return result, nil
+	}
+	err := db.Model(&UserPreferenceModel{}).
+		Where("pref_key = ? AND user_id IN ? AND pref_value IN ?", key, userIDs, values).
+		Pluck("user_id", &result).Error
+	return result, err
+}
@@ -36,6 +36,17 @@ type WorldModel struct {
 	DefaultChannelID        string `json:"defaultChannelId" gorm:"size:100"`
 	InviteSlug              string `json:"inviteSlug" gorm:"size:64;uniqueIndex"`
 	Status                  string `json:"status" gorm:"size:24;default:active;index"`
+	JoinMode                string `json:"joinMode" gorm:"size:24;default:open"`             // open/application
+	ApplicationForm         JSONList[WorldApplicationFormField] `json:"applicationForm" gorm:"type:text"` // 申请加入时填写的表单
+	Tags                    JSONList[string] `json:"tags" gorm:"type:text"`                 // 公开目录标签
+	Language                string `json:"language" gorm:"size:16;index"`                   // 主要语言，如 zh-CN
+	Genre                   string `json:"genre" gorm:"size:32;index"`                      // 规则体系/类型，如 CoC、D&D
+	RecruitingStatus        string `json:"recruitingStatus" gorm:"size:16;index"`           // open/closed，为空表示未说明
+	CoverImage              string `json:"coverImage" gorm:"size:255"`                      // 目录卡片封面
+	DirectoryHidden         bool   `json:"directoryHidden" gorm:"default:false"`            // 所有者选择不在公开目录中展示
+	Featured                bool   `json:"featured" gorm:"default:false;index"`             // 平台管理员推荐
+	FeaturedAt              *time.Time `json:"featuredAt"`
+	SearchText              string `json:"-" gorm:"size:2000"`                              // 目录检索用的小写汇总文本
 }
 
 func (*WorldModel) TableName() string {
@@ -55,6 +66,36 @@ func (m *WorldModel) BeforeCreate(tx *gorm.DB) error {
 	if strings.TrimSpace(m.Status) == "" {
 		m.Status = "active"
 	}
+	if strings.TrimSpace(m.JoinMode) == "" {
+		m.JoinMode = WorldJoinModeOpen
+	}
+	m.SearchText = m.BuildSearchText()
+	return nil
+}
+
+// BuildSearchText 汇总名称、简介、标签与类型，供公开目录检索
+func (m *WorldModel) BuildSearchText() string {
+	parts := []string{m.Name, m.Description, m.Genre, m.Language}
+	parts = append(parts, m.Tags...)
+	text := strings.ToLower(strings.Join(parts, "\n"))
+	if runes := []rune(text); len(runes) > 2000 {
+		text = string(runes[:2000])
+	}
+	return text
+}
+
+// BackfillWorldSearchText 为升级前创建的世界补齐检索文本
+func BackfillWorldSearchText() error {
+	db := GetDB()
+	var worlds []*WorldModel
+	if err := db.Where("search_text = '' OR search_text IS NULL").Find(&worlds).Error; err != nil {
+		return err
+	}
+	for _, w := range worlds {
+		if err := db.Model(&WorldModel{}).Where("id = ?", w.ID).Update("search_text", w.BuildSearchText()).Error; err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
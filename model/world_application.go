@@ -0,0 +1,55 @@
+package model
+
+import "time"
+
+const (
+	WorldJoinModeOpen        = "open"
+	WorldJoinModeApplication = "application"
+
+	WorldApplicationPending       = "pending"
+	WorldApplicationInfoRequested = "info_requested"
+	WorldApplicationApproved      = "approved"
+	WorldApplicationRejected      = "rejected"
+	WorldApplicationWithdrawn     = "withdrawn"
+)
+
+// WorldApplicationFormField 世界拥有者定义的申请表单项
+type WorldApplicationFormField struct {
+	Key       string   `json:"key"`
+	Label     string   `json:"label"`
+	Type      string   `json:"type"` // text/textarea/select
+	Required  bool     `json:"required"`
+	Options   []string `json:"options,omitempty"`
+	MaxLength int      `json:"maxLength,omitempty"`
+}
+
+type WorldApplicationAnswer struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// WorldJoinApplicationModel 加入世界的申请，同一用户在同一世界同时只有一条进行中的申请
+type WorldJoinApplicationModel struct {
+	StringPKBaseModel
+	WorldID      string                           `json:"worldId" gorm:"size:100;index:idx_world_application,priority:1"`
+	UserID       string                           `json:"userId" gorm:"size:100;index:idx_world_application,priority:2"`
+	Status       string                           `json:"status" gorm:"size:24;index"`
+	Answers      JSONList[WorldApplicationAnswer] `json:"answers" gorm:"type:text"`
+	ReviewerID   string                           `json:"reviewerId" gorm:"size:100"`
+	ReviewNote   string                           `json:"reviewNote" gorm:"size:1000"` // 拒绝理由或补充信息要求
+	ApprovedRole string                           `json:"approvedRole" gorm:"size:24"`
+	ReviewedAt   *time.Time                       `json:"reviewedAt"`
+
+	User     *UserModel `json:"user,omitempty" gorm:"-"`
+	Reviewer *UserModel `json:"reviewer,omitempty" gorm:"-"`
+}
+
+func (*WorldJoinApplicationModel) TableName() string {
+	return "world_join_applications"
+}
+
+// IsOpen 申请是否仍在进行中
+func (m *WorldJoinApplicationModel) IsOpen() bool {
+	return m.Status == WorldApplicationPending || m.Status == WorldApplicationInfoRequested
+}
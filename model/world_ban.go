@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+const (
+	WorldBanTypeBan  = "ban"  // 移出世界且无法再加入、查看
+	WorldBanTypeMute = "mute" // 保留成员身份，但无法在世界内发言
+)
+
+// WorldBanModel 世界级封禁/禁言。UserID、IP、Email 至少一项不为空，任一命中即生效
+type WorldBanModel struct {
+	StringPKBaseModel
+	WorldID   string     `json:"worldId" gorm:"size:100;index"`
+	Type      string     `json:"type" gorm:"size:16;index"`
+	UserID    string     `json:"userId" gorm:"size:100;index"`
+	IP        string     `json:"ip" gorm:"size:64;index"`
+	Email     string     `json:"email" gorm:"size:254;index"`
+	Reason    string     `json:"reason" gorm:"size:500"`
+	IssuerID  string     `json:"issuerId" gorm:"size:100"`
+	ExpireAt  *time.Time `json:"expireAt" gorm:"index"` // 为空表示永久
+	RevokedAt *time.Time `json:"revokedAt"`
+	RevokedBy string     `json:"revokedBy" gorm:"size:100"`
+
+	User   *UserModel `json:"user,omitempty" gorm:"-"`
+	Issuer *UserModel `json:"issuer,omitempty" gorm:"-"`
+}
+
+func (*WorldBanModel) TableName() string {
+	return "world_bans"
+}
+
+// IsActive 未撤销且未过期
+func (m *WorldBanModel) IsActive(now time.Time) bool {
+	if m == nil || m.RevokedAt != nil {
+		return false
+	}
+	return m.ExpireAt == nil || m.ExpireAt.After(now)
+}
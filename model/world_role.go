@@ -0,0 +1,71 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WorldRoleChannelRoleKeyPrefix 世界自定义角色在频道中派生角色的 key 前缀，完整 ID 为 ch-{channelId}-wr{roleId}
+const WorldRoleChannelRoleKeyPrefix = "wr"
+
+// WorldRoleModel 世界自定义角色（如 GM、玩家），权限为频道权限集合，自动派生到世界内所有频道。
+// 拥有者/管理员/成员/旁观者等内置身份仍决定世界级管理权，自定义角色只叠加频道权限。
+type WorldRoleModel struct {
+	StringPKBaseModel
+	WorldID     string           `json:"worldId" gorm:"size:100;index"`
+	Name        string           `json:"name" gorm:"size:64"`
+	Desc        string           `json:"desc" gorm:"size:255"`
+	Color       string           `json:"color" gorm:"size:16"`
+	Priority    int              `json:"priority"` // 越大越靠前，也用于决定成员的展示角色
+	Permissions JSONList[string] `json:"permissions" gorm:"type:text"`
+	CreatorID   string           `json:"creatorId" gorm:"size:100"`
+
+	MemberCount int64 `json:"memberCount" gorm:"-"`
+}
+
+func (*WorldRoleModel) TableName() string {
+	return "world_roles"
+}
+
+// WorldRoleAssignmentModel 成员持有的世界自定义角色，一名成员可同时持有多个
+type WorldRoleAssignmentModel struct {
+	StringPKBaseModel
+	WorldID string `json:"worldId" gorm:"size:100;index"`
+	RoleID  string `json:"roleId" gorm:"size:100;uniqueIndex:idx_world_role_assignment,priority:1"`
+	UserID  string `json:"userId" gorm:"size:100;uniqueIndex:idx_world_role_assignment,priority:2;index"`
+}
+
+func (*WorldRoleAssignmentModel) TableName() string {
+	return "world_role_assignments"
+}
+
+// WorldRoleChannelOverrideModel 单个频道对派生角色权限的覆盖，存在时同步不再使用世界角色的权限
+type WorldRoleChannelOverrideModel struct {
+	StringPKBaseModel
+	WorldID     string           `json:"worldId" gorm:"size:100;index"`
+	RoleID      string           `json:"roleId" gorm:"size:100;uniqueIndex:idx_world_role_override,priority:1"`
+	ChannelID   string           `json:"channelId" gorm:"size:100;uniqueIndex:idx_world_role_override,priority:2"`
+	Permissions JSONList[string] `json:"permissions" gorm:"type:text"`
+}
+
+func (*WorldRoleChannelOverrideModel) TableName() string {
+	return "world_role_channel_overrides"
+}
+
+// WorldRoleChannelRoleID 世界角色在指定频道中的派生频道角色 ID
+func WorldRoleChannelRoleID(channelID, roleID string) string {
+	return fmt.Sprintf("ch-%s-%s%s", channelID, WorldRoleChannelRoleKeyPrefix, roleID)
+}
+
+// WorldRoleIDFromChannelRoleID 从派生频道角色 ID 中解析世界角色 ID，不是派生角色时返回空
+func WorldRoleIDFromChannelRoleID(channelRoleID string) string {
+	channelID := ExtractChIdFromRoleId(channelRoleID)
+	if channelID == "" {
+		return ""
+	}
+	key := strings.TrimPrefix(channelRoleID, "ch-"+channelID+"-")
+	if !strings.HasPrefix(key, WorldRoleChannelRoleKeyPrefix) || len(key) <= len(WorldRoleChannelRoleKeyPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(key, WorldRoleChannelRoleKeyPrefix)
+}
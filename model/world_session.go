@@ -0,0 +1,78 @@
+package model
+
+import "time"
+
+const (
+	WorldSessionStatusActive    = "active"
+	WorldSessionStatusCancelled = "cancelled"
+
+	WorldSessionRSVPYes   = "yes"
+	WorldSessionRSVPNo    = "no"
+	WorldSessionRSVPMaybe = "maybe"
+
+	WorldSessionNotifyReminder = "reminder"
+	WorldSessionNotifyStart    = "start"
+)
+
+// WorldSessionModel 世界内排期的团（跑团场次），可按重复规则展开为多次
+type WorldSessionModel struct {
+	StringPKBaseModel
+	WorldID         string     `json:"worldId" gorm:"size:100;index"`
+	ChannelID       string     `json:"channelId" gorm:"size:100;index"`
+	Title           string     `json:"title" gorm:"size:100"`
+	Description     string     `json:"description" gorm:"size:2000"`
+	StartAt         time.Time  `json:"startAt" gorm:"index"` // 首次开始时间
+	EndAt           time.Time  `json:"endAt"`                // 首次结束时间，之后每次时长相同
+	Timezone        string     `json:"timezone" gorm:"size:64"`
+	Recurrence      string     `json:"recurrence" gorm:"size:255"` // RRULE 子集，如 FREQ=WEEKLY;BYDAY=SA
+	RecurrenceEnd   *time.Time `json:"recurrenceEnd" gorm:"index"` // 最后一次的结束时间，无限重复时为空
+	ReminderMinutes int        `json:"reminderMinutes"`            // 开始前多少分钟提醒，0 为不提醒
+	CreatorID       string     `json:"creatorId" gorm:"size:100"`
+	Status          string     `json:"status" gorm:"size:16;index"`
+
+	MyRSVP     string         `json:"myRsvp,omitempty" gorm:"-"`
+	RSVPCounts map[string]int `json:"rsvpCounts,omitempty" gorm:"-"`
+}
+
+func (*WorldSessionModel) TableName() string {
+	return "world_sessions"
+}
+
+// WorldSessionRSVPModel 成员对场次的出席回复，对整个系列生效
+type WorldSessionRSVPModel struct {
+	StringPKBaseModel
+	WorldID   string `json:"worldId" gorm:"size:100;index"`
+	SessionID string `json:"sessionId" gorm:"size:100;uniqueIndex:idx_world_session_rsvp,priority:1"`
+	UserID    string `json:"userId" gorm:"size:100;uniqueIndex:idx_world_session_rsvp,priority:2"`
+	Status    string `json:"status" gorm:"size:16"`
+	Note      string `json:"note" gorm:"size:255"`
+
+	User *UserModel `json:"user,omitempty" gorm:"-"`
+}
+
+func (*WorldSessionRSVPModel) TableName() string {
+	return "world_session_rsvps"
+}
+
+// WorldSessionNotifyLogModel 记录某次场次已发送的提醒，唯一索引保证多实例下只发一次
+type WorldSessionNotifyLogModel struct {
+	StringPKBaseModel
+	SessionID    string    `gorm:"size:100;uniqueIndex:idx_world_session_notify,priority:1"`
+	OccurrenceAt time.Time `gorm:"uniqueIndex:idx_world_session_notify,priority:2"`
+	Kind         string    `gorm:"size:16;uniqueIndex:idx_world_session_notify,priority:3"`
+}
+
+func (*WorldSessionNotifyLogModel) TableName() string {
+	return "world_session_notify_logs"
+}
+
+// CalendarFeedTokenModel 用户的日历订阅令牌，订阅地址不需要登录
+type CalendarFeedTokenModel struct {
+	StringPKBaseModel
+	UserID string `json:"userId" gorm:"size:100;uniqueIndex"`
+	Token  string `json:"token" gorm:"size:64;uniqueIndex"`
+}
+
+func (*CalendarFeedTokenModel) TableName() string {
+	return "calendar_feed_tokens"
+}
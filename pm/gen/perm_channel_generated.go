@@ -8,6 +8,7 @@ var PermChannelMap = map[string]string{
     "func_channel_text_send_ooc": "频道 - 消息 - 场外文本发送",
     "func_channel_file_send": "频道 - 消息 - 文件发送",
     "func_channel_audio_send": "频道 - 消息 - 音频发送",
+    "func_channel_media_send": "频道 - 消息 - 视频及大文件发送",
     "func_channel_invite": "频道 - 常规 - 邀请加入频道",
     "func_channel_sub_channel_create": "频道 - 常规 - 创建子频道",
     "func_channel_member_remove": "频道 - 频道管理 - 踢人",
@@ -37,6 +38,7 @@ var PermChannelArray = []map[string]string{
 	{"key": "func_channel_text_send_ooc", "desc": "频道 - 消息 - 场外文本发送"},
 	{"key": "func_channel_file_send", "desc": "频道 - 消息 - 文件发送"},
 	{"key": "func_channel_audio_send", "desc": "频道 - 消息 - 音频发送"},
+	{"key": "func_channel_media_send", "desc": "频道 - 消息 - 视频及大文件发送"},
 	{"key": "func_channel_invite", "desc": "频道 - 常规 - 邀请加入频道"},
 	{"key": "func_channel_sub_channel_create", "desc": "频道 - 常规 - 创建子频道"},
 	{"key": "func_channel_member_remove", "desc": "频道 - 频道管理 - 踢人"},
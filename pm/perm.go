@@ -32,17 +32,33 @@ func GetPerm() *gorbac.RBAC {
 // 	return perm.IsGranted(role, permission, nil)
 // }
 
-func canBase(uid, channelId, roleType string, permissions ...gorbac.Permission) bool {
-	roles, _ := model.UserRoleMappingListByUserID(uid, channelId, roleType)
-
-	if channelId != "" {
-		ch, _ := model.ChannelGet(channelId)
-		if ch.PermType == "public" {
-			roleId := fmt.Sprintf("ch-%s-%s", channelId, "visitor")
-			roles = append(roles, roleId)
-		}
+// 权限判定结果缓存，角色关联或角色权限变化时由 model.PermCacheInvalidate* 失效
+var permDecisionCache = model.NewPermCache[bool]("perm_decisions")
+
+func cachedDecision(uid, channelId, scope string, permissions []gorbac.Permission, decide func() bool) bool {
+	var sb strings.Builder
+	sb.WriteString(scope)
+	sb.WriteByte('|')
+	sb.WriteString(uid)
+	sb.WriteByte('|')
+	sb.WriteString(channelId)
+	for _, p := range permissions {
+		sb.WriteByte('|')
+		sb.WriteString(p.ID())
 	}
+	ok, _ := permDecisionCache.Load(uid, sb.String(), func() (bool, error) {
+		return decide(), nil
+	})
+	return ok
+}
+
+func canBase(uid, channelId, roleType string, permissions ...gorbac.Permission) bool {
+	return canBaseRoles(uid, channelId, roleType, true, permissions...)
+}
 
+// canBaseRoles withVisitor 为 false 时只看用户实际关联的角色，不计公开频道的游客角色
+func canBaseRoles(uid, channelId, roleType string, withVisitor bool, permissions ...gorbac.Permission) bool {
+	roles := userRolesFor(uid, channelId, roleType, withVisitor)
 	for _, permission := range permissions {
 		if gorbac.AnyGranted(perm, roles, permission, nil) {
 			return true
@@ -51,31 +67,56 @@ func canBase(uid, channelId, roleType string, permissions ...gorbac.Permission)
 	return false
 }
 
+func userRolesFor(uid, channelId, roleType string, withVisitor bool) []string {
+	roles, _ := model.UserRoleMappingListByUserIDCached(uid, channelId, roleType)
+
+	if channelId != "" && withVisitor {
+		ch, _ := model.ChannelGet(channelId)
+		if ch.PermType == "public" {
+			roleId := fmt.Sprintf("ch-%s-%s", channelId, "visitor")
+			// 缓存中的切片是共享的，追加前先复制
+			roles = append(roles[:len(roles):len(roles)], roleId)
+		}
+	}
+	return roles
+}
+
 func Can(uid string, channelId string, permissions ...gorbac.Permission) bool {
-	return canBase(uid, channelId, "", permissions...)
+	return cachedDecision(uid, channelId, "any", permissions, func() bool {
+		return canBase(uid, channelId, "", permissions...)
+	})
 }
 
 func CanWithSystemRole(uid string, permissions ...gorbac.Permission) bool {
-	return canBase(uid, "", "system", permissions...)
+	return cachedDecision(uid, "", "system", permissions, func() bool {
+		return canBase(uid, "", "system", permissions...)
+	})
 }
 
-func CanWithChannelRole(uid string, channelId string, permissions ...gorbac.Permission) bool {
-	ch, _ := model.ChannelGet(channelId)
-	if ch.RootId != "" {
-		// 如果是子频道，若根节点频道允许，则同时允许
-		var newPerm []gorbac.Permission
-		for _, i := range permissions {
-			switch i.ID() {
-			case PermFuncChannelReadAll.ID(), PermFuncChannelTextSendAll.ID():
-				newPerm = append(newPerm, i)
-			}
+// ExplainSystemPerm 判定系统权限，并返回授予该权限的系统角色
+func ExplainSystemPerm(uid string, p gorbac.Permission) (bool, []string) {
+	var granting []string
+	for _, role := range userRolesFor(uid, "", "system", false) {
+		if perm.IsGranted(role, p, nil) {
+			granting = append(granting, role)
 		}
-		if ok := canBase(uid, ch.RootId, "channel", newPerm...); ok {
+	}
+	return len(granting) > 0, granting
+}
+
+func CanWithChannelRole(uid string, channelId string, permissions ...gorbac.Permission) bool {
+	return cachedDecision(uid, channelId, "channel", permissions, func() bool {
+		return canWithChannelRole(uid, channelId, permissions...)
+	})
+}
+
+func canWithChannelRole(uid string, channelId string, permissions ...gorbac.Permission) bool {
+	for _, p := range permissions {
+		if resolveChannelPerm(uid, channelId, p, nil) {
 			return true
 		}
 	}
-
-	return canBase(uid, channelId, "channel", permissions...)
+	return false
 }
 
 // TODO: 是不是应该移动到service里，或者把services里的channel权限移动过来？
@@ -105,11 +146,13 @@ func sysRolesInit() {
 
 func Init() {
 	perm = gorbac.New()
+	defer model.PermCacheInvalidateAll()
 	sysRoles, num, _ := model.SystemRoleList(0, -1)
 	chRoles, _, _ := model.ChannelRoleAllList(0, -1)
 
 	ensureChannelIFormPerms(chRoles)
 	ensureChannelMessagePinPerms(chRoles)
+	ensureChannelMediaSendPerms(chRoles)
 
 	if num == 0 {
 		// 目前system roles表还未实用，每次创建是设计的一部分而不是bug
@@ -166,6 +209,19 @@ func ensureChannelMessagePinPerms(chRoles []*model.ChannelRoleModel) {
 	}
 }
 
+func ensureChannelMediaSendPerms(chRoles []*model.ChannelRoleModel) {
+	targetPerms := []gorbac.Permission{PermFuncChannelMediaSend}
+	for _, role := range chRoles {
+		if role == nil {
+			continue
+		}
+		if !(strings.HasSuffix(role.ID, "-owner") || strings.HasSuffix(role.ID, "-admin")) {
+			continue
+		}
+		ensureRoleHasPermissions(role.ID, targetPerms)
+	}
+}
+
 func ensureRoleHasPermissions(roleID string, perms []gorbac.Permission) {
 	if roleID == "" || len(perms) == 0 {
 		return
@@ -242,6 +298,7 @@ func ChannelRoleSetWithoutDB(roleId string, perms []gorbac.Permission) {
 	if err := perm.Add(roleCur); err != nil {
 		log.Printf("添加角色到RBAC系统失败: %v", err)
 	}
+	model.PermCacheInvalidateAll()
 }
 
 func ChannelRolePermsGet(roleId string) []string {
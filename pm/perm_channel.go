@@ -8,6 +8,7 @@ var (
 	PermFuncChannelTextSendOOC = gorbac.NewStdPermission("func_channel_text_send_ooc") // 频道 - 消息 - 场外文本发送
 	PermFuncChannelFileSend    = gorbac.NewStdPermission("func_channel_file_send")     // 频道 - 消息 - 文件发送
 	PermFuncChannelAudioSend   = gorbac.NewStdPermission("func_channel_audio_send")    // 频道 - 消息 - 音频发送
+	PermFuncChannelMediaSend   = gorbac.NewStdPermission("func_channel_media_send")    // 频道 - 消息 - 视频及大文件发送
 
 	PermFuncChannelInvite           = gorbac.NewStdPermission("func_channel_invite")             // 频道 - 常规 - 邀请加入频道
 	PermFuncChannelSubChannelCreate = gorbac.NewStdPermission("func_channel_sub_channel_create") // 频道 - 常规 - 创建子频道
@@ -0,0 +1,108 @@
+package pm
+
+import (
+	"sealchat/model"
+
+	"github.com/mikespook/gorbac"
+)
+
+// 频道权限判定顺序（逐个权限判定，任一权限通过即通过）：
+// 1. 用户在本频道上的覆盖（允许/禁止）直接决定结果
+// 2. 用户在本频道上的角色（公开频道含游客角色）
+// 3. 沿父链向上：同步中的分类先看该用户在分类上的覆盖，再看分类角色；
+//    其它上级只传递“查看全部/发言全部”，上级的禁止覆盖只让该上级不再传递
+
+const (
+	PermExplainLevelChannel  = "channel"  // 本频道
+	PermExplainLevelCategory = "category" // 同步权限的上级分类
+	PermExplainLevelAncestor = "ancestor" // 传递“全部”权限的上级频道
+	PermExplainLevelSystem   = "system"   // 系统角色
+
+	PermExplainSourceOverwrite = "overwrite"
+	PermExplainSourceRole      = "role"
+
+	PermExplainEffectAllow = "allow"
+	PermExplainEffectDeny  = "deny"
+	PermExplainEffectNone  = "none"
+)
+
+// PermExplainStep 权限判定链路上的一步
+type PermExplainStep struct {
+	ChannelID string   `json:"channelId"`
+	Level     string   `json:"level"`
+	Source    string   `json:"source"`
+	Effect    string   `json:"effect"`
+	RoleIDs   []string `json:"roleIds,omitempty"`
+}
+
+func permOverwriteEffect(uid, channelId string, p gorbac.Permission) (string, bool) {
+	overwrites, _ := model.ChannelPermOverwriteMapCached(uid, channelId)
+	effect, ok := overwrites[p.ID()]
+	return effect, ok
+}
+
+// grantingRoles 返回授予该权限的角色
+func grantingRoles(uid, channelId string, withVisitor bool, p gorbac.Permission) []string {
+	var result []string
+	for _, role := range userRolesFor(uid, channelId, "channel", withVisitor) {
+		if perm.IsGranted(role, p, nil) {
+			result = append(result, role)
+		}
+	}
+	return result
+}
+
+// resolveChannelLevel 判定单个频道层级，decided 表示该层级已给出最终结论
+func resolveChannelLevel(uid, channelId, level string, withVisitor bool, p gorbac.Permission, trace func(PermExplainStep)) (allowed, decided bool) {
+	if effect, ok := permOverwriteEffect(uid, channelId, p); ok {
+		if trace != nil {
+			trace(PermExplainStep{ChannelID: channelId, Level: level, Source: PermExplainSourceOverwrite, Effect: effect})
+		}
+		return effect == model.PermOverwriteAllow, true
+	}
+	roles := grantingRoles(uid, channelId, withVisitor, p)
+	if trace != nil {
+		step := PermExplainStep{ChannelID: channelId, Level: level, Source: PermExplainSourceRole, Effect: PermExplainEffectNone, RoleIDs: roles}
+		if len(roles) > 0 {
+			step.Effect = PermExplainEffectAllow
+		}
+		trace(step)
+	}
+	return len(roles) > 0, len(roles) > 0
+}
+
+func resolveChannelPerm(uid string, channelId string, p gorbac.Permission, trace func(PermExplainStep)) bool {
+	if allowed, decided := resolveChannelLevel(uid, channelId, PermExplainLevelChannel, true, p, trace); decided {
+		return allowed
+	}
+	ch, _ := model.ChannelGet(channelId)
+	if ch == nil || ch.ParentID == "" {
+		return false
+	}
+
+	inheritAll := p.ID() == PermFuncChannelReadAll.ID() || p.ID() == PermFuncChannelTextSendAll.ID()
+	syncing := !ch.PermOverride
+	for _, ancestor := range model.ChannelAncestors(ch) {
+		if syncing && ancestor.IsCategory() {
+			// 分类公开时的游客角色不能让非公开子频道变得可见
+			if allowed, decided := resolveChannelLevel(uid, ancestor.ID, PermExplainLevelCategory, false, p, trace); decided {
+				return allowed
+			}
+		} else if inheritAll {
+			if allowed, _ := resolveChannelLevel(uid, ancestor.ID, PermExplainLevelAncestor, true, p, trace); allowed {
+				return true
+			}
+		}
+		syncing = syncing && !ancestor.PermOverride
+	}
+	return false
+}
+
+// ExplainChannelPerm 按 CanWithChannelRole 的规则判定单个权限，并返回判定链路
+func ExplainChannelPerm(uid, channelId string, p gorbac.Permission) (bool, []PermExplainStep) {
+	steps := []PermExplainStep{}
+	allowed := resolveChannelPerm(uid, channelId, p, func(step PermExplainStep) {
+		steps = append(steps, step)
+	})
+	return allowed, steps
+}
@@ -12,6 +12,7 @@ type Channel struct {
 	BotFeatureEnabled      bool        `json:"botFeatureEnabled"`
 	BackgroundAttachmentId string      `json:"backgroundAttachmentId"`
 	BackgroundSettings     string      `json:"backgroundSettings"`
+	GMRoleIDs              []string    `json:"gmRoleIds,omitempty"`
 }
 
 type ChannelType int
@@ -136,6 +137,11 @@ type Message struct {
 	DeletedBy        string           `json:"deletedBy"`
 	ClientID         string           `json:"clientId,omitempty"`
 	WhisperMeta      *WhisperMeta     `json:"whisperMeta,omitempty"`
+	// Ephemeral 仅调用者可见的指令回复，不落库
+	Ephemeral bool `json:"ephemeral,omitempty"`
+	// IsGMRoll 仅发送者与 GM 可见的检定；GMRollMasked 表示当前接收者看到的是占位内容
+	IsGMRoll     bool `json:"isGmRoll,omitempty"`
+	GMRollMasked bool `json:"gmRollMasked,omitempty"`
 }
 
 type MessageIdentity struct {
@@ -283,11 +289,15 @@ type Command struct {
 	Children    []Command
 }
 
+// CommandDeclaration 指令参数或选项的声明。
+// Type 可取 string/text/integer/number/boolean/user，text 会吞掉其后的全部内容；
+// Autocomplete 为 true 时，客户端补全该参数会转发给 BOT
 type CommandDeclaration struct {
-	Name        string
-	Description map[string]string
-	Type        string
-	Required    bool
+	Name         string
+	Description  map[string]string
+	Type         string
+	Required     bool
+	Autocomplete bool
 }
 
 type Argv struct {
@@ -296,6 +306,11 @@ type Argv struct {
 	Options   map[string]interface{}
 }
 
+// CommandInteraction 指令调用的上下文，BOT 凭 ID 回复（可仅调用者可见）
+type CommandInteraction struct {
+	ID string `json:"id"`
+}
+
 type EventName string
 
 const (
@@ -312,6 +327,8 @@ const (
 	EventMessageReordered          EventName = "message-reordered"
 	EventMessageRemoved            EventName = "message-removed"
 	EventMessageReaction           EventName = "message.reaction"
+	EventMessageBulkProgress       EventName = "message-bulk-progress"
+	EventMessageBulkApplied        EventName = "message-bulk-applied"
 	EventInteractionCommand        EventName = "interaction/command"
 	EventReactionAdded             EventName = "reaction-added"
 	EventReactionDeleted           EventName = "reaction-deleted"
@@ -331,6 +348,11 @@ const (
 	EventChannelImageLayoutUpdated EventName = "channel-image-layout-updated"
 	EventWorldKeywordsUpdated      EventName = "world-keywords-updated"
 	EventWorldUpdated              EventName = "world-updated"
+	EventWorldApplicationUpdated   EventName = "world-application-updated"
+	EventWorldSanctionUpdated      EventName = "world-sanction-updated"
+	EventWorldRolesUpdated         EventName = "world-roles-updated"
+	EventWorldSessionsUpdated      EventName = "world-sessions-updated"
+	EventChannelReadUpdated        EventName = "channel-read-updated"
 	// Sticky Note Events
 	EventStickyNoteCreated EventName = "sticky-note-created"
 	EventStickyNoteUpdated EventName = "sticky-note-updated"
@@ -352,6 +374,7 @@ type MessageContext struct {
 	WhisperToUserID string `json:"whisperToUserId,omitempty"` // 悄悄话目标用户ID
 	IsHiddenDice    bool   `json:"isHiddenDice,omitempty"`    // 是否为暗骰
 	SenderUserID    string `json:"senderUserId,omitempty"`    // 原消息发送者ID
+	IsGMRoll        bool   `json:"isGMRoll,omitempty"`        // 是否为 GM 暗骰，回复时可带上 gm_roll 保持仅 GM 可见
 }
 
 type MessageReactionEvent struct {
@@ -363,6 +386,24 @@ type MessageReactionEvent struct {
 	Timestamp int64  `json:"timestamp"`
 }
 
+// ChannelReadReceiptPayload 成员的已读位置前进到 ReadAt（毫秒），此前的消息均视为已读
+type ChannelReadReceiptPayload struct {
+	UserIDs []string `json:"userIds"`
+	ReadAt  int64    `json:"readAt"`
+}
+
+// MessageBulkEventPayload 批量消息操作的进度与结果；结果事件每个频道只推送一次
+type MessageBulkEventPayload struct {
+	JobID           string   `json:"jobId"`
+	Action          string   `json:"action"`
+	Status          string   `json:"status"`
+	Total           int      `json:"total"`
+	Processed       int      `json:"processed"`
+	Error           string   `json:"error,omitempty"`
+	MessageIDs      []string `json:"messageIds,omitempty"`
+	TargetChannelID string   `json:"targetChannelId,omitempty"`
+}
+
 type Event struct {
 	ID                         int64                              `json:"id"`
 	Type                       EventName                          `json:"type"`
@@ -391,7 +432,12 @@ type Event struct {
 	CharacterCardBadgeSnapshot *CharacterCardBadgeSnapshotPayload `json:"characterCardBadgeSnapshot,omitempty"`
 	MessageContext             *MessageContext                    `json:"messageContext,omitempty"`
 	MessageReaction            *MessageReactionEvent              `json:"messageReaction,omitempty"`
+	MessageBulk                *MessageBulkEventPayload           `json:"messageBulk,omitempty"`
+	ReadReceipt                *ChannelReadReceiptPayload         `json:"readReceipt,omitempty"`
+	Interaction                *CommandInteraction                `json:"interaction,omitempty"`
 	IsInteractiveUpdate        bool                               `json:"is_interactive_update,omitempty"`
+	// Seq 频道内单调递增的事件序号，断线重连时据此补发遗漏事件
+	Seq int64 `json:"seq,omitempty"`
 }
 
 type TypingState string
@@ -440,8 +486,24 @@ const (
 	OpReady
 	OpLatencyProbe
 	OpLatencyResult
+	OpResumed
+	OpResyncRequired
 )
 
+// ResumedPayload 断线恢复完成后下发，Latest 为各频道当前最新序号
+type ResumedPayload struct {
+	Latest   map[string]int64 `json:"latest"`
+	Replayed map[string]int   `json:"replayed"`
+	Resync   []string         `json:"resync,omitempty"`
+}
+
+// ResyncRequiredPayload 遗漏事件过多或已过期，客户端需重新拉取该频道数据
+type ResyncRequiredPayload struct {
+	ChannelID string `json:"channelId"`
+	LatestSeq int64  `json:"latestSeq"`
+	Reason    string `json:"reason"`
+}
+
 type GatewayBody struct {
 	Event    Event
 	Ping     struct{}
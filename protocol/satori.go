@@ -0,0 +1,113 @@
+package protocol
+
+// 以下为 Satori 标准协议的资源结构，字段命名遵循 https://satori.chat/zh-CN/protocol/
+// 与站内 /ws/seal 使用的结构不同，这里只保留标准字段，方便现成的 Satori 框架直接对接
+
+const SatoriPlatform = "sealchat"
+
+// SatoriOpMeta Satori 信令中 0-4 与 Opcode 取值一致，5 为 META
+const SatoriOpMeta Opcode = 5
+
+type SatoriChannelType int
+
+const (
+	SatoriChannelText SatoriChannelType = iota
+	SatoriChannelDirect
+	SatoriChannelCategory
+	SatoriChannelVoice
+)
+
+type SatoriUser struct {
+	ID     string `json:"id"`
+	Name   string `json:"name,omitempty"`
+	Nick   string `json:"nick,omitempty"`
+	Avatar string `json:"avatar,omitempty"`
+	IsBot  bool   `json:"is_bot,omitempty"`
+}
+
+type SatoriChannel struct {
+	ID       string            `json:"id"`
+	Type     SatoriChannelType `json:"type"`
+	Name     string            `json:"name,omitempty"`
+	ParentID string            `json:"parent_id,omitempty"`
+}
+
+type SatoriGuild struct {
+	ID     string `json:"id"`
+	Name   string `json:"name,omitempty"`
+	Avatar string `json:"avatar,omitempty"`
+}
+
+type SatoriGuildMember struct {
+	User     *SatoriUser `json:"user,omitempty"`
+	Nick     string      `json:"nick,omitempty"`
+	Avatar   string      `json:"avatar,omitempty"`
+	JoinedAt int64       `json:"joined_at,omitempty"`
+}
+
+type SatoriMessage struct {
+	ID        string             `json:"id"`
+	Content   string             `json:"content"`
+	Channel   *SatoriChannel     `json:"channel,omitempty"`
+	Guild     *SatoriGuild       `json:"guild,omitempty"`
+	Member    *SatoriGuildMember `json:"member,omitempty"`
+	User      *SatoriUser        `json:"user,omitempty"`
+	Quote     *SatoriMessage     `json:"quote,omitempty"`
+	CreatedAt int64              `json:"created_at,omitempty"`
+	UpdatedAt int64              `json:"updated_at,omitempty"`
+}
+
+// SatoriLogin 同时给出 sn 与旧版的 self_id/platform，兼容新旧两代框架
+type SatoriLogin struct {
+	Sn       int64       `json:"sn"`
+	Platform string      `json:"platform"`
+	SelfID   string      `json:"self_id"`
+	User     *SatoriUser `json:"user,omitempty"`
+	Status   Status      `json:"status"`
+	Adapter  string      `json:"adapter"`
+	Features []string    `json:"features"`
+}
+
+type SatoriEvent struct {
+	Sn        int64              `json:"sn"`
+	ID        int64              `json:"id"` // 旧版字段，与 sn 相同
+	Type      string             `json:"type"`
+	Platform  string             `json:"platform"`
+	SelfID    string             `json:"self_id"`
+	Timestamp int64              `json:"timestamp"`
+	Login     *SatoriLogin       `json:"login,omitempty"`
+	Channel   *SatoriChannel     `json:"channel,omitempty"`
+	Guild     *SatoriGuild       `json:"guild,omitempty"`
+	Member    *SatoriGuildMember `json:"member,omitempty"`
+	Message   *SatoriMessage     `json:"message,omitempty"`
+	Operator  *SatoriUser        `json:"operator,omitempty"`
+	User      *SatoriUser        `json:"user,omitempty"`
+	Argv      *SatoriArgv        `json:"argv,omitempty"`
+}
+
+type SatoriArgv struct {
+	Name      string         `json:"name"`
+	Arguments []any          `json:"arguments"`
+	Options   map[string]any `json:"options"`
+}
+
+type SatoriIdentify struct {
+	Token string `json:"token"`
+	Sn    int64  `json:"sn,omitempty"`
+}
+
+type SatoriReady struct {
+	Logins    []*SatoriLogin `json:"logins"`
+	ProxyURLs []string       `json:"proxy_urls"`
+}
+
+type SatoriList[T any] struct {
+	Data []T    `json:"data"`
+	Next string `json:"next,omitempty"`
+}
+
+type SatoriBidiList[T any] struct {
+	Data []T    `json:"data"`
+	Prev string `json:"prev,omitempty"`
+	Next string `json:"next,omitempty"`
+}
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/samber/lo"
+	"golang.org/x/crypto/blake2s"
+
+	"sealchat/model"
+	"sealchat/service/storage"
+	"sealchat/utils"
+)
+
+var (
+	ErrDirectUploadDisabled = errors.New("未启用 S3 直传")
+	ErrUploadSizeMismatch   = errors.New("对象大小与声明不一致")
+	ErrUploadHashMismatch   = errors.New("文件哈希与声明不一致")
+	ErrUploadObjectMissing  = errors.New("尚未检测到已上传的对象")
+)
+
+// 签发后额外保留的时间，覆盖慢速网络下的 PUT 与确认请求
+const directUploadGrace = 10 * time.Minute
+
+// AttachmentDirectUploadCreate 为附件签发预签名 PUT 链接；哈希命中已有文件时直接完成
+func AttachmentDirectUploadCreate(user *model.UserModel, input UploadSessionCreateInput) (*model.UploadSessionModel, string, *UploadSessionResult, error) {
+	manager := GetStorageManager()
+	if !manager.DirectUploadEnabled() {
+		return nil, "", nil, ErrDirectUploadDisabled
+	}
+	if input.Size <= 0 {
+		return nil, "", nil, errors.New("文件大小无效")
+	}
+	if limit := uploadSizeLimit(model.UploadPurposeAttachment, input.MimeType); limit > 0 && input.Size > limit {
+		return nil, "", nil, ErrUploadTooLarge
+	}
+	hashHex := strings.ToLower(strings.TrimSpace(input.Hash))
+	if decoded, err := hex.DecodeString(hashHex); err != nil || len(decoded) != blake2s.Size {
+		return nil, "", nil, errors.New("直传需要提供文件的 blake2s 哈希")
+	}
+	input.SHA256 = strings.ToLower(strings.TrimSpace(input.SHA256))
+	channelID := strings.TrimSpace(input.ChannelID)
+	// 声明类型只用于提前拒绝，确认时仍以对象内容嗅探为准
+	if declared := strings.TrimSpace(input.MimeType); declared != "" && !uploadAttachmentMimeAllowed(user.ID, channelID, declared) {
+		return nil, "", nil, ErrUploadMediaForbidden
+	}
+
+	optionsMap, err := encodeUploadSessionOptions(uploadSessionOptions{Extra: input.Extra})
+	if err != nil {
+		return nil, "", nil, err
+	}
+	now := time.Now()
+	session := &model.UploadSessionModel{
+		UserID:    user.ID,
+		Purpose:   model.UploadPurposeAttachment,
+		Filename:  strings.TrimSpace(input.Filename),
+		MimeType:  strings.TrimSpace(input.MimeType),
+		TotalSize: input.Size,
+		SHA256:    input.SHA256,
+		Hash:      hashHex,
+		ChannelID: channelID,
+		Options:   optionsMap,
+		Direct:    true,
+		Status:    model.UploadSessionPending,
+		ExpiresAt: now.Add(manager.PresignTTL() + directUploadGrace),
+	}
+	session.StringPKBaseModel.Init()
+
+	if existing := findReusableAttachment(hashHex, input.SHA256, input.Size); existing != nil {
+		// 秒传同样要按已有文件的真实类型校验，不能绕过权限与白名单
+		if !uploadAttachmentMimeAllowed(user.ID, channelID, existing.MimeType) {
+			return nil, "", nil, ErrUploadMediaForbidden
+		}
+		if limit := uploadSizeLimit(model.UploadPurposeAttachment, existing.MimeType); limit > 0 && existing.Size > limit {
+			return nil, "", nil, ErrUploadTooLarge
+		}
+		att := cloneAttachmentForUser(existing, user, session)
+		if err := model.GetDB().Create(att).Error; err != nil {
+			return nil, "", nil, err
+		}
+		session.Offset = session.TotalSize
+		session.Status = model.UploadSessionCompleted
+		session.ResultID = att.ID
+		if err := model.GetDB().Create(session).Error; err != nil {
+			return nil, "", nil, err
+		}
+		return session, "", &UploadSessionResult{Attachment: att}, nil
+	}
+
+	// 对象键带上会话ID，避免未确认的上传覆盖同哈希的正式对象
+	session.ObjectKey = storage.BuildAttachmentObjectKey(hashHex, input.Size, now) + "_" + session.ID
+	putURL, err := manager.PresignedPutURL(context.Background(), session.ObjectKey)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if err := model.GetDB().Create(session).Error; err != nil {
+		return nil, "", nil, err
+	}
+	return session, putURL, nil, nil
+}
+
+// AttachmentDirectUploadConfirm 客户端 PUT 完成后校验对象大小与哈希，并登记为附件
+func AttachmentDirectUploadConfirm(session *model.UploadSessionModel) (*UploadSessionResult, error) {
+	unlock := lockUploadSession(session.ID)
+	defer unlock()
+
+	current, err := model.UploadSessionGet(session.ID)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, ErrUploadSessionNotFound
+	}
+	if !current.Direct || current.Status != model.UploadSessionPending {
+		return nil, ErrUploadSessionClosed
+	}
+	if time.Now().After(current.ExpiresAt) {
+		return nil, ErrUploadSessionExpired
+	}
+	manager := GetStorageManager()
+	if manager == nil {
+		return nil, errors.New("存储服务未初始化")
+	}
+	ctx := context.Background()
+
+	fail := func(err error) (*UploadSessionResult, error) {
+		removeUploadSessionFile(current)
+		_ = finishUploadSession(current.ID, model.UploadSessionFailed, "", err.Error())
+		return nil, err
+	}
+
+	info, err := manager.StatRemote(ctx, current.ObjectKey)
+	if err != nil {
+		// 对象还没传完时允许客户端稍后重试确认
+		return nil, ErrUploadObjectMissing
+	}
+	if info.Size != current.TotalSize {
+		return fail(fmt.Errorf("%w: %d != %d", ErrUploadSizeMismatch, info.Size, current.TotalSize))
+	}
+
+	reader, err := manager.OpenRemote(ctx, current.ObjectKey)
+	if err != nil {
+		return nil, err
+	}
+	hash, sha, head, size, err := hashRemoteObject(reader)
+	_ = reader.Close()
+	if err != nil {
+		return nil, err
+	}
+	if size != current.TotalSize {
+		return fail(fmt.Errorf("%w: %d != %d", ErrUploadSizeMismatch, size, current.TotalSize))
+	}
+	if hex.EncodeToString(hash) != current.Hash {
+		return fail(ErrUploadHashMismatch)
+	}
+	if current.SHA256 != "" && hex.EncodeToString(sha) != current.SHA256 {
+		return fail(ErrUploadChecksumMismatch)
+	}
+
+	mimeType := strings.ToLower(mimetype.Detect(head).String())
+	if idx := strings.Index(mimeType, ";"); idx >= 0 {
+		mimeType = strings.TrimSpace(mimeType[:idx])
+	}
+	if !uploadAttachmentMimeAllowed(current.UserID, current.ChannelID, mimeType) {
+		return fail(fmt.Errorf("%w: %s", ErrUploadMimeNotAllowed, mimeType))
+	}
+	if limit := uploadSizeLimit(model.UploadPurposeAttachment, mimeType); limit > 0 && size > limit {
+		return fail(ErrUploadTooLarge)
+	}
+
+	opts, _ := decodeUploadSessionOptions(current.Options)
+	att := &model.AttachmentModel{
+		Filename:    current.Filename,
+		Size:        size,
+		Hash:        hash,
+		MimeType:    mimeType,
+		MediaKind:   AttachmentMediaKind(mimeType),
+		UserID:      current.UserID,
+		ChannelID:   current.ChannelID,
+		StorageType: model.StorageS3,
+		ObjectKey:   current.ObjectKey,
+		IsTemp:      true,
+	}
+	if !manager.PrivateBucket() {
+		att.ExternalURL = manager.PublicURL(storage.BackendS3, current.ObjectKey)
+	}
+	if opts != nil {
+		att.Extra = opts.Extra
+	}
+	if user := model.UserGet(current.UserID); user != nil {
+		att.CreatorName = user.Nickname
+		att.CreatorAvatar = user.Avatar
+	}
+	att.ID = utils.NewID()
+	if err := model.GetDB().Create(att).Error; err != nil {
+		return nil, err
+	}
+	if err := finishUploadSession(current.ID, model.UploadSessionCompleted, att.ID, ""); err != nil {
+		return nil, err
+	}
+	return &UploadSessionResult{Attachment: att}, nil
+}
+
+// hashRemoteObject 流式读取对象，计算 blake2s 与 SHA-256，并保留开头用于类型嗅探
+func hashRemoteObject(r io.Reader) (hash []byte, sha []byte, head []byte, size int64, err error) {
+	blakeHash := lo.Must(blake2s.New256(nil))
+	shaHash := sha256.New()
+	headBuf := &limitedBuffer{limit: 3072}
+	size, err = io.Copy(io.MultiWriter(blakeHash, shaHash, headBuf), r)
+	if err != nil {
+		return nil, nil, nil, size, err
+	}
+	return blakeHash.Sum(nil), shaHash.Sum(nil), headBuf.Bytes(), size, nil
+}
+
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remain := b.limit - b.Len(); remain > 0 {
+		if len(p) > remain {
+			b.Buffer.Write(p[:remain])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// AttachmentRemoteURL 返回 S3 附件的下载地址；私有桶签发短期链接
+func AttachmentRemoteURL(att *model.AttachmentModel) string {
+	if att == nil {
+		return ""
+	}
+	manager := GetStorageManager()
+	if att.StorageType == model.StorageS3 && manager.PrivateBucket() && strings.TrimSpace(att.ObjectKey) != "" {
+		target, err := manager.PresignedGetURL(context.Background(), att.ObjectKey, att.Filename, att.MimeType)
+		if err != nil {
+			log.Printf("[storage] 签发下载链接失败 %s: %v", att.ID, err)
+			return ""
+		}
+		return target
+	}
+	return AttachmentPublicURL(att)
+}
+
+// 头像上传沿用的特殊频道值，头像属于公开资料
+const attachmentAvatarChannelID = "user-avatar"
+
+// CanUserAccessAttachment 判断用户能否获取私有桶附件的下载地址
+// 上传者始终可以访问；头像公开；频道附件要求能阅读该频道；未记录频道的附件只有上传者可见
+func CanUserAccessAttachment(userID string, att *model.AttachmentModel) bool {
+	if att == nil {
+		return false
+	}
+	if userID != "" && att.UserID == userID {
+		return true
+	}
+	channelID := strings.TrimSpace(att.ChannelID)
+	if channelID == attachmentAvatarChannelID {
+		return true
+	}
+	if channelID == "" {
+		return false
+	}
+	if userID != "" && CanReadChannelByUserId(userID, channelID) {
+		return true
+	}
+	_, err := CanGuestAccessChannel(channelID, "")
+	return err == nil
+}
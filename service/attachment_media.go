@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+const (
+	AttachmentKindImage    = "image"
+	AttachmentKindVideo    = "video"
+	AttachmentKindAudio    = "audio"
+	AttachmentKindDocument = "document"
+	AttachmentKindText     = "text"
+	AttachmentKindFile     = "file"
+
+	// 海报帧与 PDF 首页预览存放目录，按文件哈希命名以便去重附件共用
+	attachmentPosterDir = "./data/thumbs/posters"
+	// 文本类附件摘录的最大字节数
+	attachmentPreviewTextLimit = 2048
+	// 外部工具生成预览的超时时间
+	attachmentPreviewTimeout = 30 * time.Second
+)
+
+// AttachmentMediaInfo 上传时探测出的媒体信息
+type AttachmentMediaInfo struct {
+	MimeType    string
+	Kind        string
+	Width       int
+	Height      int
+	Duration    float64
+	PreviewText string
+	PosterPath  string
+}
+
+// AttachmentMediaKind 根据 MIME 类型归类附件，前端据此选择播放器或文件卡片
+func AttachmentMediaKind(mimeType string) string {
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+	if idx := strings.Index(mimeType, ";"); idx >= 0 {
+		mimeType = strings.TrimSpace(mimeType[:idx])
+	}
+	switch {
+	case mimeType == "":
+		return AttachmentKindFile
+	case strings.HasPrefix(mimeType, "image/"):
+		return AttachmentKindImage
+	case strings.HasPrefix(mimeType, "video/"):
+		return AttachmentKindVideo
+	case strings.HasPrefix(mimeType, "audio/"):
+		return AttachmentKindAudio
+	case mimeType == "application/pdf":
+		return AttachmentKindDocument
+	case strings.HasPrefix(mimeType, "text/"), mimeType == "application/json":
+		return AttachmentKindText
+	default:
+		return AttachmentKindFile
+	}
+}
+
+// SniffAttachmentMime 通过文件内容识别 MIME，不信任客户端声明的类型
+func SniffAttachmentMime(path string) (string, error) {
+	mt, err := mimetype.DetectFile(path)
+	if err != nil {
+		return "", err
+	}
+	value := mt.String()
+	if idx := strings.Index(value, ";"); idx >= 0 {
+		value = strings.TrimSpace(value[:idx])
+	}
+	return strings.ToLower(value), nil
+}
+
+// AttachmentMimeAllowed 判断 MIME 是否在允许列表中，支持 video/* 这类通配写法
+func AttachmentMimeAllowed(mimeType string, allowed []string) bool {
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+	if mimeType == "" {
+		return false
+	}
+	for _, item := range allowed {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		if item == mimeType {
+			return true
+		}
+		if strings.HasSuffix(item, "/*") && strings.HasPrefix(mimeType, strings.TrimSuffix(item, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// AttachmentPosterPath 返回附件预览图（视频海报帧或 PDF 首页）的本地路径
+func AttachmentPosterPath(hash []byte, size int64) string {
+	return filepath.Join(attachmentPosterDir, fmt.Sprintf("%s_%d.png", hex.EncodeToString(hash), size))
+}
+
+// PrepareAttachmentMedia 在文件落盘前探测尺寸、时长并生成预览图
+// 预览失败不影响上传，只会让前端退回到通用文件卡片
+func PrepareAttachmentMedia(localPath string, mimeType string, hash []byte, size int64, withPreview bool) *AttachmentMediaInfo {
+	info := &AttachmentMediaInfo{
+		MimeType: mimeType,
+		Kind:     AttachmentMediaKind(mimeType),
+	}
+	switch info.Kind {
+	case AttachmentKindVideo:
+		if svc := GetAudioService(); svc != nil {
+			if probe, err := svc.ProbeVideo(localPath); err == nil {
+				info.Width = probe.Width
+				info.Height = probe.Height
+				info.Duration = probe.Duration
+			}
+			if withPreview {
+				poster := AttachmentPosterPath(hash, size)
+				if fileExists(poster) {
+					info.PosterPath = poster
+				} else if err := svc.ExtractVideoFrame(localPath, poster, info.Duration); err == nil {
+					info.PosterPath = poster
+				}
+			}
+		}
+	case AttachmentKindDocument:
+		if withPreview {
+			poster := AttachmentPosterPath(hash, size)
+			if fileExists(poster) {
+				info.PosterPath = poster
+			} else if err := renderPDFFirstPage(localPath, poster); err == nil {
+				info.PosterPath = poster
+			}
+		}
+	case AttachmentKindText:
+		info.PreviewText = readTextExcerpt(localPath, attachmentPreviewTextLimit)
+	}
+	return info
+}
+
+type videoProbeResult struct {
+	Width    int
+	Height   int
+	Duration float64
+}
+
+// ProbeVideo 使用 ffprobe 读取首个视频流的尺寸与容器时长
+func (svc *audioService) ProbeVideo(path string) (*videoProbeResult, error) {
+	if svc == nil || svc.ffprobePath == "" {
+		return nil, errors.New("ffprobe not available")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), attachmentPreviewTimeout)
+	defer cancel()
+	args := []string{"-v", "error", "-select_streams", "v:0", "-show_entries", "stream=width,height:format=duration", "-of", "json", path}
+	output, err := exec.CommandContext(ctx, svc.ffprobePath, args...).Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseFFprobeVideoJSON(output)
+}
+
+// ExtractVideoFrame 截取视频的一帧作为海报图，短视频取首帧
+func (svc *audioService) ExtractVideoFrame(src, dst string, duration float64) error {
+	if svc == nil || svc.ffmpegPath == "" {
+		return errors.New("ffmpeg not available")
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	offset := "1"
+	if duration > 0 && duration < 2 {
+		offset = "0"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), attachmentPreviewTimeout)
+	defer cancel()
+	args := []string{"-hide_banner", "-loglevel", "error", "-y", "-ss", offset, "-i", src, "-frames:v", "1", dst}
+	if output, err := exec.CommandContext(ctx, svc.ffmpegPath, args...).CombinedOutput(); err != nil {
+		_ = os.Remove(dst)
+		return fmt.Errorf("ffmpeg extract frame failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	if !fileExists(dst) {
+		return errors.New("ffmpeg produced no frame")
+	}
+	return nil
+}
+
+func parseFFprobeVideoJSON(data []byte) (*videoProbeResult, error) {
+	var payload struct {
+		Streams []struct {
+			Width  int `json:"width"`
+			Height int `json:"height"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	result := &videoProbeResult{}
+	if len(payload.Streams) > 0 {
+		result.Width = payload.Streams[0].Width
+		result.Height = payload.Streams[0].Height
+	}
+	if value := strings.TrimSpace(payload.Format.Duration); value != "" {
+		if duration, err := strconv.ParseFloat(value, 64); err == nil {
+			result.Duration = duration
+		}
+	}
+	if result.Width == 0 && result.Height == 0 && result.Duration == 0 {
+		return nil, errors.New("no video stream found")
+	}
+	return result, nil
+}
+
+var (
+	pdftoppmOnce sync.Once
+	pdftoppmPath string
+)
+
+func pdftoppmCandidateNames() []string {
+	if runtime.GOOS == "windows" {
+		return []string{"pdftoppm.exe"}
+	}
+	return []string{"pdftoppm"}
+}
+
+// renderPDFFirstPage 使用 poppler 的 pdftoppm 渲染 PDF 首页，未安装时直接跳过
+func renderPDFFirstPage(src, dst string) error {
+	pdftoppmOnce.Do(func() {
+		pdftoppmPath = detectExecutable(pdftoppmCandidateNames())
+	})
+	if pdftoppmPath == "" {
+		return errors.New("pdftoppm not available")
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), attachmentPreviewTimeout)
+	defer cancel()
+	// -singlefile 让输出文件名固定为 <prefix>.png
+	prefix := strings.TrimSuffix(dst, filepath.Ext(dst))
+	args := []string{"-png", "-f", "1", "-l", "1", "-scale-to", "800", "-singlefile", src, prefix}
+	if output, err := exec.CommandContext(ctx, pdftoppmPath, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("pdftoppm failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	if !fileExists(dst) {
+		return errors.New("pdftoppm produced no image")
+	}
+	return nil
+}
+
+// readTextExcerpt 读取文本开头的一段，截断在合法的 UTF-8 边界上
+func readTextExcerpt(path string, limit int) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	buf := make([]byte, limit)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return ""
+	}
+	return truncateValidUTF8(buf[:n])
+}
+
+func truncateValidUTF8(data []byte) string {
+	// 只容忍末尾被截断的多字节字符，最多回退 3 字节
+	for i := 0; i < utf8.UTFMax-1 && len(data) > 0 && !utf8.Valid(data); i++ {
+		r, size := utf8.DecodeLastRune(data)
+		if r != utf8.RuneError || size != 1 {
+			break
+		}
+		data = data[:len(data)-1]
+	}
+	if !utf8.Valid(data) {
+		return ""
+	}
+	return string(data)
+}
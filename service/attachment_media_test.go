@@ -0,0 +1,71 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestAttachmentMediaKind(t *testing.T) {
+	tests := []struct {
+		mime     string
+		expected string
+	}{
+		{"image/webp", AttachmentKindImage},
+		{"video/mp4", AttachmentKindVideo},
+		{"VIDEO/WebM", AttachmentKindVideo},
+		{"audio/ogg", AttachmentKindAudio},
+		{"application/pdf", AttachmentKindDocument},
+		{"text/plain; charset=utf-8", AttachmentKindText},
+		{"application/json", AttachmentKindText},
+		{"application/zip", AttachmentKindFile},
+		{"", AttachmentKindFile},
+	}
+	for _, tt := range tests {
+		if got := AttachmentMediaKind(tt.mime); got != tt.expected {
+			t.Errorf("AttachmentMediaKind(%q) = %q, want %q", tt.mime, got, tt.expected)
+		}
+	}
+}
+
+func TestAttachmentMimeAllowed(t *testing.T) {
+	allowed := []string{"video/*", "application/pdf"}
+	if !AttachmentMimeAllowed("video/quicktime", allowed) {
+		t.Error("通配规则应允许 video/quicktime")
+	}
+	if !AttachmentMimeAllowed("application/pdf", allowed) {
+		t.Error("应允许 application/pdf")
+	}
+	if AttachmentMimeAllowed("application/zip", allowed) {
+		t.Error("不应允许 application/zip")
+	}
+	if AttachmentMimeAllowed("", allowed) {
+		t.Error("空 MIME 不应被允许")
+	}
+}
+
+func TestParseFFprobeVideoJSON(t *testing.T) {
+	output := []byte(`{"programs":[],"streams":[{"width":1920,"height":1080}],"format":{"duration":"12.480000"}}`)
+	result, err := parseFFprobeVideoJSON(output)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Width != 1920 || result.Height != 1080 {
+		t.Errorf("尺寸解析错误: %dx%d", result.Width, result.Height)
+	}
+	if result.Duration != 12.48 {
+		t.Errorf("时长解析错误: %v", result.Duration)
+	}
+
+	if _, err := parseFFprobeVideoJSON([]byte(`{"streams":[],"format":{}}`)); err == nil {
+		t.Error("无视频流时应返回错误")
+	}
+}
+
+func TestTruncateValidUTF8(t *testing.T) {
+	data := []byte("骰子")
+	if got := truncateValidUTF8(data[:len(data)-1]); got != "骰" {
+		t.Errorf("截断的多字节字符应被丢弃，得到 %q", got)
+	}
+	if got := truncateValidUTF8([]byte{0xff, 0xfe, 0x00, 0x01}); got != "" {
+		t.Errorf("二进制内容不应生成摘录，得到 %q", got)
+	}
+}
@@ -115,6 +115,10 @@ func AttachmentPublicURL(att *model.AttachmentModel) string {
 	if att == nil {
 		return ""
 	}
+	// 私有桶没有可直接访问的地址，由调用方改走签名下载
+	if att.StorageType == model.StorageS3 && GetStorageManager().PrivateBucket() {
+		return ""
+	}
 	if url := strings.TrimSpace(att.ExternalURL); url != "" {
 		return url
 	}
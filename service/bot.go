@@ -66,9 +66,9 @@ func BotListByChannelId(curUserId, channelId string) []string {
 			ids = append(ids, otherId)
 		}
 	} else {
-		// 获取子频道的授权
-		if ch.RootId != "" {
-			roleId := fmt.Sprintf("ch-%s-%s", ch.RootId, "bot")
+		// 获取子频道的授权：各级上级频道的机器人同样可用
+		for _, ancestor := range model.ChannelAncestors(ch) {
+			roleId := fmt.Sprintf("ch-%s-%s", ancestor.ID, "bot")
 			ids2, _ := model.UserRoleMappingUserIdListByRoleId(roleId)
 			ids = append(ids, ids2...)
 		}
@@ -111,6 +111,7 @@ func SyncBotMembers(token *model.BotTokenModel) error {
 	if name == "" {
 		return nil
 	}
+	defer model.PermCacheInvalidateUser(token.ID)
 	return model.GetDB().Model(&model.MemberModel{}).
 		Where("user_id = ?", token.ID).
 		Update("nickname", name).Error
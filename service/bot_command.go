@@ -0,0 +1,265 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"sealchat/protocol"
+)
+
+// BOT 斜杠指令的解析：把 "/cmd sub arg --opt value" 按 BOT 声明的参数表转为 Argv
+
+// BotCommandTokenize 将消息内容切分为指令 token，支持引号包裹含空格的参数。
+// at 元素转为 "@用户ID"；内容不以 / 开头或含图片等其他元素时返回 false
+func BotCommandTokenize(content string) ([]string, bool) {
+	if !strings.HasPrefix(strings.TrimSpace(content), "/") {
+		return nil, false
+	}
+	var sb strings.Builder
+	ok := true
+	var walk func(items []*protocol.Element)
+	walk = func(items []*protocol.Element) {
+		for _, el := range items {
+			switch el.Type {
+			case "text":
+				sb.WriteString(getStringAttr(el.Attrs, "content"))
+			case "at":
+				sb.WriteString(" @" + getStringAttr(el.Attrs, "id") + " ")
+			case "br":
+				sb.WriteString(" ")
+			case "p":
+				walk(el.Children)
+				sb.WriteString(" ")
+			default:
+				ok = false
+			}
+		}
+	}
+	root := protocol.ElementParse(content)
+	if root == nil {
+		return nil, false
+	}
+	walk(root.Children)
+	if !ok {
+		return nil, false
+	}
+	tokens, _ := botCommandSplit(sb.String())
+	if len(tokens) == 0 || len(tokens[0]) < 2 || tokens[0][0] != '/' {
+		return nil, false
+	}
+	return tokens, true
+}
+
+// botCommandSplit 按空白切分，引号内的空白保留；trailingSpace 表示末尾是否为空白（补全时用于判断是否在输入新参数）
+func botCommandSplit(text string) (tokens []string, trailingSpace bool) {
+	var cur strings.Builder
+	var quote rune
+	inToken := false
+	for _, r := range text {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inToken = true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inToken {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+				inToken = false
+			}
+		default:
+			cur.WriteRune(r)
+			inToken = true
+		}
+	}
+	if inToken {
+		tokens = append(tokens, cur.String())
+	}
+	return tokens, !inToken && len(text) > 0
+}
+
+// BotCommandFind 按名称查找指令，大小写不敏感
+func BotCommandFind(commands []protocol.Command, name string) *protocol.Command {
+	for i := range commands {
+		if strings.EqualFold(commands[i].Name, name) {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// botCommandDescend 逐层匹配子指令，返回最终指令、完整名称与剩余 token
+func botCommandDescend(cmd *protocol.Command, tokens []string) (*protocol.Command, string, []string) {
+	name := cmd.Name
+	for len(tokens) > 0 && len(cmd.Children) > 0 {
+		child := BotCommandFind(cmd.Children, tokens[0])
+		if child == nil {
+			break
+		}
+		cmd = child
+		name += " " + child.Name
+		tokens = tokens[1:]
+	}
+	return cmd, name, tokens
+}
+
+func botCommandFindOption(cmd *protocol.Command, name string) *protocol.CommandDeclaration {
+	for i := range cmd.Options {
+		if strings.EqualFold(cmd.Options[i].Name, name) {
+			return &cmd.Options[i]
+		}
+	}
+	return nil
+}
+
+func botCommandIsBool(decl *protocol.CommandDeclaration) bool {
+	t := strings.ToLower(decl.Type)
+	return t == "boolean" || t == "bool"
+}
+
+// botCommandConvert 按声明的类型转换参数值
+func botCommandConvert(decl *protocol.CommandDeclaration, raw string) (any, error) {
+	switch strings.ToLower(decl.Type) {
+	case "integer", "int":
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("参数 %s 需要整数", decl.Name)
+		}
+		return v, nil
+	case "number":
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("参数 %s 需要数字", decl.Name)
+		}
+		return v, nil
+	case "boolean", "bool":
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("参数 %s 需要 true 或 false", decl.Name)
+		}
+		return v, nil
+	case "user":
+		id := strings.TrimPrefix(raw, "@")
+		if id == "" {
+			return nil, fmt.Errorf("参数 %s 需要@一名用户", decl.Name)
+		}
+		return id, nil
+	}
+	return raw, nil
+}
+
+// BotCommandParse 按指令声明解析 token（不含指令名本身），返回交给 BOT 的 Argv
+func BotCommandParse(cmd *protocol.Command, tokens []string) (*protocol.Argv, error) {
+	cmd, name, tokens := botCommandDescend(cmd, tokens)
+	argv := &protocol.Argv{Name: name, Arguments: []interface{}{}, Options: map[string]interface{}{}}
+	var positional []string
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if !strings.HasPrefix(token, "--") || len(token) == 2 {
+			positional = append(positional, token)
+			continue
+		}
+		optName, value, hasValue := strings.Cut(token[2:], "=")
+		decl := botCommandFindOption(cmd, optName)
+		if decl == nil {
+			return nil, fmt.Errorf("未知选项 --%s", optName)
+		}
+		if !hasValue {
+			if botCommandIsBool(decl) {
+				argv.Options[decl.Name] = true
+				continue
+			}
+			if i+1 >= len(tokens) {
+				return nil, fmt.Errorf("选项 --%s 缺少取值", decl.Name)
+			}
+			i++
+			value = tokens[i]
+		}
+		v, err := botCommandConvert(decl, value)
+		if err != nil {
+			return nil, err
+		}
+		argv.Options[decl.Name] = v
+	}
+
+	for i := range cmd.Arguments {
+		decl := &cmd.Arguments[i]
+		if len(positional) == 0 {
+			if decl.Required {
+				return nil, fmt.Errorf("缺少参数 %s", decl.Name)
+			}
+			break
+		}
+		raw := positional[0]
+		positional = positional[1:]
+		if strings.ToLower(decl.Type) == "text" {
+			raw = strings.Join(append([]string{raw}, positional...), " ")
+			positional = nil
+		}
+		v, err := botCommandConvert(decl, raw)
+		if err != nil {
+			return nil, err
+		}
+		argv.Arguments = append(argv.Arguments, v)
+	}
+	if len(positional) > 0 {
+		return nil, fmt.Errorf("参数过多：%s", strings.Join(positional, " "))
+	}
+	for i := range cmd.Options {
+		decl := &cmd.Options[i]
+		if _, ok := argv.Options[decl.Name]; !ok && decl.Required {
+			return nil, fmt.Errorf("缺少选项 --%s", decl.Name)
+		}
+	}
+	return argv, nil
+}
+
+// BotCommandFocus 补全时定位正在输入的参数。
+// input 为不含指令名的剩余输入；返回完整指令名、正在输入的声明及其当前值，无法定位时 decl 为 nil
+func BotCommandFocus(cmd *protocol.Command, input string) (name string, decl *protocol.CommandDeclaration, value string) {
+	tokens, trailingSpace := botCommandSplit(input)
+	if !trailingSpace && len(tokens) > 0 {
+		value = tokens[len(tokens)-1]
+		tokens = tokens[:len(tokens)-1]
+	}
+	cmd, name, tokens = botCommandDescend(cmd, tokens)
+	if strings.HasPrefix(value, "--") {
+		optName, optValue, hasValue := strings.Cut(value[2:], "=")
+		if !hasValue {
+			return name, nil, value
+		}
+		return name, botCommandFindOption(cmd, optName), optValue
+	}
+	positional := 0
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if !strings.HasPrefix(token, "--") || len(token) == 2 {
+			positional++
+			continue
+		}
+		optName, _, hasValue := strings.Cut(token[2:], "=")
+		opt := botCommandFindOption(cmd, optName)
+		if opt == nil || hasValue || botCommandIsBool(opt) {
+			continue
+		}
+		if i == len(tokens)-1 {
+			return name, opt, value
+		}
+		i++
+	}
+	if n := len(cmd.Arguments); n > 0 {
+		if positional < n {
+			return name, &cmd.Arguments[positional], value
+		}
+		if last := &cmd.Arguments[n-1]; strings.ToLower(last.Type) == "text" {
+			return name, last, value
+		}
+	}
+	return name, nil, value
+}
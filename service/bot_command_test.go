@@ -0,0 +1,76 @@
+package service
+
+import (
+	"testing"
+
+	"sealchat/protocol"
+)
+
+func testBotCommand() *protocol.Command {
+	return &protocol.Command{
+		Name: "roll",
+		Arguments: []protocol.CommandDeclaration{
+			{Name: "times", Type: "integer", Required: true},
+			{Name: "reason", Type: "text"},
+		},
+		Options: []protocol.CommandDeclaration{
+			{Name: "hidden", Type: "boolean"},
+			{Name: "target", Type: "user", Autocomplete: true},
+		},
+		Children: []protocol.Command{
+			{Name: "stats", Arguments: []protocol.CommandDeclaration{{Name: "skill", Autocomplete: true}}},
+		},
+	}
+}
+
+func TestBotCommandParse(t *testing.T) {
+	tokens, ok := BotCommandTokenize(`/roll 3 "潜行 检定" 补充 --hidden --target <at id="u1"/>`)
+	if !ok {
+		t.Fatalf("expected command tokens")
+	}
+	argv, err := BotCommandParse(testBotCommand(), tokens[1:])
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if argv.Name != "roll" || len(argv.Arguments) != 2 {
+		t.Fatalf("unexpected argv: %+v", argv)
+	}
+	if argv.Arguments[0] != int64(3) || argv.Arguments[1] != "潜行 检定 补充" {
+		t.Fatalf("unexpected arguments: %#v", argv.Arguments)
+	}
+	if argv.Options["hidden"] != true || argv.Options["target"] != "u1" {
+		t.Fatalf("unexpected options: %#v", argv.Options)
+	}
+
+	if _, err := BotCommandParse(testBotCommand(), []string{"abc"}); err == nil {
+		t.Fatalf("expected type error")
+	}
+	if _, err := BotCommandParse(testBotCommand(), nil); err == nil {
+		t.Fatalf("expected missing argument error")
+	}
+	if argv, err := BotCommandParse(testBotCommand(), []string{"stats", "侦查"}); err != nil || argv.Name != "roll stats" {
+		t.Fatalf("subcommand parse failed: %+v %v", argv, err)
+	}
+
+	if _, ok := BotCommandTokenize(`/roll <img src="x"/>`); ok {
+		t.Fatalf("content with images should not be a command")
+	}
+	if _, ok := BotCommandTokenize("roll 1"); ok {
+		t.Fatalf("content without slash should not be a command")
+	}
+}
+
+func TestBotCommandFocus(t *testing.T) {
+	name, decl, value := BotCommandFocus(testBotCommand(), "stats 侦")
+	if name != "roll stats" || decl == nil || decl.Name != "skill" || value != "侦" {
+		t.Fatalf("unexpected focus: %s %+v %q", name, decl, value)
+	}
+	_, decl, value = BotCommandFocus(testBotCommand(), "1 --target ")
+	if decl == nil || decl.Name != "target" || value != "" {
+		t.Fatalf("unexpected option focus: %+v %q", decl, value)
+	}
+	_, decl, _ = BotCommandFocus(testBotCommand(), "1 --hidden ")
+	if decl == nil || decl.Name != "reason" {
+		t.Fatalf("bool option should not take a value: %+v", decl)
+	}
+}
@@ -19,7 +19,8 @@ func ChannelIdList(userId string) ([]string, error) {
 	// 包括如下内容:
 	// 1. 属性为可见的一级频道(即没有父级的频道)
 	// 2. 具有明确可看权限的频道(先查频道角色，再根据频道角色验证权限和获取频道id)
-	// 3. 补入有权查看的频道的子频道
+	// 3. 自顶向下补入可见频道的子频道：公开的、有明确权限的、或继承上级“查看全部”与分类权限的
+	// 父频道不可见的频道不会被展开到，相当于剔除了空中楼阁子频道
 
 	roles, err := model.UserRoleMappingListByUserID(userId, "", "channel")
 	if err != nil {
@@ -32,46 +33,25 @@ func ChannelIdList(userId string) ([]string, error) {
 		Where("role_id in ? and permission_id in ?", roles, []string{pm.PermFuncChannelRead.ID(), pm.PermFuncChannelReadAll.ID()}).
 		Pluck("role_id", &rolesCanRead)
 
-	// 获得1 公开的一级频道
-	var idsPublic1 []string
-	db.Model(&model.ChannelModel{}).Where("coalesce(root_id, '') = '' and perm_type = ?", "public").
-		Pluck("id", &idsPublic1)
-
-	// 这里获得的是2: 具有明确可看权限的频道，包括公开频道和非公开频道
-	ids2 := lo.Map(rolesCanRead, func(item string, index int) string {
-		return strings.SplitN(item, "-", 3)[1]
-	})
-
-	// 将公开一级频道和有权限的频道组合起来
-	idsCanRead := append(idsPublic1, ids2...)
-
-	// 值得注意，ids2里可能混合了空中楼阁子频道，也就是说你没有他上级频道的权限
-	// 要在之后进行剔除。虽然目前版本不支持2级以上频道，所以理论上不会存在
-
-	// 3.1: 在可访问频道的基础上进一步加入公开的子频道
-	var ids3 []string
-	db.Model(&model.ChannelModel{}).Where("root_id in ? and perm_type = ?", idsCanRead, "public").
-		Pluck("id", &ids3)
-
-	// 3.2
-	// 先找出我有“查看全部”权限的的顶级频道
-	// 找出这些顶级频道的下属非公开频道
-	var rolesCanRead2 []string
+	var rolesCanReadAll []string
 	db.Model(&model.RolePermissionModel{}).
 		Where("role_id in ? and permission_id in ?", roles, []string{pm.PermFuncChannelReadAll.ID()}).
-		Pluck("role_id", &rolesCanRead2)
-	ids2x := lo.Map(rolesCanRead2, func(item string, index int) string {
-		return strings.SplitN(item, "-", 3)[1]
-	})
-	var ids32 []string
-	db.Model(&model.ChannelModel{}).Where("root_id in ? and perm_type = ?", ids2x, "non-public").
-		Pluck("id", &ids32)
+		Pluck("role_id", &rolesCanReadAll)
 
-	idsCanRead = append(idsCanRead, ids3...)
-	idsCanRead = append(idsCanRead, ids32...)
+	roleChannelID := func(item string) string {
+		return strings.SplitN(item, "-", 3)[1]
+	}
+	explicit := lo.SliceToMap(rolesCanRead, func(item string) (string, bool) { return roleChannelID(item), true })
+	readAll := lo.SliceToMap(rolesCanReadAll, func(item string) (string, bool) { return roleChannelID(item), true })
+	denied, err := channelApplyReadOverwrites(userId, explicit, readAll)
+	if err != nil {
+		return nil, err
+	}
 
-	// 对idsCanRead进行去重
-	idsCanRead = lo.Uniq(idsCanRead)
+	idsCanRead, err := channelReadableTree(explicit, readAll, denied)
+	if err != nil {
+		return nil, err
+	}
 
 	// 追加私聊频道ID，使其也参与未读统计
 	if privateIDs, err := model.FriendChannelIDList(userId); err == nil {
@@ -79,16 +59,6 @@ func ChannelIdList(userId string) ([]string, error) {
 	}
 	idsCanRead = lo.Uniq(idsCanRead)
 
-	// 剔除父频道不在可读列表中的频道，但保留顶级频道
-	var idsParentNotInCanRead []string
-	db.Model(&model.ChannelModel{}).
-		Where("id in ? and coalesce(parent_id,'') != '' and parent_id not in ?", idsCanRead, idsCanRead).
-		Pluck("id", &idsParentNotInCanRead)
-
-	idsCanRead = lo.Filter(idsCanRead, func(id string, _ int) bool {
-		return !lo.Contains(idsParentNotInCanRead, id)
-	})
-
 	return idsCanRead, nil
 }
 
@@ -215,21 +185,17 @@ func ChannelListPublicByWorld(worldID string) ([]*model.ChannelModel, error) {
 		if ch == nil || strings.TrimSpace(ch.ID) == "" {
 			continue
 		}
-		if strings.ToLower(strings.TrimSpace(ch.PermType)) != "public" {
+		// 频道及其所有上级都公开才对游客可见
+		if !channelChainPublic(ch, channelMap) {
 			continue
 		}
-		if ch.RootId != "" {
-			root := channelMap[ch.RootId]
-			if root == nil || strings.ToLower(strings.TrimSpace(root.PermType)) != "public" {
-				continue
-			}
-		}
 		visible = append(visible, ch)
 	}
 	return visible, nil
 }
 
-func CanGuestAccessChannel(channelID string) (*model.ChannelModel, error) {
+// CanGuestAccessChannel 检查游客能否访问频道，ip 用于匹配世界的 IP 封禁，未知时传空
+func CanGuestAccessChannel(channelID, ip string) (*model.ChannelModel, error) {
 	channelID = strings.TrimSpace(channelID)
 	if channelID == "" {
 		return nil, errors.New("频道ID不能为空")
@@ -241,21 +207,9 @@ func CanGuestAccessChannel(channelID string) (*model.ChannelModel, error) {
 	if channel == nil || strings.TrimSpace(channel.ID) == "" {
 		return nil, errors.New("频道不存在")
 	}
-	if channel.IsPrivate || strings.ToLower(strings.TrimSpace(channel.PermType)) != "public" {
+	if channel.IsPrivate || !channelChainPublic(channel, nil) {
 		return nil, errors.New("频道不可公开访问")
 	}
-	if channel.RootId != "" {
-		root, err := model.ChannelGet(channel.RootId)
-		if err != nil {
-			return nil, err
-		}
-		if root == nil || strings.TrimSpace(root.ID) == "" {
-			return nil, errors.New("频道不可公开访问")
-		}
-		if strings.ToLower(strings.TrimSpace(root.PermType)) != "public" {
-			return nil, errors.New("频道不可公开访问")
-		}
-	}
 	if channel.WorldID != "" {
 		world, err := GetWorldByID(channel.WorldID)
 		if err != nil {
@@ -264,11 +218,21 @@ func CanGuestAccessChannel(channelID string) (*model.ChannelModel, error) {
 		if world == nil || strings.ToLower(strings.TrimSpace(world.Visibility)) != model.WorldVisibilityPublic {
 			return nil, errors.New("世界未开放公开访问")
 		}
+		if ip != "" {
+			if err := WorldBanCheck(channel.WorldID, "", ip); err != nil {
+				return nil, err
+			}
+		}
 	}
 	return channel, nil
 }
 
 func ChannelNew(channelID, channelType, channelName, worldID, creatorId, parentId string) *model.ChannelModel {
+	return ChannelNewWithKind(channelID, "", channelType, channelName, worldID, creatorId, parentId)
+}
+
+// ChannelNewWithKind 创建频道，kind 为 model.ChannelKindCategory 时创建分类
+func ChannelNewWithKind(channelID, kind, channelType, channelName, worldID, creatorId, parentId string) *model.ChannelModel {
 	if strings.TrimSpace(worldID) == "" {
 		if w, err := GetOrCreateDefaultWorld(); err == nil && w != nil {
 			worldID = w.ID
@@ -280,7 +244,8 @@ func ChannelNew(channelID, channelType, channelName, worldID, creatorId, parentI
 		Name:               channelName,
 		PermType:           channelType,
 		ParentID:           parentId,
-		RootId:             parentId, // TODO: 这个是不准的，但是目前不允许二级以上子频道
+		RootId:             model.ChannelRootIDFor(parentId),
+		Kind:               kind,
 		DefaultDiceExpr:    "d20",
 		BuiltInDiceEnabled: true,
 		BotFeatureEnabled:  false,
@@ -292,6 +257,7 @@ func ChannelNew(channelID, channelType, channelName, worldID, creatorId, parentI
 			pm.PermFuncChannelTextSend,
 			pm.PermFuncChannelFileSend,
 			pm.PermFuncChannelAudioSend,
+			pm.PermFuncChannelMediaSend,
 			pm.PermFuncChannelInvite,
 			// pm.PermFuncChannelMemberRemove,
 			pm.PermFuncChannelSubChannelCreate,
@@ -318,6 +284,7 @@ func ChannelNew(channelID, channelType, channelName, worldID, creatorId, parentI
 			pm.PermFuncChannelTextSend,
 			pm.PermFuncChannelFileSend,
 			pm.PermFuncChannelAudioSend,
+			pm.PermFuncChannelMediaSend,
 			pm.PermFuncChannelInvite,
 			// pm.PermFuncChannelMemberRemove,
 			pm.PermFuncChannelSubChannelCreate,
@@ -427,6 +394,8 @@ func ChannelDissolve(channelID, operatorID string) (err error) {
 		}
 	}
 
+	// 先注册的 defer 后执行，保证事务提交后才让权限缓存失效
+	defer model.PermCacheInvalidateAll()
 	tx := model.GetDB().Begin()
 	if tx.Error != nil {
 		return tx.Error
@@ -462,6 +431,9 @@ func ChannelDissolve(channelID, operatorID string) (err error) {
 	if err = tx.Where("id LIKE ?", rolePattern).Delete(&model.ChannelRoleModel{}).Error; err != nil {
 		return err
 	}
+	if err = tx.Where("channel_id = ?", channelID).Delete(&model.ChannelPermOverwriteModel{}).Error; err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -501,12 +473,12 @@ func ChannelArchive(channelIDs []string, userID string, includeChildren bool) er
 		targetIDs = append(targetIDs, id)
 	}
 
-	// 如果需要包含子频道
+	// 如果需要包含子频道，逐层包含全部子孙频道
 	if includeChildren {
-		var childIDs []string
-		model.GetDB().Model(&model.ChannelModel{}).
-			Where("parent_id IN ? AND status = ?", targetIDs, model.ChannelStatusActive).
-			Pluck("id", &childIDs)
+		childIDs, err := model.ChannelDescendantIDs(targetIDs, model.ChannelStatusActive)
+		if err != nil {
+			return err
+		}
 		targetIDs = append(targetIDs, childIDs...)
 	}
 
@@ -557,12 +529,12 @@ func ChannelUnarchive(channelIDs []string, userID string, includeChildren bool)
 		targetIDs = append(targetIDs, id)
 	}
 
-	// 如果需要包含子频道
+	// 如果需要包含子频道，逐层包含全部子孙频道
 	if includeChildren {
-		var childIDs []string
-		model.GetDB().Model(&model.ChannelModel{}).
-			Where("parent_id IN ? AND status = ?", targetIDs, model.ChannelStatusArchived).
-			Pluck("id", &childIDs)
+		childIDs, err := model.ChannelDescendantIDs(targetIDs, model.ChannelStatusArchived)
+		if err != nil {
+			return err
+		}
 		targetIDs = append(targetIDs, childIDs...)
 	}
 
@@ -578,8 +550,9 @@ func ChannelUnarchive(channelIDs []string, userID string, includeChildren bool)
 	return err
 }
 
-// ChannelPermanentDelete 永久删除归档频道，仅世界拥有者可操作
-func ChannelPermanentDelete(channelIDs []string, userID string) error {
+// ChannelPermanentDelete 永久删除归档频道，仅世界拥有者可操作。
+// includeChildren 为 true 时一并删除全部子孙频道，否则子频道上移到被删频道的父级。
+func ChannelPermanentDelete(channelIDs []string, userID string, includeChildren bool) error {
 	if len(channelIDs) == 0 {
 		return errors.New("频道ID列表不能为空")
 	}
@@ -618,6 +591,56 @@ func ChannelPermanentDelete(channelIDs []string, userID string) error {
 		}
 	}
 
+	// 在事务外算好要删除的频道，以及需要上移的子频道
+	targetIDs := append([]string{}, channelIDs...)
+	type liftedChild struct {
+		ID          string
+		ParentID    string
+		RootID      string
+		Descendants []string
+	}
+	var lifted []liftedChild
+	if includeChildren {
+		childIDs, err := model.ChannelDescendantIDs(channelIDs)
+		if err != nil {
+			return err
+		}
+		targetIDs = append(targetIDs, childIDs...)
+	} else {
+		deleting := lo.SliceToMap(channelIDs, func(id string) (string, bool) { return id, true })
+		for _, ch := range channels {
+			// 上移到最近一个不被删除的上级
+			newParent := ""
+			for _, ancestor := range model.ChannelAncestors(ch) {
+				if !deleting[ancestor.ID] {
+					newParent = ancestor.ID
+					break
+				}
+			}
+			var childIDs []string
+			if err := model.GetDB().Model(&model.ChannelModel{}).Where("parent_id = ?", ch.ID).Pluck("id", &childIDs).Error; err != nil {
+				return err
+			}
+			for _, childID := range childIDs {
+				if deleting[childID] {
+					continue
+				}
+				descendants, err := model.ChannelDescendantIDs([]string{childID})
+				if err != nil {
+					return err
+				}
+				lifted = append(lifted, liftedChild{
+					ID:          childID,
+					ParentID:    newParent,
+					RootID:      model.ChannelRootIDFor(newParent),
+					Descendants: descendants,
+				})
+			}
+		}
+	}
+
+	// 先注册的 defer 后执行，保证事务提交后才让权限缓存失效
+	defer model.PermCacheInvalidateAll()
 	tx := model.GetDB().Begin()
 	if tx.Error != nil {
 		return tx.Error
@@ -632,10 +655,25 @@ func ChannelPermanentDelete(channelIDs []string, userID string) error {
 		}
 	}()
 
-	for _, channelID := range channelIDs {
-		// 删除频道及其子频道
-		if err = tx.Where("id = ? OR parent_id = ?", channelID, channelID).
-			Delete(&model.ChannelModel{}).Error; err != nil {
+	for _, child := range lifted {
+		if err = tx.Model(&model.ChannelModel{}).Where("id = ?", child.ID).
+			Updates(map[string]any{"parent_id": child.ParentID, "root_id": child.RootID}).Error; err != nil {
+			return err
+		}
+		if len(child.Descendants) > 0 {
+			descendantRoot := child.RootID
+			if descendantRoot == "" {
+				descendantRoot = child.ID
+			}
+			if err = tx.Model(&model.ChannelModel{}).Where("id IN ?", child.Descendants).
+				Update("root_id", descendantRoot).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, channelID := range targetIDs {
+		if err = tx.Where("id = ?", channelID).Delete(&model.ChannelModel{}).Error; err != nil {
 			return err
 		}
 
@@ -650,6 +688,9 @@ func ChannelPermanentDelete(channelIDs []string, userID string) error {
 		if err = tx.Where("id LIKE ?", rolePattern).Delete(&model.ChannelRoleModel{}).Error; err != nil {
 			return err
 		}
+		if err = tx.Where("channel_id = ?", channelID).Delete(&model.ChannelPermOverwriteModel{}).Error; err != nil {
+			return err
+		}
 	}
 
 	return nil
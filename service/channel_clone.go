@@ -69,6 +69,8 @@ func ChannelClone(sourceChannelID string, actor *model.UserModel, params Channel
 	if actor == nil || strings.TrimSpace(actor.ID) == "" {
 		return nil, errors.New("未登录")
 	}
+	// 复制成员与角色绑定在事务内完成，提交后再让权限缓存失效
+	defer model.PermCacheInvalidateAll()
 
 	source, err := model.ChannelGet(sourceChannelID)
 	if err != nil {
@@ -134,6 +136,7 @@ func ChannelClone(sourceChannelID string, actor *model.UserModel, params Channel
 		Where("id = ?", newChannel.ID).
 		Updates(map[string]any{
 			"note":                     source.Note,
+			"kind":                     source.Kind,
 			"sort_order":               source.SortOrder,
 			"default_dice_expr":        source.DefaultDiceExpr,
 			"built_in_dice_enabled":    source.BuiltInDiceEnabled,
@@ -299,6 +302,7 @@ func cleanupClonedChannel(channelID string) {
 	if channelID == "" {
 		return
 	}
+	defer model.PermCacheInvalidateAll()
 	db := model.GetDB()
 	var noteIDs []string
 	db.Model(&model.StickyNoteModel{}).Where("channel_id = ?", channelID).Pluck("id", &noteIDs)
@@ -306,6 +310,7 @@ func cleanupClonedChannel(channelID string) {
 		db.Where("sticky_note_id IN ?", noteIDs).Delete(&model.StickyNoteUserStateModel{})
 	}
 	db.Where("channel_id = ?", channelID).Delete(&model.StickyNoteModel{})
+	db.Where("channel_id = ?", channelID).Delete(&model.StickyNoteFolderModel{})
 
 	db.Where("channel_id = ?", channelID).Delete(&model.MemberModel{})
 	db.Where("channel_id = ?", channelID).Delete(&model.ChannelIdentityModel{})
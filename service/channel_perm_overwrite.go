@@ -0,0 +1,244 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mikespook/gorbac"
+	"github.com/samber/lo"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/pm/gen"
+)
+
+var ErrPermOverwriteInvalid = errors.New("权限覆盖参数无效")
+
+// ChannelPermOverwriteEntry 单个用户在频道上的覆盖汇总
+type ChannelPermOverwriteEntry struct {
+	UserID string           `json:"userId"`
+	User   *model.UserModel `json:"user,omitempty"`
+	Allow  []string         `json:"allow"`
+	Deny   []string         `json:"deny"`
+}
+
+// ChannelPermExplanation 某用户在频道上某项权限的判定结果与链路
+type ChannelPermExplanation struct {
+	UserID         string               `json:"userId"`
+	ChannelID      string               `json:"channelId"`
+	Permission     string               `json:"permission"`
+	PermissionName string               `json:"permissionName"`
+	Allowed        bool                 `json:"allowed"`
+	Steps          []pm.PermExplainStep `json:"steps"`
+	ChannelNames   map[string]string    `json:"channelNames"`
+}
+
+func channelPermOverwriteTarget(channelID, actorID string) (*model.ChannelModel, error) {
+	ch, err := model.ChannelGet(strings.TrimSpace(channelID))
+	if err != nil {
+		return nil, err
+	}
+	if ch == nil || ch.ID == "" || ch.IsPrivate {
+		return nil, ErrChannelTreeNotFound
+	}
+	if !canManageChannelPermOverwrites(ch, actorID) {
+		return nil, ErrWorldPermission
+	}
+	return ch, nil
+}
+
+func canManageChannelPermOverwrites(ch *model.ChannelModel, actorID string) bool {
+	if pm.CanWithChannelRole(actorID, ch.ID, pm.PermFuncChannelManageRole) {
+		return true
+	}
+	if ch.WorldID != "" && IsWorldAdmin(ch.WorldID, actorID) {
+		return true
+	}
+	return pm.CanWithSystemRole(actorID, pm.PermModAdmin)
+}
+
+// permOverwriteRootOnly Root 级权限只能由持有 func_channel_manage_role_root 的人调整
+var permOverwriteRootOnly = map[string]bool{
+	pm.PermFuncChannelManageRoleRoot.ID(): true,
+	pm.PermFuncChannelRoleLinkRoot.ID():   true,
+	pm.PermFuncChannelRoleUnlinkRoot.ID(): true,
+}
+
+// checkPermOverwriteGrant 只能授予自己持有的权限；与现有覆盖相比发生变化的 Root 级权限需要 Root 管理权限。
+// 世界拥有者与平台管理员不受限制
+func checkPermOverwriteGrant(ch *model.ChannelModel, actorID, userID string, allow, deny []string) error {
+	if (ch.WorldID != "" && IsWorldOwner(ch.WorldID, actorID)) || pm.CanWithSystemRole(actorID, pm.PermModAdmin) {
+		return nil
+	}
+	existing, err := model.ChannelPermOverwriteList(ch.ID, userID)
+	if err != nil {
+		return err
+	}
+	before := map[string]string{}
+	for _, item := range existing {
+		before[item.PermissionID] = item.Effect
+	}
+	after := map[string]string{}
+	for _, id := range allow {
+		after[id] = model.PermOverwriteAllow
+	}
+	for _, id := range deny {
+		after[id] = model.PermOverwriteDeny
+	}
+	isRoot := pm.CanWithChannelRole(actorID, ch.ID, pm.PermFuncChannelManageRoleRoot)
+	for id := range lo.Assign(before, after) {
+		if before[id] == after[id] {
+			continue
+		}
+		if permOverwriteRootOnly[id] && !isRoot {
+			return fmt.Errorf("%w：调整 %s 需要 Root 管理权限", ErrWorldPermission, id)
+		}
+		if after[id] == model.PermOverwriteAllow && !pm.CanWithChannelRole(actorID, ch.ID, gorbac.NewStdPermission(id)) {
+			return fmt.Errorf("%w：不能授予自己没有的权限 %s", ErrWorldPermission, id)
+		}
+	}
+	return nil
+}
+
+// normalizePermOverwriteIDs 仅允许频道权限，去重后排序
+func normalizePermOverwriteIDs(perms []string) ([]string, error) {
+	result := make([]string, 0, len(perms))
+	seen := map[string]struct{}{}
+	for _, item := range perms {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if _, ok := gen.PermChannelMap[item]; !ok {
+			return nil, fmt.Errorf("%w：未知的频道权限 %s", ErrPermOverwriteInvalid, item)
+		}
+		if _, ok := seen[item]; ok {
+			continue
+		}
+		seen[item] = struct{}{}
+		result = append(result, item)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// ChannelPermOverwriteList 列出频道内所有用户的覆盖
+func ChannelPermOverwriteList(channelID, actorID string) ([]*ChannelPermOverwriteEntry, error) {
+	ch, err := channelPermOverwriteTarget(channelID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	items, err := model.ChannelPermOverwriteList(ch.ID, "")
+	if err != nil {
+		return nil, err
+	}
+	result := []*ChannelPermOverwriteEntry{}
+	byUser := map[string]*ChannelPermOverwriteEntry{}
+	for _, item := range items {
+		entry := byUser[item.UserID]
+		if entry == nil {
+			entry = &ChannelPermOverwriteEntry{UserID: item.UserID, Allow: []string{}, Deny: []string{}}
+			if user := model.UserGet(item.UserID); user != nil && user.ID != "" {
+				entry.User = user
+			}
+			byUser[item.UserID] = entry
+			result = append(result, entry)
+		}
+		if item.Effect == model.PermOverwriteAllow {
+			entry.Allow = append(entry.Allow, item.PermissionID)
+		} else {
+			entry.Deny = append(entry.Deny, item.PermissionID)
+		}
+	}
+	return result, nil
+}
+
+// ChannelPermOverwriteSet 整体替换用户在频道上的覆盖，allow 与 deny 均为空时清除
+func ChannelPermOverwriteSet(channelID, actorID, userID string, allow, deny []string) (*ChannelPermOverwriteEntry, error) {
+	ch, err := channelPermOverwriteTarget(channelID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	userID = strings.TrimSpace(userID)
+	if user := model.UserGet(userID); userID == "" || user == nil || user.ID == "" {
+		return nil, fmt.Errorf("%w：用户不存在", ErrPermOverwriteInvalid)
+	}
+	if userID == actorID {
+		return nil, fmt.Errorf("%w：不能修改自己的权限覆盖", ErrPermOverwriteInvalid)
+	}
+	if ch.WorldID != "" {
+		if !IsWorldMember(ch.WorldID, userID) {
+			return nil, fmt.Errorf("%w：用户不是世界成员", ErrPermOverwriteInvalid)
+		}
+		if IsWorldOwner(ch.WorldID, userID) {
+			return nil, fmt.Errorf("%w：不能修改世界拥有者的权限", ErrPermOverwriteInvalid)
+		}
+	}
+	if allow, err = normalizePermOverwriteIDs(allow); err != nil {
+		return nil, err
+	}
+	if deny, err = normalizePermOverwriteIDs(deny); err != nil {
+		return nil, err
+	}
+	denySet := map[string]struct{}{}
+	for _, id := range deny {
+		denySet[id] = struct{}{}
+	}
+	for _, id := range allow {
+		if _, ok := denySet[id]; ok {
+			return nil, fmt.Errorf("%w：%s 不能同时允许和禁止", ErrPermOverwriteInvalid, id)
+		}
+	}
+	if err := checkPermOverwriteGrant(ch, actorID, userID, allow, deny); err != nil {
+		return nil, err
+	}
+	if err := model.ChannelPermOverwriteReplace(ch.WorldID, ch.ID, userID, actorID, allow, deny); err != nil {
+		return nil, err
+	}
+	return &ChannelPermOverwriteEntry{UserID: userID, Allow: allow, Deny: deny}, nil
+}
+
+// ChannelPermExplain 说明用户在频道上能否使用某项权限；查询自己时无需管理权限
+func ChannelPermExplain(channelID, actorID, userID, permID string) (*ChannelPermExplanation, error) {
+	ch, err := model.ChannelGet(strings.TrimSpace(channelID))
+	if err != nil {
+		return nil, err
+	}
+	if ch == nil || ch.ID == "" || ch.IsPrivate {
+		return nil, ErrChannelTreeNotFound
+	}
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		userID = actorID
+	}
+	if userID != actorID && !canManageChannelPermOverwrites(ch, actorID) {
+		return nil, ErrWorldPermission
+	}
+	permID = strings.TrimSpace(permID)
+	name, ok := gen.PermChannelMap[permID]
+	if !ok {
+		return nil, fmt.Errorf("%w：未知的频道权限 %s", ErrPermOverwriteInvalid, permID)
+	}
+
+	allowed, steps := pm.ExplainChannelPerm(userID, ch.ID, gorbac.NewStdPermission(permID))
+	names := map[string]string{ch.ID: ch.Name}
+	for _, step := range steps {
+		if _, ok := names[step.ChannelID]; ok {
+			continue
+		}
+		if item, _ := model.ChannelGet(step.ChannelID); item != nil {
+			names[step.ChannelID] = item.Name
+		}
+	}
+	return &ChannelPermExplanation{
+		UserID:         userID,
+		ChannelID:      ch.ID,
+		Permission:     permID,
+		PermissionName: name,
+		Allowed:        allowed,
+		Steps:          steps,
+		ChannelNames:   names,
+	}, nil
+}
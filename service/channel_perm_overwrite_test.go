@@ -0,0 +1,138 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/samber/lo"
+
+	"sealchat/model"
+	"sealchat/pm"
+)
+
+func TestChannelPermOverwrite(t *testing.T) {
+	worldID := "world-overwrite"
+	ownerID := "owner-overwrite"
+	playerID := "player-overwrite"
+	seedTestWorld(t, testWorld{
+		World:   model.WorldModel{StringPKBaseModel: model.StringPKBaseModel{ID: worldID}, Name: "Overwrite World", OwnerID: ownerID},
+		Members: []string{playerID},
+		Channels: []model.ChannelModel{
+			{StringPKBaseModel: model.StringPKBaseModel{ID: "owhall"}, Name: "大厅", PermType: "public"},
+			{StringPKBaseModel: model.StringPKBaseModel{ID: "owgm"}, Name: "GM室", PermType: "non-public"},
+		},
+	})
+	pm.Init()
+	memberRole := "ch-owhall-member"
+	pm.RolePermApply(memberRole, []string{pm.PermFuncChannelRead.ID(), pm.PermFuncChannelTextSend.ID()})
+	if err := model.UserRoleMappingCreate(&model.UserRoleMappingModel{RoleType: "channel", UserID: playerID, RoleID: memberRole}); err != nil {
+		t.Fatalf("create role mapping failed: %v", err)
+	}
+
+	if pm.CanWithChannelRole(playerID, "owhall", pm.PermFuncChannelMessagePin) {
+		t.Fatalf("member role should not pin messages")
+	}
+	if _, err := ChannelPermOverwriteSet("owhall", playerID, playerID, []string{pm.PermFuncChannelMessagePin.ID()}, nil); !errors.Is(err, ErrWorldPermission) {
+		t.Fatalf("player should not manage overwrites, got %v", err)
+	}
+	if _, err := ChannelPermOverwriteSet("owhall", ownerID, playerID, []string{"func_channel_read"}, []string{"func_channel_read"}); !errors.Is(err, ErrPermOverwriteInvalid) {
+		t.Fatalf("conflicting overwrite should be rejected, got %v", err)
+	}
+	if _, err := ChannelPermOverwriteSet("owhall", ownerID, playerID,
+		[]string{pm.PermFuncChannelMessagePin.ID()}, []string{pm.PermFuncChannelTextSend.ID()}); err != nil {
+		t.Fatalf("set overwrite failed: %v", err)
+	}
+	if !pm.CanWithChannelRole(playerID, "owhall", pm.PermFuncChannelMessagePin) {
+		t.Fatalf("allow overwrite should grant the permission")
+	}
+	if pm.CanWithChannelRole(playerID, "owhall", pm.PermFuncChannelTextSend) {
+		t.Fatalf("deny overwrite should win over the role grant")
+	}
+
+	explain, err := ChannelPermExplain("owhall", ownerID, playerID, pm.PermFuncChannelTextSend.ID())
+	if err != nil {
+		t.Fatalf("explain failed: %v", err)
+	}
+	if explain.Allowed || len(explain.Steps) != 1 || explain.Steps[0].Source != pm.PermExplainSourceOverwrite || explain.Steps[0].Effect != pm.PermExplainEffectDeny {
+		t.Fatalf("unexpected explanation: %+v", explain)
+	}
+	explain, err = ChannelPermExplain("owhall", playerID, "", pm.PermFuncChannelRead.ID())
+	if err != nil {
+		t.Fatalf("self explain failed: %v", err)
+	}
+	if !explain.Allowed || explain.Steps[0].Source != pm.PermExplainSourceRole || !lo.Contains(explain.Steps[0].RoleIDs, memberRole) {
+		t.Fatalf("read should come from the member role: %+v", explain)
+	}
+
+	// 单独开放非公开频道的查看权限
+	if _, err := ChannelPermOverwriteSet("owgm", ownerID, playerID, []string{pm.PermFuncChannelRead.ID()}, nil); err != nil {
+		t.Fatalf("set overwrite failed: %v", err)
+	}
+	ids, err := ChannelIdList(playerID)
+	if err != nil {
+		t.Fatalf("list channel ids failed: %v", err)
+	}
+	if !lo.Contains(ids, "owgm") {
+		t.Fatalf("allow read overwrite should expose the channel, got %v", ids)
+	}
+	items, err := ChannelPermOverwriteList("owgm", ownerID)
+	if err != nil || len(items) != 1 || items[0].UserID != playerID {
+		t.Fatalf("unexpected overwrite list: %+v, %v", items, err)
+	}
+
+	if err := WorldLeave(worldID, playerID); err != nil {
+		t.Fatalf("leave failed: %v", err)
+	}
+	if pm.CanWithChannelRole(playerID, "owgm", pm.PermFuncChannelRead) {
+		t.Fatalf("leaving the world should drop overwrites")
+	}
+}
+
+func TestChannelPermOverwriteGrantLimits(t *testing.T) {
+	ownerID := "owner-ow-limit"
+	managerID := "manager-ow-limit"
+	playerID := "player-ow-limit"
+	outsiderID := "outsider-ow-limit"
+	seedTestWorld(t, testWorld{
+		World:    model.WorldModel{StringPKBaseModel: model.StringPKBaseModel{ID: "world-ow-limit"}, OwnerID: ownerID},
+		Members:  []string{managerID, playerID},
+		Users:    []string{outsiderID},
+		Channels: []model.ChannelModel{{StringPKBaseModel: model.StringPKBaseModel{ID: "owlimit"}, Name: "大厅", PermType: "public"}},
+	})
+	pm.Init()
+	managerRole := "ch-owlimit-manager"
+	pm.RolePermApply(managerRole, []string{pm.PermFuncChannelRead.ID(), pm.PermFuncChannelManageRole.ID(), pm.PermFuncChannelMessagePin.ID()})
+	if err := model.UserRoleMappingCreate(&model.UserRoleMappingModel{RoleType: "channel", UserID: managerID, RoleID: managerRole}); err != nil {
+		t.Fatalf("create role mapping failed: %v", err)
+	}
+
+	pin := pm.PermFuncChannelMessagePin.ID()
+	cases := []struct {
+		name   string
+		target string
+		allow  []string
+		want   error
+	}{
+		{"self", managerID, []string{pin}, ErrPermOverwriteInvalid},
+		{"owner", ownerID, nil, ErrPermOverwriteInvalid},
+		{"non-member", outsiderID, []string{pin}, ErrPermOverwriteInvalid},
+		{"not held", playerID, []string{pm.PermFuncChannelManageMute.ID()}, ErrWorldPermission},
+		{"root only", playerID, []string{pm.PermFuncChannelManageRoleRoot.ID()}, ErrWorldPermission},
+	}
+	for _, c := range cases {
+		if _, err := ChannelPermOverwriteSet("owlimit", managerID, c.target, c.allow, []string{pm.PermFuncChannelTextSend.ID()}); !errors.Is(err, c.want) {
+			t.Fatalf("%s: expected %v, got %v", c.name, c.want, err)
+		}
+	}
+	if _, err := ChannelPermOverwriteSet("owlimit", managerID, playerID, []string{pin}, nil); err != nil {
+		t.Fatalf("manager should grant a held permission: %v", err)
+	}
+	if _, err := ChannelPermOverwriteSet("owlimit", ownerID, playerID, []string{pm.PermFuncChannelManageMute.ID()}, nil); err != nil {
+		t.Fatalf("world owner should not be limited: %v", err)
+	}
+	// 未改动他人已有的授权时，管理者仍可调整其余项
+	if _, err := ChannelPermOverwriteSet("owlimit", managerID, playerID,
+		[]string{pm.PermFuncChannelManageMute.ID()}, []string{pm.PermFuncChannelTextSend.ID()}); err != nil {
+		t.Fatalf("unchanged grant should not need to be held: %v", err)
+	}
+}
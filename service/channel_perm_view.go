@@ -0,0 +1,152 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/mikespook/gorbac"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/pm/gen"
+)
+
+var (
+	ErrPermPreviewUserNotFound = errors.New("预览的用户不存在")
+	ErrPermPreviewNotMember    = errors.New("预览的用户不是世界成员")
+)
+
+// EffectivePermItem 单项权限的最终结果，Source 为决定结果的一步，未授予且无禁止覆盖时为空
+type EffectivePermItem struct {
+	Permission string              `json:"permission"`
+	Name       string              `json:"name"`
+	Allowed    bool                `json:"allowed"`
+	Source     *pm.PermExplainStep `json:"source,omitempty"`
+}
+
+// EffectivePermissions 用户在频道上的全部有效权限
+type EffectivePermissions struct {
+	UserID       string              `json:"userId"`
+	ChannelID    string              `json:"channelId"`
+	WorldRole    string              `json:"worldRole"` // owner/admin/member/spectator，非成员为空
+	Channel      []EffectivePermItem `json:"channel"`
+	System       []EffectivePermItem `json:"system"`
+	RoleNames    map[string]string   `json:"roleNames"`
+	ChannelNames map[string]string   `json:"channelNames"`
+}
+
+func sortedPermIDs(m map[string]string) []string {
+	ids := make([]string, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// decisivePermStep 放行时取最后一步；拒绝时取最后一条禁止覆盖
+func decisivePermStep(allowed bool, steps []pm.PermExplainStep) *pm.PermExplainStep {
+	for i := len(steps) - 1; i >= 0; i-- {
+		if allowed || steps[i].Effect == pm.PermExplainEffectDeny {
+			step := steps[i]
+			return &step
+		}
+	}
+	return nil
+}
+
+// ChannelEffectivePermissions 列出 pm/gen 中全部频道与系统权限对该用户的结果及来源；查询自己时无需管理权限
+func ChannelEffectivePermissions(channelID, actorID, userID string) (*EffectivePermissions, error) {
+	ch, err := model.ChannelGet(strings.TrimSpace(channelID))
+	if err != nil {
+		return nil, err
+	}
+	if ch == nil || ch.ID == "" || ch.IsPrivate {
+		return nil, ErrChannelTreeNotFound
+	}
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		userID = actorID
+	}
+	if userID != actorID && !canManageChannelPermOverwrites(ch, actorID) {
+		return nil, ErrWorldPermission
+	}
+
+	result := &EffectivePermissions{
+		UserID:       userID,
+		ChannelID:    ch.ID,
+		Channel:      []EffectivePermItem{},
+		System:       []EffectivePermItem{},
+		RoleNames:    map[string]string{},
+		ChannelNames: map[string]string{ch.ID: ch.Name},
+	}
+	if ch.WorldID != "" {
+		var member model.WorldMemberModel
+		if err := model.GetDB().Where("world_id = ? AND user_id = ?", ch.WorldID, userID).Limit(1).Find(&member).Error; err != nil {
+			return nil, err
+		}
+		result.WorldRole = member.Role
+	}
+
+	for _, id := range sortedPermIDs(gen.PermChannelMap) {
+		allowed, steps := pm.ExplainChannelPerm(userID, ch.ID, gorbac.NewStdPermission(id))
+		item := EffectivePermItem{Permission: id, Name: gen.PermChannelMap[id], Allowed: allowed, Source: decisivePermStep(allowed, steps)}
+		if item.Source != nil {
+			for _, roleID := range item.Source.RoleIDs {
+				result.RoleNames[roleID] = ""
+			}
+			result.ChannelNames[item.Source.ChannelID] = ""
+		}
+		result.Channel = append(result.Channel, item)
+	}
+	for _, id := range sortedPermIDs(gen.PermSystemMap) {
+		allowed, roles := pm.ExplainSystemPerm(userID, gorbac.NewStdPermission(id))
+		item := EffectivePermItem{Permission: id, Name: gen.PermSystemMap[id], Allowed: allowed}
+		if allowed {
+			item.Source = &pm.PermExplainStep{Level: pm.PermExplainLevelSystem, Source: pm.PermExplainSourceRole, Effect: pm.PermExplainEffectAllow, RoleIDs: roles}
+			for _, roleID := range roles {
+				result.RoleNames[roleID] = ""
+			}
+		}
+		result.System = append(result.System, item)
+	}
+
+	for roleID := range result.RoleNames {
+		name := roleID
+		if strings.HasPrefix(roleID, "ch-") {
+			if role, err := model.ChannelRoleGet(roleID); err == nil && role != nil && role.Name != "" {
+				name = role.Name
+			}
+		} else if role, err := model.SystemRoleGet(roleID); err == nil && role != nil && role.Name != "" {
+			name = role.Name
+		}
+		result.RoleNames[roleID] = name
+	}
+	for id := range result.ChannelNames {
+		if id == ch.ID {
+			continue
+		}
+		if item, _ := model.ChannelGet(id); item != nil {
+			result.ChannelNames[id] = item.Name
+		}
+	}
+	return result, nil
+}
+
+// ChannelPermPreviewCheck 管理员以他人视角预览频道列表与消息前的校验
+func ChannelPermPreviewCheck(worldID, actorID, targetUserID string) (*model.UserModel, error) {
+	worldID = strings.TrimSpace(worldID)
+	if worldID == "" || (!IsWorldAdmin(worldID, actorID) && !pm.CanWithSystemRole(actorID, pm.PermModAdmin)) {
+		return nil, ErrWorldPermission
+	}
+	target := model.UserGet(strings.TrimSpace(targetUserID))
+	if target == nil || target.ID == "" {
+		return nil, ErrPermPreviewUserNotFound
+	}
+	// 只能预览本世界成员的视角
+	if !IsWorldMember(worldID, target.ID) {
+		return nil, ErrPermPreviewNotMember
+	}
+	return target, nil
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"sealchat/model"
+	"sealchat/pm"
+)
+
+func TestChannelEffectivePermissions(t *testing.T) {
+	worldID := "world-effective"
+	ownerID := "owner-effective"
+	playerID := "player-effective"
+	outsiderID := "outsider-effective"
+	seedTestWorld(t, testWorld{
+		World:    model.WorldModel{StringPKBaseModel: model.StringPKBaseModel{ID: worldID}, OwnerID: ownerID},
+		Members:  []string{playerID},
+		Users:    []string{outsiderID},
+		Channels: []model.ChannelModel{{StringPKBaseModel: model.StringPKBaseModel{ID: "effhall"}, Name: "大厅", PermType: "non-public"}},
+	})
+	pm.Init()
+	db := model.GetDB()
+	memberRole := "ch-effhall-member"
+	if err := db.Create(&model.ChannelRoleModel{StringPKBaseModel: model.StringPKBaseModel{ID: memberRole}, Name: "成员", ChannelID: "effhall"}).Error; err != nil {
+		t.Fatalf("create role failed: %v", err)
+	}
+	pm.RolePermApply(memberRole, []string{pm.PermFuncChannelRead.ID(), pm.PermFuncChannelTextSend.ID()})
+	if err := model.UserRoleMappingCreate(&model.UserRoleMappingModel{RoleType: "channel", UserID: playerID, RoleID: memberRole}); err != nil {
+		t.Fatalf("create role mapping failed: %v", err)
+	}
+	if _, err := ChannelPermOverwriteSet("effhall", ownerID, playerID,
+		[]string{pm.PermFuncChannelMessagePin.ID()}, []string{pm.PermFuncChannelTextSend.ID()}); err != nil {
+		t.Fatalf("set overwrite failed: %v", err)
+	}
+
+	if _, err := ChannelEffectivePermissions("effhall", playerID, ownerID); !errors.Is(err, ErrWorldPermission) {
+		t.Fatalf("player should not inspect others, got %v", err)
+	}
+	result, err := ChannelEffectivePermissions("effhall", ownerID, playerID)
+	if err != nil {
+		t.Fatalf("effective permissions failed: %v", err)
+	}
+	if result.WorldRole != model.WorldRoleMember {
+		t.Fatalf("unexpected world role %q", result.WorldRole)
+	}
+	byID := map[string]EffectivePermItem{}
+	for _, item := range result.Channel {
+		byID[item.Permission] = item
+	}
+	if item := byID[pm.PermFuncChannelRead.ID()]; !item.Allowed || item.Source == nil || item.Source.Source != pm.PermExplainSourceRole {
+		t.Fatalf("read should come from a role: %+v", item)
+	}
+	if result.RoleNames[memberRole] != "成员" {
+		t.Fatalf("role name not resolved: %v", result.RoleNames)
+	}
+	if item := byID[pm.PermFuncChannelMessagePin.ID()]; !item.Allowed || item.Source.Source != pm.PermExplainSourceOverwrite {
+		t.Fatalf("pin should come from the overwrite: %+v", item)
+	}
+	if item := byID[pm.PermFuncChannelTextSend.ID()]; item.Allowed || item.Source == nil || item.Source.Effect != pm.PermExplainEffectDeny {
+		t.Fatalf("send should be denied by the overwrite: %+v", item)
+	}
+	if item := byID[pm.PermFuncChannelManageRole.ID()]; item.Allowed || item.Source != nil {
+		t.Fatalf("ungranted permission should have no source: %+v", item)
+	}
+
+	if _, err := ChannelPermPreviewCheck(worldID, playerID, ownerID); !errors.Is(err, ErrWorldPermission) {
+		t.Fatalf("member should not preview, got %v", err)
+	}
+	if target, err := ChannelPermPreviewCheck(worldID, ownerID, playerID); err != nil || target.ID != playerID {
+		t.Fatalf("owner preview failed: %v", err)
+	}
+	if _, err := ChannelPermPreviewCheck(worldID, ownerID, outsiderID); !errors.Is(err, ErrPermPreviewNotMember) {
+		t.Fatalf("previewing a non-member should be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,334 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+
+	"sealchat/model"
+	"sealchat/pm"
+)
+
+// 频道树：频道通过 ParentID 组成任意层级，RootId 记录顶层频道。
+// 分类（Kind=category）只用于组织子频道，不承载消息；其权限沿未设置 PermOverride 的链路传给子孙频道。
+
+var (
+	ErrChannelIsCategory   = errors.New("分类不能发送消息")
+	ErrChannelTreeInvalid  = errors.New("频道层级无效")
+	ErrChannelTreeNotFound = errors.New("频道不存在")
+)
+
+type channelTreeRow struct {
+	ID           string
+	ParentID     string
+	PermType     string
+	Kind         string
+	PermOverride bool
+}
+
+type channelTreeGrant struct {
+	all bool // 继承上级的“查看全部”
+	cat bool // 继承分类的查看权限
+}
+
+// channelReadableTree 自顶向下展开可读频道，explicit 为用户角色可读的频道，readAll 为有“查看全部”的频道，
+// denied 为用户被覆盖禁止查看的频道，仅上级的“查看全部”还能让它可见
+func channelReadableTree(explicit, readAll, denied map[string]bool) ([]string, error) {
+	db := model.GetDB()
+	cols := "id, parent_id, perm_type, kind, perm_override"
+
+	var roots []channelTreeRow
+	q := db.Model(&model.ChannelModel{}).Select(cols).Where("coalesce(parent_id, '') = ''")
+	if explicitIDs := lo.Keys(explicit); len(explicitIDs) > 0 {
+		q = q.Where("perm_type = ? OR id IN ?", "public", explicitIDs)
+	} else {
+		q = q.Where("perm_type = ?", "public")
+	}
+	if err := q.Find(&roots).Error; err != nil {
+		return nil, err
+	}
+
+	grants := map[string]channelTreeGrant{}
+	visible := make([]string, 0, len(roots))
+	var frontier []string
+	for _, row := range roots {
+		if denied[row.ID] && !readAll[row.ID] {
+			continue
+		}
+		grants[row.ID] = channelTreeGrant{
+			all: readAll[row.ID],
+			cat: row.Kind == model.ChannelKindCategory && explicit[row.ID],
+		}
+		visible = append(visible, row.ID)
+		frontier = append(frontier, row.ID)
+	}
+
+	for depth := 0; len(frontier) > 0 && depth < model.ChannelTreeMaxDepth; depth++ {
+		var children []channelTreeRow
+		if err := db.Model(&model.ChannelModel{}).Select(cols).
+			Where("parent_id IN ?", frontier).Find(&children).Error; err != nil {
+			return nil, err
+		}
+		frontier = frontier[:0:0]
+		for _, row := range children {
+			if _, ok := grants[row.ID]; ok {
+				continue
+			}
+			parent := grants[row.ParentID]
+			inheritCat := parent.cat && !row.PermOverride
+			if row.PermType != "public" && !explicit[row.ID] && !parent.all && !inheritCat {
+				continue
+			}
+			if denied[row.ID] && !parent.all && !readAll[row.ID] {
+				continue
+			}
+			grants[row.ID] = channelTreeGrant{
+				all: parent.all || readAll[row.ID],
+				cat: inheritCat || (row.Kind == model.ChannelKindCategory && explicit[row.ID]),
+			}
+			visible = append(visible, row.ID)
+			frontier = append(frontier, row.ID)
+		}
+	}
+	return visible, nil
+}
+
+// channelApplyReadOverwrites 将用户的查看权限覆盖并入 explicit/readAll，返回被禁止查看的频道
+func channelApplyReadOverwrites(userID string, explicit, readAll map[string]bool) (map[string]bool, error) {
+	var overwrites []*model.ChannelPermOverwriteModel
+	if err := model.GetDB().
+		Where("user_id = ? AND permission_id IN ?", userID, []string{pm.PermFuncChannelRead.ID(), pm.PermFuncChannelReadAll.ID()}).
+		Find(&overwrites).Error; err != nil {
+		return nil, err
+	}
+	denied := map[string]bool{}
+	for _, item := range overwrites {
+		allow := item.Effect == model.PermOverwriteAllow
+		if item.PermissionID == pm.PermFuncChannelReadAll.ID() {
+			readAll[item.ChannelID] = allow
+			if allow {
+				explicit[item.ChannelID] = true
+			}
+			continue
+		}
+		explicit[item.ChannelID] = allow
+		denied[item.ChannelID] = !allow
+	}
+	return denied, nil
+}
+
+// channelChainPublic 频道及其所有上级均为公开时返回 true，byID 为已加载的频道，缺失时回查数据库
+func channelChainPublic(ch *model.ChannelModel, byID map[string]*model.ChannelModel) bool {
+	isPublic := func(c *model.ChannelModel) bool {
+		return c != nil && strings.ToLower(strings.TrimSpace(c.PermType)) == "public"
+	}
+	if !isPublic(ch) {
+		return false
+	}
+	seen := map[string]bool{ch.ID: true}
+	parentID := strings.TrimSpace(ch.ParentID)
+	for i := 0; parentID != "" && i < model.ChannelTreeMaxDepth; i++ {
+		if seen[parentID] {
+			return false
+		}
+		seen[parentID] = true
+		parent := byID[parentID]
+		if parent == nil {
+			parent, _ = model.ChannelGet(parentID)
+			if parent == nil || parent.ID == "" {
+				return false
+			}
+		}
+		if !isPublic(parent) {
+			return false
+		}
+		parentID = strings.TrimSpace(parent.ParentID)
+	}
+	return true
+}
+
+// ChannelTreeNode 频道树节点
+type ChannelTreeNode struct {
+	*model.ChannelModel
+	Children []*ChannelTreeNode `json:"children"`
+}
+
+// BuildChannelTree 将频道列表组装为树，父频道不在列表中的频道作为顶层节点；同级按 SortOrder 降序
+func BuildChannelTree(channels []*model.ChannelModel) []*ChannelTreeNode {
+	nodes := make(map[string]*ChannelTreeNode, len(channels))
+	for _, ch := range channels {
+		if ch != nil && ch.ID != "" {
+			nodes[ch.ID] = &ChannelTreeNode{ChannelModel: ch, Children: []*ChannelTreeNode{}}
+		}
+	}
+	roots := []*ChannelTreeNode{}
+	for _, ch := range channels {
+		node := nodes[ch.ID]
+		if node == nil {
+			continue
+		}
+		if parent := nodes[ch.ParentID]; parent != nil && ch.ParentID != ch.ID {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	var sortNodes func(list []*ChannelTreeNode)
+	sortNodes = func(list []*ChannelTreeNode) {
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].SortOrder != list[j].SortOrder {
+				return list[i].SortOrder > list[j].SortOrder
+			}
+			return list[i].CreatedAt.Before(list[j].CreatedAt)
+		})
+		for _, n := range list {
+			sortNodes(n.Children)
+		}
+	}
+	sortNodes(roots)
+	return roots
+}
+
+// ChannelValidateParent 校验新频道或移动目标的父频道，返回父频道；parentID 为空表示顶层
+func ChannelValidateParent(worldID, parentID string) (*model.ChannelModel, error) {
+	parentID = strings.TrimSpace(parentID)
+	if parentID == "" {
+		return nil, nil
+	}
+	parent, err := model.ChannelGet(parentID)
+	if err != nil {
+		return nil, err
+	}
+	if parent == nil || parent.ID == "" {
+		return nil, fmt.Errorf("%w：父频道不存在", ErrChannelTreeInvalid)
+	}
+	if parent.IsPrivate || parent.WorldID != worldID {
+		return nil, fmt.Errorf("%w：父频道不属于该世界", ErrChannelTreeInvalid)
+	}
+	if parent.Status != model.ChannelStatusActive {
+		return nil, fmt.Errorf("%w：父频道已归档", ErrChannelTreeInvalid)
+	}
+	if len(model.ChannelAncestors(parent))+1 >= model.ChannelTreeMaxDepth {
+		return nil, fmt.Errorf("%w：层级过深", ErrChannelTreeInvalid)
+	}
+	return parent, nil
+}
+
+// ChannelCheckAcceptsMessages 分类不承载消息
+func ChannelCheckAcceptsMessages(ch *model.ChannelModel) error {
+	if ch.IsCategory() {
+		return ErrChannelIsCategory
+	}
+	return nil
+}
+
+// ChannelMove 将频道移到新的父频道下，并按 orderedIDs（同级频道，靠前者在前）重排 SortOrder
+func ChannelMove(channelID, actorID, parentID string, orderedIDs []string) (*model.ChannelModel, error) {
+	ch, err := model.ChannelGet(strings.TrimSpace(channelID))
+	if err != nil {
+		return nil, err
+	}
+	if ch == nil || ch.ID == "" || ch.IsPrivate {
+		return nil, ErrChannelTreeNotFound
+	}
+	if ch.WorldID == "" {
+		return nil, fmt.Errorf("%w：仅支持世界频道", ErrChannelTreeInvalid)
+	}
+	if !IsWorldAdmin(ch.WorldID, actorID) && !pm.CanWithSystemRole(actorID, pm.PermModAdmin) {
+		return nil, ErrWorldPermission
+	}
+	parentID = strings.TrimSpace(parentID)
+	parent, err := ChannelValidateParent(ch.WorldID, parentID)
+	if err != nil {
+		return nil, err
+	}
+	if parent != nil {
+		if parent.ID == ch.ID || lo.ContainsBy(model.ChannelAncestors(parent), func(a *model.ChannelModel) bool { return a.ID == ch.ID }) {
+			return nil, fmt.Errorf("%w：不能移动到自身或子频道下", ErrChannelTreeInvalid)
+		}
+	}
+
+	moved := parentID != ch.ParentID
+	var descendants []string
+	if moved {
+		// 事务外先取子孙，避免 SQLite 单连接下事务内再次取连接
+		if descendants, err = model.ChannelDescendantIDs([]string{ch.ID}); err != nil {
+			return nil, err
+		}
+	}
+	err = model.GetDB().Transaction(func(tx *gorm.DB) error {
+		if moved {
+			rootID := model.ChannelRootIDFor(parentID)
+			if err := tx.Model(&model.ChannelModel{}).Where("id = ?", ch.ID).Updates(map[string]any{
+				"parent_id":  parentID,
+				"root_id":    rootID,
+				"updated_at": time.Now(),
+			}).Error; err != nil {
+				return err
+			}
+			// 子孙频道的顶层随之变化
+			descendantRoot := rootID
+			if descendantRoot == "" {
+				descendantRoot = ch.ID
+			}
+			if len(descendants) > 0 {
+				if err := tx.Model(&model.ChannelModel{}).Where("id IN ?", descendants).
+					Update("root_id", descendantRoot).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(orderedIDs) > 0 {
+			var siblings []string
+			if err := tx.Model(&model.ChannelModel{}).
+				Where("world_id = ? AND coalesce(parent_id, '') = ? AND is_private = ?", ch.WorldID, parentID, false).
+				Pluck("id", &siblings).Error; err != nil {
+				return err
+			}
+			siblingSet := lo.SliceToMap(siblings, func(id string) (string, bool) { return id, true })
+			ordered := lo.Uniq(lo.Filter(orderedIDs, func(id string, _ int) bool { return siblingSet[id] }))
+			for i, id := range ordered {
+				if err := tx.Model(&model.ChannelModel{}).Where("id = ?", id).
+					Update("sort_order", len(ordered)-i).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if moved {
+		model.PermCacheInvalidateAll()
+	}
+	return model.ChannelGet(ch.ID)
+}
+
+// ChannelPermOverrideSet 设置频道是否不再继承所属分类的权限
+func ChannelPermOverrideSet(channelID, actorID string, override bool) (*model.ChannelModel, error) {
+	ch, err := model.ChannelGet(strings.TrimSpace(channelID))
+	if err != nil {
+		return nil, err
+	}
+	if ch == nil || ch.ID == "" || ch.IsPrivate {
+		return nil, ErrChannelTreeNotFound
+	}
+	if !pm.CanWithChannelRole(actorID, ch.ID, pm.PermFuncChannelManageRole) &&
+		!IsWorldAdmin(ch.WorldID, actorID) && !pm.CanWithSystemRole(actorID, pm.PermModAdmin) {
+		return nil, ErrWorldPermission
+	}
+	if err := model.GetDB().Model(&model.ChannelModel{}).Where("id = ?", ch.ID).
+		Update("perm_override", override).Error; err != nil {
+		return nil, err
+	}
+	model.PermCacheInvalidateAll()
+	ch.PermOverride = override
+	return ch, nil
+}
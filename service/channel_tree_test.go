@@ -0,0 +1,130 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+
+	"sealchat/model"
+	"sealchat/pm"
+)
+
+func TestBuildChannelTreeOrdering(t *testing.T) {
+	now := time.Now()
+	mk := func(id, parent string, order int) *model.ChannelModel {
+		return &model.ChannelModel{
+			StringPKBaseModel: model.StringPKBaseModel{ID: id, CreatedAt: now},
+			ParentID:          parent,
+			SortOrder:         order,
+		}
+	}
+	roots := BuildChannelTree([]*model.ChannelModel{
+		mk("a", "", 1),
+		mk("b", "", 2),
+		mk("a1", "a", 1),
+		mk("a2", "a", 5),
+		mk("a2x", "a2", 0),
+		mk("orphan", "missing", 0),
+	})
+	if len(roots) != 3 || roots[0].ID != "b" || roots[1].ID != "a" || roots[2].ID != "orphan" {
+		t.Fatalf("unexpected roots: %v", lo.Map(roots, func(n *ChannelTreeNode, _ int) string { return n.ID }))
+	}
+	a := roots[1]
+	if len(a.Children) != 2 || a.Children[0].ID != "a2" || a.Children[1].ID != "a1" {
+		t.Fatalf("children should be ordered by sortOrder desc")
+	}
+	if len(a.Children[0].Children) != 1 || a.Children[0].Children[0].ID != "a2x" {
+		t.Fatalf("grandchild not attached")
+	}
+}
+
+func TestChannelTreeCategoryInheritance(t *testing.T) {
+	worldID := "world-tree"
+	ownerID := "owner-tree"
+	playerID := "player-tree"
+	node := func(id, parent, kind string, override bool) model.ChannelModel {
+		return model.ChannelModel{
+			StringPKBaseModel: model.StringPKBaseModel{ID: id},
+			PermType:          "non-public",
+			ParentID:          parent,
+			RootId:            model.ChannelRootIDFor(parent),
+			Kind:              kind,
+			PermOverride:      override,
+		}
+	}
+	seedTestWorld(t, testWorld{
+		World: model.WorldModel{StringPKBaseModel: model.StringPKBaseModel{ID: worldID}, Name: "Tree World", OwnerID: ownerID},
+		Channels: []model.ChannelModel{
+			node("treecat", "", model.ChannelKindCategory, false),
+			node("treeroom", "treecat", "", false),
+			node("treedeep", "treeroom", "", false),
+			node("treesecret", "treecat", "", true),
+			node("treesecretsub", "treesecret", "", false),
+		},
+	})
+	pm.Init()
+
+	roleID := "ch-treecat-player"
+	pm.RolePermApply(roleID, []string{pm.PermFuncChannelRead.ID(), pm.PermFuncChannelTextSend.ID()})
+	if err := model.UserRoleMappingCreate(&model.UserRoleMappingModel{RoleType: "channel", UserID: playerID, RoleID: roleID}); err != nil {
+		t.Fatalf("create role mapping failed: %v", err)
+	}
+
+	if !pm.CanWithChannelRole(playerID, "treedeep", pm.PermFuncChannelTextSend) {
+		t.Fatalf("category permissions should reach nested channels")
+	}
+	if pm.CanWithChannelRole(playerID, "treesecret", pm.PermFuncChannelRead) ||
+		pm.CanWithChannelRole(playerID, "treesecretsub", pm.PermFuncChannelRead) {
+		t.Fatalf("override should stop category inheritance for the channel and its subtree")
+	}
+
+	ids, err := ChannelIdList(playerID)
+	if err != nil {
+		t.Fatalf("list channel ids failed: %v", err)
+	}
+	for _, id := range []string{"treecat", "treeroom", "treedeep"} {
+		if !lo.Contains(ids, id) {
+			t.Fatalf("%s should be visible, got %v", id, ids)
+		}
+	}
+	if lo.Contains(ids, "treesecret") || lo.Contains(ids, "treesecretsub") {
+		t.Fatalf("overridden subtree should be hidden, got %v", ids)
+	}
+
+	cat, _ := model.ChannelGet("treecat")
+	if err := ChannelCheckAcceptsMessages(cat); !errors.Is(err, ErrChannelIsCategory) {
+		t.Fatalf("category should reject messages, got %v", err)
+	}
+
+	// 不能移动到自身子孙下
+	if _, err := ChannelMove("treecat", ownerID, "treedeep", nil); !errors.Is(err, ErrChannelTreeInvalid) {
+		t.Fatalf("expected cycle rejection, got %v", err)
+	}
+	if _, err := ChannelMove("treeroom", playerID, "", nil); !errors.Is(err, ErrWorldPermission) {
+		t.Fatalf("non-admin move should be rejected, got %v", err)
+	}
+	moved, err := ChannelMove("treeroom", ownerID, "", []string{"treeroom", "treecat"})
+	if err != nil {
+		t.Fatalf("move failed: %v", err)
+	}
+	if moved.ParentID != "" || moved.RootId != "" || moved.SortOrder <= cat.SortOrder {
+		t.Fatalf("unexpected moved channel: parent=%q root=%q order=%d", moved.ParentID, moved.RootId, moved.SortOrder)
+	}
+	deep, _ := model.ChannelGet("treedeep")
+	if deep.RootId != "treeroom" {
+		t.Fatalf("descendant root should follow the moved channel, got %q", deep.RootId)
+	}
+	if pm.CanWithChannelRole(playerID, "treedeep", pm.PermFuncChannelRead) {
+		t.Fatalf("moving out of the category should drop inherited permissions")
+	}
+
+	if err := ChannelArchive([]string{"treecat"}, ownerID, true); err != nil {
+		t.Fatalf("archive failed: %v", err)
+	}
+	sub, _ := model.ChannelGet("treesecretsub")
+	if sub.Status != model.ChannelStatusArchived {
+		t.Fatalf("archive should cover the whole subtree, got %q", sub.Status)
+	}
+}
@@ -0,0 +1,373 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"sealchat/model"
+	"sealchat/protocol"
+	"sealchat/service/eventbus"
+	"sealchat/utils"
+
+	"github.com/samber/lo"
+)
+
+// 以下事件只反映瞬时状态，断线期间错过也无需补发
+var eventReplayEphemeral = map[protocol.EventName]bool{
+	protocol.EventTypingPreview:          true,
+	protocol.EventChannelPresenceUpdated: true,
+	protocol.EventChannelReadUpdated:     true,
+	protocol.EventMessageBulkProgress:    true,
+}
+
+type eventReplayEntry struct {
+	seq       int64
+	eventType string
+	frame     []byte
+	audience  []string
+	excluded  []string
+}
+
+// visibleTo 与推送时的范围保持一致，避免补发泄露悄悄话等定向事件
+func (e *eventReplayEntry) visibleTo(userID string) bool {
+	if len(e.audience) > 0 && !lo.Contains(e.audience, userID) {
+		return false
+	}
+	return !lo.Contains(e.excluded, userID)
+}
+
+type channelEventBuffer struct {
+	mu      sync.Mutex
+	loaded  bool
+	latest  int64
+	entries []*eventReplayEntry // 按序号升序，最多保留 MemoryPerChannel 条
+}
+
+var (
+	eventReplayBuffers   sync.Map
+	eventLogQueue        chan *model.ChannelEventLogModel
+	eventLogWriterOnce   sync.Once
+	eventReplayCleanOnce sync.Once
+)
+
+func eventReplayConfig() utils.EventReplayConfig {
+	if cfg := utils.GetConfig(); cfg != nil {
+		return cfg.EventReplay
+	}
+	return utils.EventReplayConfig{MemoryPerChannel: 256, MaxReplay: 1000, RetentionMinutes: 60}
+}
+
+func getChannelEventBuffer(channelID string) *channelEventBuffer {
+	value, _ := eventReplayBuffers.LoadOrStore(channelID, &channelEventBuffer{})
+	return value.(*channelEventBuffer)
+}
+
+// ensureLoaded 首次使用时确定序号起点，需持有 buf.mu。
+// 有持久化记录时接着数据库继续编号；否则以当前毫秒时间为起点，
+// 保证重启后序号仍然递增，旧连接带来的序号会因差距过大而要求全量刷新。
+func (buf *channelEventBuffer) ensureLoaded(channelID string, cfg utils.EventReplayConfig) {
+	if buf.loaded {
+		return
+	}
+	buf.loaded = true
+	if cfg.Persist {
+		if seq, err := model.ChannelEventLogMaxSeq(channelID); err == nil && seq > 0 {
+			buf.latest = seq
+			return
+		} else if err != nil {
+			log.Printf("[event-replay] 读取频道 %s 最新序号失败: %v", channelID, err)
+		}
+	}
+	buf.latest = time.Now().UnixMilli()
+	if eventbus.Default().Distributed() {
+		// 多实例时以数据库计数器为准，避免各实例按本地时间起点编号
+		if seq, err := model.ChannelEventSeqCurrent(channelID); err == nil && seq > 0 {
+			buf.latest = seq
+		}
+	}
+}
+
+// nextSeq 分配下一个序号，需持有 buf.mu。
+// 多实例部署时由数据库计数器分配，保证同一频道的序号在各实例间不重复
+func (buf *channelEventBuffer) nextSeq(channelID string) int64 {
+	if !eventbus.Default().Distributed() {
+		return buf.latest + 1
+	}
+	seq, err := model.ChannelEventSeqNext(channelID, buf.latest)
+	if err != nil {
+		log.Printf("[event-replay] 分配频道 %s 事件序号失败: %v", channelID, err)
+		return buf.latest + 1
+	}
+	return seq
+}
+
+// EventReplayRecord 为频道事件分配序号并写入回放缓冲。
+// audience 为空表示频道内所有人可见，excluded 为推送时排除的用户。
+func EventReplayRecord(channelID string, ev *protocol.Event, audience, excluded []string) {
+	if channelID == "" || ev == nil || eventReplayEphemeral[ev.Type] {
+		return
+	}
+	cfg := eventReplayConfig()
+	buf := getChannelEventBuffer(channelID)
+
+	buf.mu.Lock()
+	buf.ensureLoaded(channelID, cfg)
+	ev.Seq = buf.nextSeq(channelID)
+	frame, err := json.Marshal(struct {
+		protocol.Event
+		Op protocol.Opcode `json:"op"`
+	}{
+		Event: *ev,
+		Op:    protocol.OpEvent,
+	})
+	if err != nil {
+		buf.mu.Unlock()
+		ev.Seq = 0
+		log.Printf("[event-replay] 序列化事件失败: %v", err)
+		return
+	}
+	if ev.Seq > buf.latest {
+		buf.latest = ev.Seq
+	}
+	entry := &eventReplayEntry{
+		seq:       ev.Seq,
+		eventType: string(ev.Type),
+		frame:     frame,
+		audience:  append([]string(nil), audience...),
+		excluded:  append([]string(nil), excluded...),
+	}
+	buf.appendEntry(entry, cfg)
+	buf.mu.Unlock()
+
+	if cfg.Persist {
+		enqueueEventLog(channelID, entry)
+	}
+}
+
+// appendEntry 按序号插入内存缓冲并淘汰最旧的事件，需持有 buf.mu。
+// 其他实例的事件经总线到达时可能晚于本实例更大序号的事件
+func (buf *channelEventBuffer) appendEntry(entry *eventReplayEntry, cfg utils.EventReplayConfig) {
+	idx := len(buf.entries)
+	for idx > 0 && buf.entries[idx-1].seq > entry.seq {
+		idx--
+	}
+	if idx > 0 && buf.entries[idx-1].seq == entry.seq {
+		return
+	}
+	buf.entries = append(buf.entries, nil)
+	copy(buf.entries[idx+1:], buf.entries[idx:])
+	buf.entries[idx] = entry
+	if over := len(buf.entries) - cfg.MemoryPerChannel; over > 0 {
+		buf.entries = buf.entries[over:]
+	}
+}
+
+// EventReplayIngest 收录其他实例已编号并落库的事件，本实例的序号随之前移。
+// 序号由数据库计数器统一分配，各实例的事件按序号归并到同一缓冲
+func EventReplayIngest(channelID string, ev *protocol.Event, audience, excluded []string) {
+	if channelID == "" || ev == nil || ev.Seq <= 0 || eventReplayEphemeral[ev.Type] {
+		return
+	}
+	frame, err := json.Marshal(struct {
+		protocol.Event
+		Op protocol.Opcode `json:"op"`
+	}{
+		Event: *ev,
+		Op:    protocol.OpEvent,
+	})
+	if err != nil {
+		return
+	}
+	cfg := eventReplayConfig()
+	buf := getChannelEventBuffer(channelID)
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+	buf.ensureLoaded(channelID, cfg)
+	if ev.Seq > buf.latest {
+		buf.latest = ev.Seq
+	}
+	buf.appendEntry(&eventReplayEntry{
+		seq:       ev.Seq,
+		eventType: string(ev.Type),
+		frame:     frame,
+		audience:  append([]string(nil), audience...),
+		excluded:  append([]string(nil), excluded...),
+	}, cfg)
+}
+
+// EventReplayLatestSeq 返回频道当前最新事件序号
+func EventReplayLatestSeq(channelID string) int64 {
+	buf := getChannelEventBuffer(channelID)
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+	buf.ensureLoaded(channelID, eventReplayConfig())
+	return buf.latest
+}
+
+// EventReplayResult 单个频道的补发结果，Resync 为 true 时 Frames 为空
+type EventReplayResult struct {
+	Latest int64
+	Frames []json.RawMessage
+	Resync bool
+	Reason string
+}
+
+// EventReplayCollect 取出 afterSeq 之后该用户可见的事件帧。
+// 优先使用内存缓冲，不足部分从数据库补齐；序号不连续或数量超限时要求客户端全量刷新。
+func EventReplayCollect(channelID string, afterSeq int64, userID string) (*EventReplayResult, error) {
+	cfg := eventReplayConfig()
+	buf := getChannelEventBuffer(channelID)
+
+	buf.mu.Lock()
+	buf.ensureLoaded(channelID, cfg)
+	latest := buf.latest
+	var oldest int64
+	if len(buf.entries) > 0 {
+		oldest = buf.entries[0].seq
+	}
+	var memEntries []*eventReplayEntry
+	for _, entry := range buf.entries {
+		if entry.seq > afterSeq {
+			memEntries = append(memEntries, entry)
+		}
+	}
+	buf.mu.Unlock()
+
+	result := &EventReplayResult{Latest: latest}
+	switch {
+	case afterSeq > latest:
+		return resyncResult(result, "序号无效"), nil
+	case afterSeq == latest:
+		return result, nil
+	case latest-afterSeq > int64(cfg.MaxReplay):
+		return resyncResult(result, "遗漏事件过多"), nil
+	}
+
+	var collected []*eventReplayEntry
+	if oldest == 0 || oldest > afterSeq+1 {
+		if !cfg.Persist {
+			return resyncResult(result, "事件已过期"), nil
+		}
+		upto := latest
+		if oldest > 0 {
+			upto = oldest - 1
+		}
+		rows, err := model.ChannelEventLogListAfter(channelID, afterSeq, int(upto-afterSeq))
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			if row.Seq > upto {
+				break
+			}
+			collected = append(collected, &eventReplayEntry{
+				seq:       row.Seq,
+				eventType: row.EventType,
+				frame:     []byte(row.Payload),
+				audience:  row.Audience,
+				excluded:  row.Excluded,
+			})
+		}
+	}
+	collected = append(collected, memEntries...)
+
+	expected := afterSeq + 1
+	for _, entry := range collected {
+		if entry.seq != expected {
+			return resyncResult(result, "事件已过期"), nil
+		}
+		expected++
+	}
+	if expected-1 != latest {
+		return resyncResult(result, "事件已过期"), nil
+	}
+
+	for _, entry := range collected {
+		if entry.visibleTo(userID) {
+			result.Frames = append(result.Frames, entry.frame)
+		}
+	}
+	return result, nil
+}
+
+func resyncResult(result *EventReplayResult, reason string) *EventReplayResult {
+	result.Resync = true
+	result.Reason = reason
+	result.Frames = nil
+	return result
+}
+
+func enqueueEventLog(channelID string, entry *eventReplayEntry) {
+	eventLogWriterOnce.Do(func() {
+		eventLogQueue = make(chan *model.ChannelEventLogModel, 4096)
+		go runEventLogWriter()
+	})
+	item := &model.ChannelEventLogModel{
+		ChannelID: channelID,
+		Seq:       entry.seq,
+		EventType: entry.eventType,
+		Payload:   string(entry.frame),
+		Audience:  entry.audience,
+		Excluded:  entry.excluded,
+	}
+	item.Init()
+	select {
+	case eventLogQueue <- item:
+	default:
+		// 队列已满时直接丢弃，回放时会因序号不连续而要求全量刷新
+		log.Printf("[event-replay] 写入队列已满，丢弃频道 %s 的事件 %d", channelID, entry.seq)
+	}
+}
+
+// runEventLogWriter 批量落库，降低高频事件对数据库的压力
+func runEventLogWriter() {
+	const batchSize = 100
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	batch := make([]*model.ChannelEventLogModel, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := model.ChannelEventLogBatchCreate(batch); err != nil {
+			log.Printf("[event-replay] 写入事件日志失败: %v", err)
+		}
+		batch = make([]*model.ChannelEventLogModel, 0, batchSize)
+	}
+	for {
+		select {
+		case item := <-eventLogQueue:
+			batch = append(batch, item)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// StartEventReplayCleanupWorker 定期清理超过保留时长的事件日志
+func StartEventReplayCleanupWorker(interval time.Duration) {
+	eventReplayCleanOnce.Do(func() {
+		if interval <= 0 {
+			interval = 10 * time.Minute
+		}
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				cfg := eventReplayConfig()
+				if cfg.Persist {
+					cutoff := time.Now().Add(-time.Duration(cfg.RetentionMinutes) * time.Minute)
+					if _, err := model.ChannelEventLogPurgeBefore(cutoff); err != nil {
+						log.Printf("[event-replay] 清理事件日志失败: %v", err)
+					}
+				}
+				<-ticker.C
+			}
+		}()
+	})
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"sealchat/model"
+	"sealchat/protocol"
+	"sealchat/utils"
+)
+
+func TestEventReplayCollectFiltersAudience(t *testing.T) {
+	channelID := "replay-" + utils.NewID()
+	public := &protocol.Event{Type: protocol.EventMessageCreated}
+	EventReplayRecord(channelID, public, nil, nil)
+	whisper := &protocol.Event{Type: protocol.EventMessageCreated}
+	EventReplayRecord(channelID, whisper, []string{"alice"}, nil)
+	EventReplayRecord(channelID, &protocol.Event{Type: protocol.EventTypingPreview}, nil, nil)
+	except := &protocol.Event{Type: protocol.EventMessageCreated}
+	EventReplayRecord(channelID, except, nil, []string{"alice"})
+
+	if whisper.Seq != public.Seq+1 || except.Seq != whisper.Seq+1 {
+		t.Fatalf("seq not contiguous: %d %d %d", public.Seq, whisper.Seq, except.Seq)
+	}
+
+	result, err := EventReplayCollect(channelID, public.Seq-1, "alice")
+	if err != nil || result.Resync {
+		t.Fatalf("unexpected result: %+v (%v)", result, err)
+	}
+	if result.Latest != except.Seq || len(result.Frames) != 2 {
+		t.Fatalf("alice should get 2 frames, got %d (latest %d)", len(result.Frames), result.Latest)
+	}
+	var frame struct {
+		Seq int64           `json:"seq"`
+		Op  protocol.Opcode `json:"op"`
+	}
+	if err := json.Unmarshal(result.Frames[1], &frame); err != nil || frame.Seq != whisper.Seq || frame.Op != protocol.OpEvent {
+		t.Fatalf("unexpected frame: %s", result.Frames[1])
+	}
+
+	result, _ = EventReplayCollect(channelID, public.Seq, "bob")
+	if len(result.Frames) != 1 {
+		t.Fatalf("bob should only get the except event, got %d", len(result.Frames))
+	}
+}
+
+func TestEventReplayCollectRequiresResync(t *testing.T) {
+	channelID := "replay-" + utils.NewID()
+	first := &protocol.Event{Type: protocol.EventMessageCreated}
+	EventReplayRecord(channelID, first, nil, nil)
+
+	result, _ := EventReplayCollect(channelID, first.Seq+10, "alice")
+	if !result.Resync {
+		t.Fatalf("future seq should require resync")
+	}
+
+	cfg := eventReplayConfig()
+	for i := 0; i < cfg.MemoryPerChannel+1; i++ {
+		EventReplayRecord(channelID, &protocol.Event{Type: protocol.EventMessageCreated}, nil, nil)
+	}
+	// 内存缓冲已淘汰最早的事件，且未开启持久化
+	result, _ = EventReplayCollect(channelID, first.Seq-1, "alice")
+	if !result.Resync || len(result.Frames) != 0 {
+		t.Fatalf("evicted events should require resync: %+v", result)
+	}
+
+	result, _ = EventReplayCollect(channelID, result.Latest-3, "alice")
+	if result.Resync || len(result.Frames) != 3 {
+		t.Fatalf("recent events should replay: resync=%v frames=%d", result.Resync, len(result.Frames))
+	}
+}
+
+func TestEventReplayIngestMergesOutOfOrder(t *testing.T) {
+	initTestDB(t)
+	channelID := "replay-" + utils.NewID()
+	first, err := model.ChannelEventSeqNext(channelID, 100)
+	if err != nil || first != 101 {
+		t.Fatalf("counter should start after floor: %d (%v)", first, err)
+	}
+	if next, _ := model.ChannelEventSeqNext(channelID, 0); next != 102 {
+		t.Fatalf("counter should increase, got %d", next)
+	}
+
+	// 其他实例的事件晚到，缓冲仍按序号排列
+	base := EventReplayLatestSeq(channelID)
+	EventReplayIngest(channelID, &protocol.Event{Type: protocol.EventMessageCreated, Seq: base + 2}, nil, nil)
+	EventReplayIngest(channelID, &protocol.Event{Type: protocol.EventMessageCreated, Seq: base + 1}, nil, nil)
+	EventReplayIngest(channelID, &protocol.Event{Type: protocol.EventMessageCreated, Seq: base + 1}, nil, nil)
+	result, err := EventReplayCollect(channelID, base, "alice")
+	if err != nil || result.Resync || len(result.Frames) != 2 || result.Latest != base+2 {
+		t.Fatalf("unexpected result: %+v (%v)", result, err)
+	}
+}
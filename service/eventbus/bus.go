@@ -0,0 +1,147 @@
+package eventbus
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+
+	"sealchat/utils"
+)
+
+// 总线上的主题，对应需要跨实例同步的几类推送
+const (
+	TopicBroadcast = "broadcast" // 频道/用户/世界事件推送，包含输入预览
+	TopicPresence  = "presence"  // 各实例本地的频道在线快照
+	TopicCharacter = "character" // BOT 人物卡请求与响应转发
+	TopicCache     = "cache"     // 权限判定、BOT 令牌等进程内缓存的失效
+)
+
+// Message 总线消息。Origin 为发布实例，Target 非空时只投递给指定实例
+type Message struct {
+	Topic  string          `json:"topic"`
+	Origin string          `json:"origin"`
+	Target string          `json:"target,omitempty"`
+	Data   json.RawMessage `json:"data,omitempty"`
+	// Spill 消息落库的记录 ID；负载过大时 Data 为空，需按此 ID 读取
+	Spill string `json:"spill,omitempty"`
+}
+
+type Handler func(msg *Message)
+
+// Bus 实例间的事件总线。发布者自己负责本地投递，总线只把消息送达其他实例
+type Bus interface {
+	InstanceID() string
+	// Distributed 是否可能存在其他实例
+	Distributed() bool
+	Publish(topic, target string, data any) error
+	Subscribe(topic string, handler Handler)
+	Close() error
+}
+
+type dispatcher struct {
+	instanceID string
+	mu         sync.RWMutex
+	handlers   map[string][]Handler
+}
+
+func newDispatcher(instanceID string) dispatcher {
+	instanceID = strings.TrimSpace(instanceID)
+	if instanceID == "" {
+		instanceID = utils.NewID()
+	}
+	return dispatcher{instanceID: instanceID, handlers: map[string][]Handler{}}
+}
+
+func (d *dispatcher) InstanceID() string {
+	return d.instanceID
+}
+
+func (d *dispatcher) Subscribe(topic string, handler Handler) {
+	if handler == nil {
+		return
+	}
+	d.mu.Lock()
+	d.handlers[topic] = append(d.handlers[topic], handler)
+	d.mu.Unlock()
+}
+
+// accepts 忽略自己发出的以及发给其他实例的消息
+func (d *dispatcher) accepts(msg *Message) bool {
+	if msg == nil || msg.Origin == d.instanceID {
+		return false
+	}
+	return msg.Target == "" || msg.Target == d.instanceID
+}
+
+func (d *dispatcher) dispatch(msg *Message) {
+	d.mu.RLock()
+	handlers := d.handlers[msg.Topic]
+	d.mu.RUnlock()
+	for _, handler := range handlers {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("[eventbus] 处理 %s 消息异常: %v", msg.Topic, r)
+				}
+			}()
+			handler(msg)
+		}()
+	}
+}
+
+func (d *dispatcher) newMessage(topic, target string, data any) (*Message, error) {
+	raw, ok := data.(json.RawMessage)
+	if !ok {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		raw = encoded
+	}
+	return &Message{Topic: topic, Origin: d.instanceID, Target: target, Data: raw}, nil
+}
+
+var (
+	defaultBus   Bus
+	defaultBusMu sync.RWMutex
+)
+
+// Init 按配置创建总线并设为默认实例；postgres 驱动连接失败时返回错误
+func Init(cfg utils.EventBusConfig, dsn string) (Bus, error) {
+	var bus Bus
+	switch cfg.Driver {
+	case "postgres":
+		pgBus, err := NewPostgres(dsn, cfg.Channel, cfg.InstanceID)
+		if err != nil {
+			return nil, err
+		}
+		bus = pgBus
+	default:
+		bus = NewLocal(cfg.InstanceID)
+	}
+	defaultBusMu.Lock()
+	old := defaultBus
+	defaultBus = bus
+	defaultBusMu.Unlock()
+	if old != nil {
+		_ = old.Close()
+	}
+	return bus, nil
+}
+
+// Default 返回当前总线，未初始化时退化为单实例总线
+func Default() Bus {
+	defaultBusMu.RLock()
+	bus := defaultBus
+	defaultBusMu.RUnlock()
+	if bus != nil {
+		return bus
+	}
+	defaultBusMu.Lock()
+	defer defaultBusMu.Unlock()
+	if defaultBus == nil {
+		defaultBus = NewLocal("")
+	}
+	return defaultBus
+}
@@ -0,0 +1,61 @@
+package eventbus
+
+import "sync"
+
+// localHub 同一进程内的一组总线，单实例部署时只有一个成员
+type localHub struct {
+	mu      sync.RWMutex
+	members []*localBus
+}
+
+type localBus struct {
+	dispatcher
+	hub *localHub
+}
+
+// NewLocal 创建单实例总线，发布的消息不会离开本进程
+func NewLocal(instanceID string) Bus {
+	return newLocalOnHub(&localHub{}, instanceID)
+}
+
+func newLocalOnHub(hub *localHub, instanceID string) *localBus {
+	bus := &localBus{dispatcher: newDispatcher(instanceID), hub: hub}
+	hub.mu.Lock()
+	hub.members = append(hub.members, bus)
+	hub.mu.Unlock()
+	return bus
+}
+
+func (b *localBus) Distributed() bool {
+	b.hub.mu.RLock()
+	defer b.hub.mu.RUnlock()
+	return len(b.hub.members) > 1
+}
+
+func (b *localBus) Publish(topic, target string, data any) error {
+	msg, err := b.newMessage(topic, target, data)
+	if err != nil {
+		return err
+	}
+	b.hub.mu.RLock()
+	members := append([]*localBus(nil), b.hub.members...)
+	b.hub.mu.RUnlock()
+	for _, member := range members {
+		if member.accepts(msg) {
+			member.dispatch(msg)
+		}
+	}
+	return nil
+}
+
+func (b *localBus) Close() error {
+	b.hub.mu.Lock()
+	defer b.hub.mu.Unlock()
+	for i, member := range b.hub.members {
+		if member == b {
+			b.hub.members = append(b.hub.members[:i], b.hub.members[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
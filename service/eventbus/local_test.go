@@ -0,0 +1,49 @@
+package eventbus
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestLocalHubDeliversToOtherInstances(t *testing.T) {
+	hub := &localHub{}
+	a := newLocalOnHub(hub, "a")
+	b := newLocalOnHub(hub, "b")
+	c := newLocalOnHub(hub, "c")
+	if !a.Distributed() {
+		t.Fatalf("hub with several members should be distributed")
+	}
+
+	received := map[string][]string{}
+	for _, bus := range []*localBus{a, b, c} {
+		name := bus.InstanceID()
+		bus.Subscribe(TopicBroadcast, func(msg *Message) {
+			var text string
+			_ = json.Unmarshal(msg.Data, &text)
+			received[name] = append(received[name], msg.Origin+":"+text)
+		})
+	}
+
+	if err := a.Publish(TopicBroadcast, "", "hello"); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+	if err := a.Publish(TopicBroadcast, "c", "only-c"); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+
+	if len(received["a"]) != 0 {
+		t.Fatalf("publisher should not receive its own message: %v", received["a"])
+	}
+	if len(received["b"]) != 1 || received["b"][0] != "a:hello" {
+		t.Fatalf("unexpected messages for b: %v", received["b"])
+	}
+	if len(received["c"]) != 2 || received["c"][1] != "a:only-c" {
+		t.Fatalf("unexpected messages for c: %v", received["c"])
+	}
+
+	_ = b.Close()
+	_ = c.Close()
+	if a.Distributed() {
+		t.Fatalf("single member hub should not be distributed")
+	}
+}
@@ -0,0 +1,236 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"sealchat/model"
+)
+
+const (
+	// NOTIFY 负载上限为 8000 字节，预留余量给消息外层字段
+	postgresNotifyMaxPayload = 7000
+	postgresReconnectDelay   = 2 * time.Second
+	postgresSpillRetention   = 5 * time.Minute
+	// 补收时向前多取一段，容忍实例间的时钟偏差
+	postgresCatchUpMargin = 30 * time.Second
+	postgresCatchUpLimit  = 5000
+)
+
+// postgresBus 借助共享 PostgreSQL 的 LISTEN/NOTIFY 在实例间同步消息。
+// 监听使用独立连接，发布走主库连接池；每条消息同时落库，
+// 监听断开重连后从库中补收断开期间的消息，已处理过的按 ID 去重
+type postgresBus struct {
+	dispatcher
+	dsn     string
+	channel string
+	ctx     context.Context
+	cancel  context.CancelFunc
+	// seen 记录近期已处理的消息 ID，仅在 run 协程中访问
+	seen map[string]time.Time
+}
+
+func NewPostgres(dsn, channel, instanceID string) (Bus, error) {
+	if !strings.HasPrefix(dsn, "postgres://") && !strings.HasPrefix(dsn, "postgresql://") {
+		return nil, errors.New("postgres 事件总线要求主数据库为 PostgreSQL")
+	}
+	channel = strings.TrimSpace(channel)
+	if channel == "" {
+		channel = "sealchat_events"
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	bus := &postgresBus{
+		dispatcher: newDispatcher(instanceID),
+		dsn:        dsn,
+		channel:    channel,
+		ctx:        ctx,
+		cancel:     cancel,
+		seen:       map[string]time.Time{},
+	}
+	conn, err := bus.listen()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	go bus.run(conn)
+	go bus.purgeSpills()
+	return bus, nil
+}
+
+func (b *postgresBus) Distributed() bool {
+	return true
+}
+
+func (b *postgresBus) Publish(topic, target string, data any) error {
+	msg, err := b.newMessage(topic, target, data)
+	if err != nil {
+		return err
+	}
+	stored, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	spillID, err := model.EventBusSpillCreate(string(stored))
+	if err != nil {
+		return err
+	}
+	msg.Spill = spillID
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if len(payload) > postgresNotifyMaxPayload {
+		msg.Data = nil
+		if payload, err = json.Marshal(msg); err != nil {
+			return err
+		}
+	}
+	return model.GetDB().Exec("SELECT pg_notify(?, ?)", b.channel, string(payload)).Error
+}
+
+func (b *postgresBus) Close() error {
+	b.cancel()
+	return nil
+}
+
+func (b *postgresBus) listen() (*pgx.Conn, error) {
+	conn, err := pgx.Connect(b.ctx, b.dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Exec(b.ctx, "LISTEN "+pgx.Identifier{b.channel}.Sanitize()); err != nil {
+		_ = conn.Close(context.Background())
+		return nil, err
+	}
+	return conn, nil
+}
+
+// run 按到达顺序串行处理通知，保证同一频道事件的先后次序
+func (b *postgresBus) run(conn *pgx.Conn) {
+	for {
+		notification, err := conn.WaitForNotification(b.ctx)
+		if err != nil {
+			lostAt := time.Now()
+			_ = conn.Close(context.Background())
+			if b.ctx.Err() != nil {
+				return
+			}
+			log.Printf("[eventbus] 监听连接断开，准备重连: %v", err)
+			conn = b.reconnect()
+			if conn == nil {
+				return
+			}
+			// 先恢复 LISTEN 再补收，补收期间到达的通知留在连接中稍后处理并去重
+			b.catchUp(lostAt.Add(-postgresCatchUpMargin))
+			continue
+		}
+		b.handleNotification(notification.Payload)
+	}
+}
+
+// catchUp 补收监听断开期间写入的消息
+func (b *postgresBus) catchUp(since time.Time) {
+	items, err := model.EventBusSpillListSince(since, postgresCatchUpLimit)
+	if err != nil {
+		log.Printf("[eventbus] 补收断线期间的消息失败: %v", err)
+		return
+	}
+	count := 0
+	for _, item := range items {
+		if b.markSeen(item.ID) {
+			continue
+		}
+		var msg Message
+		if err := json.Unmarshal([]byte(item.Payload), &msg); err != nil || !b.accepts(&msg) {
+			continue
+		}
+		msg.Spill = item.ID
+		b.dispatch(&msg)
+		count++
+	}
+	if count > 0 {
+		log.Printf("[eventbus] 已补收断线期间的 %d 条消息", count)
+	}
+}
+
+// markSeen 记录消息已处理，返回此前是否已处理过
+func (b *postgresBus) markSeen(id string) bool {
+	if id == "" {
+		return false
+	}
+	if _, ok := b.seen[id]; ok {
+		return true
+	}
+	now := time.Now()
+	b.seen[id] = now
+	if len(b.seen)%1024 == 0 {
+		for key, at := range b.seen {
+			if now.Sub(at) > postgresSpillRetention {
+				delete(b.seen, key)
+			}
+		}
+	}
+	return false
+}
+
+func (b *postgresBus) reconnect() *pgx.Conn {
+	for {
+		select {
+		case <-b.ctx.Done():
+			return nil
+		case <-time.After(postgresReconnectDelay):
+		}
+		conn, err := b.listen()
+		if err == nil {
+			log.Printf("[eventbus] 监听连接已恢复")
+			return conn
+		}
+		log.Printf("[eventbus] 重连失败: %v", err)
+	}
+}
+
+func (b *postgresBus) handleNotification(payload string) {
+	var msg Message
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		log.Printf("[eventbus] 解析通知失败: %v", err)
+		return
+	}
+	if !b.accepts(&msg) || b.markSeen(msg.Spill) {
+		return
+	}
+	if msg.Spill != "" && msg.Data == nil {
+		data, err := model.EventBusSpillGet(msg.Spill)
+		if err != nil || data == "" {
+			log.Printf("[eventbus] 读取暂存消息 %s 失败: %v", msg.Spill, err)
+			return
+		}
+		var stored Message
+		if err := json.Unmarshal([]byte(data), &stored); err != nil {
+			log.Printf("[eventbus] 解析暂存消息 %s 失败: %v", msg.Spill, err)
+			return
+		}
+		msg.Data = stored.Data
+	}
+	b.dispatch(&msg)
+}
+
+func (b *postgresBus) purgeSpills() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := model.EventBusSpillPurgeBefore(time.Now().Add(-postgresSpillRetention)); err != nil {
+				log.Printf("[eventbus] 清理暂存消息失败: %v", err)
+			}
+		}
+	}
+}
@@ -48,6 +48,17 @@ type ExportMessage struct {
 	Content        string    `json:"content"`
 	ContentHTML    string    `json:"content_html,omitempty"` // HTML 渲染结果，用于 HTML 导出
 	WhisperTargets []string  `json:"whisper_targets"`
+	GMRollMasked   bool      `json:"gm_roll_masked,omitempty"`
+	// 仅在导出时勾选包含编辑历史才填充，按版本从旧到新排列
+	EditHistory []ExportEditRevision `json:"edit_history,omitempty"`
+}
+
+type ExportEditRevision struct {
+	Revision   int    `json:"revision"`
+	Content    string `json:"content"`
+	EditorID   string `json:"editor_id"`
+	EditorName string `json:"editor_name,omitempty"`
+	Time       int64  `json:"time"`
 }
 
 type ExportPayload struct {
@@ -82,15 +93,16 @@ type diceLogPayload struct {
 }
 
 type diceLogItem struct {
-	Nickname    string           `json:"nickname"`
-	ImUserID    string           `json:"imUserId"`
-	UniformID   string           `json:"uniformId"`
-	Time        int64            `json:"time"`
-	Message     string           `json:"message"`
-	IsDice      bool             `json:"isDice"`
-	CommandID   string           `json:"commandId"`
-	CommandInfo *diceCommandInfo `json:"commandInfo"`
-	RawMsgID    string           `json:"rawMsgId"`
+	Nickname    string               `json:"nickname"`
+	ImUserID    string               `json:"imUserId"`
+	UniformID   string               `json:"uniformId"`
+	Time        int64                `json:"time"`
+	Message     string               `json:"message"`
+	IsDice      bool                 `json:"isDice"`
+	CommandID   string               `json:"commandId"`
+	CommandInfo *diceCommandInfo     `json:"commandInfo"`
+	RawMsgID    string               `json:"rawMsgId"`
+	EditHistory []ExportEditRevision `json:"editHistory,omitempty"`
 }
 
 type diceCommandInfo struct {
@@ -133,6 +145,7 @@ func buildExportPayload(job *model.MessageExportJobModel, channelName string, me
 			Content:        originalContent,
 			ContentHTML:    htmlContent,
 			WhisperTargets: extractWhisperTargets(msg, job.ChannelID, identityResolver),
+			GMRollMasked:   msg.GMRollMasked,
 		})
 	}
 
@@ -159,6 +172,89 @@ func buildExportPayload(job *model.MessageExportJobModel, channelName string, me
 	}
 }
 
+// attachExportEditHistory 为被编辑过的消息附上历史版本（不含当前版本）
+func attachExportEditHistory(payload *ExportPayload) error {
+	if payload == nil || len(payload.Messages) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(payload.Messages))
+	for _, msg := range payload.Messages {
+		// 打码的 GM 暗骰不附带编辑历史，否则旧版本会泄露原文
+		if !msg.GMRollMasked {
+			ids = append(ids, msg.ID)
+		}
+	}
+	var histories []model.MessageEditHistoryModel
+	for start := 0; start < len(ids); start += 500 {
+		end := min(start+500, len(ids))
+		var batch []model.MessageEditHistoryModel
+		if err := model.GetDB().Where("message_id IN ?", ids[start:end]).Order("created_at asc").Find(&batch).Error; err != nil {
+			return fmt.Errorf("读取编辑历史失败: %w", err)
+		}
+		histories = append(histories, batch...)
+	}
+	if len(histories) == 0 {
+		return nil
+	}
+
+	grouped := map[string][]model.MessageEditHistoryModel{}
+	for _, h := range histories {
+		grouped[h.MessageID] = append(grouped[h.MessageID], h)
+	}
+	for i := range payload.Messages {
+		msg := &payload.Messages[i]
+		items := grouped[msg.ID]
+		if len(items) == 0 {
+			continue
+		}
+		// 历史记录保存的是编辑前内容：版本 0 由发送者写下，之后每个版本由上一次编辑的执行人产生
+		editorID := msg.SenderID
+		editTime := msg.CreatedAt
+		revisions := make([]ExportEditRevision, 0, len(items))
+		for idx, h := range items {
+			revisions = append(revisions, ExportEditRevision{
+				Revision: idx,
+				Content:  stripRichText(h.PrevContent),
+				EditorID: editorID,
+				Time:     safeUnix(editTime),
+			})
+			editorID = h.EditorID
+			editTime = h.CreatedAt
+		}
+		msg.EditHistory = revisions
+	}
+	fillExportEditorNames(payload)
+	return nil
+}
+
+func fillExportEditorNames(payload *ExportPayload) {
+	userIDs := map[string]struct{}{}
+	for _, msg := range payload.Messages {
+		for _, rev := range msg.EditHistory {
+			userIDs[rev.EditorID] = struct{}{}
+		}
+	}
+	if len(userIDs) == 0 {
+		return
+	}
+	ids := make([]string, 0, len(userIDs))
+	for id := range userIDs {
+		ids = append(ids, id)
+	}
+	var users []*model.UserModel
+	model.GetDB().Where("id IN ?", ids).Select("id, nickname, username").Find(&users)
+	names := make(map[string]string, len(users))
+	for _, u := range users {
+		names[u.ID] = resolveUserDisplayName(u)
+	}
+	for i := range payload.Messages {
+		for j := range payload.Messages[i].EditHistory {
+			rev := &payload.Messages[i].EditHistory[j]
+			rev.EditorName = names[rev.EditorID]
+		}
+	}
+}
+
 func resolvePayloadGeneratedAt(ctx *payloadContext) time.Time {
 	if ctx != nil && ctx.GeneratedAt != nil {
 		return ctx.GeneratedAt.UTC()
@@ -1658,6 +1754,7 @@ func buildDiceLogPayload(payload *ExportPayload) *diceLogPayload {
 			CommandID:   msg.ID,
 			CommandInfo: info,
 			RawMsgID:    msg.ID,
+			EditHistory: msg.EditHistory,
 		})
 	}
 	return &diceLogPayload{Version: diceLogVersion, Items: items}
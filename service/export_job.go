@@ -51,6 +51,7 @@ type ExportJobOptions struct {
 	SliceLimit         int
 	MaxConcurrency     int
 	TextColorizeBBCode bool
+	IncludeEditHistory bool
 }
 
 type exportExtraOptions struct {
@@ -58,6 +59,7 @@ type exportExtraOptions struct {
 	SliceLimit         int            `json:"slice_limit,omitempty"`
 	MaxConcurrency     int            `json:"max_concurrency,omitempty"`
 	TextColorizeBBCode bool           `json:"text_colorize_bbcode,omitempty"`
+	IncludeEditHistory bool           `json:"include_edit_history,omitempty"`
 }
 
 func normalizeExportFormat(format string) (string, bool) {
@@ -150,6 +152,10 @@ func loadMessagesForExport(job *model.MessageExportJobModel) ([]*model.MessageMo
 	if err := query.Find(&messages).Error; err != nil {
 		return nil, err
 	}
+	// 导出内容与发起人在频道中看到的一致，GM 暗骰按其身份打码
+	if channel, _ := model.ChannelGet(job.ChannelID); channel != nil {
+		GMRollMaskForViewer(job.UserID, GMRollIsViewer(job.UserID, channel), messages)
+	}
 	if job.MergeMessages {
 		return mergeSequentialMessages(messages), nil
 	}
@@ -254,6 +260,7 @@ func buildExportExtraOptions(opts *ExportJobOptions) (string, error) {
 		SliceLimit:         opts.SliceLimit,
 		MaxConcurrency:     opts.MaxConcurrency,
 		TextColorizeBBCode: opts.TextColorizeBBCode,
+		IncludeEditHistory: opts.IncludeEditHistory,
 	}
 	if len(opts.DisplaySettings) > 0 {
 		extra.DisplaySettings = opts.DisplaySettings
@@ -455,6 +462,7 @@ func RetryMessageExportJob(job *model.MessageExportJobModel) (*model.MessageExpo
 	opts.DisplaySettings = extra.DisplaySettings
 	opts.SliceLimit = extra.SliceLimit
 	opts.MaxConcurrency = extra.MaxConcurrency
+	opts.IncludeEditHistory = extra.IncludeEditHistory
 	return CreateMessageExportJob(opts)
 }
 
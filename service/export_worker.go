@@ -127,6 +127,12 @@ func processExportJob(job *model.MessageExportJobModel, cfg MessageExportWorkerC
 		}
 		payload.ExtraMeta["text_colorize_bbcode"] = true
 	}
+	if extraOptions != nil && extraOptions.IncludeEditHistory {
+		if err := attachExportEditHistory(payload); err != nil {
+			_ = markJobFailed(job, err)
+			return err
+		}
+	}
 
 	formatter, ok := getFormatter(job.Format)
 	if !ok {
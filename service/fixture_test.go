@@ -0,0 +1,65 @@
+package service
+
+import (
+	"testing"
+
+	"sealchat/model"
+)
+
+// testWorld 测试用世界。World 至少填写 ID，名称与状态缺省时分别取 ID 与 active；
+// OwnerID 非空时写入拥有者的成员记录
+type testWorld struct {
+	World    model.WorldModel
+	Members  []string // 以普通成员身份加入
+	Users    []string // 只创建用户，不加入世界
+	Channels []model.ChannelModel
+}
+
+// seedTestWorld 创建世界、拥有者与成员的成员记录、相关用户以及挂在该世界下的频道；
+// 频道未指定状态时视为正常，未指定名称时使用 ID
+func seedTestWorld(tb testing.TB, w testWorld) {
+	tb.Helper()
+	initTestDB(tb)
+	db := model.GetDB()
+	world := w.World
+	if world.Name == "" {
+		world.Name = world.ID
+	}
+	if world.Status == "" {
+		world.Status = "active"
+	}
+	if err := db.Create(&world).Error; err != nil {
+		tb.Fatalf("create world failed: %v", err)
+	}
+	var members []model.WorldMemberModel
+	if world.OwnerID != "" {
+		members = append(members, model.WorldMemberModel{WorldID: world.ID, UserID: world.OwnerID, Role: model.WorldRoleOwner})
+	}
+	for _, id := range w.Members {
+		members = append(members, model.WorldMemberModel{WorldID: world.ID, UserID: id, Role: model.WorldRoleMember})
+	}
+	users := append([]string{}, w.Users...)
+	for _, m := range members {
+		if err := db.Create(&m).Error; err != nil {
+			tb.Fatalf("create member failed: %v", err)
+		}
+		users = append(users, m.UserID)
+	}
+	for _, id := range users {
+		if err := db.Create(&model.UserModel{StringPKBaseModel: model.StringPKBaseModel{ID: id}, Username: id, Nickname: id}).Error; err != nil {
+			tb.Fatalf("create user failed: %v", err)
+		}
+	}
+	for _, ch := range w.Channels {
+		ch.WorldID = world.ID
+		if ch.Status == "" {
+			ch.Status = model.ChannelStatusActive
+		}
+		if ch.Name == "" {
+			ch.Name = ch.ID
+		}
+		if err := db.Create(&ch).Error; err != nil {
+			tb.Fatalf("create channel %s failed: %v", ch.ID, err)
+		}
+	}
+}
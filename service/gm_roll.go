@@ -0,0 +1,96 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/samber/lo"
+
+	"sealchat/model"
+)
+
+// GM 暗骰：消息正常落库，但揭示前只有发送者、世界拥有者/管理员与持有频道 GM 角色的成员能看到内容，
+// 其他人看到占位文本
+
+// GMRollIsViewer 用户是否为频道的 GM（不含发送者本人的判断）
+func GMRollIsViewer(userID string, channel *model.ChannelModel) bool {
+	if userID == "" || channel == nil || channel.ID == "" {
+		return false
+	}
+	if channel.WorldID != "" && IsWorldAdmin(channel.WorldID, userID) {
+		return true
+	}
+	if len(channel.GMRoleIDs) == 0 {
+		return false
+	}
+	roleIDs, err := model.UserRoleMappingListByUserIDCached(userID, channel.ID, "channel")
+	if err != nil {
+		return false
+	}
+	for _, id := range roleIDs {
+		if lo.Contains(channel.GMRoleIDs, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// GMRollViewerIDs 能看到频道内 GM 暗骰内容的全部用户（不含发送者）
+func GMRollViewerIDs(channel *model.ChannelModel) []string {
+	if channel == nil || channel.ID == "" {
+		return nil
+	}
+	var ids []string
+	if channel.WorldID != "" {
+		adminIDs, _ := listWorldUserIDsByRoles(channel.WorldID, model.WorldRoleOwner, model.WorldRoleAdmin)
+		ids = append(ids, adminIDs...)
+	}
+	for _, roleID := range channel.GMRoleIDs {
+		userIDs, _ := model.UserRoleMappingUserIdListByRoleId(roleID)
+		ids = append(ids, userIDs...)
+	}
+	return lo.Uniq(ids)
+}
+
+// GMRollPlaceholder 非 GM 看到的占位文本
+func GMRollPlaceholder(senderName string) string {
+	senderName = strings.TrimSpace(senderName)
+	if senderName == "" {
+		senderName = "有人"
+	}
+	return senderName + "进行了一次GM暗骰"
+}
+
+func gmRollSenderName(msg *model.MessageModel) string {
+	if msg.SenderIdentityName != "" {
+		return msg.SenderIdentityName
+	}
+	return msg.SenderMemberName
+}
+
+// GMRollMask 将消息替换为占位内容
+func GMRollMask(msg *model.MessageModel) {
+	if msg == nil || !msg.IsGMRoll {
+		return
+	}
+	msg.Content = GMRollPlaceholder(gmRollSenderName(msg))
+	msg.WidgetData = ""
+	msg.GMRollMasked = true
+}
+
+// GMRollMaskForViewer 对不可见的 GM 暗骰打码；isGM 由调用方按频道预先计算
+func GMRollMaskForViewer(userID string, isGM bool, items []*model.MessageModel) {
+	if isGM {
+		return
+	}
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		if item.IsGMRoll && item.UserID != userID {
+			GMRollMask(item)
+		}
+		if item.Quote != nil && item.Quote.IsGMRoll && item.Quote.UserID != userID {
+			GMRollMask(item.Quote)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"testing"
+
+	"sealchat/model"
+)
+
+func TestGMRollMaskForViewer(t *testing.T) {
+	newItems := func() []*model.MessageModel {
+		return []*model.MessageModel{
+			{UserID: "u1", Content: "1d100=42", IsGMRoll: true, SenderMemberName: "木落"},
+			{UserID: "u2", Content: "普通消息", Quote: &model.MessageModel{UserID: "u1", Content: "1d20=7", IsGMRoll: true}},
+		}
+	}
+
+	items := newItems()
+	GMRollMaskForViewer("u2", false, items)
+	if items[0].Content != "木落进行了一次GM暗骰" || !items[0].GMRollMasked {
+		t.Fatalf("gm roll should be masked: %+v", items[0])
+	}
+	if items[1].Content != "普通消息" || !items[1].Quote.GMRollMasked {
+		t.Fatalf("quoted gm roll should be masked: %+v", items[1])
+	}
+
+	items = newItems()
+	GMRollMaskForViewer("u1", false, items)
+	if items[0].GMRollMasked || items[1].Quote.GMRollMasked {
+		t.Fatalf("sender should see own gm roll")
+	}
+
+	items = newItems()
+	GMRollMaskForViewer("u3", true, items)
+	if items[0].GMRollMasked {
+		t.Fatalf("gm should see gm roll")
+	}
+}
@@ -0,0 +1,653 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+
+	"sealchat/model"
+	"sealchat/utils"
+)
+
+const (
+	messageBulkMaxMessages = 20000
+	messageBulkBatchSize   = 200
+)
+
+var (
+	ErrMessageBulkNoFilter      = errors.New("请至少指定一个筛选条件")
+	ErrMessageBulkTooMany       = fmt.Errorf("单次批量操作最多处理 %d 条消息，请缩小筛选范围", messageBulkMaxMessages)
+	ErrMessageBulkBusy          = errors.New("该频道已有批量操作正在执行")
+	ErrMessageBulkUnsupported   = errors.New("不支持的批量操作")
+	ErrMessageBulkEmptySelected = errors.New("没有符合条件的消息")
+)
+
+// MessageBulkFilter 批量操作的筛选条件，各条件之间为“且”的关系
+type MessageBulkFilter struct {
+	MessageIDs      []string `json:"message_ids,omitempty"`
+	StartTime       *int64   `json:"start_time,omitempty"` // 毫秒时间戳
+	EndTime         *int64   `json:"end_time,omitempty"`
+	OrderFrom       *float64 `json:"order_from,omitempty"`
+	OrderTo         *float64 `json:"order_to,omitempty"`
+	UserIDs         []string `json:"user_ids,omitempty"`
+	IdentityIDs     []string `json:"identity_ids,omitempty"`
+	ICMode          string   `json:"ic_mode,omitempty"`
+	Keyword         string   `json:"keyword,omitempty"`
+	IncludeArchived bool     `json:"include_archived,omitempty"`
+	IncludeWhispers bool     `json:"include_whispers,omitempty"`
+}
+
+func (f *MessageBulkFilter) empty() bool {
+	return len(f.MessageIDs) == 0 && f.StartTime == nil && f.EndTime == nil &&
+		f.OrderFrom == nil && f.OrderTo == nil && len(f.UserIDs) == 0 &&
+		len(f.IdentityIDs) == 0 && f.ICMode == "" && strings.TrimSpace(f.Keyword) == ""
+}
+
+type MessageBulkParams struct {
+	Reason          string `json:"reason,omitempty"`
+	IdentityID      string `json:"identity_id,omitempty"`
+	ICMode          string `json:"ic_mode,omitempty"`
+	TargetChannelID string `json:"target_channel_id,omitempty"`
+}
+
+type MessageBulkRequest struct {
+	Channel    *model.ChannelModel
+	OperatorID string
+	Action     string
+	Filter     MessageBulkFilter
+	Params     MessageBulkParams
+	// IsProtectedAuthor 返回 true 的作者（如其他管理员）的消息不会被处理
+	IsProtectedAuthor func(userID string) bool
+
+	identity       *model.ChannelIdentityModel
+	target         *model.ChannelModel
+	excludeUserIDs []string
+	// 移动时源频道成员/角色身份到目标频道的映射，跨批次复用
+	moveMemberMap   map[string]string
+	moveIdentityMap map[string]*model.ChannelIdentityModel
+}
+
+type MessageBulkPreviewResult struct {
+	Matched int `json:"matched"`
+	Skipped int `json:"skipped"`
+}
+
+// MessageBulkHooks 由调用方负责推送进度与最终结果
+type MessageBulkHooks struct {
+	OnProgress func(job *model.MessageBulkJobModel)
+	// OnFinish 按频道汇总实际处理的消息，移动操作时目标频道也会出现在结果中
+	OnFinish func(job *model.MessageBulkJobModel, applied map[string][]string)
+}
+
+func messageBulkValidate(req *MessageBulkRequest) error {
+	if req == nil || req.Channel == nil || req.Channel.ID == "" {
+		return errors.New("频道不存在")
+	}
+	req.Filter.ICMode = strings.ToLower(strings.TrimSpace(req.Filter.ICMode))
+	if req.Filter.ICMode != "" && req.Filter.ICMode != "ic" && req.Filter.ICMode != "ooc" {
+		return errors.New("ic_mode 仅支持 ic/ooc")
+	}
+	req.Filter.MessageIDs = lo.Uniq(lo.Filter(req.Filter.MessageIDs, func(id string, _ int) bool {
+		return strings.TrimSpace(id) != ""
+	}))
+	if req.Filter.empty() {
+		return ErrMessageBulkNoFilter
+	}
+
+	switch req.Action {
+	case model.MessageBulkActionArchive, model.MessageBulkActionUnarchive, model.MessageBulkActionDelete:
+	case model.MessageBulkActionSetICMode:
+		mode := strings.ToLower(strings.TrimSpace(req.Params.ICMode))
+		if mode != "ic" && mode != "ooc" {
+			return errors.New("请指定目标 ic_mode（ic/ooc）")
+		}
+		req.Params.ICMode = mode
+	case model.MessageBulkActionReassignIdentity:
+		identityID := strings.TrimSpace(req.Params.IdentityID)
+		if identityID == "" {
+			return errors.New("请指定要改用的角色身份")
+		}
+		identity, err := model.ChannelIdentityGetByID(identityID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if identity == nil || identity.ChannelID != req.Channel.ID {
+			return errors.New("角色身份不存在或不属于该频道")
+		}
+		req.identity = identity
+	case model.MessageBulkActionMove:
+		targetID := strings.TrimSpace(req.Params.TargetChannelID)
+		if targetID == "" || targetID == req.Channel.ID {
+			return errors.New("请选择其他目标频道")
+		}
+		target, err := model.ChannelGet(targetID)
+		if err != nil {
+			return err
+		}
+		if target.ID == "" {
+			return errors.New("目标频道不存在")
+		}
+		if req.Channel.WorldID == "" || target.WorldID != req.Channel.WorldID {
+			return errors.New("只能移动到同一世界内的频道")
+		}
+		req.target = target
+	default:
+		return ErrMessageBulkUnsupported
+	}
+	return nil
+}
+
+// messageBulkQuery 构造筛选查询；excludeUserIDs 用于跳过受保护作者
+func messageBulkQuery(req *MessageBulkRequest, withExclusions bool) *gorm.DB {
+	f := &req.Filter
+	q := model.GetDB().Model(&model.MessageModel{}).
+		Where("channel_id = ?", req.Channel.ID).
+		Where("is_revoked = ? AND is_deleted = ?", false, false)
+
+	if len(f.MessageIDs) > 0 {
+		q = q.Where("id IN ?", f.MessageIDs)
+	}
+	if f.StartTime != nil {
+		q = q.Where("created_at >= ?", time.UnixMilli(*f.StartTime))
+	}
+	if f.EndTime != nil {
+		q = q.Where("created_at <= ?", time.UnixMilli(*f.EndTime))
+	}
+	if f.OrderFrom != nil {
+		q = q.Where("display_order >= ?", *f.OrderFrom)
+	}
+	if f.OrderTo != nil {
+		q = q.Where("display_order <= ?", *f.OrderTo)
+	}
+	if len(f.UserIDs) > 0 {
+		q = q.Where("user_id IN ?", f.UserIDs)
+	}
+	if len(f.IdentityIDs) > 0 {
+		q = q.Where("sender_identity_id IN ?", f.IdentityIDs)
+	}
+	switch f.ICMode {
+	case "ic":
+		q = q.Where("(ic_mode = ? OR ic_mode = '' OR ic_mode IS NULL)", "ic")
+	case "ooc":
+		q = q.Where("ic_mode = ?", "ooc")
+	}
+	for _, token := range strings.Fields(strings.ToLower(f.Keyword)) {
+		q = q.Where("LOWER(content) LIKE ? ESCAPE '"+utils.LikeEscape+"'", "%"+utils.EscapeLike(token)+"%")
+	}
+	if !f.IncludeWhispers {
+		q = q.Where("is_whisper = ?", false)
+	}
+
+	switch req.Action {
+	case model.MessageBulkActionArchive:
+		q = q.Where("is_archived = ?", false)
+	case model.MessageBulkActionUnarchive:
+		q = q.Where("is_archived = ?", true)
+	default:
+		if !f.IncludeArchived {
+			q = q.Where("is_archived = ?", false)
+		}
+	}
+	switch req.Action {
+	case model.MessageBulkActionSetICMode:
+		if req.Params.ICMode == "ic" {
+			q = q.Where("ic_mode = ?", "ooc")
+		} else {
+			q = q.Where("(ic_mode <> ? OR ic_mode IS NULL)", "ooc")
+		}
+	case model.MessageBulkActionReassignIdentity:
+		// 角色身份属于具体用户，只能改写该用户自己的发言
+		q = q.Where("user_id = ? AND (sender_identity_id <> ? OR sender_identity_id IS NULL)", req.identity.UserID, req.identity.ID)
+	}
+
+	if withExclusions && len(req.excludeUserIDs) > 0 {
+		q = q.Where("user_id NOT IN ?", req.excludeUserIDs)
+	}
+	return q
+}
+
+// messageBulkResolveExclusions 找出筛选结果中受保护的作者，并返回被跳过的消息数
+func messageBulkResolveExclusions(req *MessageBulkRequest) (int, error) {
+	req.excludeUserIDs = nil
+	if req.IsProtectedAuthor == nil {
+		return 0, nil
+	}
+	var authors []string
+	if err := messageBulkQuery(req, false).Distinct("user_id").Pluck("user_id", &authors).Error; err != nil {
+		return 0, err
+	}
+	for _, userID := range authors {
+		if userID != "" && userID != req.OperatorID && req.IsProtectedAuthor(userID) {
+			req.excludeUserIDs = append(req.excludeUserIDs, userID)
+		}
+	}
+	if len(req.excludeUserIDs) == 0 {
+		return 0, nil
+	}
+	var skipped int64
+	err := messageBulkQuery(req, false).Where("user_id IN ?", req.excludeUserIDs).Count(&skipped).Error
+	return int(skipped), err
+}
+
+// MessageBulkPreview 试运行：只统计会被处理和被跳过的消息数
+func MessageBulkPreview(req *MessageBulkRequest) (*MessageBulkPreviewResult, error) {
+	if err := messageBulkValidate(req); err != nil {
+		return nil, err
+	}
+	skipped, err := messageBulkResolveExclusions(req)
+	if err != nil {
+		return nil, err
+	}
+	var matched int64
+	if err := messageBulkQuery(req, true).Count(&matched).Error; err != nil {
+		return nil, err
+	}
+	return &MessageBulkPreviewResult{Matched: int(matched), Skipped: skipped}, nil
+}
+
+// MessageBulkStart 锁定筛选结果并在后台分批执行，返回的任务可用于查询进度
+func MessageBulkStart(req *MessageBulkRequest, hooks MessageBulkHooks) (*model.MessageBulkJobModel, error) {
+	if err := messageBulkValidate(req); err != nil {
+		return nil, err
+	}
+	skipped, err := messageBulkResolveExclusions(req)
+	if err != nil {
+		return nil, err
+	}
+	// 先固定消息列表，避免执行过程中筛选结果随更新而变化
+	var ids []string
+	if err := messageBulkQuery(req, true).
+		Order("display_order asc").Order("id asc").
+		Limit(messageBulkMaxMessages+1).
+		Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) > messageBulkMaxMessages {
+		return nil, ErrMessageBulkTooMany
+	}
+	if len(ids) == 0 {
+		return nil, ErrMessageBulkEmptySelected
+	}
+
+	job := &model.MessageBulkJobModel{
+		WorldID:    req.Channel.WorldID,
+		ChannelID:  req.Channel.ID,
+		OperatorID: req.OperatorID,
+		Action:     req.Action,
+		Filter:     encodeMessageBulkJSON(req.Filter),
+		Params:     encodeMessageBulkJSON(req.Params),
+		Total:      len(ids),
+		Skipped:    skipped,
+		Status:     model.MessageBulkStatusRunning,
+	}
+	lockChannels := []string{req.Channel.ID}
+	if req.target != nil {
+		job.TargetChannelID = req.target.ID
+		lockChannels = append(lockChannels, req.target.ID)
+	}
+	job.ID = utils.NewID()
+	acquired, err := model.MessageBulkJobCreateLocked(job, lockChannels)
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		return nil, ErrMessageBulkBusy
+	}
+
+	snapshot := *job
+	go runMessageBulkJob(req, &snapshot, ids, hooks)
+	return job, nil
+}
+
+func runMessageBulkJob(req *MessageBulkRequest, job *model.MessageBulkJobModel, ids []string, hooks MessageBulkHooks) {
+	applied := map[string][]string{}
+	var runErr error
+	var moved []string
+	for start := 0; start < len(ids); start += messageBulkBatchSize {
+		batch := ids[start:min(start+messageBulkBatchSize, len(ids))]
+		if runErr = applyMessageBulkBatch(req, batch); runErr != nil {
+			break
+		}
+		if req.target != nil {
+			moved = append(moved, batch...)
+		}
+		applied[req.Channel.ID] = append(applied[req.Channel.ID], batch...)
+		if req.target != nil {
+			applied[req.target.ID] = append(applied[req.target.ID], batch...)
+		}
+		job.Processed += len(batch)
+		_ = model.GetDB().Model(&model.MessageBulkJobModel{}).Where("id = ?", job.ID).
+			Update("processed", job.Processed).Error
+		if hooks.OnProgress != nil && job.Processed < job.Total {
+			hooks.OnProgress(job)
+		}
+	}
+
+	// 引用关系要等全部批次移动完才能判断是否跨频道
+	if len(moved) > 0 {
+		if err := messageBulkDetachMovedQuotes(req, moved); err != nil && runErr == nil {
+			runErr = err
+		}
+	}
+
+	now := time.Now()
+	job.FinishedAt = &now
+	job.Status = model.MessageBulkStatusDone
+	if runErr != nil {
+		log.Printf("[message-bulk] 任务 %s 执行失败: %v", job.ID, runErr)
+		job.Status = model.MessageBulkStatusFailed
+		job.ErrorMsg = runErr.Error()
+	}
+	_ = model.GetDB().Model(&model.MessageBulkJobModel{}).Where("id = ?", job.ID).Updates(map[string]any{
+		"status":      job.Status,
+		"error_msg":   job.ErrorMsg,
+		"processed":   job.Processed,
+		"finished_at": now,
+	}).Error
+	if err := model.MessageBulkJobReleaseLocks(job.ID); err != nil {
+		log.Printf("[message-bulk] 释放任务 %s 的频道占用失败: %v", job.ID, err)
+	}
+	if hooks.OnFinish != nil {
+		hooks.OnFinish(job, applied)
+	}
+}
+
+func applyMessageBulkBatch(req *MessageBulkRequest, ids []string) error {
+	now := time.Now()
+	var orders map[string]float64
+	if req.Action == model.MessageBulkActionMove {
+		if err := messageBulkPrepareMove(req, ids); err != nil {
+			return err
+		}
+		var err error
+		if orders, err = messageBulkMoveDisplayOrders(req, ids); err != nil {
+			return err
+		}
+	}
+	err := model.GetDB().Transaction(func(tx *gorm.DB) error {
+		messages := tx.Model(&model.MessageModel{}).Where("channel_id = ? AND id IN ?", req.Channel.ID, ids)
+		switch req.Action {
+		case model.MessageBulkActionArchive, model.MessageBulkActionUnarchive:
+			archived := req.Action == model.MessageBulkActionArchive
+			reason := strings.TrimSpace(req.Params.Reason)
+			updates := map[string]any{"is_archived": archived}
+			if archived {
+				updates["archived_at"] = now
+				updates["archived_by"] = req.OperatorID
+				updates["archive_reason"] = reason
+			} else {
+				updates["archived_at"] = gorm.Expr("NULL")
+				updates["archived_by"] = ""
+				updates["archive_reason"] = ""
+			}
+			if err := messages.Updates(updates).Error; err != nil {
+				return err
+			}
+			payload, _ := json.Marshal(map[string]any{
+				"reason":    reason,
+				"archived":  archived,
+				"operator":  req.OperatorID,
+				"timestamp": now.UnixMilli(),
+				"bulk":      true,
+			})
+			logs := make([]*model.MessageArchiveLogModel, 0, len(ids))
+			for _, id := range ids {
+				logs = append(logs, &model.MessageArchiveLogModel{
+					MessageID:   id,
+					ChannelID:   req.Channel.ID,
+					OperatorID:  req.OperatorID,
+					Action:      req.Action,
+					PayloadJSON: string(payload),
+				})
+			}
+			return tx.Create(&logs).Error
+		case model.MessageBulkActionDelete:
+			return messages.Updates(map[string]any{
+				"is_deleted": true,
+				"deleted_at": now,
+				"deleted_by": req.OperatorID,
+				"content":    "",
+			}).Error
+		case model.MessageBulkActionSetICMode:
+			return messages.Update("ic_mode", req.Params.ICMode).Error
+		case model.MessageBulkActionReassignIdentity:
+			identity := req.identity
+			return messages.Updates(map[string]any{
+				"sender_identity_id":        identity.ID,
+				"sender_identity_name":      identity.DisplayName,
+				"sender_identity_color":     identity.Color,
+				"sender_identity_avatar_id": identity.AvatarAttachmentID,
+				"sender_member_name":        identity.DisplayName,
+				"sender_role_id":            identity.ID,
+			}).Error
+		case model.MessageBulkActionMove:
+			targetID := req.target.ID
+			if err := messages.Update("channel_id", targetID).Error; err != nil {
+				return err
+			}
+			if err := messageBulkRemapMoved(tx, req, ids, orders); err != nil {
+				return err
+			}
+			if err := tx.Model(&model.MessageEditHistoryModel{}).Where("message_id IN ?", ids).
+				Update("channel_id", targetID).Error; err != nil {
+				return err
+			}
+			return tx.Model(&model.MessageExternalRefModel{}).Where("message_id IN ?", ids).
+				Update("channel_id", targetID).Error
+		}
+		return ErrMessageBulkUnsupported
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		switch req.Action {
+		case model.MessageBulkActionDelete:
+			_ = model.WebhookEventLogAppendForMessage(req.Channel.ID, "message-removed", id)
+		case model.MessageBulkActionSetICMode, model.MessageBulkActionReassignIdentity:
+			_ = model.WebhookEventLogAppendForMessage(req.Channel.ID, "message-updated", id)
+		case model.MessageBulkActionMove:
+			_ = model.WebhookEventLogAppendForMessage(req.Channel.ID, "message-removed", id)
+			_ = model.WebhookEventLogAppendForMessage(req.target.ID, "message-created", id)
+		}
+	}
+	return nil
+}
+
+// messageBulkPrepareMove 在事务外准备目标频道中的成员与角色身份：成员只复用已有记录，角色身份缺失时按原样复制一份
+func messageBulkPrepareMove(req *MessageBulkRequest, ids []string) error {
+	if req.moveMemberMap == nil {
+		req.moveMemberMap = map[string]string{}
+		req.moveIdentityMap = map[string]*model.ChannelIdentityModel{}
+	}
+	db := model.GetDB()
+	var rows []model.MessageModel
+	if err := db.Select("id, member_id, sender_identity_id, whisper_sender_member_id, whisper_target_member_id").
+		Where("id IN ?", ids).Find(&rows).Error; err != nil {
+		return err
+	}
+
+	var memberIDs, identityIDs []string
+	for _, row := range rows {
+		for _, id := range []string{row.MemberID, row.WhisperSenderMemberID, row.WhisperTargetMemberID} {
+			if _, ok := req.moveMemberMap[id]; id != "" && !ok {
+				memberIDs = append(memberIDs, id)
+			}
+		}
+		if _, ok := req.moveIdentityMap[row.SenderIdentityID]; row.SenderIdentityID != "" && !ok {
+			identityIDs = append(identityIDs, row.SenderIdentityID)
+		}
+	}
+
+	if len(memberIDs) > 0 {
+		memberIDs = lo.Uniq(memberIDs)
+		var members []model.MemberModel
+		if err := db.Where("id IN ?", memberIDs).Find(&members).Error; err != nil {
+			return err
+		}
+		for _, id := range memberIDs {
+			req.moveMemberMap[id] = ""
+		}
+		for _, member := range members {
+			target, err := model.MemberGetByUserIDAndChannelIDBase(member.UserID, req.target.ID, member.Nickname, false)
+			if err != nil {
+				return err
+			}
+			if target != nil {
+				req.moveMemberMap[member.ID] = target.ID
+			}
+		}
+	}
+
+	if len(identityIDs) > 0 {
+		identityIDs = lo.Uniq(identityIDs)
+		var identities []*model.ChannelIdentityModel
+		if err := db.Where("id IN ?", identityIDs).Find(&identities).Error; err != nil {
+			return err
+		}
+		for _, id := range identityIDs {
+			req.moveIdentityMap[id] = nil
+		}
+		for _, identity := range identities {
+			var existing model.ChannelIdentityModel
+			if err := db.Where("channel_id = ? AND user_id = ? AND display_name = ?", req.target.ID, identity.UserID, identity.DisplayName).
+				Order("sort_order asc").Limit(1).Find(&existing).Error; err != nil {
+				return err
+			}
+			if existing.ID == "" {
+				existing = model.ChannelIdentityModel{
+					ChannelID:          req.target.ID,
+					UserID:             identity.UserID,
+					DisplayName:        identity.DisplayName,
+					Color:              identity.Color,
+					AvatarAttachmentID: identity.AvatarAttachmentID,
+					CharacterCardID:    identity.CharacterCardID,
+					IsHidden:           identity.IsHidden,
+					SortOrder:          identity.SortOrder,
+				}
+				existing.ID = utils.NewID()
+				if err := db.Create(&existing).Error; err != nil {
+					return err
+				}
+			}
+			req.moveIdentityMap[identity.ID] = &existing
+		}
+	}
+	return nil
+}
+
+// messageBulkMoveDisplayOrders 保留原有排序值以便按时间穿插；与目标频道已有消息撞值时微调，避免翻页游标歧义
+func messageBulkMoveDisplayOrders(req *MessageBulkRequest, ids []string) (map[string]float64, error) {
+	db := model.GetDB()
+	var rows []model.MessageModel
+	if err := db.Select("id, display_order").Where("id IN ?", ids).Order("display_order asc").Order("id asc").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	values := lo.Uniq(lo.Map(rows, func(row model.MessageModel, _ int) float64 { return row.DisplayOrder }))
+	var existing []float64
+	if err := db.Model(&model.MessageModel{}).Where("channel_id = ? AND display_order IN ?", req.target.ID, values).
+		Pluck("display_order", &existing).Error; err != nil {
+		return nil, err
+	}
+	taken := map[float64]struct{}{}
+	for _, v := range existing {
+		taken[v] = struct{}{}
+	}
+	orders := map[string]float64{}
+	for _, row := range rows {
+		order := row.DisplayOrder
+		for step := 1; ; step++ {
+			if _, ok := taken[order]; !ok {
+				// 原值已随 IN 查询核对过，只有微调后的新值需要再查一次
+				if order == row.DisplayOrder {
+					break
+				}
+				var count int64
+				if err := db.Model(&model.MessageModel{}).Where("channel_id = ? AND display_order = ?", req.target.ID, order).
+					Count(&count).Error; err != nil {
+					return nil, err
+				}
+				if count == 0 {
+					break
+				}
+			}
+			order = row.DisplayOrder + float64(step)*messageBulkOrderNudge
+		}
+		taken[order] = struct{}{}
+		if order != row.DisplayOrder {
+			orders[row.ID] = order
+		}
+	}
+	return orders, nil
+}
+
+// 撞值时的微调步长，远小于正常消息间的间隔
+const messageBulkOrderNudge = 0.001
+
+// messageBulkRemapMoved 把已移动消息的成员、角色身份与排序值改写为目标频道中的对应值
+func messageBulkRemapMoved(tx *gorm.DB, req *MessageBulkRequest, ids []string, orders map[string]float64) error {
+	for sourceID, targetID := range req.moveMemberMap {
+		for _, column := range []string{"member_id", "whisper_sender_member_id", "whisper_target_member_id"} {
+			if err := tx.Model(&model.MessageModel{}).Where("id IN ? AND "+column+" = ?", ids, sourceID).
+				Update(column, targetID).Error; err != nil {
+				return err
+			}
+		}
+	}
+	for sourceID, identity := range req.moveIdentityMap {
+		updates := map[string]any{"sender_identity_id": "", "sender_role_id": ""}
+		if identity != nil {
+			updates = map[string]any{"sender_identity_id": identity.ID, "sender_role_id": identity.ID}
+		}
+		// 名称、颜色、头像是发言时的快照，保持不变
+		if err := tx.Model(&model.MessageModel{}).Where("id IN ? AND sender_identity_id = ?", ids, sourceID).
+			Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	for id, order := range orders {
+		if err := tx.Model(&model.MessageModel{}).Where("id = ?", id).UpdateColumn("display_order", order).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// messageBulkDetachMovedQuotes 清除跨频道的引用：移动后的消息引用了留在原处的消息，或其他频道的消息引用了被移走的消息
+func messageBulkDetachMovedQuotes(req *MessageBulkRequest, moved []string) error {
+	db := model.GetDB()
+	for start := 0; start < len(moved); start += messageBulkBatchSize {
+		batch := moved[start:min(start+messageBulkBatchSize, len(moved))]
+		if err := db.Model(&model.MessageModel{}).
+			Where("id IN ? AND quote_id <> '' AND quote_id NOT IN (?)", batch,
+				db.Model(&model.MessageModel{}).Select("id").Where("channel_id = ?", req.target.ID)).
+			Update("quote_id", "").Error; err != nil {
+			return err
+		}
+		if err := db.Model(&model.MessageModel{}).
+			Where("channel_id <> ? AND quote_id IN ?", req.target.ID, batch).
+			Update("quote_id", "").Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeMessageBulkJSON(v any) model.JSONMap {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var result model.JSONMap
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil
+	}
+	return result
+}
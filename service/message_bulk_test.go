@@ -0,0 +1,200 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"sealchat/model"
+	"sealchat/utils"
+)
+
+func seedBulkChannel(t *testing.T) (*model.ChannelModel, []*model.MessageModel) {
+	t.Helper()
+	initTestDB(t)
+	channel := &model.ChannelModel{Name: "bulk", WorldID: "world-bulk"}
+	channel.ID = utils.NewID()
+	if err := model.GetDB().Create(channel).Error; err != nil {
+		t.Fatalf("create channel failed: %v", err)
+	}
+	base := time.Now().Add(-time.Hour)
+	specs := []struct {
+		user   string
+		icMode string
+	}{
+		{"player", "ic"}, {"player", "ooc"}, {"gm-other", "ic"}, {"player", "ic"},
+	}
+	var messages []*model.MessageModel
+	for i, spec := range specs {
+		msg := &model.MessageModel{
+			ChannelID:    channel.ID,
+			UserID:       spec.user,
+			Content:      "line",
+			ICMode:       spec.icMode,
+			DisplayOrder: float64(i + 1),
+		}
+		msg.ID = utils.NewID()
+		msg.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		if err := model.GetDB().Create(msg).Error; err != nil {
+			t.Fatalf("create message failed: %v", err)
+		}
+		messages = append(messages, msg)
+	}
+	return channel, messages
+}
+
+func TestMessageBulkPreviewSkipsProtectedAuthors(t *testing.T) {
+	channel, _ := seedBulkChannel(t)
+	from, to := 1.0, 3.0
+	req := &MessageBulkRequest{
+		Channel:           channel,
+		OperatorID:        "gm",
+		Action:            model.MessageBulkActionArchive,
+		Filter:            MessageBulkFilter{OrderFrom: &from, OrderTo: &to},
+		IsProtectedAuthor: func(userID string) bool { return userID == "gm-other" },
+	}
+	result, err := MessageBulkPreview(req)
+	if err != nil {
+		t.Fatalf("preview failed: %v", err)
+	}
+	if result.Matched != 2 || result.Skipped != 1 {
+		t.Fatalf("unexpected preview: %+v", result)
+	}
+
+	// 关键词中的通配符按字面匹配
+	if result, err := MessageBulkPreview(&MessageBulkRequest{Channel: channel, Action: model.MessageBulkActionArchive,
+		Filter: MessageBulkFilter{Keyword: "l_ne %"}}); err != nil || result.Matched != 0 {
+		t.Fatalf("wildcards should be escaped: %+v (%v)", result, err)
+	}
+
+	if _, err := MessageBulkPreview(&MessageBulkRequest{Channel: channel, Action: model.MessageBulkActionArchive}); !errors.Is(err, ErrMessageBulkNoFilter) {
+		t.Fatalf("expected no filter error, got %v", err)
+	}
+}
+
+func TestMessageBulkStartSetsICMode(t *testing.T) {
+	channel, messages := seedBulkChannel(t)
+	req := &MessageBulkRequest{
+		Channel:    channel,
+		OperatorID: "gm",
+		Action:     model.MessageBulkActionSetICMode,
+		Filter:     MessageBulkFilter{UserIDs: []string{"player"}},
+		Params:     MessageBulkParams{ICMode: "ooc"},
+	}
+	done := make(chan map[string][]string, 1)
+	job, err := MessageBulkStart(req, MessageBulkHooks{
+		OnFinish: func(job *model.MessageBulkJobModel, applied map[string][]string) {
+			done <- applied
+		},
+	})
+	if err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	// 已经是 OOC 的那条不计入
+	if job.Total != 2 {
+		t.Fatalf("total = %d, want 2", job.Total)
+	}
+	var applied map[string][]string
+	select {
+	case applied = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("job did not finish")
+	}
+	if len(applied[channel.ID]) != 2 {
+		t.Fatalf("unexpected applied ids: %v", applied)
+	}
+
+	stored, err := model.MessageBulkJobGet(job.ID)
+	if err != nil || stored == nil || stored.Status != model.MessageBulkStatusDone || stored.Processed != 2 {
+		t.Fatalf("unexpected job state: %+v (%v)", stored, err)
+	}
+	var modes []string
+	model.GetDB().Model(&model.MessageModel{}).Where("id IN ?", []string{messages[0].ID, messages[3].ID}).Pluck("ic_mode", &modes)
+	for _, mode := range modes {
+		if mode != "ooc" {
+			t.Fatalf("message not switched to ooc: %v", modes)
+		}
+	}
+}
+
+func TestMessageBulkMoveRemapsIdentitiesAndQuotes(t *testing.T) {
+	channel, messages := seedBulkChannel(t)
+	db := model.GetDB()
+	target := &model.ChannelModel{Name: "bulk-target", WorldID: channel.WorldID}
+	target.ID = utils.NewID()
+	if err := db.Create(target).Error; err != nil {
+		t.Fatalf("create target failed: %v", err)
+	}
+	identity := &model.ChannelIdentityModel{ChannelID: channel.ID, UserID: "player", DisplayName: "骑士"}
+	identity.ID = utils.NewID()
+	if err := db.Create(identity).Error; err != nil {
+		t.Fatalf("create identity failed: %v", err)
+	}
+	db.Model(&model.MessageModel{}).Where("id = ?", messages[0].ID).
+		Updates(map[string]any{"sender_identity_id": identity.ID, "sender_role_id": identity.ID})
+	db.Model(&model.MessageModel{}).Where("id = ?", messages[3].ID).Update("quote_id", messages[2].ID)
+	db.Model(&model.MessageModel{}).Where("id = ?", messages[2].ID).Update("quote_id", messages[0].ID)
+	occupied := &model.MessageModel{ChannelID: target.ID, UserID: "gm", Content: "existing", DisplayOrder: messages[0].DisplayOrder}
+	occupied.ID = utils.NewID()
+	if err := db.Create(occupied).Error; err != nil {
+		t.Fatalf("create target message failed: %v", err)
+	}
+
+	newReq := func() *MessageBulkRequest {
+		return &MessageBulkRequest{
+			Channel:    channel,
+			OperatorID: "gm",
+			Action:     model.MessageBulkActionMove,
+			Filter:     MessageBulkFilter{UserIDs: []string{"player"}, IncludeArchived: true},
+			Params:     MessageBulkParams{TargetChannelID: target.ID},
+		}
+	}
+	if err := db.Create(&model.MessageBulkLockModel{ChannelID: target.ID, JobID: "other-job"}).Error; err != nil {
+		t.Fatalf("create lock failed: %v", err)
+	}
+	if _, err := MessageBulkStart(newReq(), MessageBulkHooks{}); !errors.Is(err, ErrMessageBulkBusy) {
+		t.Fatalf("target locked by another job should be busy, got %v", err)
+	}
+	if err := model.MessageBulkJobReleaseLocks("other-job"); err != nil {
+		t.Fatalf("release lock failed: %v", err)
+	}
+
+	done := make(chan struct{}, 1)
+	if _, err := MessageBulkStart(newReq(), MessageBulkHooks{
+		OnFinish: func(*model.MessageBulkJobModel, map[string][]string) { done <- struct{}{} },
+	}); err != nil {
+		t.Fatalf("start move failed: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("move did not finish")
+	}
+
+	var moved model.MessageModel
+	db.Where("id = ?", messages[0].ID).Limit(1).Find(&moved)
+	if moved.ChannelID != target.ID || moved.SenderIdentityID == "" || moved.SenderIdentityID == identity.ID {
+		t.Fatalf("identity should point at a target-channel copy: %+v", moved)
+	}
+	if copied, err := model.ChannelIdentityGetByID(moved.SenderIdentityID); err != nil || copied.ChannelID != target.ID || copied.DisplayName != "骑士" {
+		t.Fatalf("unexpected identity copy: %+v (%v)", copied, err)
+	}
+	if moved.SenderRoleID != moved.SenderIdentityID {
+		t.Fatalf("role should follow the identity: %+v", moved)
+	}
+	if moved.DisplayOrder == occupied.DisplayOrder {
+		t.Fatalf("display order should not collide with existing target message")
+	}
+	var quotes []string
+	db.Model(&model.MessageModel{}).Where("id IN ?", []string{messages[2].ID, messages[3].ID}).Pluck("quote_id", &quotes)
+	for _, q := range quotes {
+		if q != "" {
+			t.Fatalf("cross-channel quotes should be detached: %v", quotes)
+		}
+	}
+	var locks int64
+	db.Model(&model.MessageBulkLockModel{}).Count(&locks)
+	if locks != 0 {
+		t.Fatalf("locks should be released after the job, got %d", locks)
+	}
+}
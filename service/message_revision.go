@@ -0,0 +1,366 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"html"
+	"strings"
+	"time"
+	"unicode"
+
+	htmlnode "golang.org/x/net/html"
+
+	"sealchat/model"
+)
+
+var ErrMessageRevisionNotFound = errors.New("指定的历史版本不存在")
+
+// 超过该规模时不再做 LCS，直接整体替换，避免超长消息拖慢请求
+const messageDiffMaxCells = 1 << 20
+
+const (
+	MessageDiffEqual  = "equal"
+	MessageDiffInsert = "insert"
+	MessageDiffDelete = "delete"
+	MessageDiffModify = "modify"
+)
+
+// MessageRevision 消息的某个版本；0 为原始内容，最后一个为当前内容
+type MessageRevision struct {
+	Revision  int       `json:"revision"`
+	Content   string    `json:"content"`
+	EditorID  string    `json:"editor_id"`
+	CreatedAt time.Time `json:"created_at"`
+	IsCurrent bool      `json:"is_current"`
+}
+
+type MessageDiffSegment struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// MessageDiffBlock 段落级差异；modify 时附带行内差异
+type MessageDiffBlock struct {
+	Op         string               `json:"op"`
+	Old        string               `json:"old,omitempty"`
+	New        string               `json:"new,omitempty"`
+	FormatOnly bool                 `json:"format_only,omitempty"`
+	Segments   []MessageDiffSegment `json:"segments,omitempty"`
+}
+
+type MessageContentDiff struct {
+	Blocks   []MessageDiffBlock `json:"blocks"`
+	Added    int                `json:"added"`
+	Removed  int                `json:"removed"`
+	Modified int                `json:"modified"`
+}
+
+// ListMessageRevisions 由编辑历史还原出消息的全部版本
+// 每条历史记录保存的是编辑前的内容，因此版本 i 的作者是第 i 次编辑的执行人
+func ListMessageRevisions(msg *model.MessageModel) ([]MessageRevision, error) {
+	if msg == nil {
+		return nil, ErrMessageRevisionNotFound
+	}
+	var histories []model.MessageEditHistoryModel
+	if err := model.GetDB().Where("message_id = ?", msg.ID).Order("created_at asc").Find(&histories).Error; err != nil {
+		return nil, err
+	}
+	revisions := make([]MessageRevision, 0, len(histories)+1)
+	editorID := msg.UserID
+	createdAt := msg.CreatedAt
+	for i, h := range histories {
+		revisions = append(revisions, MessageRevision{
+			Revision:  i,
+			Content:   h.PrevContent,
+			EditorID:  editorID,
+			CreatedAt: createdAt,
+		})
+		editorID = h.EditorID
+		createdAt = h.CreatedAt
+	}
+	revisions = append(revisions, MessageRevision{
+		Revision:  len(histories),
+		Content:   msg.Content,
+		EditorID:  editorID,
+		CreatedAt: createdAt,
+		IsCurrent: true,
+	})
+	return revisions, nil
+}
+
+// PickMessageRevision 按序号取版本，nil 或负数表示当前版本
+func PickMessageRevision(revisions []MessageRevision, revision *int) (*MessageRevision, error) {
+	if len(revisions) == 0 {
+		return nil, ErrMessageRevisionNotFound
+	}
+	if revision == nil || *revision < 0 {
+		return &revisions[len(revisions)-1], nil
+	}
+	if *revision >= len(revisions) {
+		return nil, ErrMessageRevisionNotFound
+	}
+	return &revisions[*revision], nil
+}
+
+type messageBlock struct {
+	Text   string
+	Markup string
+}
+
+// DiffMessageContent 比较两段 TipTap/HTML/纯文本内容，先按段落对齐，再对改动段落做行内比较
+func DiffMessageContent(oldContent, newContent string) *MessageContentDiff {
+	oldBlocks := splitMessageBlocks(oldContent)
+	newBlocks := splitMessageBlocks(newContent)
+	ops := lcsDiff(len(oldBlocks), len(newBlocks), func(i, j int) bool {
+		return oldBlocks[i].Markup == newBlocks[j].Markup
+	})
+
+	result := &MessageContentDiff{Blocks: []MessageDiffBlock{}}
+	var pendingDel, pendingIns []messageBlock
+	flush := func() {
+		paired := min(len(pendingDel), len(pendingIns))
+		for k := 0; k < paired; k++ {
+			oldBlock, newBlock := pendingDel[k], pendingIns[k]
+			block := MessageDiffBlock{Op: MessageDiffModify, Old: oldBlock.Text, New: newBlock.Text}
+			if oldBlock.Text == newBlock.Text {
+				block.FormatOnly = true
+			} else {
+				block.Segments = diffInlineText(oldBlock.Text, newBlock.Text)
+			}
+			result.Blocks = append(result.Blocks, block)
+			result.Modified++
+		}
+		for _, b := range pendingDel[paired:] {
+			result.Blocks = append(result.Blocks, MessageDiffBlock{Op: MessageDiffDelete, Old: b.Text})
+			result.Removed++
+		}
+		for _, b := range pendingIns[paired:] {
+			result.Blocks = append(result.Blocks, MessageDiffBlock{Op: MessageDiffInsert, New: b.Text})
+			result.Added++
+		}
+		pendingDel, pendingIns = nil, nil
+	}
+	for _, op := range ops {
+		switch op.kind {
+		case MessageDiffEqual:
+			flush()
+			text := oldBlocks[op.oldIndex].Text
+			result.Blocks = append(result.Blocks, MessageDiffBlock{Op: MessageDiffEqual, Old: text, New: text})
+		case MessageDiffDelete:
+			pendingDel = append(pendingDel, oldBlocks[op.oldIndex])
+		case MessageDiffInsert:
+			pendingIns = append(pendingIns, newBlocks[op.newIndex])
+		}
+	}
+	flush()
+	return result
+}
+
+// splitMessageBlocks 把内容拆成段落；Markup 保留格式信息，用于识别仅修改样式的段落
+func splitMessageBlocks(content string) []messageBlock {
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return nil
+	}
+	if LooksLikeTipTapJSON(trimmed) {
+		var doc tiptapNode
+		if err := json.Unmarshal([]byte(trimmed), &doc); err == nil {
+			var blocks []messageBlock
+			collectTipTapBlocks(&doc, &blocks)
+			return blocks
+		}
+	}
+	if isLikelyHTMLContent(trimmed) {
+		return splitHTMLBlocks(trimmed)
+	}
+	var blocks []messageBlock
+	for _, line := range strings.Split(normalizePlainText(trimmed), "\n") {
+		blocks = append(blocks, messageBlock{Text: line, Markup: line})
+	}
+	return blocks
+}
+
+func collectTipTapBlocks(node *tiptapNode, out *[]messageBlock) {
+	if node == nil {
+		return
+	}
+	nodeType := strings.ToLower(strings.TrimSpace(node.Type))
+	hasBlockChild := false
+	for _, child := range node.Content {
+		if child != nil && isTipTapBlockNode(strings.ToLower(child.Type)) {
+			hasBlockChild = true
+			break
+		}
+	}
+	if nodeType == "doc" || hasBlockChild {
+		for _, child := range node.Content {
+			collectTipTapBlocks(child, out)
+		}
+		return
+	}
+	w := newPlainTextWriter()
+	writeTipTapNode(w, node)
+	var markup strings.Builder
+	renderTipTapHTML(&markup, node)
+	*out = append(*out, messageBlock{
+		Text:   normalizePlainText(w.String()),
+		Markup: markup.String(),
+	})
+}
+
+func splitHTMLBlocks(input string) []messageBlock {
+	tokenizer := htmlnode.NewTokenizer(strings.NewReader(convertAtTagsToMention(input)))
+	var blocks []messageBlock
+	var text, markup strings.Builder
+	emit := func() {
+		if strings.TrimSpace(markup.String()) != "" {
+			blocks = append(blocks, messageBlock{
+				Text:   normalizePlainText(text.String()),
+				Markup: strings.TrimSpace(markup.String()),
+			})
+		}
+		text.Reset()
+		markup.Reset()
+	}
+	for {
+		tt := tokenizer.Next()
+		if tt == htmlnode.ErrorToken {
+			emit()
+			return blocks
+		}
+		raw := string(tokenizer.Raw())
+		switch tt {
+		case htmlnode.TextToken:
+			text.WriteString(html.UnescapeString(raw))
+			markup.WriteString(raw)
+		case htmlnode.StartTagToken, htmlnode.EndTagToken, htmlnode.SelfClosingTagToken:
+			name, _ := tokenizer.TagName()
+			tag := strings.ToLower(string(name))
+			if shouldInsertLineBreak(tag) {
+				emit()
+				continue
+			}
+			if tag == "img" {
+				attrs := readTagAttributes(tokenizer)
+				text.WriteString(buildCQImageMarkup(firstNonEmptyAttr(attrs, "src", "data-src", "data-original")))
+			}
+			markup.WriteString(raw)
+		}
+	}
+}
+
+// tokenizeDiffText 英文单词和数字按词切分，其余字符（含中文）逐字切分
+func tokenizeDiffText(s string) []string {
+	var tokens []string
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		j := i + 1
+		switch {
+		case isDiffWordRune(runes[i]):
+			for j < len(runes) && isDiffWordRune(runes[j]) {
+				j++
+			}
+		case unicode.IsSpace(runes[i]):
+			for j < len(runes) && unicode.IsSpace(runes[j]) {
+				j++
+			}
+		}
+		tokens = append(tokens, string(runes[i:j]))
+		i = j
+	}
+	return tokens
+}
+
+func isDiffWordRune(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_')
+}
+
+func diffInlineText(oldText, newText string) []MessageDiffSegment {
+	oldTokens := tokenizeDiffText(oldText)
+	newTokens := tokenizeDiffText(newText)
+	ops := lcsDiff(len(oldTokens), len(newTokens), func(i, j int) bool {
+		return oldTokens[i] == newTokens[j]
+	})
+	var segments []MessageDiffSegment
+	for _, op := range ops {
+		var token string
+		if op.kind == MessageDiffInsert {
+			token = newTokens[op.newIndex]
+		} else {
+			token = oldTokens[op.oldIndex]
+		}
+		if n := len(segments); n > 0 && segments[n-1].Op == op.kind {
+			segments[n-1].Text += token
+			continue
+		}
+		segments = append(segments, MessageDiffSegment{Op: op.kind, Text: token})
+	}
+	return segments
+}
+
+type diffOp struct {
+	kind     string
+	oldIndex int
+	newIndex int
+}
+
+// lcsDiff 基于最长公共子序列生成编辑脚本，删除总是排在同位置的插入之前
+func lcsDiff(n, m int, equal func(i, j int) bool) []diffOp {
+	prefix := 0
+	for prefix < n && prefix < m && equal(prefix, prefix) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < n-prefix && suffix < m-prefix && equal(n-1-suffix, m-1-suffix) {
+		suffix++
+	}
+	ops := make([]diffOp, 0, n+m)
+	for k := 0; k < prefix; k++ {
+		ops = append(ops, diffOp{kind: MessageDiffEqual, oldIndex: k, newIndex: k})
+	}
+
+	a, b := n-prefix-suffix, m-prefix-suffix
+	if a*b > messageDiffMaxCells {
+		for k := 0; k < a; k++ {
+			ops = append(ops, diffOp{kind: MessageDiffDelete, oldIndex: prefix + k})
+		}
+		for k := 0; k < b; k++ {
+			ops = append(ops, diffOp{kind: MessageDiffInsert, newIndex: prefix + k})
+		}
+	} else {
+		// table[i][j] 为 old[i:] 与 new[j:] 的公共子序列长度
+		table := make([][]int, a+1)
+		for i := range table {
+			table[i] = make([]int, b+1)
+		}
+		for i := a - 1; i >= 0; i-- {
+			for j := b - 1; j >= 0; j-- {
+				if equal(prefix+i, prefix+j) {
+					table[i][j] = table[i+1][j+1] + 1
+				} else {
+					table[i][j] = max(table[i+1][j], table[i][j+1])
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < a || j < b {
+			switch {
+			case i < a && j < b && equal(prefix+i, prefix+j):
+				ops = append(ops, diffOp{kind: MessageDiffEqual, oldIndex: prefix + i, newIndex: prefix + j})
+				i++
+				j++
+			case i < a && (j == b || table[i+1][j] >= table[i][j+1]):
+				ops = append(ops, diffOp{kind: MessageDiffDelete, oldIndex: prefix + i})
+				i++
+			default:
+				ops = append(ops, diffOp{kind: MessageDiffInsert, newIndex: prefix + j})
+				j++
+			}
+		}
+	}
+
+	for k := suffix; k > 0; k-- {
+		ops = append(ops, diffOp{kind: MessageDiffEqual, oldIndex: n - k, newIndex: m - k})
+	}
+	return ops
+}
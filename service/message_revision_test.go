@@ -0,0 +1,85 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"sealchat/model"
+)
+
+func TestDiffMessageContentTipTapBlocks(t *testing.T) {
+	oldDoc := `{"type":"doc","content":[` +
+		`{"type":"paragraph","content":[{"type":"text","text":"第一段保持不变"}]},` +
+		`{"type":"paragraph","content":[{"type":"text","text":"the quick fox"}]},` +
+		`{"type":"paragraph","content":[{"type":"text","text":"将被删除"}]}]}`
+	newDoc := `{"type":"doc","content":[` +
+		`{"type":"paragraph","content":[{"type":"text","text":"第一段保持不变"}]},` +
+		`{"type":"paragraph","content":[{"type":"text","text":"the slow fox"}]}]}`
+
+	diff := DiffMessageContent(oldDoc, newDoc)
+	if diff.Modified != 1 || diff.Removed != 1 || diff.Added != 0 {
+		t.Fatalf("unexpected stats: %+v", diff)
+	}
+	if len(diff.Blocks) != 3 || diff.Blocks[0].Op != MessageDiffEqual || diff.Blocks[1].Op != MessageDiffModify {
+		t.Fatalf("unexpected blocks: %+v", diff.Blocks)
+	}
+	want := []MessageDiffSegment{
+		{Op: MessageDiffEqual, Text: "the "},
+		{Op: MessageDiffDelete, Text: "quick"},
+		{Op: MessageDiffInsert, Text: "slow"},
+		{Op: MessageDiffEqual, Text: " fox"},
+	}
+	got := diff.Blocks[1].Segments
+	if len(got) != len(want) {
+		t.Fatalf("segments = %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("segment %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDiffMessageContentFormatOnly(t *testing.T) {
+	diff := DiffMessageContent("<p>重要提示</p>", "<p><strong>重要提示</strong></p>")
+	if len(diff.Blocks) != 1 || diff.Blocks[0].Op != MessageDiffModify || !diff.Blocks[0].FormatOnly {
+		t.Fatalf("expected a format-only change, got %+v", diff.Blocks)
+	}
+}
+
+func TestListMessageRevisionsAttributesEditors(t *testing.T) {
+	initTestDB(t)
+	sent := time.Now().Add(-time.Hour)
+	msg := &model.MessageModel{UserID: "author", ChannelID: "ch", Content: "v2"}
+	msg.ID = "msg-1"
+	msg.CreatedAt = sent
+	for i, prev := range []string{"v0", "v1"} {
+		h := &model.MessageEditHistoryModel{MessageID: msg.ID, EditorID: []string{"author", "admin"}[i], PrevContent: prev}
+		h.StringPKBaseModel.Init()
+		h.CreatedAt = sent.Add(time.Duration(i+1) * time.Minute)
+		if err := model.GetDB().Create(h).Error; err != nil {
+			t.Fatalf("create history failed: %v", err)
+		}
+	}
+
+	revisions, err := ListMessageRevisions(msg)
+	if err != nil {
+		t.Fatalf("list revisions failed: %v", err)
+	}
+	if len(revisions) != 3 {
+		t.Fatalf("expected 3 revisions, got %d", len(revisions))
+	}
+	if revisions[0].Content != "v0" || revisions[0].EditorID != "author" {
+		t.Fatalf("unexpected original revision: %+v", revisions[0])
+	}
+	if revisions[2].Content != "v2" || revisions[2].EditorID != "admin" || !revisions[2].IsCurrent {
+		t.Fatalf("unexpected current revision: %+v", revisions[2])
+	}
+	if rev, err := PickMessageRevision(revisions, nil); err != nil || rev.Revision != 2 {
+		t.Fatalf("nil should pick current revision")
+	}
+	outOfRange := 3
+	if _, err := PickMessageRevision(revisions, &outOfRange); err != ErrMessageRevisionNotFound {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sealchat/model"
+	"sealchat/protocol"
+	"sealchat/utils"
+)
+
+// OneBot v11 适配用到的 ID 映射与消息内容转换。
+// 频道对应 group_id，用户对应 user_id，消息对应 message_id，均经 onebot_id_maps 表分配稳定的数字编号
+
+const (
+	OneBotKindUser    = "user"
+	OneBotKindGroup   = "group"
+	OneBotKindMessage = "message"
+)
+
+// 用户与频道数量有限，常驻缓存；消息编号量大，每次查库
+var (
+	oneBotIDCache  utils.SyncMap[string, int64]
+	oneBotRefCache utils.SyncMap[string, string]
+)
+
+func oneBotCacheable(kind string) bool {
+	return kind == OneBotKindUser || kind == OneBotKindGroup
+}
+
+// OneBotID 返回站内 ID 对应的数字编号，失败时返回 0
+func OneBotID(kind, refID string) int64 {
+	refID = strings.TrimSpace(refID)
+	if refID == "" {
+		return 0
+	}
+	key := kind + "|" + refID
+	if id, ok := oneBotIDCache.Load(key); ok {
+		return id
+	}
+	id, err := model.OneBotIDMapGetOrCreate(kind, refID)
+	if err != nil {
+		log.Printf("[OneBot] 分配数字 ID 失败 %s: %v", key, err)
+		return 0
+	}
+	if oneBotCacheable(kind) {
+		oneBotIDCache.Store(key, id)
+		oneBotRefCache.Store(kind+"|"+strconv.FormatInt(id, 10), refID)
+	}
+	return id
+}
+
+// OneBotRefID 由数字编号反查站内 ID，未分配过时返回空串
+func OneBotRefID(kind string, id int64) string {
+	if id <= 0 {
+		return ""
+	}
+	key := kind + "|" + strconv.FormatInt(id, 10)
+	if refID, ok := oneBotRefCache.Load(key); ok {
+		return refID
+	}
+	refID, err := model.OneBotIDMapResolve(kind, id)
+	if err != nil || refID == "" {
+		return ""
+	}
+	if oneBotCacheable(kind) {
+		oneBotRefCache.Store(key, refID)
+		oneBotIDCache.Store(kind+"|"+refID, id)
+	}
+	return refID
+}
+
+var oneBotIDCleanOnce sync.Once
+
+// StartOneBotIDCleanupWorker 定期清理过期的消息编号，用户与频道编号长期保留
+func StartOneBotIDCleanupWorker(interval time.Duration) {
+	oneBotIDCleanOnce.Do(func() {
+		if interval <= 0 {
+			interval = time.Hour
+		}
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				days := 7
+				if cfg := utils.GetConfig(); cfg != nil && cfg.OneBot.MessageIDRetainDays > 0 {
+					days = cfg.OneBot.MessageIDRetainDays
+				}
+				cutoff := time.Now().AddDate(0, 0, -days)
+				if n, err := model.OneBotIDMapPurgeBefore(OneBotKindMessage, cutoff); err != nil {
+					log.Printf("[OneBot] 清理消息编号失败: %v", err)
+				} else if n > 0 {
+					log.Printf("[OneBot] 已清理 %d 条过期消息编号", n)
+				}
+				<-ticker.C
+			}
+		}()
+	})
+}
+
+// OneBotSegment OneBot v11 数组格式的消息段
+type OneBotSegment struct {
+	Type string         `json:"type"`
+	Data map[string]any `json:"data"`
+}
+
+// OneBotSegmentsToElements 将消息段转为 Element，不支持的段类型直接忽略
+func OneBotSegmentsToElements(segments []OneBotSegment) []*protocol.Element {
+	var elements []*protocol.Element
+	for _, seg := range segments {
+		attr := func(key string) string {
+			if seg.Data == nil {
+				return ""
+			}
+			switch v := seg.Data[key].(type) {
+			case string:
+				return v
+			case float64:
+				return strconv.FormatInt(int64(v), 10)
+			}
+			return ""
+		}
+		switch seg.Type {
+		case "text":
+			elements = append(elements, &protocol.Element{Type: "text", Attrs: protocol.Dict{"content": attr("text")}})
+		case "at":
+			elements = append(elements, &protocol.Element{Type: "at", Attrs: protocol.Dict{"id": attr("qq")}})
+		case "image":
+			src := attr("url")
+			if src == "" {
+				src = attr("file")
+			}
+			elements = append(elements, &protocol.Element{Type: "img", Attrs: protocol.Dict{"src": src}})
+		case "reply":
+			elements = append(elements, &protocol.Element{Type: "quote", Attrs: protocol.Dict{"id": attr("id")}})
+		}
+	}
+	return elements
+}
+
+// OneBotParseCQ 在通用 CQ 码解析之上补充 OneBot 特有的 image 与 reply，
+// 二者只在 OneBot 入口转换，webhook 等其他 CQ 码入口仍按原文保留
+func OneBotParseCQ(content string) []*protocol.Element {
+	elements := ParseCQCode(content)
+	for i, el := range elements {
+		if el.Type != "text" {
+			continue
+		}
+		text := getStringAttr(el.Attrs, "content")
+		match := cqCodePattern.FindStringSubmatch(text)
+		if match == nil || match[0] != text {
+			continue
+		}
+		params := parseCQParams(match[2])
+		switch match[1] {
+		case "image":
+			// 优先使用 url，go-cqhttp 等实现中 file 可能只是文件名
+			src := params["url"]
+			if src == "" {
+				src = params["file"]
+			}
+			elements[i] = &protocol.Element{Type: "img", Attrs: protocol.Dict{"src": unescapeCQ(src)}}
+		case "reply":
+			elements[i] = &protocol.Element{Type: "quote", Attrs: protocol.Dict{"id": unescapeCQ(params["id"])}}
+		}
+	}
+	return elements
+}
+
+// oneBotEncodeCQ 在通用 CQ 码编码之上把 img、quote 编码为 OneBot 的 image 与 reply
+func oneBotEncodeCQ(elements []*protocol.Element) string {
+	var sb strings.Builder
+	for _, el := range elements {
+		switch el.Type {
+		case "img", "image":
+			if src := getStringAttr(el.Attrs, "src"); src != "" {
+				sb.WriteString("[CQ:image,file=")
+				sb.WriteString(escapeCQ(src))
+				sb.WriteString("]")
+			}
+		case "quote":
+			if id := getStringAttr(el.Attrs, "id"); id != "" {
+				sb.WriteString("[CQ:reply,id=")
+				sb.WriteString(escapeCQ(id))
+				sb.WriteString("]")
+			}
+		default:
+			sb.WriteString(EncodeCQCode([]*protocol.Element{el}))
+		}
+	}
+	return sb.String()
+}
+
+// OneBotElementsToContent 将 BOT 发来的 Element 转为站内消息内容：
+// at 的数字 qq 换回用户 ID，reply 单独取出作为引用，base64:// 图片转为 data URL 交由附件流程处理。
+// 本地文件路径（file://）不予读取
+func OneBotElementsToContent(elements []*protocol.Element) (content string, quoteID string) {
+	kept := make([]*protocol.Element, 0, len(elements))
+	for _, el := range elements {
+		switch el.Type {
+		case "quote":
+			if id, err := strconv.ParseInt(getStringAttr(el.Attrs, "id"), 10, 64); err == nil {
+				quoteID = OneBotRefID(OneBotKindMessage, id)
+			}
+			continue
+		case "at":
+			id := getStringAttr(el.Attrs, "id")
+			if id != "all" {
+				if num, err := strconv.ParseInt(id, 10, 64); err == nil {
+					if refID := OneBotRefID(OneBotKindUser, num); refID != "" {
+						el.Attrs["id"] = refID
+					}
+				}
+			}
+		case "img":
+			src := getStringAttr(el.Attrs, "src")
+			switch {
+			case strings.HasPrefix(src, "base64://"):
+				el.Attrs["src"] = "data:image/png;base64," + strings.TrimPrefix(src, "base64://")
+			case strings.HasPrefix(src, "http://"), strings.HasPrefix(src, "https://"), strings.HasPrefix(src, "data:"):
+			default:
+				continue
+			}
+		}
+		kept = append(kept, el)
+	}
+	return ElementsToSatoriXML(kept), quoteID
+}
+
+// OneBotContentToCQ 将站内消息内容转为 CQ 码：at 的用户 ID 换成数字编号，附件引用换成可直接下载的地址
+func OneBotContentToCQ(content string) string {
+	if content == "" {
+		return content
+	}
+	root := protocol.ElementParse(content)
+	if root == nil || len(root.Children) == 0 {
+		return content
+	}
+	root.Traverse(func(el *protocol.Element) {
+		switch el.Type {
+		case "at":
+			if id := getStringAttr(el.Attrs, "id"); id != "" && id != "all" {
+				el.Attrs["id"] = strconv.FormatInt(OneBotID(OneBotKindUser, id), 10)
+			}
+		case "img", "image":
+			if src := getStringAttr(el.Attrs, "src"); src != "" {
+				el.Attrs["src"] = SatoriResourceURL(src)
+			}
+		}
+	})
+	return oneBotEncodeCQ(root.Children)
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestOneBotIDStable(t *testing.T) {
+	initTestDB(t)
+
+	userID := OneBotID(OneBotKindUser, "user-onebot-a")
+	if userID <= 0 {
+		t.Fatalf("expected positive id, got %d", userID)
+	}
+	if again := OneBotID(OneBotKindUser, "user-onebot-a"); again != userID {
+		t.Fatalf("id not stable: %d != %d", again, userID)
+	}
+	groupID := OneBotID(OneBotKindGroup, "user-onebot-a")
+	if groupID == userID {
+		t.Fatalf("different kinds should get different ids")
+	}
+	if ref := OneBotRefID(OneBotKindUser, userID); ref != "user-onebot-a" {
+		t.Fatalf("resolve mismatch: %q", ref)
+	}
+	if ref := OneBotRefID(OneBotKindGroup, userID); ref != "" {
+		t.Fatalf("resolving with wrong kind should fail, got %q", ref)
+	}
+}
+
+func TestOneBotContentConversion(t *testing.T) {
+	initTestDB(t)
+
+	num := OneBotID(OneBotKindUser, "user-onebot-b")
+	cq := OneBotContentToCQ(`你好 <at id="user-onebot-b"/>`)
+	if !strings.Contains(cq, "[CQ:at,qq="+strconv.FormatInt(num, 10)+"]") {
+		t.Fatalf("unexpected cq: %q", cq)
+	}
+
+	content, quoteID := OneBotElementsToContent(OneBotParseCQ(cq + "[CQ:image,file=file:///etc/passwd]"))
+	if !strings.Contains(content, `id="user-onebot-b"`) {
+		t.Fatalf("at id not mapped back: %q", content)
+	}
+	if strings.Contains(content, "passwd") {
+		t.Fatalf("local file should be dropped: %q", content)
+	}
+	if quoteID != "" {
+		t.Fatalf("unexpected quote: %q", quoteID)
+	}
+}
+
+func TestOneBotParseCQKeepsSharedCodec(t *testing.T) {
+	raw := "[CQ:reply,id=42]收到[CQ:image,file=abc.image,url=https://example.com/a.png]"
+	elements := OneBotParseCQ(raw)
+	if len(elements) != 3 || elements[0].Type != "quote" || elements[2].Type != "img" {
+		t.Fatalf("unexpected onebot elements: %+v", elements)
+	}
+	if src := getStringAttr(elements[2].Attrs, "src"); src != "https://example.com/a.png" {
+		t.Fatalf("image should prefer url, got %q", src)
+	}
+	if got := oneBotEncodeCQ(elements); got != "[CQ:reply,id=42]收到[CQ:image,file=https://example.com/a.png]" {
+		t.Fatalf("unexpected encoding: %q", got)
+	}
+	// webhook 等通用入口不解释 image 与 reply
+	if got := ConvertCQToSatori(raw); strings.Contains(got, "<img") || strings.Contains(got, "<quote") {
+		t.Fatalf("shared codec should keep unsupported codes as text: %q", got)
+	}
+}
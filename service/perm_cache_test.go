@@ -0,0 +1,122 @@
+package service
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"sealchat/model"
+)
+
+var (
+	queryCounterOnce sync.Once
+	queryCounter     atomic.Int64
+)
+
+// countQueries 统计测试库上执行的 SELECT 次数
+func countQueries(tb testing.TB) {
+	tb.Helper()
+	queryCounterOnce.Do(func() {
+		err := model.GetDB().Callback().Query().After("gorm:query").
+			Register("test:count_queries", func(*gorm.DB) { queryCounter.Add(1) })
+		if err != nil {
+			tb.Fatalf("register query callback failed: %v", err)
+		}
+	})
+}
+
+func seedPermCacheFixture(tb testing.TB, worldID, userID string) {
+	tb.Helper()
+	db := model.GetDB()
+	// 基准测试会以不同的 b.N 多次进入，已准备过则跳过
+	var count int64
+	db.Model(&model.WorldModel{}).Where("id = ?", worldID).Count(&count)
+	if count > 0 {
+		return
+	}
+	seedTestWorld(tb, testWorld{World: model.WorldModel{StringPKBaseModel: model.StringPKBaseModel{ID: worldID}, Name: "Perm Cache World"}})
+	if err := db.Create(&model.MemberModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: userID + "-member"},
+		UserID:            userID,
+		ChannelID:         worldID + "-ch",
+		Nickname:          "tester",
+	}).Error; err != nil {
+		tb.Fatalf("create member failed: %v", err)
+	}
+	if err := model.UserRoleMappingCreate(&model.UserRoleMappingModel{
+		RoleType: "channel",
+		UserID:   userID,
+		RoleID:   "ch-" + worldID + "-ch-member",
+	}); err != nil {
+		tb.Fatalf("create role mapping failed: %v", err)
+	}
+}
+
+func TestWorldMembershipCacheInvalidation(t *testing.T) {
+	initTestDB(t)
+	worldID := "world-perm-cache"
+	userID := "user-perm-cache"
+	seedPermCacheFixture(t, worldID, userID)
+
+	if IsWorldMember(worldID, userID) {
+		t.Fatalf("expected non-member before join")
+	}
+	if _, err := WorldJoin(worldID, userID, model.WorldRoleMember); err != nil {
+		t.Fatalf("join failed: %v", err)
+	}
+	if !IsWorldMember(worldID, userID) {
+		t.Fatalf("expected member after join")
+	}
+	if IsWorldAdmin(worldID, userID) {
+		t.Fatalf("member should not be admin")
+	}
+	if err := WorldLeave(worldID, userID); err != nil {
+		t.Fatalf("leave failed: %v", err)
+	}
+	if IsWorldMember(worldID, userID) {
+		t.Fatalf("expected non-member after leave")
+	}
+}
+
+// 模拟每条 WebSocket 消息的鉴权读取：成员列表、频道角色、世界成员身份
+func benchmarkHotPath(b *testing.B, cached bool) {
+	initTestDB(b)
+	countQueries(b)
+	worldID := "world-perm-bench"
+	userID := "user-perm-bench"
+	if cached {
+		worldID += "-cached"
+		userID += "-cached"
+	}
+	seedPermCacheFixture(b, worldID, userID)
+	channelID := worldID + "-ch"
+	db := model.GetDB()
+
+	queryCounter.Store(0)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if cached {
+			_, _ = model.MemberListByUserIDCached(userID)
+			_, _ = model.UserRoleMappingListByUserIDCached(userID, channelID, "channel")
+			_ = IsWorldMember(worldID, userID)
+			continue
+		}
+		var members []*model.MemberModel
+		db.Where("user_id = ?", userID).Find(&members)
+		_, _ = model.UserRoleMappingListByUserID(userID, channelID, "channel")
+		var member model.WorldMemberModel
+		db.Where("world_id = ? AND user_id = ?", worldID, userID).Limit(1).Find(&member)
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(queryCounter.Load())/float64(b.N), "queries/op")
+}
+
+func BenchmarkHotPathUncached(b *testing.B) {
+	benchmarkHotPath(b, false)
+}
+
+func BenchmarkHotPathCached(b *testing.B) {
+	benchmarkHotPath(b, true)
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/samber/lo"
+
+	"sealchat/model"
+	"sealchat/pm"
+)
+
+// ReadReceiptHiddenPrefKey 用户偏好：值为 true 时不出现在任何已读名单与已读事件中
+const ReadReceiptHiddenPrefKey = "read_receipts_hidden"
+
+const readReceiptSeenByLimit = 200
+
+var (
+	ErrReadReceiptsDisabled  = errors.New("该频道未开启已读回执")
+	ErrReadReceiptNotFound   = errors.New("消息不存在")
+	ErrReadReceiptPermission = errors.New("没有查看该消息的权限")
+)
+
+// MessageSeenByItem 已读名单中的一名成员，ReadAt 为其已读位置（毫秒）
+type MessageSeenByItem struct {
+	UserID   string `json:"userId"`
+	Username string `json:"username"`
+	Nickname string `json:"nickname"`
+	Avatar   string `json:"avatar"`
+	ReadAt   int64  `json:"readAt"`
+}
+
+type MessageSeenByResult struct {
+	MessageID string               `json:"messageId"`
+	Items     []*MessageSeenByItem `json:"items"`
+	Total     int                  `json:"total"`
+	// Pending 悄悄话中不在已读名单里的收件人数量，普通消息不统计
+	Pending int `json:"pending"`
+}
+
+// ReadReceiptVisibleUsers 频道开启已读回执时，返回 userIDs 中未关闭已读公开的用户；未开启时返回空
+func ReadReceiptVisibleUsers(channelID string, userIDs []string) []string {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	ch, err := model.ChannelGet(channelID)
+	if err != nil || ch == nil || !ch.ReadReceipts {
+		return nil
+	}
+	hidden, err := model.UserPreferenceUserIDsWithValue(ReadReceiptHiddenPrefKey, userIDs, "true", "1")
+	if err != nil {
+		return nil
+	}
+	return lo.Without(lo.Uniq(userIDs), hidden...)
+}
+
+// ReadReceiptAudience 返回 userIDs 中可以查看该频道已读名单的用户：世界频道仅限世界成员与平台管理员
+func ReadReceiptAudience(channelID string, userIDs []string) []string {
+	ch, err := model.ChannelGet(channelID)
+	if err != nil || ch == nil || ch.ID == "" {
+		return nil
+	}
+	return lo.Filter(userIDs, func(id string, _ int) bool {
+		return canViewReadReceipts(ch, id)
+	})
+}
+
+// canViewReadReceipts 游客与旁观者也有频道查看权限，已读信息只对世界成员开放
+func canViewReadReceipts(ch *model.ChannelModel, userID string) bool {
+	if ch.WorldID == "" {
+		return true
+	}
+	return IsWorldMember(ch.WorldID, userID) || pm.CanWithSystemRole(userID, pm.PermModAdmin)
+}
+
+// ChannelReadReceiptsSet 开启或关闭频道的已读回执
+func ChannelReadReceiptsSet(channelID, actorID string, enabled bool) (*model.ChannelModel, error) {
+	ch, err := model.ChannelGet(strings.TrimSpace(channelID))
+	if err != nil {
+		return nil, err
+	}
+	if ch == nil || ch.ID == "" || ch.IsPrivate {
+		return nil, ErrChannelTreeNotFound
+	}
+	if !pm.CanWithChannelRole(actorID, ch.ID, pm.PermFuncChannelManageInfo) &&
+		!(ch.WorldID != "" && IsWorldAdmin(ch.WorldID, actorID)) && !pm.CanWithSystemRole(actorID, pm.PermModAdmin) {
+		return nil, ErrWorldPermission
+	}
+	if err := model.GetDB().Model(&model.ChannelModel{}).Where("id = ?", ch.ID).
+		Update("read_receipts", enabled).Error; err != nil {
+		return nil, err
+	}
+	ch.ReadReceipts = enabled
+	return ch, nil
+}
+
+// MessageSeenBy 根据成员的已读位置推算已读过该消息的成员；悄悄话只统计收件人
+func MessageSeenBy(channelID, messageID, actorID string) (*MessageSeenByResult, error) {
+	ch, err := model.ChannelGet(strings.TrimSpace(channelID))
+	if err != nil {
+		return nil, err
+	}
+	if ch == nil || ch.ID == "" || ch.IsPrivate {
+		return nil, ErrReadReceiptNotFound
+	}
+	if !ch.ReadReceipts {
+		return nil, ErrReadReceiptsDisabled
+	}
+	if !pm.CanWithChannelRole(actorID, ch.ID, pm.PermFuncChannelRead, pm.PermFuncChannelReadAll) {
+		return nil, ErrReadReceiptPermission
+	}
+	if !canViewReadReceipts(ch, actorID) {
+		return nil, ErrReadReceiptPermission
+	}
+
+	var msg model.MessageModel
+	if err := model.GetDB().Where("id = ? AND channel_id = ? AND is_deleted = ?", messageID, ch.ID, false).
+		Limit(1).Find(&msg).Error; err != nil {
+		return nil, err
+	}
+	if msg.ID == "" {
+		return nil, ErrReadReceiptNotFound
+	}
+
+	var audience []string
+	if msg.IsWhisper {
+		audience = model.GetWhisperRecipientIDsBatch([]string{msg.ID})[msg.ID]
+		if msg.WhisperTo != "" {
+			audience = append(audience, msg.WhisperTo)
+		}
+		audience = lo.Without(lo.Uniq(audience), msg.UserID)
+		if msg.UserID != actorID && !lo.Contains(audience, actorID) {
+			return nil, ErrReadReceiptNotFound
+		}
+		if len(audience) == 0 {
+			return &MessageSeenByResult{MessageID: msg.ID, Items: []*MessageSeenByItem{}}, nil
+		}
+	}
+
+	records, err := model.ChannelReadListSince(ch.ID, msg.CreatedAt.UnixMilli(), audience)
+	if err != nil {
+		return nil, err
+	}
+	readAt := map[string]int64{}
+	var readers []string
+	for _, r := range records {
+		if r.UserId == msg.UserID {
+			continue
+		}
+		// 失去查看权限的成员不再出现在名单中
+		if !msg.IsWhisper && !pm.CanWithChannelRole(r.UserId, ch.ID, pm.PermFuncChannelRead, pm.PermFuncChannelReadAll) {
+			continue
+		}
+		readAt[r.UserId] = r.MessageTime
+		readers = append(readers, r.UserId)
+	}
+	readers = ReadReceiptVisibleUsers(ch.ID, readers)
+
+	result := &MessageSeenByResult{MessageID: msg.ID, Items: []*MessageSeenByItem{}, Total: len(readers)}
+	if msg.IsWhisper {
+		// 关闭已读公开的收件人按未读计，避免由差值推断
+		result.Pending = len(audience) - result.Total
+	}
+	if len(readers) > readReceiptSeenByLimit {
+		readers = readers[:readReceiptSeenByLimit]
+	}
+	if len(readers) > 0 {
+		var users []*model.UserModel
+		if err := model.GetDB().Select("id, username, nickname, avatar").Where("id IN ?", readers).Find(&users).Error; err != nil {
+			return nil, err
+		}
+		byID := lo.SliceToMap(users, func(u *model.UserModel) (string, *model.UserModel) { return u.ID, u })
+		for _, id := range readers {
+			item := &MessageSeenByItem{UserID: id, ReadAt: readAt[id]}
+			if u := byID[id]; u != nil {
+				item.Username, item.Nickname, item.Avatar = u.Username, u.Nickname, u.Avatar
+			}
+			result.Items = append(result.Items, item)
+		}
+	}
+	return result, nil
+}
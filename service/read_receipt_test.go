@@ -0,0 +1,128 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"sealchat/model"
+	"sealchat/pm"
+)
+
+func TestMessageSeenBy(t *testing.T) {
+	initTestDB(t)
+	pm.Init()
+	db := model.GetDB()
+
+	channelID := "rrhall"
+	gm, alice, bob, carol := "rr-gm", "rr-alice", "rr-bob", "rr-carol"
+	for _, u := range []string{gm, alice, bob, carol} {
+		if err := db.Create(&model.UserModel{StringPKBaseModel: model.StringPKBaseModel{ID: u}, Username: u, Nickname: u}).Error; err != nil {
+			t.Fatalf("create user failed: %v", err)
+		}
+	}
+	if err := db.Create(&model.ChannelModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: channelID},
+		Name:              "回执测试",
+		PermType:          "public",
+		Status:            model.ChannelStatusActive,
+	}).Error; err != nil {
+		t.Fatalf("create channel failed: %v", err)
+	}
+	pm.RolePermApply("ch-rrhall-visitor", []string{pm.PermFuncChannelRead.ID()})
+
+	sentAt := time.Now().Add(-time.Minute)
+	messages := []model.MessageModel{
+		{StringPKBaseModel: model.StringPKBaseModel{ID: "rr-msg", CreatedAt: sentAt}, ChannelID: channelID, UserID: gm, Content: "线索"},
+		{StringPKBaseModel: model.StringPKBaseModel{ID: "rr-whisper", CreatedAt: sentAt}, ChannelID: channelID, UserID: gm, Content: "密信",
+			IsWhisper: true, WhisperTo: alice},
+	}
+	for i := range messages {
+		if err := db.Create(&messages[i]).Error; err != nil {
+			t.Fatalf("create message failed: %v", err)
+		}
+	}
+	for _, uid := range []string{gm, alice, bob} {
+		if err := model.ChannelReadSet(channelID, uid); err != nil {
+			t.Fatalf("set read failed: %v", err)
+		}
+	}
+	// carol 的已读位置停在消息之前
+	if err := db.Create(&model.ChannelLatestReadModel{ChannelId: channelID, UserId: carol, MessageTime: sentAt.Add(-time.Hour).UnixMilli()}).Error; err != nil {
+		t.Fatalf("create read record failed: %v", err)
+	}
+
+	if _, err := MessageSeenBy(channelID, "rr-msg", alice); !errors.Is(err, ErrReadReceiptsDisabled) {
+		t.Fatalf("receipts should be off by default, got %v", err)
+	}
+	if _, err := ChannelReadReceiptsSet(channelID, alice, true); !errors.Is(err, ErrWorldPermission) {
+		t.Fatalf("member should not toggle receipts, got %v", err)
+	}
+	if err := db.Model(&model.ChannelModel{}).Where("id = ?", channelID).Update("read_receipts", true).Error; err != nil {
+		t.Fatalf("enable receipts failed: %v", err)
+	}
+
+	result, err := MessageSeenBy(channelID, "rr-msg", alice)
+	if err != nil {
+		t.Fatalf("seen-by failed: %v", err)
+	}
+	if result.Total != 2 {
+		t.Fatalf("alice and bob should have seen the message, got %+v", result.Items)
+	}
+
+	whisper, err := MessageSeenBy(channelID, "rr-whisper", gm)
+	if err != nil {
+		t.Fatalf("whisper seen-by failed: %v", err)
+	}
+	if whisper.Total != 1 || whisper.Items[0].UserID != alice || whisper.Pending != 0 {
+		t.Fatalf("whisper receipts should only cover recipients: %+v", whisper)
+	}
+	if _, err := MessageSeenBy(channelID, "rr-whisper", bob); !errors.Is(err, ErrReadReceiptNotFound) {
+		t.Fatalf("outsiders should not see whisper receipts, got %v", err)
+	}
+
+	if _, err := model.UserPreferenceUpsert(alice, ReadReceiptHiddenPrefKey, "true"); err != nil {
+		t.Fatalf("set preference failed: %v", err)
+	}
+	result, _ = MessageSeenBy(channelID, "rr-msg", bob)
+	if result.Total != 1 || result.Items[0].UserID != bob {
+		t.Fatalf("opted-out user should be hidden: %+v", result.Items)
+	}
+	whisper, _ = MessageSeenBy(channelID, "rr-whisper", gm)
+	if whisper.Total != 0 || whisper.Pending != 1 {
+		t.Fatalf("opted-out recipient should count as pending: %+v", whisper)
+	}
+	if visible := ReadReceiptVisibleUsers(channelID, []string{alice, bob}); len(visible) != 1 || visible[0] != bob {
+		t.Fatalf("unexpected visible users: %v", visible)
+	}
+}
+
+func TestMessageSeenByRequiresWorldMember(t *testing.T) {
+	ownerID, memberID, visitorID := "rrw-owner", "rrw-member", "rrw-visitor"
+	channelID := "rrwhall"
+	seedTestWorld(t, testWorld{
+		World:    model.WorldModel{StringPKBaseModel: model.StringPKBaseModel{ID: "world-rrw"}, OwnerID: ownerID},
+		Members:  []string{memberID},
+		Users:    []string{visitorID},
+		Channels: []model.ChannelModel{{StringPKBaseModel: model.StringPKBaseModel{ID: channelID}, PermType: "public", ReadReceipts: true}},
+	})
+	pm.Init()
+	db := model.GetDB()
+	visitorRole := "ch-rrwhall-visitor"
+	pm.RolePermApply(visitorRole, []string{pm.PermFuncChannelRead.ID()})
+	for _, uid := range []string{memberID, visitorID} {
+		if err := model.UserRoleMappingCreate(&model.UserRoleMappingModel{RoleType: "channel", UserID: uid, RoleID: visitorRole}); err != nil {
+			t.Fatalf("create role mapping failed: %v", err)
+		}
+	}
+	if err := db.Create(&model.MessageModel{StringPKBaseModel: model.StringPKBaseModel{ID: "rrw-msg"}, ChannelID: channelID, UserID: ownerID, Content: "开团"}).Error; err != nil {
+		t.Fatalf("create message failed: %v", err)
+	}
+
+	if _, err := MessageSeenBy(channelID, "rrw-msg", visitorID); !errors.Is(err, ErrReadReceiptPermission) {
+		t.Fatalf("visitor should not read receipts, got %v", err)
+	}
+	if _, err := MessageSeenBy(channelID, "rrw-msg", memberID); err != nil {
+		t.Fatalf("member should read receipts: %v", err)
+	}
+}
@@ -149,6 +149,44 @@ func NormalizeSatoriContent(content, userID, channelID string, cfg SatoriAttachm
 	return result, nil
 }
 
+// SatoriResourceURL resolves a single id:xxx reference (e.g. an avatar) to an absolute URL.
+func SatoriResourceURL(src string) string {
+	if !strings.HasPrefix(strings.TrimSpace(src), "id:") {
+		return src
+	}
+	return resolveImageURL(src)
+}
+
+// ResolveSatoriResourceURLs rewrites id:xxx resource references to absolute
+// attachment URLs so that standard Satori clients can fetch them directly.
+func ResolveSatoriResourceURLs(content string) string {
+	if !strings.Contains(content, "id:") || !protocol.ContainsSatoriTags(content) {
+		return content
+	}
+	root := protocol.ElementParse(content)
+	if root == nil {
+		return content
+	}
+	modified := false
+	root.Traverse(func(el *protocol.Element) {
+		switch el.Type {
+		case "img", "image", "audio", "video", "file":
+		default:
+			return
+		}
+		src, ok := el.Attrs["src"].(string)
+		if !ok || !strings.HasPrefix(src, "id:") {
+			return
+		}
+		el.Attrs["src"] = SatoriResourceURL(src)
+		modified = true
+	})
+	if !modified {
+		return content
+	}
+	return root.ToString()
+}
+
 // processDataURLToAttachment converts a data URL to an attachment
 func processDataURLToAttachment(dataURL, userID, channelID string, cfg SatoriAttachmentConfig, appFs afero.Fs, tmpDir string, expectImage bool) (string, error) {
 	// Parse data URL: data:[<mediatype>][;base64],<data>
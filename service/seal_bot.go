@@ -0,0 +1,727 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"math/rand/v2"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	ds "github.com/sealdice/dicescript"
+
+	"sealchat/model"
+)
+
+// 内置小海豹的 TRPG 指令，覆盖海豹核心的常用指令：
+// .r/.rh 掷骰与暗骰、.ra/.rc 技能检定、.sc 理智检定、.st 属性录入、.en 成长检定、.coc/.dnd 人物作成、.ri/.init 先攻。
+// 属性读写频道内当前绑定的人物卡
+
+// SealBotUserID 内置小海豹的用户 ID
+const SealBotUserID = "BOT:1000"
+
+const sealBotMaxGenerate = 10
+
+var (
+	sealBotCommandPattern = regexp.MustCompile(`(?i)^[\.。．｡](rh|ra|rc|ri|r|x|sc|st|en|coc|dnd|init)`)
+	sealBotExprPattern    = regexp.MustCompile(`^([0-9dDkKqQpPaAbBfFcCmM+\-*/()#]*)\s*(.*)$`)
+	sealBotCheckPattern   = regexp.MustCompile(`^(.*?)\s*(\d+)?\s*$`)
+	sealBotBonusPattern   = regexp.MustCompile(`(?i)^([bp])(\d?)\s*`)
+	sealBotSanPattern     = regexp.MustCompile(`^\s*([^/\s]+)/(\S+)\s*(\d+)?`)
+	sealBotStPattern      = regexp.MustCompile(`([^\s\d:：=+\-]+)\s*(?:[:：=]\s*)?([+\-]?)\s*(\d*[dD]\d+|\d+)`)
+	sealBotInitPattern    = regexp.MustCompile(`^([+\-]\d+)?\s*(\d+)?\s*(.*)$`)
+	sealBotTagPattern     = regexp.MustCompile(`<[^>]*>`)
+	sealBotBrPattern      = regexp.MustCompile(`(?i)<br\s*/?>|</p>`)
+	sealBotDicePattern    = regexp.MustCompile(`(?i)(\d*)d(\d+)`)
+)
+
+// sealBotAttrAliases 常见属性别名，录入与读取时统一为中文名
+var sealBotAttrAliases = map[string]string{
+	"san": "理智", "san值": "理智", "理智值": "理智", "sanity": "理智",
+	"hp": "体力", "生命": "体力", "生命值": "体力",
+	"mp": "魔法", "魔法值": "魔法",
+	"luck": "幸运", "运气": "幸运",
+	"str": "力量", "con": "体质", "siz": "体型", "dex": "敏捷", "app": "外貌",
+	"int": "智力", "灵感": "智力", "pow": "意志", "edu": "教育", "知识": "教育",
+	"侦察": "侦查", "图书馆": "图书馆使用",
+}
+
+// SealBotRequest 一条待处理的指令消息
+type SealBotRequest struct {
+	UserID          string
+	ChannelID       string
+	SenderName      string
+	Content         string
+	DefaultDiceExpr string
+}
+
+// SealBotReply 指令的回复。Hidden 为 true 时 Text 仅私聊发给发送者，频道内只发送 PublicText
+type SealBotReply struct {
+	Text       string
+	Hidden     bool
+	PublicText string
+}
+
+// sealBotPlainText 从消息 HTML 中取出纯文本
+func sealBotPlainText(content string) string {
+	if LooksLikeTipTapJSON(content) {
+		return ""
+	}
+	text := sealBotBrPattern.ReplaceAllString(content, "\n")
+	text = sealBotTagPattern.ReplaceAllString(text, "")
+	return strings.TrimSpace(html.UnescapeString(text))
+}
+
+// sealBotArgLetters 指令后可紧跟的英文字母：.r 后接骰子表达式，.ra/.rc 后接奖惩骰。
+// 其余指令后紧跟英文字母时视为别的单词，如 .rxyz、.stop
+var sealBotArgLetters = map[string]string{
+	"r":  "dkqpabfcm",
+	"rh": "dkqpabfcm",
+	"ra": "bp",
+	"rc": "bp",
+}
+
+func sealBotParse(content string) (cmd string, rest string, ok bool) {
+	text := sealBotPlainText(content)
+	if strings.HasPrefix(text, "/x") {
+		cmd, rest = "x", text[2:]
+	} else {
+		loc := sealBotCommandPattern.FindStringSubmatchIndex(text)
+		if loc == nil {
+			return "", "", false
+		}
+		cmd, rest = strings.ToLower(text[loc[2]:loc[3]]), text[loc[1]:]
+	}
+	if rest != "" {
+		c := rest[0]
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		if c >= 'a' && c <= 'z' && !strings.ContainsRune(sealBotArgLetters[cmd], rune(c)) {
+			return "", "", false
+		}
+	}
+	return cmd, rest, true
+}
+
+// SealBotMatch 内容是否为小海豹能处理的指令
+func SealBotMatch(content string) bool {
+	_, _, ok := sealBotParse(content)
+	return ok
+}
+
+// SealBotIsHidden 指令结果是否需要私聊发送
+func SealBotIsHidden(content string) bool {
+	cmd, _, ok := sealBotParse(content)
+	return ok && cmd == "rh"
+}
+
+// SealBotSolve 处理指令，内容不是指令时返回 nil
+func SealBotSolve(req *SealBotRequest) *SealBotReply {
+	cmd, rest, ok := sealBotParse(req.Content)
+	if !ok {
+		return nil
+	}
+	rest = strings.TrimSpace(rest)
+	name := req.SenderName
+	if name == "" {
+		name = "<未知>"
+	}
+	switch cmd {
+	case "x":
+		return &SealBotReply{Text: sealBotExpr(rest)}
+	case "r", "rh":
+		return sealBotRollCommand(req, name, rest, cmd == "rh")
+	case "ra", "rc":
+		return sealBotSkillCheck(req, name, rest)
+	case "sc":
+		return sealBotSanCheck(req, name, rest)
+	case "st":
+		return sealBotSt(req, name, rest)
+	case "en":
+		return sealBotEnhance(req, name, rest)
+	case "coc":
+		return &SealBotReply{Text: sealBotGenerate(name, rest, sealBotCoCStats)}
+	case "dnd":
+		return &SealBotReply{Text: sealBotGenerate(name, rest, sealBotDnDStats)}
+	case "ri":
+		return sealBotInitRoll(req, name, rest)
+	case "init":
+		return sealBotInitList(req, rest)
+	}
+	return nil
+}
+
+func sealBotNewVM(defaultDiceExpr string) *ds.Context {
+	vm := ds.NewVM()
+	vm.Config.EnableDiceWoD = true
+	vm.Config.EnableDiceCoC = true
+	vm.Config.EnableDiceFate = true
+	vm.Config.EnableDiceDoubleCross = true
+	vm.Config.DisableStmts = true
+	vm.Config.OpCountLimit = 30000
+	sides := "100"
+	if normalized, err := NormalizeDefaultDiceExpr(defaultDiceExpr); err == nil && defaultDiceExpr != "" {
+		sides = normalized[1:]
+	}
+	vm.Config.DefaultDiceSideExpr = "面数 ?? " + sides
+	return vm
+}
+
+type sealBotRollResult struct {
+	Detail string
+	Value  string
+	Int    int64
+}
+
+func sealBotRoll(expr, defaultDiceExpr string) (*sealBotRollResult, error) {
+	vm := sealBotNewVM(defaultDiceExpr)
+	if err := vm.Run(expr); err != nil {
+		return nil, err
+	}
+	if vm.Ret == nil {
+		return nil, errors.New("表达式没有结果")
+	}
+	ret := &sealBotRollResult{
+		Detail: strings.TrimSpace(vm.GetDetailText()),
+		Value:  vm.Ret.ToString(),
+	}
+	if v, ok := vm.Ret.ReadInt(); ok {
+		ret.Int = int64(v)
+	} else if v, ok := vm.Ret.ReadFloat(); ok {
+		ret.Int = int64(v)
+	}
+	return ret, nil
+}
+
+func sealBotRollInt(expr string) (int64, error) {
+	ret, err := sealBotRoll(expr, "")
+	if err != nil {
+		return 0, err
+	}
+	return ret.Int, nil
+}
+
+// sealBotExpr .x 保留原有的算式调试输出
+func sealBotExpr(expr string) string {
+	if expr == "" {
+		expr = "d100"
+	}
+	vm := ds.NewVM()
+	vm.Config.EnableDiceWoD = true
+	vm.Config.EnableDiceCoC = true
+	vm.Config.EnableDiceFate = true
+	vm.Config.EnableDiceDoubleCross = true
+	vm.Config.DefaultDiceSideExpr = "面数 ?? 100"
+	vm.Config.OpCountLimit = 30000
+	if err := vm.Run(expr); err != nil {
+		return "出错:" + err.Error()
+	}
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("算式: %s\n", expr))
+	sb.WriteString(fmt.Sprintf("过程: %s\n", vm.GetDetailText()))
+	sb.WriteString(fmt.Sprintf("结果: %s\n", vm.Ret.ToString()))
+	sb.WriteString(fmt.Sprintf("栈顶: %d 层数:%d 算力: %d\n", vm.StackTop(), vm.Depth(), vm.NumOpCount))
+	sb.WriteString("注: 这是一只小海豹，只实现了常用指令，完整功能请接入海豹核心")
+	return sb.String()
+}
+
+func sealBotRollCommand(req *SealBotRequest, name, rest string, hidden bool) *SealBotReply {
+	m := sealBotExprPattern.FindStringSubmatch(rest)
+	expr, reason := "", rest
+	if m != nil {
+		expr, reason = m[1], strings.TrimSpace(m[2])
+	}
+	if expr == "" {
+		expr, _ = NormalizeDefaultDiceExpr(req.DefaultDiceExpr)
+	}
+	ret, err := sealBotRoll(expr, req.DefaultDiceExpr)
+	if err != nil {
+		return &SealBotReply{Text: fmt.Sprintf("%s的掷骰表达式有误: %s", name, err.Error())}
+	}
+	text := fmt.Sprintf("%s掷出了 %s", name, strings.ToUpper(expr))
+	if ret.Detail != "" && ret.Detail != ret.Value {
+		text += ret.Detail
+	}
+	text += "=" + ret.Value
+	if reason != "" {
+		text = fmt.Sprintf("由于%s，%s", reason, text)
+	}
+	if hidden {
+		return &SealBotReply{Text: text, Hidden: true, PublicText: fmt.Sprintf("%s进行了一次暗骰", name)}
+	}
+	return &SealBotReply{Text: text}
+}
+
+// CoC7 检定结果等级
+const (
+	sealBotFumble = iota
+	sealBotFailure
+	sealBotSuccess
+	sealBotHard
+	sealBotExtreme
+	sealBotCritical
+)
+
+var sealBotLevelText = map[int]string{
+	sealBotFumble:   "大失败",
+	sealBotFailure:  "失败",
+	sealBotSuccess:  "成功",
+	sealBotHard:     "困难成功",
+	sealBotExtreme:  "极难成功",
+	sealBotCritical: "大成功",
+}
+
+// sealBotCoCLevel 按 CoC7 规则书默认规则判定：1 为大成功；技能低于 50 时 96-100 为大失败，否则仅 100
+func sealBotCoCLevel(d, value int64) int {
+	switch {
+	case d == 1:
+		return sealBotCritical
+	case d == 100 || (value < 50 && d >= 96):
+		return sealBotFumble
+	case d <= value/5:
+		return sealBotExtreme
+	case d <= value/2:
+		return sealBotHard
+	case d <= value:
+		return sealBotSuccess
+	}
+	return sealBotFailure
+}
+
+// sealBotD100 掷 d100，bonus 为正时是奖励骰，为负时是惩罚骰
+func sealBotD100(bonus int) (int64, string) {
+	units := rand.IntN(10)
+	tens := []int{rand.IntN(10)}
+	for i := 0; i < bonus || i < -bonus; i++ {
+		tens = append(tens, rand.IntN(10))
+	}
+	pick := tens[0]
+	for _, t := range tens[1:] {
+		val, cur := t*10+units, pick*10+units
+		if val == 0 {
+			val = 100
+		}
+		if cur == 0 {
+			cur = 100
+		}
+		if (bonus > 0 && val < cur) || (bonus < 0 && val > cur) {
+			pick = t
+		}
+	}
+	d := int64(pick*10 + units)
+	if d == 0 {
+		d = 100
+	}
+	if bonus == 0 {
+		return d, ""
+	}
+	parts := make([]string, 0, len(tens))
+	for _, t := range tens {
+		parts = append(parts, strconv.Itoa(t))
+	}
+	kind := "奖励骰"
+	if bonus < 0 {
+		kind = "惩罚骰"
+	}
+	return d, fmt.Sprintf("[十位%s:%s]", kind, strings.Join(parts, ","))
+}
+
+func sealBotAttrKey(name string) string {
+	name = strings.TrimSpace(name)
+	if alias, ok := sealBotAttrAliases[strings.ToLower(name)]; ok {
+		return alias
+	}
+	return name
+}
+
+func sealBotLoadCard(req *SealBotRequest) *model.CharacterCardModel {
+	card, err := CharacterCardResolveForChannel(req.UserID, req.ChannelID)
+	if err != nil {
+		return nil
+	}
+	return card
+}
+
+func sealBotAttr(card *model.CharacterCardModel, name string) (int64, bool) {
+	if card == nil || card.Attrs == nil {
+		return 0, false
+	}
+	key := sealBotAttrKey(name)
+	v, ok := card.Attrs[key]
+	if !ok {
+		for k, val := range card.Attrs {
+			if strings.EqualFold(k, key) {
+				v, ok = val, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return 0, false
+	}
+	switch n := v.(type) {
+	case float64:
+		return int64(n), true
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	case string:
+		if i, err := strconv.ParseInt(strings.TrimSpace(n), 10, 64); err == nil {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// sealBotSaveAttrs 写回人物卡；尚未绑定人物卡时以发送者名称新建一张
+func sealBotSaveAttrs(req *SealBotRequest, card *model.CharacterCardModel, attrs map[string]any) error {
+	if card != nil {
+		merged := map[string]any{}
+		for k, v := range card.Attrs {
+			merged[k] = v
+		}
+		for k, v := range attrs {
+			merged[k] = v
+		}
+		normalized, err := normalizeCharacterCardAttrs(merged)
+		if err != nil {
+			return err
+		}
+		return model.CharacterCardUpdate(card.ID, map[string]any{"attrs": normalized})
+	}
+	name := req.SenderName
+	if name == "" {
+		name = "未命名角色"
+	}
+	_, err := CharacterCardUpsertByName(req.UserID, req.ChannelID, name, "coc7", attrs)
+	return err
+}
+
+func sealBotSkillCheck(req *SealBotRequest, name, rest string) *SealBotReply {
+	bonus := 0
+	if m := sealBotBonusPattern.FindStringSubmatch(rest); m != nil {
+		n := 1
+		if m[2] != "" {
+			n, _ = strconv.Atoi(m[2])
+		}
+		if strings.EqualFold(m[1], "p") {
+			n = -n
+		}
+		bonus = n
+		rest = rest[len(m[0]):]
+	}
+	required := sealBotSuccess
+	difficulty := ""
+	for prefix, level := range map[string]int{"困难": sealBotHard, "极难": sealBotExtreme} {
+		if strings.HasPrefix(rest, prefix) {
+			required, difficulty = level, prefix
+			rest = strings.TrimPrefix(rest, prefix)
+		}
+	}
+	m := sealBotCheckPattern.FindStringSubmatch(strings.TrimSpace(rest))
+	skill, valueText := strings.TrimSpace(m[1]), m[2]
+	var value int64
+	if valueText != "" {
+		value, _ = strconv.ParseInt(valueText, 10, 64)
+	} else {
+		v, ok := sealBotAttr(sealBotLoadCard(req), skill)
+		if !ok {
+			return &SealBotReply{Text: fmt.Sprintf("未找到%s的属性「%s」，请先用 .st 录入，或直接给出数值，如 .ra 侦查 60", name, skill)}
+		}
+		value = v
+	}
+	d, bonusText := sealBotD100(bonus)
+	level := sealBotCoCLevel(d, value)
+	levelText := sealBotLevelText[level]
+	if level >= sealBotSuccess && level < sealBotCritical && level < required {
+		levelText = "失败"
+	}
+	return &SealBotReply{Text: fmt.Sprintf("%s进行%s%s检定: D100=%d%s/%d %s", name, difficulty, skill, d, bonusText, value, levelText)}
+}
+
+// sealBotMaxRoll 表达式的最大值，用于大失败时的理智损失
+func sealBotMaxRoll(expr string) (int64, error) {
+	maxExpr := sealBotDicePattern.ReplaceAllStringFunc(expr, func(s string) string {
+		parts := strings.SplitN(strings.ToLower(s), "d", 2)
+		count := int64(1)
+		if parts[0] != "" {
+			count, _ = strconv.ParseInt(parts[0], 10, 64)
+		}
+		sides, _ := strconv.ParseInt(parts[1], 10, 64)
+		return strconv.FormatInt(count*sides, 10)
+	})
+	return sealBotRollInt(maxExpr)
+}
+
+func sealBotSanCheck(req *SealBotRequest, name, rest string) *SealBotReply {
+	m := sealBotSanPattern.FindStringSubmatch(rest)
+	if m == nil {
+		return &SealBotReply{Text: "格式: .sc 成功损失/失败损失 [理智值]，如 .sc 1/1d6"}
+	}
+	card := sealBotLoadCard(req)
+	var san int64
+	if m[3] != "" {
+		san, _ = strconv.ParseInt(m[3], 10, 64)
+	} else {
+		v, ok := sealBotAttr(card, "理智")
+		if !ok {
+			return &SealBotReply{Text: fmt.Sprintf("未找到%s的理智值，请先用 .st 理智60 录入，或直接给出数值，如 .sc 1/1d6 60", name)}
+		}
+		san = v
+	}
+	d, _ := sealBotD100(0)
+	level := sealBotCoCLevel(d, san)
+	lossExpr := m[2]
+	if level >= sealBotSuccess {
+		lossExpr = m[1]
+	}
+	var loss int64
+	var err error
+	if level == sealBotFumble {
+		loss, err = sealBotMaxRoll(lossExpr)
+	} else {
+		loss, err = sealBotRollInt(lossExpr)
+	}
+	if err != nil {
+		return &SealBotReply{Text: fmt.Sprintf("理智损失表达式有误: %s", err.Error())}
+	}
+	next := san - loss
+	if next < 0 {
+		next = 0
+	}
+	if m[3] == "" {
+		_ = sealBotSaveAttrs(req, card, map[string]any{"理智": next})
+	}
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("%s的理智检定: D100=%d/%d %s\n", name, d, san, sealBotLevelText[level]))
+	sb.WriteString(fmt.Sprintf("理智变化: %d→%d (扣除%s=%d点)", san, next, strings.ToUpper(lossExpr), loss))
+	if next == 0 {
+		sb.WriteString(fmt.Sprintf("\n%s陷入了永久性疯狂", name))
+	} else if loss >= 5 {
+		sb.WriteString("\n单次损失理智不少于5点，请进行智力检定以判断是否陷入临时性疯狂")
+	}
+	return &SealBotReply{Text: sb.String()}
+}
+
+func sealBotSt(req *SealBotRequest, name, rest string) *SealBotReply {
+	card := sealBotLoadCard(req)
+	lower := strings.ToLower(rest)
+	if lower == "show" || strings.HasPrefix(lower, "show ") || lower == "list" {
+		if card == nil || len(card.Attrs) == 0 {
+			return &SealBotReply{Text: fmt.Sprintf("%s还没有录入任何属性", name)}
+		}
+		filter := strings.TrimSpace(rest[4:])
+		keys := make([]string, 0, len(card.Attrs))
+		for k := range card.Attrs {
+			if filter == "" || k == sealBotAttrKey(filter) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		parts := make([]string, 0, len(keys))
+		for _, k := range keys {
+			parts = append(parts, fmt.Sprintf("%s:%v", k, card.Attrs[k]))
+		}
+		return &SealBotReply{Text: fmt.Sprintf("%s的属性: %s", name, strings.Join(parts, " "))}
+	}
+
+	matches := sealBotStPattern.FindAllStringSubmatch(rest, -1)
+	if len(matches) == 0 {
+		return &SealBotReply{Text: "格式: .st 力量60敏捷50 / .st 体力-1d3 / .st show"}
+	}
+	attrs := map[string]any{}
+	var changes []string
+	for _, m := range matches {
+		key := sealBotAttrKey(m[1])
+		sign, valueExpr := m[2], m[3]
+		if sign == "" {
+			v, err := sealBotRollInt(valueExpr)
+			if err != nil {
+				return &SealBotReply{Text: fmt.Sprintf("属性 %s 的数值有误", key)}
+			}
+			attrs[key] = v
+			continue
+		}
+		cur, _ := sealBotAttr(card, key)
+		if v, ok := attrs[key].(int64); ok {
+			cur = v
+		}
+		delta, err := sealBotRollInt(valueExpr)
+		if err != nil {
+			return &SealBotReply{Text: fmt.Sprintf("属性 %s 的数值有误", key)}
+		}
+		next := cur + delta
+		if sign == "-" {
+			next = cur - delta
+		}
+		attrs[key] = next
+		changes = append(changes, fmt.Sprintf("%s: %d→%d (%s%s=%d)", key, cur, next, sign, strings.ToUpper(valueExpr), delta))
+	}
+	if err := sealBotSaveAttrs(req, card, attrs); err != nil {
+		return &SealBotReply{Text: "属性保存失败: " + err.Error()}
+	}
+	text := fmt.Sprintf("%s的属性录入完成，本次录入了%d条数据", name, len(attrs))
+	if len(changes) > 0 {
+		text += "\n" + strings.Join(changes, "\n")
+	}
+	return &SealBotReply{Text: text}
+}
+
+func sealBotEnhance(req *SealBotRequest, name, rest string) *SealBotReply {
+	m := sealBotCheckPattern.FindStringSubmatch(strings.TrimSpace(rest))
+	skill, valueText := strings.TrimSpace(m[1]), m[2]
+	if skill == "" {
+		return &SealBotReply{Text: "格式: .en 技能名 [技能值]，如 .en 侦查 60"}
+	}
+	card := sealBotLoadCard(req)
+	var value int64
+	if valueText != "" {
+		value, _ = strconv.ParseInt(valueText, 10, 64)
+	} else {
+		v, ok := sealBotAttr(card, skill)
+		if !ok {
+			return &SealBotReply{Text: fmt.Sprintf("未找到%s的属性「%s」，请先用 .st 录入，或直接给出数值", name, skill)}
+		}
+		value = v
+	}
+	d, _ := sealBotD100(0)
+	if d <= value && d <= 95 {
+		return &SealBotReply{Text: fmt.Sprintf("%s的%s成长检定: D100=%d/%d 失败\n%s数值不变", name, skill, d, value, skill)}
+	}
+	gain, _ := sealBotRollInt("1d10")
+	next := value + gain
+	if valueText == "" {
+		_ = sealBotSaveAttrs(req, card, map[string]any{sealBotAttrKey(skill): next})
+	}
+	return &SealBotReply{Text: fmt.Sprintf("%s的%s成长检定: D100=%d/%d 成功\n%s增加1D10=%d点，当前为%d", name, skill, d, value, skill, gain, next)}
+}
+
+type sealBotStat struct {
+	Name string
+	Expr string
+}
+
+var sealBotCoCStats = []sealBotStat{
+	{"力量", "3d6*5"}, {"体质", "3d6*5"}, {"体型", "(2d6+6)*5"}, {"敏捷", "3d6*5"}, {"外貌", "3d6*5"},
+	{"智力", "(2d6+6)*5"}, {"意志", "3d6*5"}, {"教育", "(2d6+6)*5"}, {"幸运", "3d6*5"},
+}
+
+var sealBotDnDStats = []sealBotStat{
+	{"力量", "4d6k3"}, {"体质", "4d6k3"}, {"敏捷", "4d6k3"}, {"智力", "4d6k3"}, {"感知", "4d6k3"}, {"魅力", "4d6k3"},
+}
+
+func sealBotGenerate(name, rest string, stats []sealBotStat) string {
+	count := 1
+	if n, err := strconv.Atoi(strings.TrimSpace(rest)); err == nil && n > 0 {
+		count = n
+	}
+	if count > sealBotMaxGenerate {
+		count = sealBotMaxGenerate
+	}
+	lines := []string{fmt.Sprintf("%s的人物作成:", name)}
+	for i := 0; i < count; i++ {
+		parts := make([]string, 0, len(stats)+1)
+		var total int64
+		for _, stat := range stats {
+			v, _ := sealBotRollInt(stat.Expr)
+			parts = append(parts, fmt.Sprintf("%s:%d", stat.Name, v))
+			if stat.Name != "幸运" {
+				total += v
+			}
+		}
+		parts = append(parts, fmt.Sprintf("共计:%d", total))
+		lines = append(lines, strings.Join(parts, " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sealBotInitNameLimit 先攻列表中名字的最大长度，与表字段一致
+const sealBotInitNameLimit = 100
+
+func sealBotInitName(name string) string {
+	if r := []rune(name); len(r) > sealBotInitNameLimit {
+		return string(r[:sealBotInitNameLimit])
+	}
+	return name
+}
+
+func sealBotInitRoll(req *SealBotRequest, name, rest string) *SealBotReply {
+	m := sealBotInitPattern.FindStringSubmatch(strings.TrimSpace(rest))
+	who := strings.TrimSpace(m[3])
+	if who == "" {
+		who = name
+	}
+	who = sealBotInitName(who)
+	if m[2] != "" && m[1] == "" {
+		v, _ := strconv.ParseInt(m[2], 10, 64)
+		if err := model.SealBotInitiativeSet(req.ChannelID, who, v); err != nil {
+			return &SealBotReply{Text: "先攻列表保存失败"}
+		}
+		return &SealBotReply{Text: fmt.Sprintf("%s的先攻点数设定为%d", who, v)}
+	}
+	expr := "d20" + m[1]
+	ret, err := sealBotRoll(expr, "")
+	if err != nil {
+		return &SealBotReply{Text: "先攻表达式有误: " + err.Error()}
+	}
+	if err := model.SealBotInitiativeSet(req.ChannelID, who, ret.Int); err != nil {
+		return &SealBotReply{Text: "先攻列表保存失败"}
+	}
+	return &SealBotReply{Text: fmt.Sprintf("%s的先攻点数: %s=%s", who, strings.ToUpper(expr), ret.Value)}
+}
+
+func sealBotInitList(req *SealBotRequest, rest string) *SealBotReply {
+	op, arg, _ := strings.Cut(rest, " ")
+	switch strings.ToLower(op) {
+	case "clr", "clear":
+		if err := model.SealBotInitiativeClear(req.ChannelID); err != nil {
+			return &SealBotReply{Text: "先攻列表清空失败"}
+		}
+		return &SealBotReply{Text: "先攻列表已清空"}
+	case "del", "rm":
+		arg = sealBotInitName(strings.TrimSpace(arg))
+		removed, err := model.SealBotInitiativeDelete(req.ChannelID, arg)
+		if err != nil {
+			return &SealBotReply{Text: "先攻列表修改失败"}
+		}
+		if removed {
+			return &SealBotReply{Text: fmt.Sprintf("已将%s移出先攻列表", arg)}
+		}
+		return &SealBotReply{Text: fmt.Sprintf("先攻列表中没有%s", arg)}
+	}
+	entries, err := model.SealBotInitiativeList(req.ChannelID)
+	if err != nil {
+		return &SealBotReply{Text: "先攻列表读取失败"}
+	}
+	if len(entries) == 0 {
+		return &SealBotReply{Text: "先攻列表为空，使用 .ri 加入"}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Value > entries[j].Value })
+	lines := []string{"当前先攻列表:"}
+	for i, e := range entries {
+		lines = append(lines, fmt.Sprintf("%d. %s: %d", i+1, e.Name, e.Value))
+	}
+	return &SealBotReply{Text: strings.Join(lines, "\n")}
+}
+
+// SealBotEnsureUser 确保内置小海豹的用户存在，私聊发送暗骰结果时需要
+func SealBotEnsureUser() *model.UserModel {
+	if user := model.UserGet(SealBotUserID); user != nil {
+		return user
+	}
+	user := &model.UserModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: SealBotUserID},
+		Username:          SealBotUserID,
+		Nickname:          "小海豹",
+		Salt:              "BOT_SALT",
+		IsBot:             true,
+	}
+	if err := model.GetDB().Create(user).Error; err != nil {
+		return model.UserGet(SealBotUserID)
+	}
+	return user
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"sealchat/utils"
+)
+
+func TestSealBotMatch(t *testing.T) {
+	for _, content := range []string{".r", ".rd20", ".rab侦查", ".st力量60", "。ra 侦查", ".rh 1d20", "<p>.st 力量60</p>", "/x 1d6", ".coc 3", ".init"} {
+		if !SealBotMatch(content) {
+			t.Fatalf("expected command: %q", content)
+		}
+	}
+	for _, content := range []string{"hello", ".help", "r 1d6", "", ".rxyz", ".stop", ".initiative", "/xyz", ".rcoc"} {
+		if SealBotMatch(content) {
+			t.Fatalf("unexpected command: %q", content)
+		}
+	}
+	if !SealBotIsHidden(".rh 潜行") || SealBotIsHidden(".r 潜行") {
+		t.Fatalf("hidden detection mismatch")
+	}
+}
+
+func TestSealBotCoCLevel(t *testing.T) {
+	cases := []struct {
+		d, value int64
+		level    int
+	}{
+		{1, 10, sealBotCritical},
+		{100, 90, sealBotFumble},
+		{97, 40, sealBotFumble},
+		{97, 60, sealBotFailure},
+		{12, 60, sealBotExtreme},
+		{30, 60, sealBotHard},
+		{60, 60, sealBotSuccess},
+		{61, 60, sealBotFailure},
+	}
+	for _, c := range cases {
+		if got := sealBotCoCLevel(c.d, c.value); got != c.level {
+			t.Fatalf("d=%d value=%d: expected %d, got %d", c.d, c.value, c.level, got)
+		}
+	}
+}
+
+func TestSealBotSolveStateless(t *testing.T) {
+	reply := SealBotSolve(&SealBotRequest{SenderName: "木落", Content: ".r 2d6 潜行"})
+	if reply == nil || !strings.HasPrefix(reply.Text, "由于潜行，木落掷出了 2D6") {
+		t.Fatalf("unexpected roll reply: %+v", reply)
+	}
+	reply = SealBotSolve(&SealBotRequest{SenderName: "木落", Content: ".rh"})
+	if reply == nil || !reply.Hidden || reply.PublicText != "木落进行了一次暗骰" {
+		t.Fatalf("unexpected hidden reply: %+v", reply)
+	}
+	reply = SealBotSolve(&SealBotRequest{SenderName: "木落", Content: ".ra 侦查 60"})
+	if reply == nil || !strings.Contains(reply.Text, "侦查检定: D100=") {
+		t.Fatalf("unexpected check reply: %+v", reply)
+	}
+	reply = SealBotSolve(&SealBotRequest{SenderName: "木落", Content: ".coc 2"})
+	if reply == nil || strings.Count(reply.Text, "\n") != 2 || !strings.Contains(reply.Text, "幸运:") {
+		t.Fatalf("unexpected coc reply: %+v", reply)
+	}
+}
+
+func TestSealBotInitiative(t *testing.T) {
+	initTestDB(t)
+	channelID := "chinit" + utils.NewIDWithLength(8)
+	SealBotSolve(&SealBotRequest{ChannelID: channelID, SenderName: "木落", Content: ".ri 15"})
+	SealBotSolve(&SealBotRequest{ChannelID: channelID, SenderName: "木落", Content: ".ri 18 怪物"})
+	SealBotSolve(&SealBotRequest{ChannelID: channelID, SenderName: "木落", Content: ".ri 12"})
+	reply := SealBotSolve(&SealBotRequest{ChannelID: channelID, Content: ".init"})
+	if reply == nil || !strings.Contains(reply.Text, "1. 怪物: 18\n2. 木落: 12") {
+		t.Fatalf("unexpected init list: %+v", reply)
+	}
+	reply = SealBotSolve(&SealBotRequest{ChannelID: channelID, Content: ".init del 怪物"})
+	if reply == nil || reply.Text != "已将怪物移出先攻列表" {
+		t.Fatalf("unexpected init del reply: %+v", reply)
+	}
+	SealBotSolve(&SealBotRequest{ChannelID: channelID, Content: ".init clr"})
+	reply = SealBotSolve(&SealBotRequest{ChannelID: channelID, Content: ".init"})
+	if reply == nil || reply.Text != "先攻列表为空，使用 .ri 加入" {
+		t.Fatalf("init list should be empty: %+v", reply)
+	}
+}
@@ -3,10 +3,12 @@ package storage
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"mime"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"sealchat/utils"
 )
@@ -190,6 +192,56 @@ func (m *Manager) PublicURL(backend BackendType, objectKey string) string {
 	}
 }
 
+// DirectUploadEnabled 附件是否允许客户端通过预签名 URL 直传 S3
+func (m *Manager) DirectUploadEnabled() bool {
+	return m != nil && m.cfg.S3.DirectUpload && m.ActiveBackendForAttachment() == BackendS3
+}
+
+// PrivateBucket 私有桶下载需要签发临时链接
+func (m *Manager) PrivateBucket() bool {
+	return m != nil && m.remote != nil && m.cfg.S3.PrivateBucket
+}
+
+// PresignTTL 预签名链接有效期
+func (m *Manager) PresignTTL() time.Duration {
+	ttl := m.cfg.S3.PresignTTL
+	if ttl <= 0 {
+		ttl = m.cfg.PresignTTL
+	}
+	if ttl <= 0 {
+		ttl = 900
+	}
+	return time.Duration(ttl) * time.Second
+}
+
+func (m *Manager) PresignedPutURL(ctx context.Context, objectKey string) (string, error) {
+	if m.remote == nil {
+		return "", fmt.Errorf("未启用 S3 存储")
+	}
+	return m.remote.presignPut(ctx, objectKey, m.PresignTTL())
+}
+
+func (m *Manager) PresignedGetURL(ctx context.Context, objectKey, filename, contentType string) (string, error) {
+	if m.remote == nil {
+		return "", fmt.Errorf("未启用 S3 存储")
+	}
+	return m.remote.presignGet(ctx, objectKey, m.PresignTTL(), filename, contentType)
+}
+
+func (m *Manager) StatRemote(ctx context.Context, objectKey string) (*ObjectInfo, error) {
+	if m.remote == nil {
+		return nil, fmt.Errorf("未启用 S3 存储")
+	}
+	return m.remote.stat(ctx, objectKey)
+}
+
+func (m *Manager) OpenRemote(ctx context.Context, objectKey string) (io.ReadCloser, error) {
+	if m.remote == nil {
+		return nil, fmt.Errorf("未启用 S3 存储")
+	}
+	return m.remote.open(ctx, objectKey)
+}
+
 func (m *Manager) ResolveLocalPath(objectKey string) (string, error) {
 	if m.local == nil {
 		return "", fmt.Errorf("本地存储未初始化")
@@ -103,6 +103,41 @@ func (s *s3Backend) delete(ctx context.Context, objectKey string) error {
 	return nil
 }
 
+func (s *s3Backend) presignPut(ctx context.Context, objectKey string, ttl time.Duration) (string, error) {
+	u, err := s.client.PresignedPutObject(ctx, s.bucket, objectKey, ttl)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (s *s3Backend) presignGet(ctx context.Context, objectKey string, ttl time.Duration, filename, contentType string) (string, error) {
+	params := url.Values{}
+	if strings.TrimSpace(filename) != "" {
+		params.Set("response-content-disposition", fmt.Sprintf("inline; filename*=UTF-8''%s", url.PathEscape(filename)))
+	}
+	if strings.TrimSpace(contentType) != "" {
+		params.Set("response-content-type", contentType)
+	}
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, objectKey, ttl, params)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (s *s3Backend) stat(ctx context.Context, objectKey string) (*ObjectInfo, error) {
+	info, err := s.client.StatObject(ctx, s.bucket, objectKey, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectInfo{Size: info.Size, ContentType: info.ContentType}, nil
+}
+
+func (s *s3Backend) open(ctx context.Context, objectKey string) (io.ReadCloser, error) {
+	return s.client.GetObject(ctx, s.bucket, objectKey, minio.GetObjectOptions{})
+}
+
 func (s *s3Backend) publicURL(objectKey string) string {
 	if s.publicBaseURL == "" {
 		return ""
@@ -29,6 +29,11 @@ type UploadResult struct {
 	PublicURL string
 }
 
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+}
+
 var unsafeNamePattern = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
 
 func BuildAttachmentObjectKey(hashHex string, size int64, now time.Time) string {
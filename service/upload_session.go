@@ -0,0 +1,623 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+	"golang.org/x/crypto/blake2s"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/service/storage"
+	"sealchat/utils"
+)
+
+var (
+	ErrUploadSessionNotFound  = errors.New("上传会话不存在")
+	ErrUploadSessionClosed    = errors.New("上传会话已结束")
+	ErrUploadSessionExpired   = errors.New("上传会话已过期")
+	ErrUploadOffsetMismatch   = errors.New("上传偏移与服务器记录不一致")
+	ErrUploadChunkTooLarge    = errors.New("分片超出声明的文件大小或分片上限")
+	ErrUploadChecksumMismatch = errors.New("文件校验失败，SHA-256 不一致")
+	ErrUploadTooLarge         = errors.New("文件大小超过限制")
+	ErrUploadMimeNotAllowed   = errors.New("不支持的文件类型")
+	ErrUploadMediaForbidden   = errors.New("没有在此频道发送视频或文件的权限")
+)
+
+// UploadSessionCreateInput 创建续传会话的参数
+type UploadSessionCreateInput struct {
+	Purpose   model.UploadSessionPurpose
+	Filename  string
+	MimeType  string
+	Size      int64
+	SHA256    string // 可选，完成时校验
+	Hash      string // 可选，附件使用的 blake2s 哈希，命中已有文件时直接秒传
+	ChannelID string
+	Extra     string
+	Audio     *AudioUploadOptions
+}
+
+// UploadSessionResult 会话完成后交接得到的对象
+type UploadSessionResult struct {
+	Attachment *model.AttachmentModel `json:"attachment,omitempty"`
+	AudioAsset *model.AudioAsset      `json:"audioAsset,omitempty"`
+}
+
+type uploadSessionOptions struct {
+	Extra string              `json:"extra,omitempty"`
+	Audio *AudioUploadOptions `json:"audio,omitempty"`
+}
+
+// 同一会话的分片必须串行写入
+var uploadSessionLocks sync.Map
+
+func lockUploadSession(id string) func() {
+	value, _ := uploadSessionLocks.LoadOrStore(id, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// finishUploadSession 将会话置为终态并释放对应的锁对象；持锁期间调用，之后的请求会读到终态直接返回
+func finishUploadSession(id string, status model.UploadSessionStatus, resultID, message string) error {
+	err := model.UploadSessionFinish(id, status, resultID, message)
+	uploadSessionLocks.Delete(id)
+	return err
+}
+
+func resumableUploadConfig() utils.ResumableUploadConfig {
+	if cfg := utils.GetConfig(); cfg != nil {
+		return cfg.ResumableUpload
+	}
+	return utils.ResumableUploadConfig{ChunkSizeMB: 8, SessionTTLMinutes: 24 * 60, TempDir: "./data/temp/resumable"}
+}
+
+// UploadChunkSizeLimit 单个分片允许的最大字节数
+func UploadChunkSizeLimit() int64 {
+	return resumableUploadConfig().ChunkSizeMB * 1024 * 1024
+}
+
+func uploadSessionTTL() time.Duration {
+	return time.Duration(resumableUploadConfig().SessionTTLMinutes) * time.Minute
+}
+
+// uploadSizeLimit 按用途与文件类型返回大小上限，0 表示不限制；附件类型未知时取图片与媒体上限中较大者，完成时再按实际类型复核
+func uploadSizeLimit(purpose model.UploadSessionPurpose, mimeType string) int64 {
+	cfg := utils.GetConfig()
+	if cfg == nil {
+		return 0
+	}
+	imageLimit := cfg.ImageSizeLimit * 1024
+	mediaLimit := cfg.Media.MaxUploadSizeMB * 1024 * 1024
+	switch {
+	case purpose == model.UploadPurposeAudio:
+		return cfg.Audio.MaxUploadSizeMB * 1024 * 1024
+	case strings.TrimSpace(mimeType) == "":
+		if imageLimit <= 0 || mediaLimit <= 0 {
+			return 0
+		}
+		return lo.Max([]int64{imageLimit, mediaLimit})
+	case AttachmentMediaKind(mimeType) == AttachmentKindImage:
+		return imageLimit
+	default:
+		return mediaLimit
+	}
+}
+
+// UploadSessionCreate 创建续传会话；附件若能按哈希命中已有文件，会话直接完成
+func UploadSessionCreate(user *model.UserModel, input UploadSessionCreateInput) (*model.UploadSessionModel, *UploadSessionResult, error) {
+	if input.Purpose == "" {
+		input.Purpose = model.UploadPurposeAttachment
+	}
+	if input.Purpose != model.UploadPurposeAttachment && input.Purpose != model.UploadPurposeAudio {
+		return nil, nil, fmt.Errorf("未知的上传用途: %s", input.Purpose)
+	}
+	if input.Size <= 0 {
+		return nil, nil, errors.New("文件大小无效")
+	}
+	if limit := uploadSizeLimit(input.Purpose, input.MimeType); limit > 0 && input.Size > limit {
+		return nil, nil, ErrUploadTooLarge
+	}
+	input.SHA256 = strings.ToLower(strings.TrimSpace(input.SHA256))
+	if input.SHA256 != "" {
+		if decoded, err := hex.DecodeString(input.SHA256); err != nil || len(decoded) != sha256.Size {
+			return nil, nil, errors.New("SHA-256 格式不正确")
+		}
+	}
+	if input.Purpose == model.UploadPurposeAudio && input.Audio == nil {
+		return nil, nil, errors.New("缺少音频素材参数")
+	}
+
+	optionsMap, err := encodeUploadSessionOptions(uploadSessionOptions{Extra: input.Extra, Audio: input.Audio})
+	if err != nil {
+		return nil, nil, err
+	}
+	session := &model.UploadSessionModel{
+		UserID:    user.ID,
+		Purpose:   input.Purpose,
+		Filename:  filepath.Base(strings.TrimSpace(input.Filename)),
+		MimeType:  strings.TrimSpace(input.MimeType),
+		TotalSize: input.Size,
+		SHA256:    input.SHA256,
+		ChannelID: strings.TrimSpace(input.ChannelID),
+		Options:   optionsMap,
+		Status:    model.UploadSessionPending,
+		ExpiresAt: time.Now().Add(uploadSessionTTL()),
+	}
+	session.StringPKBaseModel.Init()
+
+	if input.Purpose == model.UploadPurposeAttachment {
+		if existing := findReusableAttachment(input.Hash, input.SHA256, input.Size); existing != nil {
+			if !uploadAttachmentMimeAllowed(user.ID, session.ChannelID, existing.MimeType) {
+				return nil, nil, ErrUploadMediaForbidden
+			}
+			if limit := uploadSizeLimit(input.Purpose, existing.MimeType); limit > 0 && existing.Size > limit {
+				return nil, nil, ErrUploadTooLarge
+			}
+			att := cloneAttachmentForUser(existing, user, session)
+			if err := model.GetDB().Create(att).Error; err != nil {
+				return nil, nil, err
+			}
+			session.Offset = session.TotalSize
+			session.Status = model.UploadSessionCompleted
+			session.ResultID = att.ID
+			if err := model.GetDB().Create(session).Error; err != nil {
+				return nil, nil, err
+			}
+			return session, &UploadSessionResult{Attachment: att}, nil
+		}
+	}
+
+	dir := resumableUploadConfig().TempDir
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, nil, err
+	}
+	session.TempPath = filepath.Join(dir, session.ID+".part")
+	f, err := os.Create(session.TempPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	_ = f.Close()
+	if err := model.GetDB().Create(session).Error; err != nil {
+		_ = os.Remove(session.TempPath)
+		return nil, nil, err
+	}
+	return session, nil, nil
+}
+
+// UploadSessionAppend 在指定偏移处追加分片，写满后自动完成校验并交接
+func UploadSessionAppend(session *model.UploadSessionModel, offset int64, chunk io.Reader) (*model.UploadSessionModel, *UploadSessionResult, error) {
+	unlock := lockUploadSession(session.ID)
+	defer unlock()
+
+	// 拿到锁后重新读取，避免使用过期的偏移
+	current, err := model.UploadSessionGet(session.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if current == nil {
+		return nil, nil, ErrUploadSessionNotFound
+	}
+	if current.Status != model.UploadSessionPending {
+		return current, nil, ErrUploadSessionClosed
+	}
+	if current.Direct {
+		return current, nil, errors.New("直传会话请直接上传到签发的地址")
+	}
+	if time.Now().After(current.ExpiresAt) {
+		return current, nil, ErrUploadSessionExpired
+	}
+	if offset != current.Offset {
+		return current, nil, ErrUploadOffsetMismatch
+	}
+
+	remaining := current.TotalSize - current.Offset
+	maxChunk := remaining
+	if limit := UploadChunkSizeLimit(); limit > 0 && limit < maxChunk {
+		maxChunk = limit
+	}
+
+	f, err := os.OpenFile(current.TempPath, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return current, nil, err
+	}
+	// 丢弃上次中断时可能残留的半截数据
+	if err := f.Truncate(current.Offset); err != nil {
+		_ = f.Close()
+		return current, nil, err
+	}
+	if _, err := f.Seek(current.Offset, io.SeekStart); err != nil {
+		_ = f.Close()
+		return current, nil, err
+	}
+	written, copyErr := io.Copy(f, io.LimitReader(chunk, maxChunk+1))
+	if closeErr := f.Close(); copyErr == nil {
+		copyErr = closeErr
+	}
+	if copyErr == nil && written > maxChunk {
+		copyErr = ErrUploadChunkTooLarge
+	}
+	if copyErr != nil {
+		_ = os.Truncate(current.TempPath, current.Offset)
+		return current, nil, copyErr
+	}
+
+	newOffset := current.Offset + written
+	expiresAt := time.Now().Add(uploadSessionTTL())
+	ok, err := model.UploadSessionAdvanceOffset(current.ID, current.Offset, newOffset, expiresAt)
+	if err != nil {
+		return current, nil, err
+	}
+	if !ok {
+		return current, nil, ErrUploadOffsetMismatch
+	}
+	current.Offset = newOffset
+	current.ExpiresAt = expiresAt
+
+	if current.Offset < current.TotalSize {
+		return current, nil, nil
+	}
+	result, err := finalizeUploadSession(current)
+	if err != nil {
+		return current, nil, err
+	}
+	return current, result, nil
+}
+
+// UploadSessionCancel 取消会话并删除临时文件
+func UploadSessionCancel(session *model.UploadSessionModel) error {
+	unlock := lockUploadSession(session.ID)
+	defer unlock()
+	// 等锁期间会话可能已被最后一个分片完成或被清理
+	current, err := model.UploadSessionGet(session.ID)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return ErrUploadSessionNotFound
+	}
+	if current.Status != model.UploadSessionPending {
+		session.Status = current.Status
+		return ErrUploadSessionClosed
+	}
+	removeUploadSessionFile(current)
+	session.Status = model.UploadSessionCancelled
+	return finishUploadSession(current.ID, model.UploadSessionCancelled, "", "")
+}
+
+func finalizeUploadSession(session *model.UploadSessionModel) (*UploadSessionResult, error) {
+	fail := func(err error) (*UploadSessionResult, error) {
+		removeUploadSessionFile(session)
+		session.Status = model.UploadSessionFailed
+		session.ErrorMessage = err.Error()
+		_ = finishUploadSession(session.ID, model.UploadSessionFailed, "", err.Error())
+		return nil, err
+	}
+
+	sha, blake, size, err := hashUploadFile(session.TempPath)
+	if err != nil {
+		return fail(err)
+	}
+	if size != session.TotalSize {
+		return fail(fmt.Errorf("文件大小不一致: %d != %d", size, session.TotalSize))
+	}
+	if session.SHA256 != "" && session.SHA256 != hex.EncodeToString(sha) {
+		return fail(ErrUploadChecksumMismatch)
+	}
+	if session.SHA256 == "" {
+		session.SHA256 = hex.EncodeToString(sha)
+		_ = model.GetDB().Model(&model.UploadSessionModel{}).Where("id = ?", session.ID).Update("sha256", session.SHA256).Error
+	}
+
+	opts, err := decodeUploadSessionOptions(session.Options)
+	if err != nil {
+		return fail(err)
+	}
+
+	var result *UploadSessionResult
+	var resultID string
+	switch session.Purpose {
+	case model.UploadPurposeAudio:
+		audioOpts := *opts.Audio
+		if strings.TrimSpace(audioOpts.Name) == "" {
+			audioOpts.Name = strings.TrimSuffix(session.Filename, filepath.Ext(session.Filename))
+		}
+		asset, err := AudioCreateAssetFromImport(session.TempPath, audioOpts)
+		removeUploadSessionFile(session)
+		if err != nil {
+			return fail(err)
+		}
+		result = &UploadSessionResult{AudioAsset: asset}
+		resultID = asset.ID
+	default:
+		att, err := persistUploadSessionAttachment(session, opts, blake, size)
+		if err != nil {
+			return fail(err)
+		}
+		result = &UploadSessionResult{Attachment: att}
+		resultID = att.ID
+	}
+
+	session.Status = model.UploadSessionCompleted
+	session.ResultID = resultID
+	if err := finishUploadSession(session.ID, model.UploadSessionCompleted, resultID, ""); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func persistUploadSessionAttachment(session *model.UploadSessionModel, opts *uploadSessionOptions, hash []byte, size int64) (*model.AttachmentModel, error) {
+	mimeType, err := SniffAttachmentMime(session.TempPath)
+	if err != nil {
+		return nil, err
+	}
+	if !uploadAttachmentMimeAllowed(session.UserID, session.ChannelID, mimeType) {
+		return nil, fmt.Errorf("%w: %s", ErrUploadMimeNotAllowed, mimeType)
+	}
+	if limit := uploadSizeLimit(session.Purpose, mimeType); limit > 0 && size > limit {
+		return nil, ErrUploadTooLarge
+	}
+	previewEnabled := true
+	isAnimated := false
+	if cfg := utils.GetConfig(); cfg != nil {
+		previewEnabled = cfg.Media.PreviewEnabled
+		// 与普通图片上传一致，完成后按配置压缩为 WebP
+		if cfg.ImageCompress && shouldCompressImage(mimeType) {
+			if hash, size, mimeType, isAnimated, err = compressUploadSessionImage(session.TempPath, hash, size, mimeType, cfg.ImageCompressQuality); err != nil {
+				return nil, err
+			}
+		}
+	}
+	media := PrepareAttachmentMedia(session.TempPath, mimeType, hash, size, previewEnabled)
+	location, err := PersistAttachmentFile(hash, size, session.TempPath, mimeType)
+	if err != nil {
+		return nil, err
+	}
+	att := &model.AttachmentModel{
+		Filename:    session.Filename,
+		Size:        size,
+		Hash:        hash,
+		MimeType:    mimeType,
+		IsAnimated:  isAnimated,
+		MediaKind:   media.Kind,
+		Width:       media.Width,
+		Height:      media.Height,
+		Duration:    media.Duration,
+		PreviewText: media.PreviewText,
+		UserID:      session.UserID,
+		ChannelID:   session.ChannelID,
+		StorageType: location.StorageType,
+		ObjectKey:   location.ObjectKey,
+		ExternalURL: location.ExternalURL,
+		Extra:       opts.Extra,
+		IsTemp:      true,
+	}
+	if user := model.UserGet(session.UserID); user != nil {
+		att.CreatorName = user.Nickname
+		att.CreatorAvatar = user.Avatar
+	}
+	att.ID = utils.NewID()
+	if err := model.GetDB().Create(att).Error; err != nil {
+		return nil, err
+	}
+	return att, nil
+}
+
+// compressUploadSessionImage 压缩已写满的临时文件并原地替换，返回新的哈希、大小与类型；无法压缩时原样返回
+func compressUploadSessionImage(path string, hash []byte, size int64, mimeType string, quality int) ([]byte, int64, string, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, "", false, err
+	}
+	compressed, finalMime, ok, isAnimated, err := tryCompressImageData(data, mimeType, quality)
+	if err != nil {
+		return nil, 0, "", false, err
+	}
+	if !ok || len(compressed) == 0 {
+		return hash, size, mimeType, isAnimated, nil
+	}
+	newHash, err := blake2sHash(compressed)
+	if err != nil {
+		return nil, 0, "", false, err
+	}
+	if err := os.WriteFile(path, compressed, 0644); err != nil {
+		return nil, 0, "", false, err
+	}
+	return newHash, int64(len(compressed)), finalMime, isAnimated, nil
+}
+
+// uploadAttachmentMimeAllowed 图片可不绑定频道（头像、表情等）；其余附件必须指定频道，且需要媒体发送权限、类型在白名单内
+func uploadAttachmentMimeAllowed(userID, channelID, mimeType string) bool {
+	if AttachmentMediaKind(mimeType) == AttachmentKindImage {
+		return true
+	}
+	if channelID == "" || !pm.CanWithChannelRole(userID, channelID, pm.PermFuncChannelMediaSend) {
+		return false
+	}
+	cfg := utils.GetConfig()
+	if cfg == nil {
+		return true
+	}
+	return AttachmentMimeAllowed(mimeType, cfg.Media.AllowedMimeTypes)
+}
+
+func findReusableAttachment(hashHex, sha string, size int64) *model.AttachmentModel {
+	if hashHex = strings.TrimSpace(hashHex); hashHex != "" {
+		if hash, err := hex.DecodeString(hashHex); err == nil {
+			if existing, err := model.AttachmentFindByHashAndSize(hash, size); err == nil && existing != nil {
+				return existing
+			}
+		}
+	}
+	if sha == "" {
+		return nil
+	}
+	prev, err := model.UploadSessionFindCompletedBySHA256(sha, size)
+	if err != nil || prev == nil {
+		return nil
+	}
+	var att model.AttachmentModel
+	if err := model.GetDB().Where("id = ?", prev.ResultID).Limit(1).Find(&att).Error; err != nil || att.ID == "" {
+		return nil
+	}
+	return &att
+}
+
+func cloneAttachmentForUser(src *model.AttachmentModel, user *model.UserModel, session *model.UploadSessionModel) *model.AttachmentModel {
+	opts, _ := decodeUploadSessionOptions(session.Options)
+	att := &model.AttachmentModel{
+		Filename:      session.Filename,
+		Size:          src.Size,
+		Hash:          src.Hash,
+		MimeType:      src.MimeType,
+		IsAnimated:    src.IsAnimated,
+		MediaKind:     src.MediaKind,
+		Width:         src.Width,
+		Height:        src.Height,
+		Duration:      src.Duration,
+		PreviewText:   src.PreviewText,
+		UserID:        user.ID,
+		ChannelID:     session.ChannelID,
+		StorageType:   src.StorageType,
+		ObjectKey:     src.ObjectKey,
+		ExternalURL:   src.ExternalURL,
+		IsTemp:        true,
+		CreatorName:   user.Nickname,
+		CreatorAvatar: user.Avatar,
+	}
+	if att.Filename == "" {
+		att.Filename = src.Filename
+	}
+	if opts != nil {
+		att.Extra = opts.Extra
+	}
+	att.ID = utils.NewID()
+	return att
+}
+
+// hashUploadFile 一次读取同时计算 SHA-256（校验用）与 blake2s（附件去重用）
+func hashUploadFile(path string) ([]byte, []byte, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer f.Close()
+	shaHash := sha256.New()
+	blakeHash := lo.Must(blake2s.New256(nil))
+	size, err := io.Copy(io.MultiWriter(shaHash, blakeHash), f)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return shaHash.Sum(nil), blakeHash.Sum(nil), size, nil
+}
+
+func encodeUploadSessionOptions(opts uploadSessionOptions) (model.JSONMap, error) {
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return nil, err
+	}
+	var out model.JSONMap
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func decodeUploadSessionOptions(m model.JSONMap) (*uploadSessionOptions, error) {
+	opts := &uploadSessionOptions{}
+	if len(m) == 0 {
+		return opts, nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, opts); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+func removeUploadSessionFile(session *model.UploadSessionModel) {
+	if session.Direct && strings.TrimSpace(session.ObjectKey) != "" {
+		if manager := GetStorageManager(); manager != nil {
+			if err := manager.Delete(context.Background(), storage.BackendS3, session.ObjectKey); err != nil {
+				log.Printf("[upload] 删除直传对象失败 %s: %v", session.ObjectKey, err)
+			}
+		}
+		return
+	}
+	if strings.TrimSpace(session.TempPath) == "" {
+		return
+	}
+	if err := os.Remove(session.TempPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("[upload] 删除临时文件失败 %s: %v", session.TempPath, err)
+	}
+}
+
+var uploadSessionCleanupOnce sync.Once
+
+// StartUploadSessionCleanupWorker 定期清理过期会话的临时文件
+func StartUploadSessionCleanupWorker(interval time.Duration) {
+	uploadSessionCleanupOnce.Do(func() {
+		if interval <= 0 {
+			interval = 10 * time.Minute
+		}
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				runUploadSessionCleanup(time.Now())
+				<-ticker.C
+			}
+		}()
+	})
+}
+
+func runUploadSessionCleanup(now time.Time) {
+	for {
+		items, err := model.UploadSessionListExpired(now, 100)
+		if err != nil {
+			log.Printf("[upload] 查询过期会话失败: %v", err)
+			return
+		}
+		if len(items) == 0 {
+			break
+		}
+		finished := 0
+		for _, item := range items {
+			unlock := lockUploadSession(item.ID)
+			// 等锁期间可能刚好有分片写入并续期
+			if cur, err := model.UploadSessionGet(item.ID); err != nil || cur == nil ||
+				cur.Status != model.UploadSessionPending || cur.ExpiresAt.After(now) {
+				unlock()
+				continue
+			}
+			removeUploadSessionFile(item)
+			if err := finishUploadSession(item.ID, model.UploadSessionExpired, "", ErrUploadSessionExpired.Error()); err == nil {
+				finished++
+			}
+			unlock()
+		}
+		// 整批都被跳过时再查仍是同一批，留给下一轮处理
+		if len(items) < 100 || finished == 0 {
+			break
+		}
+	}
+	// 已结束的会话保留一段时间供客户端查询结果，同时用于按 SHA-256 秒传
+	if ret := model.UploadSessionPurgeFinished(now.Add(-30 * 24 * time.Hour)); ret.Error != nil {
+		log.Printf("[upload] 清理历史会话失败: %v", ret.Error)
+	}
+}
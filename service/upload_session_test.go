@@ -0,0 +1,156 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sealchat/model"
+)
+
+func newTestUploadSession(t *testing.T, content []byte, checksum string) *model.UploadSessionModel {
+	t.Helper()
+	initTestDB(t)
+	session := &model.UploadSessionModel{
+		UserID:    "upload-user",
+		Purpose:   model.UploadPurposeAttachment,
+		Filename:  "notes.txt",
+		TotalSize: int64(len(content)),
+		SHA256:    checksum,
+		Status:    model.UploadSessionPending,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	session.StringPKBaseModel.Init()
+	session.TempPath = filepath.Join(t.TempDir(), session.ID+".part")
+	if err := os.WriteFile(session.TempPath, nil, 0644); err != nil {
+		t.Fatalf("create temp file failed: %v", err)
+	}
+	if err := model.GetDB().Create(session).Error; err != nil {
+		t.Fatalf("create session failed: %v", err)
+	}
+	return session
+}
+
+func TestUploadSessionAppendResumesFromOffset(t *testing.T) {
+	content := []byte("hello, resumable world")
+	session := newTestUploadSession(t, content, "")
+
+	updated, result, err := UploadSessionAppend(session, 0, bytes.NewReader(content[:5]))
+	if err != nil {
+		t.Fatalf("append first chunk failed: %v", err)
+	}
+	if updated.Offset != 5 || result != nil {
+		t.Fatalf("unexpected state after first chunk: offset=%d result=%v", updated.Offset, result)
+	}
+
+	// 客户端断线后用旧偏移重发，应被拒绝并告知当前偏移
+	updated, _, err = UploadSessionAppend(session, 0, bytes.NewReader(content[:5]))
+	if !errors.Is(err, ErrUploadOffsetMismatch) {
+		t.Fatalf("expected offset mismatch, got %v", err)
+	}
+	if updated.Offset != 5 {
+		t.Fatalf("offset should stay at 5, got %d", updated.Offset)
+	}
+
+	// 超出声明大小的分片不应写入
+	oversized := append(append([]byte{}, content[5:]...), 'x')
+	if _, _, err = UploadSessionAppend(session, 5, bytes.NewReader(oversized)); !errors.Is(err, ErrUploadChunkTooLarge) {
+		t.Fatalf("expected chunk too large, got %v", err)
+	}
+	if info, err := os.Stat(session.TempPath); err != nil || info.Size() != 5 {
+		t.Fatalf("temp file should be truncated back to 5 bytes")
+	}
+}
+
+func TestUploadSessionChecksumMismatch(t *testing.T) {
+	content := []byte("checksum payload")
+	wrong := sha256.Sum256([]byte("something else"))
+	session := newTestUploadSession(t, content, hex.EncodeToString(wrong[:]))
+
+	_, _, err := UploadSessionAppend(session, 0, bytes.NewReader(content))
+	if !errors.Is(err, ErrUploadChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+	stored, err := model.UploadSessionGet(session.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("reload session failed: %v", err)
+	}
+	if stored.Status != model.UploadSessionFailed {
+		t.Fatalf("session should be failed, got %s", stored.Status)
+	}
+	if _, err := os.Stat(session.TempPath); !os.IsNotExist(err) {
+		t.Fatalf("temp file should be removed after checksum failure")
+	}
+}
+
+func TestUploadSessionRequiresChannelForMedia(t *testing.T) {
+	content := []byte("plain text without a channel")
+	session := newTestUploadSession(t, content, "")
+
+	if _, _, err := UploadSessionAppend(session, 0, bytes.NewReader(content)); !errors.Is(err, ErrUploadMimeNotAllowed) {
+		t.Fatalf("non-image upload without channel should be rejected, got %v", err)
+	}
+	if _, ok := uploadSessionLocks.Load(session.ID); ok {
+		t.Fatalf("lock entry should be released once the session is finished")
+	}
+	// 客户端持有的仍是旧状态，取消时应以数据库为准
+	if err := UploadSessionCancel(session); !errors.Is(err, ErrUploadSessionClosed) {
+		t.Fatalf("cancel after failure should report closed, got %v", err)
+	}
+}
+
+func TestUploadSessionOptionsRoundTrip(t *testing.T) {
+	folder := "folder-1"
+	encoded, err := encodeUploadSessionOptions(uploadSessionOptions{
+		Extra: "chat",
+		Audio: &AudioUploadOptions{Name: "bgm", FolderID: &folder, Tags: []string{"battle"}},
+	})
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	decoded, err := decodeUploadSessionOptions(encoded)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if decoded.Extra != "chat" || decoded.Audio == nil || decoded.Audio.Name != "bgm" ||
+		decoded.Audio.FolderID == nil || *decoded.Audio.FolderID != folder || len(decoded.Audio.Tags) != 1 {
+		t.Fatalf("options not preserved: %+v", decoded)
+	}
+}
+
+func TestHashRemoteObjectKeepsHeadForSniffing(t *testing.T) {
+	payload := bytes.Repeat([]byte("%PDF-1.7\n"), 1000)
+	hash, sha, head, size, err := hashRemoteObject(bytes.NewReader(payload))
+	if err != nil {
+		t.Fatalf("hash failed: %v", err)
+	}
+	if size != int64(len(payload)) {
+		t.Fatalf("size = %d, want %d", size, len(payload))
+	}
+	if len(head) != 3072 || !bytes.Equal(head, payload[:3072]) {
+		t.Fatalf("head should keep the first 3072 bytes, got %d", len(head))
+	}
+	wantSHA := sha256.Sum256(payload)
+	if !bytes.Equal(sha, wantSHA[:]) || len(hash) != 32 {
+		t.Fatalf("unexpected digests")
+	}
+}
+
+func TestCanUserAccessAttachmentWithoutChannel(t *testing.T) {
+	att := &model.AttachmentModel{UserID: "uploader"}
+	if !CanUserAccessAttachment("uploader", att) {
+		t.Fatalf("uploader should always access own attachment")
+	}
+	if CanUserAccessAttachment("someone-else", att) || CanUserAccessAttachment("", att) {
+		t.Fatalf("attachment without channel should be owner-only")
+	}
+	att.ChannelID = attachmentAvatarChannelID
+	if !CanUserAccessAttachment("", att) {
+		t.Fatalf("avatars should stay public")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 	"unicode/utf8"
 
+	"github.com/samber/lo"
 	"gorm.io/gorm"
 
 	"sealchat/model"
@@ -43,6 +44,14 @@ type WorldUpdateParams struct {
 	AllowAdminEditMessages *bool
 	AllowMemberEditKeywords *bool
 	CharacterCardBadgeTemplate *string
+	JoinMode                *string
+	ApplicationForm         *[]model.WorldApplicationFormField
+	Tags                    *[]string
+	Language                *string
+	Genre                   *string
+	RecruitingStatus        *string
+	CoverImage              *string
+	DirectoryHidden         *bool
 }
 
 func normalizeWorldDescription(desc string) (string, error) {
@@ -151,6 +160,7 @@ func ensureWorldOwnerRole(worldID, ownerID string) error {
 		return err
 	}
 	if member.Role != model.WorldRoleOwner {
+		defer model.PermCacheInvalidateUser(ownerID)
 		return db.Model(&model.WorldMemberModel{}).Where("id = ?", member.ID).Update("role", model.WorldRoleOwner).Error
 	}
 	return nil
@@ -302,6 +312,24 @@ func WorldUpdate(worldID, actorID string, params WorldUpdateParams) (*model.Worl
 		}
 		updates["character_card_badge_template"] = template
 	}
+	if params.JoinMode != nil {
+		switch *params.JoinMode {
+		case model.WorldJoinModeOpen, model.WorldJoinModeApplication:
+			updates["join_mode"] = *params.JoinMode
+		default:
+			return nil, fmt.Errorf("%w：未知的加入方式 %s", ErrWorldApplicationForm, *params.JoinMode)
+		}
+	}
+	if params.ApplicationForm != nil {
+		form, err := normalizeWorldApplicationForm(*params.ApplicationForm)
+		if err != nil {
+			return nil, err
+		}
+		updates["application_form"] = form
+	}
+	if err := applyWorldDirectoryUpdates(world, actorID, params, updates); err != nil {
+		return nil, err
+	}
 	if len(updates) > 0 {
 		updates["updated_at"] = time.Now()
 		if err := model.GetDB().Model(world).Updates(updates).Error; err != nil {
@@ -311,6 +339,10 @@ func WorldUpdate(worldID, actorID string, params WorldUpdateParams) (*model.Worl
 	if err := model.GetDB().Where("id = ? AND status = ?", worldID, "active").Limit(1).Find(world).Error; err != nil {
 		return nil, err
 	}
+	if text := world.BuildSearchText(); text != world.SearchText {
+		world.SearchText = text
+		_ = model.GetDB().Model(&model.WorldModel{}).Where("id = ?", world.ID).Update("search_text", text).Error
+	}
 	return world, nil
 }
 
@@ -332,6 +364,7 @@ func WorldDelete(worldID, actorID string) error {
 	if !IsWorldOwner(worldID, actorID) {
 		return ErrWorldPermission
 	}
+	defer model.PermCacheInvalidateAll()
 	return db.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Model(&model.WorldModel{}).
 			Where("id = ?", worldID).
@@ -363,7 +396,13 @@ func WorldDelete(worldID, actorID string) error {
 	})
 }
 
+// WorldJoin 不带客户端 IP 加入世界，用于系统或管理员代为加入
 func WorldJoin(worldID, userID, role string) (*model.WorldMemberModel, error) {
+	return WorldJoinWithIP(worldID, userID, role, "")
+}
+
+// WorldJoinWithIP 用户主动加入世界，ip 用于匹配 IP 封禁
+func WorldJoinWithIP(worldID, userID, role, ip string) (*model.WorldMemberModel, error) {
 	role = normalizeWorldRole(role)
 	db := model.GetDB()
 	var world model.WorldModel
@@ -383,6 +422,9 @@ func WorldJoin(worldID, userID, role string) (*model.WorldMemberModel, error) {
 		}
 		return member, nil
 	}
+	if err := WorldBanCheck(worldID, userID, ip); err != nil {
+		return nil, err
+	}
 	member = &model.WorldMemberModel{
 		WorldID:  worldID,
 		UserID:   userID,
@@ -392,6 +434,7 @@ func WorldJoin(worldID, userID, role string) (*model.WorldMemberModel, error) {
 	if err := db.Create(member).Error; err != nil {
 		return nil, err
 	}
+	model.PermCacheInvalidateUser(userID)
 	if _, err := ensureWorldMemberChannelState(worldID, userID, role); err != nil {
 		return member, err
 	}
@@ -403,12 +446,19 @@ func WorldLeave(worldID, userID string) error {
 		return errors.New("世界拥有者无法退出，请先转移所有权或删除世界")
 	}
 	db := model.GetDB()
+	defer model.PermCacheInvalidateUser(userID)
 	if err := db.Where("world_id = ? AND user_id = ?", worldID, userID).Delete(&model.WorldMemberModel{}).Error; err != nil {
 		return err
 	}
 	if err := revokeWorldChannelRoles(worldID, userID); err != nil {
 		return err
 	}
+	if err := revokeCustomWorldRoles(worldID, userID); err != nil {
+		return err
+	}
+	if err := model.ChannelPermOverwriteDeleteByWorldUser(worldID, userID); err != nil {
+		return err
+	}
 	_ = db.Where("world_id = ? AND user_id = ?", worldID, userID).Delete(&model.WorldFavoriteModel{})
 	return nil
 }
@@ -428,16 +478,27 @@ func IsWorldMember(worldID, userID string) bool {
 	return worldRoleEquals(worldID, userID, "")
 }
 
+type worldMemberRole struct {
+	member bool
+	role   string
+}
+
+// worldRoleCache 缓存用户在世界中的成员身份与角色
+var worldRoleCache = model.NewPermCache[worldMemberRole]("world_roles")
+
 func worldRoleEquals(worldID, userID, role string) bool {
-	var member model.WorldMemberModel
-	err := model.GetDB().Where("world_id = ? AND user_id = ?", worldID, userID).Limit(1).Find(&member).Error
-	if err != nil || member.ID == "" {
+	item, err := worldRoleCache.Load(userID, userID+"|"+worldID, func() (worldMemberRole, error) {
+		var member model.WorldMemberModel
+		err := model.GetDB().Where("world_id = ? AND user_id = ?", worldID, userID).Limit(1).Find(&member).Error
+		return worldMemberRole{member: member.ID != "", role: member.Role}, err
+	})
+	if err != nil || !item.member {
 		return false
 	}
 	if role == "" {
 		return true
 	}
-	return member.Role == role
+	return item.role == role
 }
 
 func ListWorldMembers(worldID string, limit int) ([]*model.WorldMemberModel, error) {
@@ -461,6 +522,8 @@ type WorldMemberDetail struct {
 	Username string    `json:"username"`
 	Nickname string    `json:"nickname"`
 	Avatar   string    `json:"avatar"`
+	// CustomRoleIDs 持有的世界自定义角色
+	CustomRoleIDs []string `json:"customRoleIds"`
 }
 
 func ListWorldMembersDetail(worldID string, page, pageSize int, keyword string) ([]*WorldMemberDetail, int64, error) {
@@ -514,6 +577,13 @@ func ListWorldMembersDetail(worldID string, page, pageSize int, keyword string)
 			Avatar:   row.Avatar,
 		})
 	}
+	roleMap, err := WorldRoleIDsByUsers(worldID, lo.Map(result, func(item *WorldMemberDetail, _ int) string { return item.UserID }))
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, item := range result {
+		item.CustomRoleIDs = roleMap[item.UserID]
+	}
 	return result, total, nil
 }
 
@@ -576,6 +646,7 @@ func WorldUpdateMemberRole(worldID, actorID, targetUserID, role string) error {
 		return ErrWorldOwnerImmutable
 	}
 	db := model.GetDB()
+	defer model.PermCacheInvalidateUser(targetUserID)
 	res := db.Model(&model.WorldMemberModel{}).
 		Where("world_id = ? AND user_id = ?", worldID, targetUserID).
 		Updates(map[string]any{"role": role, "updated_at": time.Now()})
@@ -609,6 +680,7 @@ func syncWorldRolesForNewChannel(worldID, channelID string) {
 	if worldID == "" || channelID == "" {
 		return
 	}
+	defer syncCustomWorldRolesForNewChannel(worldID, channelID)
 	adminIDs, err := listWorldUserIDsByRoles(worldID, model.WorldRoleOwner, model.WorldRoleAdmin)
 	if err == nil {
 		for _, uid := range adminIDs {
@@ -840,7 +912,7 @@ func WorldInviteCreate(worldID, creatorID string, ttlMinutes int, maxUse int, me
 	return invite, nil
 }
 
-func WorldInviteConsume(slug, userID string) (*model.WorldInviteModel, *model.WorldModel, *model.WorldMemberModel, bool, error) {
+func WorldInviteConsume(slug, userID, ip string) (*model.WorldInviteModel, *model.WorldModel, *model.WorldMemberModel, bool, error) {
 	slug = strings.TrimSpace(slug)
 	if slug == "" {
 		return nil, nil, nil, false, ErrWorldInviteInvalid
@@ -875,7 +947,7 @@ func WorldInviteConsume(slug, userID string) (*model.WorldInviteModel, *model.Wo
 	_ = db.Where("world_id = ? AND user_id = ?", invite.WorldID, userID).Limit(1).Find(existingMember).Error
 	wasMember := existingMember.ID != ""
 	role := normalizeWorldRole(invite.Role)
-	member, err := WorldJoin(invite.WorldID, userID, role)
+	member, err := WorldJoinWithIP(invite.WorldID, userID, role, ip)
 	if err != nil {
 		return nil, nil, nil, false, err
 	}
@@ -0,0 +1,453 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+
+	"sealchat/model"
+	"sealchat/utils"
+)
+
+// 世界加入申请：JoinMode 为 application 时，非成员需要填写拥有者定义的表单并等待管理员审核。
+// 管理员可以通过（并指定角色）、拒绝（附理由）或要求补充信息，申请人通过时间线与邮件收到结果。
+
+var (
+	ErrWorldApplicationNotFound = errors.New("申请不存在")
+	ErrWorldApplicationClosed   = errors.New("该申请已处理")
+	ErrWorldApplicationDisabled = errors.New("该世界未开启申请加入")
+	ErrWorldApplicationForm     = errors.New("申请表单无效")
+	ErrWorldApplicationCooldown = errors.New("申请提交过于频繁")
+)
+
+const (
+	worldApplicationMaxFields     = 20
+	worldApplicationDefaultMaxLen = 500
+	worldApplicationMaxLen        = 2000
+	worldApplicationMaxNote       = 1000
+
+	// worldApplicationRetryCooldown 申请被拒绝或撤回后，需等待这么久才能再次申请
+	worldApplicationRetryCooldown = 24 * time.Hour
+	// worldApplicationUpdateCooldown 待审核的申请两次修改之间的间隔，避免反复提交刷屏管理员的时间线
+	worldApplicationUpdateCooldown = 5 * time.Minute
+
+	WorldApplicationActionApprove     = "approve"
+	WorldApplicationActionReject      = "reject"
+	WorldApplicationActionRequestInfo = "request_info"
+)
+
+type WorldApplicationReviewParams struct {
+	Action string `json:"action"` // approve/reject/request_info
+	Role   string `json:"role"`
+	Note   string `json:"note"`
+}
+
+// normalizeWorldApplicationForm 校验并补全表单定义
+func normalizeWorldApplicationForm(fields []model.WorldApplicationFormField) (model.JSONList[model.WorldApplicationFormField], error) {
+	if len(fields) > worldApplicationMaxFields {
+		return nil, fmt.Errorf("%w：最多 %d 项", ErrWorldApplicationForm, worldApplicationMaxFields)
+	}
+	result := make(model.JSONList[model.WorldApplicationFormField], 0, len(fields))
+	seen := map[string]bool{}
+	for i, field := range fields {
+		field.Key = strings.TrimSpace(field.Key)
+		if field.Key == "" {
+			field.Key = fmt.Sprintf("q%d", i+1)
+		}
+		if seen[field.Key] {
+			return nil, fmt.Errorf("%w：表单项标识重复 %s", ErrWorldApplicationForm, field.Key)
+		}
+		seen[field.Key] = true
+		field.Label = strings.TrimSpace(field.Label)
+		if field.Label == "" {
+			return nil, fmt.Errorf("%w：第 %d 项缺少标题", ErrWorldApplicationForm, i+1)
+		}
+		switch field.Type {
+		case "", "text":
+			field.Type = "text"
+		case "textarea":
+		case "select":
+			field.Options = lo.Uniq(lo.Filter(lo.Map(field.Options, func(o string, _ int) string {
+				return strings.TrimSpace(o)
+			}), func(o string, _ int) bool { return o != "" }))
+			if len(field.Options) == 0 {
+				return nil, fmt.Errorf("%w：「%s」至少需要一个选项", ErrWorldApplicationForm, field.Label)
+			}
+		default:
+			return nil, fmt.Errorf("%w：不支持的表单项类型 %s", ErrWorldApplicationForm, field.Type)
+		}
+		if field.Type != "select" {
+			field.Options = nil
+		}
+		if field.MaxLength <= 0 {
+			field.MaxLength = worldApplicationDefaultMaxLen
+		}
+		if field.MaxLength > worldApplicationMaxLen {
+			field.MaxLength = worldApplicationMaxLen
+		}
+		result = append(result, field)
+	}
+	return result, nil
+}
+
+// buildWorldApplicationAnswers 按表单顺序整理回答，同时保存题目快照，表单之后修改也不影响已提交的申请
+func buildWorldApplicationAnswers(form []model.WorldApplicationFormField, answers map[string]string) (model.JSONList[model.WorldApplicationAnswer], error) {
+	result := make(model.JSONList[model.WorldApplicationAnswer], 0, len(form))
+	for _, field := range form {
+		value := strings.TrimSpace(answers[field.Key])
+		if value == "" {
+			if field.Required {
+				return nil, fmt.Errorf("请填写「%s」", field.Label)
+			}
+			continue
+		}
+		maxLen := field.MaxLength
+		if maxLen <= 0 {
+			maxLen = worldApplicationDefaultMaxLen
+		}
+		if len([]rune(value)) > maxLen {
+			return nil, fmt.Errorf("「%s」不能超过 %d 字", field.Label, maxLen)
+		}
+		if field.Type == "select" && !lo.Contains(field.Options, value) {
+			return nil, fmt.Errorf("「%s」的选项无效", field.Label)
+		}
+		result = append(result, model.WorldApplicationAnswer{Key: field.Key, Label: field.Label, Value: value})
+	}
+	return result, nil
+}
+
+// worldApplicationCheckCooldown 检查申请人距上次提交或被处理是否已过冷却时间
+func worldApplicationCheckCooldown(db *gorm.DB, worldID, userID string, now time.Time) error {
+	var last model.WorldJoinApplicationModel
+	if err := db.Where("world_id = ? AND user_id = ?", worldID, userID).
+		Order("updated_at DESC").Limit(1).Find(&last).Error; err != nil {
+		return err
+	}
+	if last.ID == "" {
+		return nil
+	}
+	var cooldown time.Duration
+	switch last.Status {
+	case model.WorldApplicationRejected, model.WorldApplicationWithdrawn:
+		cooldown = worldApplicationRetryCooldown
+	case model.WorldApplicationPending:
+		cooldown = worldApplicationUpdateCooldown
+	default:
+		// 管理员要求补充信息时可以立即修改
+		return nil
+	}
+	wait := last.UpdatedAt.Add(cooldown).Sub(now)
+	if wait <= 0 {
+		return nil
+	}
+	if wait >= time.Hour {
+		return fmt.Errorf("%w，请在 %d 小时后再试", ErrWorldApplicationCooldown, int(math.Ceil(wait.Hours())))
+	}
+	return fmt.Errorf("%w，请在 %d 分钟后再试", ErrWorldApplicationCooldown, int(math.Ceil(wait.Minutes())))
+}
+
+func worldApplicationGetOpen(db *gorm.DB, worldID, userID string) (*model.WorldJoinApplicationModel, error) {
+	var app model.WorldJoinApplicationModel
+	err := db.Where("world_id = ? AND user_id = ? AND status IN ?", worldID, userID,
+		[]string{model.WorldApplicationPending, model.WorldApplicationInfoRequested}).
+		Order("created_at DESC").Limit(1).Find(&app).Error
+	if err != nil {
+		return nil, err
+	}
+	if app.ID == "" {
+		return nil, nil
+	}
+	return &app, nil
+}
+
+// WorldApplicationSubmit 提交或补充申请；已有进行中的申请时更新回答并重新进入待审核
+func WorldApplicationSubmit(worldID, userID string, answers map[string]string, ip string) (*model.WorldJoinApplicationModel, error) {
+	world, err := GetWorldByID(worldID)
+	if err != nil {
+		return nil, err
+	}
+	if world.Status != "active" {
+		return nil, ErrWorldNotFound
+	}
+	if world.JoinMode != model.WorldJoinModeApplication {
+		return nil, ErrWorldApplicationDisabled
+	}
+	if world.Visibility == model.WorldVisibilityPrivate {
+		return nil, errors.New("该世界仅通过邀请加入")
+	}
+	if IsWorldMember(world.ID, userID) {
+		return nil, errors.New("你已是该世界成员")
+	}
+	if err := WorldBanCheck(world.ID, userID, ip); err != nil {
+		return nil, err
+	}
+	items, err := buildWorldApplicationAnswers(world.ApplicationForm, answers)
+	if err != nil {
+		return nil, err
+	}
+
+	db := model.GetDB()
+	if err := worldApplicationCheckCooldown(db, world.ID, userID, time.Now()); err != nil {
+		return nil, err
+	}
+	app, err := worldApplicationGetOpen(db, world.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if app != nil {
+		app.Answers = items
+		app.Status = model.WorldApplicationPending
+		if err := db.Model(app).Updates(map[string]any{
+			"answers":    app.Answers,
+			"status":     app.Status,
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+			return nil, err
+		}
+	} else {
+		app = &model.WorldJoinApplicationModel{
+			WorldID: world.ID,
+			UserID:  userID,
+			Status:  model.WorldApplicationPending,
+			Answers: items,
+		}
+		if err := db.Create(app).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	applicant := model.UserGet(userID)
+	name := worldApplicationUserName(applicant)
+	adminIDs, _ := WorldApplicationReviewerIDs(world.ID)
+	worldApplicationTimeline(adminIDs, userID, world, app,
+		fmt.Sprintf("%s 申请加入「%s」", name, world.Name), "有新的加入申请待审核")
+	return app, nil
+}
+
+// WorldApplicationReviewerIDs 能审核申请的用户（世界拥有者与管理员）
+func WorldApplicationReviewerIDs(worldID string) ([]string, error) {
+	return listWorldUserIDsByRoles(worldID, model.WorldRoleOwner, model.WorldRoleAdmin)
+}
+
+// WorldApplicationWithdraw 申请人撤回进行中的申请
+func WorldApplicationWithdraw(worldID, userID string) (*model.WorldJoinApplicationModel, error) {
+	db := model.GetDB()
+	app, err := worldApplicationGetOpen(db, worldID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if app == nil {
+		return nil, ErrWorldApplicationNotFound
+	}
+	app.Status = model.WorldApplicationWithdrawn
+	if err := db.Model(app).Updates(map[string]any{"status": app.Status, "updated_at": time.Now()}).Error; err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// WorldApplicationReview 管理员审核申请
+func WorldApplicationReview(worldID, applicationID, actorID string, params WorldApplicationReviewParams) (*model.WorldJoinApplicationModel, error) {
+	world, err := GetWorldByID(worldID)
+	if err != nil {
+		return nil, err
+	}
+	if !IsWorldAdmin(world.ID, actorID) {
+		return nil, ErrWorldPermission
+	}
+	db := model.GetDB()
+	var app model.WorldJoinApplicationModel
+	if err := db.Where("id = ? AND world_id = ?", applicationID, world.ID).Limit(1).Find(&app).Error; err != nil {
+		return nil, err
+	}
+	if app.ID == "" {
+		return nil, ErrWorldApplicationNotFound
+	}
+	if !app.IsOpen() {
+		return nil, ErrWorldApplicationClosed
+	}
+
+	note := strings.TrimSpace(params.Note)
+	if len([]rune(note)) > worldApplicationMaxNote {
+		return nil, fmt.Errorf("备注不能超过 %d 字", worldApplicationMaxNote)
+	}
+	now := time.Now()
+	updates := map[string]any{
+		"reviewer_id": actorID,
+		"review_note": note,
+		"reviewed_at": &now,
+		"updated_at":  now,
+	}
+	var title, brief string
+	switch params.Action {
+	case WorldApplicationActionApprove:
+		role := normalizeWorldRole(params.Role)
+		if role == model.WorldRoleOwner {
+			return nil, errors.New("不能以拥有者身份通过申请")
+		}
+		if role == model.WorldRoleAdmin && !IsWorldOwner(world.ID, actorID) {
+			return nil, errors.New("只有世界拥有者可以授予管理员角色")
+		}
+		if _, err := WorldJoin(world.ID, app.UserID, role); err != nil {
+			return nil, err
+		}
+		app.Status = model.WorldApplicationApproved
+		app.ApprovedRole = role
+		updates["approved_role"] = role
+		title = fmt.Sprintf("你加入「%s」的申请已通过", world.Name)
+		brief = note
+	case WorldApplicationActionReject:
+		app.Status = model.WorldApplicationRejected
+		title = fmt.Sprintf("你加入「%s」的申请未通过", world.Name)
+		brief = note
+	case WorldApplicationActionRequestInfo:
+		if note == "" {
+			return nil, errors.New("请说明需要补充的信息")
+		}
+		app.Status = model.WorldApplicationInfoRequested
+		title = fmt.Sprintf("「%s」的管理员请你补充申请信息", world.Name)
+		brief = note
+	default:
+		return nil, fmt.Errorf("未知的审核操作: %s", params.Action)
+	}
+	updates["status"] = app.Status
+	if err := db.Model(&app).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	app.ReviewerID = actorID
+	app.ReviewNote = note
+	app.ReviewedAt = &now
+
+	worldApplicationTimeline([]string{app.UserID}, actorID, world, &app, title, brief)
+	go worldApplicationSendEmail(app.UserID, world, title, brief)
+	return &app, nil
+}
+
+// WorldApplicationList 管理员查看申请列表，status 为空时返回进行中的申请
+func WorldApplicationList(worldID, actorID, status string, page, pageSize int) ([]*model.WorldJoinApplicationModel, int64, error) {
+	if !IsWorldAdmin(worldID, actorID) {
+		return nil, 0, ErrWorldPermission
+	}
+	items, total, err := utils.QueryPaginatedList(model.GetDB(), page, pageSize, &model.WorldJoinApplicationModel{}, func(q *gorm.DB) *gorm.DB {
+		q = q.Where("world_id = ?", worldID)
+		if status = strings.TrimSpace(status); status != "" && status != "all" {
+			q = q.Where("status = ?", status)
+		} else if status == "" {
+			q = q.Where("status IN ?", []string{model.WorldApplicationPending, model.WorldApplicationInfoRequested})
+		}
+		return q.Order("updated_at DESC")
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	hydrateWorldApplicationUsers(items)
+	return items, total, nil
+}
+
+// WorldApplicationGetMine 申请人查看自己在该世界最近的一条申请
+func WorldApplicationGetMine(worldID, userID string) (*model.WorldJoinApplicationModel, error) {
+	var app model.WorldJoinApplicationModel
+	if err := model.GetDB().Where("world_id = ? AND user_id = ?", worldID, userID).
+		Order("created_at DESC").Limit(1).Find(&app).Error; err != nil {
+		return nil, err
+	}
+	if app.ID == "" {
+		return nil, nil
+	}
+	return &app, nil
+}
+
+func hydrateWorldApplicationUsers(items []*model.WorldJoinApplicationModel) {
+	if len(items) == 0 {
+		return
+	}
+	ids := make([]string, 0, len(items)*2)
+	for _, item := range items {
+		ids = append(ids, item.UserID)
+		if item.ReviewerID != "" {
+			ids = append(ids, item.ReviewerID)
+		}
+	}
+	var users []*model.UserModel
+	model.GetDB().Where("id IN ?", lo.Uniq(ids)).
+		Select("id, username, nickname, avatar").
+		Find(&users)
+	byID := lo.SliceToMap(users, func(u *model.UserModel) (string, *model.UserModel) { return u.ID, u })
+	for _, item := range items {
+		item.User = byID[item.UserID]
+		item.Reviewer = byID[item.ReviewerID]
+	}
+}
+
+func worldApplicationUserName(user *model.UserModel) string {
+	if user == nil {
+		return "有人"
+	}
+	if user.Nickname != "" {
+		return user.Nickname
+	}
+	return user.Username
+}
+
+func worldApplicationTimeline(receiverIDs []string, senderID string, world *model.WorldModel, app *model.WorldJoinApplicationModel, title, brief string) {
+	items := make([]*model.TimelineModel, 0, len(receiverIDs))
+	for _, id := range lo.Uniq(receiverIDs) {
+		if strings.TrimSpace(id) == "" || id == senderID {
+			continue
+		}
+		items = append(items, &model.TimelineModel{
+			StringPKBaseModel: model.StringPKBaseModel{ID: utils.NewID()},
+			Type:              "world.application",
+			Title:             title,
+			Brief:             brief,
+			UserID:            senderID,
+			SenderId:          senderID,
+			ReceiverId:        id,
+			LocPostType:       "world",
+			LocPostID:         world.ID,
+			RelatedType:       "world_application",
+			RelatedID:         app.ID,
+		})
+	}
+	if len(items) == 0 {
+		return
+	}
+	if err := model.GetDB().CreateInBatches(items, 50).Error; err != nil {
+		log.Printf("world-application: 写入时间线失败: %v", err)
+	}
+}
+
+func worldApplicationSendEmail(userID string, world *model.WorldModel, title, note string) {
+	cfg := utils.GetConfig()
+	if cfg == nil {
+		return
+	}
+	user := model.UserGet(userID)
+	if user == nil || user.Email == nil || strings.TrimSpace(*user.Email) == "" || !user.EmailVerified {
+		return
+	}
+	svc := NewEmailService(cfg.EmailNotification.SMTP)
+	if !svc.IsConfigured() {
+		return
+	}
+	var sb strings.Builder
+	sb.WriteString(`<!DOCTYPE html><html><head><meta charset="UTF-8"></head><body style="font-family: sans-serif;">`)
+	sb.WriteString("<h3>" + escapeHTML(title) + "</h3>")
+	if note != "" {
+		sb.WriteString("<p>" + escapeHTML(note) + "</p>")
+	}
+	if siteURL := normalizeSiteURL(cfg.Domain); siteURL != "" {
+		link := fmt.Sprintf("%s/#/worlds/%s", strings.TrimRight(siteURL, "/"), world.ID)
+		sb.WriteString(fmt.Sprintf(`<p><a href="%s">前往世界「%s」</a></p>`, escapeHTML(link), escapeHTML(world.Name)))
+	}
+	sb.WriteString("</body></html>")
+	if err := svc.SendEmail(*user.Email, "SealChat "+title, sb.String()); err != nil {
+		log.Printf("world-application: 发送邮件失败: %v", err)
+	}
+}
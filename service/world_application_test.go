@@ -0,0 +1,136 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/utils"
+)
+
+func TestNormalizeWorldApplicationForm(t *testing.T) {
+	form, err := normalizeWorldApplicationForm([]model.WorldApplicationFormField{
+		{Label: " 跑团经验 ", Required: true},
+		{Key: "system", Label: "常玩规则", Type: "select", Options: []string{"CoC", " DnD ", "CoC", ""}},
+		{Key: "intro", Label: "自我介绍", Type: "textarea", MaxLength: 99999},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if form[0].Key != "q1" || form[0].Label != "跑团经验" || form[0].Type != "text" || form[0].MaxLength != worldApplicationDefaultMaxLen {
+		t.Fatalf("unexpected first field: %+v", form[0])
+	}
+	if len(form[1].Options) != 2 || form[1].Options[1] != "DnD" {
+		t.Fatalf("select options should be trimmed and deduplicated: %+v", form[1].Options)
+	}
+	if form[2].MaxLength != worldApplicationMaxLen {
+		t.Fatalf("max length should be capped: %d", form[2].MaxLength)
+	}
+
+	for _, bad := range [][]model.WorldApplicationFormField{
+		{{Label: ""}},
+		{{Key: "a", Label: "A"}, {Key: "a", Label: "B"}},
+		{{Label: "A", Type: "select"}},
+		{{Label: "A", Type: "file"}},
+	} {
+		if _, err := normalizeWorldApplicationForm(bad); !errors.Is(err, ErrWorldApplicationForm) {
+			t.Fatalf("expected form error for %+v, got %v", bad, err)
+		}
+	}
+}
+
+func TestBuildWorldApplicationAnswers(t *testing.T) {
+	form, _ := normalizeWorldApplicationForm([]model.WorldApplicationFormField{
+		{Key: "exp", Label: "跑团经验", Required: true, MaxLength: 4},
+		{Key: "system", Label: "常玩规则", Type: "select", Options: []string{"CoC", "DnD"}},
+		{Key: "note", Label: "备注"},
+	})
+
+	answers, err := buildWorldApplicationAnswers(form, map[string]string{"exp": " 三年 ", "system": "CoC", "unknown": "x"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(answers) != 2 || answers[0].Value != "三年" || answers[0].Label != "跑团经验" || answers[1].Key != "system" {
+		t.Fatalf("unexpected answers: %+v", answers)
+	}
+
+	if _, err := buildWorldApplicationAnswers(form, map[string]string{}); err == nil {
+		t.Fatalf("missing required answer should fail")
+	}
+	if _, err := buildWorldApplicationAnswers(form, map[string]string{"exp": "五年以上了"}); err == nil {
+		t.Fatalf("too long answer should fail")
+	}
+	if _, err := buildWorldApplicationAnswers(form, map[string]string{"exp": "一年", "system": "PF2e"}); err == nil {
+		t.Fatalf("invalid select option should fail")
+	}
+}
+
+func TestWorldApplicationSubmitReview(t *testing.T) {
+	worldID := "world-app-" + utils.NewIDWithLength(6)
+	ownerID := "owner-app-" + utils.NewIDWithLength(6)
+	aliceID := "alice-app-" + utils.NewIDWithLength(6)
+	bobID := "bob-app-" + utils.NewIDWithLength(6)
+	seedTestWorld(t, testWorld{
+		World: model.WorldModel{
+			StringPKBaseModel: model.StringPKBaseModel{ID: worldID},
+			Name:              "Application World",
+			OwnerID:           ownerID,
+			Visibility:        model.WorldVisibilityPublic,
+			JoinMode:          model.WorldJoinModeApplication,
+			ApplicationForm:   model.JSONList[model.WorldApplicationFormField]{{Key: "exp", Label: "跑团经验", Type: "text", Required: true, MaxLength: 100}},
+		},
+		Users: []string{aliceID, bobID},
+	})
+	pm.Init()
+	db := model.GetDB()
+
+	if _, err := WorldApplicationSubmit(worldID, aliceID, map[string]string{}, ""); err == nil {
+		t.Fatalf("missing required answer should fail")
+	}
+	app, err := WorldApplicationSubmit(worldID, aliceID, map[string]string{"exp": "三年"}, "")
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	if app.Status != model.WorldApplicationPending {
+		t.Fatalf("new application should be pending, got %s", app.Status)
+	}
+	if _, err := WorldApplicationSubmit(worldID, aliceID, map[string]string{"exp": "五年"}, ""); !errors.Is(err, ErrWorldApplicationCooldown) {
+		t.Fatalf("immediate resubmit should hit cooldown, got %v", err)
+	}
+	if _, err := WorldApplicationReview(worldID, app.ID, aliceID, WorldApplicationReviewParams{Action: "approve"}); !errors.Is(err, ErrWorldPermission) {
+		t.Fatalf("applicant should not review, got %v", err)
+	}
+	if _, err := WorldApplicationReview(worldID, app.ID, ownerID, WorldApplicationReviewParams{Action: "approve"}); err != nil {
+		t.Fatalf("approve failed: %v", err)
+	}
+	if !IsWorldMember(worldID, aliceID) {
+		t.Fatalf("approved applicant should become a member")
+	}
+
+	app, err = WorldApplicationSubmit(worldID, bobID, map[string]string{"exp": "新手"}, "")
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	if _, err := WorldApplicationReview(worldID, app.ID, ownerID, WorldApplicationReviewParams{Action: "reject", Note: "名额已满"}); err != nil {
+		t.Fatalf("reject failed: %v", err)
+	}
+	if IsWorldMember(worldID, bobID) {
+		t.Fatalf("rejected applicant should not become a member")
+	}
+	if _, err := WorldApplicationSubmit(worldID, bobID, map[string]string{"exp": "新手"}, ""); !errors.Is(err, ErrWorldApplicationCooldown) {
+		t.Fatalf("resubmit after rejection should hit cooldown, got %v", err)
+	}
+	expired := time.Now().Add(-worldApplicationRetryCooldown - time.Minute)
+	if err := db.Model(&model.WorldJoinApplicationModel{}).Where("id = ?", app.ID).UpdateColumn("updated_at", expired).Error; err != nil {
+		t.Fatalf("backdate application failed: %v", err)
+	}
+	again, err := WorldApplicationSubmit(worldID, bobID, map[string]string{"exp": "新手"}, "")
+	if err != nil {
+		t.Fatalf("resubmit after cooldown failed: %v", err)
+	}
+	if again.ID == app.ID || again.Status != model.WorldApplicationPending {
+		t.Fatalf("resubmit should create a new pending application: %+v", again)
+	}
+}
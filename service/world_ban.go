@@ -0,0 +1,366 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/samber/lo"
+
+	"sealchat/model"
+)
+
+// 世界封禁：封禁（ban）会移出世界并阻止再次加入与查看，禁言（mute）保留成员身份但禁止发言。
+// 处罚可以指向用户、IP 或邮箱，任一命中即生效，到期或撤销后自动失效。
+
+var (
+	ErrWorldBanned      = errors.New("你已被该世界封禁")
+	ErrWorldMuted       = errors.New("你在该世界已被禁言")
+	ErrWorldBanNotFound = errors.New("封禁记录不存在")
+	ErrWorldBanInvalid  = errors.New("封禁参数无效")
+)
+
+const worldBanMaxReason = 500
+
+type WorldBanParams struct {
+	Type            string `json:"type"` // ban/mute
+	UserID          string `json:"userId"`
+	IP              string `json:"ip"`
+	Email           string `json:"email"`
+	IncludeEmail    bool   `json:"includeEmail"` // 同时封禁该用户绑定的邮箱
+	Reason          string `json:"reason"`
+	DurationMinutes int    `json:"durationMinutes"` // 0 表示永久
+}
+
+// 世界处罚缓存：发言等高频路径依赖它。条目按世界分代，处罚变更只让该世界的条目失效，
+// 并通过 worldSanctionSync 同步到其他实例；另设存活时间兜底用户邮箱变更等未显式失效的情况。
+const (
+	worldSanctionCacheTTL        = time.Minute
+	worldSanctionCacheSweepEvery = 1024
+)
+
+type worldSanctionCacheEntry struct {
+	items    []*model.WorldBanModel
+	gen      uint64
+	expireAt time.Time
+}
+
+var (
+	worldSanctionCache  sync.Map // userID|worldID|ip -> *worldSanctionCacheEntry
+	worldSanctionGens   sync.Map // worldID -> *atomic.Uint64
+	worldSanctionStores atomic.Int64
+	worldSanctionSync   atomic.Pointer[func(worldIDs []string)]
+)
+
+func worldSanctionGen(worldID string) *atomic.Uint64 {
+	value, _ := worldSanctionGens.LoadOrStore(worldID, &atomic.Uint64{})
+	return value.(*atomic.Uint64)
+}
+
+// WorldSanctionCacheSetSync 注册跨实例同步失效的发布函数，多实例部署时由事件总线注册
+func WorldSanctionCacheSetSync(fn func(worldIDs []string)) {
+	if fn == nil {
+		worldSanctionSync.Store(nil)
+		return
+	}
+	worldSanctionSync.Store(&fn)
+}
+
+// WorldSanctionCacheApplyRemote 处理其他实例同步来的失效，只作用于本实例
+func WorldSanctionCacheApplyRemote(worldIDs []string) {
+	for _, worldID := range worldIDs {
+		worldSanctionGen(worldID).Add(1)
+	}
+}
+
+func worldSanctionCacheInvalidate(worldID string) {
+	worldSanctionGen(worldID).Add(1)
+	if fn := worldSanctionSync.Load(); fn != nil {
+		(*fn)([]string{worldID})
+	}
+}
+
+// WorldBanCreate 管理员新增封禁或禁言
+func WorldBanCreate(worldID, actorID string, params WorldBanParams) (*model.WorldBanModel, error) {
+	world, err := GetWorldByID(worldID)
+	if err != nil {
+		return nil, err
+	}
+	if !IsWorldAdmin(world.ID, actorID) {
+		return nil, ErrWorldPermission
+	}
+	banType := strings.TrimSpace(params.Type)
+	if banType == "" {
+		banType = model.WorldBanTypeBan
+	}
+	if banType != model.WorldBanTypeBan && banType != model.WorldBanTypeMute {
+		return nil, fmt.Errorf("%w：未知的类型 %s", ErrWorldBanInvalid, banType)
+	}
+	reason := strings.TrimSpace(params.Reason)
+	if len([]rune(reason)) > worldBanMaxReason {
+		return nil, fmt.Errorf("%w：理由不能超过 %d 字", ErrWorldBanInvalid, worldBanMaxReason)
+	}
+	if params.DurationMinutes < 0 {
+		return nil, fmt.Errorf("%w：时长无效", ErrWorldBanInvalid)
+	}
+
+	ban := &model.WorldBanModel{
+		WorldID:  world.ID,
+		Type:     banType,
+		UserID:   strings.TrimSpace(params.UserID),
+		IP:       strings.TrimSpace(params.IP),
+		Email:    strings.ToLower(strings.TrimSpace(params.Email)),
+		Reason:   reason,
+		IssuerID: actorID,
+	}
+	if ban.UserID != "" {
+		if ban.UserID == actorID {
+			return nil, fmt.Errorf("%w：不能封禁自己", ErrWorldBanInvalid)
+		}
+		if IsWorldOwner(world.ID, ban.UserID) {
+			return nil, ErrWorldOwnerImmutable
+		}
+		if IsWorldAdmin(world.ID, ban.UserID) && !IsWorldOwner(world.ID, actorID) {
+			return nil, ErrWorldPermission
+		}
+		target := model.UserGet(ban.UserID)
+		if target == nil {
+			return nil, ErrWorldMemberInvalid
+		}
+		if params.IncludeEmail && ban.Email == "" && target.Email != nil {
+			ban.Email = strings.ToLower(strings.TrimSpace(*target.Email))
+		}
+	}
+	if ban.UserID == "" && ban.IP == "" && ban.Email == "" {
+		return nil, fmt.Errorf("%w：需要指定用户、IP 或邮箱", ErrWorldBanInvalid)
+	}
+	if params.DurationMinutes > 0 {
+		expireAt := time.Now().Add(time.Duration(params.DurationMinutes) * time.Minute)
+		ban.ExpireAt = &expireAt
+	}
+
+	db := model.GetDB()
+	if err := db.Create(ban).Error; err != nil {
+		return nil, err
+	}
+	worldSanctionCacheInvalidate(world.ID)
+
+	if ban.Type == model.WorldBanTypeBan && ban.UserID != "" {
+		if IsWorldMember(world.ID, ban.UserID) {
+			if err := WorldLeave(world.ID, ban.UserID); err != nil {
+				return ban, err
+			}
+		}
+		now := time.Now()
+		_ = db.Model(&model.WorldJoinApplicationModel{}).
+			Where("world_id = ? AND user_id = ? AND status IN ?", world.ID, ban.UserID,
+				[]string{model.WorldApplicationPending, model.WorldApplicationInfoRequested}).
+			Updates(map[string]any{
+				"status":      model.WorldApplicationRejected,
+				"reviewer_id": actorID,
+				"review_note": reason,
+				"reviewed_at": &now,
+				"updated_at":  now,
+			}).Error
+	}
+	return ban, nil
+}
+
+// WorldBanRevoke 撤销处罚
+func WorldBanRevoke(worldID, banID, actorID string) (*model.WorldBanModel, error) {
+	if !IsWorldAdmin(worldID, actorID) {
+		return nil, ErrWorldPermission
+	}
+	db := model.GetDB()
+	var ban model.WorldBanModel
+	if err := db.Where("id = ? AND world_id = ?", banID, worldID).Limit(1).Find(&ban).Error; err != nil {
+		return nil, err
+	}
+	if ban.ID == "" {
+		return nil, ErrWorldBanNotFound
+	}
+	if ban.RevokedAt != nil {
+		return &ban, nil
+	}
+	now := time.Now()
+	if err := db.Model(&ban).Updates(map[string]any{
+		"revoked_at": &now,
+		"revoked_by": actorID,
+		"updated_at": now,
+	}).Error; err != nil {
+		return nil, err
+	}
+	ban.RevokedAt = &now
+	ban.RevokedBy = actorID
+	worldSanctionCacheInvalidate(worldID)
+	return &ban, nil
+}
+
+// WorldBanList 列出世界的处罚记录，默认只返回仍生效的
+func WorldBanList(worldID, actorID string, includeInactive bool) ([]*model.WorldBanModel, error) {
+	if !IsWorldAdmin(worldID, actorID) {
+		return nil, ErrWorldPermission
+	}
+	q := model.GetDB().Where("world_id = ?", worldID)
+	if !includeInactive {
+		q = q.Where("revoked_at IS NULL AND (expire_at IS NULL OR expire_at > ?)", time.Now())
+	}
+	var items []*model.WorldBanModel
+	if err := q.Order("created_at DESC").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	hydrateWorldBanUsers(items)
+	return items, nil
+}
+
+func hydrateWorldBanUsers(items []*model.WorldBanModel) {
+	ids := make([]string, 0, len(items)*2)
+	for _, item := range items {
+		if item.UserID != "" {
+			ids = append(ids, item.UserID)
+		}
+		ids = append(ids, item.IssuerID)
+	}
+	if len(ids) == 0 {
+		return
+	}
+	var users []*model.UserModel
+	model.GetDB().Where("id IN ?", lo.Uniq(ids)).
+		Select("id, username, nickname, avatar").
+		Find(&users)
+	byID := lo.SliceToMap(users, func(u *model.UserModel) (string, *model.UserModel) { return u.ID, u })
+	for _, item := range items {
+		item.User = byID[item.UserID]
+		item.Issuer = byID[item.IssuerID]
+	}
+}
+
+func worldSanctionsLoad(worldID, userID, ip string) []*model.WorldBanModel {
+	worldID = strings.TrimSpace(worldID)
+	if worldID == "" || (userID == "" && ip == "") {
+		return nil
+	}
+	key := userID + "|" + worldID + "|" + ip
+	gen := worldSanctionGen(worldID).Load()
+	now := time.Now()
+	if value, ok := worldSanctionCache.Load(key); ok {
+		entry := value.(*worldSanctionCacheEntry)
+		if entry.gen == gen && now.Before(entry.expireAt) {
+			return entry.items
+		}
+	}
+	conds := []string{}
+	args := []any{}
+	if userID != "" {
+		conds = append(conds, "user_id = ?")
+		args = append(args, userID)
+		if user := model.UserGet(userID); user != nil && user.Email != nil && *user.Email != "" {
+			conds = append(conds, "email = ?")
+			args = append(args, strings.ToLower(*user.Email))
+		}
+	}
+	if ip != "" {
+		conds = append(conds, "ip = ?")
+		args = append(args, ip)
+	}
+	var items []*model.WorldBanModel
+	if err := model.GetDB().
+		Where("world_id = ? AND revoked_at IS NULL AND (expire_at IS NULL OR expire_at > ?)", worldID, now).
+		Where("("+strings.Join(conds, " OR ")+")", args...).
+		Find(&items).Error; err != nil {
+		return nil
+	}
+	// 使用查询前的代数，查询期间发生的失效会让这条结果下次直接作废
+	worldSanctionCache.Store(key, &worldSanctionCacheEntry{items: items, gen: gen, expireAt: now.Add(worldSanctionCacheTTL)})
+	if worldSanctionStores.Add(1)%worldSanctionCacheSweepEvery == 0 {
+		worldSanctionCache.Range(func(key, value any) bool {
+			if now.After(value.(*worldSanctionCacheEntry).expireAt) {
+				worldSanctionCache.Delete(key)
+			}
+			return true
+		})
+	}
+	return items
+}
+
+// WorldSanctionFind 返回命中的处罚，封禁优先于禁言；没有时返回 nil
+func WorldSanctionFind(worldID, userID, ip string) *model.WorldBanModel {
+	now := time.Now()
+	var found *model.WorldBanModel
+	for _, item := range worldSanctionsLoad(worldID, userID, ip) {
+		if !item.IsActive(now) {
+			continue
+		}
+		if item.Type == model.WorldBanTypeBan {
+			return item
+		}
+		if found == nil {
+			found = item
+		}
+	}
+	return found
+}
+
+func worldSanctionError(base error, ban *model.WorldBanModel) error {
+	detail := ""
+	if ban.ExpireAt != nil {
+		detail += "，解除时间 " + ban.ExpireAt.Format("2006-01-02 15:04")
+	}
+	if ban.Reason != "" {
+		detail += "，理由：" + ban.Reason
+	}
+	return fmt.Errorf("%w%s", base, detail)
+}
+
+// WorldBanCheck 加入、查看世界前调用，被封禁时返回错误
+func WorldBanCheck(worldID, userID, ip string) error {
+	if ban := WorldSanctionFind(worldID, userID, ip); ban != nil && ban.Type == model.WorldBanTypeBan {
+		return worldSanctionError(ErrWorldBanned, ban)
+	}
+	return nil
+}
+
+// WorldSpeakCheck 发言前调用，封禁与禁言都会阻止发言
+func WorldSpeakCheck(worldID, userID, ip string) error {
+	ban := WorldSanctionFind(worldID, userID, ip)
+	if ban == nil {
+		return nil
+	}
+	if ban.Type == model.WorldBanTypeBan {
+		return worldSanctionError(ErrWorldBanned, ban)
+	}
+	return worldSanctionError(ErrWorldMuted, ban)
+}
+
+// ChannelBanCheck 按频道所属世界检查封禁；私聊等无世界的频道直接放行
+func ChannelBanCheck(channelID, userID, ip string) error {
+	worldID := worldIDOfChannel(channelID)
+	if worldID == "" {
+		return nil
+	}
+	return WorldBanCheck(worldID, userID, ip)
+}
+
+// ChannelSpeakCheck 按频道所属世界检查禁言
+func ChannelSpeakCheck(channelID, userID, ip string) error {
+	worldID := worldIDOfChannel(channelID)
+	if worldID == "" {
+		return nil
+	}
+	return WorldSpeakCheck(worldID, userID, ip)
+}
+
+func worldIDOfChannel(channelID string) string {
+	channelID = strings.TrimSpace(channelID)
+	if channelID == "" || strings.Contains(channelID, ":") {
+		return ""
+	}
+	channel, err := model.ChannelGet(channelID)
+	if err != nil || channel == nil {
+		return ""
+	}
+	return channel.WorldID
+}
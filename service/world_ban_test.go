@@ -0,0 +1,75 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"sealchat/model"
+	"sealchat/utils"
+)
+
+func TestWorldBanIsActive(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Hour)
+
+	if !(&model.WorldBanModel{}).IsActive(now) {
+		t.Fatalf("permanent ban should be active")
+	}
+	if !(&model.WorldBanModel{ExpireAt: &future}).IsActive(now) {
+		t.Fatalf("unexpired ban should be active")
+	}
+	if (&model.WorldBanModel{ExpireAt: &past}).IsActive(now) {
+		t.Fatalf("expired ban should be inactive")
+	}
+	if (&model.WorldBanModel{RevokedAt: &past}).IsActive(now) {
+		t.Fatalf("revoked ban should be inactive")
+	}
+}
+
+func TestWorldSanctionError(t *testing.T) {
+	expire := time.Date(2030, 1, 2, 3, 4, 0, 0, time.Local)
+	err := worldSanctionError(ErrWorldMuted, &model.WorldBanModel{Reason: "刷屏", ExpireAt: &expire})
+	msg := err.Error()
+	if !strings.HasPrefix(msg, ErrWorldMuted.Error()) || !strings.Contains(msg, "2030-01-02 03:04") || !strings.Contains(msg, "刷屏") {
+		t.Fatalf("unexpected message: %s", msg)
+	}
+	if !errors.Is(err, ErrWorldMuted) {
+		t.Fatalf("sanction error should wrap the base error")
+	}
+	if worldSanctionError(ErrWorldBanned, &model.WorldBanModel{}).Error() != ErrWorldBanned.Error() {
+		t.Fatalf("no detail expected without reason and expiry")
+	}
+}
+
+func TestWorldSanctionCacheApplyRemote(t *testing.T) {
+	initTestDB(t)
+	db := model.GetDB()
+	worldID := "world-ban-" + utils.NewIDWithLength(6)
+	userID := "user-ban-" + utils.NewIDWithLength(6)
+	ban := &model.WorldBanModel{WorldID: worldID, Type: model.WorldBanTypeMute, UserID: userID}
+	if err := db.Create(ban).Error; err != nil {
+		t.Fatalf("create ban failed: %v", err)
+	}
+	if err := WorldSpeakCheck(worldID, userID, ""); !errors.Is(err, ErrWorldMuted) {
+		t.Fatalf("muted user should not speak, got %v", err)
+	}
+	if err := WorldBanCheck(worldID, userID, ""); err != nil {
+		t.Fatalf("mute should not block viewing, got %v", err)
+	}
+
+	// 模拟其他实例撤销：直接改库后只收到总线失效
+	now := time.Now()
+	if err := db.Model(ban).Update("revoked_at", &now).Error; err != nil {
+		t.Fatalf("revoke ban failed: %v", err)
+	}
+	if err := WorldSpeakCheck(worldID, userID, ""); err == nil {
+		t.Fatalf("cached sanction should still apply before invalidation")
+	}
+	WorldSanctionCacheApplyRemote([]string{worldID})
+	if err := WorldSpeakCheck(worldID, userID, ""); err != nil {
+		t.Fatalf("remote invalidation should drop the cached sanction, got %v", err)
+	}
+}
@@ -0,0 +1,388 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/utils"
+)
+
+// 公开世界目录：仅列出公开、未被所有者隐藏的世界，按活跃度（最近发言与成员数）排序。
+
+var ErrWorldDirectoryInvalid = errors.New("世界目录信息无效")
+
+const (
+	WorldRecruitingOpen   = "open"
+	WorldRecruitingClosed = "closed"
+
+	worldDirectoryMaxTags      = 10
+	worldDirectoryMaxTagLength = 20
+	worldDirectoryFacetSample  = 500
+	worldDirectoryFacetLimit   = 20
+
+	WorldDirectorySortActivity = "activity"
+	WorldDirectorySortMembers  = "members"
+	WorldDirectorySortRecent   = "recent"
+	WorldDirectorySortNewest   = "newest"
+)
+
+type WorldDirectoryQuery struct {
+	Keyword    string
+	Tag        string
+	Language   string
+	Genre      string
+	Recruiting string
+	Featured   bool
+	Sort       string
+	Page       int
+	PageSize   int
+}
+
+type WorldDirectoryItem struct {
+	World         *model.WorldModel `json:"world"`
+	MemberCount   int64             `json:"memberCount"`
+	ChannelCount  int64             `json:"channelCount"`
+	LastActiveAt  int64             `json:"lastActiveAt"`
+	ActivityScore float64           `json:"activityScore"`
+}
+
+type WorldDirectoryFacet struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type WorldDirectoryResult struct {
+	Items    []*WorldDirectoryItem             `json:"items"`
+	Total    int                               `json:"total"`
+	Page     int                               `json:"page"`
+	PageSize int                               `json:"pageSize"`
+	Facets   map[string][]*WorldDirectoryFacet `json:"facets"`
+}
+
+func normalizeWorldTags(tags []string) ([]string, error) {
+	result := make([]string, 0, len(tags))
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+		if tag == "" {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > worldDirectoryMaxTagLength {
+			return nil, fmt.Errorf("%w：标签不能超过 %d 个字符", ErrWorldDirectoryInvalid, worldDirectoryMaxTagLength)
+		}
+		key := strings.ToLower(tag)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, tag)
+	}
+	if len(result) > worldDirectoryMaxTags {
+		return nil, fmt.Errorf("%w：标签最多 %d 个", ErrWorldDirectoryInvalid, worldDirectoryMaxTags)
+	}
+	return result, nil
+}
+
+func normalizeWorldDirectoryText(value string, max int, label string) (string, error) {
+	value = strings.TrimSpace(value)
+	if utf8.RuneCountInString(value) > max {
+		return "", fmt.Errorf("%w：%s不能超过 %d 个字符", ErrWorldDirectoryInvalid, label, max)
+	}
+	return value, nil
+}
+
+// normalizeWorldCoverImage 封面只接受已上传的附件（id:xxx 或附件 ID）或 http(s) 图片地址
+func normalizeWorldCoverImage(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	lower := strings.ToLower(value)
+	if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") {
+		parsed, err := url.Parse(value)
+		if err != nil || parsed.Host == "" {
+			return "", fmt.Errorf("%w：封面地址无效", ErrWorldDirectoryInvalid)
+		}
+		return value, nil
+	}
+	att, err := ResolveAttachment(strings.TrimPrefix(value, "id:"))
+	if err != nil {
+		return "", err
+	}
+	if att == nil {
+		return "", fmt.Errorf("%w：封面需为已上传的图片或 http(s) 地址", ErrWorldDirectoryInvalid)
+	}
+	return "id:" + att.ID, nil
+}
+
+// applyWorldDirectoryUpdates 处理世界更新中与公开目录相关的字段
+func applyWorldDirectoryUpdates(world *model.WorldModel, actorID string, params WorldUpdateParams, updates map[string]interface{}) error {
+	if params.Tags != nil {
+		tags, err := normalizeWorldTags(*params.Tags)
+		if err != nil {
+			return err
+		}
+		updates["tags"] = model.JSONList[string](tags)
+	}
+	if params.Language != nil {
+		value, err := normalizeWorldDirectoryText(*params.Language, 16, "语言")
+		if err != nil {
+			return err
+		}
+		updates["language"] = value
+	}
+	if params.Genre != nil {
+		value, err := normalizeWorldDirectoryText(*params.Genre, 32, "规则类型")
+		if err != nil {
+			return err
+		}
+		updates["genre"] = value
+	}
+	if params.RecruitingStatus != nil {
+		switch value := strings.TrimSpace(*params.RecruitingStatus); value {
+		case "", WorldRecruitingOpen, WorldRecruitingClosed:
+			updates["recruiting_status"] = value
+		default:
+			return fmt.Errorf("%w：未知的招募状态 %s", ErrWorldDirectoryInvalid, value)
+		}
+	}
+	if params.CoverImage != nil {
+		value, err := normalizeWorldDirectoryText(*params.CoverImage, 255, "封面")
+		if err != nil {
+			return err
+		}
+		if value, err = normalizeWorldCoverImage(value); err != nil {
+			return err
+		}
+		updates["cover_image"] = value
+	}
+	if params.DirectoryHidden != nil {
+		// 是否出现在公开目录由所有者决定
+		if world.OwnerID != actorID && !pm.CanWithSystemRole(actorID, pm.PermModAdmin) {
+			return ErrWorldPermission
+		}
+		updates["directory_hidden"] = *params.DirectoryHidden
+	}
+	return nil
+}
+
+// WorldSetFeatured 平台管理员推荐或取消推荐世界
+func WorldSetFeatured(worldID string, featured bool) (*model.WorldModel, error) {
+	world, err := GetWorldByID(worldID)
+	if err != nil {
+		return nil, err
+	}
+	updates := map[string]interface{}{"featured": featured, "featured_at": nil}
+	if featured {
+		updates["featured_at"] = time.Now()
+	}
+	if err := model.GetDB().Model(&model.WorldModel{}).Where("id = ?", world.ID).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return GetWorldByID(worldID)
+}
+
+// worldActivityScore 最近发言按一周半衰，成员数取对数，避免大世界长期霸榜
+func worldActivityScore(memberCount int64, lastActiveMs int64, now time.Time) float64 {
+	score := math.Log1p(float64(memberCount)) * 2
+	if lastActiveMs > 0 {
+		age := now.Sub(time.UnixMilli(lastActiveMs))
+		if age < 0 {
+			age = 0
+		}
+		score += 10 * math.Exp2(-age.Hours()/(24*7))
+	}
+	return math.Round(score*100) / 100
+}
+
+type worldDirectoryRow struct {
+	ID           string
+	MemberCount  int64
+	ChannelCount int64
+	LastActive   int64
+}
+
+// worldDirectoryScoreExpr 与 worldActivityScore 相同的活跃度公式，供数据库排序使用；
+// 超过约一年未发言的衰减项直接按 0 处理，避免部分数据库 POWER 下溢报错
+func worldDirectoryScoreExpr(now time.Time) clause.Expr {
+	return gorm.Expr(`LN(1 + COALESCE(mc.member_count, 0)) * 2 + CASE
+		WHEN COALESCE(cs.last_active, 0) <= 0 OR cs.last_active < ? THEN 0
+		WHEN cs.last_active >= ? THEN 10
+		ELSE 10 * POWER(2, -(? - cs.last_active) / 604800000.0) END`,
+		now.AddDate(-1, 0, 0).UnixMilli(), now.UnixMilli(), now.UnixMilli())
+}
+
+func worldDirectoryOrder(q *gorm.DB, mode string, now time.Time) *gorm.DB {
+	switch mode {
+	case WorldDirectorySortMembers:
+		q = q.Order("member_count DESC").Order("last_active DESC")
+	case WorldDirectorySortRecent:
+		q = q.Order("last_active DESC").Order("member_count DESC")
+	case WorldDirectorySortNewest:
+		q = q.Order("worlds.created_at DESC")
+	default:
+		// 默认排序下推荐世界置顶
+		q = q.Order("worlds.featured DESC").
+			Order(clause.OrderBy{Expression: clause.Expr{SQL: "(?) DESC", Vars: []interface{}{worldDirectoryScoreExpr(now)}}}).
+			Order("worlds.created_at DESC")
+	}
+	return q.Order("worlds.id")
+}
+
+// WorldDirectoryList 公开世界目录
+func WorldDirectoryList(query WorldDirectoryQuery, now time.Time) (*WorldDirectoryResult, error) {
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.PageSize <= 0 {
+		query.PageSize = 20
+	}
+	if query.PageSize > 50 {
+		query.PageSize = 50
+	}
+	db := model.GetDB()
+	filter := func() *gorm.DB {
+		q := db.Model(&model.WorldModel{}).
+			Where("worlds.status = ? AND worlds.visibility = ? AND worlds.directory_hidden = ?", "active", model.WorldVisibilityPublic, false)
+		for _, token := range strings.Fields(strings.ToLower(query.Keyword)) {
+			q = q.Where("worlds.search_text LIKE ? ESCAPE '!'", "%"+utils.EscapeLike(token)+"%")
+		}
+		if tag := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(query.Tag, "#"))); tag != "" {
+			// 标签以 JSON 数组存储，按带引号的完整元素匹配
+			encoded, _ := json.Marshal(tag)
+			q = q.Where("LOWER(worlds.tags) LIKE ? ESCAPE '!'", "%"+utils.EscapeLike(string(encoded))+"%")
+		}
+		if v := strings.TrimSpace(query.Language); v != "" {
+			q = q.Where("LOWER(worlds.language) = ?", strings.ToLower(v))
+		}
+		if v := strings.TrimSpace(query.Genre); v != "" {
+			q = q.Where("LOWER(worlds.genre) = ?", strings.ToLower(v))
+		}
+		if v := strings.TrimSpace(query.Recruiting); v != "" {
+			q = q.Where("worlds.recruiting_status = ?", v)
+		}
+		if query.Featured {
+			q = q.Where("worlds.featured = ?", true)
+		}
+		return q
+	}
+
+	var total int64
+	if err := filter().Count(&total).Error; err != nil {
+		return nil, err
+	}
+	facets, err := worldDirectoryFacets(filter)
+	if err != nil {
+		return nil, err
+	}
+	result := &WorldDirectoryResult{
+		Total:    int(total),
+		Page:     query.Page,
+		PageSize: query.PageSize,
+		Facets:   facets,
+		Items:    []*WorldDirectoryItem{},
+	}
+	offset := (query.Page - 1) * query.PageSize
+	if int64(offset) >= total {
+		return result, nil
+	}
+
+	members := db.Table("world_members").Select("world_id, COUNT(*) AS member_count").Group("world_id")
+	channels := db.Table("channels").
+		Select("world_id, COUNT(*) AS channel_count, MAX(recent_sent_at) AS last_active").
+		Where("status = ? AND perm_type = ?", model.ChannelStatusActive, "public").
+		Group("world_id")
+	var rows []worldDirectoryRow
+	q := filter().
+		Select("worlds.id, COALESCE(mc.member_count, 0) AS member_count, COALESCE(cs.channel_count, 0) AS channel_count, COALESCE(cs.last_active, 0) AS last_active").
+		Joins("LEFT JOIN (?) AS mc ON mc.world_id = worlds.id", members).
+		Joins("LEFT JOIN (?) AS cs ON cs.world_id = worlds.id", channels)
+	if err := worldDirectoryOrder(q, query.Sort, now).Offset(offset).Limit(query.PageSize).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return result, nil
+	}
+	var worlds []*model.WorldModel
+	ids := lo.Map(rows, func(row worldDirectoryRow, _ int) string { return row.ID })
+	if err := db.Where("id IN ?", ids).Find(&worlds).Error; err != nil {
+		return nil, err
+	}
+	worldMap := lo.KeyBy(worlds, func(w *model.WorldModel) string { return w.ID })
+	for _, row := range rows {
+		w := worldMap[row.ID]
+		if w == nil {
+			continue
+		}
+		result.Items = append(result.Items, &WorldDirectoryItem{
+			World:         w,
+			MemberCount:   row.MemberCount,
+			ChannelCount:  row.ChannelCount,
+			LastActiveAt:  row.LastActive,
+			ActivityScore: worldActivityScore(row.MemberCount, row.LastActive, now),
+		})
+	}
+	return result, nil
+}
+
+// worldDirectoryFacets 统计筛选结果中的标签、语言、规则类型分布，供筛选面板使用；
+// 语言与类型直接分组统计，标签为 JSON 列，只取最近创建的一批世界统计
+func worldDirectoryFacets(filter func() *gorm.DB) (map[string][]*WorldDirectoryFacet, error) {
+	facets := map[string][]*WorldDirectoryFacet{}
+	for key, column := range map[string]string{"languages": "worlds.language", "genres": "worlds.genre"} {
+		var rows []struct {
+			Value string
+			Count int
+		}
+		if err := filter().Select(column + " AS value, COUNT(*) AS count").
+			Where(column + " <> ''").Group(column).
+			Order("count DESC").Order("value").Limit(worldDirectoryFacetLimit).
+			Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		list := make([]*WorldDirectoryFacet, 0, len(rows))
+		for _, row := range rows {
+			list = append(list, &WorldDirectoryFacet{Value: row.Value, Count: row.Count})
+		}
+		facets[key] = list
+	}
+
+	var tagRows []model.JSONList[string]
+	if err := filter().Order("worlds.created_at DESC").Limit(worldDirectoryFacetSample).
+		Pluck("worlds.tags", &tagRows).Error; err != nil {
+		return nil, err
+	}
+	counter := map[string]int{}
+	for _, tags := range tagRows {
+		for _, tag := range tags {
+			counter[tag]++
+		}
+	}
+	list := make([]*WorldDirectoryFacet, 0, len(counter))
+	for value, count := range counter {
+		list = append(list, &WorldDirectoryFacet{Value: value, Count: count})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Value < list[j].Value
+	})
+	if len(list) > worldDirectoryFacetLimit {
+		list = list[:worldDirectoryFacetLimit]
+	}
+	facets["tags"] = list
+	return facets, nil
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"sealchat/model"
+)
+
+func TestNormalizeWorldTags(t *testing.T) {
+	tags, err := normalizeWorldTags([]string{" #克苏鲁 ", "coc", "COC", "", "新手友好"})
+	if err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	if len(tags) != 3 || tags[0] != "克苏鲁" || tags[1] != "coc" || tags[2] != "新手友好" {
+		t.Fatalf("unexpected tags: %v", tags)
+	}
+	many := make([]string, worldDirectoryMaxTags+1)
+	for i := range many {
+		many[i] = string(rune('a' + i))
+	}
+	if _, err := normalizeWorldTags(many); !errors.Is(err, ErrWorldDirectoryInvalid) {
+		t.Fatalf("expected too many tags error, got %v", err)
+	}
+}
+
+func TestWorldActivityScorePrefersRecentActivity(t *testing.T) {
+	now := time.Now()
+	active := worldActivityScore(3, now.Add(-time.Hour).UnixMilli(), now)
+	dormant := worldActivityScore(100, now.AddDate(0, -2, 0).UnixMilli(), now)
+	if active <= dormant {
+		t.Fatalf("recently active small world should rank above dormant large world: %v <= %v", active, dormant)
+	}
+	if got := worldActivityScore(0, now.AddDate(0, 0, -7).UnixMilli(), now); got != 5 {
+		t.Fatalf("activity bonus should halve after one week, got %v", got)
+	}
+	if worldActivityScore(10, 0, now) <= worldActivityScore(1, 0, now) {
+		t.Fatalf("member count should still contribute")
+	}
+}
+
+func TestWorldDirectoryList(t *testing.T) {
+	initTestDB(t)
+	db := model.GetDB()
+	now := time.Now()
+	genre := "directory-test-genre"
+
+	create := func(id string, mutate func(w *model.WorldModel)) {
+		w := &model.WorldModel{
+			StringPKBaseModel: model.StringPKBaseModel{ID: id},
+			Name:              id,
+			Visibility:        model.WorldVisibilityPublic,
+			Status:            "active",
+			Genre:             genre,
+			Language:          "zh-CN",
+		}
+		mutate(w)
+		if err := db.Create(w).Error; err != nil {
+			t.Fatalf("create world %s failed: %v", id, err)
+		}
+	}
+	create("dir-active", func(w *model.WorldModel) { w.Tags = model.JSONList[string]{"新手友好"} })
+	create("dir-featured", func(w *model.WorldModel) { w.Featured = true; w.Description = "长期团 寻找调查员" })
+	create("dir-hidden", func(w *model.WorldModel) { w.DirectoryHidden = true })
+	create("dir-private", func(w *model.WorldModel) { w.Visibility = model.WorldVisibilityPrivate })
+	if err := db.Create(&model.ChannelModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: "dir-active-ch"},
+		WorldID:           "dir-active",
+		PermType:          "public",
+		Status:            model.ChannelStatusActive,
+		RecentSentAt:      now.UnixMilli(),
+	}).Error; err != nil {
+		t.Fatalf("create channel failed: %v", err)
+	}
+
+	result, err := WorldDirectoryList(WorldDirectoryQuery{Genre: genre}, now)
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if result.Total != 2 {
+		t.Fatalf("hidden and private worlds must be excluded, got %d", result.Total)
+	}
+	if result.Items[0].World.ID != "dir-featured" || result.Items[1].World.ID != "dir-active" {
+		t.Fatalf("featured world should be pinned first: %s, %s", result.Items[0].World.ID, result.Items[1].World.ID)
+	}
+	if result.Items[1].ChannelCount != 1 || result.Items[1].LastActiveAt == 0 {
+		t.Fatalf("channel stats not filled: %+v", result.Items[1])
+	}
+
+	result, _ = WorldDirectoryList(WorldDirectoryQuery{Genre: genre, Sort: WorldDirectorySortRecent}, now)
+	if result.Items[0].World.ID != "dir-active" {
+		t.Fatalf("recent sort should put active world first")
+	}
+
+	result, _ = WorldDirectoryList(WorldDirectoryQuery{Genre: genre, Keyword: "寻找 长期团"}, now)
+	if result.Total != 1 || result.Items[0].World.ID != "dir-featured" {
+		t.Fatalf("keyword search mismatch: %d", result.Total)
+	}
+
+	result, _ = WorldDirectoryList(WorldDirectoryQuery{Genre: genre, Tag: "#新手友好"}, now)
+	if result.Total != 1 || result.Items[0].World.ID != "dir-active" {
+		t.Fatalf("tag filter mismatch: %d", result.Total)
+	}
+	if facets := result.Facets["tags"]; len(facets) != 1 || facets[0].Value != "新手友好" {
+		t.Fatalf("unexpected tag facets: %+v", facets)
+	}
+
+	result, _ = WorldDirectoryList(WorldDirectoryQuery{Genre: genre, Keyword: "%"}, now)
+	if result.Total != 0 {
+		t.Fatalf("LIKE wildcards in keyword must be escaped, got %d", result.Total)
+	}
+
+	result, _ = WorldDirectoryList(WorldDirectoryQuery{Genre: genre, Page: 2, PageSize: 1}, now)
+	if result.Total != 2 || len(result.Items) != 1 || result.Items[0].World.ID != "dir-active" {
+		t.Fatalf("second page mismatch: total=%d items=%d", result.Total, len(result.Items))
+	}
+	if facets := result.Facets["genres"]; len(facets) != 1 || facets[0].Count != 2 {
+		t.Fatalf("facets should cover the whole result, got %+v", facets)
+	}
+}
+
+func TestNormalizeWorldCoverImage(t *testing.T) {
+	initTestDB(t)
+	att := &model.AttachmentModel{UserID: "cover-uploader"}
+	if err := model.GetDB().Create(att).Error; err != nil {
+		t.Fatalf("create attachment failed: %v", err)
+	}
+	for _, value := range []string{att.ID, "id:" + att.ID} {
+		if got, err := normalizeWorldCoverImage(value); err != nil || got != "id:"+att.ID {
+			t.Fatalf("attachment cover %q rejected: %q %v", value, got, err)
+		}
+	}
+	if got, err := normalizeWorldCoverImage("https://example.com/cover.png"); err != nil || got == "" {
+		t.Fatalf("https cover rejected: %v", err)
+	}
+	for _, value := range []string{"javascript:alert(1)", "missing-attachment", "https://", "data:image/png;base64,AAAA"} {
+		if _, err := normalizeWorldCoverImage(value); !errors.Is(err, ErrWorldDirectoryInvalid) {
+			t.Fatalf("cover %q should be rejected, got %v", value, err)
+		}
+	}
+}
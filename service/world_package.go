@@ -0,0 +1,1809 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"golang.org/x/crypto/blake2s"
+	"gorm.io/gorm"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/pm/gen"
+	"sealchat/utils"
+)
+
+// 世界包：把整个世界（频道树、角色权限、术语、便签、iForm、骰子宏、音频场景与音频文件、人物卡模板、画廊）
+// 打成 zip，便于在其他 SealChat 实例导入。zip 内为 manifest.json 与 files/ 目录，包内 ID 仅用于相互引用，
+// 导入时全部重新分配。
+
+const (
+	WorldPackageVersion = 1
+
+	worldPackageManifestName = "manifest.json"
+	worldPackageFileDir      = "files/"
+	worldPackageMaxManifest  = 32 << 20
+	// worldPackageMaxFile 未配置上传大小限制时单个文件的上限
+	worldPackageMaxFile      = 512 << 20
+	worldPackageRenameSuffix = "（导入）"
+)
+
+const (
+	WorldPackageConflictSkip      = "skip"
+	WorldPackageConflictOverwrite = "overwrite"
+	WorldPackageConflictRename    = "rename"
+)
+
+var ErrWorldPackageInvalid = errors.New("无效的世界包")
+
+type WorldPackageWorld struct {
+	Name                       string   `json:"name"`
+	Description                string   `json:"description"`
+	Visibility                 string   `json:"visibility"`
+	AllowAdminEditMessages     bool     `json:"allowAdminEditMessages"`
+	AllowMemberEditKeywords    bool     `json:"allowMemberEditKeywords"`
+	CharacterCardBadgeTemplate string   `json:"characterCardBadgeTemplate"`
+	Tags                       []string `json:"tags,omitempty"`
+	Language                   string   `json:"language,omitempty"`
+	Genre                      string   `json:"genre,omitempty"`
+}
+
+type WorldPackageRole struct {
+	Key         string   `json:"key"`
+	Name        string   `json:"name"`
+	Desc        string   `json:"desc"`
+	Permissions []string `json:"permissions"`
+}
+
+// WorldPackageWorldRole 世界自定义角色，各频道的派生角色导入后重新生成
+type WorldPackageWorldRole struct {
+	ID          string                     `json:"id"`
+	Name        string                     `json:"name"`
+	Desc        string                     `json:"desc"`
+	Color       string                     `json:"color"`
+	Priority    int                        `json:"priority"`
+	Permissions []string                   `json:"permissions"`
+	Overrides   []WorldPackageRoleOverride `json:"overrides,omitempty"`
+}
+
+// WorldPackageRoleOverride 频道对世界角色权限的覆盖，ChannelID 为包内频道 ID
+type WorldPackageRoleOverride struct {
+	ChannelID   string   `json:"channelId"`
+	Permissions []string `json:"permissions"`
+}
+
+type WorldPackageChannel struct {
+	ID                 string             `json:"id"`
+	ParentID           string             `json:"parentId"`
+	Name               string             `json:"name"`
+	Note               string             `json:"note"`
+	PermType           string             `json:"permType"`
+	Kind               string             `json:"kind,omitempty"`
+	PermOverride       bool               `json:"permOverride,omitempty"`
+	ReadReceipts       bool               `json:"readReceipts,omitempty"`
+	SortOrder          int                `json:"sortOrder"`
+	IsDefault          bool               `json:"isDefault"`
+	DefaultDiceExpr    string             `json:"defaultDiceExpr"`
+	BuiltInDiceEnabled bool               `json:"builtInDiceEnabled"`
+	BotFeatureEnabled  bool               `json:"botFeatureEnabled"`
+	BackgroundFileID   string             `json:"backgroundFileId"`
+	BackgroundSettings string             `json:"backgroundSettings"`
+	GMRoleKeys         []string           `json:"gmRoleKeys"`
+	Roles              []WorldPackageRole `json:"roles"`
+}
+
+type WorldPackageAudioAsset struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Size        int64    `json:"size"`
+	WorldScoped bool     `json:"worldScoped"`
+	FileID      string   `json:"fileId"`
+}
+
+type WorldPackageCardTemplate struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	SheetType string `json:"sheetType"`
+	Content   string `json:"content"`
+}
+
+type WorldPackageFile struct {
+	ID       string `json:"id"`
+	Kind     string `json:"kind"` // attachment/audio
+	Name     string `json:"name"`
+	MimeType string `json:"mimeType"`
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+}
+
+// WorldPackageManifest 便签、iForm 等直接沿用模型结构，导出时清理掉与原实例用户相关的字段
+type WorldPackageManifest struct {
+	Version            int                           `json:"version"`
+	ExportedAt         time.Time                     `json:"exportedAt"`
+	World              WorldPackageWorld             `json:"world"`
+	Channels           []WorldPackageChannel         `json:"channels"`
+	WorldRoles         []WorldPackageWorldRole       `json:"worldRoles,omitempty"`
+	Keywords           []WorldKeywordInput           `json:"keywords"`
+	StickyFolders      []model.StickyNoteFolderModel `json:"stickyFolders"`
+	StickyNotes        []model.StickyNoteModel       `json:"stickyNotes"`
+	IForms             []model.ChannelIFormModel     `json:"iforms"`
+	DiceMacros         []model.DiceMacroModel        `json:"diceMacros"`
+	AudioAssets        []WorldPackageAudioAsset      `json:"audioAssets"`
+	AudioScenes        []model.AudioScene            `json:"audioScenes"`
+	CardTemplates      []WorldPackageCardTemplate    `json:"cardTemplates"`
+	GalleryCollections []model.GalleryCollection     `json:"galleryCollections"`
+	GalleryItems       []model.GalleryItem           `json:"galleryItems"`
+	Files              []WorldPackageFile            `json:"files"`
+	Warnings           []string                      `json:"warnings,omitempty"`
+}
+
+type worldPackageWriter struct {
+	zw       *zip.Writer
+	manifest *WorldPackageManifest
+	written  map[string]bool
+}
+
+func (w *worldPackageWriter) warn(format string, args ...any) {
+	w.manifest.Warnings = append(w.manifest.Warnings, fmt.Sprintf(format, args...))
+}
+
+func (w *worldPackageWriter) writeFile(id, kind, name, mimeType string, r io.Reader) error {
+	entryPath := worldPackageFileDir + id
+	fw, err := w.zw.Create(entryPath)
+	if err != nil {
+		return err
+	}
+	size, err := io.Copy(fw, r)
+	if err != nil {
+		return err
+	}
+	w.written[id] = true
+	w.manifest.Files = append(w.manifest.Files, WorldPackageFile{
+		ID:       id,
+		Kind:     kind,
+		Name:     name,
+		MimeType: mimeType,
+		Path:     entryPath,
+		Size:     size,
+	})
+	return nil
+}
+
+// addAttachment 写入图片附件，返回包内文件 ID；无法读取时记录警告并返回空串
+func (w *worldPackageWriter) addAttachment(token string) (string, error) {
+	token = strings.TrimPrefix(strings.TrimSpace(token), "id:")
+	if token == "" {
+		return "", nil
+	}
+	att, err := ResolveAttachment(token)
+	if err != nil {
+		return "", err
+	}
+	if att == nil {
+		w.warn("附件 %s 不存在，已跳过", token)
+		return "", nil
+	}
+	if w.written[att.ID] {
+		return att.ID, nil
+	}
+	data, mimeType, _, err := readAttachmentFile(att)
+	if err != nil {
+		w.warn("附件 %s 读取失败，已跳过: %v", att.ID, err)
+		return "", nil
+	}
+	if err := w.writeFile(att.ID, "attachment", att.Filename, mimeType, bytes.NewReader(data)); err != nil {
+		return "", err
+	}
+	return att.ID, nil
+}
+
+func (w *worldPackageWriter) addAudio(asset *model.AudioAsset) (string, error) {
+	if w.written[asset.ID] {
+		return asset.ID, nil
+	}
+	f, _, _, err := AudioOpenLocalVariant(asset, "")
+	if err != nil {
+		w.warn("音频 %s 读取失败，已跳过: %v", asset.Name, err)
+		return "", nil
+	}
+	defer f.Close()
+	name := asset.Name + filepath.Ext(asset.ObjectKey)
+	if err := w.writeFile(asset.ID, "audio", name, mime.TypeByExtension(filepath.Ext(asset.ObjectKey)), f); err != nil {
+		return "", err
+	}
+	return asset.ID, nil
+}
+
+func ensureWorldPackageAdmin(worldID, actorID string) error {
+	if IsWorldAdmin(worldID, actorID) || pm.CanWithSystemRole(actorID, pm.PermModAdmin) {
+		return nil
+	}
+	return ErrWorldPermission
+}
+
+// WorldPackageExport 将世界导出为 zip 写入 w
+func WorldPackageExport(worldID, actorID string, w io.Writer) error {
+	world, err := GetWorldByID(worldID)
+	if err != nil {
+		return err
+	}
+	if err := ensureWorldPackageAdmin(world.ID, actorID); err != nil {
+		return err
+	}
+	channels, err := ChannelListByWorld(world.ID)
+	if err != nil {
+		return err
+	}
+	channelIDs := lo.Map(channels, func(ch *model.ChannelModel, _ int) string { return ch.ID })
+
+	manifest := &WorldPackageManifest{
+		Version:    WorldPackageVersion,
+		ExportedAt: time.Now(),
+		World: WorldPackageWorld{
+			Name:                       world.Name,
+			Description:                world.Description,
+			Visibility:                 world.Visibility,
+			AllowAdminEditMessages:     world.AllowAdminEditMessages,
+			AllowMemberEditKeywords:    world.AllowMemberEditKeywords,
+			CharacterCardBadgeTemplate: world.CharacterCardBadgeTemplate,
+			Tags:                       world.Tags,
+			Language:                   world.Language,
+			Genre:                      world.Genre,
+		},
+	}
+	pw := &worldPackageWriter{zw: zip.NewWriter(w), manifest: manifest, written: map[string]bool{}}
+	db := model.GetDB()
+
+	channelSet := lo.SliceToMap(channelIDs, func(id string) (string, struct{}) { return id, struct{}{} })
+	if manifest.WorldRoles, err = worldPackageExportWorldRoles(db, world.ID, channelSet); err != nil {
+		return err
+	}
+	for _, ch := range channels {
+		item := WorldPackageChannel{
+			ID:                 ch.ID,
+			Name:               ch.Name,
+			Note:               ch.Note,
+			PermType:           ch.PermType,
+			Kind:               ch.Kind,
+			PermOverride:       ch.PermOverride,
+			ReadReceipts:       ch.ReadReceipts,
+			SortOrder:          ch.SortOrder,
+			IsDefault:          ch.ID == world.DefaultChannelID,
+			DefaultDiceExpr:    ch.DefaultDiceExpr,
+			BuiltInDiceEnabled: ch.BuiltInDiceEnabled,
+			BotFeatureEnabled:  ch.BotFeatureEnabled,
+			BackgroundSettings: ch.BackgroundSettings,
+		}
+		if _, ok := channelSet[ch.ParentID]; ok {
+			item.ParentID = ch.ParentID
+		}
+		if item.BackgroundFileID, err = pw.addAttachment(ch.BackgroundAttachmentId); err != nil {
+			return err
+		}
+		for _, roleID := range ch.GMRoleIDs {
+			if key, ok := extractRoleKey(roleID, ch.ID); ok {
+				item.GMRoleKeys = append(item.GMRoleKeys, key)
+			}
+		}
+		if item.Roles, err = worldPackageExportRoles(db, ch.ID); err != nil {
+			return err
+		}
+		manifest.Channels = append(manifest.Channels, item)
+	}
+
+	var keywords []*model.WorldKeywordModel
+	if err := db.Where("world_id = ?", world.ID).Order("category ASC, keyword ASC").Find(&keywords).Error; err != nil {
+		return err
+	}
+	for _, kw := range keywords {
+		sortOrder, enabled := kw.SortOrder, kw.IsEnabled
+		manifest.Keywords = append(manifest.Keywords, WorldKeywordInput{
+			Keyword:           kw.Keyword,
+			Category:          kw.Category,
+			Aliases:           kw.Aliases,
+			MatchMode:         string(kw.MatchMode),
+			Description:       kw.Description,
+			DescriptionFormat: string(kw.DescriptionFormat),
+			Display:           string(kw.Display),
+			SortOrder:         &sortOrder,
+			Enabled:           &enabled,
+		})
+	}
+
+	if len(channelIDs) > 0 {
+		if err := worldPackageExportChannelData(db, pw, channelIDs, actorID); err != nil {
+			return err
+		}
+	}
+	if err := worldPackageExportAudio(db, pw, world.ID, channelIDs); err != nil {
+		return err
+	}
+
+	mw, err := pw.zw.Create(worldPackageManifestName)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(mw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return err
+	}
+	return pw.zw.Close()
+}
+
+func worldPackageExportRoles(db *gorm.DB, channelID string) ([]WorldPackageRole, error) {
+	var roles []model.ChannelRoleModel
+	if err := db.Where("channel_id = ?", channelID).Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	roleIDs := lo.Map(roles, func(r model.ChannelRoleModel, _ int) string { return r.ID })
+	var perms []model.RolePermissionModel
+	if len(roleIDs) > 0 {
+		if err := db.Where("role_id IN ?", roleIDs).Find(&perms).Error; err != nil {
+			return nil, err
+		}
+	}
+	permsByRole := lo.GroupBy(perms, func(p model.RolePermissionModel) string { return p.RoleID })
+	var items []WorldPackageRole
+	for _, role := range roles {
+		key, ok := extractRoleKey(role.ID, channelID)
+		// 世界角色的派生角色随 worldRoles 导出，导入时按新角色 ID 重新生成
+		if !ok || model.WorldRoleIDFromChannelRoleID(role.ID) != "" {
+			continue
+		}
+		items = append(items, WorldPackageRole{
+			Key:  key,
+			Name: role.Name,
+			Desc: role.Desc,
+			Permissions: lo.Map(permsByRole[role.ID], func(p model.RolePermissionModel, _ int) string {
+				return p.PermissionID
+			}),
+		})
+	}
+	return items, nil
+}
+
+func worldPackageExportWorldRoles(db *gorm.DB, worldID string, channelSet map[string]struct{}) ([]WorldPackageWorldRole, error) {
+	var roles []*model.WorldRoleModel
+	if err := db.Where("world_id = ?", worldID).Order("priority DESC").Order("created_at ASC").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	if len(roles) == 0 {
+		return nil, nil
+	}
+	var overrides []model.WorldRoleChannelOverrideModel
+	if err := db.Where("world_id = ?", worldID).Find(&overrides).Error; err != nil {
+		return nil, err
+	}
+	overridesByRole := lo.GroupBy(overrides, func(item model.WorldRoleChannelOverrideModel) string { return item.RoleID })
+	items := make([]WorldPackageWorldRole, 0, len(roles))
+	for _, role := range roles {
+		item := WorldPackageWorldRole{
+			ID:          role.ID,
+			Name:        role.Name,
+			Desc:        role.Desc,
+			Color:       role.Color,
+			Priority:    role.Priority,
+			Permissions: role.Permissions,
+		}
+		for _, override := range overridesByRole[role.ID] {
+			if _, ok := channelSet[override.ChannelID]; !ok {
+				continue
+			}
+			item.Overrides = append(item.Overrides, WorldPackageRoleOverride{
+				ChannelID:   override.ChannelID,
+				Permissions: override.Permissions,
+			})
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func worldPackageExportChannelData(db *gorm.DB, pw *worldPackageWriter, channelIDs []string, actorID string) error {
+	m := pw.manifest
+
+	var folders []model.StickyNoteFolderModel
+	if err := db.Where("channel_id IN ? AND is_deleted = ?", channelIDs, false).Find(&folders).Error; err != nil {
+		return err
+	}
+	for _, folder := range folders {
+		folder.WorldID = ""
+		folder.CreatorID = ""
+		m.StickyFolders = append(m.StickyFolders, folder)
+	}
+
+	var notes []model.StickyNoteModel
+	if err := db.Where("channel_id IN ? AND is_deleted = ?", channelIDs, false).Find(&notes).Error; err != nil {
+		return err
+	}
+	for _, note := range notes {
+		note.WorldID = ""
+		note.CreatorID = ""
+		note.ViewerIDs = ""
+		note.EditorIDs = ""
+		note.EditingLockUserID = ""
+		note.EditingLockSessionID = ""
+		note.EditingLockExpireAt = nil
+		m.StickyNotes = append(m.StickyNotes, note)
+	}
+
+	var forms []model.ChannelIFormModel
+	if err := db.Where("channel_id IN ?", channelIDs).Find(&forms).Error; err != nil {
+		return err
+	}
+	for _, form := range forms {
+		form.CreatedBy = ""
+		form.UpdatedBy = ""
+		m.IForms = append(m.IForms, form)
+	}
+
+	// 骰子宏属于个人，只导出操作者自己的
+	var macros []model.DiceMacroModel
+	if err := db.Where("channel_id IN ? AND user_id = ?", channelIDs, actorID).Find(&macros).Error; err != nil {
+		return err
+	}
+	for _, macro := range macros {
+		macro.UserID = ""
+		m.DiceMacros = append(m.DiceMacros, macro)
+	}
+
+	var templateIDs []string
+	if err := db.Model(&model.CharacterCardTemplateBindingModel{}).
+		Where("channel_id IN ? AND template_id <> ''", channelIDs).
+		Distinct("template_id").
+		Pluck("template_id", &templateIDs).Error; err != nil {
+		return err
+	}
+	if len(templateIDs) > 0 {
+		var templates []model.CharacterCardTemplateModel
+		if err := db.Where("id IN ?", templateIDs).Find(&templates).Error; err != nil {
+			return err
+		}
+		for _, tpl := range templates {
+			m.CardTemplates = append(m.CardTemplates, WorldPackageCardTemplate{
+				ID:        tpl.ID,
+				Name:      tpl.Name,
+				SheetType: tpl.SheetType,
+				Content:   tpl.Content,
+			})
+		}
+	}
+
+	var collections []model.GalleryCollection
+	if err := db.Where("owner_type = ? AND owner_id IN ?", model.OwnerTypeChannel, channelIDs).
+		Order("`order`").Find(&collections).Error; err != nil {
+		return err
+	}
+	if len(collections) == 0 {
+		return nil
+	}
+	for _, col := range collections {
+		col.QuotaUsed = 0
+		col.CreatedBy = ""
+		col.UpdatedBy = ""
+		m.GalleryCollections = append(m.GalleryCollections, col)
+	}
+	var items []model.GalleryItem
+	collectionIDs := lo.Map(collections, func(c model.GalleryCollection, _ int) string { return c.ID })
+	if err := db.Where("collection_id IN ?", collectionIDs).Order("`order`").Find(&items).Error; err != nil {
+		return err
+	}
+	for _, item := range items {
+		fileID, err := pw.addAttachment(item.AttachmentID)
+		if err != nil {
+			return err
+		}
+		if fileID == "" {
+			continue
+		}
+		item.AttachmentID = fileID
+		item.ThumbURL = ""
+		item.CreatedBy = ""
+		m.GalleryItems = append(m.GalleryItems, item)
+	}
+	return nil
+}
+
+func worldPackageExportAudio(db *gorm.DB, pw *worldPackageWriter, worldID string, channelIDs []string) error {
+	m := pw.manifest
+	query := db.Where("scope = ? AND world_id = ?", model.AudioScopeWorld, worldID)
+	if len(channelIDs) > 0 {
+		query = db.Where(query).Or("channel_scope IN ?", channelIDs)
+	}
+	var scenes []model.AudioScene
+	if err := query.Order("`order`").Find(&scenes).Error; err != nil {
+		return err
+	}
+
+	var assets []*model.AudioAsset
+	if err := db.Where("scope = ? AND world_id = ? AND deleted_at IS NULL", model.AudioScopeWorld, worldID).
+		Find(&assets).Error; err != nil {
+		return err
+	}
+	known := lo.SliceToMap(assets, func(a *model.AudioAsset) (string, bool) { return a.ID, true })
+	var referenced []string
+	for _, scene := range scenes {
+		for _, track := range scene.Tracks {
+			if track.AssetID != nil && *track.AssetID != "" && !known[*track.AssetID] {
+				referenced = append(referenced, *track.AssetID)
+			}
+		}
+	}
+	if referenced = lo.Uniq(referenced); len(referenced) > 0 {
+		var extra []*model.AudioAsset
+		if err := db.Where("id IN ? AND deleted_at IS NULL", referenced).Find(&extra).Error; err != nil {
+			return err
+		}
+		assets = append(assets, extra...)
+	}
+
+	exported := map[string]bool{}
+	for _, asset := range assets {
+		fileID, err := pw.addAudio(asset)
+		if err != nil {
+			return err
+		}
+		if fileID == "" {
+			continue
+		}
+		exported[asset.ID] = true
+		m.AudioAssets = append(m.AudioAssets, WorldPackageAudioAsset{
+			ID:          asset.ID,
+			Name:        asset.Name,
+			Description: asset.Description,
+			Tags:        asset.Tags,
+			Size:        asset.Size,
+			WorldScoped: asset.Scope == model.AudioScopeWorld,
+			FileID:      fileID,
+		})
+	}
+
+	for _, scene := range scenes {
+		for i := range scene.Tracks {
+			if id := scene.Tracks[i].AssetID; id != nil && !exported[*id] {
+				scene.Tracks[i].AssetID = nil
+			}
+		}
+		scene.WorldID = nil
+		scene.CreatedBy = ""
+		scene.UpdatedBy = ""
+		m.AudioScenes = append(m.AudioScenes, scene)
+	}
+	return nil
+}
+
+type worldPackageReader struct {
+	zr       *zip.Reader
+	manifest *WorldPackageManifest
+	files    map[string]WorldPackageFile
+}
+
+func openWorldPackage(r io.ReaderAt, size int64) (*worldPackageReader, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, ErrWorldPackageInvalid
+	}
+	var manifestFile *zip.File
+	for _, f := range zr.File {
+		if f.Name == worldPackageManifestName {
+			manifestFile = f
+			break
+		}
+	}
+	if manifestFile == nil || manifestFile.UncompressedSize64 > worldPackageMaxManifest {
+		return nil, ErrWorldPackageInvalid
+	}
+	rc, err := manifestFile.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	var manifest WorldPackageManifest
+	if err := json.NewDecoder(io.LimitReader(rc, worldPackageMaxManifest)).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWorldPackageInvalid, err)
+	}
+	if manifest.Version <= 0 || manifest.Version > WorldPackageVersion {
+		return nil, fmt.Errorf("不支持的世界包版本: %d", manifest.Version)
+	}
+	if strings.TrimSpace(manifest.World.Name) == "" {
+		return nil, fmt.Errorf("%w: 缺少世界名称", ErrWorldPackageInvalid)
+	}
+	return &worldPackageReader{
+		zr:       zr,
+		manifest: &manifest,
+		files:    lo.SliceToMap(manifest.Files, func(f WorldPackageFile) (string, WorldPackageFile) { return f.ID, f }),
+	}, nil
+}
+
+// extractFile 把包内文件解压到临时文件，超过 limit 时报错；调用方负责删除返回的临时文件
+func (p *worldPackageReader) extractFile(fileID string, limit int64) (string, WorldPackageFile, error) {
+	meta, ok := p.files[fileID]
+	if !ok {
+		return "", meta, fmt.Errorf("包内缺少文件 %s", fileID)
+	}
+	entryPath := path.Clean(meta.Path)
+	if !strings.HasPrefix(entryPath, worldPackageFileDir) {
+		return "", meta, fmt.Errorf("非法的文件路径 %s", meta.Path)
+	}
+	if limit <= 0 {
+		limit = worldPackageMaxFile
+	}
+	for _, f := range p.zr.File {
+		if f.Name != entryPath {
+			continue
+		}
+		if f.UncompressedSize64 > uint64(limit) {
+			return "", meta, fmt.Errorf("文件 %s 过大", meta.Name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return "", meta, err
+		}
+		defer rc.Close()
+		// 压缩包声明的大小不可信，按实际解压出的字节数再限制一次
+		tempPath, size, err := worldPackageTempFile(io.LimitReader(rc, limit+1), "world-package-*"+filepath.Ext(meta.Name))
+		if err != nil {
+			return "", meta, err
+		}
+		if size > limit {
+			_ = os.Remove(tempPath)
+			return "", meta, fmt.Errorf("文件 %s 过大", meta.Name)
+		}
+		return tempPath, meta, nil
+	}
+	return "", meta, fmt.Errorf("包内缺少文件 %s", meta.Path)
+}
+
+// sortedChannels 父频道排在子频道之前
+func (p *worldPackageReader) sortedChannels() []WorldPackageChannel {
+	byID := lo.SliceToMap(p.manifest.Channels, func(ch WorldPackageChannel) (string, WorldPackageChannel) { return ch.ID, ch })
+	depth := func(ch WorldPackageChannel) int {
+		d := 0
+		for seen := map[string]bool{}; ch.ParentID != "" && !seen[ch.ParentID]; d++ {
+			seen[ch.ParentID] = true
+			parent, ok := byID[ch.ParentID]
+			if !ok {
+				break
+			}
+			ch = parent
+		}
+		return d
+	}
+	items := append([]WorldPackageChannel(nil), p.manifest.Channels...)
+	sort.SliceStable(items, func(i, j int) bool { return depth(items[i]) < depth(items[j]) })
+	return items
+}
+
+type WorldPackagePreviewResult struct {
+	Version    int               `json:"version"`
+	ExportedAt time.Time         `json:"exportedAt"`
+	World      WorldPackageWorld `json:"world"`
+	Counts     map[string]int    `json:"counts"`
+	Conflicts  []string          `json:"conflicts"`
+	Warnings   []string          `json:"warnings"`
+}
+
+// WorldPackagePreview 解析世界包并列出导入时的冲突，不落库
+func WorldPackagePreview(r io.ReaderAt, size int64, actorID, targetWorldID string) (*WorldPackagePreviewResult, error) {
+	pkg, err := openWorldPackage(r, size)
+	if err != nil {
+		return nil, err
+	}
+	m := pkg.manifest
+	result := &WorldPackagePreviewResult{
+		Version:    m.Version,
+		ExportedAt: m.ExportedAt,
+		World:      m.World,
+		Counts: map[string]int{
+			"channels":           len(m.Channels),
+			"worldRoles":         len(m.WorldRoles),
+			"keywords":           len(m.Keywords),
+			"stickyFolders":      len(m.StickyFolders),
+			"stickyNotes":        len(m.StickyNotes),
+			"iforms":             len(m.IForms),
+			"diceMacros":         len(m.DiceMacros),
+			"audioAssets":        len(m.AudioAssets),
+			"audioScenes":        len(m.AudioScenes),
+			"cardTemplates":      len(m.CardTemplates),
+			"galleryCollections": len(m.GalleryCollections),
+			"galleryItems":       len(m.GalleryItems),
+			"files":              len(m.Files),
+		},
+		Conflicts: []string{},
+		Warnings:  append([]string{}, m.Warnings...),
+	}
+	db := model.GetDB()
+
+	targetWorldID = strings.TrimSpace(targetWorldID)
+	if targetWorldID != "" {
+		if _, err := GetWorldByID(targetWorldID); err != nil {
+			return nil, err
+		}
+		if err := ensureWorldPackageAdmin(targetWorldID, actorID); err != nil {
+			return nil, err
+		}
+		existing, err := ChannelListByWorld(targetWorldID)
+		if err != nil {
+			return nil, err
+		}
+		names := lo.SliceToMap(existing, func(ch *model.ChannelModel) (string, bool) { return ch.Name, true })
+		for _, ch := range m.Channels {
+			if names[ch.Name] {
+				result.Conflicts = append(result.Conflicts, fmt.Sprintf("频道「%s」已存在", ch.Name))
+			}
+		}
+		if len(m.WorldRoles) > 0 {
+			roleNames := lo.Map(m.WorldRoles, func(r WorldPackageWorldRole, _ int) string { return strings.TrimSpace(r.Name) })
+			var dup []string
+			if err := db.Model(&model.WorldRoleModel{}).
+				Where("world_id = ? AND name IN ?", targetWorldID, roleNames).
+				Pluck("name", &dup).Error; err != nil {
+				return nil, err
+			}
+			for _, name := range dup {
+				result.Conflicts = append(result.Conflicts, fmt.Sprintf("世界角色「%s」已存在", name))
+			}
+		}
+		if len(m.Keywords) > 0 {
+			keywords := lo.Map(m.Keywords, func(k WorldKeywordInput, _ int) string { return strings.TrimSpace(k.Keyword) })
+			var dup []string
+			if err := db.Model(&model.WorldKeywordModel{}).
+				Where("world_id = ? AND keyword IN ?", targetWorldID, keywords).
+				Pluck("keyword", &dup).Error; err != nil {
+				return nil, err
+			}
+			for _, kw := range dup {
+				result.Conflicts = append(result.Conflicts, fmt.Sprintf("术语「%s」已存在", kw))
+			}
+		}
+	} else {
+		var count int64
+		db.Model(&model.WorldModel{}).
+			Where("owner_id = ? AND name = ? AND status = ?", actorID, strings.TrimSpace(m.World.Name), "active").
+			Count(&count)
+		if count > 0 {
+			result.Conflicts = append(result.Conflicts, fmt.Sprintf("你已拥有名为「%s」的世界", m.World.Name))
+		}
+	}
+
+	if len(m.CardTemplates) > 0 {
+		names := lo.Map(m.CardTemplates, func(t WorldPackageCardTemplate, _ int) string { return t.Name })
+		var dup []string
+		if err := db.Model(&model.CharacterCardTemplateModel{}).
+			Where("user_id = ? AND name IN ?", actorID, names).
+			Pluck("name", &dup).Error; err != nil {
+			return nil, err
+		}
+		for _, name := range lo.Uniq(dup) {
+			result.Conflicts = append(result.Conflicts, fmt.Sprintf("人物卡模板「%s」已存在", name))
+		}
+	}
+
+	for _, f := range m.Files {
+		if _, ok := lo.Find(pkg.zr.File, func(zf *zip.File) bool { return zf.Name == path.Clean(f.Path) }); !ok {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("包内缺少文件 %s", f.Name))
+		}
+	}
+	return result, nil
+}
+
+type WorldPackageImportOptions struct {
+	TargetWorldID    string `json:"targetWorldId"`
+	Name             string `json:"name"`
+	ConflictStrategy string `json:"conflictStrategy"`
+}
+
+type WorldPackageImportResult struct {
+	WorldID    string            `json:"worldId"`
+	Created    map[string]int    `json:"created"`
+	Skipped    map[string]int    `json:"skipped"`
+	Warnings   []string          `json:"warnings"`
+	ChannelMap map[string]string `json:"channelMap"`
+}
+
+type worldPackageImporter struct {
+	pkg       *worldPackageReader
+	actorID   string
+	worldID   string
+	strategy  string
+	result    *WorldPackageImportResult
+	channels  map[string]string   // 包内频道 ID -> 新频道 ID
+	fresh     map[string]bool     // 本次新建的频道，只有它们导入便签等附属内容
+	rolePerms map[string][]string // 需要同步到内存的角色权限
+	// worldRoles 包内世界角色 ID -> 目标世界角色；skipped 的角色也记录，以便映射 GM 角色与覆盖
+	worldRoles map[string]*model.WorldRoleModel
+	assets     map[string]string
+	files      map[string]string // 包内附件文件 ID -> 新附件 ID
+	created    []string          // 新建频道，失败时清理
+	// rollback 导入中途失败时按相反顺序执行，撤销新建的记录并恢复被覆盖的记录。
+	// 导入涉及文件存储与转码，无法放进一个数据库事务，这里逐项补偿
+	rollback []func(db *gorm.DB) error
+}
+
+func (im *worldPackageImporter) warn(format string, args ...any) {
+	im.result.Warnings = append(im.result.Warnings, fmt.Sprintf(format, args...))
+}
+
+func (im *worldPackageImporter) onRollback(fn func(db *gorm.DB) error) {
+	im.rollback = append(im.rollback, fn)
+}
+
+// restoreOnRollback 记录 row 当前的 columns，回滚时写回；row 须为已加载的模型指针
+func (im *worldPackageImporter) restoreOnRollback(row any, columns ...string) {
+	im.onRollback(func(db *gorm.DB) error {
+		return db.Model(row).Select(columns).Updates(row).Error
+	})
+}
+
+func (im *worldPackageImporter) rollbackAll() {
+	db := model.GetDB()
+	for i := len(im.rollback) - 1; i >= 0; i-- {
+		if err := im.rollback[i](db); err != nil {
+			log.Printf("世界包导入回滚失败: %v", err)
+		}
+	}
+}
+
+// WorldPackageImport 导入世界包；TargetWorldID 为空时新建世界，否则合并到已有世界
+func WorldPackageImport(r io.ReaderAt, size int64, actorID string, opts WorldPackageImportOptions) (*WorldPackageImportResult, error) {
+	pkg, err := openWorldPackage(r, size)
+	if err != nil {
+		return nil, err
+	}
+	strategy := strings.TrimSpace(opts.ConflictStrategy)
+	switch strategy {
+	case "":
+		strategy = WorldPackageConflictSkip
+	case WorldPackageConflictSkip, WorldPackageConflictOverwrite, WorldPackageConflictRename:
+	default:
+		return nil, fmt.Errorf("未知的冲突处理方式: %s", strategy)
+	}
+	defer model.PermCacheInvalidateAll()
+
+	im := &worldPackageImporter{
+		pkg:      pkg,
+		actorID:  actorID,
+		strategy: strategy,
+		result: &WorldPackageImportResult{
+			Created:    map[string]int{},
+			Skipped:    map[string]int{},
+			Warnings:   append([]string{}, pkg.manifest.Warnings...),
+			ChannelMap: map[string]string{},
+		},
+		fresh:      map[string]bool{},
+		rolePerms:  map[string][]string{},
+		worldRoles: map[string]*model.WorldRoleModel{},
+		assets:     map[string]string{},
+		files:      map[string]string{},
+	}
+	im.channels = im.result.ChannelMap
+
+	newWorld := strings.TrimSpace(opts.TargetWorldID) == ""
+	var defaultChannel *model.ChannelModel
+	if newWorld {
+		w := pkg.manifest.World
+		name := strings.TrimSpace(opts.Name)
+		if name == "" {
+			name = w.Name
+		}
+		world, ch, err := WorldCreate(actorID, WorldCreateParams{
+			Name:        name,
+			Description: w.Description,
+			Visibility:  w.Visibility,
+		})
+		if err != nil {
+			return nil, err
+		}
+		im.worldID = world.ID
+		defaultChannel = ch
+		if err := model.GetDB().Model(&model.WorldModel{}).Where("id = ?", world.ID).Updates(map[string]any{
+			"allow_admin_edit_messages":     w.AllowAdminEditMessages,
+			"allow_member_edit_keywords":    w.AllowMemberEditKeywords,
+			"character_card_badge_template": w.CharacterCardBadgeTemplate,
+		}).Error; err != nil {
+			_ = WorldDelete(world.ID, actorID)
+			return nil, err
+		}
+		if _, err := WorldUpdate(world.ID, actorID, WorldUpdateParams{
+			Tags:     &w.Tags,
+			Language: &w.Language,
+			Genre:    &w.Genre,
+		}); err != nil {
+			_ = WorldDelete(world.ID, actorID)
+			return nil, err
+		}
+	} else {
+		im.worldID = strings.TrimSpace(opts.TargetWorldID)
+		if _, err := GetWorldByID(im.worldID); err != nil {
+			return nil, err
+		}
+		if err := ensureWorldPackageAdmin(im.worldID, actorID); err != nil {
+			return nil, err
+		}
+	}
+	im.result.WorldID = im.worldID
+
+	if err := im.run(defaultChannel); err != nil {
+		im.rollbackAll()
+		if newWorld {
+			_ = WorldDelete(im.worldID, actorID)
+		} else {
+			for _, id := range im.created {
+				cleanupClonedChannel(id)
+			}
+		}
+		return nil, err
+	}
+	applyRolePermsToMemory(im.rolePerms)
+	return im.result, nil
+}
+
+func (im *worldPackageImporter) run(defaultChannel *model.ChannelModel) error {
+	if err := im.importWorldRoles(); err != nil {
+		return err
+	}
+	if err := im.importChannels(defaultChannel); err != nil {
+		return err
+	}
+	if err := im.importWorldRoleOverrides(); err != nil {
+		return err
+	}
+	if len(im.pkg.manifest.Keywords) > 0 {
+		if err := im.snapshotKeywords(); err != nil {
+			return err
+		}
+		stats, err := WorldKeywordImport(im.worldID, im.actorID, im.pkg.manifest.Keywords, im.strategy == WorldPackageConflictOverwrite)
+		if err != nil {
+			return err
+		}
+		im.result.Created["keywords"] += stats.Created + stats.Updated
+		im.result.Skipped["keywords"] += stats.Skipped
+	}
+	if err := im.importStickyNotes(); err != nil {
+		return err
+	}
+	if err := im.importChannelExtras(); err != nil {
+		return err
+	}
+	if err := im.importAudio(); err != nil {
+		return err
+	}
+	if err := im.importCardTemplates(); err != nil {
+		return err
+	}
+	return im.importGallery()
+}
+
+// snapshotKeywords 回滚时删除本次新增的术语，并恢复被覆盖的术语
+func (im *worldPackageImporter) snapshotKeywords() error {
+	var before []*model.WorldKeywordModel
+	if err := model.GetDB().Where("world_id = ?", im.worldID).Find(&before).Error; err != nil {
+		return err
+	}
+	worldID := im.worldID
+	ids := lo.Map(before, func(item *model.WorldKeywordModel, _ int) string { return item.ID })
+	im.onRollback(func(db *gorm.DB) error {
+		q := db.Where("world_id = ?", worldID)
+		if len(ids) > 0 {
+			q = q.Where("id NOT IN ?", ids)
+		}
+		if err := q.Delete(&model.WorldKeywordModel{}).Error; err != nil {
+			return err
+		}
+		if im.strategy != WorldPackageConflictOverwrite {
+			return nil
+		}
+		for _, item := range before {
+			if err := db.Save(item).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return nil
+}
+
+func (im *worldPackageImporter) importChannels(defaultChannel *model.ChannelModel) error {
+	db := model.GetDB()
+	existing, err := ChannelListByWorld(im.worldID)
+	if err != nil {
+		return err
+	}
+	for _, item := range im.pkg.sortedChannels() {
+		name := strings.TrimSpace(item.Name)
+		if name == "" {
+			name = "未命名频道"
+		}
+		parentID := im.channels[item.ParentID]
+		permType := item.PermType
+		if permType != "public" && permType != "non-public" {
+			permType = "public"
+		}
+		kind := ""
+		if item.Kind == model.ChannelKindCategory {
+			kind = model.ChannelKindCategory
+		}
+
+		targetID := ""
+		fresh := true
+		if defaultChannel != nil && item.IsDefault {
+			// 新建世界自带的大厅直接承接包内的默认频道
+			targetID = defaultChannel.ID
+			defaultChannel = nil
+		} else if dup, ok := lo.Find(existing, func(ch *model.ChannelModel) bool {
+			return ch.Name == name && ch.ParentID == parentID
+		}); ok {
+			switch im.strategy {
+			case WorldPackageConflictSkip:
+				im.channels[item.ID] = dup.ID
+				im.result.Skipped["channels"]++
+				continue
+			case WorldPackageConflictOverwrite:
+				targetID = dup.ID
+				fresh = false
+			default:
+				name += worldPackageRenameSuffix
+			}
+		}
+		if targetID == "" {
+			ch := ChannelNew(utils.NewID(), permType, name, im.worldID, im.actorID, parentID)
+			if ch == nil || ch.ID == "" {
+				return errors.New("创建频道失败")
+			}
+			targetID = ch.ID
+			im.created = append(im.created, ch.ID)
+		}
+		im.channels[item.ID] = targetID
+		im.fresh[targetID] = fresh
+
+		if err := im.applyRoles(db, targetID, item.Roles); err != nil {
+			return err
+		}
+		gmRoleIDs := model.JSONList[string]{}
+		for _, key := range item.GMRoleKeys {
+			if oldRoleID := model.WorldRoleIDFromChannelRoleID(fmt.Sprintf("ch-%s-%s", item.ID, key)); oldRoleID != "" {
+				if role := im.worldRoles[oldRoleID]; role != nil {
+					gmRoleIDs = append(gmRoleIDs, model.WorldRoleChannelRoleID(targetID, role.ID))
+				}
+				continue
+			}
+			if _, ok := im.rolePerms[fmt.Sprintf("ch-%s-%s", targetID, key)]; ok {
+				gmRoleIDs = append(gmRoleIDs, fmt.Sprintf("ch-%s-%s", targetID, key))
+			}
+		}
+		updates := map[string]any{
+			"name":                  name,
+			"perm_type":             permType,
+			"kind":                  kind,
+			"perm_override":         item.PermOverride,
+			"read_receipts":         item.ReadReceipts,
+			"note":                  item.Note,
+			"sort_order":            item.SortOrder,
+			"default_dice_expr":     item.DefaultDiceExpr,
+			"built_in_dice_enabled": item.BuiltInDiceEnabled,
+			"bot_feature_enabled":   item.BotFeatureEnabled,
+			"background_settings":   item.BackgroundSettings,
+			"gm_role_ids":           gmRoleIDs,
+		}
+		if item.BackgroundFileID != "" {
+			if attID := im.attachment(item.BackgroundFileID, targetID); attID != "" {
+				updates["background_attachment_id"] = attID
+			}
+		}
+		if !fresh {
+			var prev model.ChannelModel
+			if err := db.Where("id = ?", targetID).Limit(1).Find(&prev).Error; err != nil {
+				return err
+			}
+			im.restoreOnRollback(&prev, lo.Uniq(append(lo.Keys(updates), "background_attachment_id"))...)
+		}
+		if err := db.Model(&model.ChannelModel{}).Where("id = ?", targetID).Updates(updates).Error; err != nil {
+			return err
+		}
+		if fresh {
+			im.result.Created["channels"]++
+		} else {
+			im.result.Created["channelsOverwritten"]++
+		}
+	}
+	return nil
+}
+
+// importWorldRoles 在频道之前导入世界角色，新建频道会自动派生它们；同名角色按冲突策略处理
+func (im *worldPackageImporter) importWorldRoles() error {
+	if len(im.pkg.manifest.WorldRoles) == 0 {
+		return nil
+	}
+	db := model.GetDB()
+	var existing []*model.WorldRoleModel
+	if err := db.Where("world_id = ?", im.worldID).Find(&existing).Error; err != nil {
+		return err
+	}
+	count := len(existing)
+	for _, item := range im.pkg.manifest.WorldRoles {
+		perms, err := normalizeWorldRolePermissions(lo.Filter(item.Permissions, func(p string, _ int) bool {
+			_, ok := gen.PermChannelMap[p]
+			return ok
+		}))
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSpace(item.Name)
+		if name == "" || len([]rune(name)) > worldRoleMaxNameLen {
+			im.warn("世界角色「%s」名称无效，已跳过", item.Name)
+			continue
+		}
+		if dup, ok := lo.Find(existing, func(r *model.WorldRoleModel) bool { return r.Name == name }); ok {
+			switch im.strategy {
+			case WorldPackageConflictSkip:
+				im.worldRoles[item.ID] = dup
+				im.result.Skipped["worldRoles"]++
+				continue
+			case WorldPackageConflictOverwrite:
+				prev := *dup
+				im.onRollback(func(db *gorm.DB) error {
+					if err := db.Model(&prev).Select("desc", "color", "priority", "permissions").Updates(&prev).Error; err != nil {
+						return err
+					}
+					return worldRolePropagate(&prev)
+				})
+				dup.Desc = item.Desc
+				dup.Color = item.Color
+				dup.Priority = item.Priority
+				dup.Permissions = perms
+				if err := db.Model(dup).Updates(map[string]any{
+					"desc":        dup.Desc,
+					"color":       dup.Color,
+					"priority":    dup.Priority,
+					"permissions": dup.Permissions,
+					"updated_at":  time.Now(),
+				}).Error; err != nil {
+					return err
+				}
+				im.worldRoles[item.ID] = dup
+				im.result.Created["worldRolesOverwritten"]++
+				continue
+			default:
+				name = string(lo.Subset([]rune(name+worldPackageRenameSuffix), 0, worldRoleMaxNameLen))
+			}
+		}
+		if count >= worldRoleMaxCount {
+			im.warn("世界角色已达上限 %d 个，「%s」未导入", worldRoleMaxCount, name)
+			continue
+		}
+		role := &model.WorldRoleModel{
+			WorldID:     im.worldID,
+			Name:        name,
+			Desc:        string(lo.Subset([]rune(item.Desc), 0, worldRoleMaxDescLen)),
+			Color:       string(lo.Subset([]rune(item.Color), 0, 16)),
+			Priority:    item.Priority,
+			Permissions: perms,
+			CreatorID:   im.actorID,
+		}
+		if err := db.Create(role).Error; err != nil {
+			return err
+		}
+		im.onRollback(func(db *gorm.DB) error {
+			return worldRolePurge(db, role)
+		})
+		existing = append(existing, role)
+		count++
+		im.worldRoles[item.ID] = role
+		im.result.Created["worldRoles"]++
+	}
+	return nil
+}
+
+// importWorldRoleOverrides 写入本次导入频道的权限覆盖，然后把导入的世界角色派生到世界内所有频道
+func (im *worldPackageImporter) importWorldRoleOverrides() error {
+	db := model.GetDB()
+	for _, item := range im.pkg.manifest.WorldRoles {
+		role := im.worldRoles[item.ID]
+		if role == nil {
+			continue
+		}
+		for _, override := range item.Overrides {
+			channelID := im.channels[override.ChannelID]
+			// 跳过的频道保持原样，只有新建或覆盖的频道接收包内覆盖
+			if _, ok := im.fresh[channelID]; !ok || channelID == "" {
+				continue
+			}
+			perms, err := normalizeWorldRolePermissions(lo.Filter(override.Permissions, func(p string, _ int) bool {
+				_, ok := gen.PermChannelMap[p]
+				return ok
+			}))
+			if err != nil {
+				return err
+			}
+			var prev model.WorldRoleChannelOverrideModel
+			if err := db.Where("role_id = ? AND channel_id = ?", role.ID, channelID).Limit(1).Find(&prev).Error; err != nil {
+				return err
+			}
+			if prev.ID != "" {
+				im.restoreOnRollback(&prev, "permissions")
+				if err := db.Model(&prev).Updates(map[string]any{
+					"permissions": model.JSONList[string](perms),
+					"updated_at":  time.Now(),
+				}).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			row := &model.WorldRoleChannelOverrideModel{
+				WorldID:     im.worldID,
+				RoleID:      role.ID,
+				ChannelID:   channelID,
+				Permissions: perms,
+			}
+			if err := db.Create(row).Error; err != nil {
+				return err
+			}
+			im.onRollback(func(db *gorm.DB) error {
+				return db.Delete(row).Error
+			})
+		}
+	}
+	for _, role := range lo.Uniq(lo.Values(im.worldRoles)) {
+		if err := worldRolePropagate(role); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (im *worldPackageImporter) applyRoles(db *gorm.DB, channelID string, roles []WorldPackageRole) error {
+	for _, role := range roles {
+		key := strings.TrimSpace(role.Key)
+		if key == "" || len(key) > 64 {
+			continue
+		}
+		roleID := fmt.Sprintf("ch-%s-%s", channelID, key)
+		// 旧版本导出的包可能带有世界角色的派生角色，它们由 worldRoles 重新生成
+		if model.WorldRoleIDFromChannelRoleID(roleID) != "" {
+			continue
+		}
+		var existing model.ChannelRoleModel
+		if err := db.Where("id = ?", roleID).Limit(1).Find(&existing).Error; err != nil {
+			return err
+		}
+		if existing.ID == "" {
+			im.onRollback(func(db *gorm.DB) error {
+				if err := db.Where("role_id = ?", roleID).Delete(&model.RolePermissionModel{}).Error; err != nil {
+					return err
+				}
+				return db.Where("id = ?", roleID).Delete(&model.ChannelRoleModel{}).Error
+			})
+			if err := db.Create(&model.ChannelRoleModel{
+				StringPKBaseModel: model.StringPKBaseModel{ID: roleID},
+				Name:              role.Name,
+				Desc:              role.Desc,
+				ChannelID:         channelID,
+			}).Error; err != nil {
+				return err
+			}
+		} else {
+			var prevPerms []model.RolePermissionModel
+			if err := db.Where("role_id = ?", roleID).Find(&prevPerms).Error; err != nil {
+				return err
+			}
+			im.restoreOnRollback(&existing, "name", "desc")
+			im.onRollback(func(db *gorm.DB) error {
+				if err := db.Where("role_id = ?", roleID).Delete(&model.RolePermissionModel{}).Error; err != nil {
+					return err
+				}
+				if len(prevPerms) == 0 {
+					return nil
+				}
+				return db.Create(&prevPerms).Error
+			})
+			if err := db.Model(&model.ChannelRoleModel{}).Where("id = ?", roleID).
+				Updates(map[string]any{"name": role.Name, "desc": role.Desc}).Error; err != nil {
+				return err
+			}
+		}
+
+		// 只接受本实例认识的频道权限
+		perms := lo.Uniq(lo.Filter(role.Permissions, func(p string, _ int) bool {
+			_, ok := gen.PermChannelMap[p]
+			return ok
+		}))
+		if err := db.Where("role_id = ?", roleID).Delete(&model.RolePermissionModel{}).Error; err != nil {
+			return err
+		}
+		if len(perms) > 0 {
+			rows := lo.Map(perms, func(p string, _ int) model.RolePermissionModel {
+				return model.RolePermissionModel{
+					StringPKBaseModel: model.StringPKBaseModel{ID: utils.NewID()},
+					RoleID:            roleID,
+					PermissionID:      p,
+				}
+			})
+			if err := db.Create(&rows).Error; err != nil {
+				return err
+			}
+		}
+		im.rolePerms[roleID] = perms
+	}
+	return nil
+}
+
+// attachment 从包内文件重建附件，同一文件只建一次
+func (im *worldPackageImporter) attachment(fileID, channelID string) string {
+	if id, ok := im.files[fileID]; ok {
+		return id
+	}
+	tempPath, _, err := im.pkg.extractFile(fileID, uploadSizeLimit(model.UploadPurposeAttachment, ""))
+	if err == nil {
+		var attID string
+		attID, err = worldPackageCreateAttachment(tempPath, im.actorID, channelID)
+		_ = os.Remove(tempPath)
+		if err == nil {
+			im.files[fileID] = attID
+			im.onRollback(func(db *gorm.DB) error {
+				return db.Where("id = ?", attID).Delete(&model.AttachmentModel{}).Error
+			})
+			return attID
+		}
+	}
+	im.warn("图片 %s 导入失败: %v", fileID, err)
+	im.files[fileID] = ""
+	return ""
+}
+
+// worldPackageCreateAttachment 按文件内容判断类型，不采信包内声明的 mimeType；
+// 同一文件已存在时复用存储，但为导入的频道新建附件记录，不把其他频道的附件 ID 暴露出去
+func worldPackageCreateAttachment(tempPath, userID, channelID string) (string, error) {
+	f, err := os.Open(tempPath)
+	if err != nil {
+		return "", err
+	}
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	mimeType := http.DetectContentType(head[:n])
+	if parsed, _, err := mime.ParseMediaType(mimeType); err == nil {
+		mimeType = parsed
+	}
+	h, err := blake2s.New256(nil)
+	if err != nil {
+		_ = f.Close()
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		_ = f.Close()
+		return "", err
+	}
+	size, err := io.Copy(h, f)
+	_ = f.Close()
+	if err != nil {
+		return "", err
+	}
+	hash := h.Sum(nil)
+
+	if AttachmentMediaKind(mimeType) != AttachmentKindImage {
+		cfg := utils.GetConfig()
+		if cfg == nil || !AttachmentMimeAllowed(mimeType, cfg.Media.AllowedMimeTypes) {
+			return "", fmt.Errorf("不支持的文件类型 %s", mimeType)
+		}
+	}
+	if limit := uploadSizeLimit(model.UploadPurposeAttachment, mimeType); limit > 0 && size > limit {
+		return "", fmt.Errorf("文件过大")
+	}
+
+	item := &model.AttachmentModel{
+		Size:      size,
+		Hash:      hash,
+		MimeType:  mimeType,
+		ChannelID: channelID,
+		UserID:    userID,
+	}
+	if existing, err := model.AttachmentFindByHashAndSize(hash, size); err != nil {
+		return "", err
+	} else if existing != nil {
+		item.Filename = existing.Filename
+		item.IsAnimated = existing.IsAnimated
+		item.StorageType = existing.StorageType
+		item.ObjectKey = existing.ObjectKey
+		item.ExternalURL = existing.ExternalURL
+	} else {
+		// 持久化会移走临时文件，这里复制一份，调用方仍按原路径清理
+		copyPath, err := worldPackageCopyTemp(tempPath)
+		if err != nil {
+			return "", err
+		}
+		location, err := PersistAttachmentFile(hash, size, copyPath, mimeType)
+		if err != nil {
+			_ = os.Remove(copyPath)
+			return "", err
+		}
+		item.Filename = generateFilename(hash, size, mimeType)
+		item.StorageType = location.StorageType
+		item.ObjectKey = location.ObjectKey
+		item.ExternalURL = location.ExternalURL
+	}
+	_, created := model.AttachmentCreate(item)
+	if created == nil || created.ID == "" {
+		return "", errors.New("创建附件失败")
+	}
+	return created.ID, nil
+}
+
+// worldPackageTempFile 把 r 写入临时目录下的新文件，返回路径与写入的字节数
+func worldPackageTempFile(r io.Reader, pattern string) (string, int64, error) {
+	tmpDir := "./data/temp/"
+	if cfg := utils.GetConfig(); cfg != nil && strings.TrimSpace(cfg.Storage.Local.TempDir) != "" {
+		tmpDir = cfg.Storage.Local.TempDir
+	}
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return "", 0, err
+	}
+	f, err := os.CreateTemp(tmpDir, pattern)
+	if err != nil {
+		return "", 0, err
+	}
+	size, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return "", 0, err
+	}
+	return f.Name(), size, nil
+}
+
+func worldPackageCopyTemp(src string) (string, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	dst, _, err := worldPackageTempFile(f, "*.upload")
+	return dst, err
+}
+
+// freshChannel 返回本次新建频道的 ID；已存在的频道不重复导入附属内容
+func (im *worldPackageImporter) freshChannel(packageChannelID, kind string) string {
+	id := im.channels[packageChannelID]
+	if id == "" || !im.fresh[id] {
+		im.result.Skipped[kind]++
+		return ""
+	}
+	return id
+}
+
+func (im *worldPackageImporter) importStickyNotes() error {
+	db := model.GetDB()
+	m := im.pkg.manifest
+	folderMap := map[string]string{}
+	byID := lo.SliceToMap(m.StickyFolders, func(f model.StickyNoteFolderModel) (string, model.StickyNoteFolderModel) { return f.ID, f })
+	var create func(f model.StickyNoteFolderModel, depth int) (string, error)
+	create = func(f model.StickyNoteFolderModel, depth int) (string, error) {
+		if id, ok := folderMap[f.ID]; ok {
+			return id, nil
+		}
+		channelID := im.freshChannel(f.ChannelID, "stickyFolders")
+		if channelID == "" {
+			folderMap[f.ID] = ""
+			return "", nil
+		}
+		parentID := ""
+		if parent, ok := byID[f.ParentID]; ok && depth < 32 {
+			var err error
+			if parentID, err = create(parent, depth+1); err != nil {
+				return "", err
+			}
+		}
+		clone := f
+		clone.StringPKBaseModel = model.StringPKBaseModel{ID: utils.NewID()}
+		clone.ChannelID = channelID
+		clone.WorldID = im.worldID
+		clone.ParentID = parentID
+		clone.CreatorID = im.actorID
+		clone.Children = nil
+		if err := db.Create(&clone).Error; err != nil {
+			return "", err
+		}
+		folderMap[f.ID] = clone.ID
+		im.result.Created["stickyFolders"]++
+		return clone.ID, nil
+	}
+	for _, f := range m.StickyFolders {
+		if _, err := create(f, 0); err != nil {
+			return err
+		}
+	}
+
+	for _, note := range m.StickyNotes {
+		channelID := im.freshChannel(note.ChannelID, "stickyNotes")
+		if channelID == "" {
+			continue
+		}
+		clone := note
+		clone.StringPKBaseModel = model.StringPKBaseModel{ID: utils.NewID()}
+		clone.ChannelID = channelID
+		clone.WorldID = im.worldID
+		clone.FolderID = folderMap[note.FolderID]
+		clone.CreatorID = im.actorID
+		clone.ViewerIDs = ""
+		clone.EditorIDs = ""
+		clone.EditingLockUserID = ""
+		clone.EditingLockSessionID = ""
+		clone.EditingLockExpireAt = nil
+		clone.IsDeleted = false
+		clone.DeletedAt = nil
+		clone.DeletedBy = ""
+		clone.Creator = nil
+		clone.EditingLockUser = nil
+		if err := db.Create(&clone).Error; err != nil {
+			return err
+		}
+		im.result.Created["stickyNotes"]++
+	}
+	return nil
+}
+
+func (im *worldPackageImporter) importChannelExtras() error {
+	db := model.GetDB()
+	for _, form := range im.pkg.manifest.IForms {
+		channelID := im.freshChannel(form.ChannelID, "iforms")
+		if channelID == "" {
+			continue
+		}
+		clone := form
+		clone.StringPKBaseModel = model.StringPKBaseModel{ID: utils.NewID()}
+		clone.ChannelID = channelID
+		clone.CreatedBy = im.actorID
+		clone.UpdatedBy = im.actorID
+		if err := db.Create(&clone).Error; err != nil {
+			return err
+		}
+		im.result.Created["iforms"]++
+	}
+	for _, macro := range im.pkg.manifest.DiceMacros {
+		channelID := im.freshChannel(macro.ChannelID, "diceMacros")
+		if channelID == "" {
+			continue
+		}
+		clone := macro
+		clone.StringPKBaseModel = model.StringPKBaseModel{ID: utils.NewID()}
+		clone.ChannelID = channelID
+		clone.UserID = im.actorID
+		if err := db.Create(&clone).Error; err != nil {
+			return err
+		}
+		im.result.Created["diceMacros"]++
+	}
+	return nil
+}
+
+func (im *worldPackageImporter) importAudio() error {
+	db := model.GetDB()
+	m := im.pkg.manifest
+	worldID := im.worldID
+
+	for _, asset := range m.AudioAssets {
+		// 同名同大小的世界音频视为同一文件，避免重复导入
+		var dup model.AudioAsset
+		if err := db.Where("scope = ? AND world_id = ? AND name = ? AND size = ? AND deleted_at IS NULL",
+			model.AudioScopeWorld, worldID, asset.Name, asset.Size).Limit(1).Find(&dup).Error; err != nil {
+			return err
+		}
+		if dup.ID != "" {
+			im.assets[asset.ID] = dup.ID
+			im.result.Skipped["audioAssets"]++
+			continue
+		}
+		tempPath, _, err := im.pkg.extractFile(asset.FileID, uploadSizeLimit(model.UploadPurposeAudio, ""))
+		if err != nil {
+			im.warn("音频 %s 导入失败: %v", asset.Name, err)
+			continue
+		}
+		created, err := AudioCreateAssetFromImport(tempPath, AudioUploadOptions{
+			Name:        asset.Name,
+			Tags:        asset.Tags,
+			Description: asset.Description,
+			Visibility:  model.AudioVisibilityPublic,
+			CreatedBy:   im.actorID,
+			Scope:       model.AudioScopeWorld,
+			WorldID:     &worldID,
+		})
+		_ = os.Remove(tempPath)
+		if err != nil {
+			im.warn("音频 %s 导入失败: %v", asset.Name, err)
+			continue
+		}
+		im.assets[asset.ID] = created.ID
+		im.result.Created["audioAssets"]++
+		assetID := created.ID
+		im.onRollback(func(*gorm.DB) error { return AudioDeleteAsset(assetID, true) })
+	}
+
+	for _, scene := range m.AudioScenes {
+		var channelScope *string
+		if scene.ChannelScope != nil && *scene.ChannelScope != "" {
+			channelID := im.freshChannel(*scene.ChannelScope, "audioScenes")
+			if channelID == "" {
+				continue
+			}
+			channelScope = &channelID
+		} else {
+			var dup model.AudioScene
+			if err := db.Where("scope = ? AND world_id = ? AND channel_scope IS NULL AND name = ?",
+				model.AudioScopeWorld, worldID, scene.Name).Limit(1).Find(&dup).Error; err != nil {
+				return err
+			}
+			if dup.ID != "" {
+				switch im.strategy {
+				case WorldPackageConflictSkip:
+					im.result.Skipped["audioScenes"]++
+					continue
+				case WorldPackageConflictOverwrite:
+					im.restoreOnRollback(&dup, "description", "tracks", "tags", "updated_by")
+					if err := db.Model(&model.AudioScene{}).Where("id = ?", dup.ID).Updates(map[string]any{
+						"description": scene.Description,
+						"tracks":      im.remapTracks(scene.Tracks),
+						"tags":        scene.Tags,
+						"updated_by":  im.actorID,
+					}).Error; err != nil {
+						return err
+					}
+					im.result.Created["audioScenes"]++
+					continue
+				default:
+					scene.Name += worldPackageRenameSuffix
+				}
+			}
+		}
+		clone := scene
+		clone.StringPKBaseModel = model.StringPKBaseModel{ID: utils.NewID()}
+		clone.Tracks = im.remapTracks(scene.Tracks)
+		clone.ChannelScope = channelScope
+		clone.Scope = model.AudioScopeWorld
+		clone.WorldID = &worldID
+		clone.CreatedBy = im.actorID
+		clone.UpdatedBy = im.actorID
+		if err := db.Create(&clone).Error; err != nil {
+			return err
+		}
+		sceneID := clone.ID
+		im.onRollback(func(db *gorm.DB) error {
+			return db.Where("id = ?", sceneID).Delete(&model.AudioScene{}).Error
+		})
+		im.result.Created["audioScenes"]++
+	}
+	return nil
+}
+
+func (im *worldPackageImporter) remapTracks(tracks model.JSONList[model.AudioSceneTrack]) model.JSONList[model.AudioSceneTrack] {
+	out := make(model.JSONList[model.AudioSceneTrack], 0, len(tracks))
+	for _, track := range tracks {
+		if track.AssetID != nil {
+			if id, ok := im.assets[*track.AssetID]; ok {
+				track.AssetID = &id
+			} else {
+				track.AssetID = nil
+			}
+		}
+		out = append(out, track)
+	}
+	return out
+}
+
+func (im *worldPackageImporter) importCardTemplates() error {
+	db := model.GetDB()
+	for _, tpl := range im.pkg.manifest.CardTemplates {
+		name := strings.TrimSpace(tpl.Name)
+		var dup model.CharacterCardTemplateModel
+		if err := db.Where("user_id = ? AND name = ?", im.actorID, name).Limit(1).Find(&dup).Error; err != nil {
+			return err
+		}
+		if dup.ID != "" {
+			switch im.strategy {
+			case WorldPackageConflictSkip:
+				im.result.Skipped["cardTemplates"]++
+				continue
+			case WorldPackageConflictOverwrite:
+				im.restoreOnRollback(&dup, "sheet_type", "content")
+				if err := db.Model(&model.CharacterCardTemplateModel{}).Where("id = ?", dup.ID).Updates(map[string]any{
+					"sheet_type": tpl.SheetType,
+					"content":    tpl.Content,
+				}).Error; err != nil {
+					return err
+				}
+				im.result.Created["cardTemplates"]++
+				continue
+			default:
+				name += worldPackageRenameSuffix
+			}
+		}
+		tplID := utils.NewID()
+		if err := db.Create(&model.CharacterCardTemplateModel{
+			StringPKBaseModel: model.StringPKBaseModel{ID: tplID},
+			UserID:            im.actorID,
+			Name:              name,
+			SheetType:         tpl.SheetType,
+			Content:           tpl.Content,
+		}).Error; err != nil {
+			return err
+		}
+		im.onRollback(func(db *gorm.DB) error {
+			return db.Where("id = ?", tplID).Delete(&model.CharacterCardTemplateModel{}).Error
+		})
+		im.result.Created["cardTemplates"]++
+	}
+	return nil
+}
+
+func (im *worldPackageImporter) importGallery() error {
+	db := model.GetDB()
+	m := im.pkg.manifest
+	collectionMap := map[string]string{}
+	collectionChannel := map[string]string{}
+	for _, col := range m.GalleryCollections {
+		channelID := im.freshChannel(col.OwnerID, "galleryCollections")
+		if channelID == "" {
+			continue
+		}
+		clone := col
+		clone.StringPKBaseModel = model.StringPKBaseModel{ID: utils.NewID()}
+		clone.OwnerType = model.OwnerTypeChannel
+		clone.OwnerID = channelID
+		clone.QuotaUsed = 0
+		clone.CreatedBy = im.actorID
+		clone.UpdatedBy = im.actorID
+		if err := db.Create(&clone).Error; err != nil {
+			return err
+		}
+		collectionMap[col.ID] = clone.ID
+		collectionChannel[clone.ID] = channelID
+		im.result.Created["galleryCollections"]++
+	}
+
+	quota := map[string]int64{}
+	for _, item := range m.GalleryItems {
+		collectionID := collectionMap[item.CollectionID]
+		if collectionID == "" {
+			im.result.Skipped["galleryItems"]++
+			continue
+		}
+		attID := im.attachment(item.AttachmentID, collectionChannel[collectionID])
+		if attID == "" {
+			im.result.Skipped["galleryItems"]++
+			continue
+		}
+		clone := item
+		clone.StringPKBaseModel = model.StringPKBaseModel{ID: utils.NewID()}
+		clone.CollectionID = collectionID
+		clone.AttachmentID = attID
+		clone.ThumbURL = ""
+		clone.CreatedBy = im.actorID
+		if err := db.Create(&clone).Error; err != nil {
+			return err
+		}
+		quota[collectionID] += clone.Size
+		im.result.Created["galleryItems"]++
+	}
+	for id, used := range quota {
+		if err := db.Model(&model.GalleryCollection{}).Where("id = ?", id).Update("quota_used", used).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
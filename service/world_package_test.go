@@ -0,0 +1,238 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/utils"
+)
+
+func buildTestWorldPackage(t *testing.T, manifest *WorldPackageManifest, files map[string]string) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(content))
+	}
+	w, err := zw.Create(worldPackageManifestName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.NewEncoder(w).Encode(manifest); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestWorldPackageOpen(t *testing.T) {
+	manifest := &WorldPackageManifest{
+		Version: WorldPackageVersion,
+		World:   WorldPackageWorld{Name: "克苏鲁的呼唤"},
+		Channels: []WorldPackageChannel{
+			{ID: "c3", ParentID: "c2", Name: "孙频道"},
+			{ID: "c2", ParentID: "c1", Name: "子频道"},
+			{ID: "c1", Name: "大厅", IsDefault: true},
+		},
+		Files: []WorldPackageFile{
+			{ID: "f1", Kind: "attachment", Path: "files/f1"},
+			{ID: "f2", Kind: "attachment", Path: "../manifest.json"},
+		},
+	}
+	r := buildTestWorldPackage(t, manifest, map[string]string{"files/f1": "hello"})
+	pkg, err := openWorldPackage(r, r.Size())
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+
+	order := pkg.sortedChannels()
+	if order[0].ID != "c1" || order[1].ID != "c2" || order[2].ID != "c3" {
+		t.Fatalf("parents should come first: %+v", order)
+	}
+
+	tempPath, _, err := pkg.extractFile("f1", 0)
+	if err != nil {
+		t.Fatalf("extract failed: %v", err)
+	}
+	data, _ := os.ReadFile(tempPath)
+	_ = os.Remove(tempPath)
+	if string(data) != "hello" {
+		t.Fatalf("unexpected file content: %q", data)
+	}
+	if _, _, err := pkg.extractFile("f1", 3); err == nil {
+		t.Fatalf("file over the limit should be rejected")
+	}
+	if _, _, err := pkg.extractFile("f2", 0); err == nil {
+		t.Fatalf("path outside files/ should be rejected")
+	}
+	if _, _, err := pkg.extractFile("missing", 0); err == nil {
+		t.Fatalf("missing file should error")
+	}
+}
+
+func TestWorldPackageRoundTrip(t *testing.T) {
+	initTestDB(t)
+	pm.Init()
+	db := model.GetDB()
+	ownerID := "pkgowner" + utils.NewIDWithLength(8)
+
+	world, lobby, err := WorldCreate(ownerID, WorldCreateParams{Name: "导出测试"})
+	if err != nil {
+		t.Fatalf("create world failed: %v", err)
+	}
+	child := ChannelNew(utils.NewIDWithLength(16), "public", "调查", world.ID, ownerID, lobby.ID)
+	if child == nil || child.ID == "" {
+		t.Fatalf("create channel failed")
+	}
+	if _, err := WorldKeywordCreate(world.ID, ownerID, WorldKeywordInput{Keyword: "深潜者", Description: "海里的东西"}); err != nil {
+		t.Fatalf("create keyword failed: %v", err)
+	}
+	if err := db.Create(&model.StickyNoteModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: utils.NewID()},
+		ChannelID:         child.ID,
+		WorldID:           world.ID,
+		Title:             "线索",
+		Content:           "<p>灯塔</p>",
+		CreatorID:         ownerID,
+		IsPublic:          true,
+	}).Error; err != nil {
+		t.Fatalf("create sticky note failed: %v", err)
+	}
+	roleName := "守秘人"
+	rolePerms := []string{"func_channel_read", "func_channel_text_send"}
+	gm, err := WorldRoleCreate(world.ID, ownerID, WorldRoleParams{Name: &roleName, Permissions: &rolePerms})
+	if err != nil {
+		t.Fatalf("create world role failed: %v", err)
+	}
+	if err := WorldRoleChannelOverrideRecord(model.WorldRoleChannelRoleID(child.ID, gm.ID), []string{"func_channel_read"}); err != nil {
+		t.Fatalf("record override failed: %v", err)
+	}
+	if err := db.Model(&model.ChannelModel{}).Where("id = ?", child.ID).
+		Update("gm_role_ids", model.JSONList[string]{model.WorldRoleChannelRoleID(child.ID, gm.ID)}).Error; err != nil {
+		t.Fatalf("set gm roles failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WorldPackageExport(world.ID, ownerID, &buf); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	r := bytes.NewReader(buf.Bytes())
+	result, err := WorldPackageImport(r, r.Size(), ownerID, WorldPackageImportOptions{Name: "导入测试"})
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if result.WorldID == "" || result.WorldID == world.ID {
+		t.Fatalf("import should create a new world: %+v", result)
+	}
+
+	imported, err := ChannelListByWorld(result.WorldID)
+	if err != nil || len(imported) != 2 {
+		t.Fatalf("expected 2 channels, got %d (%v)", len(imported), err)
+	}
+	newChild := result.ChannelMap[child.ID]
+	newLobby := result.ChannelMap[lobby.ID]
+	var childModel model.ChannelModel
+	db.Where("id = ?", newChild).Limit(1).Find(&childModel)
+	if childModel.Name != "调查" || childModel.ParentID != newLobby {
+		t.Fatalf("channel tree not restored: %+v", childModel)
+	}
+
+	var keywordCount int64
+	db.Model(&model.WorldKeywordModel{}).Where("world_id = ? AND keyword = ?", result.WorldID, "深潜者").Count(&keywordCount)
+	if keywordCount != 1 {
+		t.Fatalf("keyword not imported")
+	}
+	var notes []model.StickyNoteModel
+	db.Where("channel_id = ?", newChild).Find(&notes)
+	if len(notes) != 1 || notes[0].Title != "线索" || notes[0].WorldID != result.WorldID {
+		t.Fatalf("sticky note not imported: %+v", notes)
+	}
+
+	var newRole model.WorldRoleModel
+	db.Where("world_id = ? AND name = ?", result.WorldID, roleName).Limit(1).Find(&newRole)
+	if newRole.ID == "" || newRole.ID == gm.ID || len(newRole.Permissions) != 2 {
+		t.Fatalf("world role not imported: %+v", newRole)
+	}
+	var orphans int64
+	db.Model(&model.ChannelRoleModel{}).Where("id = ?", model.WorldRoleChannelRoleID(newChild, gm.ID)).Count(&orphans)
+	if orphans != 0 {
+		t.Fatalf("derived role of the source world should not be imported")
+	}
+	var derivedPerms []string
+	db.Model(&model.RolePermissionModel{}).Where("role_id = ?", model.WorldRoleChannelRoleID(newChild, newRole.ID)).Pluck("permission_id", &derivedPerms)
+	if len(derivedPerms) != 1 || derivedPerms[0] != "func_channel_read" {
+		t.Fatalf("channel override not applied to derived role: %v", derivedPerms)
+	}
+	if len(childModel.GMRoleIDs) != 1 || childModel.GMRoleIDs[0] != model.WorldRoleChannelRoleID(newChild, newRole.ID) {
+		t.Fatalf("gm role should point at the new derived role: %v", childModel.GMRoleIDs)
+	}
+
+	// 再导入到同一世界，默认跳过同名频道与已有术语
+	r = bytes.NewReader(buf.Bytes())
+	again, err := WorldPackageImport(r, r.Size(), ownerID, WorldPackageImportOptions{TargetWorldID: result.WorldID})
+	if err != nil {
+		t.Fatalf("import into existing world failed: %v", err)
+	}
+	if again.Created["channels"] != 0 || again.Skipped["channels"] != 2 || again.Skipped["keywords"] != 1 || again.Skipped["worldRoles"] != 1 {
+		t.Fatalf("unexpected merge result: created=%v skipped=%v", again.Created, again.Skipped)
+	}
+}
+
+func TestWorldPackageOpenInvalid(t *testing.T) {
+	r := bytes.NewReader([]byte("not a zip"))
+	if _, err := openWorldPackage(r, r.Size()); err == nil {
+		t.Fatalf("expected error for non-zip input")
+	}
+	r = buildTestWorldPackage(t, &WorldPackageManifest{Version: WorldPackageVersion + 1, World: WorldPackageWorld{Name: "x"}}, nil)
+	if _, err := openWorldPackage(r, r.Size()); err == nil {
+		t.Fatalf("expected error for unsupported version")
+	}
+	r = buildTestWorldPackage(t, &WorldPackageManifest{Version: WorldPackageVersion}, nil)
+	if _, err := openWorldPackage(r, r.Size()); err == nil {
+		t.Fatalf("expected error for missing world name")
+	}
+}
+
+func TestWorldPackageRollbackRestoresKeywords(t *testing.T) {
+	initTestDB(t)
+	pm.Init()
+	db := model.GetDB()
+	ownerID := "pkgowner" + utils.NewIDWithLength(8)
+	world, _, err := WorldCreate(ownerID, WorldCreateParams{Name: "回滚测试"})
+	if err != nil {
+		t.Fatalf("create world failed: %v", err)
+	}
+	kept, err := WorldKeywordCreate(world.ID, ownerID, WorldKeywordInput{Keyword: "旧词条", Description: "原文"})
+	if err != nil {
+		t.Fatalf("create keyword failed: %v", err)
+	}
+
+	im := &worldPackageImporter{worldID: world.ID, actorID: ownerID, strategy: WorldPackageConflictOverwrite}
+	if err := im.snapshotKeywords(); err != nil {
+		t.Fatalf("snapshot failed: %v", err)
+	}
+	if _, err := WorldKeywordImport(world.ID, ownerID, []WorldKeywordInput{
+		{Keyword: "旧词条", Description: "覆盖"},
+		{Keyword: "新词条", Description: "新增"},
+	}, true); err != nil {
+		t.Fatalf("keyword import failed: %v", err)
+	}
+	im.rollbackAll()
+
+	var items []model.WorldKeywordModel
+	db.Where("world_id = ?", world.ID).Find(&items)
+	if len(items) != 1 || items[0].ID != kept.ID || items[0].Description != "原文" {
+		t.Fatalf("keywords not restored: %+v", items)
+	}
+}
@@ -0,0 +1,546 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/pm/gen"
+	"sealchat/utils"
+)
+
+// 世界自定义角色：角色本身只保存一组频道权限，实际生效依赖为世界内每个频道派生的
+// 频道角色 ch-{channelId}-wr{roleId}。频道管理员修改派生角色权限时记为该频道的覆盖。
+
+var (
+	ErrWorldRoleNotFound = errors.New("世界角色不存在")
+	ErrWorldRoleInvalid  = errors.New("世界角色参数无效")
+)
+
+const (
+	worldRoleMaxCount   = 50
+	worldRoleMaxNameLen = 32
+	worldRoleMaxDescLen = 255
+)
+
+type WorldRoleParams struct {
+	Name        *string   `json:"name"`
+	Desc        *string   `json:"desc"`
+	Color       *string   `json:"color"`
+	Priority    *int      `json:"priority"`
+	Permissions *[]string `json:"permissions"`
+}
+
+// normalizeWorldRolePermissions 仅允许频道权限，去重后排序保证存储稳定
+func normalizeWorldRolePermissions(perms []string) ([]string, error) {
+	result := make([]string, 0, len(perms))
+	seen := map[string]struct{}{}
+	for _, item := range perms {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if _, ok := gen.PermChannelMap[item]; !ok {
+			return nil, fmt.Errorf("%w：未知的频道权限 %s", ErrWorldRoleInvalid, item)
+		}
+		if _, ok := seen[item]; ok {
+			continue
+		}
+		seen[item] = struct{}{}
+		result = append(result, item)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func applyWorldRoleParams(role *model.WorldRoleModel, params WorldRoleParams) error {
+	if params.Name != nil {
+		name := strings.TrimSpace(*params.Name)
+		if name == "" || len([]rune(name)) > worldRoleMaxNameLen {
+			return fmt.Errorf("%w：名称需为 1-%d 个字符", ErrWorldRoleInvalid, worldRoleMaxNameLen)
+		}
+		role.Name = name
+	}
+	if params.Desc != nil {
+		desc := strings.TrimSpace(*params.Desc)
+		if len([]rune(desc)) > worldRoleMaxDescLen {
+			return fmt.Errorf("%w：描述不能超过 %d 个字符", ErrWorldRoleInvalid, worldRoleMaxDescLen)
+		}
+		role.Desc = desc
+	}
+	if params.Color != nil {
+		color := strings.TrimSpace(*params.Color)
+		if len(color) > 16 {
+			return fmt.Errorf("%w：颜色格式无效", ErrWorldRoleInvalid)
+		}
+		role.Color = color
+	}
+	if params.Priority != nil {
+		role.Priority = *params.Priority
+	}
+	if params.Permissions != nil {
+		perms, err := normalizeWorldRolePermissions(*params.Permissions)
+		if err != nil {
+			return err
+		}
+		role.Permissions = perms
+	}
+	return nil
+}
+
+// worldRoleChannelRolePattern 匹配世界角色在所有频道中派生角色 ID 的 LIKE 模式，查询需写成 LIKE ? ESCAPE '!'
+func worldRoleChannelRolePattern(roleID string) string {
+	return "ch-%-" + utils.EscapeLike(model.WorldRoleChannelRoleKeyPrefix+roleID)
+}
+
+func worldRoleGet(worldID, roleID string) (*model.WorldRoleModel, error) {
+	var role model.WorldRoleModel
+	if err := model.GetDB().Where("id = ? AND world_id = ?", roleID, worldID).Limit(1).Find(&role).Error; err != nil {
+		return nil, err
+	}
+	if role.ID == "" {
+		return nil, ErrWorldRoleNotFound
+	}
+	return &role, nil
+}
+
+func worldRoleNameTaken(worldID, name, excludeID string) bool {
+	var count int64
+	q := model.GetDB().Model(&model.WorldRoleModel{}).Where("world_id = ? AND name = ?", worldID, name)
+	if excludeID != "" {
+		q = q.Where("id <> ?", excludeID)
+	}
+	q.Count(&count)
+	return count > 0
+}
+
+// WorldRoleList 世界成员可查看角色列表，按优先级从高到低
+func WorldRoleList(worldID, actorID string) ([]*model.WorldRoleModel, error) {
+	if !IsWorldMember(worldID, actorID) && !pm.CanWithSystemRole(actorID, pm.PermModAdmin) {
+		return nil, ErrWorldPermission
+	}
+	var items []*model.WorldRoleModel
+	db := model.GetDB()
+	if err := db.Where("world_id = ?", worldID).
+		Order("priority DESC").
+		Order("created_at ASC").
+		Find(&items).Error; err != nil {
+		return nil, err
+	}
+	var counts []struct {
+		RoleID string
+		Total  int64
+	}
+	db.Model(&model.WorldRoleAssignmentModel{}).
+		Select("role_id, COUNT(*) AS total").
+		Where("world_id = ?", worldID).
+		Group("role_id").
+		Scan(&counts)
+	countMap := lo.SliceToMap(counts, func(c struct {
+		RoleID string
+		Total  int64
+	}) (string, int64) {
+		return c.RoleID, c.Total
+	})
+	for _, item := range items {
+		item.MemberCount = countMap[item.ID]
+	}
+	return items, nil
+}
+
+// WorldRoleCreate 新建世界角色并派生到所有频道
+func WorldRoleCreate(worldID, actorID string, params WorldRoleParams) (*model.WorldRoleModel, error) {
+	if _, err := GetWorldByID(worldID); err != nil {
+		return nil, err
+	}
+	if !IsWorldAdmin(worldID, actorID) {
+		return nil, ErrWorldPermission
+	}
+	if params.Name == nil {
+		return nil, fmt.Errorf("%w：名称不能为空", ErrWorldRoleInvalid)
+	}
+	var count int64
+	model.GetDB().Model(&model.WorldRoleModel{}).Where("world_id = ?", worldID).Count(&count)
+	if count >= worldRoleMaxCount {
+		return nil, fmt.Errorf("%w：每个世界最多 %d 个角色", ErrWorldRoleInvalid, worldRoleMaxCount)
+	}
+	role := &model.WorldRoleModel{
+		WorldID:     worldID,
+		CreatorID:   actorID,
+		Permissions: model.JSONList[string]{},
+	}
+	if err := applyWorldRoleParams(role, params); err != nil {
+		return nil, err
+	}
+	if worldRoleNameTaken(worldID, role.Name, "") {
+		return nil, fmt.Errorf("%w：角色名称已存在", ErrWorldRoleInvalid)
+	}
+	if err := model.GetDB().Create(role).Error; err != nil {
+		return nil, err
+	}
+	if err := worldRolePropagate(role); err != nil {
+		return role, err
+	}
+	return role, nil
+}
+
+// WorldRoleUpdate 修改世界角色，权限变化会同步到未被覆盖的频道
+func WorldRoleUpdate(worldID, roleID, actorID string, params WorldRoleParams) (*model.WorldRoleModel, error) {
+	if !IsWorldAdmin(worldID, actorID) {
+		return nil, ErrWorldPermission
+	}
+	role, err := worldRoleGet(worldID, roleID)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyWorldRoleParams(role, params); err != nil {
+		return nil, err
+	}
+	if params.Name != nil && worldRoleNameTaken(worldID, role.Name, role.ID) {
+		return nil, fmt.Errorf("%w：角色名称已存在", ErrWorldRoleInvalid)
+	}
+	if err := model.GetDB().Model(role).Updates(map[string]any{
+		"name":        role.Name,
+		"desc":        role.Desc,
+		"color":       role.Color,
+		"priority":    role.Priority,
+		"permissions": role.Permissions,
+		"updated_at":  time.Now(),
+	}).Error; err != nil {
+		return nil, err
+	}
+	if params.Name != nil || params.Permissions != nil {
+		if err := worldRolePropagate(role); err != nil {
+			return role, err
+		}
+	}
+	return role, nil
+}
+
+// WorldRoleReorder 按给定顺序重排优先级，排在前面的优先级更高
+func WorldRoleReorder(worldID, actorID string, roleIDs []string) error {
+	if !IsWorldAdmin(worldID, actorID) {
+		return ErrWorldPermission
+	}
+	roleIDs = lo.Uniq(roleIDs)
+	return model.GetDB().Transaction(func(tx *gorm.DB) error {
+		for i, id := range roleIDs {
+			res := tx.Model(&model.WorldRoleModel{}).
+				Where("id = ? AND world_id = ?", id, worldID).
+				Updates(map[string]any{"priority": len(roleIDs) - i, "updated_at": time.Now()})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return ErrWorldRoleNotFound
+			}
+		}
+		return nil
+	})
+}
+
+// WorldRoleDelete 删除世界角色及其在各频道的派生角色
+func WorldRoleDelete(worldID, roleID, actorID string) error {
+	if !IsWorldAdmin(worldID, actorID) {
+		return ErrWorldPermission
+	}
+	role, err := worldRoleGet(worldID, roleID)
+	if err != nil {
+		return err
+	}
+	db := model.GetDB()
+	var userIDs []string
+	db.Model(&model.WorldRoleAssignmentModel{}).Where("role_id = ?", role.ID).Pluck("user_id", &userIDs)
+
+	if err := worldRolePurge(db, role); err != nil {
+		return err
+	}
+	model.PermCacheInvalidateUser(userIDs...)
+	return nil
+}
+
+// worldRolePurge 删除世界角色及其派生频道角色、持有记录与频道覆盖
+func worldRolePurge(db *gorm.DB, role *model.WorldRoleModel) error {
+	pattern := worldRoleChannelRolePattern(role.ID)
+	var channelRoleIDs []string
+	db.Model(&model.ChannelRoleModel{}).Where("id LIKE ? ESCAPE '!'", pattern).Pluck("id", &channelRoleIDs)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id LIKE ? ESCAPE '!'", pattern).Delete(&model.UserRoleMappingModel{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("role_id LIKE ? ESCAPE '!'", pattern).Delete(&model.RolePermissionModel{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id LIKE ? ESCAPE '!'", pattern).Delete(&model.ChannelRoleModel{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("role_id = ?", role.ID).Delete(&model.WorldRoleAssignmentModel{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("role_id = ?", role.ID).Delete(&model.WorldRoleChannelOverrideModel{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(role).Error
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range channelRoleIDs {
+		_ = pm.GetPerm().Remove(id)
+	}
+	return nil
+}
+
+// WorldRoleAssign 为成员授予角色
+func WorldRoleAssign(worldID, roleID, actorID string, userIDs []string) error {
+	if !IsWorldAdmin(worldID, actorID) {
+		return ErrWorldPermission
+	}
+	role, err := worldRoleGet(worldID, roleID)
+	if err != nil {
+		return err
+	}
+	userIDs = lo.Uniq(lo.Compact(userIDs))
+	for _, uid := range userIDs {
+		if !IsWorldMember(worldID, uid) {
+			return ErrWorldMemberInvalid
+		}
+	}
+	db := model.GetDB()
+	for _, uid := range userIDs {
+		var existing model.WorldRoleAssignmentModel
+		if err := db.Where("role_id = ? AND user_id = ?", role.ID, uid).Limit(1).Find(&existing).Error; err != nil {
+			return err
+		}
+		if existing.ID != "" {
+			continue
+		}
+		if err := db.Create(&model.WorldRoleAssignmentModel{WorldID: worldID, RoleID: role.ID, UserID: uid}).Error; err != nil {
+			return err
+		}
+	}
+	channels, err := ChannelListByWorld(worldID)
+	if err != nil {
+		return err
+	}
+	for _, ch := range channels {
+		for _, uid := range userIDs {
+			if _, err := model.MemberGetByUserIDAndChannelIDBase(uid, ch.ID, "", true); err != nil {
+				continue
+			}
+			if err := ensureChannelRoleLink(uid, ch.ID, model.WorldRoleChannelRoleKeyPrefix+role.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WorldRoleUnassign 收回成员的角色
+func WorldRoleUnassign(worldID, roleID, actorID string, userIDs []string) error {
+	if !IsWorldAdmin(worldID, actorID) {
+		return ErrWorldPermission
+	}
+	role, err := worldRoleGet(worldID, roleID)
+	if err != nil {
+		return err
+	}
+	userIDs = lo.Uniq(lo.Compact(userIDs))
+	if len(userIDs) == 0 {
+		return nil
+	}
+	db := model.GetDB()
+	if err := db.Where("role_id = ? AND user_id IN ?", role.ID, userIDs).Delete(&model.WorldRoleAssignmentModel{}).Error; err != nil {
+		return err
+	}
+	pattern := worldRoleChannelRolePattern(role.ID)
+	defer model.PermCacheInvalidateUser(userIDs...)
+	return db.Unscoped().Where("user_id IN ? AND role_id LIKE ? ESCAPE '!'", userIDs, pattern).Delete(&model.UserRoleMappingModel{}).Error
+}
+
+// WorldRoleIDsByUsers 返回成员持有的自定义角色 ID，供成员列表展示
+func WorldRoleIDsByUsers(worldID string, userIDs []string) (map[string][]string, error) {
+	result := map[string][]string{}
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+	var items []model.WorldRoleAssignmentModel
+	if err := model.GetDB().Where("world_id = ? AND user_id IN ?", worldID, userIDs).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		result[item.UserID] = append(result[item.UserID], item.RoleID)
+	}
+	return result, nil
+}
+
+// WorldRoleChannelOverrideList 列出角色在各频道的权限覆盖
+func WorldRoleChannelOverrideList(worldID, roleID, actorID string) ([]*model.WorldRoleChannelOverrideModel, error) {
+	if !IsWorldAdmin(worldID, actorID) {
+		return nil, ErrWorldPermission
+	}
+	var items []*model.WorldRoleChannelOverrideModel
+	err := model.GetDB().Where("world_id = ? AND role_id = ?", worldID, roleID).Find(&items).Error
+	return items, err
+}
+
+// WorldRoleChannelOverrideRecord 派生频道角色的权限被单独修改后记录为覆盖，避免下次同步被冲掉。
+// 不是派生角色时什么也不做。
+func WorldRoleChannelOverrideRecord(channelRoleID string, perms []string) error {
+	roleID := model.WorldRoleIDFromChannelRoleID(channelRoleID)
+	if roleID == "" {
+		return nil
+	}
+	channelID := model.ExtractChIdFromRoleId(channelRoleID)
+	db := model.GetDB()
+	var role model.WorldRoleModel
+	if err := db.Where("id = ?", roleID).Limit(1).Find(&role).Error; err != nil || role.ID == "" {
+		return err
+	}
+	// 与 pm.RolePermApply 一致，忽略非频道权限
+	normalized, _ := normalizeWorldRolePermissions(lo.Filter(perms, func(item string, _ int) bool {
+		_, ok := gen.PermChannelMap[strings.TrimSpace(item)]
+		return ok
+	}))
+	var existing model.WorldRoleChannelOverrideModel
+	if err := db.Where("role_id = ? AND channel_id = ?", roleID, channelID).Limit(1).Find(&existing).Error; err != nil {
+		return err
+	}
+	if existing.ID != "" {
+		return db.Model(&existing).Updates(map[string]any{
+			"permissions": model.JSONList[string](normalized),
+			"updated_at":  time.Now(),
+		}).Error
+	}
+	return db.Create(&model.WorldRoleChannelOverrideModel{
+		WorldID:     role.WorldID,
+		RoleID:      roleID,
+		ChannelID:   channelID,
+		Permissions: normalized,
+	}).Error
+}
+
+// WorldRoleChannelOverrideClear 移除频道覆盖，恢复为世界角色的权限
+func WorldRoleChannelOverrideClear(worldID, roleID, channelID, actorID string) error {
+	if !IsWorldAdmin(worldID, actorID) {
+		return ErrWorldPermission
+	}
+	role, err := worldRoleGet(worldID, roleID)
+	if err != nil {
+		return err
+	}
+	if err := model.GetDB().Where("role_id = ? AND channel_id = ?", role.ID, channelID).
+		Delete(&model.WorldRoleChannelOverrideModel{}).Error; err != nil {
+		return err
+	}
+	return worldRoleSyncChannel(role, channelID, nil)
+}
+
+func worldRoleOverrideMap(roleID string) map[string][]string {
+	var items []model.WorldRoleChannelOverrideModel
+	model.GetDB().Where("role_id = ?", roleID).Find(&items)
+	return lo.SliceToMap(items, func(item model.WorldRoleChannelOverrideModel) (string, []string) {
+		return item.ChannelID, item.Permissions
+	})
+}
+
+// worldRoleSyncChannel 确保派生频道角色存在并写入权限；override 为 nil 时使用世界角色权限
+func worldRoleSyncChannel(role *model.WorldRoleModel, channelID string, override []string) error {
+	channelRoleID := model.WorldRoleChannelRoleID(channelID, role.ID)
+	db := model.GetDB()
+	var existing model.ChannelRoleModel
+	if err := db.Where("id = ?", channelRoleID).Limit(1).Find(&existing).Error; err != nil {
+		return err
+	}
+	if existing.ID == "" {
+		if err := model.ChannelRoleCreate(&model.ChannelRoleModel{
+			StringPKBaseModel: model.StringPKBaseModel{ID: channelRoleID},
+			Name:              role.Name,
+			Desc:              "世界角色",
+			ChannelID:         channelID,
+		}); err != nil {
+			return err
+		}
+	} else if existing.Name != role.Name {
+		if err := db.Model(&existing).Update("name", role.Name).Error; err != nil {
+			return err
+		}
+	}
+	perms := []string(role.Permissions)
+	if override != nil {
+		perms = override
+	}
+	pm.RolePermApply(channelRoleID, perms)
+	return nil
+}
+
+// worldRolePropagate 将角色同步到世界内所有频道，并为持有者建立角色关联
+func worldRolePropagate(role *model.WorldRoleModel) error {
+	channels, err := ChannelListByWorld(role.WorldID)
+	if err != nil {
+		return err
+	}
+	overrides := worldRoleOverrideMap(role.ID)
+	for _, ch := range channels {
+		if ch == nil || strings.TrimSpace(ch.ID) == "" {
+			continue
+		}
+		if err := worldRoleSyncChannel(role, ch.ID, overrides[ch.ID]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncCustomWorldRolesForNewChannel 新频道创建后派生所有世界角色并关联持有者
+func syncCustomWorldRolesForNewChannel(worldID, channelID string) {
+	var roles []*model.WorldRoleModel
+	db := model.GetDB()
+	if err := db.Where("world_id = ?", worldID).Find(&roles).Error; err != nil || len(roles) == 0 {
+		return
+	}
+	for _, role := range roles {
+		if err := worldRoleSyncChannel(role, channelID, nil); err != nil {
+			log.Printf("同步世界角色到新频道失败 role=%s channel=%s err=%v", role.ID, channelID, err)
+			continue
+		}
+		var userIDs []string
+		db.Model(&model.WorldRoleAssignmentModel{}).Where("role_id = ?", role.ID).Pluck("user_id", &userIDs)
+		for _, uid := range userIDs {
+			if _, err := model.MemberGetByUserIDAndChannelIDBase(uid, channelID, "", true); err != nil {
+				continue
+			}
+			_ = ensureChannelRoleLink(uid, channelID, model.WorldRoleChannelRoleKeyPrefix+role.ID)
+		}
+	}
+}
+
+// revokeCustomWorldRoles 成员离开世界时收回所有自定义角色
+func revokeCustomWorldRoles(worldID, userID string) error {
+	db := model.GetDB()
+	var roleIDs []string
+	if err := db.Model(&model.WorldRoleAssignmentModel{}).
+		Where("world_id = ? AND user_id = ?", worldID, userID).
+		Pluck("role_id", &roleIDs).Error; err != nil {
+		return err
+	}
+	if len(roleIDs) == 0 {
+		return nil
+	}
+	defer model.PermCacheInvalidateUser(userID)
+	for _, roleID := range roleIDs {
+		pattern := worldRoleChannelRolePattern(roleID)
+		if err := db.Unscoped().Where("user_id = ? AND role_id LIKE ? ESCAPE '!'", userID, pattern).Delete(&model.UserRoleMappingModel{}).Error; err != nil {
+			return err
+		}
+	}
+	return db.Where("world_id = ? AND user_id = ?", worldID, userID).Delete(&model.WorldRoleAssignmentModel{}).Error
+}
@@ -8,12 +8,13 @@ import (
 	"testing"
 
 	"sealchat/model"
+	"sealchat/pm"
 	"sealchat/utils"
 )
 
 var testDBOnce sync.Once
 
-func initTestDB(t *testing.T) {
+func initTestDB(t testing.TB) {
 	t.Helper()
 	testDBOnce.Do(func() {
 		cfg := &utils.AppConfig{
@@ -112,3 +113,77 @@ func TestSyncWorldChannelRolesMemberPublicOnly(t *testing.T) {
 		t.Fatalf("role ids=%v expect %v", roleIDs, expected)
 	}
 }
+
+func TestWorldCustomRolePropagation(t *testing.T) {
+	worldID := "world-custom-role"
+	ownerID := "owner-custom-role"
+	playerID := "player-custom-role"
+	chA, chB := "ch-custom-a", "ch-custom-b"
+	seedTestWorld(t, testWorld{
+		World:   model.WorldModel{StringPKBaseModel: model.StringPKBaseModel{ID: worldID}, Name: "Custom Role World", OwnerID: ownerID},
+		Members: []string{playerID},
+		Channels: []model.ChannelModel{
+			{StringPKBaseModel: model.StringPKBaseModel{ID: chA}, PermType: "non-public"},
+			{StringPKBaseModel: model.StringPKBaseModel{ID: chB}, PermType: "non-public"},
+		},
+	})
+	pm.Init()
+	db := model.GetDB()
+
+	readPerm := pm.PermFuncChannelRead.ID()
+	sendPerm := pm.PermFuncChannelTextSend.ID()
+	name := "GM"
+	perms := []string{readPerm}
+	role, err := WorldRoleCreate(worldID, ownerID, WorldRoleParams{Name: &name, Permissions: &perms})
+	if err != nil {
+		t.Fatalf("create role failed: %v", err)
+	}
+	if _, err := WorldRoleCreate(worldID, playerID, WorldRoleParams{Name: &name}); err != ErrWorldPermission {
+		t.Fatalf("non-admin should not create roles, got %v", err)
+	}
+	if err := WorldRoleAssign(worldID, role.ID, ownerID, []string{playerID}); err != nil {
+		t.Fatalf("assign failed: %v", err)
+	}
+	if !pm.CanWithChannelRole(playerID, chA, pm.PermFuncChannelRead) || !pm.CanWithChannelRole(playerID, chB, pm.PermFuncChannelRead) {
+		t.Fatalf("assigned role should grant read in every channel")
+	}
+
+	// 频道 A 单独调整后，世界角色的修改不再覆盖它
+	roleA := model.WorldRoleChannelRoleID(chA, role.ID)
+	pm.RolePermApply(roleA, []string{readPerm})
+	if err := WorldRoleChannelOverrideRecord(roleA, []string{readPerm}); err != nil {
+		t.Fatalf("record override failed: %v", err)
+	}
+	perms = []string{readPerm, sendPerm}
+	if _, err := WorldRoleUpdate(worldID, role.ID, ownerID, WorldRoleParams{Permissions: &perms}); err != nil {
+		t.Fatalf("update role failed: %v", err)
+	}
+	if pm.CanWithChannelRole(playerID, chA, pm.PermFuncChannelTextSend) {
+		t.Fatalf("overridden channel should keep its own permissions")
+	}
+	if !pm.CanWithChannelRole(playerID, chB, pm.PermFuncChannelTextSend) {
+		t.Fatalf("role update should reach channels without override")
+	}
+
+	chC := "ch-custom-c"
+	if err := db.Create(&model.ChannelModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: chC},
+		WorldID:           worldID,
+		Name:              chC,
+		PermType:          "non-public",
+		Status:            "active",
+	}).Error; err != nil {
+		t.Fatalf("create channel failed: %v", err)
+	}
+	syncWorldRolesForNewChannel(worldID, chC)
+	if !pm.CanWithChannelRole(playerID, chC, pm.PermFuncChannelTextSend) {
+		t.Fatalf("new channel should receive world roles")
+	}
+
+	if err := WorldLeave(worldID, playerID); err != nil {
+		t.Fatalf("leave failed: %v", err)
+	}
+	if pm.CanWithChannelRole(playerID, chB, pm.PermFuncChannelRead) {
+		t.Fatalf("leaving the world should revoke custom roles")
+	}
+}
@@ -0,0 +1,624 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm/clause"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/utils"
+)
+
+// 世界场次排期：场次可按重复规则展开，成员对整个系列回复出席情况。
+// 提醒通过时间线与邮件发送给回复“参加/可能”的成员，开始时由 api 层在频道内发布开团消息。
+
+var (
+	ErrWorldSessionNotFound = errors.New("场次不存在")
+	ErrWorldSessionInvalid  = errors.New("场次参数无效")
+	ErrCalendarFeedInvalid  = errors.New("日历订阅地址无效")
+)
+
+const (
+	worldSessionMaxDuration    = 7 * 24 * time.Hour
+	worldSessionMaxReminder    = 24 * 60
+	worldSessionStartWindow    = 10 * time.Minute // 开始后仍补发开团消息的时间窗口，覆盖短暂停机
+	worldSessionListMaxRange   = 370 * 24 * time.Hour
+	worldSessionListMaxResults = 500
+)
+
+type WorldSessionParams struct {
+	ChannelID       string    `json:"channelId"`
+	Title           string    `json:"title"`
+	Description     string    `json:"description"`
+	StartAt         time.Time `json:"startAt"`
+	EndAt           time.Time `json:"endAt"`
+	Timezone        string    `json:"timezone"`
+	Recurrence      string    `json:"recurrence"`
+	ReminderMinutes int       `json:"reminderMinutes"`
+}
+
+// WorldSessionOccurrence 场次展开后的某一次
+type WorldSessionOccurrence struct {
+	Session *model.WorldSessionModel `json:"session"`
+	StartAt time.Time                `json:"startAt"`
+	EndAt   time.Time                `json:"endAt"`
+}
+
+// WorldSessionAnnouncement 到点需要在频道内发布的开团消息
+type WorldSessionAnnouncement struct {
+	Session      *model.WorldSessionModel
+	OccurrenceAt time.Time
+	EndAt        time.Time
+	Attendees    []string
+}
+
+func canManageWorldSession(worldID, channelID, actorID string) bool {
+	if IsWorldAdmin(worldID, actorID) {
+		return true
+	}
+	return channelID != "" && pm.CanWithChannelRole(actorID, channelID, pm.PermFuncChannelManageInfo)
+}
+
+func applyWorldSessionParams(session *model.WorldSessionModel, params WorldSessionParams) error {
+	title := strings.TrimSpace(params.Title)
+	if title == "" || len([]rune(title)) > 100 {
+		return fmt.Errorf("%w：标题需为 1-100 个字符", ErrWorldSessionInvalid)
+	}
+	desc := strings.TrimSpace(params.Description)
+	if len([]rune(desc)) > 2000 {
+		return fmt.Errorf("%w：简介不能超过 2000 个字符", ErrWorldSessionInvalid)
+	}
+	channelID := strings.TrimSpace(params.ChannelID)
+	channel, err := model.ChannelGet(channelID)
+	if err != nil || channel == nil || channel.ID == "" || channel.WorldID != session.WorldID || channel.Status != model.ChannelStatusActive {
+		return fmt.Errorf("%w：频道不属于该世界", ErrWorldSessionInvalid)
+	}
+	if channel.IsCategory() {
+		return fmt.Errorf("%w：分类不能作为开团频道", ErrWorldSessionInvalid)
+	}
+	if params.StartAt.IsZero() || !params.EndAt.After(params.StartAt) {
+		return fmt.Errorf("%w：结束时间需晚于开始时间", ErrWorldSessionInvalid)
+	}
+	if params.EndAt.Sub(params.StartAt) > worldSessionMaxDuration {
+		return fmt.Errorf("%w：单次时长不能超过 7 天", ErrWorldSessionInvalid)
+	}
+	tz := strings.TrimSpace(params.Timezone)
+	if tz != "" {
+		if _, err := time.LoadLocation(tz); err != nil {
+			return fmt.Errorf("%w：未知时区 %s", ErrWorldSessionInvalid, tz)
+		}
+	}
+	rec, err := parseSessionRecurrence(params.Recurrence)
+	if err != nil {
+		return fmt.Errorf("%w：%s", ErrWorldSessionInvalid, err.Error())
+	}
+	if params.ReminderMinutes < 0 || params.ReminderMinutes > worldSessionMaxReminder {
+		return fmt.Errorf("%w：提醒时间需在 0-%d 分钟之间", ErrWorldSessionInvalid, worldSessionMaxReminder)
+	}
+
+	session.ChannelID = channel.ID
+	session.Title = title
+	session.Description = desc
+	session.StartAt = params.StartAt.UTC()
+	session.EndAt = params.EndAt.UTC()
+	session.Timezone = tz
+	session.Recurrence = rec.String()
+	session.ReminderMinutes = params.ReminderMinutes
+	session.RecurrenceEnd = nil
+	if last, ok := sessionLastStart(session.StartAt.In(loadSessionLocation(tz)), rec); ok {
+		end := last.Add(session.EndAt.Sub(session.StartAt)).UTC()
+		session.RecurrenceEnd = &end
+	}
+	return nil
+}
+
+func worldSessionGet(worldID, sessionID string) (*model.WorldSessionModel, error) {
+	var session model.WorldSessionModel
+	if err := model.GetDB().Where("id = ? AND world_id = ?", sessionID, worldID).Limit(1).Find(&session).Error; err != nil {
+		return nil, err
+	}
+	if session.ID == "" {
+		return nil, ErrWorldSessionNotFound
+	}
+	return &session, nil
+}
+
+// WorldSessionCreate 世界管理员或目标频道的管理者可以排期
+func WorldSessionCreate(worldID, actorID string, params WorldSessionParams) (*model.WorldSessionModel, error) {
+	if _, err := GetWorldByID(worldID); err != nil {
+		return nil, err
+	}
+	if !canManageWorldSession(worldID, strings.TrimSpace(params.ChannelID), actorID) {
+		return nil, ErrWorldPermission
+	}
+	session := &model.WorldSessionModel{
+		WorldID:   worldID,
+		CreatorID: actorID,
+		Status:    model.WorldSessionStatusActive,
+	}
+	if err := applyWorldSessionParams(session, params); err != nil {
+		return nil, err
+	}
+	if err := model.GetDB().Create(session).Error; err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// WorldSessionUpdate 修改场次，已有的出席回复保留
+func WorldSessionUpdate(worldID, sessionID, actorID string, params WorldSessionParams) (*model.WorldSessionModel, error) {
+	session, err := worldSessionGet(worldID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != model.WorldSessionStatusActive {
+		return nil, fmt.Errorf("%w：场次已取消", ErrWorldSessionInvalid)
+	}
+	if !canManageWorldSession(worldID, session.ChannelID, actorID) ||
+		!canManageWorldSession(worldID, strings.TrimSpace(params.ChannelID), actorID) {
+		return nil, ErrWorldPermission
+	}
+	if err := applyWorldSessionParams(session, params); err != nil {
+		return nil, err
+	}
+	if err := model.GetDB().Model(session).Updates(map[string]any{
+		"channel_id":       session.ChannelID,
+		"title":            session.Title,
+		"description":      session.Description,
+		"start_at":         session.StartAt,
+		"end_at":           session.EndAt,
+		"timezone":         session.Timezone,
+		"recurrence":       session.Recurrence,
+		"recurrence_end":   session.RecurrenceEnd,
+		"reminder_minutes": session.ReminderMinutes,
+		"updated_at":       time.Now(),
+	}).Error; err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// WorldSessionCancel 取消场次并通知已回复参加的成员
+func WorldSessionCancel(worldID, sessionID, actorID string) (*model.WorldSessionModel, error) {
+	session, err := worldSessionGet(worldID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if !canManageWorldSession(worldID, session.ChannelID, actorID) {
+		return nil, ErrWorldPermission
+	}
+	if session.Status == model.WorldSessionStatusCancelled {
+		return session, nil
+	}
+	if err := model.GetDB().Model(session).Updates(map[string]any{
+		"status":     model.WorldSessionStatusCancelled,
+		"updated_at": time.Now(),
+	}).Error; err != nil {
+		return nil, err
+	}
+	session.Status = model.WorldSessionStatusCancelled
+	attendees := worldSessionAttendees(session)
+	worldSessionTimeline(lo.Without(attendees, actorID), actorID, session, "场次已取消："+session.Title, "")
+	return session, nil
+}
+
+// filterReadableWorldSessions 只保留 userID 能阅读其频道的场次，世界管理员可见全部
+func filterReadableWorldSessions(sessions []*model.WorldSessionModel, userID string) []*model.WorldSessionModel {
+	admins := map[string]bool{}
+	readable := map[string]bool{}
+	return lo.Filter(sessions, func(s *model.WorldSessionModel, _ int) bool {
+		admin, ok := admins[s.WorldID]
+		if !ok {
+			admin = IsWorldAdmin(s.WorldID, userID)
+			admins[s.WorldID] = admin
+		}
+		if admin {
+			return true
+		}
+		canRead, ok := readable[s.ChannelID]
+		if !ok {
+			canRead = CanReadChannelByUserId(userID, s.ChannelID)
+			readable[s.ChannelID] = canRead
+		}
+		return canRead
+	})
+}
+
+// WorldSessionList 展开 [from, to) 内的场次，进行中的场次也会包含；看不到的频道中的场次不返回
+func WorldSessionList(worldID, actorID string, from, to time.Time) ([]*WorldSessionOccurrence, error) {
+	member := IsWorldMember(worldID, actorID)
+	if !member && !pm.CanWithSystemRole(actorID, pm.PermModAdmin) {
+		return nil, ErrWorldPermission
+	}
+	if !to.After(from) || to.Sub(from) > worldSessionListMaxRange {
+		return nil, fmt.Errorf("%w：查询范围无效", ErrWorldSessionInvalid)
+	}
+	var sessions []*model.WorldSessionModel
+	if err := model.GetDB().
+		Where("world_id = ? AND status = ? AND start_at < ?", worldID, model.WorldSessionStatusActive, to).
+		Where("recurrence_end IS NULL OR recurrence_end > ?", from).
+		Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	if member {
+		sessions = filterReadableWorldSessions(sessions, actorID)
+	}
+	worldSessionHydrate(sessions, actorID)
+	items := expandWorldSessions(sessions, from, to, worldSessionListMaxResults)
+	return items, nil
+}
+
+func expandWorldSessions(sessions []*model.WorldSessionModel, from, to time.Time, limit int) []*WorldSessionOccurrence {
+	var items []*WorldSessionOccurrence
+	for _, session := range sessions {
+		rec, err := parseSessionRecurrence(session.Recurrence)
+		if err != nil {
+			continue
+		}
+		duration := session.EndAt.Sub(session.StartAt)
+		start := session.StartAt.In(loadSessionLocation(session.Timezone))
+		for _, occ := range sessionOccurrences(start, rec, from.Add(-duration), to, limit) {
+			if !occ.Add(duration).After(from) {
+				continue
+			}
+			items = append(items, &WorldSessionOccurrence{
+				Session: session,
+				StartAt: occ.UTC(),
+				EndAt:   occ.Add(duration).UTC(),
+			})
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].StartAt.Before(items[j].StartAt) })
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+	return items
+}
+
+// WorldSessionGet 场次详情
+func WorldSessionGet(worldID, sessionID, actorID string) (*model.WorldSessionModel, error) {
+	if !IsWorldMember(worldID, actorID) && !pm.CanWithSystemRole(actorID, pm.PermModAdmin) {
+		return nil, ErrWorldPermission
+	}
+	session, err := worldSessionGet(worldID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if IsWorldMember(worldID, actorID) && len(filterReadableWorldSessions([]*model.WorldSessionModel{session}, actorID)) == 0 {
+		return nil, ErrWorldSessionNotFound
+	}
+	worldSessionHydrate([]*model.WorldSessionModel{session}, actorID)
+	return session, nil
+}
+
+func worldSessionHydrate(sessions []*model.WorldSessionModel, userID string) {
+	if len(sessions) == 0 {
+		return
+	}
+	ids := lo.Map(sessions, func(s *model.WorldSessionModel, _ int) string { return s.ID })
+	var rsvps []model.WorldSessionRSVPModel
+	model.GetDB().Where("session_id IN ?", ids).Find(&rsvps)
+	byID := lo.SliceToMap(sessions, func(s *model.WorldSessionModel) (string, *model.WorldSessionModel) { return s.ID, s })
+	for _, s := range sessions {
+		s.RSVPCounts = map[string]int{}
+	}
+	for _, item := range rsvps {
+		s := byID[item.SessionID]
+		if s == nil {
+			continue
+		}
+		s.RSVPCounts[item.Status]++
+		if item.UserID == userID {
+			s.MyRSVP = item.Status
+		}
+	}
+}
+
+// WorldSessionRSVPSet 回复出席情况
+func WorldSessionRSVPSet(worldID, sessionID, userID, status, note string) (*model.WorldSessionRSVPModel, error) {
+	if !IsWorldMember(worldID, userID) {
+		return nil, ErrWorldPermission
+	}
+	status = strings.TrimSpace(status)
+	if status != model.WorldSessionRSVPYes && status != model.WorldSessionRSVPNo && status != model.WorldSessionRSVPMaybe {
+		return nil, fmt.Errorf("%w：出席状态仅支持 yes/no/maybe", ErrWorldSessionInvalid)
+	}
+	note = strings.TrimSpace(note)
+	if len([]rune(note)) > 255 {
+		return nil, fmt.Errorf("%w：备注不能超过 255 个字符", ErrWorldSessionInvalid)
+	}
+	session, err := worldSessionGet(worldID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != model.WorldSessionStatusActive {
+		return nil, fmt.Errorf("%w：场次已取消", ErrWorldSessionInvalid)
+	}
+	db := model.GetDB()
+	var rsvp model.WorldSessionRSVPModel
+	if err := db.Where("session_id = ? AND user_id = ?", session.ID, userID).Limit(1).Find(&rsvp).Error; err != nil {
+		return nil, err
+	}
+	if rsvp.ID != "" {
+		rsvp.Status = status
+		rsvp.Note = note
+		if err := db.Model(&rsvp).Updates(map[string]any{"status": status, "note": note, "updated_at": time.Now()}).Error; err != nil {
+			return nil, err
+		}
+		return &rsvp, nil
+	}
+	rsvp = model.WorldSessionRSVPModel{
+		WorldID:   worldID,
+		SessionID: session.ID,
+		UserID:    userID,
+		Status:    status,
+		Note:      note,
+	}
+	if err := db.Create(&rsvp).Error; err != nil {
+		return nil, err
+	}
+	return &rsvp, nil
+}
+
+// WorldSessionRSVPList 列出场次的出席回复
+func WorldSessionRSVPList(worldID, sessionID, actorID string) ([]*model.WorldSessionRSVPModel, error) {
+	if !IsWorldMember(worldID, actorID) && !pm.CanWithSystemRole(actorID, pm.PermModAdmin) {
+		return nil, ErrWorldPermission
+	}
+	if _, err := worldSessionGet(worldID, sessionID); err != nil {
+		return nil, err
+	}
+	var items []*model.WorldSessionRSVPModel
+	db := model.GetDB()
+	if err := db.Where("session_id = ?", sessionID).Order("updated_at DESC").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	userIDs := lo.Map(items, func(item *model.WorldSessionRSVPModel, _ int) string { return item.UserID })
+	if len(userIDs) > 0 {
+		var users []*model.UserModel
+		db.Where("id IN ?", userIDs).Select("id, username, nickname, avatar").Find(&users)
+		byID := lo.SliceToMap(users, func(u *model.UserModel) (string, *model.UserModel) { return u.ID, u })
+		for _, item := range items {
+			item.User = byID[item.UserID]
+		}
+	}
+	return items, nil
+}
+
+// worldSessionAttendees 回复参加或可能参加、且仍在世界中的成员
+func worldSessionAttendees(session *model.WorldSessionModel) []string {
+	var userIDs []string
+	model.GetDB().Model(&model.WorldSessionRSVPModel{}).
+		Where("session_id = ? AND status IN ?", session.ID, []string{model.WorldSessionRSVPYes, model.WorldSessionRSVPMaybe}).
+		Pluck("user_id", &userIDs)
+	return lo.Filter(userIDs, func(id string, _ int) bool { return IsWorldMember(session.WorldID, id) })
+}
+
+// worldSessionClaim 抢占某次场次的通知，返回 false 表示已由其他实例或之前的轮次处理
+func worldSessionClaim(sessionID string, occurrence time.Time, kind string) bool {
+	res := model.GetDB().Clauses(clause.OnConflict{DoNothing: true}).Create(&model.WorldSessionNotifyLogModel{
+		SessionID:    sessionID,
+		OccurrenceAt: occurrence.UTC().Truncate(time.Second),
+		Kind:         kind,
+	})
+	return res.Error == nil && res.RowsAffected == 1
+}
+
+// WorldSessionProcessDue 发送到期的提醒，并返回需要发布开团消息的场次，由 api 层定时调用
+func WorldSessionProcessDue(now time.Time) []*WorldSessionAnnouncement {
+	var sessions []*model.WorldSessionModel
+	if err := model.GetDB().
+		Where("status = ? AND start_at <= ?", model.WorldSessionStatusActive, now.Add(worldSessionMaxReminder*time.Minute)).
+		Where("recurrence_end IS NULL OR recurrence_end >= ?", now.Add(-worldSessionStartWindow)).
+		Find(&sessions).Error; err != nil {
+		log.Printf("world-session: 查询场次失败: %v", err)
+		return nil
+	}
+	var announcements []*WorldSessionAnnouncement
+	for _, session := range sessions {
+		rec, err := parseSessionRecurrence(session.Recurrence)
+		if err != nil {
+			continue
+		}
+		start := session.StartAt.In(loadSessionLocation(session.Timezone))
+		duration := session.EndAt.Sub(session.StartAt)
+		from := now.Add(-worldSessionStartWindow)
+		to := now.Add(time.Duration(session.ReminderMinutes)*time.Minute + time.Second)
+		for _, occ := range sessionOccurrences(start, rec, from, to, 10) {
+			if !occ.After(now) {
+				if worldSessionClaim(session.ID, occ, model.WorldSessionNotifyStart) {
+					announcements = append(announcements, &WorldSessionAnnouncement{
+						Session:      session,
+						OccurrenceAt: occ,
+						EndAt:        occ.Add(duration),
+						Attendees:    worldSessionAttendees(session),
+					})
+				}
+				continue
+			}
+			if session.ReminderMinutes > 0 && worldSessionClaim(session.ID, occ, model.WorldSessionNotifyReminder) {
+				worldSessionRemind(session, occ)
+			}
+		}
+	}
+	return announcements
+}
+
+var worldSessionWorkerOnce sync.Once
+
+// StartWorldSessionWorker 定时检查到期的场次，announce 负责在频道内发布开团消息
+func StartWorldSessionWorker(interval time.Duration, announce func(*WorldSessionAnnouncement)) {
+	worldSessionWorkerOnce.Do(func() {
+		if interval <= 0 {
+			interval = 30 * time.Second
+		}
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				for _, item := range WorldSessionProcessDue(time.Now()) {
+					if announce != nil {
+						announce(item)
+					}
+				}
+				<-ticker.C
+			}
+		}()
+	})
+}
+
+func worldSessionFormatTime(session *model.WorldSessionModel, t time.Time) string {
+	loc := loadSessionLocation(session.Timezone)
+	return t.In(loc).Format("2006-01-02 15:04") + " (" + loc.String() + ")"
+}
+
+func worldSessionRemind(session *model.WorldSessionModel, occurrence time.Time) {
+	attendees := worldSessionAttendees(session)
+	if len(attendees) == 0 {
+		return
+	}
+	title := "场次即将开始：" + session.Title
+	brief := "开始时间 " + worldSessionFormatTime(session, occurrence)
+	worldSessionTimeline(attendees, session.CreatorID, session, title, brief)
+	world, err := GetWorldByID(session.WorldID)
+	if err != nil {
+		return
+	}
+	for _, uid := range attendees {
+		worldSessionSendEmail(uid, world, session, title, brief)
+	}
+}
+
+func worldSessionTimeline(receiverIDs []string, senderID string, session *model.WorldSessionModel, title, brief string) {
+	items := make([]*model.TimelineModel, 0, len(receiverIDs))
+	for _, id := range lo.Uniq(receiverIDs) {
+		if strings.TrimSpace(id) == "" {
+			continue
+		}
+		items = append(items, &model.TimelineModel{
+			StringPKBaseModel: model.StringPKBaseModel{ID: utils.NewID()},
+			Type:              "world.session",
+			Title:             title,
+			Brief:             brief,
+			UserID:            senderID,
+			SenderId:          senderID,
+			ReceiverId:        id,
+			LocPostType:       "channel",
+			LocPostID:         session.ChannelID,
+			RelatedType:       "world_session",
+			RelatedID:         session.ID,
+		})
+	}
+	if len(items) == 0 {
+		return
+	}
+	if err := model.GetDB().CreateInBatches(items, 50).Error; err != nil {
+		log.Printf("world-session: 写入时间线失败: %v", err)
+	}
+}
+
+func worldSessionSendEmail(userID string, world *model.WorldModel, session *model.WorldSessionModel, title, brief string) {
+	cfg := utils.GetConfig()
+	if cfg == nil {
+		return
+	}
+	user := model.UserGet(userID)
+	if user == nil || user.Email == nil || strings.TrimSpace(*user.Email) == "" || !user.EmailVerified {
+		return
+	}
+	svc := NewEmailService(cfg.EmailNotification.SMTP)
+	if !svc.IsConfigured() {
+		return
+	}
+	var sb strings.Builder
+	sb.WriteString(`<!DOCTYPE html><html><head><meta charset="UTF-8"></head><body style="font-family: sans-serif;">`)
+	sb.WriteString("<h3>" + escapeHTML(title) + "</h3>")
+	sb.WriteString("<p>" + escapeHTML(brief) + "</p>")
+	if session.Description != "" {
+		sb.WriteString("<p>" + escapeHTML(session.Description) + "</p>")
+	}
+	if link := resolveChannelURLForEmail(session.ChannelID, cfg.Domain); link != "" {
+		sb.WriteString(fmt.Sprintf(`<p><a href="%s">前往世界「%s」</a></p>`, escapeHTML(link), escapeHTML(world.Name)))
+	}
+	sb.WriteString("</body></html>")
+	if err := svc.SendEmail(*user.Email, "SealChat "+title, sb.String()); err != nil {
+		log.Printf("world-session: 发送邮件失败: %v", err)
+	}
+}
+
+// CalendarFeedToken 获取用户的日历订阅令牌，rotate 为 true 时重新生成使旧地址失效
+func CalendarFeedToken(userID string, rotate bool) (string, error) {
+	db := model.GetDB()
+	var item model.CalendarFeedTokenModel
+	if err := db.Where("user_id = ?", userID).Limit(1).Find(&item).Error; err != nil {
+		return "", err
+	}
+	if item.ID != "" && !rotate {
+		return item.Token, nil
+	}
+	token := utils.NewIDWithLength(32)
+	if item.ID != "" {
+		if err := db.Model(&item).Updates(map[string]any{"token": token, "updated_at": time.Now()}).Error; err != nil {
+			return "", err
+		}
+		return token, nil
+	}
+	if err := db.Create(&model.CalendarFeedTokenModel{UserID: userID, Token: token}).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// CalendarFeedRender 生成订阅令牌对应用户的 iCalendar 内容，包含其所在世界近期的场次，已回复不参加的除外
+func CalendarFeedRender(token string, now time.Time) ([]byte, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, ErrCalendarFeedInvalid
+	}
+	db := model.GetDB()
+	var item model.CalendarFeedTokenModel
+	if err := db.Where("token = ?", token).Limit(1).Find(&item).Error; err != nil {
+		return nil, err
+	}
+	if item.ID == "" {
+		return nil, ErrCalendarFeedInvalid
+	}
+	var worldIDs []string
+	if err := db.Model(&model.WorldMemberModel{}).Where("user_id = ?", item.UserID).Pluck("world_id", &worldIDs).Error; err != nil {
+		return nil, err
+	}
+	from := now.AddDate(0, 0, -30)
+	to := now.AddDate(0, 0, 365)
+	var sessions []*model.WorldSessionModel
+	if len(worldIDs) > 0 {
+		if err := db.Where("world_id IN ? AND status = ? AND start_at < ?", worldIDs, model.WorldSessionStatusActive, to).
+			Where("recurrence_end IS NULL OR recurrence_end > ?", from).
+			Find(&sessions).Error; err != nil {
+			return nil, err
+		}
+	}
+	sessions = filterReadableWorldSessions(sessions, item.UserID)
+	worldSessionHydrate(sessions, item.UserID)
+	sessions = lo.Filter(sessions, func(s *model.WorldSessionModel, _ int) bool {
+		return s.MyRSVP != model.WorldSessionRSVPNo
+	})
+	worlds := map[string]*model.WorldModel{}
+	for _, id := range lo.Uniq(lo.Map(sessions, func(s *model.WorldSessionModel, _ int) string { return s.WorldID })) {
+		if w, err := GetWorldByID(id); err == nil {
+			worlds[id] = w
+		}
+	}
+	siteURL := ""
+	if cfg := utils.GetConfig(); cfg != nil {
+		siteURL = strings.TrimRight(normalizeSiteURL(cfg.Domain), "/")
+	}
+	return renderWorldSessionICS(expandWorldSessions(sessions, from, to, 2000), worlds, siteURL, now), nil
+}
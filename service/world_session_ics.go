@@ -0,0 +1,76 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"sealchat/model"
+)
+
+const icsTimeLayout = "20060102T150405Z"
+
+// icsEscape 按 RFC 5545 转义 TEXT 值
+func icsEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, ";", `\;`)
+	s = strings.ReplaceAll(s, ",", `\,`)
+	s = strings.ReplaceAll(s, "\r\n", `\n`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return s
+}
+
+// icsWriteLine 写入一行内容，超过 75 字节时折行，不拆开 UTF-8 字符
+func icsWriteLine(sb *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		sb.WriteString(line[:cut])
+		sb.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74 // 续行首的空格占一个字节
+	}
+	sb.WriteString(line)
+	sb.WriteString("\r\n")
+}
+
+func renderWorldSessionICS(items []*WorldSessionOccurrence, worlds map[string]*model.WorldModel, siteURL string, now time.Time) []byte {
+	var sb strings.Builder
+	icsWriteLine(&sb, "BEGIN:VCALENDAR")
+	icsWriteLine(&sb, "VERSION:2.0")
+	icsWriteLine(&sb, "PRODID:-//SealChat//World Sessions//ZH")
+	icsWriteLine(&sb, "CALSCALE:GREGORIAN")
+	icsWriteLine(&sb, "METHOD:PUBLISH")
+	icsWriteLine(&sb, "X-WR-CALNAME:"+icsEscape("SealChat 场次"))
+	stamp := now.UTC().Format(icsTimeLayout)
+	for _, item := range items {
+		session := item.Session
+		summary := session.Title
+		if world := worlds[session.WorldID]; world != nil {
+			summary = fmt.Sprintf("[%s] %s", world.Name, session.Title)
+		}
+		icsWriteLine(&sb, "BEGIN:VEVENT")
+		icsWriteLine(&sb, fmt.Sprintf("UID:%s-%d@sealchat", session.ID, item.StartAt.Unix()))
+		icsWriteLine(&sb, "DTSTAMP:"+stamp)
+		icsWriteLine(&sb, "DTSTART:"+item.StartAt.UTC().Format(icsTimeLayout))
+		icsWriteLine(&sb, "DTEND:"+item.EndAt.UTC().Format(icsTimeLayout))
+		icsWriteLine(&sb, "SUMMARY:"+icsEscape(summary))
+		if session.Description != "" {
+			icsWriteLine(&sb, "DESCRIPTION:"+icsEscape(session.Description))
+		}
+		if siteURL != "" {
+			icsWriteLine(&sb, fmt.Sprintf("URL:%s/%s/%s", siteURL, session.WorldID, session.ChannelID))
+		}
+		if session.MyRSVP == model.WorldSessionRSVPMaybe {
+			icsWriteLine(&sb, "STATUS:TENTATIVE")
+		} else {
+			icsWriteLine(&sb, "STATUS:CONFIRMED")
+		}
+		icsWriteLine(&sb, "END:VEVENT")
+	}
+	icsWriteLine(&sb, "END:VCALENDAR")
+	return []byte(sb.String())
+}
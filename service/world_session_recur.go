@@ -0,0 +1,290 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 场次重复规则，支持 RFC 5545 RRULE 的常用子集：
+// FREQ=DAILY|WEEKLY|MONTHLY，INTERVAL，COUNT，UNTIL，以及 WEEKLY 下的 BYDAY。
+// 展开按场次时区的墙上时间进行，夏令时切换后仍保持同一钟点。
+
+// sessionRecurrenceMaxIterations 单次展开最多推进的周期数，从查询范围附近开始计数，
+// 因此只限制单个查询窗口的大小，不限制规则本身的跨度
+const sessionRecurrenceMaxIterations = 5000
+
+type sessionRecurrence struct {
+	Freq     string
+	Interval int
+	Count    int
+	Until    *time.Time
+	ByDay    []time.Weekday
+}
+
+var sessionWeekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+func parseSessionRecurrence(rule string) (*sessionRecurrence, error) {
+	rule = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:"))
+	if rule == "" {
+		return nil, nil
+	}
+	rec := &sessionRecurrence{Interval: 1}
+	for _, part := range strings.Split(rule, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("无效的重复规则片段: %s", part)
+		}
+		key = strings.ToUpper(strings.TrimSpace(key))
+		value = strings.ToUpper(strings.TrimSpace(value))
+		switch key {
+		case "FREQ":
+			if value != "DAILY" && value != "WEEKLY" && value != "MONTHLY" {
+				return nil, fmt.Errorf("不支持的重复频率: %s", value)
+			}
+			rec.Freq = value
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 || n > 365 {
+				return nil, fmt.Errorf("无效的重复间隔: %s", value)
+			}
+			rec.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 || n > 1000 {
+				return nil, fmt.Errorf("无效的重复次数: %s", value)
+			}
+			rec.Count = n
+		case "UNTIL":
+			t, err := parseSessionUntil(value)
+			if err != nil {
+				return nil, err
+			}
+			rec.Until = &t
+		case "BYDAY":
+			for _, code := range strings.Split(value, ",") {
+				day, ok := sessionWeekdayCodes[strings.TrimSpace(code)]
+				if !ok {
+					return nil, fmt.Errorf("无效的星期: %s", code)
+				}
+				rec.ByDay = append(rec.ByDay, day)
+			}
+		default:
+			return nil, fmt.Errorf("不支持的重复规则字段: %s", key)
+		}
+	}
+	if rec.Freq == "" {
+		return nil, fmt.Errorf("重复规则缺少 FREQ")
+	}
+	if len(rec.ByDay) > 0 && rec.Freq != "WEEKLY" {
+		return nil, fmt.Errorf("BYDAY 仅支持每周重复")
+	}
+	if rec.Count > 0 && rec.Until != nil {
+		return nil, fmt.Errorf("COUNT 与 UNTIL 不能同时使用")
+	}
+	rec.ByDay = uniqueSortedWeekdays(rec.ByDay)
+	return rec, nil
+}
+
+func parseSessionUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			if layout == "20060102" {
+				// 仅日期时包含当天
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无效的截止时间: %s", value)
+}
+
+func uniqueSortedWeekdays(days []time.Weekday) []time.Weekday {
+	seen := map[time.Weekday]bool{}
+	result := make([]time.Weekday, 0, len(days))
+	for _, d := range days {
+		if !seen[d] {
+			seen[d] = true
+			result = append(result, d)
+		}
+	}
+	// 以周一为一周开始排序，与 RRULE 默认 WKST=MO 一致
+	sort.Slice(result, func(i, j int) bool {
+		return (result[i]+6)%7 < (result[j]+6)%7
+	})
+	return result
+}
+
+// String 规范化后的规则文本
+func (r *sessionRecurrence) String() string {
+	if r == nil {
+		return ""
+	}
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		codes := make([]string, 0, len(r.ByDay))
+		for _, d := range r.ByDay {
+			for code, wd := range sessionWeekdayCodes {
+				if wd == d {
+					codes = append(codes, code)
+				}
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, fmt.Sprintf("COUNT=%d", r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// skipPeriods 返回可以直接跳过的周期数，使展开从 from 之前不远处开始。
+// 有 COUNT 时必须从头计数，不能跳过
+func (r *sessionRecurrence) skipPeriods(start, from time.Time) int {
+	if r.Count > 0 || !from.After(start) {
+		return 0
+	}
+	from = from.In(start.Location())
+	var periods, margin int
+	switch r.Freq {
+	case "DAILY", "WEEKLY":
+		sy, sm, sd := start.Date()
+		fy, fm, fd := from.Date()
+		days := int(time.Date(fy, fm, fd, 0, 0, 0, 0, time.UTC).Sub(time.Date(sy, sm, sd, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+		periods, margin = days, 1
+		if r.Freq == "WEEKLY" {
+			periods = days / 7
+		}
+	case "MONTHLY":
+		// 31 日等日期会跳过若干月份，多留一些余量保证不漏掉 from 之前的最后一次
+		periods = (from.Year()-start.Year())*12 + int(from.Month()) - int(start.Month())
+		margin = 12
+	}
+	k := periods/r.Interval - margin
+	if k < 0 {
+		return 0
+	}
+	return k
+}
+
+// each 按时间顺序依次产出开始时间，fn 返回 false 时停止；
+// from 之前的周期会被跳过（有 COUNT 时除外），但仍可能产出少量早于 from 的时间，由调用方过滤
+func (r *sessionRecurrence) each(start, from time.Time, fn func(time.Time) bool) {
+	if r == nil {
+		fn(start)
+		return
+	}
+	emitted := 0
+	emit := func(t time.Time) bool {
+		if t.Before(start) {
+			return true
+		}
+		if r.Until != nil && t.After(*r.Until) {
+			return false
+		}
+		if r.Count > 0 && emitted >= r.Count {
+			return false
+		}
+		emitted++
+		return fn(t)
+	}
+	first := r.skipPeriods(start, from)
+	for k := first; k < first+sessionRecurrenceMaxIterations; k++ {
+		switch r.Freq {
+		case "DAILY":
+			if !emit(start.AddDate(0, 0, k*r.Interval)) {
+				return
+			}
+		case "WEEKLY":
+			if len(r.ByDay) == 0 {
+				if !emit(start.AddDate(0, 0, 7*k*r.Interval)) {
+					return
+				}
+				continue
+			}
+			// 本周一同一钟点为基准
+			offset := (int(start.Weekday()) + 6) % 7
+			weekStart := start.AddDate(0, 0, -offset+7*k*r.Interval)
+			for _, d := range r.ByDay {
+				if !emit(weekStart.AddDate(0, 0, (int(d)+6)%7)) {
+					return
+				}
+			}
+		case "MONTHLY":
+			t := start.AddDate(0, k*r.Interval, 0)
+			// 没有该日期的月份（如 31 日）跳过
+			if t.Day() != start.Day() {
+				continue
+			}
+			if !emit(t) {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// sessionOccurrences 返回开始时间落在 [from, to) 内的各次开始时间，最多 limit 个
+func sessionOccurrences(start time.Time, rec *sessionRecurrence, from, to time.Time, limit int) []time.Time {
+	var result []time.Time
+	rec.each(start, from, func(t time.Time) bool {
+		if !t.Before(to) {
+			return false
+		}
+		if !t.Before(from) {
+			result = append(result, t)
+		}
+		return limit <= 0 || len(result) < limit
+	})
+	return result
+}
+
+// sessionLastStart 有限重复时返回最后一次开始时间，无限重复返回 false
+func sessionLastStart(start time.Time, rec *sessionRecurrence) (time.Time, bool) {
+	if rec != nil && rec.Count == 0 && rec.Until == nil {
+		return time.Time{}, false
+	}
+	last := start
+	from := start
+	if rec != nil && rec.Until != nil {
+		from = *rec.Until
+	}
+	rec.each(start, from, func(t time.Time) bool {
+		last = t
+		return true
+	})
+	return last, true
+}
+
+func loadSessionLocation(name string) *time.Location {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
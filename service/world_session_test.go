@@ -0,0 +1,211 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"sealchat/model"
+	"sealchat/pm"
+	"sealchat/utils"
+)
+
+func TestSessionRecurrenceWeeklyByDay(t *testing.T) {
+	rec, err := parseSessionRecurrence("FREQ=WEEKLY;BYDAY=SA,WE;COUNT=4")
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if got := rec.String(); got != "FREQ=WEEKLY;BYDAY=WE,SA;COUNT=4" {
+		t.Fatalf("unexpected normalized rule: %s", got)
+	}
+	// 2024-06-06 为周四，首次应落在周六
+	start := time.Date(2024, 6, 6, 20, 0, 0, 0, time.UTC)
+	got := sessionOccurrences(start, rec, start, start.AddDate(1, 0, 0), 0)
+	want := []string{"2024-06-08", "2024-06-12", "2024-06-15", "2024-06-19"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d occurrences, got %v", len(want), got)
+	}
+	for i, occ := range got {
+		if occ.Format("2006-01-02") != want[i] || occ.Hour() != 20 {
+			t.Fatalf("occurrence %d = %v, want %s 20:00", i, occ, want[i])
+		}
+	}
+	last, ok := sessionLastStart(start, rec)
+	if !ok || last.Format("2006-01-02") != "2024-06-19" {
+		t.Fatalf("unexpected last start: %v %v", last, ok)
+	}
+}
+
+func TestSessionRecurrenceMonthlySkipsShortMonths(t *testing.T) {
+	rec, err := parseSessionRecurrence("RRULE:FREQ=MONTHLY;UNTIL=20240601")
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	start := time.Date(2024, 1, 31, 19, 0, 0, 0, time.UTC)
+	got := sessionOccurrences(start, rec, start, start.AddDate(1, 0, 0), 0)
+	var days []string
+	for _, occ := range got {
+		days = append(days, occ.Format("01-02"))
+	}
+	if strings.Join(days, ",") != "01-31,03-31,05-31" {
+		t.Fatalf("unexpected monthly occurrences: %v", days)
+	}
+}
+
+func TestSessionRecurrenceKeepsWallClockAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("tzdata unavailable")
+	}
+	rec, _ := parseSessionRecurrence("FREQ=WEEKLY")
+	start := time.Date(2024, 3, 2, 19, 0, 0, 0, loc)
+	got := sessionOccurrences(start, rec, start, start.AddDate(0, 0, 15), 0)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 occurrences, got %v", got)
+	}
+	for _, occ := range got {
+		if occ.In(loc).Hour() != 19 {
+			t.Fatalf("occurrence drifted from wall clock: %v", occ)
+		}
+	}
+}
+
+func TestSessionRecurrenceInvalid(t *testing.T) {
+	for _, rule := range []string{
+		"FREQ=YEARLY",
+		"INTERVAL=2",
+		"FREQ=DAILY;BYDAY=MO",
+		"FREQ=WEEKLY;COUNT=3;UNTIL=20240101",
+		"FREQ=WEEKLY;BYDAY=XX",
+	} {
+		if _, err := parseSessionRecurrence(rule); err == nil {
+			t.Fatalf("expected error for %q", rule)
+		}
+	}
+	if rec, err := parseSessionRecurrence(""); rec != nil || err != nil {
+		t.Fatalf("empty rule should mean single occurrence")
+	}
+}
+
+func TestRenderWorldSessionICS(t *testing.T) {
+	start := time.Date(2024, 6, 8, 12, 0, 0, 0, time.UTC)
+	session := &model.WorldSessionModel{
+		StringPKBaseModel: model.StringPKBaseModel{ID: "s1"},
+		WorldID:           "w1",
+		ChannelID:         "c1",
+		Title:             "第一章; 序幕, 开始",
+		Description:       strings.Repeat("很长的简介", 20) + "\n第二行",
+		MyRSVP:            model.WorldSessionRSVPMaybe,
+	}
+	items := []*WorldSessionOccurrence{{Session: session, StartAt: start, EndAt: start.Add(3 * time.Hour)}}
+	worlds := map[string]*model.WorldModel{"w1": {Name: "测试世界"}}
+	out := string(renderWorldSessionICS(items, worlds, "https://chat.example.com", start))
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:s1-1717848000@sealchat\r\n",
+		"DTSTART:20240608T120000Z\r\n",
+		"DTEND:20240608T150000Z\r\n",
+		`SUMMARY:[测试世界] 第一章\; 序幕\, 开始`,
+		"URL:https://chat.example.com/w1/c1\r\n",
+		"STATUS:TENTATIVE\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("ics missing %q:\n%s", want, out)
+		}
+	}
+	for _, line := range strings.Split(out, "\r\n") {
+		if len(line) > 75 {
+			t.Fatalf("line not folded (%d bytes): %s", len(line), line)
+		}
+	}
+	unfolded := strings.ReplaceAll(out, "\r\n ", "")
+	if !strings.Contains(unfolded, `很长的简介很长的简介\n第二行`) {
+		t.Fatalf("folded description not restorable:\n%s", unfolded)
+	}
+}
+
+func TestWorldSessionClaimOnce(t *testing.T) {
+	initTestDB(t)
+	occ := time.Date(2024, 6, 8, 12, 0, 0, 0, time.UTC)
+	if !worldSessionClaim("session-claim", occ, model.WorldSessionNotifyStart) {
+		t.Fatalf("first claim should succeed")
+	}
+	if worldSessionClaim("session-claim", occ.In(time.FixedZone("UTC+8", 8*3600)), model.WorldSessionNotifyStart) {
+		t.Fatalf("duplicate claim should be rejected")
+	}
+	if !worldSessionClaim("session-claim", occ, model.WorldSessionNotifyReminder) {
+		t.Fatalf("different kind should be claimable")
+	}
+}
+
+func TestSessionRecurrenceFarFromStart(t *testing.T) {
+	daily, _ := parseSessionRecurrence("FREQ=DAILY")
+	start := time.Date(2000, 1, 1, 20, 0, 0, 0, time.UTC)
+	from := time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)
+	if got := sessionOccurrences(start, daily, from, from.AddDate(0, 0, 3), 0); len(got) != 3 || got[0].Format("2006-01-02 15:04") != "2030-06-01 20:00" {
+		t.Fatalf("daily rule should expand decades after its start: %v", got)
+	}
+
+	monthly, _ := parseSessionRecurrence("FREQ=MONTHLY")
+	start = time.Date(2000, 1, 31, 20, 0, 0, 0, time.UTC)
+	from = time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC)
+	if got := sessionOccurrences(start, monthly, from, from.AddDate(1, 0, 0), 0); len(got) != 7 {
+		t.Fatalf("expected 7 month-end occurrences in 2040, got %v", got)
+	}
+
+	until, _ := parseSessionRecurrence("FREQ=DAILY;UNTIL=20351231")
+	last, ok := sessionLastStart(time.Date(2000, 1, 1, 20, 0, 0, 0, time.UTC), until)
+	if !ok || last.Format("2006-01-02") != "2035-12-31" {
+		t.Fatalf("last start of a long UNTIL rule: %v %v", last, ok)
+	}
+}
+
+func TestWorldSessionListHidesUnreadableChannels(t *testing.T) {
+	initTestDB(t)
+	pm.Init()
+	ownerID := "sessowner" + utils.NewIDWithLength(8)
+	memberID := "sessmember" + utils.NewIDWithLength(8)
+
+	world, lobby, err := WorldCreate(ownerID, WorldCreateParams{Name: "场次可见性"})
+	if err != nil {
+		t.Fatalf("create world failed: %v", err)
+	}
+	secret := ChannelNew(utils.NewIDWithLength(16), "non-public", "密室", world.ID, ownerID, "")
+	if secret == nil || secret.ID == "" {
+		t.Fatalf("create channel failed")
+	}
+	if _, err := WorldJoin(world.ID, memberID, model.WorldRoleMember); err != nil {
+		t.Fatalf("join failed: %v", err)
+	}
+
+	start := time.Now().Add(time.Hour)
+	params := WorldSessionParams{Title: "公开团", ChannelID: lobby.ID, StartAt: start, EndAt: start.Add(time.Hour)}
+	if _, err := WorldSessionCreate(world.ID, ownerID, params); err != nil {
+		t.Fatalf("create session failed: %v", err)
+	}
+	params.Title, params.ChannelID = "密团", secret.ID
+	if _, err := WorldSessionCreate(world.ID, ownerID, params); err != nil {
+		t.Fatalf("create session failed: %v", err)
+	}
+
+	from, to := time.Now(), time.Now().AddDate(0, 0, 1)
+	items, err := WorldSessionList(world.ID, memberID, from, to)
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(items) != 1 || items[0].Session.Title != "公开团" {
+		t.Fatalf("member should only see sessions in readable channels: %d", len(items))
+	}
+	if items, _ := WorldSessionList(world.ID, ownerID, from, to); len(items) != 2 {
+		t.Fatalf("world owner should see every session, got %d", len(items))
+	}
+
+	category := ChannelNewWithKind(utils.NewIDWithLength(16), model.ChannelKindCategory, "public", "分类", world.ID, ownerID, "")
+	params.ChannelID = category.ID
+	if _, err := WorldSessionCreate(world.ID, ownerID, params); !errors.Is(err, ErrWorldSessionInvalid) {
+		t.Fatalf("category channel should be rejected, got %v", err)
+	}
+}
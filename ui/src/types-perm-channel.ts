@@ -8,6 +8,7 @@ export interface ChannelRolePermSheet {
   func_channel_text_send_ooc: PermResult; // 频道 - 消息 - 场外文本发送
   func_channel_file_send: PermResult; // 频道 - 消息 - 文件发送
   func_channel_audio_send: PermResult; // 频道 - 消息 - 音频发送
+  func_channel_media_send: PermResult; // 频道 - 消息 - 视频及大文件发送
   func_channel_invite: PermResult; // 频道 - 常规 - 邀请加入频道
   func_channel_sub_channel_create: PermResult; // 频道 - 常规 - 创建子频道
   func_channel_member_remove: PermResult; // 频道 - 频道管理 - 踢人
@@ -41,6 +41,57 @@ type AudioConfig struct {
 	AllowNonAdminCreateWorld bool     `json:"allowNonAdminCreateWorld" yaml:"allowNonAdminCreateWorld"`
 }
 
+// ResumableUploadConfig 分片续传配置
+type ResumableUploadConfig struct {
+	ChunkSizeMB       int64  `json:"chunkSizeMB" yaml:"chunkSizeMB"`             // 单个分片的上限，同时作为推荐分片大小下发给客户端
+	SessionTTLMinutes int    `json:"sessionTTLMinutes" yaml:"sessionTTLMinutes"` // 会话无进展多久后过期
+	TempDir           string `json:"tempDir" yaml:"tempDir"`
+}
+
+// EventReplayConfig WebSocket 断线重连时的事件补发配置
+type EventReplayConfig struct {
+	MemoryPerChannel int  `json:"memoryPerChannel" yaml:"memoryPerChannel"` // 每个频道在内存中保留的最近事件数
+	MaxReplay        int  `json:"maxReplay" yaml:"maxReplay"`               // 单个频道最多补发的事件数，超出则要求客户端全量刷新
+	RetentionMinutes int  `json:"retentionMinutes" yaml:"retentionMinutes"` // 数据库中事件的保留时长
+	Persist          bool `json:"persist" yaml:"persist"`                   // 是否写入数据库，关闭后仅能从内存补发
+}
+
+// EventBusConfig 多实例部署时实例间同步推送所用的事件总线
+type EventBusConfig struct {
+	Driver     string `json:"driver" yaml:"driver"`         // local：单实例；postgres：通过主库的 LISTEN/NOTIFY 同步
+	Channel    string `json:"channel" yaml:"channel"`       // NOTIFY 频道名，同一集群的实例需保持一致
+	InstanceID string `json:"instanceId" yaml:"instanceId"` // 实例标识，留空时启动时随机生成
+}
+
+// WSTransportConfig WebSocket 传输选项，客户端在 OpIdentify 时声明需要的编码与压缩，未声明时保持 JSON 文本帧
+type WSTransportConfig struct {
+	Compression      bool `json:"compression" yaml:"compression"`           // 是否允许协商 permessage-deflate
+	CompressionLevel int  `json:"compressionLevel" yaml:"compressionLevel"` // 压缩级别 1-9，越大越省流量但更耗 CPU
+	Msgpack          bool `json:"msgpack" yaml:"msgpack"`                   // 是否允许使用 MessagePack 二进制帧
+}
+
+// OneBotConfig OneBot v11 适配。HTTP API 始终可用（/onebot/v11/:action，以 BOT 令牌鉴权），
+// Reverse 中的每一项由 SealChat 主动连接到 BOT 框架的反向 WebSocket 地址
+type OneBotConfig struct {
+	HeartbeatIntervalSec int                   `json:"heartbeatIntervalSec" yaml:"heartbeatIntervalSec"` // 心跳元事件间隔，0 为关闭
+	ReconnectIntervalSec int                   `json:"reconnectIntervalSec" yaml:"reconnectIntervalSec"` // 断线重连间隔
+	MessageIDRetainDays  int                   `json:"messageIdRetainDays" yaml:"messageIdRetainDays"`   // 消息数字编号的保留天数，过期后 BOT 无法再引用
+	Reverse              []OneBotReverseConfig `json:"-" yaml:"reverse"`                                 // 含令牌，禁止前端获取
+}
+
+type OneBotReverseConfig struct {
+	URL         string `yaml:"url"`         // 例如 ws://127.0.0.1:8080/onebot/v11/ws
+	BotToken    string `yaml:"botToken"`    // SealChat 中 BOT 的令牌，决定以哪个 BOT 身份接入
+	AccessToken string `yaml:"accessToken"` // BOT 框架要求的 access_token，可留空
+}
+
+// MediaConfig 视频与通用文件附件配置
+type MediaConfig struct {
+	MaxUploadSizeMB  int64    `json:"maxUploadSizeMB" yaml:"maxUploadSizeMB"`
+	AllowedMimeTypes []string `json:"allowedMimeTypes" yaml:"allowedMimeTypes"`
+	PreviewEnabled   bool     `json:"previewEnabled" yaml:"previewEnabled"`
+}
+
 type StorageMode string
 
 const (
@@ -60,6 +111,19 @@ const (
 	defaultBackupRetentionCount     = 5
 	defaultAuthTokenMaxAgeDays      = 15
 	defaultAuthRefreshThresholdDays = 7
+	defaultMediaMaxUploadSizeMB     = 200
+	defaultResumableChunkSizeMB     = 8
+	defaultResumableSessionTTLMin   = 24 * 60
+	defaultResumableTempDir         = "./data/temp/resumable"
+	defaultEventReplayMemory        = 256
+	defaultEventReplayMaxReplay     = 1000
+	defaultEventReplayRetentionMin  = 60
+	defaultEventBusDriver           = "local"
+	defaultEventBusChannel          = "sealchat_events"
+	defaultWSCompressionLevel       = 1
+	defaultOneBotHeartbeatSec       = 15
+	defaultOneBotReconnectSec       = 5
+	defaultOneBotMessageIDRetain    = 7
 )
 
 type CaptchaMode string
@@ -130,6 +194,8 @@ type S3StorageConfig struct {
 	PresignTTL         int    `json:"presignTTL" yaml:"presignTTL"`
 	MaxSizeMB          int64  `json:"maxSizeMB" yaml:"maxSizeMB"`
 	LogLevel           string `json:"logLevel" yaml:"logLevel"`
+	DirectUpload       bool   `json:"directUpload" yaml:"directUpload"`   // 客户端通过预签名 PUT 直传，不经过服务端
+	PrivateBucket      bool   `json:"privateBucket" yaml:"privateBucket"` // 私有桶，下载时签发短期 GET 链接
 }
 
 // SMTPConfig SMTP 邮件服务配置
@@ -217,12 +283,19 @@ type AppConfig struct {
 	ImageCompress             bool                    `json:"imageCompress" yaml:"imageCompress"`
 	ImageCompressQuality      int                     `json:"imageCompressQuality" yaml:"imageCompressQuality"`
 	KeywordMaxLength          int64                   `json:"keywordMaxLength" yaml:"keywordMaxLength"` // 术语最大字数
+	TrustedProxies            []string                `json:"trustedProxies" yaml:"trustedProxies"`     // 可信反向代理的 IP 或 CIDR，仅信任它们传入的 X-Real-IP/X-Forwarded-For
 	DSN                       string                  `json:"-" yaml:"dbUrl" koanf:"dbUrl"`
 	BuiltInSealBotEnable      bool                    `json:"builtInSealBotEnable" yaml:"builtInSealBotEnable"` // 内置小海豹启用
 	Version                   int                     `json:"version" yaml:"version"`
 	GalleryQuotaMB            int64                   `json:"galleryQuotaMB" yaml:"galleryQuotaMB"`
 	LogUpload                 LogUploadConfig         `json:"logUpload" yaml:"logUpload"`
 	Audio                     AudioConfig             `json:"audio" yaml:"audio"`
+	Media                     MediaConfig             `json:"media" yaml:"media"`
+	ResumableUpload           ResumableUploadConfig   `json:"resumableUpload" yaml:"resumableUpload"`
+	EventReplay               EventReplayConfig       `json:"eventReplay" yaml:"eventReplay"`
+	EventBus                  EventBusConfig          `json:"eventBus" yaml:"eventBus"`
+	WSTransport               WSTransportConfig       `json:"wsTransport" yaml:"wsTransport"`
+	OneBot                    OneBotConfig            `json:"oneBot" yaml:"oneBot"`
 	Export                    ExportConfig            `json:"export" yaml:"export"`
 	Storage                   StorageConfig           `json:"storage" yaml:"storage"`
 	SQLite                    SQLiteConfig            `json:"sqlite" yaml:"sqlite"`
@@ -285,6 +358,7 @@ func ReadConfig() *AppConfig {
 		ImageCompress:             true,
 		ImageCompressQuality:      85,
 		KeywordMaxLength:          2000,
+		TrustedProxies:            []string{"127.0.0.1", "::1"},
 		DSN:                       "./data/chat.db",
 		BuiltInSealBotEnable:      true,
 		Version:                   1,
@@ -311,6 +385,36 @@ func ReadConfig() *AppConfig {
 			AllowWorldAudioWorkbench: false,
 			AllowNonAdminCreateWorld: true,
 		},
+		Media: MediaConfig{
+			MaxUploadSizeMB:  defaultMediaMaxUploadSizeMB,
+			AllowedMimeTypes: DefaultMediaMimeTypes(),
+			PreviewEnabled:   true,
+		},
+		ResumableUpload: ResumableUploadConfig{
+			ChunkSizeMB:       defaultResumableChunkSizeMB,
+			SessionTTLMinutes: defaultResumableSessionTTLMin,
+			TempDir:           defaultResumableTempDir,
+		},
+		EventReplay: EventReplayConfig{
+			MemoryPerChannel: defaultEventReplayMemory,
+			MaxReplay:        defaultEventReplayMaxReplay,
+			RetentionMinutes: defaultEventReplayRetentionMin,
+			Persist:          true,
+		},
+		EventBus: EventBusConfig{
+			Driver:  defaultEventBusDriver,
+			Channel: defaultEventBusChannel,
+		},
+		WSTransport: WSTransportConfig{
+			Compression:      true,
+			CompressionLevel: defaultWSCompressionLevel,
+			Msgpack:          true,
+		},
+		OneBot: OneBotConfig{
+			HeartbeatIntervalSec: defaultOneBotHeartbeatSec,
+			ReconnectIntervalSec: defaultOneBotReconnectSec,
+			MessageIDRetainDays:  defaultOneBotMessageIDRetain,
+		},
 		Export: ExportConfig{
 			StorageDir:            defaultExportStorageDir,
 			DownloadBandwidthKBps: 0,
@@ -458,6 +562,12 @@ func ReadConfig() *AppConfig {
 	}
 	applyImageBaseURLFallback(&config)
 	applySQLiteDefaults(&config.SQLite)
+	applyMediaDefaults(&config.Media)
+	applyResumableUploadDefaults(&config.ResumableUpload)
+	applyEventReplayDefaults(&config.EventReplay)
+	applyEventBusDefaults(&config.EventBus)
+	applyWSTransportDefaults(&config.WSTransport)
+	applyOneBotDefaults(&config.OneBot)
 	applyExportDefaults(&config.Export)
 	config.Captcha.normalize()
 	applyEmailNotificationDefaults(&config.EmailNotification)
@@ -493,6 +603,113 @@ func applySQLiteDefaults(cfg *SQLiteConfig) {
 	}
 }
 
+// DefaultMediaMimeTypes 默认允许的视频与文档类型
+func DefaultMediaMimeTypes() []string {
+	return []string{
+		"video/mp4",
+		"video/webm",
+		"video/quicktime",
+		"video/x-matroska",
+		"application/pdf",
+		"text/plain",
+		"text/markdown",
+		"text/csv",
+		"application/json",
+		"application/zip",
+	}
+}
+
+func applyMediaDefaults(cfg *MediaConfig) {
+	if cfg == nil {
+		return
+	}
+	if cfg.MaxUploadSizeMB <= 0 {
+		cfg.MaxUploadSizeMB = defaultMediaMaxUploadSizeMB
+	}
+	if len(cfg.AllowedMimeTypes) == 0 {
+		cfg.AllowedMimeTypes = DefaultMediaMimeTypes()
+	}
+}
+
+func applyResumableUploadDefaults(cfg *ResumableUploadConfig) {
+	if cfg == nil {
+		return
+	}
+	if cfg.ChunkSizeMB <= 0 {
+		cfg.ChunkSizeMB = defaultResumableChunkSizeMB
+	}
+	if cfg.SessionTTLMinutes <= 0 {
+		cfg.SessionTTLMinutes = defaultResumableSessionTTLMin
+	}
+	if strings.TrimSpace(cfg.TempDir) == "" {
+		cfg.TempDir = defaultResumableTempDir
+	}
+}
+
+func applyEventReplayDefaults(cfg *EventReplayConfig) {
+	if cfg == nil {
+		return
+	}
+	if cfg.MemoryPerChannel <= 0 {
+		cfg.MemoryPerChannel = defaultEventReplayMemory
+	}
+	if cfg.MaxReplay <= 0 {
+		cfg.MaxReplay = defaultEventReplayMaxReplay
+	}
+	if cfg.RetentionMinutes <= 0 {
+		cfg.RetentionMinutes = defaultEventReplayRetentionMin
+	}
+}
+
+func applyEventBusDefaults(cfg *EventBusConfig) {
+	if cfg == nil {
+		return
+	}
+	cfg.Driver = strings.ToLower(strings.TrimSpace(cfg.Driver))
+	if cfg.Driver == "" {
+		cfg.Driver = defaultEventBusDriver
+	}
+	if strings.TrimSpace(cfg.Channel) == "" {
+		cfg.Channel = defaultEventBusChannel
+	}
+	cfg.InstanceID = strings.TrimSpace(cfg.InstanceID)
+}
+
+func applyWSTransportDefaults(cfg *WSTransportConfig) {
+	if cfg == nil {
+		return
+	}
+	if cfg.CompressionLevel < 1 || cfg.CompressionLevel > 9 {
+		cfg.CompressionLevel = defaultWSCompressionLevel
+	}
+}
+
+func applyOneBotDefaults(cfg *OneBotConfig) {
+	if cfg == nil {
+		return
+	}
+	if cfg.HeartbeatIntervalSec < 0 {
+		cfg.HeartbeatIntervalSec = 0
+	}
+	if cfg.ReconnectIntervalSec <= 0 {
+		cfg.ReconnectIntervalSec = defaultOneBotReconnectSec
+	}
+	if cfg.MessageIDRetainDays <= 0 {
+		cfg.MessageIDRetainDays = defaultOneBotMessageIDRetain
+	}
+	reverse := cfg.Reverse[:0]
+	for _, item := range cfg.Reverse {
+		item.URL = strings.TrimSpace(item.URL)
+		item.BotToken = strings.TrimSpace(item.BotToken)
+		item.AccessToken = strings.TrimSpace(item.AccessToken)
+		if item.URL == "" || item.BotToken == "" {
+			continue
+		}
+		reverse = append(reverse, item)
+	}
+	cfg.Reverse = reverse
+}
+
 func applyExportDefaults(cfg *ExportConfig) {
 	if cfg == nil {
 		return
@@ -713,6 +930,7 @@ func WriteConfig(config *AppConfig) {
 		_ = k.Set("imageCompress", config.ImageCompress)
 		_ = k.Set("imageCompressQuality", config.ImageCompressQuality)
 		_ = k.Set("keywordMaxLength", config.KeywordMaxLength)
+		_ = k.Set("trustedProxies", config.TrustedProxies)
 		_ = k.Set("builtInSealBotEnable", config.BuiltInSealBotEnable)
 		_ = k.Set("galleryQuotaMB", config.GalleryQuotaMB)
 		_ = k.Set("imageBaseUrl", config.ImageBaseURL)
@@ -735,6 +953,34 @@ func WriteConfig(config *AppConfig) {
 		_ = k.Set("audio.ffmpegPath", config.Audio.FFmpegPath)
 		_ = k.Set("audio.allowWorldAudioWorkbench", config.Audio.AllowWorldAudioWorkbench)
 		_ = k.Set("audio.allowNonAdminCreateWorld", config.Audio.AllowNonAdminCreateWorld)
+		_ = k.Set("media.maxUploadSizeMB", config.Media.MaxUploadSizeMB)
+		_ = k.Set("media.allowedMimeTypes", config.Media.AllowedMimeTypes)
+		_ = k.Set("media.previewEnabled", config.Media.PreviewEnabled)
+		_ = k.Set("resumableUpload.chunkSizeMB", config.ResumableUpload.ChunkSizeMB)
+		_ = k.Set("resumableUpload.sessionTTLMinutes", config.ResumableUpload.SessionTTLMinutes)
+		_ = k.Set("resumableUpload.tempDir", config.ResumableUpload.TempDir)
+		_ = k.Set("eventReplay.memoryPerChannel", config.EventReplay.MemoryPerChannel)
+		_ = k.Set("eventReplay.maxReplay", config.EventReplay.MaxReplay)
+		_ = k.Set("eventReplay.retentionMinutes", config.EventReplay.RetentionMinutes)
+		_ = k.Set("eventReplay.persist", config.EventReplay.Persist)
+		_ = k.Set("eventBus.driver", config.EventBus.Driver)
+		_ = k.Set("eventBus.channel", config.EventBus.Channel)
+		_ = k.Set("eventBus.instanceId", config.EventBus.InstanceID)
+		_ = k.Set("wsTransport.compression", config.WSTransport.Compression)
+		_ = k.Set("wsTransport.compressionLevel", config.WSTransport.CompressionLevel)
+		_ = k.Set("wsTransport.msgpack", config.WSTransport.Msgpack)
+		_ = k.Set("oneBot.heartbeatIntervalSec", config.OneBot.HeartbeatIntervalSec)
+		_ = k.Set("oneBot.reconnectIntervalSec", config.OneBot.ReconnectIntervalSec)
+		_ = k.Set("oneBot.messageIdRetainDays", config.OneBot.MessageIDRetainDays)
+		oneBotReverse := make([]map[string]any, 0, len(config.OneBot.Reverse))
+		for _, item := range config.OneBot.Reverse {
+			oneBotReverse = append(oneBotReverse, map[string]any{
+				"url":         item.URL,
+				"botToken":    item.BotToken,
+				"accessToken": item.AccessToken,
+			})
+		}
+		_ = k.Set("oneBot.reverse", oneBotReverse)
 		_ = k.Set("export.storageDir", config.Export.StorageDir)
 		_ = k.Set("export.downloadBandwidthKBps", config.Export.DownloadBandwidthKBps)
 		_ = k.Set("export.downloadBurstKB", config.Export.DownloadBurstKB)
@@ -770,6 +1016,8 @@ func WriteConfig(config *AppConfig) {
 		_ = k.Set("storage.s3.presignTTL", config.Storage.S3.PresignTTL)
 		_ = k.Set("storage.s3.maxSizeMB", config.Storage.S3.MaxSizeMB)
 		_ = k.Set("storage.s3.logLevel", config.Storage.S3.LogLevel)
+		_ = k.Set("storage.s3.directUpload", config.Storage.S3.DirectUpload)
+		_ = k.Set("storage.s3.privateBucket", config.Storage.S3.PrivateBucket)
 		_ = k.Set("captcha.mode", string(config.Captcha.Mode))
 		_ = k.Set("captcha.turnstile.siteKey", config.Captcha.Turnstile.SiteKey)
 		_ = k.Set("captcha.turnstile.secretKey", config.Captcha.Turnstile.SecretKey)
@@ -1257,6 +1505,9 @@ func EnsureDataDirs(cfg *AppConfig) {
 	if cfg.Export.StorageDir != "" {
 		dirs = append(dirs, cfg.Export.StorageDir)
 	}
+	if cfg.ResumableUpload.TempDir != "" {
+		dirs = append(dirs, cfg.ResumableUpload.TempDir)
+	}
 
 	// 创建所有目录
 	for _, dir := range dirs {
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"strings"
+
 	"github.com/samber/lo"
 	"gorm.io/gorm"
 )
@@ -63,3 +65,13 @@ func QueryOneToManyMap[K IDType, T IDType](db *gorm.DB, items []K, f1 func(i K)
 		f2(i, lst)
 	}
 }
+
+// LikeEscape 是 EscapeLike 使用的转义符，查询时需写成 `LIKE ? ESCAPE '!'`；选用 ! 是因为反斜杠在各数据库字符串字面量中的含义不一致
+const LikeEscape = "!"
+
+var likeEscaper = strings.NewReplacer(LikeEscape, LikeEscape+LikeEscape, "%", LikeEscape+"%", "_", LikeEscape+"_")
+
+// EscapeLike 转义用户输入中的 LIKE 通配符，使 % 与 _ 按字面匹配
+func EscapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
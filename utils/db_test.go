@@ -0,0 +1,17 @@
+package utils
+
+import "testing"
+
+func TestEscapeLike(t *testing.T) {
+	cases := map[string]string{
+		"plain": "plain",
+		"50%":   "50!%",
+		"a_b":   "a!_b",
+		"wow!":  "wow!!",
+	}
+	for input, want := range cases {
+		if got := EscapeLike(input); got != want {
+			t.Fatalf("EscapeLike(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
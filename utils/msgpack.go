@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// JSONToMsgpack 把 JSON 文本转成结构相同的 MessagePack。
+// 先走 JSON 序列化可以保留 json tag、omitempty 与各类自定义 MarshalJSON 的行为，
+// 两种编码下客户端拿到的字段完全一致
+func JSONToMsgpack(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return msgpack.Marshal(normalizeJSONNumbers(value))
+}
+
+// MsgpackToJSON 把客户端发来的 MessagePack 转回 JSON，供现有的 JSON 处理流程使用
+func MsgpackToJSON(data []byte) ([]byte, error) {
+	var value any
+	if err := msgpack.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	value, err := normalizeMsgpackKeys(value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+// normalizeJSONNumbers 整数编码为 MessagePack 整型，其余按 float64 处理
+func normalizeJSONNumbers(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for key, item := range v {
+			v[key] = normalizeJSONNumbers(item)
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = normalizeJSONNumbers(item)
+		}
+		return v
+	}
+	return value
+}
+
+// normalizeMsgpackKeys MessagePack 允许非字符串键，转 JSON 前统一成字符串
+func normalizeMsgpackKeys(value any) (any, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			normalized, err := normalizeMsgpackKeys(item)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = normalized
+		}
+		return v, nil
+	case map[any]any:
+		result := make(map[string]any, len(v))
+		for key, item := range v {
+			normalized, err := normalizeMsgpackKeys(item)
+			if err != nil {
+				return nil, err
+			}
+			switch k := key.(type) {
+			case string:
+				result[k] = normalized
+			case int8, int16, int32, int64, uint8, uint16, uint32, uint64:
+				result[fmt.Sprint(k)] = normalized
+			default:
+				return nil, fmt.Errorf("不支持的 MessagePack 键类型: %T", key)
+			}
+		}
+		return result, nil
+	case []any:
+		for i, item := range v {
+			normalized, err := normalizeMsgpackKeys(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = normalized
+		}
+		return v, nil
+	}
+	return value, nil
+}
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"sealchat/protocol"
+)
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	input := []byte(`{"op":0,"body":{"id":"abc","count":3,"ratio":1.5,"ok":true,"empty":null,"list":[1,"a",{"x":-2}]}}`)
+	packed, err := JSONToMsgpack(input)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	output, err := MsgpackToJSON(packed)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	var expected, actual any
+	_ = json.Unmarshal(input, &expected)
+	_ = json.Unmarshal(output, &actual)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("round trip mismatch:\nexpected %s\nactual   %s", input, output)
+	}
+}
+
+// sampleMessageListPayload 构造与 message.list 响应结构相近的负载：多条消息，每条带 User/Member
+func sampleMessageListPayload(count int) []byte {
+	users := []*protocol.User{
+		{ID: "u-4Fq2mZ8kLw", Name: "keeper", Nick: "守秘人", Avatar: "id:avatar-keeper-8d2f"},
+		{ID: "u-9Tb7xR1cPe", Name: "player_a", Nick: "调查员甲", Avatar: "id:avatar-a-71c0"},
+		{ID: "u-2Hn6vJ3sQa", Name: "player_b", Nick: "调查员乙", Avatar: "id:avatar-b-e93b"},
+	}
+	channel := &protocol.Channel{ID: "ch-Xy82Kd0qLm", Name: "主线剧情"}
+	items := make([]*protocol.Message, 0, count)
+	for i := 0; i < count; i++ {
+		user := users[i%len(users)]
+		items = append(items, &protocol.Message{
+			ID:           fmt.Sprintf("msg-%08d", i),
+			Channel:      channel,
+			User:         user,
+			Member:       &protocol.GuildMember{ID: "m-" + user.ID, User: user, Nick: user.Nick},
+			Content:      fmt.Sprintf("第 %d 条消息：门后传来低沉的脚步声，%s 握紧了手电筒。", i, user.Nick),
+			Timestamp:    1760000000000 + int64(i)*1500,
+			CreatedAt:    1760000000000 + int64(i)*1500,
+			UpdatedAt:    1760000000000 + int64(i)*1500,
+			DisplayOrder: float64(i) + 0.5,
+			IcMode:       "ic",
+		})
+	}
+	data, _ := json.Marshal(protocol.GatewayPayloadStructure{
+		Op:   protocol.OpEvent,
+		Body: map[string]any{"echo": "list-1", "data": map[string]any{"data": items, "next": "cursor"}},
+	})
+	return data
+}
+
+func deflateSize(data []byte, level int) int {
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, level)
+	_, _ = w.Write(data)
+	_ = w.Close()
+	return buf.Len()
+}
+
+// BenchmarkMessageListWire 比较 message.list 在各传输选项下的帧大小，压缩级别与默认配置一致
+func BenchmarkMessageListWire(b *testing.B) {
+	payload := sampleMessageListPayload(50)
+	packed, err := JSONToMsgpack(payload)
+	if err != nil {
+		b.Fatalf("encode failed: %v", err)
+	}
+	cases := []struct {
+		name string
+		run  func() int
+	}{
+		{"json", func() int { return len(payload) }},
+		{"json+deflate", func() int { return deflateSize(payload, defaultWSCompressionLevel) }},
+		{"msgpack", func() int {
+			out, _ := JSONToMsgpack(payload)
+			return len(out)
+		}},
+		{"msgpack+deflate", func() int { return deflateSize(packed, defaultWSCompressionLevel) }},
+	}
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			size := 0
+			for i := 0; i < b.N; i++ {
+				size = c.run()
+			}
+			b.ReportMetric(float64(size), "wire-bytes")
+		})
+	}
+}